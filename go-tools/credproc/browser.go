@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// openBrowser opens url in the system browser; tests substitute a fake.
+var openBrowser = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// randomString is n random bytes, base64url encoded.
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pkceChallenge is the S256 code challenge for verifier (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// callbackResult is what the loopback redirect brought back.
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// browserLogin runs the authorization code flow with PKCE: it listens on a
+// loopback port for the redirect, sends the user's browser to the
+// authorization endpoint, and exchanges the code once state matches. The ID
+// token must carry the nonce of the request.
+// Without a browser, the URL printed on prompt can be opened by hand.
+func browserLogin(ctx context.Context, meta *oidcMetadata, opts loginOptions, prompt io.Writer) (*tokenResponse, error) {
+	if meta.AuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("%s has no authorization_endpoint", opts.issuer)
+	}
+	// Loopback redirects may use any port (RFC 8252); --redirect-port is for
+	// IdPs that insist on a registered one.
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(opts.redirectPort)))
+	if err != nil {
+		return nil, fmt.Errorf("listening for the redirect: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	state := randomString(24)
+	nonce := randomString(16)
+	verifier := randomString(32)
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("authorization_endpoint: %w", err)
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", opts.clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", opts.scope)
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	authURL.RawQuery = q.Encode()
+
+	results := make(chan callbackResult, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var res callbackResult
+		switch {
+		case query.Get("state") != state:
+			// Not our request; keep waiting for the real redirect.
+			http.Error(w, "Sign-in failed: the state does not match this login. Start again with credproc login.", http.StatusBadRequest)
+			verbosef("Ignoring a redirect with the wrong state")
+			return
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("the IdP refused the sign-in: %s %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			res.err = fmt.Errorf("the redirect carries no authorization code")
+		default:
+			res.code = query.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, "Sign-in failed; see the terminal.", http.StatusBadRequest)
+		} else {
+			fmt.Fprint(w, "Signed in. You can close this tab and return to the terminal.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	fmt.Fprintf(prompt, "Opening your browser to sign in. If it does not open, visit:\n%s\n", authURL)
+	if !opts.noBrowser {
+		if err := openBrowser(authURL.String()); err != nil {
+			verbosef("Could not open a browser: %v", err)
+		}
+	}
+
+	var res callbackResult
+	select {
+	case <-ctx.Done():
+		return nil, loginCanceled(ctx, "completed")
+	case res = <-results:
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {opts.clientID},
+		"code_verifier": {verifier},
+	}
+	if opts.clientSecret != "" {
+		form.Set("client_secret", opts.clientSecret)
+	}
+	tr, status, err := postTokenRequest(ctx, meta.TokenEndpoint, form)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("code exchange: %w", err)
+	case tr.Error != "":
+		return nil, fmt.Errorf("code exchange: %s%s", tr.Error, describeOAuthError(tr))
+	case status != http.StatusOK:
+		return nil, fmt.Errorf("code exchange: token endpoint returned HTTP %d", status)
+	case tr.IDToken == "":
+		return nil, fmt.Errorf("code exchange: the response has no id_token (is the openid scope granted?)")
+	}
+	if err := checkJWTShape(tr.IDToken); err != nil {
+		return nil, fmt.Errorf("code exchange: id_token: %w", err)
+	}
+	// The nonce ties the ID token to this sign-in; a token minted for
+	// another one is replayed.
+	claims, err := tokenClaims(tr.IDToken)
+	if err != nil {
+		return nil, fmt.Errorf("code exchange: id_token: %w", err)
+	}
+	switch got, _ := claims["nonce"].(string); {
+	case got == "":
+		return nil, fmt.Errorf("code exchange: the ID token has no nonce claim; the IdP must echo the nonce of the sign-in request")
+	case got != nonce:
+		return nil, fmt.Errorf("code exchange: the ID token's nonce does not match this sign-in; it was issued for another one")
+	}
+	return tr, nil
+}
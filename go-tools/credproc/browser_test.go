@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCodeIdP simulates an IdP's authorization and token endpoints for the
+// authorization code flow. Its authorization endpoint approves at once,
+// redirecting with a code, or with deny set, an access_denied error. Its ID
+// tokens carry the nonce of the request, or what nonce makes of it.
+type fakeCodeIdP struct {
+	*httptest.Server
+	deny  bool
+	nonce func(sent string) string
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> code_challenge
+	redirects  map[string]string // code -> redirect_uri
+	nonces     map[string]string // code -> nonce
+}
+
+func newFakeCodeIdP(t *testing.T) *fakeCodeIdP {
+	idp := &fakeCodeIdP{challenges: map[string]string{}, redirects: map[string]string{}, nonces: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcMetadata{Issuer: idp.URL, AuthorizationEndpoint: idp.URL + "/authorize", TokenEndpoint: idp.URL + "/token"})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		redirect, err := url.Parse(q.Get("redirect_uri"))
+		if err != nil || q.Get("client_id") != "credproc" || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("state") == "" {
+			http.Error(w, "bad authorization request", http.StatusBadRequest)
+			return
+		}
+		back := url.Values{"state": {q.Get("state")}}
+		if idp.deny {
+			back.Set("error", "access_denied")
+			back.Set("error_description", "user declined")
+		} else {
+			code := randomString(8)
+			idp.mu.Lock()
+			idp.challenges[code] = q.Get("code_challenge")
+			idp.redirects[code] = q.Get("redirect_uri")
+			idp.nonces[code] = q.Get("nonce")
+			idp.mu.Unlock()
+			back.Set("code", code)
+		}
+		redirect.RawQuery = back.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.PostFormValue("code")
+		idp.mu.Lock()
+		challenge, redirect, nonce := idp.challenges[code], idp.redirects[code], idp.nonces[code]
+		delete(idp.challenges, code)
+		idp.mu.Unlock()
+		if idp.nonce != nil {
+			nonce = idp.nonce(nonce)
+		}
+		if r.PostFormValue("grant_type") != "authorization_code" || challenge == "" ||
+			pkceChallenge(r.PostFormValue("code_verifier")) != challenge || r.PostFormValue("redirect_uri") != redirect {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token":      testToken(fmt.Sprintf(`{"sub":"alice","email":"alice@example.com","nonce":%q,"exp":%d}`, nonce, time.Now().Add(time.Hour).Unix())),
+			"refresh_token": "refresh-0",
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// fakeBrowser stands in for the system browser, following the sign-in URL
+// and its redirects. It reports the URLs it was asked to open.
+func fakeBrowser(t *testing.T) <-chan string {
+	opened := make(chan string, 10)
+	saved := openBrowser
+	openBrowser = func(u string) error {
+		opened <- u
+		go func() {
+			if resp, err := http.Get(u); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+	t.Cleanup(func() { openBrowser = saved })
+	return opened
+}
+
+func browserLoginOpts(idp *fakeCodeIdP) loginOptions {
+	return loginOptions{issuer: idp.URL, clientID: "credproc", scope: defaultLoginScope}
+}
+
+func TestBrowserLogin(t *testing.T) {
+	idp := newFakeCodeIdP(t)
+	opened := fakeBrowser(t)
+	meta, err := discoverOIDC(context.Background(), idp.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tokens, err := browserLogin(ctx, meta, browserLoginOpts(idp), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "refresh-0" || checkJWTShape(tokens.IDToken) != nil {
+		t.Errorf("tokens = %+v, want an ID token and the refresh token", tokens)
+	}
+
+	authURL, _ := url.Parse(<-opened)
+	redirect, _ := url.Parse(authURL.Query().Get("redirect_uri"))
+	if redirect.Hostname() != "127.0.0.1" || redirect.Port() == "0" || redirect.Path != "/callback" {
+		t.Errorf("redirect_uri %s, want a loopback callback on an ephemeral port", redirect)
+	}
+}
+
+func TestBrowserLoginRedirectPort(t *testing.T) {
+	idp := newFakeCodeIdP(t)
+	opened := fakeBrowser(t)
+	meta, _ := discoverOIDC(context.Background(), idp.URL)
+
+	// Find a free port, then ask for it.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	opts := browserLoginOpts(idp)
+	opts.redirectPort = port
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := browserLogin(ctx, meta, opts, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	authURL, _ := url.Parse(<-opened)
+	if want := fmt.Sprintf("http://127.0.0.1:%d/callback", port); authURL.Query().Get("redirect_uri") != want {
+		t.Errorf("redirect_uri %s, want %s", authURL.Query().Get("redirect_uri"), want)
+	}
+
+	// A port in use is reported rather than silently replaced.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	opts.redirectPort = busy.Addr().(*net.TCPAddr).Port
+	if _, err := browserLogin(ctx, meta, opts, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "listening") {
+		t.Errorf("browserLogin on a busy port = %v", err)
+	}
+}
+
+func TestBrowserLoginState(t *testing.T) {
+	idp := newFakeCodeIdP(t)
+	meta, _ := discoverOIDC(context.Background(), idp.URL)
+
+	// A redirect with the wrong state, such as a forged one, is refused and
+	// the login keeps waiting for the real one.
+	forged := make(chan int, 1)
+	saved := openBrowser
+	openBrowser = func(u string) error {
+		authURL, _ := url.Parse(u)
+		callback := authURL.Query().Get("redirect_uri") + "?code=forged&state=wrong"
+		go func() {
+			resp, err := http.Get(callback)
+			if err != nil {
+				forged <- 0
+				return
+			}
+			resp.Body.Close()
+			forged <- resp.StatusCode
+			if resp, err := http.Get(u); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+	t.Cleanup(func() { openBrowser = saved })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := browserLogin(ctx, meta, browserLoginOpts(idp), &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if status := <-forged; status != http.StatusBadRequest {
+		t.Errorf("forged redirect got HTTP %d, want 400", status)
+	}
+}
+
+func TestBrowserLoginFailures(t *testing.T) {
+	t.Run("denied", func(t *testing.T) {
+		idp := newFakeCodeIdP(t)
+		idp.deny = true
+		fakeBrowser(t)
+		meta, _ := discoverOIDC(context.Background(), idp.URL)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := browserLogin(ctx, meta, browserLoginOpts(idp), &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "access_denied") {
+			t.Errorf("browserLogin = %v, want the IdP's refusal", err)
+		}
+	})
+
+	for name, tt := range map[string]struct {
+		nonce func(string) string
+		err   string
+	}{
+		"replayed nonce": {func(string) string { return "from-another-sign-in" }, "nonce does not match this sign-in"},
+		"missing nonce":  {func(string) string { return "" }, "no nonce claim"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			idp := newFakeCodeIdP(t)
+			idp.nonce = tt.nonce
+			fakeBrowser(t)
+			meta, _ := discoverOIDC(context.Background(), idp.URL)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if tr, err := browserLogin(ctx, meta, browserLoginOpts(idp), &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("browserLogin = %v, %v; want %q", tr, err, tt.err)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		idp := newFakeCodeIdP(t)
+		opened := fakeBrowser(t)
+		opts := browserLoginOpts(idp)
+		opts.noBrowser = true
+		meta, _ := discoverOIDC(context.Background(), idp.URL)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if _, err := browserLogin(ctx, meta, opts, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("browserLogin = %v, want a timeout", err)
+		}
+		if len(opened) != 0 {
+			t.Error("--no-browser opened a browser")
+		}
+	})
+}
+
+func TestBrowserLoginNoBrowser(t *testing.T) {
+	idp := newFakeCodeIdP(t)
+	opened := fakeBrowser(t)
+	meta, _ := discoverOIDC(context.Background(), idp.URL)
+	opts := browserLoginOpts(idp)
+	opts.noBrowser = true
+
+	// The user copies the printed URL into a browser by hand.
+	prompt := &syncBuffer{}
+	go func() {
+		re := regexp.MustCompile(`http://\S+/authorize\S*`)
+		for range 100 {
+			if u := re.FindString(prompt.String()); u != "" {
+				if resp, err := http.Get(u); err == nil {
+					resp.Body.Close()
+				}
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := browserLogin(ctx, meta, opts, prompt); err != nil {
+		t.Fatal(err)
+	}
+	if len(opened) != 0 {
+		t.Error("--no-browser opened a browser")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for one writer and one reader.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestBrowserLoginStoresTokens(t *testing.T) {
+	idp := newFakeCodeIdP(t)
+	fakeBrowser(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("BCCE_CACHE_BACKEND", "file")
+	t.Setenv("BCCE_AUTH_FLOW", "")
+	t.Setenv("OIDC_CLIENT_SECRET", "")
+	if err := runLogin([]string{"--issuer", idp.URL, "--client-id", "credproc", "--timeout", "10s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	store := fileCache{dir: filepath.Join(home, ".bcce", "cache")}
+	data, err := store.load(loginStoreName("default"))
+	if err != nil || data == nil {
+		t.Fatalf("no stored login: %v", err)
+	}
+	var login storedLogin
+	if err := json.Unmarshal(data, &login); err != nil {
+		t.Fatal(err)
+	}
+	if login.Issuer != idp.URL || login.TokenEndpoint != idp.URL+"/token" || login.RefreshToken != "refresh-0" || login.ClientID != "credproc" {
+		t.Errorf("stored login %+v", login)
+	}
+	if token, err := storedLoginToken(store, "default"); err != nil || token != login.IDToken {
+		t.Errorf("storedLoginToken = %v, want the stored ID token", err)
+	}
+}
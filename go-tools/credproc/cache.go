@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCacheBuffer is how much validity cached credentials must have left
+// to be reused; the SDKs refresh a few minutes before expiry themselves.
+const defaultCacheBuffer = 5 * time.Minute
+
+// defaultCacheDir is ~/.bcce/cache, or "" without a home directory.
+func defaultCacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bcce", "cache")
+}
+
+// cacheKey identifies whose credentials these are: the role, the identity
+// pool, the Logins providers, the role session name, the mapped session
+// tags and their role, and the token's subject. Tokens without a sub
+// claim are keyed by their hash, so two never share an entry.
+func cacheKey(cfg *Config) string {
+	providers := make([]string, 0, len(cfg.Logins))
+	for provider := range cfg.Logins {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	tokens := []string{cfg.OIDCToken}
+	for _, provider := range providers {
+		tokens = append(tokens, cfg.Logins[provider])
+	}
+	subject := ""
+	for _, token := range tokens {
+		if claims, err := tokenClaims(token); err == nil {
+			if subject, _ = claims["sub"].(string); subject != "" {
+				break
+			}
+		}
+	}
+	if subject == "" {
+		sum := sha256.Sum256([]byte(strings.Join(tokens, "\n")))
+		subject = "token:" + hex.EncodeToString(sum[:])
+	}
+
+	parts := []string{cfg.RoleArn, cfg.IdentityPoolID, strings.Join(providers, ","), cfg.SessionName, subject}
+	if len(cfg.SessionTags) > 0 {
+		tagKeys := make([]string, 0, len(cfg.SessionTags))
+		for key := range cfg.SessionTags {
+			tagKeys = append(tagKeys, key)
+		}
+		sort.Strings(tagKeys)
+		tags := make([]string, 0, len(tagKeys))
+		for _, key := range tagKeys {
+			tags = append(tags, key+"="+cfg.SessionTags[key])
+		}
+		parts = append(parts, cfg.TaggedRoleArn, strings.Join(tags, "\n"), strings.Join(cfg.TransitiveTagKeys, ","))
+	}
+
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// freshEnough reports whether creds are valid for more than buffer after now.
+func freshEnough(creds *CredentialsOutput, now time.Time, buffer time.Duration) bool {
+	expiration, err := time.Parse(time.RFC3339, creds.Expiration)
+	if err != nil {
+		return false
+	}
+	return expiration.Sub(now) > buffer
+}
+
+// fileCache stores credentials as one JSON file per key, readable only by
+// the user.
+type fileCache struct {
+	dir string
+}
+
+func (c fileCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get returns the cached credentials for key. A missing entry is nil
+// without an error; a corrupt or too widely readable one is an error, and
+// the next put overwrites it.
+func (c fileCache) get(key string) (*CredentialsOutput, error) {
+	data, err := c.load(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var creds CredentialsOutput
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", c.path(key), err)
+	}
+	if creds.AccessKeyId == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%s has no credentials", c.path(key))
+	}
+	return &creds, nil
+}
+
+// put stores creds under key.
+func (c fileCache) put(key string, creds *CredentialsOutput) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return c.save(key, data)
+}
+
+// load returns the entry stored under name, or nil if there is none.
+func (c fileCache) load(name string) ([]byte, error) {
+	path := c.path(name)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Windows has no permission bits to check; the profile directory's
+	// ACLs protect the file.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("%s is accessible by other users (mode %04o)", path, info.Mode().Perm())
+	}
+	return os.ReadFile(path)
+}
+
+// save stores data under name, replacing the entry atomically so a
+// concurrent reader never sees half a file.
+func (c fileCache) save(name string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600.
+	f, err := os.CreateTemp(c.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(name))
+}
+
+// exchanger performs the token exchange; tests substitute a fake.
+type exchanger func(ctx context.Context, cfg *Config) (*CredentialsOutput, error)
+
+// cachedCredentials returns cached credentials with more than buffer of
+// validity left, or exchanges and caches new ones. The exchange happens
+// under the key's lock, and a waiter that gets the lock rechecks the cache
+// the holder has just filled. The cache never fails the exchange: unusable
+// entries are ignored and overwritten, and a waiter that times out
+// exchanges the token itself.
+func cachedCredentials(ctx context.Context, cfg *Config, cache cacheBackend, locks cacheLocks, buffer time.Duration, exchange exchanger) (*CredentialsOutput, error) {
+	key := cacheKey(cfg)
+	if creds := freshCached(cache, key, buffer); creds != nil {
+		return creds, nil
+	}
+
+	if locks.dir != "" {
+		release, err := locks.acquire(ctx, key)
+		switch {
+		case errors.Is(err, errLockTimeout):
+			verbosef("Another credproc has held the cache lock for %s; exchanging the token without it", locks.timeout)
+		case err != nil:
+			verbosef("Exchanging the token without the cache lock: %v", err)
+		default:
+			defer release()
+			if creds := freshCached(cache, key, buffer); creds != nil {
+				return creds, nil
+			}
+		}
+	}
+
+	creds, err := exchange(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.put(key, creds); err != nil {
+		verbosef("Could not cache credentials: %v", err)
+	}
+	return creds, nil
+}
+
+// freshCached returns the credentials cached under key if they have more
+// than buffer of validity left.
+func freshCached(cache cacheBackend, key string, buffer time.Duration) *CredentialsOutput {
+	creds, err := cache.get(key)
+	switch {
+	case err != nil:
+		verbosef("Ignoring cached credentials: %v", err)
+	case creds != nil && freshEnough(creds, time.Now(), buffer):
+		verbosef("Using cached credentials, valid until %s", creds.Expiration)
+		return creds
+	case creds != nil:
+		verbosef("Cached credentials expire at %s, within %s; exchanging the token again", creds.Expiration, buffer)
+	}
+	return nil
+}
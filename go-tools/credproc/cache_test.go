@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFreshEnough(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expiration string
+		buffer     time.Duration
+		want       bool
+	}{
+		{now.Add(time.Hour).Format(time.RFC3339), defaultCacheBuffer, true},
+		{now.Add(5*time.Minute + time.Second).Format(time.RFC3339), defaultCacheBuffer, true},
+		{now.Add(5 * time.Minute).Format(time.RFC3339), defaultCacheBuffer, false},
+		{now.Add(time.Minute).Format(time.RFC3339), defaultCacheBuffer, false},
+		{now.Add(-time.Minute).Format(time.RFC3339), 0, false},
+		{now.Add(20 * time.Minute).Format(time.RFC3339), 30 * time.Minute, false},
+		{"2026-10-16T13:00:00+02:00", defaultCacheBuffer, false}, // 11:00 UTC
+		{"not a time", 0, false},
+	}
+	for _, tt := range tests {
+		if got := freshEnough(&CredentialsOutput{Expiration: tt.expiration}, now, tt.buffer); got != tt.want {
+			t.Errorf("freshEnough(%s, buffer %s) = %v, want %v", tt.expiration, tt.buffer, got, tt.want)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	alice := testToken(`{"iss":"https://idp.example.com","sub":"alice"}`)
+	base := &Config{RoleArn: "arn:aws:iam::123456789012:role/dev", IdentityPoolID: "us-east-1:pool", OIDCToken: alice, Logins: map[string]string{"idp.example.com": alice}}
+	key := cacheKey(base)
+
+	aliceRotated := testToken(`{"iss":"https://idp.example.com","sub":"alice","iat":2}`)
+	if got := cacheKey(&Config{RoleArn: base.RoleArn, IdentityPoolID: base.IdentityPoolID, OIDCToken: aliceRotated, Logins: map[string]string{"idp.example.com": aliceRotated}}); got != key {
+		t.Error("a new token for the same subject changed the cache key")
+	}
+
+	bob := testToken(`{"iss":"https://idp.example.com","sub":"bob"}`)
+	others := map[string]*Config{
+		"another role":     {RoleArn: "arn:aws:iam::123456789012:role/prod", IdentityPoolID: base.IdentityPoolID, OIDCToken: alice, Logins: base.Logins},
+		"no role":          {IdentityPoolID: base.IdentityPoolID, OIDCToken: alice, Logins: base.Logins},
+		"another pool":     {RoleArn: base.RoleArn, IdentityPoolID: "us-east-1:other", OIDCToken: alice, Logins: base.Logins},
+		"another provider": {RoleArn: base.RoleArn, IdentityPoolID: base.IdentityPoolID, OIDCToken: alice, Logins: map[string]string{"accounts.google.com": alice}},
+		"another subject":  {RoleArn: base.RoleArn, IdentityPoolID: base.IdentityPoolID, OIDCToken: bob, Logins: map[string]string{"idp.example.com": bob}},
+		"another session":  {RoleArn: base.RoleArn, IdentityPoolID: base.IdentityPoolID, OIDCToken: alice, Logins: base.Logins, SessionName: "shared-laptop"},
+		"session tags":     {RoleArn: base.RoleArn, IdentityPoolID: base.IdentityPoolID, OIDCToken: alice, Logins: base.Logins, SessionTags: map[string]string{"team": "ml"}, TaggedRoleArn: base.RoleArn},
+	}
+	for name, cfg := range others {
+		if cacheKey(cfg) == key {
+			t.Errorf("%s: same cache key", name)
+		}
+	}
+
+	tagged := func(team string) *Config {
+		return &Config{RoleArn: base.RoleArn, IdentityPoolID: base.IdentityPoolID, OIDCToken: alice, Logins: base.Logins, SessionTags: map[string]string{"team": team}, TaggedRoleArn: base.RoleArn}
+	}
+	if cacheKey(tagged("ml")) == cacheKey(tagged("ops")) {
+		t.Error("sessions with different tags share a cache key")
+	}
+
+	opaque := func(token string) *Config {
+		return &Config{IdentityPoolID: base.IdentityPoolID, Logins: map[string]string{"corp": token}}
+	}
+	if cacheKey(opaque("token-a")) == cacheKey(opaque("token-b")) {
+		t.Error("tokens without a subject share a cache key")
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	cache := fileCache{dir: filepath.Join(t.TempDir(), "cache")}
+	creds := &CredentialsOutput{Version: 1, AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "session", Expiration: "2026-10-16T13:00:00Z"}
+
+	if got, err := cache.get("k"); got != nil || err != nil {
+		t.Fatalf("get from an empty cache = %v, %v; want nothing", got, err)
+	}
+	if err := cache.put("k", creds); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.get("k")
+	if err != nil || *got != *creds {
+		t.Fatalf("get = %+v, %v; want %+v", got, err, creds)
+	}
+
+	if runtime.GOOS != "windows" {
+		for path, want := range map[string]os.FileMode{cache.dir: 0o700, cache.path("k"): 0o600} {
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+				t.Errorf("%s: mode %v, %v; want %04o", path, info.Mode().Perm(), err, want)
+			}
+		}
+		// A file others can read is not trusted, and put replaces it.
+		if err := os.Chmod(cache.path("k"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := cache.get("k"); got != nil || err == nil {
+			t.Errorf("get of a 0644 entry = %v, %v; want an error", got, err)
+		}
+		if err := cache.put("k", creds); err != nil {
+			t.Fatal(err)
+		}
+		if info, _ := os.Stat(cache.path("k")); info.Mode().Perm() != 0o600 {
+			t.Errorf("rewritten entry has mode %04o, want 0600", info.Mode().Perm())
+		}
+	}
+
+	if err := os.WriteFile(cache.path("k"), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cache.get("k"); got != nil || err == nil {
+		t.Errorf("get of a corrupt entry = %v, %v; want an error", got, err)
+	}
+}
+
+func TestCachedCredentials(t *testing.T) {
+	cache := fileCache{dir: t.TempDir()}
+	cfg := &Config{IdentityPoolID: "us-east-1:pool", Logins: map[string]string{"idp.example.com": testToken(`{"sub":"alice"}`)}}
+	exchanges := 0
+	expiration := time.Now().Add(time.Hour)
+	exchange := func(context.Context, *Config) (*CredentialsOutput, error) {
+		exchanges++
+		return &CredentialsOutput{Version: 1, AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", Expiration: expiration.UTC().Format(time.RFC3339)}, nil
+	}
+
+	for range 3 {
+		if _, err := cachedCredentials(context.Background(), cfg, cache, cacheLocks{}, defaultCacheBuffer, exchange); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exchanges != 1 {
+		t.Errorf("exchanges = %d, want 1 with the rest from the cache", exchanges)
+	}
+
+	// Within the buffer of expiry, the token is exchanged again.
+	if _, err := cachedCredentials(context.Background(), cfg, cache, cacheLocks{}, 2*time.Hour, exchange); err != nil {
+		t.Fatal(err)
+	}
+	if exchanges != 2 {
+		t.Errorf("exchanges = %d, want 2 once the cached credentials are within the buffer", exchanges)
+	}
+
+	// A corrupt entry is ignored and overwritten.
+	if err := os.WriteFile(cache.path(cacheKey(cfg)), []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cachedCredentials(context.Background(), cfg, cache, cacheLocks{}, defaultCacheBuffer, exchange); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cache.get(cacheKey(cfg)); err != nil || got == nil {
+		t.Errorf("corrupt entry not overwritten: %v, %v", got, err)
+	}
+}
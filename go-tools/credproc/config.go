@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the credproc config (--config, default ~/.bcce/credproc.yaml).
+// Each profile is selected with --profile (default "default"), so one file
+// can serve several AWS profiles' credential_process lines.
+type fileConfig struct {
+	Profiles map[string]profileConfig `yaml:"profiles"`
+}
+
+type profileConfig struct {
+	// Cognito Logins entries: provider name -> token source (a literal
+	// token, env:VAR_NAME, file:/path, or cmd:COMMAND)
+	Logins map[string]string `yaml:"logins"`
+	// Command printing the OIDC token, when neither flags nor the
+	// environment give one
+	TokenCommand string `yaml:"token_command"`
+	// Session tags for a chained sts:AssumeRole: tag key -> claim of the
+	// ID token, as in BCCE_SESSION_TAGS
+	SessionTags map[string]string `yaml:"session_tags"`
+	// Keys of SessionTags passed on to roles chained from the session
+	TransitiveTagKeys []string `yaml:"transitive_tag_keys"`
+}
+
+// defaultConfigPath is ~/.bcce/credproc.yaml, or "" without a home directory.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bcce", "credproc.yaml")
+}
+
+// loadProfileConfig reads profile from the config at path. A missing file
+// or profile is an error only when required (an explicit --config).
+func loadProfileConfig(path, profile string, required bool) (profileConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return profileConfig{}, nil
+	}
+	if err != nil {
+		return profileConfig{}, err
+	}
+
+	var cfg fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		return profileConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	p, ok := cfg.Profiles[profile]
+	if !ok && required {
+		return profileConfig{}, fmt.Errorf("%s has no profile %q", path, profile)
+	}
+	return p, nil
+}
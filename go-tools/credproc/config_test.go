@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfileConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("valid.yaml", "profiles:\n  default:\n    logins:\n      accounts.google.com: env:OIDC_ID_TOKEN\n")
+	typo := write("typo.yaml", "profiles:\n  default:\n    login:\n      accounts.google.com: env:OIDC_ID_TOKEN\n")
+
+	tests := []struct {
+		name     string
+		path     string
+		profile  string
+		required bool
+		logins   int
+		err      string
+	}{
+		{name: "profile", path: valid, profile: "default", logins: 1},
+		{name: "missing optional file", path: filepath.Join(dir, "missing.yaml"), profile: "default"},
+		{name: "missing required file", path: filepath.Join(dir, "missing.yaml"), profile: "default", required: true, err: "no such file"},
+		{name: "missing optional profile", path: valid, profile: "prod"},
+		{name: "missing required profile", path: valid, profile: "prod", required: true, err: `has no profile "prod"`},
+		{name: "unknown key", path: typo, profile: "default", err: "field login not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := loadProfileConfig(tt.path, tt.profile, tt.required)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || len(p.Logins) != tt.logins {
+				t.Errorf("loadProfileConfig = %+v, %v; want %d logins", p, err, tt.logins)
+			}
+		})
+	}
+}
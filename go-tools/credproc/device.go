@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// devicePollUnit scales the polling intervals the IdP gives in seconds;
+// tests shrink it.
+var devicePollUnit = time.Second
+
+// deviceAuthorization is the device authorization response (RFC 8628).
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// deviceLogin runs the device authorization grant: it prints the URL and
+// code on prompt, then polls the token endpoint until the user approves,
+// the code expires, or ctx ends (the login timeout or Ctrl-C).
+func deviceLogin(ctx context.Context, meta *oidcMetadata, opts loginOptions, prompt io.Writer) (*tokenResponse, error) {
+	if meta.DeviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("%s does not support the device flow (no device_authorization_endpoint)", opts.issuer)
+	}
+	form := url.Values{"client_id": {opts.clientID}, "scope": {opts.scope}}
+	if opts.clientSecret != "" {
+		form.Set("client_secret", opts.clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.DeviceAuthorizationEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("device authorization: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var tr tokenResponse
+		if json.Unmarshal(body, &tr) == nil && tr.Error != "" {
+			return nil, fmt.Errorf("device authorization: %s%s", tr.Error, describeOAuthError(&tr))
+		}
+		return nil, fmt.Errorf("device authorization: HTTP %d", resp.StatusCode)
+	}
+	var auth deviceAuthorization
+	if err := json.Unmarshal(body, &auth); err != nil || auth.DeviceCode == "" || auth.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization: malformed response")
+	}
+
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(prompt, "To sign in, open %s\nand check that it shows the code %s\n", auth.VerificationURIComplete, auth.UserCode)
+	} else {
+		fmt.Fprintf(prompt, "To sign in, open %s\nand enter the code %s\n", auth.VerificationURI, auth.UserCode)
+	}
+
+	interval := time.Duration(auth.Interval) * devicePollUnit
+	if auth.Interval <= 0 {
+		interval = 5 * devicePollUnit
+	}
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(auth.ExpiresIn)*devicePollUnit)
+		defer cancel()
+	}
+
+	poll := url.Values{"grant_type": {deviceCodeGrant}, "device_code": {auth.DeviceCode}, "client_id": {opts.clientID}}
+	if opts.clientSecret != "" {
+		poll.Set("client_secret", opts.clientSecret)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, loginCanceled(ctx, "approved")
+		case <-time.After(interval):
+		}
+		tr, status, err := postTokenRequest(ctx, meta.TokenEndpoint, poll)
+		if ctx.Err() != nil {
+			return nil, loginCanceled(ctx, "approved")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("device flow: %w", err)
+		}
+		switch tr.Error {
+		case "":
+		case "authorization_pending":
+			verbosef("Waiting for approval")
+			continue
+		case "slow_down":
+			interval += 5 * devicePollUnit
+			verbosef("The IdP asked to poll less often; now every %s", interval)
+			continue
+		case "expired_token":
+			return nil, fmt.Errorf("the code expired before the sign-in was approved; run credproc login --device again")
+		case "access_denied":
+			return nil, fmt.Errorf("the sign-in was denied")
+		default:
+			return nil, fmt.Errorf("device flow: %s%s", tr.Error, describeOAuthError(tr))
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("device flow: token endpoint returned HTTP %d", status)
+		}
+		if tr.IDToken == "" {
+			return nil, fmt.Errorf("device flow: the response has no id_token (is the openid scope granted?)")
+		}
+		if err := checkJWTShape(tr.IDToken); err != nil {
+			return nil, fmt.Errorf("device flow: id_token: %w", err)
+		}
+		return tr, nil
+	}
+}
+
+// loginCanceled explains why an interactive login stopped waiting.
+func loginCanceled(ctx context.Context, what string) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out before the sign-in was %s", what)
+	}
+	return fmt.Errorf("login canceled")
+}
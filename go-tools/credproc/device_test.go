@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIdP simulates an OIDC provider's discovery document, device
+// authorization endpoint, and token endpoint. Each device-code poll gets
+// the next of replies, an OAuth error code or "" for tokens.
+type fakeIdP struct {
+	*httptest.Server
+	replies   []string
+	polls     int
+	expiresIn int
+}
+
+func newFakeIdP(t *testing.T, replies ...string) *fakeIdP {
+	idp := &fakeIdP{replies: replies, expiresIn: 600}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                      idp.URL,
+			TokenEndpoint:               idp.URL + "/token",
+			DeviceAuthorizationEndpoint: idp.URL + "/device",
+		})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("client_id") != "credproc" || !strings.Contains(r.PostFormValue("scope"), "openid") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(deviceAuthorization{
+			DeviceCode:      "device-code",
+			UserCode:        "WDJB-MJHT",
+			VerificationURI: idp.URL + "/activate",
+			ExpiresIn:       idp.expiresIn,
+			Interval:        1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("grant_type") != deviceCodeGrant || r.PostFormValue("device_code") != "device-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		reply := "authorization_pending"
+		if idp.polls < len(idp.replies) {
+			reply = idp.replies[idp.polls]
+		}
+		idp.polls++
+		if reply != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":%q}`, reply)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token":      testToken(fmt.Sprintf(`{"iss":%q,"sub":"alice","email":"alice@example.com","exp":%d}`, idp.URL, time.Now().Add(time.Hour).Unix())),
+			"refresh_token": "refresh-0",
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// fastDevicePolling makes the IdP's one-second intervals milliseconds.
+func fastDevicePolling(t *testing.T) {
+	saved := devicePollUnit
+	devicePollUnit = 10 * time.Millisecond
+	t.Cleanup(func() { devicePollUnit = saved })
+}
+
+func TestDeviceLogin(t *testing.T) {
+	fastDevicePolling(t)
+	idp := newFakeIdP(t, "authorization_pending", "slow_down", "authorization_pending", "")
+	meta, err := discoverOIDC(context.Background(), idp.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prompt bytes.Buffer
+	opts := loginOptions{issuer: idp.URL, clientID: "credproc", scope: defaultLoginScope}
+	tokens, err := deviceLogin(context.Background(), meta, opts, &prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "refresh-0" || checkJWTShape(tokens.IDToken) != nil {
+		t.Errorf("tokens = %+v, want an ID token and the refresh token", tokens)
+	}
+	if idp.polls != 4 {
+		t.Errorf("polled %d times, want 4", idp.polls)
+	}
+	if !strings.Contains(prompt.String(), "WDJB-MJHT") || !strings.Contains(prompt.String(), "/activate") {
+		t.Errorf("prompt %q lacks the URL or the code", prompt.String())
+	}
+}
+
+func TestDeviceLoginFailures(t *testing.T) {
+	fastDevicePolling(t)
+	opts := loginOptions{issuer: "https://idp.example.com", clientID: "credproc", scope: defaultLoginScope}
+
+	t.Run("expired device code", func(t *testing.T) {
+		idp := newFakeIdP(t, "authorization_pending", "expired_token")
+		meta, _ := discoverOIDC(context.Background(), idp.URL)
+		_, err := deviceLogin(context.Background(), meta, opts, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "expired") || !strings.Contains(err.Error(), "login --device") {
+			t.Errorf("deviceLogin = %v, want an expired-code error", err)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		idp := newFakeIdP(t, "access_denied")
+		meta, _ := discoverOIDC(context.Background(), idp.URL)
+		if _, err := deviceLogin(context.Background(), meta, opts, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "denied") {
+			t.Errorf("deviceLogin = %v, want a denial", err)
+		}
+	})
+
+	t.Run("code lifetime", func(t *testing.T) {
+		idp := newFakeIdP(t)
+		idp.expiresIn = 5
+		meta, _ := discoverOIDC(context.Background(), idp.URL)
+		start := time.Now()
+		_, err := deviceLogin(context.Background(), meta, opts, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("deviceLogin = %v, want a timeout when the code lapses", err)
+		}
+		if waited := time.Since(start); waited > 2*time.Second {
+			t.Errorf("a 50ms code lifetime took %s", waited)
+		}
+	})
+
+	t.Run("Ctrl-C", func(t *testing.T) {
+		idp := newFakeIdP(t)
+		meta, _ := discoverOIDC(context.Background(), idp.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		if _, err := deviceLogin(ctx, meta, opts, &bytes.Buffer{}); err == nil || err.Error() != "login canceled" {
+			t.Errorf("deviceLogin = %v, want it canceled", err)
+		}
+	})
+
+	t.Run("no device endpoint", func(t *testing.T) {
+		if _, err := deviceLogin(context.Background(), &oidcMetadata{TokenEndpoint: "https://idp.example.com/token"}, opts, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "device_authorization_endpoint") {
+			t.Errorf("deviceLogin = %v", err)
+		}
+	})
+}
+
+func TestLoginThenRun(t *testing.T) {
+	fastDevicePolling(t)
+	idp := newFakeIdP(t, "")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("BCCE_CACHE_BACKEND", "file")
+	t.Setenv("BCCE_AUTH_FLOW", "device")
+	t.Setenv("OIDC_ISSUER", idp.URL)
+	t.Setenv("OIDC_CLIENT_ID", "credproc")
+	if err := runLogin([]string{"--profile", "dev"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A normal run of the profile uses the stored login transparently.
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	t.Setenv("BCCE_LOGINS_JSON", "")
+	t.Setenv("BCCE_ROLE_ARN", "")
+	t.Setenv("OIDC_PROVIDER_NAME", "")
+	t.Setenv("OIDC_ID_TOKEN", "")
+	t.Setenv("OIDC_ID_TOKEN_FILE", "")
+	t.Setenv("OIDC_TOKEN_COMMAND", "")
+	t.Setenv("OIDC_TOKEN_ENDPOINT", "")
+	store := fileCache{dir: filepath.Join(home, ".bcce", "cache")}
+	cfg, err := loadConfig(options{profile: "dev"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := tokenClaims(cfg.OIDCToken); claims["email"] != "alice@example.com" {
+		t.Errorf("OIDC token claims %v, want the login's", claims)
+	}
+	if _, err := loadConfig(options{profile: "prod"}, store); err == nil || !strings.Contains(err.Error(), "credproc login") {
+		t.Errorf("loadConfig of a profile without a login = %v", err)
+	}
+}
+
+func TestNoCacheKeepsStoredLogin(t *testing.T) {
+	fastDevicePolling(t)
+	idp := newFakeIdP(t, "")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("BCCE_CACHE_BACKEND", "file")
+	t.Setenv("BCCE_AUTH_FLOW", "device")
+	t.Setenv("OIDC_ISSUER", idp.URL)
+	t.Setenv("OIDC_CLIENT_ID", "credproc")
+	if err := runLogin([]string{"--profile", "dev"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	for _, name := range []string{"BCCE_LOGINS_JSON", "BCCE_ROLE_ARN", "OIDC_PROVIDER_NAME", "OIDC_ID_TOKEN", "OIDC_ID_TOKEN_FILE", "OIDC_TOKEN_COMMAND", "OIDC_TOKEN_ENDPOINT"} {
+		t.Setenv(name, "")
+	}
+	opts := options{profile: "dev", noCache: true}
+	store, cache, err := openStores(opts, filepath.Join(home, ".bcce", "cache"))
+	if err != nil || store == nil || cache != nil {
+		t.Fatalf("openStores with --no-cache = %v, %v, %v; want the login store and no credential cache", store, cache, err)
+	}
+	cfg, err := loadConfig(opts, store)
+	if err != nil {
+		t.Fatalf("loadConfig with --no-cache and a stored login: %v", err)
+	}
+	if claims, _ := tokenClaims(cfg.OIDCToken); claims["email"] != "alice@example.com" {
+		t.Errorf("OIDC token claims %v, want the login's", claims)
+	}
+}
+
+func TestStoredLoginRefresh(t *testing.T) {
+	endpoint := &fakeTokenEndpoint{valid: "refresh-0", rotate: true}
+	srv := httptest.NewServer(endpoint)
+	defer srv.Close()
+	t.Setenv("OIDC_CLIENT_SECRET", "")
+	store := fileCache{dir: t.TempDir()}
+
+	expired := testToken(fmt.Sprintf(`{"sub":"alice","exp":%d}`, time.Now().Add(-time.Hour).Unix()))
+	if err := saveLogin(store, "default", &storedLogin{ClientID: "credproc", TokenEndpoint: srv.URL, IDToken: expired, RefreshToken: "refresh-0"}); err != nil {
+		t.Fatal(err)
+	}
+	token, err := storedLoginToken(store, "default")
+	if err != nil || grantNumber(t, token) != 1 {
+		t.Fatalf("storedLoginToken = %q, %v; want a refreshed token", token, err)
+	}
+	// The refreshed ID token is stored, so the next run reuses it.
+	if again, err := storedLoginToken(store, "default"); err != nil || again != token || endpoint.grants != 1 {
+		t.Errorf("second storedLoginToken = %v after %d grants; want the stored token", err, endpoint.grants)
+	}
+
+	if err := saveLogin(store, "stale", &storedLogin{ClientID: "credproc", TokenEndpoint: srv.URL, IDToken: expired}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storedLoginToken(store, "stale"); err == nil || !strings.Contains(err.Error(), "run credproc login again") {
+		t.Errorf("storedLoginToken of an expired login without a refresh token = %v", err)
+	}
+
+	// A rejected refresh token is fixed by logging in again, not by
+	// OIDC_REFRESH_TOKEN, which the login never read.
+	if err := saveLogin(store, "work", &storedLogin{ClientID: "credproc", TokenEndpoint: srv.URL, IDToken: expired, RefreshToken: "revoked"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = storedLoginToken(store, "work")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") || !strings.Contains(err.Error(), "run credproc login --profile work again") || strings.Contains(err.Error(), "OIDC_REFRESH_TOKEN") {
+		t.Errorf("storedLoginToken with a revoked refresh token = %v, want credproc login advised", err)
+	}
+}
@@ -7,4 +7,5 @@ require (
 	github.com/aws/aws-sdk-go-v2/config v1.27.24
 	github.com/aws/aws-sdk-go-v2/service/cognitoidentity v1.25.5
 	github.com/aws/aws-sdk-go-v2/service/sts v1.30.3
+	gopkg.in/yaml.v3 v3.0.1
 )
\ No newline at end of file
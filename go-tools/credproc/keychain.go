@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// keychainService names credproc's entries in the OS secret store.
+const keychainService = "bcce-credproc"
+
+// cacheBackend stores credentials under a cache key, and other secrets
+// such as refresh tokens as blobs under a name. get and load return nil
+// without an error for a missing entry.
+type cacheBackend interface {
+	get(key string) (*CredentialsOutput, error)
+	put(key string, creds *CredentialsOutput) error
+	load(name string) ([]byte, error)
+	save(name string, data []byte) error
+}
+
+// secretStore is an OS facility holding secrets by name: the macOS
+// Keychain, Windows Credential Manager, or the Secret Service on Linux.
+type secretStore interface {
+	// available reports why the store cannot be used here, or nil.
+	available() error
+	// load returns nil without an error when name has no secret.
+	load(name string) ([]byte, error)
+	save(name string, secret []byte) error
+	remove(name string) error
+}
+
+// keychainCache keeps the same JSON blob as fileCache in a secretStore, so
+// no credentials are written to disk by credproc itself.
+type keychainCache struct {
+	store secretStore
+}
+
+func (c keychainCache) get(key string) (*CredentialsOutput, error) {
+	data, err := c.store.load(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var creds CredentialsOutput
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("keychain entry %s is corrupt: %w", key, err)
+	}
+	if creds.AccessKeyId == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("keychain entry %s has no credentials", key)
+	}
+	return &creds, nil
+}
+
+func (c keychainCache) put(key string, creds *CredentialsOutput) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return c.store.save(key, data)
+}
+
+func (c keychainCache) load(name string) ([]byte, error) {
+	return c.store.load(name)
+}
+
+func (c keychainCache) save(name string, data []byte) error {
+	return c.store.save(name, data)
+}
+
+// openCache returns the backend BCCE_CACHE_BACKEND names: "file" (the
+// default) in dir, "keychain" in store, or nil for "none". An unavailable
+// keychain falls back to the file backend with a notice, since a missing
+// D-Bus session on a headless box should not fail the exchange.
+func openCache(backend string, store secretStore, dir string) (cacheBackend, error) {
+	var file cacheBackend
+	if dir != "" {
+		file = fileCache{dir: dir}
+	}
+	switch backend {
+	case "", "file":
+		return file, nil
+	case "none":
+		return nil, nil
+	case "keychain":
+		if err := store.available(); err != nil {
+			if file == nil {
+				log.Printf("Notice: keychain cache unavailable (%v); not caching credentials", err)
+			} else {
+				log.Printf("Notice: keychain cache unavailable (%v); using %s instead", err, dir)
+			}
+			return file, nil
+		}
+		verbosef("Caching credentials in the OS keychain")
+		return keychainCache{store: store}, nil
+	}
+	return nil, fmt.Errorf("BCCE_CACHE_BACKEND must be keychain, file, or none, not %q", backend)
+}
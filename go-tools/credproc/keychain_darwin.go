@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// osSecretStore is the user's login Keychain, reached through security(1)
+// so credproc needs no cgo.
+var osSecretStore secretStore = macKeychain{}
+
+type macKeychain struct{}
+
+// errSecItemNotFound is security(1)'s exit status for a missing item.
+const errSecItemNotFound = 44
+
+func (macKeychain) available() error {
+	if _, err := exec.LookPath("security"); err != nil {
+		return fmt.Errorf("security is not installed")
+	}
+	if _, err := security(nil, "default-keychain"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func security(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("security", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil && args[0] == "-i" && stderr.Len() > 0 {
+		// Interactive mode exits 0 even when a command fails.
+		err = errors.New("command failed")
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("security %s: %w: %s", args[0], err, msg)
+		}
+		return out, fmt.Errorf("security %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+func (macKeychain) load(name string) ([]byte, error) {
+	out, err := security(nil, "find-generic-password", "-s", keychainService, "-a", name, "-w")
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == errSecItemNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out, []byte("\n")), nil
+}
+
+// save runs add-generic-password in interactive mode so the secret goes
+// over stdin rather than the command line, where ps would show it.
+func (macKeychain) save(name string, secret []byte) error {
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -X %s\n", keychainService, name, "BCCE", hex.EncodeToString(secret))
+	_, err := security([]byte(command), "-i")
+	return err
+}
+
+func (macKeychain) remove(name string) error {
+	_, err := security(nil, "delete-generic-password", "-s", keychainService, "-a", name)
+	return err
+}
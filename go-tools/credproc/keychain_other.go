@@ -0,0 +1,70 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// osSecretStore is the freedesktop Secret Service (GNOME Keyring, KWallet),
+// reached through secret-tool so credproc needs no D-Bus library.
+var osSecretStore secretStore = secretService{}
+
+type secretService struct{}
+
+func (secretService) available() error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return fmt.Errorf("secret-tool is not installed")
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return fmt.Errorf("no D-Bus session (DBUS_SESSION_BUS_ADDRESS is not set)")
+	}
+	return nil
+}
+
+// errNoMatch is secret-tool's silent exit 1 when lookup finds nothing.
+var errNoMatch = errors.New("no matching secret")
+
+func secretTool(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		var exit *exec.ExitError
+		if msg == "" && len(out) == 0 && errors.As(err, &exit) && exit.ExitCode() == 1 {
+			return nil, errNoMatch
+		}
+		if msg != "" {
+			return out, fmt.Errorf("secret-tool %s: %s", args[0], msg)
+		}
+		return out, fmt.Errorf("secret-tool %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+func (secretService) load(name string) ([]byte, error) {
+	out, err := secretTool(nil, "lookup", "service", keychainService, "key", name)
+	if errors.Is(err, errNoMatch) {
+		return nil, nil
+	}
+	return out, err
+}
+
+// save passes the secret on stdin, never on the command line.
+func (secretService) save(name string, secret []byte) error {
+	_, err := secretTool(secret, "store", "--label=BCCE credentials", "service", keychainService, "key", name)
+	return err
+}
+
+func (secretService) remove(name string) error {
+	_, err := secretTool(nil, "clear", "service", keychainService, "key", name)
+	return err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeSecretStore is an in-memory secretStore.
+type fakeSecretStore struct {
+	unavailable error
+	secrets     map[string][]byte
+}
+
+func (s *fakeSecretStore) available() error { return s.unavailable }
+
+func (s *fakeSecretStore) load(name string) ([]byte, error) { return s.secrets[name], nil }
+
+func (s *fakeSecretStore) save(name string, secret []byte) error {
+	if s.secrets == nil {
+		s.secrets = map[string][]byte{}
+	}
+	s.secrets[name] = secret
+	return nil
+}
+
+func (s *fakeSecretStore) remove(name string) error {
+	delete(s.secrets, name)
+	return nil
+}
+
+func TestKeychainCache(t *testing.T) {
+	store := &fakeSecretStore{}
+	cache := keychainCache{store: store}
+	creds := &CredentialsOutput{Version: 1, AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "session", Expiration: "2026-10-16T13:00:00Z"}
+
+	if got, err := cache.get("k"); got != nil || err != nil {
+		t.Fatalf("get from an empty keychain = %v, %v; want nothing", got, err)
+	}
+	if err := cache.put("k", creds); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.get("k")
+	if err != nil || *got != *creds {
+		t.Fatalf("get = %+v, %v; want %+v", got, err, creds)
+	}
+
+	for name, data := range map[string]string{"corrupt": "{not json", "empty": `{"Version":1}`} {
+		store.secrets["k"] = []byte(data)
+		if got, err := cache.get("k"); got != nil || err == nil {
+			t.Errorf("get of a %s entry = %v, %v; want an error", name, got, err)
+		}
+	}
+}
+
+func TestOpenCache(t *testing.T) {
+	dir := t.TempDir()
+	available := &fakeSecretStore{}
+	unavailable := &fakeSecretStore{unavailable: errors.New("no D-Bus session")}
+
+	tests := []struct {
+		backend string
+		store   secretStore
+		dir     string
+		want    cacheBackend
+	}{
+		{"", available, dir, fileCache{dir: dir}},
+		{"file", available, dir, fileCache{dir: dir}},
+		{"none", available, dir, nil},
+		{"keychain", available, dir, keychainCache{store: available}},
+		{"keychain", unavailable, dir, fileCache{dir: dir}},
+		{"keychain", unavailable, "", nil},
+		{"file", available, "", nil},
+	}
+	for _, tt := range tests {
+		got, err := openCache(tt.backend, tt.store, tt.dir)
+		if err != nil || got != tt.want {
+			t.Errorf("openCache(%q, available=%v, %q) = %#v, %v; want %#v", tt.backend, tt.store.available() == nil, tt.dir, got, err, tt.want)
+		}
+	}
+
+	if _, err := openCache("vault", available, dir); err == nil {
+		t.Error("openCache accepted an unknown backend")
+	}
+}
+
+func TestCachedCredentialsKeychain(t *testing.T) {
+	store := &fakeSecretStore{}
+	cfg := &Config{IdentityPoolID: "us-east-1:pool", Logins: map[string]string{"idp.example.com": testToken(`{"sub":"alice"}`)}}
+	exchanges := 0
+	exchange := func(context.Context, *Config) (*CredentialsOutput, error) {
+		exchanges++
+		return &CredentialsOutput{Version: 1, AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", Expiration: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}, nil
+	}
+
+	for range 2 {
+		if _, err := cachedCredentials(context.Background(), cfg, keychainCache{store: store}, cacheLocks{}, defaultCacheBuffer, exchange); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exchanges != 1 {
+		t.Errorf("exchanges = %d, want 1 with the rest from the keychain", exchanges)
+	}
+	if store.secrets[cacheKey(cfg)] == nil {
+		t.Error("credentials not stored under the cache key")
+	}
+}
+
+// TestOSSecretStore round-trips through the platform's real keychain,
+// where there is one.
+func TestOSSecretStore(t *testing.T) {
+	if err := osSecretStore.available(); err != nil {
+		t.Skipf("no OS secret store: %v", err)
+	}
+	name := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	t.Cleanup(func() { osSecretStore.remove(name) })
+
+	if got, err := osSecretStore.load(name); err != nil || got != nil {
+		t.Fatalf("load of a missing entry = %q, %v; want nothing", got, err)
+	}
+	secret := []byte(`{"Version":1,"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"s3cr\"et"}`)
+	if err := osSecretStore.save(name, secret); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := osSecretStore.load(name); err != nil || string(got) != string(secret) {
+		t.Fatalf("load = %q, %v; want %q", got, err, secret)
+	}
+	if err := osSecretStore.remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := osSecretStore.load(name); err != nil || got != nil {
+		t.Errorf("load after remove = %q, %v; want nothing", got, err)
+	}
+}
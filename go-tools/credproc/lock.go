@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultLockTimeout is how long an invocation waits for another one
+// exchanging the same token before exchanging it itself.
+const defaultLockTimeout = 10 * time.Second
+
+// lockPollInterval is how often a waiter retries the lock.
+const lockPollInterval = 50 * time.Millisecond
+
+var errLockTimeout = errors.New("timed out waiting for the lock")
+
+// cacheLocks serializes exchanges for one cache key across processes, so a
+// burst of parallel SDK calls makes one Cognito exchange rather than one
+// each. The locks are advisory (flock, LockFileEx) and the OS drops them
+// when their holder exits, so a crashed process cannot leave a stale one.
+// A zero dir disables locking.
+type cacheLocks struct {
+	dir     string
+	timeout time.Duration
+}
+
+// acquire takes the lock for key, waiting up to the timeout, and returns
+// the function that releases it. The lock file itself is never removed:
+// deleting it would let two processes lock different files of one name.
+func (l cacheLocks) acquire(ctx context.Context, key string) (release func(), err error) {
+	if err := os.MkdirAll(l.dir, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, key+".lock"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(l.timeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("locking %s: %w", f.Name(), err)
+		}
+		if locked {
+			// Closing the file releases the lock.
+			return func() { f.Close() }, nil
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, errLockTimeout
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedCredentialsConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{IdentityPoolID: "us-east-1:pool", Logins: map[string]string{"idp.example.com": testToken(`{"sub":"alice"}`)}}
+	var exchanges atomic.Int32
+	exchange := func(context.Context, *Config) (*CredentialsOutput, error) {
+		exchanges.Add(1)
+		time.Sleep(100 * time.Millisecond) // long enough for every caller to queue
+		return &CredentialsOutput{Version: 1, AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", Expiration: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}, nil
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each caller opens its own lock file, as separate processes do.
+			_, err := cachedCredentials(context.Background(), cfg, fileCache{dir: dir}, cacheLocks{dir: dir, timeout: defaultLockTimeout}, defaultCacheBuffer, exchange)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := exchanges.Load(); got != 1 {
+		t.Errorf("exchanges = %d, want 1 with every other caller reading the cache", got)
+	}
+}
+
+func TestCacheLocksTimeout(t *testing.T) {
+	cfg := &Config{IdentityPoolID: "us-east-1:pool", Logins: map[string]string{"idp.example.com": "opaque"}}
+	key := cacheKey(cfg)
+	locks := cacheLocks{dir: t.TempDir(), timeout: 100 * time.Millisecond}
+	release, err := locks.acquire(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := locks.acquire(context.Background(), key); err != errLockTimeout {
+		t.Fatalf("acquire of a held lock = %v, want errLockTimeout", err)
+	}
+	if waited := time.Since(start); waited < locks.timeout || waited > 10*locks.timeout {
+		t.Errorf("waited %s for a %s timeout", waited, locks.timeout)
+	}
+
+	// A waiter that times out exchanges the token itself.
+	exchanged := false
+	exchange := func(context.Context, *Config) (*CredentialsOutput, error) {
+		exchanged = true
+		return &CredentialsOutput{Version: 1, AccessKeyId: "ASIAEXAMPLE", SecretAccessKey: "secret", Expiration: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}, nil
+	}
+	if _, err := cachedCredentials(context.Background(), cfg, fileCache{dir: t.TempDir()}, locks, defaultCacheBuffer, exchange); err != nil || !exchanged {
+		t.Errorf("cachedCredentials with the lock held = %v, exchanged %v; want an exchange", err, exchanged)
+	}
+
+	release()
+	if release, err := locks.acquire(context.Background(), key); err != nil {
+		t.Errorf("acquire after release = %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestCacheLocksStaleFile(t *testing.T) {
+	// A lock file left by a crashed process holds no lock.
+	locks := cacheLocks{dir: t.TempDir(), timeout: time.Second}
+	if err := os.WriteFile(filepath.Join(locks.dir, "k.lock"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	release, err := locks.acquire(context.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if waited := time.Since(start); waited >= lockPollInterval {
+		t.Errorf("waited %s on a stale lock file", waited)
+	}
+}
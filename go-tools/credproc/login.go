@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// defaultLoginTimeout bounds an interactive login, however long the IdP
+// lets its codes live.
+const defaultLoginTimeout = 5 * time.Minute
+
+// defaultLoginScope asks for an ID token and a refresh token.
+const defaultLoginScope = "openid offline_access"
+
+// oidcMetadata is the part of an issuer's discovery document credproc uses.
+type oidcMetadata struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+// discoverOIDC fetches issuer's /.well-known/openid-configuration.
+func discoverOIDC(ctx context.Context, issuer string) (*oidcMetadata, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery: %s returned HTTP %d", url, resp.StatusCode)
+	}
+	var meta oidcMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("discovery: %s: %w", url, err)
+	}
+	if meta.TokenEndpoint == "" {
+		return nil, fmt.Errorf("discovery: %s has no token_endpoint", url)
+	}
+	return &meta, nil
+}
+
+// storedLogin is what credproc login keeps in the cache backend for a
+// profile, so later runs need no token configuration.
+type storedLogin struct {
+	Issuer        string `json:"issuer"`
+	ClientID      string `json:"client_id"`
+	TokenEndpoint string `json:"token_endpoint"`
+	IDToken       string `json:"id_token"`
+	RefreshToken  string `json:"refresh_token,omitempty"`
+}
+
+func loginStoreName(profile string) string {
+	return "login-" + profile
+}
+
+func saveLogin(store cacheBackend, profile string, login *storedLogin) error {
+	data, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+	return store.save(loginStoreName(profile), data)
+}
+
+// storedLoginToken returns a current ID token from the profile's stored
+// login, refreshing it when it has expired, or "" when there is no login.
+func storedLoginToken(store cacheBackend, profile string) (string, error) {
+	data, err := store.load(loginStoreName(profile))
+	if err != nil || data == nil {
+		return "", err
+	}
+	var login storedLogin
+	if err := json.Unmarshal(data, &login); err != nil {
+		return "", fmt.Errorf("stored login for profile %q is corrupt; run credproc login again: %w", profile, err)
+	}
+	if !tokenExpired(login.IDToken, time.Now()) {
+		return login.IDToken, nil
+	}
+	if login.RefreshToken == "" {
+		return "", fmt.Errorf("the login for profile %q has expired and has no refresh token; run credproc login again", profile)
+	}
+	verbosef("The ID token from credproc login has expired")
+	token, err := refreshIDToken(refreshConfig{
+		endpoint:     login.TokenEndpoint,
+		clientID:     login.ClientID,
+		clientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		refreshToken: login.RefreshToken,
+		source:       loginCommand(profile),
+		login:        true,
+	}, store)
+	if err != nil {
+		return "", fmt.Errorf("%w (credproc login)", err)
+	}
+	// Keep the new ID token so the next run need not refresh.
+	login.IDToken = token
+	if err := saveLogin(store, profile, &login); err != nil {
+		verbosef("Could not store the refreshed ID token: %v", err)
+	}
+	return token, nil
+}
+
+// loginCommand is the credproc login command that signs profile in.
+func loginCommand(profile string) string {
+	if profile == "default" {
+		return "credproc login"
+	}
+	return "credproc login --profile " + profile
+}
+
+// loginOptions are the flags of credproc login.
+type loginOptions struct {
+	device       bool
+	issuer       string
+	clientID     string
+	clientSecret string
+	scope        string
+	profile      string
+	timeout      time.Duration
+	noBrowser    bool
+	redirectPort int
+}
+
+// runLogin is credproc login: it signs in interactively in the browser, or
+// with a device code, prompting on stderr, and stores the tokens for the
+// profile's later runs.
+func runLogin(args []string) error {
+	var opts loginOptions
+	fs := flag.NewFlagSet("credproc login", flag.ContinueOnError)
+	fs.BoolVar(&opts.device, "device", os.Getenv("BCCE_AUTH_FLOW") == "device", "Use the device authorization grant (default when BCCE_AUTH_FLOW=device)")
+	fs.StringVar(&opts.issuer, "issuer", os.Getenv("OIDC_ISSUER"), "OIDC issuer URL (default OIDC_ISSUER)")
+	fs.StringVar(&opts.clientID, "client-id", os.Getenv("OIDC_CLIENT_ID"), "OAuth client ID (default OIDC_CLIENT_ID)")
+	fs.StringVar(&opts.scope, "scope", defaultLoginScope, "OAuth scopes to request")
+	fs.StringVar(&opts.profile, "profile", "default", "Profile whose runs use this login")
+	fs.DurationVar(&opts.timeout, "timeout", defaultLoginTimeout, "How long to wait for the sign-in")
+	fs.BoolVar(&opts.noBrowser, "no-browser", false, "Print the sign-in URL instead of opening a browser")
+	fs.IntVar(&opts.redirectPort, "redirect-port", 0, "Loopback port for the sign-in redirect (default any free port)")
+	fs.BoolVar(&verbose, "verbose", false, "Report progress on stderr (never tokens)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts.clientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	if opts.issuer == "" || opts.clientID == "" {
+		return fmt.Errorf("--issuer (OIDC_ISSUER) and --client-id (OIDC_CLIENT_ID) are required")
+	}
+
+	store, err := openCache(os.Getenv("BCCE_CACHE_BACKEND"), osSecretStore, defaultCacheDir())
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return fmt.Errorf("credproc login stores tokens in the credential cache, which BCCE_CACHE_BACKEND=none disables")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	meta, err := discoverOIDC(ctx, opts.issuer)
+	if err != nil {
+		return err
+	}
+	var tokens *tokenResponse
+	if opts.device {
+		tokens, err = deviceLogin(ctx, meta, opts, os.Stderr)
+	} else {
+		tokens, err = browserLogin(ctx, meta, opts, os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	login := &storedLogin{
+		Issuer:        opts.issuer,
+		ClientID:      opts.clientID,
+		TokenEndpoint: meta.TokenEndpoint,
+		IDToken:       tokens.IDToken,
+		RefreshToken:  tokens.RefreshToken,
+	}
+	if err := saveLogin(store, opts.profile, login); err != nil {
+		return fmt.Errorf("storing the login: %w", err)
+	}
+	who := "you"
+	if claims, err := tokenClaims(tokens.IDToken); err == nil {
+		for _, claim := range []string{"email", "preferred_username", "sub"} {
+			if v, _ := claims[claim].(string); v != "" {
+				who = v
+				break
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Signed in as %s; profile %q now uses this login.\n", who, opts.profile)
+	if tokens.RefreshToken == "" {
+		fmt.Fprintf(os.Stderr, "The IdP issued no refresh token, so you will need to sign in again when the ID token expires (is %q allowed?).\n", "offline_access")
+	}
+	return nil
+}
+
+// loginMain runs credproc login and exits.
+func loginMain(args []string) {
+	if err := runLogin(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		log.Printf("Login failed: %v", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
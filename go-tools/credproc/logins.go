@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// resolveTokenSource returns the token a Logins source names: env:VAR_NAME
+// reads a variable, file:/path a file, cmd:COMMAND runs a command, and
+// anything else is the token.
+func resolveTokenSource(source string) (string, error) {
+	var token string
+	switch {
+	case strings.HasPrefix(source, "env:"):
+		name := strings.TrimPrefix(source, "env:")
+		token = os.Getenv(name)
+		if token == "" {
+			return "", fmt.Errorf("%s is not set", name)
+		}
+	case strings.HasPrefix(source, "file:"):
+		var err error
+		if token, err = readTokenFile(strings.TrimPrefix(source, "file:")); err != nil {
+			return "", err
+		}
+	case strings.HasPrefix(source, "cmd:"):
+		var err error
+		if token, err = runTokenCommand(strings.TrimPrefix(source, "cmd:"), tokenCommandTimeout); err != nil {
+			return "", err
+		}
+	default:
+		token = source
+	}
+	return token, nil
+}
+
+// describeSource names a source for logs without revealing a literal token.
+func describeSource(source string) string {
+	if strings.HasPrefix(source, "env:") || strings.HasPrefix(source, "file:") || strings.HasPrefix(source, "cmd:") {
+		return source
+	}
+	return "literal token"
+}
+
+// parseLoginsJSON reads BCCE_LOGINS_JSON, a provider -> token source object.
+func parseLoginsJSON(value string) (map[string]string, error) {
+	var sources map[string]string
+	if err := json.Unmarshal([]byte(value), &sources); err != nil {
+		return nil, fmt.Errorf("BCCE_LOGINS_JSON must be a JSON object of provider name to token source: %w", err)
+	}
+	return sources, nil
+}
+
+// buildLogins resolves every source into the Logins map sent to Cognito.
+// Entries that do not resolve are left out; at least one must resolve.
+func buildLogins(sources map[string]string) (map[string]string, error) {
+	providers := make([]string, 0, len(sources))
+	for provider := range sources {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	logins := map[string]string{}
+	var failures []string
+	for _, provider := range providers {
+		source := sources[provider]
+		token, err := resolveTokenSource(source)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s (%s): %v", provider, describeSource(source), err))
+			verbosef("Login %s from %s skipped: %v", provider, describeSource(source), err)
+			continue
+		}
+		logins[provider] = token
+		verbosef("Login %s from %s", provider, describeSource(source))
+	}
+	if len(logins) == 0 {
+		if len(failures) == 0 {
+			return nil, fmt.Errorf("no Logins entries configured")
+		}
+		return nil, fmt.Errorf("no Logins entry resolved: %s", strings.Join(failures, "; "))
+	}
+	return logins, nil
+}
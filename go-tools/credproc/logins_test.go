@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildLogins(t *testing.T) {
+	dir := t.TempDir()
+	samlFile := filepath.Join(dir, "saml")
+	if err := os.WriteFile(samlFile, []byte("saml-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CORP_OIDC_TOKEN", "oidc-token")
+	t.Setenv("UNSET_TOKEN", "")
+
+	tests := []struct {
+		name    string
+		sources map[string]string
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "every kind of source",
+			sources: map[string]string{"arn:aws:iam::123456789012:saml-provider/corp": "file:" + samlFile, "dev-123.okta.com/oauth2/default": "env:CORP_OIDC_TOKEN", "accounts.google.com": "literal-token"},
+			want:    map[string]string{"arn:aws:iam::123456789012:saml-provider/corp": "saml-token", "dev-123.okta.com/oauth2/default": "oidc-token", "accounts.google.com": "literal-token"},
+		},
+		{
+			name:    "unresolved entries are left out",
+			sources: map[string]string{"a": "env:UNSET_TOKEN", "b": "file:" + emptyFile, "c": "env:CORP_OIDC_TOKEN"},
+			want:    map[string]string{"c": "oidc-token"},
+		},
+		{
+			name:    "none resolves",
+			sources: map[string]string{"a": "env:UNSET_TOKEN", "b": "file:" + filepath.Join(dir, "missing")},
+			err:     "no Logins entry resolved: a (env:UNSET_TOKEN): UNSET_TOKEN is not set; b (file:",
+		},
+		{
+			name: "empty",
+			err:  "no Logins entries configured",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildLogins(tt.sources)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildLogins = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeSourceHidesLiterals(t *testing.T) {
+	if got := describeSource("eyJhbGciOi.secret.sig"); strings.Contains(got, "secret") {
+		t.Errorf("describeSource revealed a literal token: %q", got)
+	}
+	if got := describeSource("env:CORP_TOKEN"); got != "env:CORP_TOKEN" {
+		t.Errorf("describeSource(env:CORP_TOKEN) = %q", got)
+	}
+}
+
+func TestLoadConfigLogins(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	t.Setenv("OIDC_ID_TOKEN", "")
+	t.Setenv("OIDC_ID_TOKEN_FILE", "")
+	t.Setenv("OIDC_PROVIDER_NAME", "")
+	t.Setenv("BCCE_ROLE_ARN", "")
+	t.Setenv("BCCE_LOGINS_JSON", "")
+	t.Setenv("SAML_TOKEN", "saml-token")
+	t.Setenv("CORP_OIDC_TOKEN", "oidc-token")
+
+	if _, err := loadConfig(options{profile: "default"}, nil); err == nil || !strings.Contains(err.Error(), "BCCE_LOGINS_JSON") {
+		t.Errorf("loadConfig with no token = %v, want an error naming every way to give one", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(home, ".bcce"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := "profiles:\n  dev:\n    logins:\n      corp-saml: env:SAML_TOKEN\n      dev-123.okta.com/oauth2/default: env:CORP_OIDC_TOKEN\n"
+	if err := os.WriteFile(filepath.Join(home, ".bcce", "credproc.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(options{profile: "dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"corp-saml": "saml-token", "dev-123.okta.com/oauth2/default": "oidc-token"}; !reflect.DeepEqual(cfg.Logins, want) {
+		t.Errorf("Logins from the config profile = %v, want %v", cfg.Logins, want)
+	}
+
+	t.Setenv("BCCE_LOGINS_JSON", `{"corp-saml": "env:SAML_TOKEN"}`)
+	t.Setenv("BCCE_ROLE_ARN", "arn:aws:iam::123456789012:role/bedrock")
+	cfg, err = loadConfig(options{profile: "dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"corp-saml": "saml-token"}; !reflect.DeepEqual(cfg.Logins, want) || cfg.OIDCToken != "saml-token" {
+		t.Errorf("Logins from BCCE_LOGINS_JSON = %v (web identity token %q), want %v and its token", cfg.Logins, cfg.OIDCToken, want)
+	}
+
+	t.Setenv("BCCE_LOGINS_JSON", `["not", "an", "object"]`)
+	if _, err := loadConfig(options{profile: "dev"}, nil); err == nil || !strings.Contains(err.Error(), "JSON object") {
+		t.Errorf("loadConfig with a malformed BCCE_LOGINS_JSON = %v", err)
+	}
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -29,14 +31,63 @@ type Config struct {
 	IdentityPoolID string
 	OIDCToken      string
 	RoleArn        string
+	// Cognito Logins map sent with both Cognito calls: provider name -> token
+	Logins map[string]string
+	// Checked against the OIDC token before any AWS call
+	Expect tokenExpectations
+	// DurationSeconds and RoleSessionName for AssumeRoleWithWebIdentity
+	SessionDuration time.Duration
+	SessionName     string
+	// Session tags mapped from the OIDC token's claims, set on a chained
+	// sts:AssumeRole into TaggedRoleArn
+	SessionTags       map[string]string
+	TransitiveTagKeys []string
+	TaggedRoleArn     string
 }
 
-func loadConfig() (*Config, error) {
+// options are the command-line flags.
+type options struct {
+	provider    string // Cognito Logins key for the OIDC token
+	configPath  string
+	profile     string
+	verbose     bool
+	debugClaims bool // print the OIDC token's claims on stderr
+
+	tokenFile           string // read the OIDC token from this file
+	tokenCommand        string // run this command for the OIDC token
+	tokenCommandTimeout time.Duration
+
+	duration time.Duration // session length with BCCE_ROLE_ARN
+
+	noCache     bool
+	cacheBuffer time.Duration // reuse cached credentials with more validity left
+	lockTimeout time.Duration // wait this long for a concurrent exchange
+}
+
+// verbose enables progress notes on stderr; they never include tokens.
+var verbose bool
+
+func verbosef(format string, args ...any) {
+	if verbose {
+		log.Printf(format, args...)
+	}
+}
+
+// loadConfig reads the environment and the config file profile. The Logins
+// map comes from BCCE_LOGINS_JSON, else the profile's logins, else the
+// shorthand of the OIDC token (see oidcTokenSource) under the --provider or
+// OIDC_PROVIDER_NAME key. Without a current token, the refresh_token grant
+// or the profile's credproc login supplies one. store, which may be nil,
+// keeps refresh tokens and logins.
+func loadConfig(opts options, store cacheBackend) (*Config, error) {
 	cfg := &Config{
 		Region:         os.Getenv("AWS_REGION"),
 		IdentityPoolID: os.Getenv("COGNITO_IDENTITY_POOL_ID"),
-		OIDCToken:      os.Getenv("OIDC_ID_TOKEN"),
 		RoleArn:        os.Getenv("BCCE_ROLE_ARN"),
+		Expect: tokenExpectations{
+			audience: os.Getenv("OIDC_EXPECTED_AUDIENCE"),
+			issuer:   os.Getenv("OIDC_EXPECTED_ISSUER"),
+		},
 	}
 
 	if cfg.Region == "" {
@@ -45,14 +96,122 @@ func loadConfig() (*Config, error) {
 	if cfg.IdentityPoolID == "" {
 		return nil, fmt.Errorf("COGNITO_IDENTITY_POOL_ID environment variable is required")
 	}
-	if cfg.OIDCToken == "" {
-		return nil, fmt.Errorf("OIDC_ID_TOKEN environment variable is required")
+	var err error
+	if cfg.SessionDuration, err = parseSessionDuration(opts.duration, os.Getenv("BCCE_SESSION_DURATION")); err != nil {
+		return nil, err
+	}
+	if cfg.RoleArn == "" && cfg.SessionDuration != defaultSessionDuration {
+		verbosef("Ignoring the session duration: Cognito issues one-hour credentials without BCCE_ROLE_ARN")
+	}
+
+	configPath, required := opts.configPath, opts.configPath != ""
+	if !required {
+		configPath = defaultConfigPath()
+	}
+	var profile profileConfig
+	if configPath != "" {
+		var err error
+		if profile, err = loadProfileConfig(configPath, opts.profile, required); err != nil {
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+	}
+
+	tokenSource := oidcTokenSource(opts, profile)
+	if tokenSource != "" {
+		token, err := resolveTokenSource(tokenSource)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC token from %s: %w", describeSource(tokenSource), err)
+		}
+		if !strings.HasPrefix(tokenSource, "env:") {
+			if err := checkJWTShape(token); err != nil {
+				return nil, fmt.Errorf("OIDC token from %s: %w", describeSource(tokenSource), err)
+			}
+		}
+		cfg.OIDCToken = token
+	}
+	tokenFrom := describeSource(tokenSource)
+
+	// An expired or missing ID token is refreshed when an OAuth client is
+	// configured; a rotated refresh token is kept in the cache backend.
+	refresh, canRefresh, err := refreshConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if canRefresh && (cfg.OIDCToken == "" || tokenExpired(cfg.OIDCToken, time.Now())) {
+		if cfg.OIDCToken != "" {
+			verbosef("The ID token from %s has expired", tokenFrom)
+		}
+		if cfg.OIDCToken, err = refreshIDToken(refresh, store); err != nil {
+			return nil, err
+		}
+		tokenFrom = "the refresh_token grant"
+	}
+	if cfg.OIDCToken == "" && !canRefresh && store != nil {
+		if cfg.OIDCToken, err = storedLoginToken(store, opts.profile); err != nil {
+			return nil, err
+		}
+		tokenFrom = "credproc login"
+	}
+
+	var logins map[string]string
+	switch {
+	case os.Getenv("BCCE_LOGINS_JSON") != "":
+		sources, err := parseLoginsJSON(os.Getenv("BCCE_LOGINS_JSON"))
+		if err != nil {
+			return nil, err
+		}
+		verbosef("Logins from BCCE_LOGINS_JSON")
+		if logins, err = buildLogins(sources); err != nil {
+			return nil, err
+		}
+	case len(profile.Logins) > 0:
+		verbosef("Logins from profile %q of %s", opts.profile, configPath)
+		var err error
+		if logins, err = buildLogins(profile.Logins); err != nil {
+			return nil, err
+		}
+	case cfg.OIDCToken == "":
+		return nil, fmt.Errorf("an OIDC token is required: set --token-command, --token-file, OIDC_TOKEN_COMMAND, OIDC_ID_TOKEN_FILE, or OIDC_ID_TOKEN (or BCCE_LOGINS_JSON, logins or token_command in the config file, OIDC_TOKEN_ENDPOINT with a refresh token, or run credproc login)")
+	default:
+		// The token is already read; reading a rotating file twice could
+		// mix two tokens.
+		providerName, err := resolveProvider(opts.provider, os.Getenv("OIDC_PROVIDER_NAME"), cfg.OIDCToken)
+		if err != nil {
+			return nil, err
+		}
+		logins = map[string]string{providerName: cfg.OIDCToken}
+		verbosef("Login %s from %s", providerName, tokenFrom)
+	}
+	cfg.Logins = logins
+
+	// AssumeRoleWithWebIdentity takes one token; with a single login, that one.
+	if cfg.RoleArn != "" && cfg.OIDCToken == "" {
+		if len(logins) != 1 {
+			return nil, fmt.Errorf("BCCE_ROLE_ARN needs OIDC_ID_TOKEN when there are several Logins entries")
+		}
+		for _, token := range logins {
+			cfg.OIDCToken = token
+		}
+	}
+
+	var nameFrom string
+	cfg.SessionName, nameFrom = resolveSessionName(os.Getenv("BCCE_SESSION_NAME"), os.Getenv("BCCE_SESSION_NAME_CLAIM"), cfg.OIDCToken)
+	if cfg.RoleArn != "" {
+		verbosef("Role session name %q from %s", cfg.SessionName, nameFrom)
+	}
+	if err := configureSessionTags(cfg, profile); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
 }
 
 func exchangeToken(ctx context.Context, cfg *Config) (*CredentialsOutput, error) {
+	// A token AWS would refuse fails here, without a round trip
+	if err := validateTokens(cfg, time.Now()); err != nil {
+		return nil, err
+	}
+
 	// Load AWS config
 	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
 	if err != nil {
@@ -65,10 +224,7 @@ func exchangeToken(ctx context.Context, cfg *Config) (*CredentialsOutput, error)
 	// Get Identity ID from Cognito Identity Pool using OIDC token
 	getIdInput := &cognitoidentity.GetIdInput{
 		IdentityPoolId: aws.String(cfg.IdentityPoolID),
-		Logins: map[string]string{
-			// This key depends on your OIDC provider configuration
-			"accounts.google.com": cfg.OIDCToken, // Example for Google
-		},
+		Logins:         cfg.Logins,
 	}
 
 	getIdOutput, err := cognitoClient.GetId(ctx, getIdInput)
@@ -79,33 +235,35 @@ func exchangeToken(ctx context.Context, cfg *Config) (*CredentialsOutput, error)
 	// Get credentials for the identity
 	getCredsInput := &cognitoidentity.GetCredentialsForIdentityInput{
 		IdentityId: getIdOutput.IdentityId,
-		Logins: map[string]string{
-			"accounts.google.com": cfg.OIDCToken,
-		},
+		Logins:     cfg.Logins,
 	}
 
 	if cfg.RoleArn != "" {
 		// If specific role is required, use STS AssumeRoleWithWebIdentity instead
 		stsClient := sts.NewFromConfig(awsCfg)
-		
-		assumeRoleInput := &sts.AssumeRoleWithWebIdentityInput{
-			RoleArn:          aws.String(cfg.RoleArn),
-			RoleSessionName:  aws.String("bcce-session"),
-			WebIdentityToken: aws.String(cfg.OIDCToken),
-			DurationSeconds:  aws.Int32(3600), // 1 hour
-		}
 
-		assumeRoleOutput, err := stsClient.AssumeRoleWithWebIdentity(ctx, assumeRoleInput)
+		assumeRoleOutput, err := assumeRole(ctx, stsClient, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to assume role: %w", err)
 		}
+		creds := assumeRoleOutput.Credentials
+
+		// Mapped session tags need a second, chained call
+		if len(cfg.SessionTags) > 0 {
+			chained := sts.NewFromConfig(awsCfg, func(o *sts.Options) {
+				o.Credentials = sessionCredentials(creds)
+			})
+			if creds, err = assumeTaggedRole(ctx, chained, cfg); err != nil {
+				return nil, fmt.Errorf("failed to assume role with session tags: %w", err)
+			}
+		}
 
 		return &CredentialsOutput{
 			Version:         1,
-			AccessKeyId:     *assumeRoleOutput.Credentials.AccessKeyId,
-			SecretAccessKey: *assumeRoleOutput.Credentials.SecretAccessKey,
-			SessionToken:    *assumeRoleOutput.Credentials.SessionToken,
-			Expiration:      assumeRoleOutput.Credentials.Expiration.Format(time.RFC3339),
+			AccessKeyId:     *creds.AccessKeyId,
+			SecretAccessKey: *creds.SecretAccessKey,
+			SessionToken:    *creds.SessionToken,
+			Expiration:      creds.Expiration.Format(time.RFC3339),
 		}, nil
 	}
 
@@ -124,28 +282,90 @@ func exchangeToken(ctx context.Context, cfg *Config) (*CredentialsOutput, error)
 	}, nil
 }
 
+// openStores opens the cache backend in dir. store keeps credproc login's
+// stored logins and rotated refresh tokens; cache, the same backend unless
+// --no-cache, keeps credentials. --no-cache skips only the credentials, so a
+// profile signed in with credproc login still finds its login.
+func openStores(opts options, dir string) (store, cache cacheBackend, err error) {
+	if store, err = openCache(os.Getenv("BCCE_CACHE_BACKEND"), osSecretStore, dir); err != nil {
+		return nil, nil, err
+	}
+	if opts.noCache {
+		return store, nil, nil
+	}
+	return store, store, nil
+}
+
+// fail reports err on stderr and exits after writing empty credentials,
+// which satisfies the credential_process contract.
+func fail(what string, err error) {
+	log.Printf("%s: %v", what, err)
+	emptyCreds := &CredentialsOutput{Version: 1}
+	json.NewEncoder(os.Stdout).Encode(emptyCreds)
+	os.Exit(1)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		loginMain(os.Args[2:])
+	}
+
+	var opts options
+	flag.StringVar(&opts.provider, "provider", "", "Cognito Logins key of the OIDC provider (default OIDC_PROVIDER_NAME, else the token's issuer)")
+	flag.StringVar(&opts.tokenFile, "token-file", "", "File holding the OIDC token, read on every run (default OIDC_ID_TOKEN_FILE, else OIDC_ID_TOKEN)")
+	flag.StringVar(&opts.tokenCommand, "token-command", "", "Shell command printing the OIDC token (default OIDC_TOKEN_COMMAND)")
+	flag.DurationVar(&opts.tokenCommandTimeout, "token-command-timeout", defaultTokenCommandTimeout, "How long the token command may run")
+	flag.StringVar(&opts.configPath, "config", "", "Config file (default ~/.bcce/credproc.yaml)")
+	flag.StringVar(&opts.profile, "profile", "default", "Profile of the config file to use")
+	flag.BoolVar(&opts.verbose, "verbose", false, "Report progress on stderr (never tokens)")
+	flag.BoolVar(&opts.debugClaims, "debug-claims", false, "Print the OIDC token's claims on stderr (they may identify the user)")
+	flag.DurationVar(&opts.duration, "duration", 0, "Session length with BCCE_ROLE_ARN, 15m to 12h (default BCCE_SESSION_DURATION, else 1h)")
+	flag.BoolVar(&opts.noCache, "no-cache", false, "Neither read nor write cached credentials (a credproc login is still used)")
+	flag.DurationVar(&opts.cacheBuffer, "cache-buffer", 0, "Validity cached credentials must have left to be reused (default BCCE_CACHE_BUFFER, else 5m)")
+	flag.DurationVar(&opts.lockTimeout, "lock-timeout", defaultLockTimeout, "How long to wait for another credproc exchanging the same token")
+	flag.Parse()
+	verbose = opts.verbose
+	tokenCommandTimeout = opts.tokenCommandTimeout
+
+	if opts.cacheBuffer == 0 {
+		opts.cacheBuffer = defaultCacheBuffer
+		if v := os.Getenv("BCCE_CACHE_BUFFER"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				fail("Configuration error", fmt.Errorf("BCCE_CACHE_BUFFER must be a duration such as 10m, not %q", v))
+			}
+			opts.cacheBuffer = d
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	cacheDir := defaultCacheDir()
+	store, cache, err := openStores(opts, cacheDir)
+	if err != nil {
+		fail("Configuration error", err)
+	}
+
 	// Load configuration
-	cfg, err := loadConfig()
+	cfg, err := loadConfig(opts, store)
 	if err != nil {
-		log.Printf("Configuration error: %v", err)
-		// Return empty credentials to satisfy AWS credential_process contract
-		emptyCreds := &CredentialsOutput{Version: 1}
-		json.NewEncoder(os.Stdout).Encode(emptyCreds)
-		os.Exit(1)
+		fail("Configuration error", err)
 	}
 
-	// Exchange OIDC token for AWS credentials
-	creds, err := exchangeToken(ctx, cfg)
+	if opts.debugClaims && cfg.OIDCToken != "" {
+		fmt.Fprintf(os.Stderr, "OIDC token claims:\n%s\n", debugClaims(cfg.OIDCToken))
+	}
+
+	// Exchange OIDC token for AWS credentials, unless the cache has them
+	var creds *CredentialsOutput
+	if cache == nil {
+		creds, err = exchangeToken(ctx, cfg)
+	} else {
+		creds, err = cachedCredentials(ctx, cfg, cache, cacheLocks{dir: cacheDir, timeout: opts.lockTimeout}, opts.cacheBuffer, exchangeToken)
+	}
 	if err != nil {
-		log.Printf("Token exchange failed: %v", err)
-		// Return empty credentials to satisfy AWS credential_process contract
-		emptyCreds := &CredentialsOutput{Version: 1}
-		json.NewEncoder(os.Stdout).Encode(emptyCreds)
-		os.Exit(1)
+		fail("Token exchange failed", err)
 	}
 
 	// Output credentials in AWS credential_process format
@@ -153,4 +373,4 @@ func main() {
 		log.Printf("JSON encoding failed: %v", err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// tokenClaims decodes the payload of a JWT without verifying it, explaining
+// how a malformed token is malformed.
+func tokenClaims(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed ID token: %d dot-separated segments, want 3", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token: the payload is not valid base64url")
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token: the payload is not a JSON object")
+	}
+	return claims, nil
+}
+
+// providerFromToken derives the Cognito Logins key from the token's iss
+// claim: the issuer without its scheme or trailing slash, which is how
+// Cognito names OIDC providers (e.g. https://dev-123.okta.com/oauth2/default
+// becomes dev-123.okta.com/oauth2/default).
+func providerFromToken(token string) (string, error) {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return "", err
+	}
+	iss, _ := claims["iss"].(string)
+	if iss == "" {
+		return "", fmt.Errorf("OIDC token has no iss claim")
+	}
+	if _, rest, ok := strings.Cut(iss, "://"); ok {
+		iss = rest
+	}
+	return strings.TrimSuffix(iss, "/"), nil
+}
+
+// resolveProvider returns the Logins key to use: the --provider flag, then
+// OIDC_PROVIDER_NAME, then the key derived from the token's issuer.
+func resolveProvider(flagValue, envValue, token string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if envValue != "" {
+		return envValue, nil
+	}
+	provider, err := providerFromToken(token)
+	if err != nil {
+		return "", fmt.Errorf("set OIDC_PROVIDER_NAME or --provider to the identity pool's provider name (e.g. login.microsoftonline.com/<tenant>/v2.0); it could not be derived from the token: %w", err)
+	}
+	return provider, nil
+}
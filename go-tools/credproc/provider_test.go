@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testToken builds an unsigned JWT with the given payload.
+func testToken(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestResolveProvider(t *testing.T) {
+	okta := testToken(`{"iss":"https://dev-123.okta.com/oauth2/default","sub":"u1"}`)
+	tests := []struct {
+		name      string
+		flagValue string
+		envValue  string
+		token     string
+		want      string
+		err       string
+	}{
+		{name: "flag", flagValue: "login.microsoftonline.com/tenant/v2.0", envValue: "accounts.google.com", token: okta, want: "login.microsoftonline.com/tenant/v2.0"},
+		{name: "environment", envValue: "cognito-idp.us-east-1.amazonaws.com/us-east-1_abc", token: okta, want: "cognito-idp.us-east-1.amazonaws.com/us-east-1_abc"},
+		{name: "derived from iss", token: okta, want: "dev-123.okta.com/oauth2/default"},
+		{name: "derived, trailing slash", token: testToken(`{"iss":"https://oidc.eks.us-west-2.amazonaws.com/id/ABC/"}`), want: "oidc.eks.us-west-2.amazonaws.com/id/ABC"},
+		{name: "derived, no scheme", token: testToken(`{"iss":"accounts.google.com"}`), want: "accounts.google.com"},
+		{name: "missing iss", token: testToken(`{"sub":"u1"}`), err: "has no iss claim"},
+		{name: "not a JWT", token: "opaque-token", err: "malformed ID token: 1 dot-separated segments"},
+		{name: "bad payload", token: "a.%%%.c", err: "the payload is not valid base64url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProvider(tt.flagValue, tt.envValue, tt.token)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.Contains(err.Error(), "OIDC_PROVIDER_NAME") {
+					t.Fatalf("err = %v, want one containing %q and naming OIDC_PROVIDER_NAME", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveProvider = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("BCCE_LOGINS_JSON", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	t.Setenv("OIDC_ID_TOKEN_FILE", "")
+	t.Setenv("OIDC_ID_TOKEN", "opaque-token")
+	t.Setenv("OIDC_PROVIDER_NAME", "")
+
+	if _, err := loadConfig(options{}, nil); err == nil {
+		t.Error("loadConfig without a provider succeeded, want an error before any AWS call")
+	}
+	t.Setenv("OIDC_PROVIDER_NAME", "accounts.google.com")
+	cfg, err := loadConfig(options{}, nil)
+	if err != nil || cfg.Logins["accounts.google.com"] != "opaque-token" {
+		t.Errorf("loadConfig = %+v, %v; want provider accounts.google.com", cfg, err)
+	}
+	cfg, err = loadConfig(options{provider: "dev-123.okta.com/oauth2/default"}, nil)
+	if err != nil || cfg.Logins["dev-123.okta.com/oauth2/default"] != "opaque-token" {
+		t.Errorf("loadConfig with --provider = %+v, %v; want the flag's provider", cfg, err)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// refreshTimeout bounds the refresh_token grant.
+const refreshTimeout = 15 * time.Second
+
+// tokenExpirySkew treats ID tokens this close to exp as expired, so one
+// does not lapse between here and Cognito.
+const tokenExpirySkew = 30 * time.Second
+
+// refreshConfig is the OAuth client credproc refreshes ID tokens with.
+type refreshConfig struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	// refreshToken is the configured token; a rotated one may be stored.
+	refreshToken string
+	// source is where refreshToken comes from, named in the fix when the
+	// IdP rejects it: the variable it is configured in, or the credproc
+	// login command that stored it.
+	source string
+	// login is whether source is a credproc login command.
+	login bool
+}
+
+// refreshConfigFromEnv reads OIDC_TOKEN_ENDPOINT, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET and OIDC_REFRESH_TOKEN (or OIDC_REFRESH_TOKEN_FILE).
+// ok is false when no token endpoint is set.
+func refreshConfigFromEnv() (r refreshConfig, ok bool, err error) {
+	r = refreshConfig{
+		endpoint:     os.Getenv("OIDC_TOKEN_ENDPOINT"),
+		clientID:     os.Getenv("OIDC_CLIENT_ID"),
+		clientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		refreshToken: os.Getenv("OIDC_REFRESH_TOKEN"),
+		source:       "OIDC_REFRESH_TOKEN",
+	}
+	if r.endpoint == "" {
+		return r, false, nil
+	}
+	if r.clientID == "" {
+		return r, false, fmt.Errorf("OIDC_TOKEN_ENDPOINT needs OIDC_CLIENT_ID")
+	}
+	if path := os.Getenv("OIDC_REFRESH_TOKEN_FILE"); path != "" {
+		if r.refreshToken, err = readTokenFile(path); err != nil {
+			return r, false, fmt.Errorf("OIDC_REFRESH_TOKEN_FILE: %w", err)
+		}
+		r.source = "OIDC_REFRESH_TOKEN_FILE"
+	}
+	return r, true, nil
+}
+
+// storeName is where the client's rotated refresh token is kept.
+func (r refreshConfig) storeName() string {
+	sum := sha256.Sum256([]byte(r.endpoint + "\x00" + r.clientID))
+	return "refresh-" + hex.EncodeToString(sum[:])
+}
+
+// storedRefresh is a rotated refresh token and a hash of the configured one
+// it descends from. Once the configured token changes (the user logged in
+// again), the stored one is stale.
+type storedRefresh struct {
+	Configured   string `json:"configured_sha256"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// currentRefreshToken returns the stored rotation of the configured token,
+// or the configured token itself.
+func currentRefreshToken(r refreshConfig, store cacheBackend) string {
+	if store == nil {
+		return r.refreshToken
+	}
+	data, err := store.load(r.storeName())
+	if err != nil {
+		verbosef("Ignoring the stored refresh token: %v", err)
+		return r.refreshToken
+	}
+	var stored storedRefresh
+	if data == nil || json.Unmarshal(data, &stored) != nil || stored.RefreshToken == "" {
+		return r.refreshToken
+	}
+	if stored.Configured != sha256Hex(r.refreshToken) {
+		verbosef("The configured refresh token changed; not using the stored one")
+		return r.refreshToken
+	}
+	verbosef("Using the stored refresh token")
+	return stored.RefreshToken
+}
+
+// saveRefreshToken stores a rotated refresh token in the cache backend.
+func saveRefreshToken(r refreshConfig, store cacheBackend, token string) {
+	if store == nil {
+		verbosef("Not storing the rotated refresh token: the cache is disabled")
+		return
+	}
+	data, err := json.Marshal(storedRefresh{Configured: sha256Hex(r.refreshToken), RefreshToken: token})
+	if err == nil {
+		err = store.save(r.storeName(), data)
+	}
+	if err != nil {
+		log.Printf("Could not store the rotated refresh token: %v", err)
+	}
+}
+
+// tokenResponse is a token endpoint's reply, success or error (RFC 6749).
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// postTokenRequest posts form to the token endpoint and decodes the reply.
+func postTokenRequest(ctx context.Context, endpoint string, form url.Values) (*tokenResponse, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("token endpoint returned HTTP %d without a JSON body", resp.StatusCode)
+	}
+	return &tr, resp.StatusCode, nil
+}
+
+// refreshIDToken performs the refresh_token grant and returns the new ID
+// token, storing the refresh token if the IdP rotated it.
+func refreshIDToken(r refreshConfig, store cacheBackend) (string, error) {
+	refreshToken := currentRefreshToken(r, store)
+	if refreshToken == "" {
+		return "", fmt.Errorf("no refresh token: set OIDC_REFRESH_TOKEN or OIDC_REFRESH_TOKEN_FILE")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {r.clientID},
+	}
+	if r.clientSecret != "" {
+		form.Set("client_secret", r.clientSecret)
+	}
+	verbosef("Refreshing the ID token at %s", r.endpoint)
+	tr, status, err := postTokenRequest(ctx, r.endpoint, form)
+	if err != nil {
+		return "", fmt.Errorf("refresh_token grant: %w", err)
+	}
+	switch {
+	case tr.Error == "invalid_grant" && r.login:
+		return "", fmt.Errorf("the refresh token was rejected (invalid_grant%s); run %s again", describeOAuthError(tr), r.source)
+	case tr.Error == "invalid_grant":
+		return "", fmt.Errorf("the refresh token was rejected (invalid_grant%s); re-authenticate with your IdP and update %s", describeOAuthError(tr), r.source)
+	case tr.Error != "":
+		return "", fmt.Errorf("refresh_token grant: %s%s", tr.Error, describeOAuthError(tr))
+	case status != http.StatusOK:
+		return "", fmt.Errorf("refresh_token grant: token endpoint returned HTTP %d", status)
+	case tr.IDToken == "":
+		return "", fmt.Errorf("refresh_token grant: the response has no id_token (is the openid scope granted?)")
+	}
+	if err := checkJWTShape(tr.IDToken); err != nil {
+		return "", fmt.Errorf("refresh_token grant: id_token: %w", err)
+	}
+	if tr.RefreshToken != "" && tr.RefreshToken != refreshToken {
+		verbosef("The IdP rotated the refresh token; storing the new one")
+		saveRefreshToken(r, store, tr.RefreshToken)
+	}
+	return tr.IDToken, nil
+}
+
+func describeOAuthError(tr *tokenResponse) string {
+	if tr.ErrorDescription == "" {
+		return ""
+	}
+	return ": " + tr.ErrorDescription
+}
+
+// tokenExpired reports whether a JWT's exp is within tokenExpirySkew of
+// now. Tokens without a readable exp are left to the IdP to judge.
+func tokenExpired(token string, now time.Time) bool {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return false
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return false
+	}
+	return time.Unix(int64(exp), 0).Before(now.Add(tokenExpirySkew))
+}
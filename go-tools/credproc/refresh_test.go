@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTokenEndpoint is an OAuth token endpoint accepting one refresh token
+// at a time, rotating it on each use when rotate is set.
+type fakeTokenEndpoint struct {
+	valid  string
+	rotate bool
+	grants int
+}
+
+func (e *fakeTokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("client_id") != "credproc" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_request"}`)
+		return
+	}
+	if r.PostFormValue("refresh_token") != e.valid {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Refresh token expired"}`)
+		return
+	}
+	e.grants++
+	resp := map[string]string{"id_token": testToken(fmt.Sprintf(`{"sub":"alice","grant":%d}`, e.grants))}
+	if e.rotate {
+		e.valid = fmt.Sprintf("refresh-%d", e.grants)
+		resp["refresh_token"] = e.valid
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func grantNumber(t *testing.T, token string) int {
+	t.Helper()
+	claims, err := tokenClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(claims["grant"].(float64))
+}
+
+func TestRefreshIDToken(t *testing.T) {
+	endpoint := &fakeTokenEndpoint{valid: "refresh-0"}
+	srv := httptest.NewServer(endpoint)
+	defer srv.Close()
+	r := refreshConfig{endpoint: srv.URL, clientID: "credproc", refreshToken: "refresh-0", source: "OIDC_REFRESH_TOKEN_FILE"}
+	store := fileCache{dir: t.TempDir()}
+
+	token, err := refreshIDToken(r, store)
+	if err != nil || grantNumber(t, token) != 1 {
+		t.Fatalf("refreshIDToken = %q, %v; want the first grant's ID token", token, err)
+	}
+	if data, _ := store.load(r.storeName()); data != nil {
+		t.Errorf("stored %s without a rotation", data)
+	}
+
+	// A rotated refresh token is stored and used next time; the configured
+	// one is no longer valid.
+	endpoint.rotate = true
+	for want := 2; want <= 3; want++ {
+		token, err := refreshIDToken(r, store)
+		if err != nil || grantNumber(t, token) != want {
+			t.Fatalf("refreshIDToken = %q, %v; want grant %d", token, err, want)
+		}
+	}
+	if got := currentRefreshToken(r, store); got != "refresh-3" {
+		t.Errorf("stored refresh token %q, want refresh-3", got)
+	}
+
+	// Without a cache backend the rotation is lost, and the configured
+	// token is rejected with an actionable error.
+	_, err = refreshIDToken(r, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") || !strings.Contains(err.Error(), "re-authenticate with your IdP and update OIDC_REFRESH_TOKEN_FILE") {
+		t.Errorf("refreshIDToken with a revoked token = %v, want an invalid_grant error telling the user to log in", err)
+	}
+
+	// A new configured token (after logging in again) wins over the stored
+	// rotation of the old one.
+	endpoint.valid = "fresh-login"
+	r.refreshToken = "fresh-login"
+	if _, err := refreshIDToken(r, store); err != nil {
+		t.Errorf("refreshIDToken with a new configured token = %v", err)
+	}
+}
+
+func TestRefreshIDTokenErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"server error", http.StatusInternalServerError, `{}`, "HTTP 500"},
+		{"HTML", http.StatusBadGateway, `<html>`, "without a JSON body"},
+		{"no id_token", http.StatusOK, `{"access_token":"at"}`, "no id_token"},
+		{"opaque id_token", http.StatusOK, `{"id_token":"opaque"}`, "not a JWT"},
+		{"other OAuth error", http.StatusUnauthorized, `{"error":"invalid_client"}`, "invalid_client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			_, err := refreshIDToken(refreshConfig{endpoint: srv.URL, clientID: "credproc", refreshToken: "rt"}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("refreshIDToken = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{testToken(`{"exp":1800003600}`), false},
+		{testToken(`{"exp":1800000010}`), true}, // within the skew
+		{testToken(`{"exp":1799999000}`), true},
+		{testToken(`{"sub":"no exp"}`), false},
+		{"opaque", false},
+	}
+	for _, tt := range tests {
+		if got := tokenExpired(tt.token, now); got != tt.want {
+			t.Errorf("tokenExpired(%s) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigRefresh(t *testing.T) {
+	endpoint := &fakeTokenEndpoint{valid: "refresh-0"}
+	srv := httptest.NewServer(endpoint)
+	defer srv.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	t.Setenv("BCCE_LOGINS_JSON", "")
+	t.Setenv("BCCE_ROLE_ARN", "")
+	t.Setenv("OIDC_PROVIDER_NAME", "idp.example.com")
+	t.Setenv("OIDC_ID_TOKEN_FILE", "")
+	t.Setenv("OIDC_TOKEN_COMMAND", "")
+	t.Setenv("OIDC_TOKEN_ENDPOINT", srv.URL)
+	t.Setenv("OIDC_CLIENT_ID", "credproc")
+	t.Setenv("OIDC_CLIENT_SECRET", "")
+	t.Setenv("OIDC_REFRESH_TOKEN", "refresh-0")
+	t.Setenv("OIDC_REFRESH_TOKEN_FILE", "")
+
+	valid := testToken(fmt.Sprintf(`{"sub":"alice","exp":%d}`, time.Now().Add(time.Hour).Unix()))
+	expired := testToken(fmt.Sprintf(`{"sub":"alice","exp":%d}`, time.Now().Add(-time.Minute).Unix()))
+	for _, tt := range []struct {
+		name, token string
+		refreshed   bool
+	}{
+		{"valid token", valid, false},
+		{"expired token", expired, true},
+		{"no token", "", true},
+	} {
+		t.Setenv("OIDC_ID_TOKEN", tt.token)
+		grants := endpoint.grants
+		cfg, err := loadConfig(options{}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if refreshed := endpoint.grants > grants; refreshed != tt.refreshed {
+			t.Errorf("%s: refreshed = %v, want %v", tt.name, refreshed, tt.refreshed)
+		}
+		if cfg.Logins["idp.example.com"] != cfg.OIDCToken || (tt.refreshed && cfg.OIDCToken == tt.token) {
+			t.Errorf("%s: Logins %v do not carry the current ID token", tt.name, cfg.Logins)
+		}
+	}
+
+	t.Setenv("OIDC_CLIENT_ID", "")
+	if _, err := loadConfig(options{}, nil); err == nil || !strings.Contains(err.Error(), "OIDC_CLIENT_ID") {
+		t.Errorf("loadConfig without OIDC_CLIENT_ID = %v", err)
+	}
+}
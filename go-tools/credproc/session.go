@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// STS accepts DurationSeconds from 15 minutes to 12 hours; the role's
+// MaxSessionDuration (1 hour unless raised) may allow less.
+const (
+	minSessionDuration     = 15 * time.Minute
+	maxSessionDuration     = 12 * time.Hour
+	defaultSessionDuration = time.Hour
+)
+
+// parseSessionDuration returns --duration, else BCCE_SESSION_DURATION, else
+// an hour, checked against the STS limits.
+func parseSessionDuration(flagValue time.Duration, envValue string) (time.Duration, error) {
+	d, from := flagValue, "--duration"
+	if d == 0 && envValue != "" {
+		var err error
+		if d, err = time.ParseDuration(envValue); err != nil {
+			return 0, fmt.Errorf("BCCE_SESSION_DURATION must be a duration such as 4h or 45m, not %q", envValue)
+		}
+		from = "BCCE_SESSION_DURATION"
+	}
+	if d == 0 {
+		return defaultSessionDuration, nil
+	}
+	if d < minSessionDuration || d > maxSessionDuration {
+		return 0, fmt.Errorf("%s must be between %s and %s, not %s", from, minSessionDuration, maxSessionDuration, d)
+	}
+	return d.Truncate(time.Second), nil
+}
+
+// apiError is the part of smithy's API errors credproc inspects.
+type apiError interface {
+	ErrorCode() string
+	ErrorMessage() string
+}
+
+var maxSessionSeconds = regexp.MustCompile(`MaxSessionDuration\D{0,40}?(\d+)`)
+
+// exceedsMaxSession reports whether err is STS refusing DurationSeconds
+// above the role's MaxSessionDuration, and the maximum if the message
+// states one.
+func exceedsMaxSession(err error) (max time.Duration, ok bool) {
+	var ae apiError
+	if !errors.As(err, &ae) || ae.ErrorCode() != "ValidationError" || !strings.Contains(ae.ErrorMessage(), "MaxSessionDuration") {
+		return 0, false
+	}
+	if m := maxSessionSeconds.FindStringSubmatch(ae.ErrorMessage()); m != nil {
+		if secs, err := strconv.Atoi(m[1]); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	return 0, true
+}
+
+// assumeRoleAPI is the STS call credproc makes; tests substitute a fake.
+type assumeRoleAPI interface {
+	AssumeRoleWithWebIdentity(ctx context.Context, in *sts.AssumeRoleWithWebIdentityInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleWithWebIdentityOutput, error)
+}
+
+// assumeRole calls AssumeRoleWithWebIdentity for the configured duration.
+// If that exceeds the role's MaxSessionDuration it retries once at the
+// maximum STS states (or an hour) and warns, since the fix is the role's.
+func assumeRole(ctx context.Context, client assumeRoleAPI, cfg *Config) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	duration := cfg.SessionDuration
+	if duration == 0 {
+		duration = defaultSessionDuration
+	}
+	name := cfg.SessionName
+	if name == "" {
+		name = defaultSessionName
+	}
+	hasTags, err := checkTokenSessionTags(cfg.OIDCToken, len(cfg.SessionTags) > 0)
+	if err != nil {
+		return nil, err
+	}
+	input := &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(cfg.RoleArn),
+		RoleSessionName:  aws.String(name),
+		WebIdentityToken: aws.String(cfg.OIDCToken),
+		DurationSeconds:  aws.Int32(int32(duration / time.Second)),
+	}
+	out, err := client.AssumeRoleWithWebIdentity(ctx, input)
+	if max, ok := exceedsMaxSession(err); ok {
+		limit := fmt.Sprintf("allows sessions of at most %s", max)
+		if max == 0 || max >= duration {
+			limit, max = "does not allow sessions that long", defaultSessionDuration
+		}
+		log.Printf("Warning: %s %s, not the %s requested; retrying with %s. Ask an administrator to raise the role's MaxSessionDuration.", cfg.RoleArn, limit, duration, max)
+		input.DurationSeconds = aws.Int32(int32(max / time.Second))
+		duration = max
+		out, err = client.AssumeRoleWithWebIdentity(ctx, input)
+	}
+	if err != nil {
+		tags := ""
+		if hasTags {
+			tags = "the session tags in the ID token"
+		}
+		return nil, explainTagSessionDenied(err, cfg.RoleArn, "sts:AssumeRoleWithWebIdentity", tags)
+	}
+	if out.Credentials != nil && out.Credentials.Expiration != nil {
+		if note := sessionCapNote(duration, time.Now(), *out.Credentials.Expiration, cfg.OIDCToken); note != "" {
+			log.Printf("Warning: %s", note)
+		}
+	}
+	return out, nil
+}
+
+// maxChainedSession is the STS limit on sessions from role chaining.
+const maxChainedSession = time.Hour
+
+// taggedRoleAPI is the chained STS call credproc makes for mapped session
+// tags; tests substitute a fake.
+type taggedRoleAPI interface {
+	AssumeRole(ctx context.Context, in *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+}
+
+// assumeTaggedRole calls sts:AssumeRole into cfg.TaggedRoleArn with cfg's
+// session tags, which AssumeRoleWithWebIdentity cannot take. client must
+// sign with the web identity session's credentials. STS caps chained
+// sessions at an hour, so a longer duration is cut to that, with a warning.
+func assumeTaggedRole(ctx context.Context, client taggedRoleAPI, cfg *Config) (*types.Credentials, error) {
+	duration := cfg.SessionDuration
+	if duration == 0 {
+		duration = defaultSessionDuration
+	}
+	if duration > maxChainedSession {
+		log.Printf("Warning: the session lasts %s rather than the %s requested: session tags from a claim mapping need role chaining, which STS limits to %s", maxChainedSession, duration, maxChainedSession)
+		duration = maxChainedSession
+	}
+	name := cfg.SessionName
+	if name == "" {
+		name = defaultSessionName
+	}
+	keys := make([]string, 0, len(cfg.SessionTags))
+	for key := range cfg.SessionTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(cfg.SessionTags[key])})
+	}
+	out, err := client.AssumeRole(ctx, &sts.AssumeRoleInput{
+		RoleArn:           aws.String(cfg.TaggedRoleArn),
+		RoleSessionName:   aws.String(name),
+		DurationSeconds:   aws.Int32(int32(duration / time.Second)),
+		Tags:              tags,
+		TransitiveTagKeys: cfg.TransitiveTagKeys,
+	})
+	if err != nil {
+		return nil, explainTagSessionDenied(err, cfg.TaggedRoleArn, "sts:AssumeRole", "the session tags mapped from the ID token's claims")
+	}
+	return out.Credentials, nil
+}
+
+// sessionCredentials signs with creds, the web identity session's, for the
+// chained sts:AssumeRole.
+func sessionCredentials(creds *types.Credentials) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     aws.ToString(creds.AccessKeyId),
+			SecretAccessKey: aws.ToString(creds.SecretAccessKey),
+			SessionToken:    aws.ToString(creds.SessionToken),
+			Source:          "AssumeRoleWithWebIdentity",
+			CanExpire:       creds.Expiration != nil,
+			Expires:         aws.ToTime(creds.Expiration),
+		}, nil
+	})
+}
+
+// sessionCapNote explains credentials that expire well before the requested
+// duration: when that is the ID token's expiry, a longer duration cannot
+// help, and the fix is a longer-lived token.
+func sessionCapNote(requested time.Duration, issued, expiration time.Time, token string) string {
+	got := expiration.Sub(issued)
+	if got >= requested-time.Minute {
+		return ""
+	}
+	if claims, err := tokenClaims(token); err == nil {
+		if exp, ok := numericClaim(claims, "exp"); ok && expiration.Sub(exp).Abs() <= time.Minute {
+			return fmt.Sprintf("the session lasts %s rather than %s because the OIDC token expires at %s; sessions cannot outlive the token", got.Round(time.Minute), requested, exp.UTC().Format(time.RFC3339))
+		}
+	}
+	return fmt.Sprintf("the session lasts %s rather than the %s requested", got.Round(time.Minute), requested)
+}
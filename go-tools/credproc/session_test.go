@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+func TestParseSessionDuration(t *testing.T) {
+	tests := []struct {
+		flag time.Duration
+		env  string
+		want time.Duration
+		err  string
+	}{
+		{0, "", time.Hour, ""},
+		{0, "4h", 4 * time.Hour, ""},
+		{0, "45m", 45 * time.Minute, ""},
+		{2 * time.Hour, "4h", 2 * time.Hour, ""},
+		{0, "15m", 15 * time.Minute, ""},
+		{0, "12h", 12 * time.Hour, ""},
+		{0, "10m", 0, "BCCE_SESSION_DURATION must be between"},
+		{13 * time.Hour, "", 0, "--duration must be between"},
+		{0, "3600", 0, "such as 4h"},
+	}
+	for _, tt := range tests {
+		got, err := parseSessionDuration(tt.flag, tt.env)
+		if tt.err == "" && (err != nil || got != tt.want) || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("parseSessionDuration(%s, %q) = %s, %v; want %s, %q", tt.flag, tt.env, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// fakeAPIError is an AWS API error as smithy reports it.
+type fakeAPIError struct{ code, message string }
+
+func (e fakeAPIError) Error() string        { return e.code + ": " + e.message }
+func (e fakeAPIError) ErrorCode() string    { return e.code }
+func (e fakeAPIError) ErrorMessage() string { return e.message }
+
+// fakeSTS grants sessions up to maxDuration, refusing longer ones with the
+// given message, and caps them at the token's expiry tokenExp if set.
+type fakeSTS struct {
+	maxDuration time.Duration
+	message     string
+	tokenExp    time.Time
+	requested   []time.Duration
+}
+
+func (f *fakeSTS) AssumeRoleWithWebIdentity(ctx context.Context, in *sts.AssumeRoleWithWebIdentityInput, _ ...func(*sts.Options)) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	d := time.Duration(aws.ToInt32(in.DurationSeconds)) * time.Second
+	f.requested = append(f.requested, d)
+	if d > f.maxDuration {
+		return nil, fmt.Errorf("operation error STS: AssumeRoleWithWebIdentity, %w", fakeAPIError{"ValidationError", f.message})
+	}
+	expiration := time.Now().Add(d)
+	if !f.tokenExp.IsZero() && f.tokenExp.Before(expiration) {
+		expiration = f.tokenExp
+	}
+	return &sts.AssumeRoleWithWebIdentityOutput{Credentials: &types.Credentials{
+		AccessKeyId: aws.String("ASIAEXAMPLE"), SecretAccessKey: aws.String("secret"), SessionToken: aws.String("session"), Expiration: aws.Time(expiration),
+	}}, nil
+}
+
+func TestAssumeRoleMaxSessionDuration(t *testing.T) {
+	const generic = "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."
+	tests := []struct {
+		name    string
+		sts     *fakeSTS
+		request time.Duration
+		want    []time.Duration
+		err     bool
+	}{
+		{"within the maximum", &fakeSTS{maxDuration: 8 * time.Hour, message: generic}, 4 * time.Hour, []time.Duration{4 * time.Hour}, false},
+		{"maximum unstated", &fakeSTS{maxDuration: time.Hour, message: generic}, 4 * time.Hour, []time.Duration{4 * time.Hour, time.Hour}, false},
+		{"maximum stated", &fakeSTS{maxDuration: 2 * time.Hour, message: "The requested DurationSeconds exceeds the MaxSessionDuration of 7200 seconds set for this role."}, 4 * time.Hour, []time.Duration{4 * time.Hour, 2 * time.Hour}, false},
+		{"retried once only", &fakeSTS{maxDuration: 30 * time.Minute, message: generic}, 4 * time.Hour, []time.Duration{4 * time.Hour, time.Hour}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{RoleArn: "arn:aws:iam::123456789012:role/bedrock", OIDCToken: testToken(`{"sub":"alice"}`), SessionDuration: tt.request}
+			_, err := assumeRole(context.Background(), tt.sts, cfg)
+			if (err != nil) != tt.err {
+				t.Errorf("assumeRole = %v, want error %v", err, tt.err)
+			}
+			if fmt.Sprint(tt.sts.requested) != fmt.Sprint(tt.want) {
+				t.Errorf("requested %v, want %v", tt.sts.requested, tt.want)
+			}
+		})
+	}
+
+	other := &fakeSTS{message: "Request ARN is invalid"}
+	if _, err := assumeRole(context.Background(), other, &Config{RoleArn: "bad", SessionDuration: time.Hour}); err == nil || len(other.requested) != 1 {
+		t.Errorf("assumeRole retried another ValidationError: %v, %v", err, other.requested)
+	}
+}
+
+func TestExceedsMaxSession(t *testing.T) {
+	if _, ok := exceedsMaxSession(errors.New("MaxSessionDuration")); ok {
+		t.Error("a plain error matched")
+	}
+	if _, ok := exceedsMaxSession(fakeAPIError{"AccessDenied", "MaxSessionDuration"}); ok {
+		t.Error("another error code matched")
+	}
+	if max, ok := exceedsMaxSession(fakeAPIError{"ValidationError", "exceeds the MaxSessionDuration of 7200 seconds"}); !ok || max != 2*time.Hour {
+		t.Errorf("exceedsMaxSession = %s, %v; want 2h", max, ok)
+	}
+}
+
+func TestSessionCapNote(t *testing.T) {
+	issued := time.Unix(1_800_000_000, 0)
+	token := testToken(fmt.Sprintf(`{"exp":%d}`, issued.Add(20*time.Minute).Unix()))
+
+	if note := sessionCapNote(time.Hour, issued, issued.Add(time.Hour), token); note != "" {
+		t.Errorf("a full session got a note: %s", note)
+	}
+	if note := sessionCapNote(4*time.Hour, issued, issued.Add(20*time.Minute), token); !strings.Contains(note, "OIDC token expires") {
+		t.Errorf("a session capped by the token: %q", note)
+	}
+	if note := sessionCapNote(4*time.Hour, issued, issued.Add(time.Hour), token); note == "" || strings.Contains(note, "OIDC token") {
+		t.Errorf("a session short for another reason: %q", note)
+	}
+
+	f := &fakeSTS{maxDuration: 8 * time.Hour, tokenExp: time.Now().Add(20 * time.Minute)}
+	if _, err := assumeRole(context.Background(), f, &Config{RoleArn: "arn:aws:iam::123456789012:role/bedrock", OIDCToken: token, SessionDuration: 4 * time.Hour}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// defaultSessionName is used when no claim names the user.
+const defaultSessionName = "bcce-session"
+
+// maxSessionNameLen is the STS limit on RoleSessionName.
+const maxSessionNameLen = 64
+
+// sessionNameClaims are tried in order for the RoleSessionName: claim
+// (BCCE_SESSION_NAME_CLAIM, default email), then sub.
+func sessionNameClaims(claim string) []string {
+	if claim == "" {
+		claim = "email"
+	}
+	if claim == "sub" {
+		return []string{"sub"}
+	}
+	return []string{claim, "sub"}
+}
+
+// resolveSessionName picks the RoleSessionName, so CloudTrail attributes
+// each session to its user: BCCE_SESSION_NAME if set, else the first of
+// the claims the token carries, else bcce-session. It also says where the
+// name came from.
+func resolveSessionName(literal, claim, token string) (name, source string) {
+	if literal != "" {
+		return sanitizeSessionName(literal), "BCCE_SESSION_NAME"
+	}
+	claims, err := tokenClaims(token)
+	if err == nil {
+		for _, c := range sessionNameClaims(claim) {
+			if v, ok := claims[c].(string); ok && v != "" {
+				if name := sanitizeSessionName(v); name != "" {
+					return name, "the " + c + " claim"
+				}
+			}
+		}
+	}
+	return defaultSessionName, "the default (the token has no " + strings.Join(sessionNameClaims(claim), " or ") + " claim)"
+}
+
+// sanitizeSessionName fits s to STS's RoleSessionName rules: 2 to 64 of
+// [A-Za-z0-9_+=,.@-]. "@" and other runes become "-", with runs collapsed,
+// and a name too long keeps its start plus a hash of the whole, so two
+// long names sharing a prefix stay distinct. It returns "" when nothing of
+// s survives.
+func sanitizeSessionName(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_+=,.-", r)
+		if !ok || r == '-' {
+			if !dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = true
+			continue
+		}
+		b.WriteRune(r)
+		dash = false
+	}
+	name := strings.TrimRight(b.String(), "-")
+	switch {
+	case name == "":
+		return ""
+	case len(name) < 2:
+		return "bcce-" + name
+	case len(name) > maxSessionNameLen:
+		sum := sha256.Sum256([]byte(name))
+		hash := hex.EncodeToString(sum[:])[:8]
+		return strings.TrimRight(name[:maxSessionNameLen-len(hash)-1], "-") + "-" + hash
+	}
+	return name
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var stsSessionName = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+func TestSanitizeSessionName(t *testing.T) {
+	long := strings.Repeat("very.long.name", 6) + "@example.com"
+	tests := []struct {
+		in, want string
+	}{
+		{"alice@example.com", "alice-example.com"},
+		{"bob_smith+bedrock@corp.example", "bob_smith+bedrock-corp.example"},
+		{"José Müller", "Jos-M-ller"},
+		{"山田太郎", ""},
+		{"山田 taro", "taro"},
+		{"a", "bcce-a"},
+		{"--a b--", "a-b"},
+		{"x/y\\z:w", "x-y-z-w"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeSessionName(tt.in); got != tt.want {
+			t.Errorf("sanitizeSessionName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	got := sanitizeSessionName(long)
+	if !stsSessionName.MatchString(got) || !strings.HasPrefix(got, "very.long.name") {
+		t.Errorf("sanitizeSessionName of a %d-byte email = %q, want a valid name keeping its start", len(long), got)
+	}
+	if again := sanitizeSessionName(long); again != got {
+		t.Errorf("truncation is not deterministic: %q then %q", got, again)
+	}
+	if other := sanitizeSessionName(strings.Replace(long, "example", "example2", 1)); other == got {
+		t.Errorf("two long names sharing a prefix both became %q", got)
+	}
+}
+
+func TestResolveSessionName(t *testing.T) {
+	token := testToken(`{"sub":"00u1abcd","email":"alice@example.com","upn":"ALICE@CORP"}`)
+	tests := []struct {
+		name, literal, claim, token string
+		want, from                  string
+	}{
+		{"default claim", "", "", token, "alice-example.com", "email"},
+		{"configured claim", "", "upn", token, "ALICE-CORP", "upn"},
+		{"missing claim falls back to sub", "", "preferred_username", token, "00u1abcd", "sub"},
+		{"no email", "", "", testToken(`{"sub":"00u1abcd"}`), "00u1abcd", "sub"},
+		{"unusable email", "", "", testToken(`{"sub":"00u1abcd","email":"山田"}`), "00u1abcd", "sub"},
+		{"no claims", "", "", testToken(`{}`), defaultSessionName, "default"},
+		{"opaque token", "", "", "opaque", defaultSessionName, "default"},
+		{"literal", "ci runner #7", "", token, "ci-runner-7", "BCCE_SESSION_NAME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, from := resolveSessionName(tt.literal, tt.claim, tt.token)
+			if got != tt.want || !strings.Contains(from, tt.from) {
+				t.Errorf("resolveSessionName = %q from %q, want %q from %s", got, from, tt.want, tt.from)
+			}
+			if !stsSessionName.MatchString(got) {
+				t.Errorf("%q is not a valid RoleSessionName", got)
+			}
+		})
+	}
+}
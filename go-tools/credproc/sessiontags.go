@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AssumeRoleWithWebIdentity takes no Tags or TransitiveTagKeys parameters:
+// its session tags come only from this claim of the signed ID token, so they
+// are configured at the IdP. credproc checks them before the call and
+// explains the failure when the role's trust policy does not allow
+// sts:TagSession. Tags from other claims need a claim mapping
+// (BCCE_SESSION_TAGS), which credproc sets on a chained sts:AssumeRole.
+const tokenTagsClaim = "https://aws.amazon.com/tags"
+
+// STS limits on session tags.
+const (
+	maxSessionTags    = 50
+	maxTagKeyLen      = 128
+	maxTagValueLen    = 256
+	maxTransitiveTags = 50
+)
+
+// tagChars matches the characters STS allows in tag keys and values.
+var tagChars = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+const tagCharsetDescribe = "letters, digits, spaces, and _.:/=+-@"
+
+// tokenSessionTags reads the session tags an ID token carries, or nil if
+// it carries none. principal_tags values are single-element arrays (or,
+// leniently, strings). With a claim mapping configured (mapped), a token or
+// tags claim it cannot read is an error rather than no tags, since the
+// chained session's tags are then taken from claims of that same token.
+func tokenSessionTags(token string, mapped bool) (tags map[string]string, transitive []string, err error) {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		if mapped {
+			return nil, nil, err
+		}
+		return nil, nil, nil
+	}
+	raw, ok := claims[tokenTagsClaim]
+	if !ok {
+		return nil, nil, nil
+	}
+	claim, ok := raw.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("the %s claim is not an object", tokenTagsClaim)
+	}
+	principal, ok := claim["principal_tags"].(map[string]any)
+	if !ok && mapped && claim["principal_tags"] != nil {
+		return nil, nil, fmt.Errorf("principal_tags is not an object")
+	}
+	tags = map[string]string{}
+	for key, v := range principal {
+		switch v := v.(type) {
+		case string:
+			tags[key] = v
+		case []any:
+			s, ok := "", len(v) == 1
+			if ok {
+				s, ok = v[0].(string)
+			}
+			if !ok {
+				return nil, nil, fmt.Errorf("session tag %q must have exactly one string value", key)
+			}
+			tags[key] = s
+		default:
+			return nil, nil, fmt.Errorf("session tag %q must have exactly one string value", key)
+		}
+	}
+	keys, ok := claim["transitive_tag_keys"].([]any)
+	if !ok && mapped && claim["transitive_tag_keys"] != nil {
+		return nil, nil, fmt.Errorf("transitive_tag_keys is not a list")
+	}
+	for _, k := range keys {
+		s, ok := k.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("transitive_tag_keys must be strings")
+		}
+		transitive = append(transitive, s)
+	}
+	return tags, transitive, nil
+}
+
+// validateSessionTags checks tags against the STS limits, so a bad tag
+// fails here rather than as an opaque STS error.
+func validateSessionTags(tags map[string]string, transitive []string) error {
+	if len(tags) > maxSessionTags {
+		return fmt.Errorf("%d session tags, more than the %d STS allows", len(tags), maxSessionTags)
+	}
+	seen := map[string]string{}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := tags[key]
+		switch {
+		case key == "" || utf8.RuneCountInString(key) > maxTagKeyLen:
+			return fmt.Errorf("session tag key %q must be 1 to %d characters", key, maxTagKeyLen)
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return fmt.Errorf("session tag key %q uses the reserved aws: prefix", key)
+		case !tagChars.MatchString(key):
+			return fmt.Errorf("session tag key %q may only contain %s", key, tagCharsetDescribe)
+		case utf8.RuneCountInString(value) > maxTagValueLen:
+			return fmt.Errorf("session tag %q has a value longer than %d characters", key, maxTagValueLen)
+		case !tagChars.MatchString(value):
+			return fmt.Errorf("session tag %q has a value with characters other than %s", key, tagCharsetDescribe)
+		}
+		// Keys are case-insensitive to IAM.
+		if other, dup := seen[strings.ToLower(key)]; dup {
+			return fmt.Errorf("session tag keys %q and %q differ only in case", other, key)
+		}
+		seen[strings.ToLower(key)] = key
+	}
+	if len(transitive) > maxTransitiveTags {
+		return fmt.Errorf("%d transitive tag keys, more than the %d STS allows", len(transitive), maxTransitiveTags)
+	}
+	for _, key := range transitive {
+		if _, ok := seen[strings.ToLower(key)]; !ok {
+			return fmt.Errorf("transitive tag key %q is not one of the session tags", key)
+		}
+	}
+	return nil
+}
+
+// checkTokenSessionTags validates the ID token's session tags before
+// AssumeRoleWithWebIdentity, reporting them in verbose mode. mapped is
+// whether a claim mapping is configured. It returns whether the token
+// carries any.
+func checkTokenSessionTags(token string, mapped bool) (bool, error) {
+	tags, transitive, err := tokenSessionTags(token, mapped)
+	if err == nil {
+		err = validateSessionTags(tags, transitive)
+	}
+	if err != nil {
+		return false, fmt.Errorf("the ID token's session tags (%s claim) would be refused by STS: %w; fix the claim at the IdP", tokenTagsClaim, err)
+	}
+	if len(tags) == 0 {
+		return false, nil
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	verbosef("Session tags from the ID token: %s (transitive: %s)", strings.Join(keys, ", "), strings.Join(transitive, ", "))
+	return true, nil
+}
+
+// explainTagSessionDenied translates the AccessDenied STS returns for
+// action when the call sets session tags, described by tags ("" for none),
+// but the role's trust policy does not allow sts:TagSession.
+func explainTagSessionDenied(err error, roleArn, action, tags string) error {
+	var ae apiError
+	if !errors.As(err, &ae) || ae.ErrorCode() != "AccessDenied" {
+		return err
+	}
+	if strings.Contains(ae.ErrorMessage(), "sts:TagSession") {
+		return fmt.Errorf("the trust policy of %s does not allow sts:TagSession, which %s need; add sts:TagSession to the statement allowing %s: %w", roleArn, tags, action, err)
+	}
+	if tags != "" {
+		return fmt.Errorf("%w (with %s, the trust policy of %s must also allow sts:TagSession)", err, tags, roleArn)
+	}
+	return err
+}
+
+// sessionTagMapping takes the value of the session tag key from claim of
+// the ID token, or from its index'th element when index is not -1.
+type sessionTagMapping struct {
+	key   string
+	claim string
+	index int
+}
+
+var claimIndex = regexp.MustCompile(`^(.+)\[(\d+)\]$`)
+
+// newSessionTagMapping parses the claim side of a mapping: a claim name,
+// optionally indexed into a list claim, as in groups[0].
+func newSessionTagMapping(key, claim string) (sessionTagMapping, error) {
+	key, claim = strings.TrimSpace(key), strings.TrimSpace(claim)
+	if key == "" || claim == "" {
+		return sessionTagMapping{}, fmt.Errorf("%q must map a tag key to a claim, as in team:groups[0]", key+":"+claim)
+	}
+	m := sessionTagMapping{key: key, claim: claim, index: -1}
+	if sub := claimIndex.FindStringSubmatch(claim); sub != nil {
+		index, err := strconv.Atoi(sub[2])
+		if err != nil {
+			return sessionTagMapping{}, fmt.Errorf("session tag %q: index %s is out of range", key, sub[2])
+		}
+		m.claim, m.index = sub[1], index
+	}
+	return m, nil
+}
+
+// parseSessionTagMappings parses BCCE_SESSION_TAGS: comma-separated
+// key:claim pairs such as team:groups[0],costcenter:cost_center. Each pair
+// is split at its first colon, so claim names may contain colons
+// (cognito:groups) but tag keys may not.
+func parseSessionTagMappings(value string) ([]sessionTagMapping, error) {
+	var mappings []sessionTagMapping
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, claim, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("%q must map a tag key to a claim, as in team:groups[0]", strings.TrimSpace(pair))
+		}
+		m, err := newSessionTagMapping(key, claim)
+		if err != nil {
+			return nil, err
+		}
+		if seen[m.key] {
+			return nil, fmt.Errorf("session tag %q is mapped twice", m.key)
+		}
+		seen[m.key] = true
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// profileSessionTagMappings reads the session_tags map of a config file
+// profile, in key order.
+func profileSessionTagMappings(tags map[string]string) ([]sessionTagMapping, error) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	mappings := make([]sessionTagMapping, 0, len(keys))
+	for _, key := range keys {
+		m, err := newSessionTagMapping(key, tags[key])
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// resolveSessionTags takes the tag values from the ID token's claims. A
+// missing claim is an error rather than a missing tag, since policies
+// conditioned on the tag would then silently deny.
+func resolveSessionTags(mappings []sessionTagMapping, token string) (map[string]string, error) {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		v, ok := claims[m.claim]
+		if !ok {
+			return nil, fmt.Errorf("session tag %q: the ID token has no %s claim", m.key, m.claim)
+		}
+		if m.index >= 0 {
+			list, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("session tag %q: the %s claim is not a list, so it has no element %d", m.key, m.claim, m.index)
+			}
+			if m.index >= len(list) {
+				return nil, fmt.Errorf("session tag %q: the %s claim has %d elements, so it has no element %d", m.key, m.claim, len(list), m.index)
+			}
+			v = list[m.index]
+		}
+		switch v := v.(type) {
+		case string:
+			tags[m.key] = v
+		case float64:
+			tags[m.key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			tags[m.key] = strconv.FormatBool(v)
+		case []any:
+			return nil, fmt.Errorf("session tag %q: the %s claim is a list; pick one element, as in %s[0]", m.key, m.claim, m.claim)
+		default:
+			return nil, fmt.Errorf("session tag %q: the %s claim is not a string", m.key, m.claim)
+		}
+	}
+	return tags, nil
+}
+
+// configureSessionTags sets cfg's session tags from the claim mapping of
+// BCCE_SESSION_TAGS (with BCCE_TRANSITIVE_TAG_KEYS), else of the profile's
+// session_tags (with transitive_tag_keys), checked against the STS limits.
+// They go on a chained sts:AssumeRole into BCCE_TAGGED_ROLE_ARN, which must
+// be set: chaining into BCCE_ROLE_ARN itself needs a trust policy that lets
+// the role assume itself, which is rarely what was meant.
+func configureSessionTags(cfg *Config, profile profileConfig) error {
+	var (
+		mappings   []sessionTagMapping
+		transitive []string
+		from       string
+		err        error
+	)
+	switch {
+	case os.Getenv("BCCE_SESSION_TAGS") != "":
+		from = "BCCE_SESSION_TAGS"
+		mappings, err = parseSessionTagMappings(os.Getenv("BCCE_SESSION_TAGS"))
+		for _, key := range strings.Split(os.Getenv("BCCE_TRANSITIVE_TAG_KEYS"), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				transitive = append(transitive, key)
+			}
+		}
+	case len(profile.SessionTags) > 0:
+		from = "session_tags of the config file"
+		mappings, err = profileSessionTagMappings(profile.SessionTags)
+		transitive = profile.TransitiveTagKeys
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", from, err)
+	}
+	if cfg.RoleArn == "" {
+		return fmt.Errorf("%s needs BCCE_ROLE_ARN: session tags are set when assuming a role, and Cognito's credentials take none", from)
+	}
+	taggedRoleArn := os.Getenv("BCCE_TAGGED_ROLE_ARN")
+	if taggedRoleArn == "" {
+		return fmt.Errorf("%s needs BCCE_TAGGED_ROLE_ARN, the role the tagged session is chained into from BCCE_ROLE_ARN", from)
+	}
+	tags, err := resolveSessionTags(mappings, cfg.OIDCToken)
+	if err == nil {
+		err = validateSessionTags(tags, transitive)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", from, err)
+	}
+	cfg.SessionTags, cfg.TransitiveTagKeys = tags, transitive
+	cfg.TaggedRoleArn = taggedRoleArn
+	keys := make([]string, 0, len(tags))
+	for _, m := range mappings {
+		keys = append(keys, m.key)
+	}
+	verbosef("Session tags from %s: %s (transitive: %s), set on a chained sts:AssumeRole into %s", from, strings.Join(keys, ", "), strings.Join(transitive, ", "), cfg.TaggedRoleArn)
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+func tagsToken(claim string) string {
+	return testToken(fmt.Sprintf(`{"sub":"alice","https://aws.amazon.com/tags":%s}`, claim))
+}
+
+func TestTokenSessionTags(t *testing.T) {
+	tags, transitive, err := tokenSessionTags(tagsToken(`{"principal_tags":{"team":["ml"],"costcenter":"1234"},"transitive_tag_keys":["team"]}`), false)
+	if err != nil || tags["team"] != "ml" || tags["costcenter"] != "1234" || len(transitive) != 1 || transitive[0] != "team" {
+		t.Errorf("tokenSessionTags = %v, %v, %v", tags, transitive, err)
+	}
+	for _, mapped := range []bool{false, true} {
+		if tags, _, err := tokenSessionTags(testToken(`{"sub":"alice"}`), mapped); tags != nil || err != nil {
+			t.Errorf("tokenSessionTags of a token without tags, mapped %v = %v, %v", mapped, tags, err)
+		}
+		for _, claim := range []string{`"team:ml"`, `{"principal_tags":{"team":["ml","ops"]}}`, `{"principal_tags":{"team":7}}`, `{"principal_tags":{},"transitive_tag_keys":[7]}`} {
+			if _, _, err := tokenSessionTags(tagsToken(claim), mapped); err == nil {
+				t.Errorf("tokenSessionTags, mapped %v, accepted %s", mapped, claim)
+			}
+		}
+	}
+	// Without a mapping, what the token carries is for STS to judge; with
+	// one, a token or claim credproc cannot read is an error.
+	for _, token := range []string{"not-a-jwt", tagsToken(`{"principal_tags":["team"]}`), tagsToken(`{"transitive_tag_keys":"team"}`)} {
+		if _, _, err := tokenSessionTags(token, false); err != nil {
+			t.Errorf("tokenSessionTags(%q) without a mapping = %v, want no error", token, err)
+		}
+		if _, _, err := tokenSessionTags(token, true); err == nil {
+			t.Errorf("tokenSessionTags(%q) with a mapping accepted it", token)
+		}
+	}
+}
+
+func TestValidateSessionTags(t *testing.T) {
+	many := map[string]string{}
+	for i := range maxSessionTags + 1 {
+		many[fmt.Sprintf("tag%d", i)] = "v"
+	}
+	tests := []struct {
+		name       string
+		tags       map[string]string
+		transitive []string
+		err        string
+	}{
+		{"valid", map[string]string{"team": "ml", "cost-center": "R+D 12", "email": "alice@example.com"}, []string{"team"}, ""},
+		{"unicode", map[string]string{"équipe": "données"}, nil, ""},
+		{"too many", many, nil, "more than the 50"},
+		{"long key", map[string]string{strings.Repeat("k", 129): "v"}, nil, "1 to 128"},
+		{"empty key", map[string]string{"": "v"}, nil, "1 to 128"},
+		{"long value", map[string]string{"team": strings.Repeat("v", 257)}, nil, "longer than 256"},
+		{"reserved prefix", map[string]string{"AWS:team": "ml"}, nil, "reserved"},
+		{"bad key chars", map[string]string{"team#1": "ml"}, nil, "may only contain"},
+		{"bad value chars", map[string]string{"team": "ml;ops"}, nil, "characters other than"},
+		{"case duplicates", map[string]string{"Team": "ml", "team": "ops"}, nil, "differ only in case"},
+		{"transitive not a tag", map[string]string{"team": "ml"}, []string{"project"}, "not one of the session tags"},
+	}
+	for _, tt := range tests {
+		err := validateSessionTags(tt.tags, tt.transitive)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: validateSessionTags = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestAssumeRoleSessionTags(t *testing.T) {
+	cfg := &Config{RoleArn: "arn:aws:iam::123456789012:role/bedrock", SessionDuration: time.Hour}
+
+	// Invalid tags fail before any STS call.
+	cfg.OIDCToken = tagsToken(`{"principal_tags":{"aws:team":["ml"]}}`)
+	f := &fakeSTS{maxDuration: time.Hour}
+	if _, err := assumeRole(context.Background(), f, cfg); err == nil || !strings.Contains(err.Error(), "fix the claim at the IdP") || len(f.requested) != 0 {
+		t.Errorf("assumeRole with invalid tags = %v after %d calls, want a local error", err, len(f.requested))
+	}
+
+	denied := &deniedSTS{message: "User: arn:aws:sts::123456789012:assumed-role/x is not authorized to perform: sts:TagSession on resource: arn:aws:iam::123456789012:role/bedrock"}
+	cfg.OIDCToken = tagsToken(`{"principal_tags":{"team":["ml"]}}`)
+	if _, err := assumeRole(context.Background(), denied, cfg); err == nil || !strings.Contains(err.Error(), "does not allow sts:TagSession") {
+		t.Errorf("assumeRole denied sts:TagSession = %v, want the trust policy explained", err)
+	}
+
+	denied.message = "Not authorized to perform sts:AssumeRoleWithWebIdentity"
+	if _, err := assumeRole(context.Background(), denied, cfg); err == nil || !strings.Contains(err.Error(), "must also allow sts:TagSession") {
+		t.Errorf("assumeRole denied with tags = %v, want a hint about sts:TagSession", err)
+	}
+	cfg.OIDCToken = testToken(`{"sub":"alice"}`)
+	if _, err := assumeRole(context.Background(), denied, cfg); err == nil || strings.Contains(err.Error(), "TagSession") {
+		t.Errorf("assumeRole denied without tags = %v, want the plain error", err)
+	}
+}
+
+// deniedSTS refuses every call with AccessDenied.
+type deniedSTS struct {
+	message string
+}
+
+func (d *deniedSTS) AssumeRoleWithWebIdentity(context.Context, *sts.AssumeRoleWithWebIdentityInput, ...func(*sts.Options)) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+	return nil, fmt.Errorf("operation error STS: AssumeRoleWithWebIdentity, %w", fakeAPIError{"AccessDenied", d.message})
+}
+
+func TestExplainTagSessionDenied(t *testing.T) {
+	plain := errors.New("network down")
+	if got := explainTagSessionDenied(plain, "role", "sts:AssumeRole", "tags"); got != plain {
+		t.Errorf("explainTagSessionDenied changed an unrelated error: %v", got)
+	}
+}
+
+func TestParseSessionTagMappings(t *testing.T) {
+	got, err := parseSessionTagMappings(" team:groups[0], costcenter:cost_center,,org:cognito:groups[1]")
+	want := []sessionTagMapping{{"team", "groups", 0}, {"costcenter", "cost_center", -1}, {"org", "cognito:groups", 1}}
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseSessionTagMappings = %v, %v; want %v", got, err, want)
+	}
+	for value, wantErr := range map[string]string{
+		"team":                    "must map a tag key to a claim",
+		"team:":                   "must map a tag key to a claim",
+		":groups":                 "must map a tag key to a claim",
+		"team:groups,team:groups": "mapped twice",
+	} {
+		if _, err := parseSessionTagMappings(value); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseSessionTagMappings(%q) = %v, want %q", value, err, wantErr)
+		}
+	}
+}
+
+func TestResolveSessionTags(t *testing.T) {
+	token := testToken(`{"sub":"alice","groups":["ml","ops"],"cost_center":"1234","level":7,"admin":false,"cognito:groups":"x"}`)
+	tests := []struct {
+		mapping string
+		want    string
+		err     string
+	}{
+		{mapping: "team:groups[0],costcenter:cost_center", want: "map[costcenter:1234 team:ml]"},
+		{mapping: "team:groups[1],level:level,admin:admin", want: "map[admin:false level:7 team:ops]"},
+		{mapping: "team:groups[2]", err: "has 2 elements, so it has no element 2"},
+		{mapping: "team:groups", err: "is a list; pick one element, as in groups[0]"},
+		{mapping: "team:cost_center[0]", err: "is not a list"},
+		{mapping: "team:department", err: "has no department claim"},
+	}
+	for _, tt := range tests {
+		mappings, err := parseSessionTagMappings(tt.mapping)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags, err := resolveSessionTags(mappings, token)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.mapping, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(tags) != tt.want {
+			t.Errorf("%s: resolveSessionTags = %v, %v; want %s", tt.mapping, tags, err, tt.want)
+		}
+	}
+}
+
+func TestConfigureSessionTags(t *testing.T) {
+	const (
+		role   = "arn:aws:iam::123456789012:role/bedrock"
+		tagged = "arn:aws:iam::123456789012:role/tagged"
+	)
+	token := testToken(`{"sub":"alice","groups":["ml"],"cost_center":"R&D"}`)
+	tests := []struct {
+		name    string
+		env     map[string]string
+		profile profileConfig
+		roleArn string
+		want    string // the tags, transitive keys, and tagged role
+		err     string
+	}{
+		{name: "none", roleArn: role, want: "map[] [] "},
+		{name: "environment", env: map[string]string{"BCCE_SESSION_TAGS": "team:groups[0]", "BCCE_TRANSITIVE_TAG_KEYS": "team", "BCCE_TAGGED_ROLE_ARN": tagged}, roleArn: role, want: "map[team:ml] [team] " + tagged},
+		{name: "config file", env: map[string]string{"BCCE_TAGGED_ROLE_ARN": tagged}, profile: profileConfig{SessionTags: map[string]string{"team": "groups[0]"}}, roleArn: role, want: "map[team:ml] [] " + tagged},
+		{name: "environment over config file", env: map[string]string{"BCCE_SESSION_TAGS": "group:groups[0]", "BCCE_TAGGED_ROLE_ARN": tagged}, profile: profileConfig{SessionTags: map[string]string{"team": "groups[0]"}}, roleArn: role, want: "map[group:ml] [] " + tagged},
+		{name: "without a tagged role", env: map[string]string{"BCCE_SESSION_TAGS": "team:groups[0]"}, roleArn: role, err: "BCCE_SESSION_TAGS needs BCCE_TAGGED_ROLE_ARN"},
+		{name: "config file without a tagged role", profile: profileConfig{SessionTags: map[string]string{"team": "groups[0]"}}, roleArn: role, err: "session_tags of the config file needs BCCE_TAGGED_ROLE_ARN"},
+		{name: "without a role", env: map[string]string{"BCCE_SESSION_TAGS": "team:groups[0]", "BCCE_TAGGED_ROLE_ARN": tagged}, err: "BCCE_SESSION_TAGS needs BCCE_ROLE_ARN"},
+		{name: "invalid value", env: map[string]string{"BCCE_SESSION_TAGS": "costcenter:cost_center", "BCCE_TAGGED_ROLE_ARN": tagged}, roleArn: role, err: "BCCE_SESSION_TAGS: session tag \"costcenter\" has a value with characters other than"},
+		{name: "transitive not mapped", env: map[string]string{"BCCE_TAGGED_ROLE_ARN": tagged}, profile: profileConfig{SessionTags: map[string]string{"team": "groups[0]"}, TransitiveTagKeys: []string{"project"}}, roleArn: role, err: "session_tags of the config file: transitive tag key \"project\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"BCCE_SESSION_TAGS", "BCCE_TRANSITIVE_TAG_KEYS", "BCCE_TAGGED_ROLE_ARN"} {
+				t.Setenv(name, tt.env[name])
+			}
+			cfg := &Config{RoleArn: tt.roleArn, OIDCToken: token}
+			err := configureSessionTags(cfg, tt.profile)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if got := fmt.Sprint(cfg.SessionTags, cfg.TransitiveTagKeys, " ", cfg.TaggedRoleArn); err != nil || got != tt.want {
+				t.Errorf("configureSessionTags = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// fakeTaggedSTS records the chained AssumeRole, refusing it with
+// AccessDenied and message when that is set.
+type fakeTaggedSTS struct {
+	message string
+	input   *sts.AssumeRoleInput
+}
+
+func (f *fakeTaggedSTS) AssumeRole(_ context.Context, in *sts.AssumeRoleInput, _ ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	f.input = in
+	if f.message != "" {
+		return nil, fmt.Errorf("operation error STS: AssumeRole, %w", fakeAPIError{"AccessDenied", f.message})
+	}
+	return &sts.AssumeRoleOutput{Credentials: &types.Credentials{AccessKeyId: aws.String("ASIATAGGED")}}, nil
+}
+
+func TestAssumeTaggedRole(t *testing.T) {
+	cfg := &Config{
+		TaggedRoleArn:     "arn:aws:iam::123456789012:role/bedrock",
+		SessionName:       "alice",
+		SessionDuration:   4 * time.Hour,
+		SessionTags:       map[string]string{"team": "ml", "costcenter": "1234"},
+		TransitiveTagKeys: []string{"team"},
+	}
+	f := &fakeTaggedSTS{}
+	creds, err := assumeTaggedRole(context.Background(), f, cfg)
+	if err != nil || aws.ToString(creds.AccessKeyId) != "ASIATAGGED" {
+		t.Fatalf("assumeTaggedRole = %v, %v", creds, err)
+	}
+	var tags []string
+	for _, tag := range f.input.Tags {
+		tags = append(tags, aws.ToString(tag.Key)+"="+aws.ToString(tag.Value))
+	}
+	if got := strings.Join(tags, ","); got != "costcenter=1234,team=ml" {
+		t.Errorf("Tags = %s, want costcenter=1234,team=ml", got)
+	}
+	if fmt.Sprint(f.input.TransitiveTagKeys) != "[team]" || aws.ToString(f.input.RoleSessionName) != "alice" || aws.ToString(f.input.RoleArn) != cfg.TaggedRoleArn {
+		t.Errorf("input = %+v", f.input)
+	}
+	if got := aws.ToInt32(f.input.DurationSeconds); got != 3600 {
+		t.Errorf("DurationSeconds = %d, want the 3600 STS allows chained sessions", got)
+	}
+
+	f.message = "User: arn:aws:sts::123456789012:assumed-role/web/alice is not authorized to perform: sts:TagSession on resource: arn:aws:iam::123456789012:role/bedrock"
+	if _, err := assumeTaggedRole(context.Background(), f, cfg); err == nil || !strings.Contains(err.Error(), "does not allow sts:TagSession") || !strings.Contains(err.Error(), "allowing sts:AssumeRole:") {
+		t.Errorf("assumeTaggedRole denied sts:TagSession = %v, want the trust policy explained", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// oidcTokenSource names where the OIDC token comes from, as a Logins token
+// source: --token-command, --token-file, OIDC_TOKEN_COMMAND,
+// OIDC_ID_TOKEN_FILE, OIDC_ID_TOKEN, then the profile's token_command.
+// It is "" when none is set.
+func oidcTokenSource(opts options, profile profileConfig) string {
+	switch {
+	case opts.tokenCommand != "":
+		return "cmd:" + opts.tokenCommand
+	case opts.tokenFile != "":
+		return "file:" + opts.tokenFile
+	case os.Getenv("OIDC_TOKEN_COMMAND") != "":
+		return "cmd:" + os.Getenv("OIDC_TOKEN_COMMAND")
+	case os.Getenv("OIDC_ID_TOKEN_FILE") != "":
+		return "file:" + os.Getenv("OIDC_ID_TOKEN_FILE")
+	case os.Getenv("OIDC_ID_TOKEN") != "":
+		return "env:OIDC_ID_TOKEN"
+	case profile.TokenCommand != "":
+		return "cmd:" + profile.TokenCommand
+	}
+	return ""
+}
+
+// readTokenFile reads a token file afresh, since projected tokens rotate
+// in place. Unreadable and empty files are reported differently: the first
+// is usually a wrong path, the second a writer caught mid-rotation.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// checkJWTShape reports whether token looks like a compact JWS: three
+// base64url segments, the first two JSON objects. It does not verify it.
+func checkJWTShape(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("not a JWT: %d dot-separated segments, want 3", len(parts))
+	}
+	for i, name := range []string{"header", "payload"} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+			return errors.New("not a JWT: the " + name + " is not base64url JSON")
+		}
+	}
+	if parts[2] == "" {
+		return errors.New("not a JWT: the signature is empty")
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckJWTShape(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string // substring of the error, "" for none
+	}{
+		{testToken(`{"sub":"alice"}`), ""},
+		{"opaque-token", "1 dot-separated segments"},
+		{"a.b.c.d", "4 dot-separated segments"},
+		{"!!.e30.sig", "header"},
+		{"e30.bm90IGpzb24.sig", "payload"},
+		{strings.TrimSuffix(testToken(`{"sub":"alice"}`), "sig"), "signature"},
+	}
+	for _, tt := range tests {
+		err := checkJWTShape(tt.token)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("checkJWTShape(%q) = %v, want %q", tt.token, err, tt.want)
+		}
+	}
+}
+
+func TestReadTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := os.WriteFile(path, []byte("  token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readTokenFile(path); got != "token" || err != nil {
+		t.Errorf("readTokenFile = %q, %v; want the trimmed token", got, err)
+	}
+
+	if _, err := readTokenFile(filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "cannot read") {
+		t.Errorf("readTokenFile of a missing file = %v, want a read error", err)
+	}
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTokenFile(path); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("readTokenFile of an empty file = %v, want an empty-file error", err)
+	}
+}
+
+func TestLoadConfigTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	t.Setenv("BCCE_LOGINS_JSON", "")
+	t.Setenv("BCCE_ROLE_ARN", "")
+	t.Setenv("OIDC_PROVIDER_NAME", "")
+	t.Setenv("OIDC_ID_TOKEN", "")
+	t.Setenv("OIDC_ID_TOKEN_FILE", "")
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	token := func(sub string) string {
+		return testToken(`{"iss":"https://idp.example.com","sub":"` + sub + `"}`)
+	}
+	subject := func(opts options) string {
+		t.Helper()
+		cfg, err := loadConfig(opts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, _ := tokenClaims(cfg.Logins["idp.example.com"])
+		if cfg.OIDCToken != cfg.Logins["idp.example.com"] {
+			t.Errorf("OIDCToken and the Logins entry differ")
+		}
+		return claims["sub"].(string)
+	}
+
+	_, err := loadConfig(options{}, nil)
+	for _, name := range []string{"--token-file", "OIDC_ID_TOKEN_FILE", "OIDC_ID_TOKEN"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("loadConfig with no token = %v, want an error naming %s", err, name)
+		}
+	}
+
+	t.Setenv("OIDC_ID_TOKEN", token("env"))
+	envFile := write("env-token", token("env-file")+"\n")
+	t.Setenv("OIDC_ID_TOKEN_FILE", envFile)
+	flagFile := write("flag-token", token("flag-file"))
+	if got := subject(options{tokenFile: flagFile}); got != "flag-file" {
+		t.Errorf("token from %s, want --token-file first", got)
+	}
+	if got := subject(options{}); got != "env-file" {
+		t.Errorf("token from %s, want OIDC_ID_TOKEN_FILE before OIDC_ID_TOKEN", got)
+	}
+
+	// The file is read on every run, so a rotated token is picked up.
+	write("env-token", token("rotated"))
+	if got := subject(options{}); got != "rotated" {
+		t.Errorf("token from %s after rotation, want the new token", got)
+	}
+
+	for _, tt := range []struct{ content, want string }{
+		{"", "is empty"},
+		{"opaque-token", "not a JWT"},
+	} {
+		write("env-token", tt.content)
+		if _, err := loadConfig(options{}, nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("loadConfig with token file %q = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+	t.Setenv("OIDC_ID_TOKEN_FILE", filepath.Join(dir, "missing"))
+	if _, err := loadConfig(options{}, nil); err == nil || !strings.Contains(err.Error(), "cannot read") {
+		t.Errorf("loadConfig with a missing token file = %v, want a read error rather than a fallback to OIDC_ID_TOKEN", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// defaultTokenCommandTimeout bounds a token command; helpers that open a
+// browser for a fresh login need some time, but must not hang the SDK.
+const defaultTokenCommandTimeout = 20 * time.Second
+
+// tokenCommandTimeout is set from --token-command-timeout.
+var tokenCommandTimeout = defaultTokenCommandTimeout
+
+// maxStderrReport caps how much of a failed command's stderr is repeated.
+const maxStderrReport = 1024
+
+// runTokenCommand runs command through the shell and returns its trimmed
+// stdout as the token. Its stderr is kept for the error on failure; the
+// token itself never appears in errors or logs.
+func runTokenCommand(command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// A killed shell can leave its children holding stdout open.
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("token command timed out after %s%s", timeout, stderrReport(stderr.String()))
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return "", fmt.Errorf("token command exited with status %d%s", exit.ExitCode(), stderrReport(stderr.String()))
+	}
+	if err != nil {
+		return "", fmt.Errorf("token command: %w", err)
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("token command printed nothing on stdout%s", stderrReport(stderr.String()))
+	}
+	return token, nil
+}
+
+// stderrReport formats the tail of a command's stderr for an error.
+func stderrReport(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
+	}
+	if len(stderr) > maxStderrReport {
+		stderr = "..." + stderr[len(stderr)-maxStderrReport:]
+	}
+	return ": " + stderr
+}
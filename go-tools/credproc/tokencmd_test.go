@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTokenHelper is the fake token command: the test binary run again with
+// BCCE_TOKEN_HELPER naming what to do.
+func TestTokenHelper(t *testing.T) {
+	switch os.Getenv("BCCE_TOKEN_HELPER") {
+	case "":
+		return
+	case "success":
+		fmt.Fprintln(os.Stderr, "minting a token")
+		fmt.Println(testToken(`{"iss":"https://idp.example.com","sub":"helper"}`))
+	case "fail":
+		fmt.Fprintln(os.Stderr, "corp-auth: session expired, run corp-auth login")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "garbage":
+		fmt.Println("not-a-jwt")
+	}
+	os.Exit(0)
+}
+
+// helperCommand is a shell command running the fake token command in mode.
+func helperCommand(t *testing.T, mode string) string {
+	t.Setenv("BCCE_TOKEN_HELPER", mode)
+	return fmt.Sprintf("%q -test.run=^TestTokenHelper$", os.Args[0])
+}
+
+func TestRunTokenCommand(t *testing.T) {
+	token, err := runTokenCommand(helperCommand(t, "success"), 10*time.Second)
+	if err != nil || token != testToken(`{"iss":"https://idp.example.com","sub":"helper"}`) {
+		t.Errorf("runTokenCommand = %q, %v; want the helper's token", token, err)
+	}
+
+	_, err = runTokenCommand(helperCommand(t, "fail"), 10*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "status 3") || !strings.Contains(err.Error(), "session expired") {
+		t.Errorf("runTokenCommand of a failing command = %v, want its exit status and stderr", err)
+	}
+
+	start := time.Now()
+	_, err = runTokenCommand(helperCommand(t, "hang"), 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("runTokenCommand of a hanging command = %v, want a timeout", err)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("a 200ms timeout took %s", waited)
+	}
+}
+
+func TestStderrReport(t *testing.T) {
+	if got := stderrReport(" \n"); got != "" {
+		t.Errorf("stderrReport of blank output = %q", got)
+	}
+	long := strings.Repeat("x", 2*maxStderrReport) + "END"
+	if got := stderrReport(long); len(got) > maxStderrReport+10 || !strings.HasSuffix(got, "END") {
+		t.Errorf("stderrReport kept %d bytes, want the last %d", len(got), maxStderrReport)
+	}
+}
+
+func TestLoadConfigTokenCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("COGNITO_IDENTITY_POOL_ID", "us-east-1:pool")
+	t.Setenv("BCCE_LOGINS_JSON", "")
+	t.Setenv("BCCE_ROLE_ARN", "")
+	t.Setenv("OIDC_PROVIDER_NAME", "")
+	t.Setenv("OIDC_ID_TOKEN", testToken(`{"iss":"https://idp.example.com","sub":"env"}`))
+	t.Setenv("OIDC_ID_TOKEN_FILE", "")
+	t.Setenv("OIDC_TOKEN_COMMAND", "")
+
+	command := helperCommand(t, "success")
+	subject := func(opts options) string {
+		t.Helper()
+		cfg, err := loadConfig(opts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, _ := tokenClaims(cfg.Logins["idp.example.com"])
+		return claims["sub"].(string)
+	}
+
+	if got := subject(options{tokenCommand: command}); got != "helper" {
+		t.Errorf("token from %s, want --token-command before OIDC_ID_TOKEN", got)
+	}
+	t.Setenv("OIDC_TOKEN_COMMAND", command)
+	if got := subject(options{}); got != "helper" {
+		t.Errorf("token from %s, want OIDC_TOKEN_COMMAND before OIDC_ID_TOKEN", got)
+	}
+
+	// A profile's token_command applies when the environment has no token.
+	t.Setenv("OIDC_TOKEN_COMMAND", "")
+	t.Setenv("OIDC_ID_TOKEN", "")
+	if err := os.MkdirAll(filepath.Join(home, ".bcce"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf("profiles:\n  corp:\n    token_command: '%s'\n", command)
+	if err := os.WriteFile(filepath.Join(home, ".bcce", "credproc.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := subject(options{profile: "corp"}); got != "helper" {
+		t.Errorf("token from %s, want the profile's token_command", got)
+	}
+
+	garbage := helperCommand(t, "garbage")
+	if _, err := loadConfig(options{tokenCommand: garbage}, nil); err == nil || !strings.Contains(err.Error(), "not a JWT") {
+		t.Errorf("loadConfig with a command printing a non-JWT = %v", err)
+	} else if strings.Contains(err.Error(), "not-a-jwt") {
+		t.Errorf("error reveals the command's output: %v", err)
+	}
+}
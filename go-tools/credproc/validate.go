@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// claimSkew tolerates clocks that differ from the IdP's by this much.
+const claimSkew = time.Minute
+
+// tokenExpectations are the aud and iss the ID token must carry, from
+// OIDC_EXPECTED_AUDIENCE and OIDC_EXPECTED_ISSUER; empty ones are not checked.
+type tokenExpectations struct {
+	audience string
+	issuer   string
+}
+
+// validateIDToken checks the ID token's time window and, when expected,
+// its audience and issuer, so a token AWS would refuse fails here with a
+// specific message instead of an opaque provider error. The signature is
+// left to AWS.
+func validateIDToken(token string, now time.Time, want tokenExpectations) error {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return err
+	}
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return fmt.Errorf("ID token has no numeric exp claim")
+	}
+	if now.After(exp.Add(claimSkew)) {
+		return fmt.Errorf("ID token expired %s ago (exp=%s)", humanDuration(now.Sub(exp)), exp.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(claimSkew).Before(nbf) {
+		return fmt.Errorf("ID token is not valid for another %s (nbf=%s); check this machine's clock", humanDuration(nbf.Sub(now)), nbf.UTC().Format(time.RFC3339))
+	}
+
+	if want.audience != "" {
+		var audiences []string
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []string{aud}
+		case []any:
+			for _, a := range aud {
+				if s, ok := a.(string); ok {
+					audiences = append(audiences, s)
+				}
+			}
+		}
+		found := false
+		for _, a := range audiences {
+			found = found || a == want.audience
+		}
+		if !found {
+			return fmt.Errorf("ID token audience %q does not include %q (OIDC_EXPECTED_AUDIENCE); the token was issued for another client", audiences, want.audience)
+		}
+	}
+	if want.issuer != "" {
+		iss, _ := claims["iss"].(string)
+		if strings.TrimSuffix(iss, "/") != strings.TrimSuffix(want.issuer, "/") {
+			return fmt.Errorf("ID token issuer %q is not %q (OIDC_EXPECTED_ISSUER)", iss, want.issuer)
+		}
+	}
+	return nil
+}
+
+// validateTokens runs validateIDToken on every Logins token, naming the
+// provider of one that fails, and on the OIDC token when it is not among
+// them. The expected audience and issuer apply to each.
+func validateTokens(cfg *Config, now time.Time) error {
+	providers := make([]string, 0, len(cfg.Logins))
+	for provider := range cfg.Logins {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	checked := false
+	for _, provider := range providers {
+		token := cfg.Logins[provider]
+		if err := validateIDToken(token, now, cfg.Expect); err != nil {
+			return fmt.Errorf("login %s: %w", provider, err)
+		}
+		checked = checked || token == cfg.OIDCToken
+	}
+	if cfg.OIDCToken != "" && !checked {
+		return validateIDToken(cfg.OIDCToken, now, cfg.Expect)
+	}
+	return nil
+}
+
+// numericClaim reads a NumericDate claim.
+func numericClaim(claims map[string]any, name string) (time.Time, bool) {
+	v, ok := claims[name].(float64)
+	if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// humanDuration renders d in its largest whole unit, e.g. "42 minutes".
+func humanDuration(d time.Duration) string {
+	unit := func(n int64, name string) string {
+		if n == 1 {
+			return "1 " + name
+		}
+		return fmt.Sprintf("%d %ss", n, name)
+	}
+	switch {
+	case d >= 48*time.Hour:
+		return unit(int64(d/(24*time.Hour)), "day")
+	case d >= 2*time.Hour:
+		return unit(int64(d/time.Hour), "hour")
+	case d >= 2*time.Minute:
+		return unit(int64(d/time.Minute), "minute")
+	}
+	return unit(int64(d/time.Second), "second")
+}
+
+// debugClaims renders the token's claims for --debug-claims.
+func debugClaims(token string) string {
+	claims, err := tokenClaims(token)
+	if err != nil {
+		return err.Error()
+	}
+	out, _ := json.MarshalIndent(claims, "", "  ")
+	return string(out)
+}
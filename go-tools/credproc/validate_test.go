@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateIDToken(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	claims := func(extra string) string {
+		return testToken(fmt.Sprintf(`{"email":"alice@example.com",%s}`, extra))
+	}
+	future, past := now.Add(time.Hour).Unix(), now.Add(-42*time.Minute).Unix()
+	expect := tokenExpectations{audience: "bedrock-client", issuer: "https://idp.example.com/"}
+
+	tests := []struct {
+		name  string
+		token string
+		want  tokenExpectations
+		err   string // substring, "" for valid
+	}{
+		{"valid", claims(fmt.Sprintf(`"exp":%d`, future)), tokenExpectations{}, ""},
+		{"valid with expectations", claims(fmt.Sprintf(`"exp":%d,"aud":"bedrock-client","iss":"https://idp.example.com"`, future)), expect, ""},
+		{"audience list", claims(fmt.Sprintf(`"exp":%d,"aud":["other","bedrock-client"],"iss":"https://idp.example.com/"`, future)), expect, ""},
+		{"expired", claims(fmt.Sprintf(`"exp":%d`, past)), tokenExpectations{}, "ID token expired 42 minutes ago (exp=2027-01-15T07:18:00Z)"},
+		{"expired within skew", claims(fmt.Sprintf(`"exp":%d`, now.Add(-30*time.Second).Unix())), tokenExpectations{}, ""},
+		{"expired days ago", claims(fmt.Sprintf(`"exp":%d`, now.Add(-72*time.Hour).Unix())), tokenExpectations{}, "expired 3 days ago"},
+		{"no exp", claims(`"sub":"alice"`), tokenExpectations{}, "no numeric exp"},
+		{"string exp", claims(`"exp":"soon"`), tokenExpectations{}, "no numeric exp"},
+		{"not yet valid", claims(fmt.Sprintf(`"exp":%d,"nbf":%d`, future, now.Add(10*time.Minute).Unix())), tokenExpectations{}, "not valid for another 10 minutes"},
+		{"nbf within skew", claims(fmt.Sprintf(`"exp":%d,"nbf":%d`, future, now.Add(30*time.Second).Unix())), tokenExpectations{}, ""},
+		{"wrong audience", claims(fmt.Sprintf(`"exp":%d,"aud":"other","iss":"https://idp.example.com"`, future)), expect, "OIDC_EXPECTED_AUDIENCE"},
+		{"no audience", claims(fmt.Sprintf(`"exp":%d,"iss":"https://idp.example.com"`, future)), expect, "OIDC_EXPECTED_AUDIENCE"},
+		{"wrong issuer", claims(fmt.Sprintf(`"exp":%d,"aud":"bedrock-client","iss":"https://evil.example.com"`, future)), expect, "OIDC_EXPECTED_ISSUER"},
+		{"two segments", "header.payload", tokenExpectations{}, "malformed ID token: 2 dot-separated segments"},
+		{"opaque", "opaque-token", tokenExpectations{}, "malformed ID token: 1 dot-separated segments"},
+		{"bad base64", "e30.!!!.sig", tokenExpectations{}, "malformed ID token: the payload is not valid base64url"},
+		{"not JSON", "e30.bm90IGpzb24.sig", tokenExpectations{}, "malformed ID token: the payload is not a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIDToken(tt.token, now, tt.want)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("validateIDToken = %v, want valid", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("validateIDToken = %v, want %q", err, tt.err)
+			case err != nil && strings.Contains(err.Error(), "alice@example.com"):
+				t.Errorf("error reveals unrelated claims: %v", err)
+			}
+		})
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		time.Second:                 "1 second",
+		90 * time.Second:            "90 seconds",
+		42 * time.Minute:            "42 minutes",
+		3*time.Hour + time.Minute:   "3 hours",
+		50 * time.Hour:              "2 days",
+		119*time.Minute + time.Hour: "2 hours",
+	} {
+		if got := humanDuration(d); got != want {
+			t.Errorf("humanDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestExchangeTokenValidatesFirst(t *testing.T) {
+	// The region has no endpoint, so getting past validation would fail
+	// differently.
+	expired := testToken(fmt.Sprintf(`{"sub":"alice","exp":%d}`, time.Now().Add(-time.Hour).Unix()))
+	cfg := &Config{Region: "nowhere-1", IdentityPoolID: "nowhere-1:pool", OIDCToken: expired, Logins: map[string]string{"idp.example.com": expired}}
+	_, err := exchangeToken(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "ID token expired 60 minutes ago") {
+		t.Errorf("exchangeToken with an expired token = %v, want the local validation error", err)
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	now := time.Now()
+	valid := testToken(fmt.Sprintf(`{"sub":"alice","iss":"https://idp.example.com","aud":"credproc","exp":%d}`, now.Add(time.Hour).Unix()))
+	expired := testToken(fmt.Sprintf(`{"sub":"alice","iss":"https://idp.example.com","aud":"credproc","exp":%d}`, now.Add(-time.Hour).Unix()))
+	early := testToken(fmt.Sprintf(`{"sub":"alice","iss":"https://idp.example.com","aud":"credproc","exp":%d,"nbf":%d}`, now.Add(2*time.Hour).Unix(), now.Add(time.Hour).Unix()))
+	otherAudience := testToken(fmt.Sprintf(`{"sub":"alice","iss":"https://idp.example.com","aud":"another-app","exp":%d}`, now.Add(time.Hour).Unix()))
+	otherIssuer := testToken(fmt.Sprintf(`{"sub":"alice","iss":"https://evil.example.com","aud":"credproc","exp":%d}`, now.Add(time.Hour).Unix()))
+	expect := tokenExpectations{audience: "credproc", issuer: "https://idp.example.com"}
+	tests := []struct {
+		name   string
+		token  string
+		logins map[string]string
+		err    string
+	}{
+		{name: "all valid", token: valid, logins: map[string]string{"idp.example.com": valid, "partner.example.com": valid}},
+		{name: "expired second login", token: valid, logins: map[string]string{"idp.example.com": valid, "partner.example.com": expired}, err: "login partner.example.com: ID token expired 60 minutes ago"},
+		{name: "login not yet valid", logins: map[string]string{"partner.example.com": early}, err: "login partner.example.com: ID token is not valid for another"},
+		{name: "login for another client", logins: map[string]string{"partner.example.com": otherAudience}, err: "login partner.example.com: ID token audience"},
+		{name: "login from another issuer", logins: map[string]string{"partner.example.com": otherIssuer}, err: "login partner.example.com: ID token issuer"},
+		{name: "malformed login", logins: map[string]string{"partner.example.com": "opaque"}, err: "login partner.example.com: "},
+		{name: "OIDC token outside the logins", token: expired, logins: map[string]string{"idp.example.com": valid}, err: "ID token expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTokens(&Config{OIDCToken: tt.token, Logins: tt.logins, Expect: expect}, now)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("validateTokens = %v, want valid", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("validateTokens = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDebugClaims(t *testing.T) {
+	if got := debugClaims(testToken(`{"sub":"alice"}`)); !strings.Contains(got, `"sub": "alice"`) {
+		t.Errorf("debugClaims = %q", got)
+	}
+	if got := debugClaims("opaque"); !strings.Contains(got, "malformed") {
+		t.Errorf("debugClaims of a malformed token = %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// bundleFile is one entry of the support bundle.
+type bundleFile struct {
+	Name        string
+	Description string
+	Data        []byte
+}
+
+// Environment variables copied into the bundle, by prefix or exact name.
+var bundleEnvPrefixes = []string{"AWS_", "ANTHROPIC_", "CLAUDE_CODE_", "OTEL_", "BCCE_"}
+
+var bundleEnvNames = map[string]bool{
+	"HTTP_PROXY": true, "HTTPS_PROXY": true, "NO_PROXY": true, "ALL_PROXY": true,
+	"NODE_OPTIONS": true, "NODE_EXTRA_CA_CERTS": true, "SSL_CERT_FILE": true,
+}
+
+// secretEnv reports whether the value of the environment variable name is a
+// credential.
+func secretEnv(name string) bool {
+	if probes.SecretSettingsEnv[name] {
+		return true
+	}
+	for _, suffix := range []string{"_TOKEN", "_SECRET", "_PASSWORD", "_KEY", "_KEY_ID"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return strings.Contains(name, "SECRET")
+}
+
+// sanitizedEnv returns the relevant variables of environ as sorted
+// NAME=value lines with secrets removed.
+func sanitizedEnv(environ []string) []string {
+	var lines []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		upper := strings.ToUpper(name)
+		relevant := bundleEnvNames[upper]
+		for _, prefix := range bundleEnvPrefixes {
+			relevant = relevant || strings.HasPrefix(upper, prefix)
+		}
+		if !relevant {
+			continue
+		}
+		if secretEnv(upper) && value != "" {
+			value = probes.Redacted
+		}
+		lines = append(lines, name+"="+probes.RedactSecrets(value))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// sanitizedAWSConfig redacts keys and credential_process arguments in the
+// text of an AWS config file.
+func sanitizedAWSConfig(data []byte) []byte {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "credential_process" {
+			line = key + "= " + probes.RedactCommand(value)
+		}
+		out.WriteString(probes.RedactSecrets(line) + "\n")
+	}
+	return out.Bytes()
+}
+
+// sanitizedSettings redacts secret env entries in a Claude Code settings
+// file, falling back to text redaction when it is not valid JSON.
+func sanitizedSettings(data []byte) []byte {
+	var settings map[string]any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return []byte(probes.RedactSecrets(string(data)))
+	}
+	if env, ok := settings["env"].(map[string]any); ok {
+		for k, v := range env {
+			if s, ok := v.(string); ok && secretEnv(strings.ToUpper(k)) && s != "" {
+				env[k] = probes.Redacted
+			}
+		}
+	}
+	if helper, ok := settings["apiKeyHelper"].(string); ok {
+		settings["apiKeyHelper"] = probes.RedactCommand(helper)
+	}
+	out, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return []byte(probes.RedactSecrets(string(data)))
+	}
+	return []byte(probes.RedactSecrets(string(out)) + "\n")
+}
+
+// bundleFiles collects the bundle contents. Files that do not exist are
+// left out.
+func bundleFiles(rep output.Report, probeLog []byte) ([]bundleFile, error) {
+	reportJSON, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := []bundleFile{
+		{"report.json", "doctor-probes JSON report (verbose)", []byte(probes.RedactSecrets(string(reportJSON)) + "\n")},
+		{"probe-log.jsonl", "structured log of every probe attempt", []byte(probes.RedactSecrets(string(probeLog)))},
+	}
+
+	env := sanitizedEnv(os.Environ())
+	names := make([]string, len(env))
+	for i, line := range env {
+		names[i], _, _ = strings.Cut(line, "=")
+	}
+	files = append(files, bundleFile{"environment.txt", "environment variables: " + strings.Join(names, ", "), []byte(strings.Join(env, "\n") + "\n")})
+
+	system, err := json.MarshalIndent(map[string]any{
+		"os":           runtime.GOOS,
+		"arch":         runtime.GOARCH,
+		"go_version":   runtime.Version(),
+		"tool_version": version,
+		"environment":  rep.Environment,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, bundleFile{"system.json", "OS, architecture, and detected environment", append(system, '\n')})
+
+	awsConfigPath := os.Getenv("AWS_CONFIG_FILE")
+	if awsConfigPath == "" {
+		awsConfigPath = config.DefaultSharedConfigFilename()
+	}
+	if data, err := os.ReadFile(awsConfigPath); err == nil {
+		files = append(files, bundleFile{"aws-config.txt", awsConfigPath + " with keys and credential_process arguments redacted", sanitizedAWSConfig(data)})
+	}
+
+	for _, f := range probes.SettingsFiles() {
+		if data, err := os.ReadFile(f.Path); err == nil {
+			files = append(files, bundleFile{"claude-settings-" + f.Scope + ".json", f.Path + " with secret env values redacted", sanitizedSettings(data)})
+		}
+	}
+	return files, nil
+}
+
+// writeBundle writes the support bundle to path as a gzipped tar and returns
+// what it contains.
+func writeBundle(path string, rep output.Report, probeLog []byte) ([]bundleFile, error) {
+	files, err := bundleFiles(rep, probeLog)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{Name: "bcce-doctor/" + f.Name, Mode: 0o600, Size: int64(len(f.Data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			out.Close()
+			return nil, err
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	for _, c := range []io.Closer{tw, gz, out} {
+		if err := c.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func printBundleSummary(w io.Writer, path string, files []bundleFile) {
+	fmt.Fprintf(w, "Support bundle written to %s. It contains:\n", path)
+	for _, f := range files {
+		fmt.Fprintf(w, "  %-32s %7d bytes  %s\n", f.Name, len(f.Data), f.Description)
+	}
+	fmt.Fprintf(w, "Access keys, session and bearer tokens, OIDC tokens, and proxy passwords were replaced with %s. Review the archive (tar -tzvf %s) before sending it.\n", probes.Redacted, path)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// checkDependencies lists, per check ID, the checks it needs. With
+// --fail-fast a check is skipped when any prerequisite failed or was itself
+// skipped; without it the graph is ignored. New checks declare their
+// prerequisites here.
+var checkDependencies = map[string][]string{
+	"dns.bedrock_runtime":        {"region"},
+	"dns.bedrock_control":        {"region"},
+	"dns.sts":                    {"region"},
+	"auth.sts_endpoint":          {"region"},
+	"region.consistency":         {"region"},
+	"ip_family.bedrock_runtime":  {"dns.bedrock_runtime"},
+	"ip_family.bedrock_control":  {"dns.bedrock_control"},
+	"ip_family.sts":              {"dns.sts"},
+	"https.bedrock_runtime":      {"dns.bedrock_runtime"},
+	"payload.bedrock_runtime":    {"https.bedrock_runtime"},
+	"network.idle_connection":    {"https.bedrock_runtime"},
+	"network.stream_buffering":   {"https.bedrock_runtime"},
+	"network.concurrency":        {"https.bedrock_runtime"},
+	"network.connection_reuse":   {"https.bedrock_runtime"},
+	"network.proxy_overhead":     {"dns.bedrock_runtime"},
+	"network.ip_ranges":          {"dns.bedrock_runtime"},
+	"tls.alpn":                   {"https.bedrock_runtime"},
+	"tls.version_policy":         {"dns.bedrock_runtime"},
+	"tls.revocation":             {"dns.bedrock_runtime"},
+	"tls.inspection":             {"dns.bedrock_runtime"},
+	"tls.certificate":            {"dns.bedrock_runtime"},
+	"tls.os_trust":               {"dns.bedrock_runtime"},
+	"network.middlebox":          {"https.bedrock_runtime"},
+	"network.egress_route":       {"dns.bedrock_runtime"},
+	"bedrock.unsigned_request":   {"dns.bedrock_control"},
+	"bedrock.signed_request":     {"bedrock.unsigned_request"},
+	"bedrock.api_access":         {"https.bedrock_runtime", "dns.bedrock_control", "dns.sts"},
+	"bedrock.api_key":            {"https.bedrock_runtime"},
+	"auth_matrix.summary":        {"bedrock.unsigned_request"},
+	"auth.session_duration":      {"dns.sts"},
+	"bedrock.model_region":       {"bedrock.api_access"},
+	"bedrock.model_lifecycle":    {"bedrock.api_access"},
+	"bedrock.model_id_shape":     {"bedrock.api_access"},
+	"bedrock.cross_account":      {"bedrock.api_access"},
+	"small_model.access":         {"bedrock.api_access"},
+	"bedrock.profile_regions":    {"bedrock.api_access"},
+	"bedrock.iam_simulation":     {"bedrock.api_access"},
+	"bedrock.invocation_logging": {"bedrock.api_access"},
+	"bedrock.service_quotas":     {"bedrock.api_access"},
+	"bedrock.usage":              {"bedrock.api_access"},
+	"bedrock.throttling":         {"bedrock.api_access"},
+	"bedrock.count_tokens":       {"bedrock.api_access"},
+	"bedrock.benchmark":          {"bedrock.api_access"},
+}
+
+// validateDependencies rejects a dependency graph with a cycle, naming it.
+func validateDependencies(deps map[string][]string) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		path = append(path, id)
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("check dependency cycle: %s", strings.Join(path, " -> "))
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		for _, dep := range deps[id] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[id] = done
+		return nil
+	}
+
+	ids := make([]string, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := visit(id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockedBy returns the failed check that blocks id under --fail-fast, or ""
+// when id may run.
+func (r *recorder) blockedBy(id string) string {
+	if !r.failFast {
+		return ""
+	}
+	for _, dep := range checkDependencies[id] {
+		if cause, ok := r.failed[dep]; ok {
+			return cause
+		}
+	}
+	return ""
+}
+
+// skip records id as skipped because the check cause failed.
+func (r *recorder) skip(id, name, cause string) {
+	r.failed[id] = cause
+	r.add(probes.CheckResult{
+		ID:      id,
+		Name:    name,
+		Status:  "skip",
+		Message: fmt.Sprintf("skipped (dependency failed: %s)", cause),
+	})
+}
+
+// run adds the results of check, or a skipped result when a prerequisite of
+// id failed or, for an optional check, the request budget is used up. An
+// optional check the budget cuts short is reported as skipped too.
+func (r *recorder) run(id, name string, check func() []probes.CheckResult) {
+	if cause := r.blockedBy(id); cause != "" {
+		r.skip(id, name, cause)
+		return
+	}
+	if !probes.EssentialChecks[id] && r.env.Engine.Exhausted() {
+		r.add(r.budgetSkip(id, name))
+		return
+	}
+	start := time.Now()
+	results, cutShort := r.env.Engine.RunCheck(id, check)
+	for _, res := range results {
+		rerunnable[res.ID] = check
+	}
+	if cutShort {
+		for i := range results {
+			results[i] = r.budgetSkip(results[i].ID, results[i].Name)
+		}
+	}
+	r.add(stamp(results, start)...)
+}
+
+// budgetSkip is the result of an optional check the request budget stopped.
+func (r *recorder) budgetSkip(id, name string) probes.CheckResult {
+	return probes.CheckResult{
+		ID:      id,
+		Name:    name,
+		Status:  "skip",
+		Message: fmt.Sprintf("skipped (request budget of %d exhausted; raise --max-requests)", r.env.Engine.Budget()),
+	}
+}
+
+// inherit makes failures among results block checks recorded by r, so
+// per-profile runs respect the shared network checks.
+func (r *recorder) inherit(results []probes.CheckResult) {
+	for _, res := range results {
+		if res.Status == "fail" || res.Status == "skip" {
+			r.failed[res.ID] = res.ID
+		}
+	}
+}
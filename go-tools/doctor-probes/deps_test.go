@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		deps map[string][]string
+		err  string
+	}{
+		{name: "built-in graph", deps: checkDependencies},
+		{name: "diamond", deps: map[string][]string{"d": {"b", "c"}, "b": {"a"}, "c": {"a"}}},
+		{name: "self", deps: map[string][]string{"a": {"a"}}, err: "check dependency cycle: a -> a"},
+		{name: "three checks", deps: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, err: "check dependency cycle: a -> b -> c -> a"},
+	}
+	for _, tt := range tests {
+		err := validateDependencies(tt.deps)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: validateDependencies = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRecorderRun(t *testing.T) {
+	type step struct {
+		id     string
+		status string // what the check returns when it runs
+	}
+	tests := []struct {
+		name     string
+		failFast bool
+		inherit  []probes.CheckResult
+		steps    []step
+		want     []string // status per step
+		messages map[int]string
+	}{
+		{
+			name:  "without --fail-fast every check runs",
+			steps: []step{{"dns.bedrock_runtime", "fail"}, {"https.bedrock_runtime", "fail"}, {"payload.bedrock_runtime", "pass"}},
+			want:  []string{"fail", "fail", "pass"},
+		},
+		{
+			name:     "skips carry the root failure",
+			failFast: true,
+			steps:    []step{{"dns.bedrock_runtime", "fail"}, {"https.bedrock_runtime", "pass"}, {"payload.bedrock_runtime", "pass"}, {"dns.sts", "pass"}},
+			want:     []string{"fail", "skip", "skip", "pass"},
+			messages: map[int]string{2: "skipped (dependency failed: dns.bedrock_runtime)"},
+		},
+		{
+			name:     "warnings do not block",
+			failFast: true,
+			steps:    []step{{"https.bedrock_runtime", "warn"}, {"payload.bedrock_runtime", "pass"}},
+			want:     []string{"warn", "pass"},
+		},
+		{
+			name:     "inherited shared failures block profile checks",
+			failFast: true,
+			inherit:  []probes.CheckResult{{ID: "dns.sts", Status: "fail"}},
+			steps:    []step{{"bedrock.api_access", "pass"}, {"bedrock.service_quotas", "pass"}},
+			want:     []string{"skip", "skip"},
+			messages: map[int]string{1: "skipped (dependency failed: dns.sts)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newRecorder(options{env: probes.NewEnv(probes.RunSettings{})})
+			rec.failFast = tt.failFast
+			rec.inherit(tt.inherit)
+			for _, s := range tt.steps {
+				rec.run(s.id, s.id, func() []probes.CheckResult {
+					return []probes.CheckResult{{ID: s.id, Status: s.status}}
+				})
+			}
+			var got []string
+			for _, r := range rec.results {
+				got = append(got, r.Status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+			for i, msg := range tt.messages {
+				if rec.results[i].Message != msg {
+					t.Errorf("result %d message = %q, want %q", i, rec.results[i].Message, msg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// exitPolicy maps the overall run outcome to a process exit code.
+type exitPolicy struct {
+	Name string
+	Fail int
+	Warn int
+	Pass int
+}
+
+var (
+	defaultExitPolicy      = exitPolicy{Name: "default", Fail: 1, Warn: 2, Pass: 0}
+	strictExitPolicy       = exitPolicy{Name: "strict", Fail: 1, Warn: 1, Pass: 0}
+	warnExitZeroExitPolicy = exitPolicy{Name: "warn-exit-zero", Fail: 1, Warn: 0, Pass: 0}
+)
+
+// exitRenderError is the exit code when the report could not be written.
+// It is outside every policy's codes, so wrappers can tell a broken report
+// from a failing check.
+const exitRenderError = 3
+
+func selectExitPolicy(strict, warnExitZero bool) (exitPolicy, error) {
+	switch {
+	case strict && warnExitZero:
+		return exitPolicy{}, fmt.Errorf("--strict and --warn-exit-zero are mutually exclusive")
+	case strict:
+		return strictExitPolicy, nil
+	case warnExitZero:
+		return warnExitZeroExitPolicy, nil
+	default:
+		return defaultExitPolicy, nil
+	}
+}
+
+func (p exitPolicy) code(results []probes.CheckResult) int {
+	hasFailures, hasWarnings := output.Summarize(results)
+	switch {
+	case hasFailures:
+		return p.Fail
+	case hasWarnings:
+		return p.Warn
+	default:
+		return p.Pass
+	}
+}
+
+func (p exitPolicy) String() string {
+	return fmt.Sprintf("exit policy: %s", p.Name)
+}
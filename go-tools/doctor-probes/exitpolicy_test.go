@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+func TestSelectExitPolicy(t *testing.T) {
+	tests := []struct {
+		strict, warnExitZero bool
+		want                 string
+		err                  bool
+	}{
+		{want: "default"},
+		{strict: true, want: "strict"},
+		{warnExitZero: true, want: "warn-exit-zero"},
+		{strict: true, warnExitZero: true, err: true},
+	}
+	for _, tt := range tests {
+		got, err := selectExitPolicy(tt.strict, tt.warnExitZero)
+		if (err != nil) != tt.err || got.Name != tt.want {
+			t.Errorf("selectExitPolicy(%v, %v) = %q, %v; want %q, error %v", tt.strict, tt.warnExitZero, got.Name, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestExitPolicyCode(t *testing.T) {
+	pass := probes.CheckResult{Status: "pass"}
+	warn := probes.CheckResult{Status: "warn"}
+	fail := probes.CheckResult{Status: "fail"}
+	skip := probes.CheckResult{Status: "skip"}
+	suppressedFail := probes.CheckResult{Status: "fail", Suppressed: true}
+
+	tests := []struct {
+		name    string
+		results []probes.CheckResult
+		want    map[string]int // per policy name
+	}{
+		{"all pass", []probes.CheckResult{pass, skip}, map[string]int{"default": 0, "strict": 0, "warn-exit-zero": 0}},
+		{"warning", []probes.CheckResult{pass, warn}, map[string]int{"default": 2, "strict": 1, "warn-exit-zero": 0}},
+		{"failure", []probes.CheckResult{warn, fail}, map[string]int{"default": 1, "strict": 1, "warn-exit-zero": 1}},
+		{"suppressed failure", []probes.CheckResult{pass, suppressedFail}, map[string]int{"default": 0, "strict": 0, "warn-exit-zero": 0}},
+	}
+	for _, tt := range tests {
+		for _, p := range []exitPolicy{defaultExitPolicy, strictExitPolicy, warnExitZeroExitPolicy} {
+			if got := p.code(tt.results); got != tt.want[p.Name] {
+				t.Errorf("%s under %s: code = %d, want %d", tt.name, p.Name, got, tt.want[p.Name])
+			}
+		}
+	}
+	if got := strictExitPolicy.String(); got != "exit policy: strict" {
+		t.Errorf("String = %q, want exit policy: strict", got)
+	}
+	if exitRenderError == defaultExitPolicy.Fail || exitRenderError == defaultExitPolicy.Warn {
+		t.Errorf("exitRenderError %d collides with a policy code", exitRenderError)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// historyKeep is the number of runs kept per region and profile.
+const historyKeep = 100
+
+// historyRecord is one region/profile report to persist and compare with the
+// previous run in the same namespace.
+type historyRecord struct {
+	Namespace string
+	Profile   string
+	Report    output.Report
+	Previous  *output.Report
+}
+
+var unsafeNamespace = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// historyNamespace names the directory that holds the runs for a region and
+// profile, so that only like runs are compared.
+func historyNamespace(region, profile string) string {
+	if region == "" {
+		region = "no-region"
+	}
+	return unsafeNamespace.ReplaceAllString(region, "_") + "@" + unsafeNamespace.ReplaceAllString(profile, "_")
+}
+
+func historyDir(namespace string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".bcce", "doctor-history", namespace), nil
+}
+
+// historyRecords splits rep into one record per profile: with --profiles
+// each profile's results are stored with the shared network results.
+func historyRecords(rep output.Report) []historyRecord {
+	if len(rep.Profiles) == 0 {
+		profile := probes.ActiveProfile()
+		return []historyRecord{{Namespace: historyNamespace(rep.Region, profile), Profile: profile, Report: rep}}
+	}
+	records := make([]historyRecord, 0, len(rep.Profiles))
+	for _, run := range rep.Profiles {
+		r := rep
+		r.Profiles = nil
+		r.Results = append(append([]probes.CheckResult(nil), rep.Results...), run.Results...)
+		records = append(records, historyRecord{Namespace: historyNamespace(rep.Region, run.Profile), Profile: run.Profile, Report: r})
+	}
+	return records
+}
+
+// loadHistory returns the stored runs of namespace, newest first. Files that
+// cannot be read, are not reports, or come from another major schema
+// version are skipped.
+func loadHistory(namespace string) ([]output.Report, error) {
+	dir, err := historyDir(namespace)
+	if err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	major, _, _ := strings.Cut(schemaVersion, ".")
+	var reports []output.Report
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		var r output.Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			continue
+		}
+		if m, _, _ := strings.Cut(r.SchemaVersion, "."); m != major || r.Timestamp.IsZero() {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// saveHistory stores rep in namespace and prunes all but the newest
+// historyKeep runs.
+func saveHistory(namespace string, rep output.Report) error {
+	dir, err := historyDir(namespace)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, rep.Timestamp.UTC().Format("20060102T150405.000Z")+".json")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return err
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for len(names) > historyKeep {
+		os.Remove(names[0])
+		names = names[1:]
+	}
+	return nil
+}
+
+// recordHistory stores every record and fills in its previous run.
+// Failures are reported on stderr and never affect the run.
+func recordHistory(records []historyRecord) {
+	for i := range records {
+		previous, err := loadHistory(records[i].Namespace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "history: %v\n", err)
+		}
+		if len(previous) > 0 {
+			records[i].Previous = &previous[0]
+		}
+		if err := saveHistory(records[i].Namespace, records[i].Report); err != nil {
+			fmt.Fprintf(os.Stderr, "history: %v\n", err)
+		}
+	}
+}
+
+// resultKey identifies a check across runs: its stable ID, or its name for
+// the few results without one.
+func resultKey(r probes.CheckResult) string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Name
+}
+
+func statusSummary(results []probes.CheckResult) string {
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	return fmt.Sprintf("%d pass, %d warn, %d fail, %d skipped", counts["pass"], counts["warn"], counts["fail"], counts["skip"])
+}
+
+// printDiff prints the checks whose status changed since the previous run
+// of the same region and profile, plus checks that appeared or went away.
+func printDiff(w io.Writer, rec historyRecord, style output.Style) {
+	fmt.Fprintf(w, "%s (region %s, profile %s)\n", style.Title("BCCE Doctor Probes Diff"), rec.Report.Region, rec.Profile)
+	if rec.Previous == nil {
+		fmt.Fprintln(w, "No previous run to compare with; this run is now the baseline.")
+		fmt.Fprintln(w)
+		return
+	}
+	prev := rec.Previous
+	fmt.Fprintf(w, "%s %s %s\n", prev.Timestamp.Local().Format(time.DateTime), style.Arrow(), rec.Report.Timestamp.Local().Format(time.DateTime))
+
+	before := map[string]probes.CheckResult{}
+	for _, r := range prev.Results {
+		before[resultKey(r)] = r
+	}
+	changed := 0
+	seen := map[string]bool{}
+	for _, r := range rec.Report.Results {
+		key := resultKey(r)
+		seen[key] = true
+		old, ok := before[key]
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "%s %s: new check, %s\n", style.Mark(r.Status), r.Name, r.Status)
+		case old.Status != r.Status:
+			fmt.Fprintf(w, "%s %s: %s %s %s\n", style.Mark(r.Status), r.Name, old.Status, style.Arrow(), r.Status)
+		default:
+			continue
+		}
+		changed++
+		if r.Status != "pass" {
+			fmt.Fprintf(w, "   %s\n", r.Message)
+			if r.Fix != "" {
+				fmt.Fprintf(w, "   Fix: %s\n", r.Fix)
+			}
+		}
+	}
+	for _, r := range prev.Results {
+		if !seen[resultKey(r)] {
+			fmt.Fprintf(w, "%s %s: no longer reported (was %s)\n", style.Removed(), r.Name, r.Status)
+			changed++
+		}
+	}
+	if changed == 0 {
+		fmt.Fprintln(w, "No status changes since the previous run.")
+	}
+	fmt.Fprintln(w)
+}
+
+// runHistory lists the last n runs of the current region and profile and
+// returns the exit code.
+func runHistory(n int, regionFlag string) int {
+	profile := probes.ActiveProfile()
+	region := probes.CurrentRegion(regionFlag)
+	reports, err := loadHistory(historyNamespace(region, profile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--history: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Last runs for region %s, profile %s:\n", region, profile)
+	if len(reports) == 0 {
+		fmt.Println("  (none)")
+		return 0
+	}
+	if len(reports) > n {
+		reports = reports[:n]
+	}
+	for _, r := range reports {
+		fmt.Printf("%s  %s  %s\n", r.Timestamp.Local().Format(time.DateTime), r.ToolVersion, statusSummary(r.Results))
+		for _, c := range r.Results {
+			if c.Status == "fail" {
+				fmt.Printf("    %s %s\n", consoleStyle.Mark("fail"), c.Name)
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func historyReport(region string, at time.Time, results ...probes.CheckResult) output.Report {
+	return output.Report{SchemaVersion: schemaVersion, ToolVersion: "test", Region: region, Timestamp: at.UTC(), Results: results}
+}
+
+func TestHistoryNamespace(t *testing.T) {
+	tests := []struct {
+		region, profile, want string
+	}{
+		{"us-east-1", "default", "us-east-1@default"},
+		{"", "dev", "no-region@dev"},
+		{"eu-west-1", "team/admin role", "eu-west-1@team_admin_role"},
+		{"../..", "x", ".._..@x"},
+	}
+	for _, tt := range tests {
+		got := historyNamespace(tt.region, tt.profile)
+		if got != tt.want {
+			t.Errorf("historyNamespace(%q, %q) = %q, want %q", tt.region, tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryRecords(t *testing.T) {
+	shared := []probes.CheckResult{{ID: "dns.sts", Status: "pass"}}
+	t.Run("active profile", func(t *testing.T) {
+		probestest.Unsetenv(t, "AWS_DEFAULT_PROFILE")
+		t.Setenv("AWS_PROFILE", "dev")
+		records := historyRecords(historyReport("us-east-1", time.Now(), shared...))
+		if len(records) != 1 || records[0].Namespace != "us-east-1@dev" || records[0].Profile != "dev" {
+			t.Errorf("records = %+v, want one for us-east-1@dev", records)
+		}
+	})
+	t.Run("one per --profiles entry", func(t *testing.T) {
+		rep := historyReport("us-east-1", time.Now(), shared...)
+		rep.Profiles = []output.ProfileRun{
+			{Profile: "dev", Results: []probes.CheckResult{{ID: "bedrock.api_access", Status: "pass"}}},
+			{Profile: "prod", Results: []probes.CheckResult{{ID: "bedrock.api_access", Status: "fail"}}},
+		}
+		records := historyRecords(rep)
+		if len(records) != 2 {
+			t.Fatalf("got %d records, want 2", len(records))
+		}
+		for i, want := range []string{"dev", "prod"} {
+			r := records[i]
+			if r.Namespace != "us-east-1@"+want || r.Report.Profiles != nil || len(r.Report.Results) != 2 || r.Report.Results[0].ID != "dns.sts" {
+				t.Errorf("record %d = %+v, want %s with the shared results first and no nested profiles", i, r, want)
+			}
+		}
+		if records[1].Report.Results[1].Status != "fail" || len(rep.Results) != 1 {
+			t.Errorf("records share or modify the report's results")
+		}
+	})
+}
+
+func TestLoadHistory(t *testing.T) {
+	probestest.Home(t)
+	const ns = "us-east-1@default"
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := saveHistory(ns, historyReport("us-east-1", t0.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir, _ := historyDir(ns)
+	bad := map[string]string{
+		"20261016T100000.000Z.json": "{not json",
+		"20261016T100100.000Z.json": `{"schema_version":"0.9","timestamp":"2026-10-16T10:01:00Z","results":[]}`,
+		"20261016T100200.000Z.json": `{"schema_version":"` + schemaVersion + `","results":[]}`,
+		"20261016T100300.000Z.json": `[1, 2, 3]`,
+	}
+	for name, content := range bad {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "20261016T100400.000Z.json"), 0o700)
+
+	reports, err := loadHistory(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Time
+	for _, r := range reports {
+		got = append(got, r.Timestamp)
+	}
+	want := []time.Time{t0.Add(2 * time.Minute), t0.Add(time.Minute), t0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadHistory timestamps = %v, want the valid runs newest first %v", got, want)
+	}
+
+	if reports, err := loadHistory("eu-west-1@default"); err != nil || len(reports) != 0 {
+		t.Errorf("empty namespace = %d reports, %v; want none", len(reports), err)
+	}
+}
+
+func TestSaveHistoryPrunes(t *testing.T) {
+	probestest.Home(t)
+	const ns = "us-east-1@default"
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < historyKeep+2; i++ {
+		if err := saveHistory(ns, historyReport("us-east-1", t0.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir, _ := historyDir(ns)
+	names, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(names) != historyKeep {
+		t.Fatalf("kept %d runs, want %d", len(names), historyKeep)
+	}
+	if oldest := filepath.Base(names[0]); oldest != "20261016T090002.000Z.json" {
+		t.Errorf("oldest kept run = %s, want the two oldest pruned", oldest)
+	}
+	if info, err := os.Stat(names[0]); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("history file mode = %v, want private", info.Mode().Perm())
+	}
+}
+
+func TestRecordHistoryNamespacing(t *testing.T) {
+	probestest.Home(t)
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	record := func(region, profile string, at time.Time) historyRecord {
+		records := []historyRecord{{Namespace: historyNamespace(region, profile), Profile: profile, Report: historyReport(region, at)}}
+		recordHistory(records)
+		return records[0]
+	}
+
+	if r := record("us-east-1", "dev", t0); r.Previous != nil {
+		t.Errorf("first run has a previous run: %+v", r.Previous)
+	}
+	if r := record("us-west-2", "dev", t0.Add(time.Minute)); r.Previous != nil {
+		t.Errorf("another region compared with us-east-1: %+v", r.Previous)
+	}
+	if r := record("us-east-1", "prod", t0.Add(2*time.Minute)); r.Previous != nil {
+		t.Errorf("another profile compared with dev: %+v", r.Previous)
+	}
+	r := record("us-east-1", "dev", t0.Add(3*time.Minute))
+	if r.Previous == nil || !r.Previous.Timestamp.Equal(t0) {
+		t.Errorf("previous = %+v, want the first us-east-1@dev run", r.Previous)
+	}
+}
+
+func TestPrintDiff(t *testing.T) {
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	span := fmt.Sprintf("%s → %s\n", t0.Local().Format(time.DateTime), t1.Local().Format(time.DateTime))
+	previous := historyReport("us-east-1", t0,
+		probes.CheckResult{ID: "dns.sts", Name: "DNS - STS", Status: "pass"},
+		probes.CheckResult{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "pass"},
+		probes.CheckResult{ID: "bedrock.throttling", Name: "Bedrock Throttling Probe", Status: "warn"},
+	)
+	tests := []struct {
+		name     string
+		previous *output.Report
+		current  output.Report
+		want     string
+	}{
+		{
+			name:    "first run",
+			current: historyReport("us-east-1", t1),
+			want:    "🩺 BCCE Doctor Probes Diff (region us-east-1, profile dev)\nNo previous run to compare with; this run is now the baseline.\n\n",
+		},
+		{
+			name:     "no changes",
+			previous: &previous,
+			current:  historyReport("us-east-1", t1, previous.Results...),
+			want:     "🩺 BCCE Doctor Probes Diff (region us-east-1, profile dev)\n" + span + "No status changes since the previous run.\n\n",
+		},
+		{
+			name:     "changed, new, and gone",
+			previous: &previous,
+			current: historyReport("us-east-1", t1,
+				probes.CheckResult{ID: "dns.sts", Name: "DNS - STS", Status: "pass"},
+				probes.CheckResult{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "fail", Message: "timeout", Fix: "Check the proxy"},
+				probes.CheckResult{ID: "tls.alpn", Name: "HTTP/2 Negotiation", Status: "pass"},
+			),
+			want: "🩺 BCCE Doctor Probes Diff (region us-east-1, profile dev)\n" + span +
+				"❌ HTTPS Connectivity: pass → fail\n" +
+				"   timeout\n" +
+				"   Fix: Check the proxy\n" +
+				"✅ HTTP/2 Negotiation: new check, pass\n" +
+				"➖ Bedrock Throttling Probe: no longer reported (was warn)\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printDiff(&buf, historyRecord{Profile: "dev", Report: tt.current, Previous: tt.previous}, output.Style{})
+			if got := buf.String(); got != tt.want {
+				t.Errorf("printDiff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	probestest.Home(t)
+	probestest.Unsetenv(t, "AWS_DEFAULT_PROFILE")
+	t.Setenv("AWS_PROFILE", "dev")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	stdout, _ := captureOutput(t, func() {
+		if code := runHistory(5, ""); code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+	})
+	if want := "Last runs for region us-east-1, profile dev:\n  (none)\n"; stdout != want {
+		t.Errorf("empty history = %q, want %q", stdout, want)
+	}
+
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rep := historyReport("us-east-1", t0.Add(time.Duration(i)*time.Minute),
+			probes.CheckResult{Name: "DNS - STS", Status: "pass"},
+			probes.CheckResult{Name: fmt.Sprintf("Run %d", i), Status: "fail"},
+		)
+		if err := saveHistory("us-east-1@dev", rep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saveHistory("us-east-1@prod", historyReport("us-east-1", t0, probes.CheckResult{Name: "Other profile", Status: "fail"}))
+
+	stdout, _ = captureOutput(t, func() { runHistory(2, "") })
+	want := "Last runs for region us-east-1, profile dev:\n" +
+		t0.Add(2*time.Minute).Local().Format(time.DateTime) + "  test  1 pass, 0 warn, 1 fail, 0 skipped\n    ❌ Run 2\n" +
+		t0.Add(time.Minute).Local().Format(time.DateTime) + "  test  1 pass, 0 warn, 1 fail, 0 skipped\n    ❌ Run 1\n"
+	if stdout != want {
+		t.Errorf("runHistory(2) =\n%s\nwant\n%s", stdout, want)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// rerunnable maps each check ID to the check that produced it on the last
+// run, so --interactive can run just that check again to confirm a fix.
+// Checks recorded with rec.run are rerunnable; the others are not.
+var rerunnable = map[string]func() []probes.CheckResult{}
+
+//go:embed data/settings_template.json
+var settingsTemplate []byte
+
+// Guided fix kinds, from safest to most manual.
+const (
+	fixKindEnv      = "env"       // set a variable; offer to persist it
+	fixKindSSOLogin = "sso_login" // run aws sso login
+	fixKindIT       = "it"        // for network or AWS administrators
+	fixKindCommand  = "command"   // a command to review and run by hand
+	fixKindManual   = "manual"    // prose only
+)
+
+// guidedFix is what --interactive offers for one failed check.
+type guidedFix struct {
+	Kind    string
+	Var     string // fixKindEnv
+	Value   string // fixKindEnv
+	Command string // the FixCommand for this platform's shell
+}
+
+var (
+	// Single literal assignments only; anything with expansions or several
+	// statements is shown, not applied.
+	bashEnvAssignment       = regexp.MustCompile(`^export ([A-Za-z_][A-Za-z0-9_]*)=("?)([^"$\x60\\;&|<>\s]*)("?)$`)
+	powershellEnvAssignment = regexp.MustCompile(`^\$env:([A-Za-z_][A-Za-z0-9_]*) = "([^"$\x60]*)"$`)
+	ssoLoginCommand         = regexp.MustCompile(`^aws sso login(?: --profile ([A-Za-z0-9_.@+-]+))?$`)
+)
+
+// classifyFix decides how --interactive handles r's fix. Only setting a
+// variable and aws sso login are ever applied; IT fixes and other commands
+// are shown for copying.
+func classifyFix(r probes.CheckResult, powershell bool) guidedFix {
+	fix := guidedFix{Kind: fixKindManual}
+	if r.FixCommand != nil {
+		fix.Command = r.FixCommand.Bash
+		if powershell {
+			fix.Command = r.FixCommand.PowerShell
+		}
+	}
+	command := strings.TrimSpace(fix.Command)
+	switch {
+	case probes.ITCodes[r.Code]:
+		fix.Kind = fixKindIT
+	case command == "":
+	case strings.Contains(command, "aws sso login"):
+		fix.Kind = fixKindSSOLogin
+	case probes.DestructiveCommand.MatchString(command):
+		fix.Kind = fixKindManual
+	default:
+		fix.Kind = fixKindCommand
+		if powershell {
+			if m := powershellEnvAssignment.FindStringSubmatch(command); m != nil {
+				fix.Kind, fix.Var, fix.Value = fixKindEnv, m[1], m[2]
+			}
+		} else if m := bashEnvAssignment.FindStringSubmatch(command); m != nil && m[2] == m[4] {
+			fix.Kind, fix.Var, fix.Value = fixKindEnv, m[1], m[3]
+		}
+	}
+	return fix
+}
+
+// ssoLoginArgs returns the arguments of the aws sso login a fix asks for:
+// the profile it names, or else the active one.
+func ssoLoginArgs(command string) []string {
+	args := []string{"sso", "login"}
+	if m := ssoLoginCommand.FindStringSubmatch(strings.TrimSpace(command)); m != nil && m[1] != "" {
+		return append(args, "--profile", m[1])
+	}
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		args = append(args, "--profile", p)
+	}
+	return args
+}
+
+// backupFile copies path to path.bak-<UTC timestamp> and returns the copy's
+// name, or "" when path does not exist.
+func backupFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	backup := fmt.Sprintf("%s.bak-%s", path, time.Now().UTC().Format("20060102T150405Z"))
+	return backup, os.WriteFile(backup, data, info.Mode().Perm())
+}
+
+// shellRCFile returns the startup file of the user's shell that exports
+// belong in, or "" for shells whose syntax differs (fish, PowerShell).
+func shellRCFile(home, shell, goos string) string {
+	switch filepath.Base(shell) {
+	case "zsh":
+		return filepath.Join(home, ".zshrc")
+	case "bash":
+		if goos == "darwin" {
+			// Terminal.app starts login shells, which skip .bashrc.
+			return filepath.Join(home, ".bash_profile")
+		}
+		return filepath.Join(home, ".bashrc")
+	}
+	return ""
+}
+
+// appendExport appends line to the shell rc file after backing it up. It
+// returns the backup's name, or "" when the file did not exist. A line
+// already present is not added again.
+func appendExport(rcFile, line string) (backup string, err error) {
+	data, err := os.ReadFile(rcFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, existing := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(existing) == line {
+			return "", nil
+		}
+	}
+	if backup, err = backupFile(rcFile); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(rcFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return backup, err
+	}
+	defer f.Close()
+	prefix := "\n"
+	if len(data) == 0 || strings.HasSuffix(string(data), "\n") {
+		prefix = ""
+	}
+	_, err = fmt.Fprintf(f, "%s# Added by bcce doctor-probes --interactive on %s\n%s\n", prefix, time.Now().UTC().Format(time.DateOnly), line)
+	return backup, err
+}
+
+// writeSettingsFromTemplate creates the user settings.json from the embedded
+// template with env set into its env block. It never overwrites a file.
+func writeSettingsFromTemplate(path string, env map[string]string) error {
+	var settings map[string]any
+	if err := json.Unmarshal(settingsTemplate, &settings); err != nil {
+		return fmt.Errorf("settings template: %w", err)
+	}
+	block, _ := settings["env"].(map[string]any)
+	if block == nil {
+		block = map[string]any{}
+		settings["env"] = block
+	}
+	for k, v := range env {
+		block[k] = v
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// rerunCheck runs the check that produced id again and returns its new
+// result for id.
+func rerunCheck(opts options, id string) (probes.CheckResult, bool) {
+	check, ok := rerunnable[id]
+	if !ok {
+		return probes.CheckResult{}, false
+	}
+	rec := newRecorder(opts)
+	start := time.Now()
+	rec.add(stamp(check(), start)...)
+	for _, r := range rec.results {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return probes.CheckResult{}, false
+}
+
+// guide holds the terminal and platform of an --interactive session.
+type guide struct {
+	opts       options
+	in         *bufio.Reader
+	out        io.Writer
+	home       string
+	shell      string
+	goos       string
+	powershell bool
+}
+
+// confirm asks a y/N question; anything but y or yes is no.
+func (g *guide) confirm(question string) bool {
+	fmt.Fprintf(g.out, "%s [y/N] ", question)
+	answer, _ := g.in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// block prints text indented, for copying.
+func (g *guide) block(title, text string) {
+	fmt.Fprintf(g.out, "  %s\n\n", title)
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		fmt.Fprintf(g.out, "      %s\n", line)
+	}
+	fmt.Fprintln(g.out)
+}
+
+// applyEnv sets the variable for this process and offers to persist it in
+// the shell rc file or, when there is none yet, in ~/.claude/settings.json.
+func (g *guide) applyEnv(fix guidedFix) bool {
+	if !g.confirm(fmt.Sprintf("Set %s=%s for the checks in this session?", fix.Var, fix.Value)) {
+		return false
+	}
+	os.Setenv(fix.Var, fix.Value)
+
+	if rc := shellRCFile(g.home, g.shell, g.goos); rc != "" && !g.powershell {
+		line := fmt.Sprintf("export %s=%s", fix.Var, fix.Value)
+		if g.confirm(fmt.Sprintf("Append %q to %s (a backup is made first)?", line, rc)) {
+			if backup, err := appendExport(rc, line); err != nil {
+				fmt.Fprintf(g.out, "  Could not update %s: %v\n", rc, err)
+			} else if backup != "" {
+				fmt.Fprintf(g.out, "  Updated %s; the previous version is %s\n", rc, backup)
+			} else {
+				fmt.Fprintf(g.out, "  Updated %s\n", rc)
+			}
+		}
+	}
+	settings := filepath.Join(g.home, ".claude", "settings.json")
+	if _, err := os.Stat(settings); errors.Is(err, os.ErrNotExist) {
+		if g.confirm(fmt.Sprintf("Create %s for Bedrock with %s=%s in its env block?", settings, fix.Var, fix.Value)) {
+			env := map[string]string{fix.Var: fix.Value}
+			if region := probes.CurrentRegion(g.opts.region); region != "" && fix.Var != "AWS_REGION" {
+				env["AWS_REGION"] = region
+			}
+			if err := writeSettingsFromTemplate(settings, env); err != nil {
+				fmt.Fprintf(g.out, "  Could not create %s: %v\n", settings, err)
+			} else {
+				fmt.Fprintf(g.out, "  Created %s\n", settings)
+			}
+		}
+	}
+	return true
+}
+
+// applySSOLogin runs aws sso login attached to the terminal.
+func (g *guide) applySSOLogin(fix guidedFix) bool {
+	args := ssoLoginArgs(fix.Command)
+	if !g.confirm(fmt.Sprintf("Run aws %s now?", strings.Join(args, " "))) {
+		return false
+	}
+	cmd := exec.Command("aws", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, g.out, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(g.out, "  aws sso login failed: %v\n", err)
+		return false
+	}
+	g.opts.env.Reset()
+	return true
+}
+
+// runInteractive walks through the failed checks one at a time, applies the
+// safe local fixes the user agrees to, and runs each fixed check again. It
+// returns results with confirmed fixes replaced by their new results.
+func runInteractive(opts options, in io.Reader, out io.Writer, results []probes.CheckResult) []probes.CheckResult {
+	home, _ := os.UserHomeDir()
+	g := &guide{
+		opts: opts, in: bufio.NewReader(in), out: out, home: home,
+		shell: os.Getenv("SHELL"), goos: runtime.GOOS, powershell: runtime.GOOS == "windows",
+	}
+
+	var failed []int
+	for i, r := range results {
+		if r.Status == "fail" && !r.Suppressed {
+			failed = append(failed, i)
+		}
+	}
+	fmt.Fprintln(out)
+	if len(failed) == 0 {
+		fmt.Fprintln(out, "Nothing to fix: no checks failed.")
+		return results
+	}
+	fmt.Fprintf(out, "%d checks failed. Going through them one at a time; nothing changes unless you answer y.\n", len(failed))
+
+	for n, i := range failed {
+		r := results[i]
+		fmt.Fprintf(out, "\n[%d/%d] %s %s", n+1, len(failed), consoleStyle.Mark(r.Status), r.Name)
+		if r.Code != "" {
+			fmt.Fprintf(out, " (%s)", r.Code)
+		}
+		fmt.Fprintf(out, "\n  %s\n", r.Message)
+		if r.Fix != "" {
+			fmt.Fprintf(out, "  Fix: %s\n", r.Fix)
+		}
+		fmt.Fprintln(out)
+
+		fix := classifyFix(r, g.powershell)
+		applied := false
+		switch fix.Kind {
+		case fixKindEnv:
+			applied = g.applyEnv(fix)
+		case fixKindSSOLogin:
+			applied = g.applySSOLogin(fix)
+		case fixKindIT:
+			text := fmt.Sprintf("Check: %s (%s)\nResult: %s\nRequested change: %s", r.Name, r.Code, r.Message, r.Fix)
+			if fix.Command != "" {
+				text += "\nCommand: " + fix.Command
+			}
+			g.block("This needs your network or AWS administrator. Send them:", probes.RedactSecrets(text))
+		case fixKindCommand:
+			g.block("Review and run this yourself:", fix.Command)
+		}
+		if !applied {
+			continue
+		}
+		updated, ok := rerunCheck(opts, r.ID)
+		if !ok {
+			fmt.Fprintln(out, "  Applied. Run doctor-probes again to confirm.")
+			continue
+		}
+		results[i] = updated
+		fmt.Fprintf(out, "  Re-ran %s: %s %s\n", r.Name, consoleStyle.Mark(updated.Status), updated.Message)
+	}
+	return results
+}
@@ -0,0 +1,142 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// JUnit renders the report as JUnit XML for CI test reporting: a test suite
+// for the shared checks and one per --profiles profile, with a test case per
+// check. Failed checks are failures and skipped or suppressed ones are
+// skipped. Warnings fail their test case when WarnFails is set, as under an
+// exit policy that does not exit 0 on warnings; otherwise they pass with the
+// warning in the case's output. The results of one group share the time of
+// its call, so only the run as a whole gets a total: its wall time.
+type JUnit struct {
+	WarnFails bool
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties *junitProperties `xml:"properties"`
+	Cases      []junitCase      `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Property []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func (j JUnit) Render(w io.Writer, rep Report) error {
+	suites := junitSuites{Name: "BCCE Doctor Probes"}
+	props := &junitProperties{Property: []junitProperty{
+		{Name: "region", Value: rep.Region},
+		{Name: "environment", Value: rep.Environment.String()},
+		{Name: "tool_version", Value: rep.ToolVersion},
+	}}
+	suites.Suites = append(suites.Suites, j.suite("doctor-probes", rep, props, rep.Results))
+	for _, run := range rep.Profiles {
+		suites.Suites = append(suites.Suites, j.suite("doctor-probes profile "+run.Profile, rep, nil, run.Results))
+	}
+	for _, s := range suites.Suites {
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Skipped += s.Skipped
+	}
+	suites.Time = junitSeconds(rep.DurationMs)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (j JUnit) suite(name string, rep Report, props *junitProperties, results []probes.CheckResult) junitSuite {
+	s := junitSuite{Name: name, Properties: props, Tests: len(results)}
+	if !rep.Timestamp.IsZero() {
+		s.Timestamp = rep.Timestamp.UTC().Format("2006-01-02T15:04:05")
+	}
+	for _, r := range results {
+		c := junitCase{Name: r.Name, ClassName: r.ID, Time: junitSeconds(r.DurationMs)}
+		switch {
+		case r.Suppressed:
+			c.Skipped = &junitSkipped{Message: "suppressed: " + r.Message}
+			s.Skipped++
+		case r.Status == "skip":
+			c.Skipped = &junitSkipped{Message: r.Message}
+			s.Skipped++
+		case r.Status == "fail", r.Status == "warn" && j.WarnFails:
+			c.Failure = &junitFailure{Message: r.Message, Type: r.Code, Text: junitDetail(r)}
+			s.Failures++
+		case r.Status == "warn":
+			c.SystemOut = &junitOutput{Text: "WARN: " + junitDetail(r)}
+		}
+		s.Cases = append(s.Cases, c)
+	}
+	return s
+}
+
+// junitDetail is the body of a failing or warning case: the message and how
+// to fix it.
+func junitDetail(r probes.CheckResult) string {
+	lines := []string{r.Message}
+	if r.Fix != "" {
+		lines = append(lines, "Fix: "+r.Fix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func junitSeconds(ms float64) string {
+	return fmt.Sprintf("%.3f", ms/1000)
+}
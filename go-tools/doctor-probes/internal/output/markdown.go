@@ -0,0 +1,78 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// Markdown renders the report as a GitHub-flavored Markdown table, for pull
+// request comments, job summaries, and tickets. Policy names the exit policy
+// in the summary line.
+type Markdown struct {
+	Policy string
+}
+
+func (m Markdown) Render(w io.Writer, rep Report) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "# BCCE Doctor Probes Report")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "- Region: %s\n", markdownText(rep.Region))
+	fmt.Fprintf(&b, "- Environment: %s\n", markdownText(rep.Environment.String()))
+	if !rep.Timestamp.IsZero() {
+		fmt.Fprintf(&b, "- Run at: %s\n", rep.Timestamp.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	fmt.Fprintln(&b)
+	markdownTable(&b, rep.Results)
+
+	for _, run := range rep.Profiles {
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "## Profile %s\n", markdownText(run.Profile))
+		fmt.Fprintln(&b)
+		markdownTable(&b, run.Results)
+	}
+
+	all := AllResults(rep.Results, rep.Profiles)
+	counts := map[string]int{}
+	suppressed := 0
+	for _, r := range all {
+		if r.Suppressed {
+			suppressed++
+			continue
+		}
+		counts[r.Status]++
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "**%d failed, %d warnings, %d passed, %d skipped** (%s)\n", counts["fail"], counts["warn"], counts["pass"], counts["skip"], m.Policy)
+	if suppressed > 0 {
+		fmt.Fprintf(&b, "\n%d suppressed check(s) not shown; they are in --format json with \"suppressed\": true\n", suppressed)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownTable writes one row per check that is not suppressed.
+func markdownTable(b *strings.Builder, results []probes.CheckResult) {
+	fmt.Fprintln(b, "| Status | Check | Result | Fix |")
+	fmt.Fprintln(b, "| --- | --- | --- | --- |")
+	for _, r := range results {
+		if r.Suppressed {
+			continue
+		}
+		message := r.Message
+		if r.DurationMs >= slowCheckMs {
+			message += fmt.Sprintf(" (%s)", probes.FormatSeconds(r.DurationMs))
+		}
+		fmt.Fprintf(b, "| %s %s | %s | %s | %s |\n", Style{}.Mark(r.Status), strings.ToUpper(r.Status), markdownText(r.Name), markdownText(message), markdownText(r.Fix))
+	}
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+// markdownText escapes s for a table cell: pipes would end the cell and
+// newlines the row.
+func markdownText(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,159 @@
+// Package output renders a finished doctor-probes run: the console report
+// in its styles and the machine-readable formats.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// Renderer writes a finished run to w in one output format. New formats
+// implement it and are selected by the --format flag.
+type Renderer interface {
+	Render(w io.Writer, rep Report) error
+}
+
+// JSON renders the report as indented JSON.
+type JSON struct{}
+
+func (JSON) Render(w io.Writer, rep Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// Console prints every check, the profile comparison, and a summary line.
+// With Quiet, passing checks are left out. Policy names the exit policy in
+// the summary line.
+type Console struct {
+	Quiet  bool
+	Policy string
+	Style  Style
+}
+
+func (c Console) Render(w io.Writer, rep Report) error {
+	fmt.Fprintln(w, c.Style.Title("BCCE Doctor Probes Report"))
+	fmt.Fprintf(w, "Environment: %s\n", rep.Environment)
+	fmt.Fprintln(w)
+
+	suppressed := 0
+	for _, result := range rep.Results {
+		if result.Suppressed {
+			suppressed++
+			continue
+		}
+		if c.Quiet && result.Status == "pass" {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s: %s", c.Style.Mark(result.Status), result.Name, result.Message)
+		if result.DurationMs >= slowCheckMs {
+			fmt.Fprintf(w, " (%s)", probes.FormatSeconds(result.DurationMs))
+		}
+		fmt.Fprintln(w)
+		if result.Fix != "" {
+			fmt.Fprintf(w, "   Fix: %s\n", result.Fix)
+		}
+		if len(result.Regions) > 0 && result.Status != "pass" {
+			probes.PrintRegionTable(w, result.Regions)
+		}
+	}
+
+	fmt.Fprintln(w)
+
+	if len(rep.Profiles) > 0 {
+		printProfileTable(w, rep.Profiles)
+		fmt.Fprintln(w)
+	}
+
+	all := AllResults(rep.Results, rep.Profiles)
+	hasFailures, hasWarnings := Summarize(all)
+	if hasFailures {
+		fmt.Fprintf(w, "%s Critical connectivity issues detected (%s)\n", c.Style.Mark("fail"), c.Policy)
+	} else if hasWarnings {
+		// The emoji is drawn narrower than it measures; pad it.
+		pad := " "
+		if !c.Style.ASCII {
+			pad = "  "
+		}
+		fmt.Fprintf(w, "%s%sSome warnings detected (%s)\n", c.Style.Mark("warn"), pad, c.Policy)
+	} else {
+		fmt.Fprintf(w, "%s All connectivity checks passed (%s)\n", c.Style.Mark("pass"), c.Policy)
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(w, "%d suppressed check(s) not shown; they are in --format json with \"suppressed\": true\n", suppressed)
+	}
+	if rep.DurationMs > 0 {
+		fmt.Fprintf(w, "Ran %d checks in %s", len(all), probes.FormatSeconds(rep.DurationMs))
+		if slowest := slowestResult(all); slowest.DurationMs > 0 {
+			fmt.Fprintf(w, "; slowest: %s (%s)", slowest.Name, probes.FormatSeconds(slowest.DurationMs))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// slowCheckMs is the duration above which console output shows a check's
+// time.
+const slowCheckMs = 1000
+
+// slowestResult returns the result that took longest.
+func slowestResult(results []probes.CheckResult) (slowest probes.CheckResult) {
+	for _, r := range results {
+		if r.DurationMs > slowest.DurationMs {
+			slowest = r
+		}
+	}
+	return slowest
+}
+
+// printProfileTable renders a check × profile comparison followed by the
+// detail of every non-passing cell.
+func printProfileTable(w io.Writer, runs []ProfileRun) {
+	var names []string
+	cells := map[string]map[string]string{}
+	for _, run := range runs {
+		for _, r := range run.Results {
+			if cells[r.Name] == nil {
+				cells[r.Name] = map[string]string{}
+				names = append(names, r.Name)
+			}
+			cells[r.Name][run.Profile] = strings.ToUpper(r.Status)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "CHECK")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "\t%s", run.Profile)
+	}
+	fmt.Fprintln(tw)
+	for _, name := range names {
+		fmt.Fprint(tw, name)
+		for _, run := range runs {
+			cell := cells[name][run.Profile]
+			if cell == "" {
+				cell = "-"
+			}
+			fmt.Fprintf(tw, "\t%s", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+
+	for _, run := range runs {
+		for _, r := range run.Results {
+			if r.Status == "pass" {
+				continue
+			}
+			fmt.Fprintf(w, "[%s] %s: %s\n", run.Profile, r.Name, r.Message)
+			if r.Fix != "" {
+				fmt.Fprintf(w, "   Fix: %s\n", r.Fix)
+			}
+		}
+	}
+}
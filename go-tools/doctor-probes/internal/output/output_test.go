@@ -0,0 +1,175 @@
+package output
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenReport has a result of every kind the console output shows.
+func goldenReport() Report {
+	return Report{
+		Region:      "us-east-1",
+		Environment: probes.HostEnvironment{OS: "linux", Container: "docker", CI: "GitHub Actions"},
+		Timestamp:   time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+		DurationMs:  3342,
+		Results: []probes.CheckResult{
+			{ID: "region", Name: "AWS Region", Status: "pass", Message: "us-east-1 from AWS_REGION (partition aws)", DurationMs: 2},
+			{
+				ID: "region.consistency", Name: "Region Consistency", Status: "warn", Message: "Claude Code and the AWS CLI use different regions",
+				Fix: "Set AWS_REGION to one region", DurationMs: 3,
+				Regions: []probes.RegionSource{
+					{Source: "AWS_REGION (environment)", Value: "us-east-1", Region: "us-east-1", UsedBy: "Claude Code", Matches: true},
+					{Source: "region (profile dev)", Value: "eu-west-1", Region: "eu-west-1", UsedBy: "AWS CLI"},
+				},
+			},
+			{ID: "dns.bedrock_runtime", Name: "DNS - Bedrock Runtime", Status: "pass", Message: "Resolved bedrock-runtime.us-east-1.amazonaws.com", DurationMs: 12},
+			{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "warn", Code: "E_SLOW_TTFB", Message: "Reachable (median of 3: ttfb 900ms)", Fix: "Check VPN/proxy routing", DurationMs: 2750},
+			{ID: "tls.revocation", Name: "Certificate Revocation", Status: "warn", Message: "OCSP unreachable (suppressed by --severity)", Suppressed: true},
+			{ID: "bedrock.api_access", Name: "Bedrock API Access", Status: "fail", Code: "E_IAM_ACCESS_DENIED", Message: "AccessDenied", Fix: "Grant bedrock:ListFoundationModels", DurationMs: 340},
+			{ID: "bedrock.usage", Name: "Usage", Status: "skip", Message: "skipped (dependency failed: bedrock.api_access)"},
+		},
+		Profiles: []ProfileRun{
+			{Profile: "dev", Results: []probes.CheckResult{{ID: "bedrock.api_access", Name: "Bedrock API Access", Status: "pass", Message: "ok", DurationMs: 120}}},
+			{Profile: "prod", Results: []probes.CheckResult{{ID: "bedrock.api_access", Name: "Bedrock API Access", Status: "fail", Message: "denied", Fix: "Grant access", DurationMs: 80}}},
+		},
+	}
+}
+
+// TestGolden pins the output of each console style and format byte for
+// byte. Run with -update to rewrite testdata after an intended change.
+func TestGolden(t *testing.T) {
+	const policy = "exit policy: default"
+	tests := []struct {
+		golden string
+		r      Renderer
+	}{
+		// A terminal: emoji marks in color.
+		{"emoji", Console{Policy: policy, Style: Style{Color: true}}},
+		// --ascii on a terminal: tags in color.
+		{"color", Console{Policy: policy, Style: Style{ASCII: true, Color: true}}},
+		// --no-color or NO_COLOR on a terminal.
+		{"no-color", Console{Policy: policy, Style: Style{}}},
+		// --ascii --no-color, or stdout is not a terminal.
+		{"ascii", Console{Policy: policy, Style: Style{ASCII: true}}},
+		{"quiet", Console{Quiet: true, Policy: policy, Style: Style{ASCII: true}}},
+		{"junit", JUnit{WarnFails: true}},
+		{"junit-warn-passes", JUnit{}},
+		{"markdown", Markdown{Policy: policy}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.r.Render(&buf, goldenReport()); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", tt.golden+".golden")
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output =\n%s\nwant (%s)\n%s", got, path, want)
+			}
+		})
+	}
+}
+
+func TestConsoleTiming(t *testing.T) {
+	tests := []struct {
+		name    string
+		rep     Report
+		summary string
+	}{
+		{
+			name: "wall time and the slowest check",
+			rep: Report{DurationMs: 4100, Results: []probes.CheckResult{
+				{Name: "DNS - STS", Status: "pass", Message: "resolved", DurationMs: 999},
+				{Name: "Bedrock API Access", Status: "pass", Message: "ok", DurationMs: 2350},
+			}},
+			summary: "[PASS] DNS - STS: resolved\n[PASS] Bedrock API Access: ok (2.4s)\n\n" +
+				"[PASS] All connectivity checks passed (exit policy: default)\n" +
+				"Ran 2 checks in 4.1s; slowest: Bedrock API Access (2.4s)\n",
+		},
+		{
+			name: "no check timed",
+			rep: Report{DurationMs: 50, Results: []probes.CheckResult{
+				{Name: "DNS - STS", Status: "pass", Message: "resolved"},
+			}},
+			summary: "[PASS] DNS - STS: resolved\n\n" +
+				"[PASS] All connectivity checks passed (exit policy: default)\n" +
+				"Ran 1 checks in 0.1s\n",
+		},
+		{
+			name: "no wall time",
+			rep: Report{Results: []probes.CheckResult{
+				{Name: "DNS - STS", Status: "pass", Message: "resolved", DurationMs: 40},
+			}},
+			summary: "[PASS] DNS - STS: resolved\n\n" +
+				"[PASS] All connectivity checks passed (exit policy: default)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			Console{Policy: "exit policy: default", Style: Style{ASCII: true}}.Render(&buf, tt.rep)
+			want := "BCCE Doctor Probes Report\nEnvironment: " + tt.rep.Environment.String() + "\n\n" + tt.summary
+			if got := buf.String(); got != want {
+				t.Errorf("Render =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSlowestResult(t *testing.T) {
+	results := []probes.CheckResult{
+		{Name: "DNS - STS", DurationMs: 40},
+		{Name: "Bedrock API Access", DurationMs: 2350},
+		{Name: "Service Quotas", DurationMs: 900},
+	}
+	if got := slowestResult(results); got.Name != "Bedrock API Access" {
+		t.Errorf("slowestResult = %q, want Bedrock API Access", got.Name)
+	}
+	if got := slowestResult(nil); got.DurationMs != 0 {
+		t.Errorf("slowestResult(nil) = %+v, want none", got)
+	}
+	if got := probes.FormatSeconds(2350); got != "2.4s" {
+		t.Errorf("FormatSeconds(2350) = %q, want 2.4s", got)
+	}
+}
+
+func TestPrintProfileTable(t *testing.T) {
+	runs := []ProfileRun{
+		{Profile: "dev", Results: []probes.CheckResult{
+			{Name: "Bedrock API Access", Status: "pass"},
+			{Name: "Service Quotas", Status: "warn", Message: "quota low", Fix: "Request more"},
+		}},
+		{Profile: "prod", Results: []probes.CheckResult{
+			{Name: "Bedrock API Access", Status: "fail", Message: "denied"},
+		}},
+	}
+	var buf bytes.Buffer
+	printProfileTable(&buf, runs)
+	want := `CHECK               dev   prod
+Bedrock API Access  PASS  FAIL
+Service Quotas      WARN  -
+[dev] Service Quotas: quota low
+   Fix: Request more
+[prod] Bedrock API Access: denied
+`
+	if got := buf.String(); got != want {
+		t.Errorf("printProfileTable =\n%s\nwant\n%s", got, want)
+	}
+}
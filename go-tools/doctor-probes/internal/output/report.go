@@ -0,0 +1,52 @@
+package output
+
+import (
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// Report is the JSON envelope around a set of check results, and what every
+// renderer is given.
+type Report struct {
+	SchemaVersion string                 `json:"schema_version"`
+	ToolVersion   string                 `json:"tool_version"`
+	Region        string                 `json:"region"`
+	Environment   probes.HostEnvironment `json:"environment"`
+	Timestamp     time.Time              `json:"timestamp"`
+	DurationMs    float64                `json:"duration_ms"` // wall time of the run, profiles included
+	Results       []probes.CheckResult   `json:"results"`
+	Profiles      []ProfileRun           `json:"profiles,omitempty"` // --profiles only
+}
+
+// ProfileRun holds the credential-dependent results for one AWS profile.
+type ProfileRun struct {
+	Profile string               `json:"profile"`
+	Results []probes.CheckResult `json:"results"`
+}
+
+// AllResults flattens shared and per-profile results, for the exit code.
+func AllResults(results []probes.CheckResult, runs []ProfileRun) []probes.CheckResult {
+	all := append([]probes.CheckResult(nil), results...)
+	for _, run := range runs {
+		all = append(all, run.Results...)
+	}
+	return all
+}
+
+// Summarize reports whether any result that is not suppressed failed or
+// warned.
+func Summarize(results []probes.CheckResult) (hasFailures, hasWarnings bool) {
+	for _, result := range results {
+		if result.Suppressed {
+			continue
+		}
+		switch result.Status {
+		case "warn":
+			hasWarnings = true
+		case "fail":
+			hasFailures = true
+		}
+	}
+	return hasFailures, hasWarnings
+}
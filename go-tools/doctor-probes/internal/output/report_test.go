@@ -0,0 +1,48 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+func TestAllResults(t *testing.T) {
+	shared := []probes.CheckResult{{ID: "network.dns"}}
+	runs := []ProfileRun{
+		{Profile: "a", Results: []probes.CheckResult{{ID: "bedrock.api_access"}}},
+		{Profile: "b", Results: []probes.CheckResult{{ID: "bedrock.api_access"}, {ID: "quotas.bedrock"}}},
+	}
+	var ids []string
+	for _, r := range AllResults(shared, runs) {
+		ids = append(ids, r.ID)
+	}
+	if want := []string{"network.dns", "bedrock.api_access", "bedrock.api_access", "quotas.bedrock"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("AllResults IDs = %v, want %v", ids, want)
+	}
+	if len(shared) != 1 {
+		t.Errorf("AllResults modified the shared results")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		statuses         []string
+		suppressed       bool
+		failures, warned bool
+	}{
+		{[]string{"pass", "skip"}, false, false, false},
+		{[]string{"pass", "warn"}, false, false, true},
+		{[]string{"warn", "fail"}, false, true, true},
+		{[]string{"fail"}, true, false, false},
+	}
+	for _, tt := range tests {
+		var results []probes.CheckResult
+		for _, status := range tt.statuses {
+			results = append(results, probes.CheckResult{Status: status, Suppressed: tt.suppressed})
+		}
+		if failures, warned := Summarize(results); failures != tt.failures || warned != tt.warned {
+			t.Errorf("Summarize(%v, suppressed %v) = %v, %v; want %v, %v", tt.statuses, tt.suppressed, failures, warned, tt.failures, tt.warned)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"os"
+	"runtime"
+)
+
+// Style is how console output marks check status.
+type Style struct {
+	ASCII bool // [PASS]/[WARN]/[FAIL] tags instead of emoji
+	Color bool // ANSI colors on the status marks
+}
+
+// DetectStyle picks the console style. Output that is not a terminal (CI
+// logs, files, pipes) gets the plain style: ASCII tags and no color.
+func DetectStyle(ascii, noColor bool) Style {
+	tty := IsTerminal(os.Stdout)
+	color := tty && !noColor && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	if runtime.GOOS == "windows" && os.Getenv("WT_SESSION") == "" && os.Getenv("TERM") == "" {
+		// Legacy conhost prints escape sequences literally.
+		color = false
+	}
+	return Style{ASCII: ascii || !tty, Color: color}
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+var statusMarks = map[string]struct{ emoji, tag, color string }{
+	"pass": {"✅", "[PASS]", "32"},
+	"warn": {"⚠️", "[WARN]", "33"},
+	"fail": {"❌", "[FAIL]", "31"},
+	"skip": {"⏭️", "[SKIP]", "90"},
+}
+
+// Mark returns the status marker of a check line.
+func (s Style) Mark(status string) string {
+	m, ok := statusMarks[status]
+	if !ok {
+		m = statusMarks["pass"]
+	}
+	text := m.emoji
+	if s.ASCII {
+		text = m.tag
+	}
+	if s.Color {
+		return "\x1b[" + m.color + "m" + text + "\x1b[0m"
+	}
+	return text
+}
+
+// Title returns a report heading.
+func (s Style) Title(text string) string {
+	if s.ASCII {
+		return text
+	}
+	return "🩺 " + text
+}
+
+// Arrow separates the before and after of a change.
+func (s Style) Arrow() string {
+	if s.ASCII {
+		return "->"
+	}
+	return "→"
+}
+
+// Removed marks a check that is no longer reported.
+func (s Style) Removed() string {
+	if s.ASCII {
+		return "[GONE]"
+	}
+	return "➖"
+}
@@ -0,0 +1,45 @@
+package output
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStyleMark(t *testing.T) {
+	tests := []struct {
+		style  Style
+		status string
+		want   string
+	}{
+		{Style{}, "pass", "✅"},
+		{Style{}, "skip", "⏭️"},
+		{Style{ASCII: true}, "warn", "[WARN]"},
+		{Style{ASCII: true}, "fail", "[FAIL]"},
+		{Style{ASCII: true}, "unknown", "[PASS]"},
+		{Style{ASCII: true, Color: true}, "fail", "\x1b[31m[FAIL]\x1b[0m"},
+		{Style{Color: true}, "skip", "\x1b[90m⏭️\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if got := tt.style.Mark(tt.status); got != tt.want {
+			t.Errorf("%+v.Mark(%s) = %q, want %q", tt.style, tt.status, got, tt.want)
+		}
+	}
+	ascii, emoji := Style{ASCII: true}, Style{}
+	if ascii.Title("Report") != "Report" || ascii.Arrow() != "->" || ascii.Removed() != "[GONE]" {
+		t.Errorf("ASCII style uses non-ASCII decorations")
+	}
+	if emoji.Title("Report") != "🩺 Report" || emoji.Arrow() != "→" || emoji.Removed() != "➖" {
+		t.Errorf("emoji style = %q, %q, %q", emoji.Title("Report"), emoji.Arrow(), emoji.Removed())
+	}
+}
+
+func TestDetectStyleWithoutTerminal(t *testing.T) {
+	if IsTerminal(os.Stdout) {
+		t.Skip("stdout is a terminal")
+	}
+	for _, ascii := range []bool{false, true} {
+		if got := DetectStyle(ascii, false); got != (Style{ASCII: true}) {
+			t.Errorf("DetectStyle(%v, false) = %+v, want plain ASCII without color", ascii, got)
+		}
+	}
+}
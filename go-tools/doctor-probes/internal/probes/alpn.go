@@ -0,0 +1,249 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// alpnProtocols is what Claude Code's HTTP client offers, in order.
+var alpnProtocols = []string{"h2", "http/1.1"}
+
+// alpnHandshake is the outcome of one TLS handshake to the runtime endpoint.
+type alpnHandshake struct {
+	Route string // "direct" or "via proxy"
+	State tls.ConnectionState
+	Err   error
+}
+
+func (h alpnHandshake) protocol() string {
+	if h.State.NegotiatedProtocol == "" {
+		// No ALPN answer means the server (or a middlebox) only speaks HTTP/1.1
+		return "http/1.1"
+	}
+	return h.State.NegotiatedProtocol
+}
+
+// describe summarizes the handshake; verbose adds the TLS version and
+// cipher suite.
+func (h alpnHandshake) describe(verbose bool) string {
+	if h.Err != nil {
+		return fmt.Sprintf("%s: %v", h.Route, h.Err)
+	}
+	s := fmt.Sprintf("%s: %s", h.Route, h.protocol())
+	if verbose {
+		s += fmt.Sprintf(" (%s, %s)", tls.VersionName(h.State.Version), tls.CipherSuiteName(h.State.CipherSuite))
+	}
+	return s
+}
+
+// tlsHandshake dials addr on a connection of its own, tunnelling through
+// proxy (HTTP CONNECT, or SOCKS5) when it is non-nil, and performs a TLS
+// handshake with cfg. It bypasses http.Transport so that no pooled connection can hide the
+// handshake.
+func tlsHandshake(env Env, addr string, proxy *url.URL, cfg *tls.Config) (tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	conn, err := dialRoute(ctx, env, &net.Dialer{Timeout: 5 * time.Second}, addr, proxy)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+
+	cfg = cfg.Clone()
+	cfg.ServerName = host
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return tlsConn.ConnectionState(), nil
+}
+
+// dialRoute connects to addr directly when proxy is nil, or else through
+// it: a SOCKS5 proxy, or an HTTP CONNECT tunnel.
+func dialRoute(ctx context.Context, env Env, dialer *net.Dialer, addr string, proxy *url.URL) (conn net.Conn, err error) {
+	err = env.Engine.do(ctx, addr, func() (err error) {
+		switch {
+		case proxy == nil:
+			conn, err = dialer.DialContext(ctx, "tcp", addr)
+		case isSOCKS(proxy):
+			conn, err = socksDialContext(proxy, dialer)(ctx, "tcp", addr)
+		default:
+			conn, err = dialProxyTunnel(ctx, dialer, proxy, addr)
+		}
+		return err
+	})
+	return conn, err
+}
+
+// endpointAddr returns the host and host:port of an https URL.
+func endpointAddr(rawURL string) (host, addr string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	addr = u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	return u.Hostname(), addr, nil
+}
+
+// proxyHostPort is the host:port of proxy, with the scheme's default port.
+func proxyHostPort(proxy *url.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+	port := "80"
+	if proxy.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// dialProxyTunnel opens an HTTP CONNECT tunnel to addr through proxy.
+func dialProxyTunnel(ctx context.Context, dialer *net.Dialer, proxy *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyHostPort(proxy)
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxyAddr, err)
+	}
+	if proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s: %w", proxyAddr, err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The server speaks only after the client hello, so nothing past the
+	// CONNECT response is buffered here.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Server: resp.Header.Get("Server")}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// claudeCodeProxy returns the proxy Claude Code would use for host, or nil
+// when it connects directly.
+func claudeCodeProxy(host string) (*url.URL, error) {
+	raw, name := proxyEnv("https_proxy", "HTTPS_PROXY", "http_proxy", "HTTP_PROXY")
+	if raw == "" {
+		return nil, nil
+	}
+	noProxy, _ := proxyEnv("no_proxy", "NO_PROXY")
+	if !undiciShouldProxy(parseNoProxy(noProxy), noProxy, host, 443) {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return proxy, nil
+}
+
+// alpnResult reports the protocol negotiated with the Bedrock runtime
+// endpoint on the route Claude Code takes and, when a proxy is in use, on a
+// direct connection as well, unless direct is false. It is never worse than
+// a warning: HTTP/1.1 works, it only streams less efficiently.
+func alpnResult(env Env, bedrockURL string, direct bool) CheckResult {
+	result := CheckResult{ID: "tls.alpn", Name: "HTTP/2 Negotiation"}
+
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	handshake := func(route string, proxy *url.URL) alpnHandshake {
+		start := time.Now()
+		state, err := tlsHandshake(env, addr, proxy, &tls.Config{NextProtos: alpnProtocols})
+		logProbe("alpn", addr, start, err, "route", route, "protocol", state.NegotiatedProtocol)
+		return alpnHandshake{Route: route, State: state, Err: err}
+	}
+
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+
+	var primary alpnHandshake
+	var bypass *alpnHandshake
+	switch {
+	case proxy == nil:
+		primary = handshake("direct", nil)
+	case direct:
+		d := handshake("direct", nil)
+		bypass = &d
+		fallthrough
+	default:
+		primary = handshake("via proxy", proxy)
+	}
+
+	summary := primary.describe(env.Verbose)
+	if bypass != nil {
+		summary += "; " + bypass.describe(env.Verbose)
+	}
+	result.Message = fmt.Sprintf("Offered %s to %s; %s", strings.Join(alpnProtocols, ","), addr, summary)
+
+	switch {
+	case primary.Err != nil:
+		result.Status, result.Code = "warn", classifyNetError(primary.Err)
+		result.Fix = "See HTTPS Connectivity; the TLS handshake to the runtime endpoint did not complete"
+	case bypass != nil && bypass.Err == nil && bypass.protocol() != primary.protocol():
+		result.Status, result.Code = "warn", codeALPNMismatch
+		result.Message += "; the proxy and direct routes negotiate different protocols, so something on one of them terminates TLS"
+		result.Fix = "Ask the proxy team to exempt *.amazonaws.com from TLS inspection, or add the Bedrock runtime host to NO_PROXY if it is reachable directly"
+	case primary.protocol() != "h2":
+		result.Status, result.Code = "warn", codeHTTP2Unavailable
+		result.Message += "; only HTTP/1.1 is available, which raises streaming latency"
+		result.Fix = "A middlebox is stripping ALPN; check TLS-inspecting firewalls and proxies between this host and AWS"
+	default:
+		result.Status = "pass"
+	}
+	return result
+}
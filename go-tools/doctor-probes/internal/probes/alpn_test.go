@@ -0,0 +1,217 @@
+package probes
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestALPNHandshakeString(t *testing.T) {
+	state := tls.ConnectionState{NegotiatedProtocol: "h2", Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}
+	tests := []struct {
+		name      string
+		handshake alpnHandshake
+		verbose   bool
+		want      string
+	}{
+		{name: "h2", handshake: alpnHandshake{Route: "direct", State: state}, want: "direct: h2"},
+		{name: "no ALPN answer", handshake: alpnHandshake{Route: "via proxy"}, want: "via proxy: http/1.1"},
+		{name: "error", handshake: alpnHandshake{Route: "direct", Err: errors.New("reset")}, want: "direct: reset"},
+		{name: "verbose", handshake: alpnHandshake{Route: "direct", State: state}, verbose: true, want: "direct: h2 (TLS 1.3, TLS_AES_128_GCM_SHA256)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.handshake.describe(tt.verbose); got != tt.want {
+				t.Errorf("describe(%v) = %q, want %q", tt.verbose, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaudeCodeProxy(t *testing.T) {
+	const host = "bedrock-runtime.us-east-1.amazonaws.com"
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string // empty means direct
+		err  string
+	}{
+		{name: "no proxy"},
+		{name: "HTTPS_PROXY", env: map[string]string{"HTTPS_PROXY": "http://proxy:3128"}, want: "http://proxy:3128"},
+		{name: "falls back to HTTP_PROXY", env: map[string]string{"HTTP_PROXY": "http://fallback:8080"}, want: "http://fallback:8080"},
+		{name: "scheme-less", env: map[string]string{"https_proxy": "proxy:3128"}, want: "http://proxy:3128"},
+		{name: "bypassed by NO_PROXY", env: map[string]string{"HTTPS_PROXY": "http://proxy:3128", "NO_PROXY": ".amazonaws.com"}},
+		{name: "unparsable", env: map[string]string{"HTTPS_PROXY": "http://proxy:port"}, err: "HTTPS_PROXY: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withProxyEnv(t, tt.env)
+			got, err := claudeCodeProxy(host)
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Errorf("err = %v, want prefix %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotURL := ""
+			if got != nil {
+				gotURL = got.String()
+			}
+			if gotURL != tt.want {
+				t.Errorf("claudeCodeProxy = %q, want %q", gotURL, tt.want)
+			}
+		})
+	}
+}
+
+// connectProxy answers one CONNECT request with status and then answers the
+// client's first write through the tunnel, the way a TLS server does. It returns the proxy URL and the request seen.
+func connectProxy(t *testing.T, status int) (*url.URL, func() *http.Request) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var (
+		mu   sync.Mutex
+		seen *http.Request
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		seen = req
+		mu.Unlock()
+		(&http.Response{StatusCode: status, ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"Server": {"squid"}}}).Write(conn)
+		if _, err := br.ReadString('\n'); err == nil {
+			conn.Write([]byte("hello"))
+		}
+	}()
+	return &url.URL{Scheme: "http", Host: ln.Addr().String(), User: url.UserPassword("alice", "s3cret")}, func() *http.Request {
+		<-done
+		mu.Lock()
+		defer mu.Unlock()
+		return seen
+	}
+}
+
+func TestDialProxyTunnel(t *testing.T) {
+	const target = "bedrock-runtime.us-east-1.amazonaws.com:443"
+	tests := []struct {
+		name   string
+		status int
+		err    string
+	}{
+		{name: "tunnel open", status: http.StatusOK},
+		{name: "proxy auth required", status: http.StatusProxyAuthRequired, err: "407"},
+		{name: "forbidden", status: http.StatusForbidden, err: "403"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, request := connectProxy(t, tt.status)
+			conn, err := dialProxyTunnel(t.Context(), &net.Dialer{}, proxy, target)
+			if tt.err != "" {
+				var statusErr *httpStatusError
+				if !errors.As(err, &statusErr) || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want an HTTP %s status error", err, tt.err)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				conn.Write([]byte("ping\n"))
+				buf := make([]byte, 5)
+				if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+					t.Errorf("tunnel read %q, %v; want hello", buf, err)
+				}
+				conn.Close()
+			}
+
+			req := request()
+			if req == nil {
+				t.Fatal("proxy saw no request")
+			}
+			if req.Method != http.MethodConnect || req.Host != target {
+				t.Errorf("request = %s %s, want CONNECT %s", req.Method, req.Host, target)
+			}
+			if got := req.Header.Get("Proxy-Authorization"); got != "Basic YWxpY2U6czNjcmV0" {
+				t.Errorf("Proxy-Authorization = %q, want basic credentials from the proxy URL", got)
+			}
+		})
+	}
+}
+
+func TestALPNResult(t *testing.T) {
+	// The test server's certificate is not trusted, so the handshake fails
+	// verification the way a TLS-inspecting middlebox with a private CA does.
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	tests := []struct {
+		name     string
+		url      string
+		env      map[string]string
+		noDirect bool // --no-direct-probe
+		code     string
+		contains string
+	}{
+		{name: "refused", url: "https://127.0.0.1:1", code: codeConnectRefused, contains: "Offered h2,http/1.1 to 127.0.0.1:1; direct: "},
+		{name: "untrusted certificate", url: srv.URL, code: codeTLSFailure, contains: "direct: tls: failed to verify certificate"},
+		{
+			name:     "proxy refuses, direct checked too",
+			url:      "https://127.0.0.1:1",
+			env:      map[string]string{"HTTPS_PROXY": "http://127.0.0.1:1"},
+			code:     codeConnectRefused,
+			contains: "via proxy: proxy 127.0.0.1:1: ",
+		},
+		{
+			name:     "proxy refuses, direct not probed",
+			url:      "https://127.0.0.1:1",
+			env:      map[string]string{"HTTPS_PROXY": "http://127.0.0.1:1"},
+			noDirect: true,
+			code:     codeConnectRefused,
+			contains: "via proxy: proxy 127.0.0.1:1: ",
+		},
+		{name: "bad proxy URL", url: srv.URL, env: map[string]string{"HTTPS_PROXY": "http://proxy:port"}, code: codeConnectFailure, contains: "Cannot parse the proxy URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, tt.env)
+			r := alpnResult(env, tt.url, !tt.noDirect)
+			if r.ID != "tls.alpn" || r.Status != "warn" || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want warn %s", r.ID, r.Status, r.Code, r.Message, tt.code)
+			}
+			if !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("message %q does not contain %q", r.Message, tt.contains)
+			}
+			if strings.Contains(r.Message, "via proxy: ") && strings.Contains(r.Message, "; direct: ") != !tt.noDirect {
+				t.Errorf("message %q, want a direct handshake %v", r.Message, !tt.noDirect)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package probes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bedrockAPIKeyEnv holds a Bedrock API key. When it is set, Claude Code
+// sends the key as a bearer token instead of signing with SigV4, even if
+// AWS credentials are also available.
+const bedrockAPIKeyEnv = "AWS_BEARER_TOKEN_BEDROCK"
+
+// Bedrock API key prefixes. A short-term key is the prefix followed by a
+// base64 presigned URL, whose X-Amz-Date and X-Amz-Expires give its expiry.
+// A long-term key is the prefix followed by base64 of its ID and secret.
+const (
+	shortTermKeyPrefix = "bedrock-api-key-"
+	longTermKeyPrefix  = "ABSK"
+)
+
+// apiKeyShape describes a key from its format alone.
+type apiKeyShape struct {
+	Kind    string    // "short-term", "long-term", or "" when unrecognized
+	Expires time.Time // zero unless short-term
+}
+
+// parseAPIKey checks the key's format, so a mangled or expired key fails
+// with a message naming the problem rather than Bedrock's generic 403.
+func parseAPIKey(key string) (apiKeyShape, error) {
+	if strings.TrimSpace(key) != key || strings.ContainsAny(key, " \t\r\n") {
+		return apiKeyShape{}, errors.New("contains whitespace or a line break")
+	}
+	if strings.ContainsAny(key, `"'`) {
+		return apiKeyShape{}, errors.New("contains quotes; export the key without them")
+	}
+	switch {
+	case strings.HasPrefix(key, shortTermKeyPrefix):
+		presigned, err := decodeBase64(strings.TrimPrefix(key, shortTermKeyPrefix))
+		if err != nil {
+			return apiKeyShape{}, errors.New("short-term key is not valid base64; it may be truncated")
+		}
+		_, rawQuery, _ := strings.Cut(string(presigned), "?")
+		q, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return apiKeyShape{}, errors.New("short-term key does not hold a presigned URL")
+		}
+		signed, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+		if err != nil {
+			return apiKeyShape{}, errors.New("short-term key has no valid X-Amz-Date")
+		}
+		secs, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+		if err != nil {
+			return apiKeyShape{}, errors.New("short-term key has no valid X-Amz-Expires")
+		}
+		return apiKeyShape{Kind: "short-term", Expires: signed.Add(time.Duration(secs) * time.Second)}, nil
+	case strings.HasPrefix(key, longTermKeyPrefix):
+		if _, err := decodeBase64(strings.TrimPrefix(key, longTermKeyPrefix)); err != nil {
+			return apiKeyShape{}, errors.New("long-term key is not valid base64; it may be truncated")
+		}
+		return apiKeyShape{Kind: "long-term"}, nil
+	}
+	return apiKeyShape{}, nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// apiKeyResponse is what Bedrock answered a request made with the key.
+type apiKeyResponse struct {
+	Status    int
+	ErrorType string // from x-amzn-ErrorType, without its namespace
+	Message   string
+	Diag      *Diagnostics
+}
+
+// callWithAPIKey sends one free request to the runtime endpoint with the key
+// as a bearer token: an empty InvokeModel body for model, which Bedrock
+// rejects as invalid only after authorizing it, or ListAsyncInvokes when no
+// model is configured.
+func callWithAPIKey(env Env, runtimeURL, key, model string) (*apiKeyResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	method, target, body := http.MethodGet, runtimeURL+"/async-invoke?maxResults=1", ""
+	if model != "" {
+		method, target, body = http.MethodPost, runtimeURL+"/model/"+url.PathEscape(model)+"/invoke", "{}"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := env.ProxyHTTP.Do(req)
+	if err != nil {
+		logProbe("api_key", target, start, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out := &apiKeyResponse{Status: resp.StatusCode, Diag: httpDiagnostics(resp)}
+	out.ErrorType, _, _ = strings.Cut(resp.Header.Get("x-amzn-ErrorType"), ":")
+	var payload struct {
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, &payload) == nil {
+		out.Message = firstNonEmpty(payload.Message, payload.MessageUpper)
+	}
+	out.Diag.ErrorCode = out.ErrorType
+	logProbe("api_key", target, start, nil, "http_status", resp.StatusCode,
+		"request_id", out.Diag.RequestID, "error_type", out.ErrorType)
+	return out, nil
+}
+
+// sigV4Source returns where the SDK finds AWS credentials, or "" when it
+// finds none.
+func sigV4Source(env Env, region string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return ""
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil || !creds.HasKeys() {
+		return ""
+	}
+	return firstNonEmpty(creds.Source, "the default credential chain")
+}
+
+// apiKeyResults checks the Bedrock API key in AWS_BEARER_TOKEN_BEDROCK, if
+// set, against the runtime endpoint at runtimeURL.
+func apiKeyResults(env Env, region, runtimeURL string) []CheckResult {
+	key := os.Getenv(bedrockAPIKeyEnv)
+	if key == "" {
+		return nil
+	}
+
+	result := CheckResult{ID: "bedrock.api_key", Name: "Bedrock API Key"}
+	// Claude Code takes the key over SigV4, so AWS credentials that also
+	// resolve are silently unused; say so whatever the key's state.
+	fallback := ""
+	if source := sigV4Source(env, region); source != "" {
+		fallback = fmt.Sprintf("; Claude Code uses the key rather than the AWS credentials from %s, so unset %s to use those instead", source, bedrockAPIKeyEnv)
+	}
+
+	shape, err := parseAPIKey(key)
+	if err != nil {
+		result.Code = codeAPIKeyMalformed
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("%s is malformed: %v", bedrockAPIKeyEnv, err)
+		result.Fix = "Copy the key again from the Bedrock console and export it exactly as shown" + fallback
+		return []CheckResult{result}
+	}
+	if !shape.Expires.IsZero() && time.Now().After(shape.Expires) {
+		result.Code = codeAPIKeyExpired
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("%s is a short-term key that expired at %s", bedrockAPIKeyEnv, shape.Expires.Local().Format(time.RFC1123))
+		result.Fix = "Generate a new short-term key; they last at most 12 hours or the console session that created them" + fallback
+		return []CheckResult{result}
+	}
+
+	model := configuredModel()
+	resp, err := callWithAPIKey(env, runtimeURL, key, model)
+	if err != nil {
+		result.Code = classifyNetError(err)
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("Request with %s failed: %v", bedrockAPIKeyEnv, err)
+		result.Fix = "Check connectivity to " + runtimeURL
+		return []CheckResult{result}
+	}
+	result.attachDiagnostics(env, resp.Diag)
+
+	// AccessDenied names the key's identity, so the key itself authenticated.
+	authorized := resp.Status < 300 || resp.ErrorType == "ValidationException"
+	authenticated := authorized || resp.ErrorType == "AccessDeniedException" && strings.Contains(resp.Message, "not authorized to perform")
+	switch {
+	case resp.Status == http.StatusTooManyRequests:
+		result.Code = codeThrottled
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("%s was accepted but the request was throttled", bedrockAPIKeyEnv)
+		result.Fix = "Retry later, or request a Bedrock quota increase"
+	case !authenticated && strings.Contains(strings.ToLower(resp.Message), "expired"):
+		result.Code = codeAPIKeyExpired
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("Bedrock rejected %s as expired: %s", bedrockAPIKeyEnv, resp.Message)
+		result.Fix = "Generate a new key in the Bedrock console" + fallback
+	case !authenticated:
+		result.Code = codeAPIKeyRejected
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("Bedrock rejected %s (HTTP %d %s): %s", bedrockAPIKeyEnv, resp.Status, resp.ErrorType, resp.Message)
+		result.Fix = "Check the key is for this account and has not been revoked, and that AWS_REGION is a region it was created for" + fallback
+		if shape.Kind == "" {
+			result.Fix = "The value does not look like a Bedrock API key (bedrock-api-key-... or ABSK...); copy it again from the Bedrock console" + fallback
+		}
+	case model != "" && !authorized:
+		result.Code = codeIAMAccessDenied
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("%s is valid but may not invoke %s: %s", bedrockAPIKeyEnv, model, resp.Message)
+		result.Fix = "Grant the key's IAM user bedrock:InvokeModel and bedrock:InvokeModelWithResponseStream on the model" + fallback
+	case fallback != "":
+		result.Code = codeAuthPrecedence
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("%s %s works, and AWS credentials are also set%s", bedrockAPIKeyEnv, describeKeyScope(shape, model), fallback)
+		result.Fix = "Keep only the method you mean Claude Code to use"
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s %s works; Claude Code authenticates with it", bedrockAPIKeyEnv, describeKeyScope(shape, model))
+	}
+	return []CheckResult{result}
+}
+
+// describeKeyScope says what kind of key it is and what the check proved.
+func describeKeyScope(shape apiKeyShape, model string) string {
+	kind := "(" + firstNonEmpty(shape.Kind, "unrecognized format") + " key"
+	if !shape.Expires.IsZero() {
+		kind += fmt.Sprintf(", expires in %s", time.Until(shape.Expires).Round(time.Minute))
+	}
+	kind += ")"
+	if model != "" {
+		return kind + " for " + model
+	}
+	return kind
+}
+
+// firstNonEmpty returns the first of values that is not "".
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
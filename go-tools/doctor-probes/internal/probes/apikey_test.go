@@ -0,0 +1,198 @@
+package probes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// shortTermKey builds a short-term API key signed at signed and valid for expires.
+func shortTermKey(signed time.Time, expires time.Duration) string {
+	presigned := fmt.Sprintf("bedrock.amazonaws.com/?Action=CallWithBearerToken&X-Amz-Date=%s&X-Amz-Expires=%d&X-Amz-Signature=abc",
+		signed.UTC().Format("20060102T150405Z"), int(expires.Seconds()))
+	return shortTermKeyPrefix + base64.StdEncoding.EncodeToString([]byte(presigned))
+}
+
+var longTermKey = longTermKeyPrefix + base64.StdEncoding.EncodeToString([]byte("BedrockAPIKey-abcd-at-123456789012:c2VjcmV0"))
+
+func TestParseAPIKey(t *testing.T) {
+	signed := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		key     string
+		kind    string
+		expires time.Time
+		err     string
+	}{
+		{name: "short-term", key: shortTermKey(signed, 12*time.Hour), kind: "short-term", expires: signed.Add(12 * time.Hour)},
+		{name: "long-term", key: longTermKey, kind: "long-term"},
+		{name: "unrecognized", key: "sk-ant-abc", kind: ""},
+		{name: "trailing newline", key: longTermKey + "\n", err: "whitespace or a line break"},
+		{name: "quoted", key: `"` + longTermKey + `"`, err: "contains quotes"},
+		{name: "truncated long-term", key: longTermKeyPrefix + "abc!", err: "long-term key is not valid base64"},
+		{name: "truncated short-term", key: shortTermKeyPrefix + "%%%", err: "short-term key is not valid base64"},
+		{
+			name: "short-term without a date",
+			key:  shortTermKeyPrefix + base64.StdEncoding.EncodeToString([]byte("bedrock.amazonaws.com/?X-Amz-Expires=60")),
+			err:  "no valid X-Amz-Date",
+		},
+		{
+			name: "short-term without an expiry",
+			key:  shortTermKeyPrefix + base64.StdEncoding.EncodeToString([]byte("bedrock.amazonaws.com/?X-Amz-Date=20261016T080000Z")),
+			err:  "no valid X-Amz-Expires",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, err := parseAPIKey(tt.key)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shape.Kind != tt.kind || !shape.Expires.Equal(tt.expires) {
+				t.Errorf("shape = %+v, want %s expiring %v", shape, tt.kind, tt.expires)
+			}
+		})
+	}
+}
+
+func TestAPIKeyResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		model     string
+		sigV4     bool // AWS credentials also resolve
+		status    int
+		errorType string
+		message   string
+		want      string // status
+		code      string
+		contains  string
+		requests  int
+	}{
+		{name: "not set", key: ""},
+		{
+			name: "malformed", key: longTermKey + " ", want: "fail", code: codeAPIKeyMalformed,
+			contains: "is malformed: contains whitespace",
+		},
+		{
+			name: "expired locally", key: shortTermKey(time.Now().Add(-13*time.Hour), 12*time.Hour), want: "fail", code: codeAPIKeyExpired,
+			contains: "short-term key that expired at",
+		},
+		{
+			name: "works without a model", key: longTermKey, status: http.StatusOK, want: "pass",
+			contains: "(long-term key) works; Claude Code authenticates with it", requests: 1,
+		},
+		{
+			name: "authorized for the model", key: shortTermKey(time.Now(), 12*time.Hour), model: "anthropic.claude-3-haiku-20240307-v1:0",
+			status: http.StatusBadRequest, errorType: "ValidationException", message: "Malformed input request", want: "pass",
+			contains: "(short-term key, expires in ", requests: 1,
+		},
+		{
+			name: "AWS credentials also set", key: longTermKey, sigV4: true, status: http.StatusOK, want: "warn", code: codeAuthPrecedence,
+			contains: "rather than the AWS credentials from EnvConfigCredentials", requests: 1,
+		},
+		{
+			name: "rejected", key: longTermKey, status: http.StatusForbidden, errorType: "UnrecognizedClientException",
+			message: "The security token included in the request is invalid", want: "fail", code: codeAPIKeyRejected,
+			contains: "Bedrock rejected AWS_BEARER_TOKEN_BEDROCK (HTTP 403 UnrecognizedClientException)", requests: 1,
+		},
+		{
+			name: "rejected as expired", key: longTermKey, status: http.StatusForbidden, errorType: "AccessDeniedException",
+			message: "Bearer token has expired", want: "fail", code: codeAPIKeyExpired, contains: "rejected AWS_BEARER_TOKEN_BEDROCK as expired", requests: 1,
+		},
+		{
+			name: "not allowed to invoke", key: longTermKey, model: "anthropic.claude-3-haiku-20240307-v1:0", status: http.StatusForbidden,
+			errorType: "AccessDeniedException", message: "User: arn:aws:iam::123456789012:user/k is not authorized to perform: bedrock:InvokeModel",
+			want: "fail", code: codeIAMAccessDenied, contains: "is valid but may not invoke", requests: 1,
+		},
+		{
+			name: "throttled", key: longTermKey, status: http.StatusTooManyRequests, errorType: "ThrottlingException",
+			want: "warn", code: codeThrottled, contains: "was accepted but the request was throttled", requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, nil)
+			if !tt.sigV4 {
+				probestest.Unsetenv(t, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY")
+			}
+			t.Setenv(bedrockAPIKeyEnv, tt.key)
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			requests := 0
+			runtime := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if got := r.Header.Get("Authorization"); got != "Bearer "+tt.key {
+					t.Errorf("Authorization = %q", got)
+				}
+				wantPath := "/async-invoke"
+				if tt.model != "" {
+					wantPath = "/model/" + tt.model + "/invoke"
+				}
+				if r.URL.Path != wantPath {
+					t.Errorf("path = %s, want %s", r.URL.Path, wantPath)
+				}
+				if tt.errorType != "" {
+					w.Header().Set("x-amzn-ErrorType", tt.errorType+":http://internal.amazon.com/coral/")
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"message":%q}`, tt.message)
+			}))
+			defer runtime.Close()
+
+			got := apiKeyResults(env, "us-east-1", runtime.URL)
+			if requests != tt.requests {
+				t.Errorf("%d requests, want %d", requests, tt.requests)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.Status != tt.want || r.Code != tt.code || !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("got %s %s %q; want %s %s containing %q", r.Status, r.Code, r.Message, tt.want, tt.code, tt.contains)
+			}
+			if strings.Contains(r.Message+r.Fix, tt.key) {
+				t.Errorf("result echoes the key: %q", r.Message)
+			}
+		})
+	}
+}
+
+func TestAPIKeyResultsUnreachable(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	probestest.AWS(t, nil)
+	probestest.Unsetenv(t, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "ANTHROPIC_MODEL")
+	t.Setenv(bedrockAPIKeyEnv, longTermKey)
+	got := apiKeyResults(env, "us-east-1", "http://127.0.0.1:1")
+	if len(got) != 1 || got[0].Status != "fail" || got[0].Code != codeConnectRefused {
+		t.Errorf("results = %+v, want one fail with %s", got, codeConnectRefused)
+	}
+}
+
+func TestRedactSecretsAPIKeys(t *testing.T) {
+	for _, key := range []string{longTermKey, shortTermKey(time.Now(), time.Hour)} {
+		got := RedactSecrets("key " + key + " end")
+		if !strings.HasPrefix(got, "key [REDACTED]") || strings.Contains(got, key[len(key)-24:len(key)-4]) {
+			t.Errorf("redactSecrets left %q", got)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// runtimeURL returns the Bedrock runtime base URL the raw probes call: the
+// endpoint override, or the regional (FIPS) endpoint.
+func runtimeURL(env Env, region string, fips bool) string {
+	if env.Endpoint != nil {
+		return strings.TrimSuffix(env.Endpoint.String(), "/")
+	}
+	service := "bedrock-runtime"
+	if fips {
+		service = fipsService(service)
+	}
+	return "https://" + partitionForRegion(region).hostname(service, region)
+}
+
+// bearerAuth sends a Bedrock API key the way Claude Code does.
+func bearerAuth(token string) requestAuth {
+	return func(_ context.Context, req *http.Request, _ []byte) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// authStep is one request of an auth matrix row.
+type authStep struct {
+	Name    string // ListFoundationModels or InvokeModel
+	OK      bool
+	Code    string // error code when not OK
+	Fix     string
+	Detail  string
+	Elapsed time.Duration
+	Diag    *Diagnostics
+}
+
+func (s authStep) String() string {
+	if s.OK {
+		return fmt.Sprintf("%s %s in %s", s.Name, s.Detail, s.Elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s failed (%s) in %s: %s", s.Name, s.Code, s.Elapsed.Round(time.Millisecond), s.Detail)
+}
+
+// runAuthStep sends one request with auth. InvokeModel is sent with an
+// invalid body (see authzProbeBody), so it proves authorization at no cost.
+func runAuthStep(ctx context.Context, env Env, method, name, target string, body []byte, auth requestAuth) authStep {
+	step := authStep{Name: name}
+	start := time.Now()
+	reply, err := sendAWSProbe(ctx, env, target, body, auth)
+	step.Elapsed = time.Since(start)
+	logProbe("https", target, start, err, "auth", method)
+	if err != nil {
+		step.Code, step.Detail = classifyNetError(err), err.Error()
+		return step
+	}
+	step.Diag = reply.Diag
+	switch {
+	case reply.StatusCode < 300:
+		step.OK, step.Detail = true, fmt.Sprintf("HTTP %d", reply.StatusCode)
+	case body != nil && reply.ErrorCode == "ValidationException":
+		step.OK, step.Detail = true, "authorized"
+	default:
+		class := classifyAWSError(reply.err(), "bedrock:"+name)
+		step.Code, step.Fix = class.Code, class.Fix
+		step.Detail = strings.TrimSpace(fmt.Sprintf("HTTP %d %s", reply.StatusCode, reply.ErrorCode)) + fmt.Sprintf(" (%q)", reply.Message)
+	}
+	return step
+}
+
+// authMatrixRow runs the access check, and the invoke probe when a model is
+// configured, with auth.
+func authMatrixRow(env Env, id, name, source string, region string, fips bool, auth requestAuth) CheckResult {
+	result := CheckResult{ID: "auth_matrix." + id, Name: "Auth Matrix - " + name, Metrics: map[string]float64{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	steps := []authStep{runAuthStep(ctx, env, id, "ListFoundationModels", controlPlaneURL(env, region, fips), nil, auth)}
+	result.Metrics["list_ms"] = DurationMs(steps[0].Elapsed)
+	if model := configuredModel(); model != "" {
+		target := runtimeURL(env, region, fips) + "/model/" + url.PathEscape(model) + "/invoke"
+		step := runAuthStep(ctx, env, id, "InvokeModel", target, []byte(authzProbeBody), auth)
+		steps = append(steps, step)
+		result.Metrics["invoke_ms"] = DurationMs(step.Elapsed)
+	}
+
+	parts := make([]string, len(steps))
+	result.Status = "pass"
+	for i, s := range steps {
+		parts[i] = s.String()
+		if !s.OK && result.Status == "pass" {
+			result.Status, result.Code, result.Fix = "fail", s.Code, s.Fix
+			result.attachDiagnostics(env, s.Diag)
+		}
+	}
+	result.Message = fmt.Sprintf("%s: %s", source, strings.Join(parts, "; "))
+	if id == "api_key" && (result.Code == codeCredentialsInvalid || result.Code == codeCredentialsExpired) {
+		// The credential fixes are about access keys, not API keys.
+		result.Fix = "AWS does not accept the API key in AWS_BEARER_TOKEN_BEDROCK: short-term keys expire with the session that made them, and long-term keys can be revoked or expire. Generate a new key in the Bedrock console (API keys)"
+	}
+	if result.Status == "fail" {
+		result.Message = RedactSecrets(result.Message)
+	}
+	return result
+}
+
+// notConfiguredRow is the row of a method with no credential material.
+func notConfiguredRow(id, name, why string) CheckResult {
+	return CheckResult{ID: "auth_matrix." + id, Name: "Auth Matrix - " + name, Status: "skip", Message: "not configured: " + why}
+}
+
+// authMatrixResults runs the Bedrock access check, and an invoke probe when
+// ANTHROPIC_MODEL is set, once with SigV4 credentials and once with the
+// Bedrock API key, and names the method Claude Code uses. A method without
+// credentials is reported as not configured rather than failed.
+func authMatrixResults(ctx context.Context, env Env, region string, fips bool) []CheckResult {
+	var sigv4 CheckResult
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err == nil {
+		creds, retrieveErr := cfg.Credentials.Retrieve(ctx)
+		err = retrieveErr
+		if err == nil {
+			source := fmt.Sprintf("access key %s from %s", keySuffix(creds.AccessKeyID), creds.Source)
+			sigv4 = authMatrixRow(env, "sigv4", "SigV4", source, region, fips, sigV4Auth(creds, region))
+		}
+	}
+	cancel()
+	if err != nil {
+		class := classifyAWSError(err, "")
+		if class.Code == codeCredentialsMissing {
+			sigv4 = notConfiguredRow("sigv4", "SigV4", "no AWS credentials found")
+		} else {
+			sigv4 = CheckResult{ID: "auth_matrix.sigv4", Name: "Auth Matrix - SigV4", Status: "fail", Code: class.Code,
+				Message: fmt.Sprintf("AWS credentials are configured but cannot be resolved: %v", err), Fix: class.Fix, FixCommand: class.FixCommand}
+		}
+	}
+
+	var apiKey CheckResult
+	if token := os.Getenv(bedrockAPIKeyEnv); token != "" {
+		apiKey = authMatrixRow(env, "api_key", "API Key", "AWS_BEARER_TOKEN_BEDROCK", region, fips, bearerAuth(token))
+	} else {
+		apiKey = notConfiguredRow("api_key", "API Key", "AWS_BEARER_TOKEN_BEDROCK is not set")
+	}
+
+	return []CheckResult{sigv4, apiKey, authMatrixSummary(sigv4, apiKey)}
+}
+
+// authRowState is a row's outcome for the summary.
+func authRowState(r CheckResult) string {
+	switch r.Status {
+	case "pass":
+		return "works"
+	case "skip":
+		return "not configured"
+	}
+	return "fails with " + r.Code
+}
+
+// authMatrixSummary states which method Claude Code uses: the API key when
+// AWS_BEARER_TOKEN_BEDROCK is set, SigV4 otherwise. It fails when that
+// method does not work.
+func authMatrixSummary(sigv4, apiKey CheckResult) CheckResult {
+	result := CheckResult{ID: "auth_matrix.summary", Name: "Auth Method Used"}
+	used, other := sigv4, apiKey
+	why := "AWS_BEARER_TOKEN_BEDROCK is not set, so Claude Code signs requests with the AWS credentials"
+	if apiKey.Status != "skip" {
+		used, other = apiKey, sigv4
+		why = "AWS_BEARER_TOKEN_BEDROCK is set, so Claude Code sends the API key and ignores the AWS credentials"
+	}
+	usedName := strings.TrimPrefix(used.Name, "Auth Matrix - ")
+	otherName := strings.TrimPrefix(other.Name, "Auth Matrix - ")
+	summary := fmt.Sprintf("%s %s, %s %s", usedName, authRowState(used), otherName, authRowState(other))
+
+	switch {
+	case used.Status == "pass":
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Claude Code will use %s, which works from here: %s (%s)", usedName, why, summary)
+	case used.Status == "skip":
+		result.Status, result.Code = "fail", codeCredentialsMissing
+		result.Message = "Neither method is configured: no AWS credentials and no AWS_BEARER_TOKEN_BEDROCK"
+		result.Fix = "Configure a profile (aws configure sso) and export AWS_PROFILE, or export AWS_BEARER_TOKEN_BEDROCK"
+	default:
+		result.Status, result.Code = "fail", used.Code
+		result.Message = fmt.Sprintf("Claude Code will use %s, which fails from here: %s (%s)", usedName, why, summary)
+		result.Fix = used.Fix
+		if other.Status == "pass" {
+			// Only a set API key can shadow working SigV4 credentials.
+			result.Fix = "SigV4 works from this machine: unset AWS_BEARER_TOKEN_BEDROCK to use it, or replace the key"
+			result.FixCommand = &FixCommand{Bash: "unset AWS_BEARER_TOKEN_BEDROCK", PowerShell: "Remove-Item Env:AWS_BEARER_TOKEN_BEDROCK -ErrorAction SilentlyContinue"}
+		}
+	}
+	return result
+}
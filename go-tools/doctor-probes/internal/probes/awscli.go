@@ -0,0 +1,80 @@
+package probes
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+)
+
+// minAWSCLIVersion is the first AWS CLI release with [sso-session] support,
+// which the SSO login runbooks use.
+const minAWSCLIVersion = "2.13.0"
+
+const awsCLIInstallDocs = "https://docs.aws.amazon.com/cli/latest/userguide/getting-started-install.html"
+
+var awsCLIVersionPattern = regexp.MustCompile(`aws-cli/(\d+\.\d+\.\d+)`)
+
+// awsCLIInstall returns the install instructions for this OS.
+func awsCLIInstall() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "Install AWS CLI v2 with the macOS installer https://awscli.amazonaws.com/AWSCLIV2.pkg (or brew install awscli); see " + awsCLIInstallDocs
+	case "windows":
+		return "Install AWS CLI v2 with the Windows installer https://awscli.amazonaws.com/AWSCLIV2.msi (or winget install Amazon.AWSCLI); see " + awsCLIInstallDocs
+	default:
+		return "Install AWS CLI v2: curl -o awscliv2.zip https://awscli.amazonaws.com/awscli-exe-linux-$(uname -m).zip && unzip awscliv2.zip && sudo ./aws/install; see " + awsCLIInstallDocs
+	}
+}
+
+// awsCLIResult checks that the AWS CLI our runbooks use (aws sso login,
+// model access requests) is installed and recent enough. Without it Claude
+// Code still works, so the check is never worse than a warning.
+func awsCLIResult() CheckResult {
+	result := CheckResult{ID: "local.aws_cli", Name: "AWS CLI"}
+
+	path, err := exec.LookPath("aws")
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSCLIMissing
+		result.Message = "aws not found on PATH; the SSO login and model access steps of the setup guide need it"
+		result.Fix = awsCLIInstall()
+		return result
+	}
+	// v1 prints its version to stderr; runLocal returns both streams.
+	out, err := runLocal(path, "--version")
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSCLIMissing
+		result.Message = fmt.Sprintf("%s --version failed: %v %s", path, err, out)
+		result.Fix = "Reinstall the AWS CLI. " + awsCLIInstall()
+		return result
+	}
+	m := awsCLIVersionPattern.FindStringSubmatch(out)
+	if m == nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Could not parse %s --version output %q", path, out)
+		return result
+	}
+	v, _ := parseVersion(m[1])
+	minimum, _ := parseVersion(minAWSCLIVersion)
+
+	result.Message = fmt.Sprintf("AWS CLI %s at %s", m[1], path)
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		if cliRegion, err := runLocal(path, "configure", "get", "region"); err == nil && cliRegion != "" && cliRegion != region {
+			result.Message += fmt.Sprintf("; its configured default region is %s, not AWS_REGION (%s), so aws commands run where AWS_REGION is unset look in %s", cliRegion, region, cliRegion)
+		}
+	}
+	switch {
+	case v[0] < 2:
+		result.Status, result.Code = "warn", codeAWSCLIOutdated
+		result.Message = fmt.Sprintf("AWS CLI v1 (%s) cannot run aws sso login with SSO sessions; %s", m[1], result.Message)
+		result.Fix = "Uninstall AWS CLI v1 (pip uninstall awscli) and install v2. " + awsCLIInstall()
+	case versionLess(v, minimum):
+		result.Status, result.Code = "warn", codeAWSCLIOutdated
+		result.Message = fmt.Sprintf("AWS CLI %s is older than %s, the first with [sso-session] support; %s", m[1], minAWSCLIVersion, result.Message)
+		result.Fix = "Update the AWS CLI. " + awsCLIInstall()
+	default:
+		result.Status = "pass"
+	}
+	return result
+}
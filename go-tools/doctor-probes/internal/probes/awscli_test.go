@@ -0,0 +1,84 @@
+package probes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAWSCLIResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string // body of the fake aws; empty means none on PATH
+		region   string // AWS_REGION
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "missing",
+			status:   "warn",
+			code:     codeAWSCLIMissing,
+			contains: []string{"aws not found on PATH", "Install AWS CLI v2"},
+		},
+		{
+			name:     "current",
+			script:   `echo "aws-cli/2.15.30 Python/3.11.8 Linux/6.5 exe/x86_64"`,
+			status:   "pass",
+			contains: []string{"AWS CLI 2.15.30 at "},
+		},
+		{
+			name:     "v1 prints to stderr",
+			script:   `echo "aws-cli/1.29.0 Python/3.9 Linux/6.5 botocore/1.31.0" >&2`,
+			status:   "warn",
+			code:     codeAWSCLIOutdated,
+			contains: []string{"AWS CLI v1 (1.29.0) cannot run aws sso login", "pip uninstall awscli"},
+		},
+		{
+			name:     "before sso-session",
+			script:   `echo "aws-cli/2.9.1 Python/3.9"`,
+			status:   "warn",
+			code:     codeAWSCLIOutdated,
+			contains: []string{"AWS CLI 2.9.1 is older than 2.13.0", "Update the AWS CLI"},
+		},
+		{
+			name:     "broken",
+			script:   `echo "error while loading shared libraries" >&2; exit 127`,
+			status:   "warn",
+			code:     codeAWSCLIMissing,
+			contains: []string{"--version failed", "error while loading shared libraries", "Reinstall the AWS CLI"},
+		},
+		{
+			name:   "unparsable",
+			script: `echo "something else"`,
+			status: "warn",
+		},
+		{
+			name:     "default region differs",
+			script:   `if [ "$1" = configure ]; then echo eu-west-1; else echo "aws-cli/2.15.30 Python/3.11.8"; fi`,
+			region:   "us-east-1",
+			status:   "pass",
+			contains: []string{"its configured default region is eu-west-1, not AWS_REGION (us-east-1)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripts := map[string]string{}
+			if tt.script != "" {
+				scripts["aws"] = tt.script
+			}
+			fakePath(t, scripts)
+			t.Setenv("AWS_REGION", tt.region)
+
+			r := awsCLIResult()
+			if r.ID != "local.aws_cli" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package probes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsConfigCache holds the configs an Env loaded, per profile and region, so
+// repeated runs (watch mode) reuse the same credential cache instead of
+// re-resolving the chain every time.
+type awsConfigCache struct {
+	mu      sync.Mutex
+	configs map[string]aws.Config
+}
+
+func newAWSConfigCache() *awsConfigCache {
+	return &awsConfigCache{configs: make(map[string]aws.Config)}
+}
+
+func (env Env) loadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	cache := env.awsConfigs
+	if cache == nil {
+		cache = newAWSConfigCache()
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key := ActiveProfile() + "@" + region
+	if cfg, ok := cache.configs[key]; ok {
+		return cfg, nil
+	}
+
+	optFns := append([]func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithRetryer(sdkRetryer),
+	}, env.AWSOptions...)
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	// The SDK adds AWS_CA_BUNDLE (or ca_bundle) only to its own client type,
+	// so the client goes through the engine once loading is done. Credential
+	// providers keep the client they were built with.
+	cfg = env.Engine.limitedConfig(cfg)
+	cache.configs[key] = cfg
+	return cfg, nil
+}
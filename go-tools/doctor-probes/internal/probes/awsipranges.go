@@ -0,0 +1,190 @@
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// awsIPRangesURL publishes every public AWS address range.
+const awsIPRangesURL = "https://ip-ranges.amazonaws.com/ip-ranges.json"
+
+// awsIPRange is one published prefix. Service is AMAZON for the catch-all
+// ranges and a service name (EC2, CLOUDFRONT, ...) for the rest.
+type awsIPRange struct {
+	Prefix  netip.Prefix
+	Region  string
+	Service string
+}
+
+func (r awsIPRange) String() string {
+	return fmt.Sprintf("%s %s %s", r.Service, r.Region, r.Prefix)
+}
+
+// ipRangesMaxAge is how long a cached ip-ranges.json is used before it is
+// downloaded again. AWS publishes changes several times a week.
+const ipRangesMaxAge = 24 * time.Hour
+
+var (
+	awsIPRangesOnce sync.Once
+	awsIPRangesList []awsIPRange
+	awsIPRangesErr  error
+)
+
+// awsIPRanges loads the published ranges once per process: from
+// --ip-ranges-file, from the cache in ~/.bcce when it is fresh, or else
+// downloaded through the proxy from the environment and cached. A stale
+// cache is used when the download fails.
+func awsIPRanges(env Env) ([]awsIPRange, error) {
+	awsIPRangesOnce.Do(func() {
+		awsIPRangesList, awsIPRangesErr = loadAWSIPRanges(env)
+	})
+	return awsIPRangesList, awsIPRangesErr
+}
+
+func loadAWSIPRanges(env Env) ([]awsIPRange, error) {
+	if env.IPRangesFile != "" {
+		data, err := os.ReadFile(env.IPRangesFile)
+		if err != nil {
+			return nil, err
+		}
+		return parseAWSIPRanges(env.IPRangesFile, data)
+	}
+	cache := ipRangesCachePath()
+	var cached []byte
+	if cache != "" {
+		if info, err := os.Stat(cache); err == nil {
+			cached, _ = os.ReadFile(cache)
+			if cached != nil && time.Since(info.ModTime()) < ipRangesMaxAge {
+				return parseAWSIPRanges(cache, cached)
+			}
+		}
+	}
+	data, err := fetchAWSIPRanges(env)
+	if err != nil {
+		if cached != nil {
+			debugLog.Debug("using stale ip-ranges cache", "path", cache, "error", err.Error())
+			return parseAWSIPRanges(cache, cached)
+		}
+		return nil, err
+	}
+	ranges, err := parseAWSIPRanges(awsIPRangesURL, data)
+	if err == nil && cache != "" {
+		writeIPRangesCache(cache, data)
+	}
+	return ranges, err
+}
+
+// ipRangesCachePath is ~/.bcce/ip-ranges.json, or "" without a home
+// directory.
+func ipRangesCachePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bcce", "ip-ranges.json")
+}
+
+// writeIPRangesCache replaces the cache atomically; failing to cache is not
+// an error.
+func writeIPRangesCache(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ip-ranges-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		debugLog.Debug("could not cache ip-ranges", "path", path, "error", err.Error())
+	}
+}
+
+func fetchAWSIPRanges(env Env) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, awsIPRangesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := env.ProxyHTTP.Do(req)
+	logProbe("ip_ranges", awsIPRangesURL, start, err)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered HTTP %d", awsIPRangesURL, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseAWSIPRanges parses ip-ranges.json read from source.
+func parseAWSIPRanges(source string, data []byte) ([]awsIPRange, error) {
+	var doc struct {
+		Prefixes []struct {
+			Prefix  string `json:"ip_prefix"`
+			Region  string `json:"region"`
+			Service string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			Prefix  string `json:"ipv6_prefix"`
+			Region  string `json:"region"`
+			Service string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	var ranges []awsIPRange
+	add := func(prefix, region, service string) {
+		if p, err := netip.ParsePrefix(prefix); err == nil {
+			ranges = append(ranges, awsIPRange{Prefix: p, Region: region, Service: service})
+		}
+	}
+	for _, p := range doc.Prefixes {
+		add(p.Prefix, p.Region, p.Service)
+	}
+	for _, p := range doc.IPv6Prefixes {
+		add(p.Prefix, p.Region, p.Service)
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("%s lists no prefixes", source)
+	}
+	return ranges, nil
+}
+
+// awsRangeFor returns the most specific published range containing ip,
+// preferring a named service over the AMAZON catch-all of the same size.
+func awsRangeFor(ranges []awsIPRange, ip netip.Addr) (awsIPRange, bool) {
+	ip = ip.Unmap()
+	var best awsIPRange
+	found := false
+	for _, r := range ranges {
+		if !r.Prefix.Contains(ip) {
+			continue
+		}
+		better := r.Prefix.Bits() > best.Prefix.Bits() ||
+			(r.Prefix.Bits() == best.Prefix.Bits() && best.Service == "AMAZON" && r.Service != "AMAZON")
+		if !found || better {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
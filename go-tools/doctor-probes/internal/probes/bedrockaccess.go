@@ -0,0 +1,60 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// configuredModel returns the model Claude Code is configured to use.
+func configuredModel() string {
+	return os.Getenv("ANTHROPIC_MODEL")
+}
+
+// checkBedrockAccess also returns the Anthropic models offered in region.
+func checkBedrockAccess(ctx context.Context, env Env, region string) ([]bedrocktypes.FoundationModelSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Minimal dry-run: list foundation models (read-only operation)
+	return AnthropicModels(ctx, env, cfg)
+}
+
+// bedrockAccessResults makes a signed ListFoundationModels call and, when it
+// succeeds, checks the configured model's access grant.
+func bedrockAccessResults(ctx context.Context, env Env, region string) []CheckResult {
+	models, err := checkBedrockAccess(ctx, env, region)
+	if err != nil {
+		result := CheckResult{
+			ID:      "bedrock.api_access",
+			Name:    "Bedrock API Access",
+			Status:  "fail",
+			Message: err.Error(),
+			Fix:     "Check AWS credentials and IAM permissions for bedrock:ListFoundationModels",
+		}
+		if errors.Is(err, errNoModels) {
+			result.Status, result.Code = "warn", codeNoModels
+			result.Fix = "Request access to Anthropic models in AWS Bedrock console"
+		} else {
+			result.setAWSError(err, "bedrock:ListFoundationModels")
+		}
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return []CheckResult{result}
+	}
+
+	return []CheckResult{{
+		ID:      "bedrock.api_access",
+		Name:    "Bedrock API Access",
+		Status:  "pass",
+		Message: fmt.Sprintf("Authenticated access: signed ListFoundationModels call succeeded in %s", region),
+	}, modelAccessResult(env, region, models)}
+}
@@ -0,0 +1,283 @@
+package probes
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// MaxBenchmarkRuns caps --benchmark-runs; every run is a billed request.
+const MaxBenchmarkRuns = 20
+
+// benchmarkPrompt is the fixed prompt of every run: about 200 input tokens,
+// asking for about 200 output tokens.
+const benchmarkPrompt = `Read the following passage and summarize it in exactly five sentences.
+
+Network paths between developer machines and cloud services are rarely direct. A request may leave a laptop through a VPN client, pass a corporate proxy that inspects TLS, cross a secure web gateway that applies data loss prevention rules, and only then reach the public internet or a private interconnect. Each of these hops can add latency, buffer streamed responses, or rewrite headers. When an interactive tool feels slow, the cause is often one of these intermediaries rather than the service itself. Measuring the time to the first streamed token separates connection and queueing delays from generation speed, while the rate of output tokens shows whether the response is being delivered as it is produced. Comparing these numbers across regions and inference profiles helps teams choose the configuration that gives their users the most responsive experience.`
+
+// benchmarkMaxTokens bounds the output of each run.
+const benchmarkMaxTokens = 256
+
+var benchmarkBody = func() []byte {
+	body, _ := json.Marshal(map[string]any{
+		"anthropic_version": "bedrock-2023-05-31",
+		"max_tokens":        benchmarkMaxTokens,
+		"messages":          []map[string]string{{"role": "user", "content": benchmarkPrompt}},
+	})
+	return body
+}()
+
+//go:embed data/benchmark_baselines.json
+var benchmarkBaselineData []byte
+
+// benchmarkBaseline is the expected performance of a Claude model family,
+// used to flag anomalies rather than to grade.
+type benchmarkBaseline struct {
+	Family          string  `json:"family"` // haiku, sonnet, or opus
+	TTFTMs          float64 `json:"ttft_ms"`
+	TokensPerSecond float64 `json:"tokens_per_second"`
+	Note            string  `json:"note"`
+}
+
+// benchmarkBaselines is the embedded baseline table; a malformed entry is a
+// build defect and panics at startup.
+var benchmarkBaselines = func() []benchmarkBaseline {
+	var baselines []benchmarkBaseline
+	if err := json.Unmarshal(benchmarkBaselineData, &baselines); err != nil {
+		panic(fmt.Sprintf("benchmark_baselines.json: %v", err))
+	}
+	return baselines
+}()
+
+func baselineFor(modelID string) *benchmarkBaseline {
+	tier, _ := claudeFamily(modelID)
+	for i := range benchmarkBaselines {
+		if benchmarkBaselines[i].Family == tier {
+			return &benchmarkBaselines[i]
+		}
+	}
+	return nil
+}
+
+// Anomaly thresholds against the baseline.
+const (
+	benchmarkSlowTTFTFactor       = 2.0 // median TTFT above twice the baseline
+	benchmarkSlowThroughputFactor = 0.5 // median tokens/s below half the baseline
+)
+
+// BenchmarkRun is one streaming invocation of the benchmark, as reported in
+// the JSON output.
+type BenchmarkRun struct {
+	Run             int     `json:"run"`
+	TTFTMs          float64 `json:"ttft_ms,omitempty"` // request sent to first text delta
+	TotalMs         float64 `json:"total_ms"`
+	InputTokens     int     `json:"input_tokens,omitempty"`
+	OutputTokens    int     `json:"output_tokens,omitempty"`
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"` // output tokens after the first, per second
+	Throttled       bool    `json:"throttled,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// benchmarkEvent is the part of a streamed Messages API event the benchmark
+// reads.
+type benchmarkEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Metrics *struct {
+		InputTokenCount  int `json:"inputTokenCount"`
+		OutputTokenCount int `json:"outputTokenCount"`
+	} `json:"amazon-bedrock-invocationMetrics"`
+}
+
+// benchmarkRun streams one response and times the first text delta and the
+// end of the stream.
+func benchmarkRun(ctx context.Context, client BedrockRuntimeClient, modelID string, n int) (BenchmarkRun, error) {
+	run := BenchmarkRun{Run: n}
+	start := time.Now()
+	out, err := client.InvokeModelWithResponseStream(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        benchmarkBody,
+	})
+	if err == nil {
+		stream := out.GetStream()
+		var first time.Duration
+		for event := range stream.Events() {
+			chunk, ok := event.(*brtypes.ResponseStreamMemberChunk)
+			if !ok {
+				continue
+			}
+			var ev benchmarkEvent
+			if json.Unmarshal(chunk.Value.Bytes, &ev) != nil {
+				continue
+			}
+			switch ev.Type {
+			case "message_start":
+				run.InputTokens = ev.Message.Usage.InputTokens
+			case "content_block_delta":
+				if first == 0 {
+					first = time.Since(start)
+				}
+			case "message_delta":
+				run.OutputTokens = ev.Usage.OutputTokens
+			}
+			if ev.Metrics != nil && run.OutputTokens == 0 {
+				run.InputTokens, run.OutputTokens = ev.Metrics.InputTokenCount, ev.Metrics.OutputTokenCount
+			}
+		}
+		err = stream.Err()
+		stream.Close()
+		total := time.Since(start)
+		run.TTFTMs, run.TotalMs = DurationMs(first), DurationMs(total)
+		if first > 0 && total > first && run.OutputTokens > 1 {
+			run.TokensPerSecond = float64(run.OutputTokens-1) / (total - first).Seconds()
+		}
+	} else {
+		run.TotalMs = DurationMs(time.Since(start))
+	}
+	if err != nil {
+		run.Error = err.Error()
+		run.Throttled = isThrottlingError(err)
+	}
+	logProbe("benchmark", modelID, start, err, "run", n, "ttft_ms", run.TTFTMs, "output_tokens", run.OutputTokens, "throttled", run.Throttled)
+	return run, err
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of values by the
+// nearest-rank method, or 0 for no values.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[max(int(math.Ceil(p*float64(len(sorted))))-1, 0)]
+}
+
+// benchmarkResult streams the fixed prompt to the configured model runs
+// times, one after another, and reports median and p90 time to first token,
+// output tokens per second, and total latency against the model family's
+// baseline. SDK retries are off, so throttling shows up as a finding instead
+// of as latency. It is never worse than a warning.
+func benchmarkResult(ctx context.Context, env Env, region string, runs int) CheckResult {
+	costNote := fmt.Sprintf("%d streaming requests of ~200 input and up to %d output tokens, which incur a charge", runs, benchmarkMaxTokens)
+	result := CheckResult{ID: "bedrock.benchmark", Name: "Bedrock Benchmark"}
+
+	model := configuredModel()
+	if model == "" {
+		result.Status, result.Code = "warn", codeModelUnset
+		result.Message = "ANTHROPIC_MODEL not set; no model to benchmark"
+		result.Fix = "export ANTHROPIC_MODEL=<model-or-inference-profile-id>"
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(runs)*90*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return result
+	}
+	client := env.BedrockRuntime(cfg, func(o *bedrockruntime.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+
+	var ttft, throughput, total []float64
+	throttled := 0
+	var lastErr error
+	for i := 1; i <= runs; i++ {
+		run, err := benchmarkRun(ctx, client, model, i)
+		result.Benchmark = append(result.Benchmark, run)
+		switch {
+		case run.Throttled:
+			throttled++
+		case err != nil:
+			lastErr = err
+		default:
+			ttft = append(ttft, run.TTFTMs)
+			throughput = append(throughput, run.TokensPerSecond)
+			total = append(total, run.TotalMs)
+		}
+		if err != nil && !run.Throttled {
+			// Access and validation errors repeat on every run.
+			break
+		}
+	}
+
+	result.Metrics = map[string]float64{
+		"runs":      float64(len(result.Benchmark)),
+		"completed": float64(len(total)),
+		"throttled": float64(throttled),
+	}
+	if lastErr != nil && len(total) == 0 {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Benchmark of %s failed: %v", model, lastErr)
+		result.Fix = "Add bedrock:InvokeModelWithResponseStream permission for the model"
+		result.setAWSError(lastErr, "bedrock:InvokeModelWithResponseStream on "+model)
+		result.attachDiagnostics(env, awsDiagnostics(lastErr))
+		return result
+	}
+	if len(total) == 0 {
+		result.Status, result.Code = "warn", codeBenchmarkThrottled
+		result.Message = fmt.Sprintf("All %d benchmark runs of %s were throttled; %s", throttled, model, costNote)
+		result.Fix = "Throttling at one request at a time means the model's requests- or tokens-per-minute quota is exhausted or very low; see Bedrock Service Quotas and request an increase, or use a cross-region inference profile"
+		return result
+	}
+
+	for name, values := range map[string][]float64{"ttft_ms": ttft, "tokens_per_second": throughput, "total_ms": total} {
+		result.Metrics[name+"_p50"] = percentile(values, 0.5)
+		result.Metrics[name+"_p90"] = percentile(values, 0.9)
+	}
+	m := result.Metrics
+	summary := fmt.Sprintf("%d of %d runs of %s completed: time to first token %s median (p90 %s), %.0f tokens/s median (p90 %.0f), total %s median (p90 %s)",
+		len(total), len(result.Benchmark), model,
+		FormatSeconds(m["ttft_ms_p50"]), FormatSeconds(m["ttft_ms_p90"]),
+		m["tokens_per_second_p50"], m["tokens_per_second_p90"],
+		FormatSeconds(m["total_ms_p50"]), FormatSeconds(m["total_ms_p90"]))
+
+	var anomalies []string
+	if baseline := baselineFor(model); baseline != nil {
+		summary += fmt.Sprintf("; expected for %s: ~%s to first token, ~%.0f tokens/s (%s)", baseline.Family, FormatSeconds(baseline.TTFTMs), baseline.TokensPerSecond, baseline.Note)
+		if m["ttft_ms_p50"] > benchmarkSlowTTFTFactor*baseline.TTFTMs {
+			anomalies = append(anomalies, fmt.Sprintf("time to first token is over %.0fx the baseline", benchmarkSlowTTFTFactor))
+		}
+		if m["tokens_per_second_p50"] < benchmarkSlowThroughputFactor*baseline.TokensPerSecond {
+			anomalies = append(anomalies, fmt.Sprintf("output is under %.0f%% of the baseline rate", 100*benchmarkSlowThroughputFactor))
+		}
+	} else {
+		summary += "; no baseline for this model family"
+	}
+
+	switch {
+	case throttled > 0:
+		result.Status, result.Code = "warn", codeBenchmarkThrottled
+		result.Message = fmt.Sprintf("%d of %d benchmark runs were throttled (not retried); %s", throttled, len(result.Benchmark), summary)
+		result.Fix = "Throttling at one request at a time means the model's requests- or tokens-per-minute quota is low or shared; see Bedrock Service Quotas and request an increase, or use a cross-region inference profile"
+	case len(anomalies) > 0:
+		result.Status, result.Code = "warn", codeBenchmarkSlow
+		result.Message = fmt.Sprintf("Slower than expected: %s; %s", strings.Join(anomalies, " and "), summary)
+		result.Fix = "Benchmark other regions (--region) or a cross-region inference profile (us., eu., apac., global.). A slow first token with normal throughput points at the network path or a proxy (see HTTPS Connectivity); low throughput with a normal first token points at a buffering gateway (see --stream-probe)"
+	default:
+		result.Status = "pass"
+		result.Message = summary
+	}
+	result.Message += "; " + costNote
+	return result
+}
@@ -0,0 +1,227 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{40, 10, 30, 20, 50}
+	tests := []struct {
+		p    float64
+		want float64
+	}{{0.5, 30}, {0.9, 50}, {0.2, 10}, {1, 50}}
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of nothing = %v, want 0", got)
+	}
+}
+
+func TestBaselineFor(t *testing.T) {
+	tests := map[string]string{
+		"us.anthropic.claude-3-5-haiku-20241022-v1:0": "haiku",
+		"anthropic.claude-sonnet-4-20250514-v1:0":     "sonnet",
+		"anthropic.claude-opus-4-1-20250805-v1:0":     "opus",
+		"anthropic.claude-instant-v1":                 "",
+	}
+	for model, want := range tests {
+		b := baselineFor(model)
+		got := ""
+		if b != nil {
+			got = b.Family
+		}
+		if got != want || (b != nil && (b.TTFTMs <= 0 || b.TokensPerSecond <= 0)) {
+			t.Errorf("baselineFor(%s) = %+v, want %q", model, b, want)
+		}
+	}
+}
+
+// eventStreamChunk frames a Messages API event as a Bedrock response stream
+// chunk in the AWS event stream encoding.
+func eventStreamChunk(event string) []byte {
+	payload := []byte(`{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(event)) + `"}`)
+	var headers bytes.Buffer
+	for _, h := range [][2]string{{":event-type", "chunk"}, {":content-type", "application/json"}, {":message-type", "event"}} {
+		headers.WriteByte(byte(len(h[0])))
+		headers.WriteString(h[0])
+		headers.WriteByte(7) // string value
+		binary.Write(&headers, binary.BigEndian, uint16(len(h[1])))
+		headers.WriteString(h[1])
+	}
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, uint32(12+headers.Len()+len(payload)+4))
+	binary.Write(&msg, binary.BigEndian, uint32(headers.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(headers.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
+
+// benchmarkStream answers InvokeModelWithResponseStream with a response of
+// outputTokens tokens whose first delta arrives at once and whose end
+// arrives after gap.
+func benchmarkStream(w http.ResponseWriter, outputTokens string, gap time.Duration) {
+	w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+	w.Header().Set("x-amzn-RequestId", "r-1")
+	for _, event := range []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":212}}}`,
+		`{"type":"content_block_delta","delta":{"type":"text_delta","text":"One."}}`,
+	} {
+		w.Write(eventStreamChunk(event))
+	}
+	w.(http.Flusher).Flush()
+	time.Sleep(gap)
+	w.Write(eventStreamChunk(`{"type":"message_delta","usage":{"output_tokens":` + outputTokens + `}}`))
+	w.Write(eventStreamChunk(`{"type":"message_stop"}`))
+}
+
+func TestBenchmarkResult(t *testing.T) {
+	const haiku = "anthropic.claude-3-5-haiku-20241022-v1:0"
+	tests := []struct {
+		name      string
+		model     string
+		runs      int
+		answers   []string // per request: ok, slow, throttle, or deny; the last repeats
+		status    string
+		code      string
+		contains  []string
+		requests  int
+		completed float64
+	}{
+		{
+			name:     "no model",
+			runs:     3,
+			status:   "warn",
+			code:     codeModelUnset,
+			contains: []string{"ANTHROPIC_MODEL not set"},
+		},
+		{
+			name:      "as expected",
+			model:     haiku,
+			runs:      3,
+			answers:   []string{"ok"},
+			status:    "pass",
+			contains:  []string{"3 of 3 runs of " + haiku + " completed: time to first token ", "expected for haiku: ~0.7s to first token, ~90 tokens/s", "3 streaming requests of ~200 input and up to 256 output tokens, which incur a charge"},
+			requests:  3,
+			completed: 3,
+		},
+		{
+			name:      "slow output",
+			model:     haiku,
+			runs:      1,
+			answers:   []string{"slow"},
+			status:    "warn",
+			code:      codeBenchmarkSlow,
+			contains:  []string{"Slower than expected: output is under 50% of the baseline rate", "--stream-probe"},
+			requests:  1,
+			completed: 1,
+		},
+		{
+			name:      "no baseline",
+			model:     "anthropic.claude-instant-v1",
+			runs:      1,
+			answers:   []string{"slow"},
+			status:    "pass",
+			contains:  []string{"no baseline for this model family"},
+			requests:  1,
+			completed: 1,
+		},
+		{
+			name:      "some throttled",
+			model:     haiku,
+			runs:      2,
+			answers:   []string{"throttle", "ok"},
+			status:    "warn",
+			code:      codeBenchmarkThrottled,
+			contains:  []string{"1 of 2 benchmark runs were throttled (not retried)", "1 of 2 runs of " + haiku + " completed"},
+			requests:  2,
+			completed: 1,
+		},
+		{
+			name:     "all throttled",
+			model:    haiku,
+			runs:     2,
+			answers:  []string{"throttle"},
+			status:   "warn",
+			code:     codeBenchmarkThrottled,
+			contains: []string{"All 2 benchmark runs of " + haiku + " were throttled"},
+			requests: 2,
+		},
+		{
+			name:     "denied stops after one run",
+			model:    haiku,
+			runs:     3,
+			answers:  []string{"deny"},
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Benchmark of " + haiku + " failed", "bedrock:InvokeModelWithResponseStream"},
+			requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			var (
+				mu       sync.Mutex
+				requests int
+			)
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/invoke-with-response-stream") {
+					t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+					return
+				}
+				mu.Lock()
+				answer := tt.answers[min(requests, len(tt.answers)-1)]
+				requests++
+				mu.Unlock()
+				switch answer {
+				case "ok":
+					benchmarkStream(w, "200", 0)
+				case "slow":
+					benchmarkStream(w, "2", 100*time.Millisecond)
+				case "throttle":
+					probestest.AWSJSONError(w, http.StatusTooManyRequests, "ThrottlingException", "Too many requests, please wait before trying again.")
+				default:
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+				}
+			})
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			r := benchmarkResult(context.Background(), env, "us-east-1", tt.runs)
+			if r.ID != "bedrock.benchmark" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if requests != tt.requests || len(r.Benchmark) != tt.requests || r.Metrics["completed"] != tt.completed {
+				t.Errorf("%d requests, %d runs reported, %v completed; want %d, %d, %v", requests, len(r.Benchmark), r.Metrics["completed"], tt.requests, tt.requests, tt.completed)
+			}
+			for _, run := range r.Benchmark {
+				if run.Error == "" && (run.InputTokens != 212 || run.TTFTMs <= 0 || run.TokensPerSecond <= 0) {
+					t.Errorf("run %+v, want input tokens, time to first token, and throughput", run)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package probes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateDetails describes a served certificate chain for support.
+type CertificateDetails struct {
+	Subject   string    `json:"subject"`
+	SANs      []string  `json:"sans"`
+	Issuers   []string  `json:"issuers"` // issuer of each served certificate, leaf first
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+func (c *CertificateDetails) String() string {
+	return fmt.Sprintf("SANs %s; issuers %s", strings.Join(c.SANs, ", "), strings.Join(c.Issuers, " <- "))
+}
+
+func certificateDetails(chain []*x509.Certificate) *CertificateDetails {
+	leaf := chain[0]
+	d := &CertificateDetails{
+		Subject:   leaf.Subject.String(),
+		SANs:      append([]string(nil), leaf.DNSNames...),
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+	}
+	for _, ip := range leaf.IPAddresses {
+		d.SANs = append(d.SANs, ip.String())
+	}
+	for _, cert := range chain {
+		d.Issuers = append(d.Issuers, cert.Issuer.String())
+	}
+	return d
+}
+
+// amazonRoot reports whether a served chain whose last certificate is last
+// terminates at one of the roots AWS endpoints chain to (Amazon Root CA 1-4,
+// or the Starfield roots they are cross-signed by).
+func amazonRoot(last *x509.Certificate) bool {
+	for _, org := range last.Issuer.Organization {
+		if strings.Contains(org, "Amazon") || strings.Contains(org, "Starfield Technologies") {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateResult inspects the certificate served for the Bedrock runtime
+// host on Claude Code's route: its validity window, whether its SANs cover
+// the host, and whether the chain ends at an Amazon root. Captive portals and
+// DNS-hijacking middleboxes fail the second; TLS inspection fails the third.
+func certificateResult(env Env, bedrockURL string) CheckResult {
+	result := CheckResult{ID: "tls.certificate", Name: "Certificate Validation"}
+
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+	// Verified below, one property at a time.
+	start := time.Now()
+	state, err := tlsHandshake(env, addr, proxy, &tls.Config{InsecureSkipVerify: true})
+	logProbe("certificate", addr, start, err)
+	if err != nil {
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("TLS handshake with %s failed: %v", addr, err)
+		result.Fix = "See HTTPS Connectivity"
+		return result
+	}
+	chain := state.PeerCertificates
+	if len(chain) == 0 {
+		result.Status, result.Code = "fail", codeTLSFailure
+		result.Message = fmt.Sprintf("%s presented no certificate", addr)
+		return result
+	}
+	leaf, last := chain[0], chain[len(chain)-1]
+	details := certificateDetails(chain)
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, verifyErr := leaf.Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates})
+
+	now := time.Now()
+	switch {
+	case now.Before(leaf.NotBefore) || now.After(leaf.NotAfter):
+		result.Status, result.Code = "fail", codeCertClockSkew
+		result.Message = fmt.Sprintf("The certificate for %s is valid from %s to %s, but this system's clock reads %s",
+			host, leaf.NotBefore.UTC().Format(time.DateTime), leaf.NotAfter.UTC().Format(time.DateTime), now.UTC().Format(time.DateTime))
+		result.Fix = "AWS renews its certificates long before they expire, so the system clock is almost certainly wrong; it also breaks SigV4 signing. Enable time synchronization (timedatectl set-ntp true, w32tm /resync, or Date & Time settings)"
+	case leaf.VerifyHostname(host) != nil:
+		result.Status, result.Code = "fail", codeCertNameMismatch
+		result.Message = fmt.Sprintf("The certificate served for %s is for %s, issued by %s", host, strings.Join(details.SANs, ", "), issuerName(leaf))
+		result.Fix = "Something other than AWS answered for this host: usually a captive portal (sign in to the network's login page) or a DNS server or middlebox that redirects AWS traffic. Check DNS - Bedrock Runtime and the resolver comparison"
+	case !amazonRoot(last) && verifyErr != nil:
+		result.Status, result.Code = "fail", codeCertUnexpectedRoot
+		result.Message = fmt.Sprintf("The certificate chain for %s ends at %s, which is neither an Amazon root nor trusted by this system: %v", host, last.Issuer, verifyErr)
+		result.Fix = "A TLS-intercepting device is in the path and its root CA is not installed. See TLS Inspection, or ask IT for the root CA and set NODE_EXTRA_CA_CERTS to it"
+	case !amazonRoot(last):
+		result.Status, result.Code = "warn", codeCertUnexpectedRoot
+		result.Message = fmt.Sprintf("The certificate chain for %s ends at %s, not an Amazon root; this system trusts it", host, last.Issuer)
+		result.Fix = "TLS to AWS is intercepted by a device whose root CA is installed locally. See TLS Inspection"
+	case verifyErr != nil:
+		result.Status, result.Code = "fail", codeTLSFailure
+		result.Message = fmt.Sprintf("The certificate chain for %s ends at %s, but this system does not trust it: %v", host, last.Issuer, verifyErr)
+		result.Fix = "The system CA bundle is missing the Amazon roots; update it (ca-certificates package, or OS updates)"
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Certificate for %s is valid until %s and chains to %s", host, leaf.NotAfter.UTC().Format(time.DateOnly), issuerName(last))
+	}
+	result.attachDiagnostics(env, &Diagnostics{Certificate: details})
+	return result
+}
@@ -0,0 +1,169 @@
+package probes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// certServer starts a TLS server for 127.0.0.1 whose leaf certificate is
+// issued by a fresh CA of organization org; edit adjusts the leaf template.
+func certServer(t *testing.T, org string, edit func(*x509.Certificate)) string {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{org}, CommonName: org + " Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ = x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if edit != nil {
+		edit(leaf)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestAmazonRoot(t *testing.T) {
+	tests := map[string]bool{
+		"Amazon":                           true,
+		"Starfield Technologies, Inc.":     true,
+		"Zscaler Inc.":                     false,
+		"Amazon Web Services Impersonator": true,
+		"":                                 false,
+	}
+	for org, want := range tests {
+		cert := &x509.Certificate{Issuer: pkix.Name{Organization: []string{org}}}
+		if got := amazonRoot(cert); got != want {
+			t.Errorf("amazonRoot(O=%s) = %v, want %v", org, got, want)
+		}
+	}
+}
+
+func TestCertificateResult(t *testing.T) {
+	withProxyEnv(t, nil)
+	tests := []struct {
+		name     string
+		url      string
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "expired",
+			url:      certServer(t, "Amazon", func(c *x509.Certificate) { c.NotAfter = time.Now().Add(-time.Minute) }),
+			status:   "fail",
+			code:     codeCertClockSkew,
+			contains: []string{"The certificate for 127.0.0.1 is valid from", "but this system's clock reads", "time synchronization"},
+		},
+		{
+			name: "name mismatch",
+			url: certServer(t, "Captive Portal", func(c *x509.Certificate) {
+				c.IPAddresses = nil
+				c.DNSNames = []string{"login.portal.example"}
+			}),
+			status:   "fail",
+			code:     codeCertNameMismatch,
+			contains: []string{"served for 127.0.0.1 is for login.portal.example, issued by Captive Portal Root", "captive portal"},
+		},
+		{
+			name:     "untrusted interceptor root",
+			url:      certServer(t, "Corp Inspection", nil),
+			status:   "fail",
+			code:     codeCertUnexpectedRoot,
+			contains: []string{"ends at CN=Corp Inspection Root,O=Corp Inspection, which is neither an Amazon root nor trusted", "NODE_EXTRA_CA_CERTS"},
+		},
+		{
+			name:     "Amazon root missing from the system store",
+			url:      certServer(t, "Amazon", nil),
+			status:   "fail",
+			code:     codeTLSFailure,
+			contains: []string{"ends at CN=Amazon Root,O=Amazon, but this system does not trust it", "CA bundle"},
+		},
+		{
+			name:     "refused",
+			url:      "https://127.0.0.1:1",
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"TLS handshake with 127.0.0.1:1 failed", "See HTTPS Connectivity"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			got := certificateResult(env, tt.url)
+			if got.ID != "tls.certificate" || got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", got.ID, got.Status, got.Code, got.Message, tt.status, tt.code)
+			}
+			text := got.Message + " " + got.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCertificateDetails(t *testing.T) {
+	env := NewEnv(RunSettings{Verbose: true})
+	withProxyEnv(t, nil)
+
+	got := certificateResult(env, certServer(t, "Corp Inspection", func(c *x509.Certificate) {
+		c.DNSNames = []string{"bedrock-runtime.us-east-1.amazonaws.com"}
+	}))
+	d := got.Diagnostics
+	if d == nil || d.Certificate == nil {
+		t.Fatalf("no certificate diagnostics in %+v", got)
+	}
+	if want := []string{"bedrock-runtime.us-east-1.amazonaws.com", "127.0.0.1"}; strings.Join(d.Certificate.SANs, ",") != strings.Join(want, ",") {
+		t.Errorf("SANs = %v, want DNS names then IPs %v", d.Certificate.SANs, want)
+	}
+	if !strings.HasSuffix(got.Message, "[SANs bedrock-runtime.us-east-1.amazonaws.com, 127.0.0.1; issuers CN=Corp Inspection Root,O=Corp Inspection]") {
+		t.Errorf("message %q does not end with the certificate details", got.Message)
+	}
+}
@@ -0,0 +1,772 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Check is one check with a single result. Run reaches DNS, endpoints, and
+// AWS only through env, and takes the run's settings from it, so a check
+// runs against fakes as it does for real.
+type Check interface {
+	ID() string
+	Name() string
+	Run(ctx context.Context, env Env) CheckResult
+}
+
+// Group is a check that reports a result per item it finds (install
+// components, settings files, credential sources), or none when it does not
+// apply. ID and Name describe the group as a whole, for a skipped result.
+type Group interface {
+	ID() string
+	Name() string
+	RunAll(ctx context.Context, env Env) []CheckResult
+}
+
+// LocalInstall checks the Claude Code CLI, Node.js, and the npm prefix.
+type LocalInstall struct{}
+
+func (LocalInstall) ID() string   { return "local" }
+func (LocalInstall) Name() string { return "Local Install" }
+func (LocalInstall) RunAll(ctx context.Context, env Env) []CheckResult {
+	return localResults()
+}
+
+// Settings checks the Claude Code settings files and what they set.
+type Settings struct{}
+
+func (Settings) ID() string   { return "settings" }
+func (Settings) Name() string { return "Claude Settings" }
+func (Settings) RunAll(ctx context.Context, env Env) []CheckResult {
+	return settingsResults(env)
+}
+
+// TimeSync checks the clock against NTP.
+type TimeSync struct{}
+
+func (TimeSync) ID() string   { return "clock.time_sync" }
+func (TimeSync) Name() string { return "Time Sync" }
+func (TimeSync) Run(ctx context.Context, env Env) CheckResult {
+	return timeSyncResult(env)
+}
+
+// Region checks that a region is configured, and where it comes from.
+type Region struct {
+	Flag string // --region
+}
+
+func (Region) ID() string   { return "region" }
+func (Region) Name() string { return "AWS Region" }
+func (c Region) Run(ctx context.Context, env Env) CheckResult {
+	return regionResult(ResolveRegion(c.Flag))
+}
+
+// RegionConsistency compares the regions set in every configuration source.
+type RegionConsistency struct {
+	Flag string // --region
+}
+
+func (RegionConsistency) ID() string   { return "region.consistency" }
+func (RegionConsistency) Name() string { return "Region Consistency" }
+func (c RegionConsistency) Run(ctx context.Context, env Env) CheckResult {
+	return regionConsistencyResult(c.Flag)
+}
+
+// EndpointOverride reports the --endpoint-url in use and the public
+// endpoints it replaces.
+type EndpointOverride struct {
+	Endpoint *url.URL
+	Skipped  []string
+}
+
+func (EndpointOverride) ID() string   { return "endpoint.override" }
+func (EndpointOverride) Name() string { return "Bedrock Endpoint Override" }
+func (c EndpointOverride) Run(ctx context.Context, env Env) CheckResult {
+	return CheckResult{
+		ID:      c.ID(),
+		Name:    c.Name(),
+		Status:  "pass",
+		Message: fmt.Sprintf("Probing %s; public endpoints %s and %s skipped", c.Endpoint, c.Skipped[0], c.Skipped[1]),
+	}
+}
+
+// EndpointDNS resolves the host of one endpoint. Run keeps the addresses
+// and the error of the last attempt for the checks that compare them.
+type EndpointDNS struct {
+	Endpoint string // e.g. "Bedrock Runtime"
+	Host     string
+	Region   string
+	FIPS     bool
+
+	Addrs []string
+	Err   error
+}
+
+func (c *EndpointDNS) ID() string   { return checkID("dns", c.Endpoint) }
+func (c *EndpointDNS) Name() string { return fmt.Sprintf("DNS - %s", c.Endpoint) }
+func (c *EndpointDNS) Run(ctx context.Context, env Env) CheckResult {
+	var result CheckResult
+	result, c.Addrs, c.Err = dnsResult(env, c.Endpoint, c.Host, c.Region, c.FIPS)
+	return result
+}
+
+// DNSMethods resolves an endpoint with --resolver and over DNS-over-HTTPS.
+type DNSMethods struct {
+	Endpoint string
+	Host     string
+	Server   string
+	DoH      *url.URL
+}
+
+func (c DNSMethods) ID() string   { return checkID("dns_methods", c.Endpoint) }
+func (c DNSMethods) Name() string { return fmt.Sprintf("DNS Methods - %s", c.Endpoint) }
+func (c DNSMethods) Run(ctx context.Context, env Env) CheckResult {
+	return dnsMethodsResult(env, c.Endpoint, c.Host, c.Server, c.DoH)
+}
+
+// DNSCompare compares the system resolver's answer, from an EndpointDNS
+// check that has run, with public resolvers.
+type DNSCompare struct {
+	System  *EndpointDNS
+	Servers []string
+}
+
+func (c DNSCompare) ID() string   { return checkID("dns_compare", c.System.Endpoint) }
+func (c DNSCompare) Name() string { return fmt.Sprintf("DNS Compare - %s", c.System.Endpoint) }
+func (c DNSCompare) Run(ctx context.Context, env Env) CheckResult {
+	system := dnsAnswer{Resolver: env.dnsResolverName(), Addrs: c.System.Addrs, Err: c.System.Err}
+	return dnsCompareResult(env, c.System.Endpoint, c.System.Host, system, c.Servers)
+}
+
+// AddressFamilies checks IPv4 and IPv6 reachability of an endpoint.
+type AddressFamilies struct {
+	Endpoint string
+	Host     string
+}
+
+func (c AddressFamilies) ID() string   { return checkID("ip_family", c.Endpoint) }
+func (c AddressFamilies) Name() string { return fmt.Sprintf("IP Families - %s", c.Endpoint) }
+func (c AddressFamilies) Run(ctx context.Context, env Env) CheckResult {
+	return addressFamilyResult(env, c.Endpoint, c.Host)
+}
+
+// DualStack checks the dual-stack name of a public endpoint.
+type DualStack struct {
+	Endpoint string
+	Host     string
+}
+
+func (c DualStack) ID() string   { return checkID("dual_stack", c.Endpoint) }
+func (c DualStack) Name() string { return fmt.Sprintf("Dual-Stack - %s", c.Endpoint) }
+func (c DualStack) Run(ctx context.Context, env Env) CheckResult {
+	return dualStackResult(ctx, env, c.Endpoint, c.Host)
+}
+
+// NoProxy evaluates NO_PROXY for each endpoint host with Node's rules.
+type NoProxy struct {
+	Hosts []string
+}
+
+func (NoProxy) ID() string   { return "network.no_proxy" }
+func (NoProxy) Name() string { return "NO_PROXY Coverage" }
+func (c NoProxy) RunAll(ctx context.Context, env Env) []CheckResult {
+	return noProxyResult(env, c.Hosts)
+}
+
+// SystemProxy compares the OS proxy and PAC settings with the environment.
+type SystemProxy struct{}
+
+func (SystemProxy) ID() string   { return "network.system_proxy" }
+func (SystemProxy) Name() string { return "System Proxy" }
+func (SystemProxy) RunAll(ctx context.Context, env Env) []CheckResult {
+	return systemProxyResults(env)
+}
+
+// SOCKSProxy checks the SOCKS5 proxy named by ALL_PROXY.
+type SOCKSProxy struct {
+	URL string
+}
+
+func (SOCKSProxy) ID() string   { return "network.socks_proxy" }
+func (SOCKSProxy) Name() string { return "SOCKS Proxy" }
+func (c SOCKSProxy) RunAll(ctx context.Context, env Env) []CheckResult {
+	return socksResults(ctx, env, c.URL)
+}
+
+// EgressRoute reports the local interface and AWS range of the route.
+type EgressRoute struct {
+	URL string
+}
+
+func (EgressRoute) ID() string   { return "network.egress_route" }
+func (EgressRoute) Name() string { return "Egress Route" }
+func (c EgressRoute) Run(ctx context.Context, env Env) CheckResult {
+	return egressRouteResult(ctx, env, c.URL)
+}
+
+// IPRanges checks the addresses EndpointDNS checks resolved against AWS's
+// published ranges.
+type IPRanges struct {
+	Region string
+	DNS    []*EndpointDNS
+	Sample int
+}
+
+func (IPRanges) ID() string   { return "network.ip_ranges" }
+func (IPRanges) Name() string { return "AWS IP Ranges" }
+func (c IPRanges) Run(ctx context.Context, env Env) CheckResult {
+	endpoints := make([]resolvedEndpoint, len(c.DNS))
+	for i, dns := range c.DNS {
+		endpoints[i] = resolvedEndpoint{Name: dns.Endpoint, Host: dns.Host, Addrs: dns.Addrs}
+	}
+	return ipRangesResult(env, c.Region, endpoints, c.Sample)
+}
+
+// HTTPSConnectivity probes the Bedrock runtime endpoint unsigned.
+type HTTPSConnectivity struct {
+	URL           string
+	TTFBThreshold time.Duration
+}
+
+func (HTTPSConnectivity) ID() string   { return "https.bedrock_runtime" }
+func (HTTPSConnectivity) Name() string { return "HTTPS Connectivity" }
+func (c HTTPSConnectivity) Run(ctx context.Context, env Env) CheckResult {
+	return httpsConnectivityResult(env, c.URL, c.TTFBThreshold)
+}
+
+// ConnectionReuse measures what a new connection costs over a reused one.
+type ConnectionReuse struct {
+	URL         string
+	ColdPenalty time.Duration
+}
+
+func (ConnectionReuse) ID() string   { return "network.connection_reuse" }
+func (ConnectionReuse) Name() string { return "Connection Reuse" }
+func (c ConnectionReuse) Run(ctx context.Context, env Env) CheckResult {
+	return connectionReuseResult(env, c.URL, c.ColdPenalty)
+}
+
+// ProxyOverhead measures what the proxy costs over a direct path.
+type ProxyOverhead struct {
+	URL       string
+	Threshold time.Duration
+	Direct    bool
+}
+
+func (ProxyOverhead) ID() string   { return "network.proxy_overhead" }
+func (ProxyOverhead) Name() string { return "Proxy Overhead" }
+func (c ProxyOverhead) Run(ctx context.Context, env Env) CheckResult {
+	return proxyOverheadResult(env, c.URL, c.Threshold, c.Direct)
+}
+
+// ALPN checks the protocol negotiated directly and through the proxy.
+type ALPN struct {
+	URL    string
+	Direct bool
+}
+
+func (ALPN) ID() string   { return "tls.alpn" }
+func (ALPN) Name() string { return "HTTP/2 Negotiation" }
+func (c ALPN) Run(ctx context.Context, env Env) CheckResult {
+	return alpnResult(env, c.URL, c.Direct)
+}
+
+// TLSPolicy checks the protocol versions whatever terminates TLS accepts.
+type TLSPolicy struct {
+	URL string
+}
+
+func (TLSPolicy) ID() string   { return "tls.version_policy" }
+func (TLSPolicy) Name() string { return "TLS Version Policy" }
+func (c TLSPolicy) Run(ctx context.Context, env Env) CheckResult {
+	return tlsPolicyResult(env, c.URL)
+}
+
+// TLSInspection names the issuer of the served certificate and the
+// inspecting product, if any.
+type TLSInspection struct {
+	URL string
+}
+
+func (TLSInspection) ID() string   { return "tls.inspection" }
+func (TLSInspection) Name() string { return "TLS Inspection" }
+func (c TLSInspection) Run(ctx context.Context, env Env) CheckResult {
+	return tlsInspectionResult(env, c.URL)
+}
+
+// Certificate checks the validity window, names, and root of the served
+// certificate.
+type Certificate struct {
+	URL string
+}
+
+func (Certificate) ID() string   { return "tls.certificate" }
+func (Certificate) Name() string { return "Certificate Validation" }
+func (c Certificate) Run(ctx context.Context, env Env) CheckResult {
+	return certificateResult(env, c.URL)
+}
+
+// OSTrust verifies the served chain against the OS trust store.
+type OSTrust struct {
+	URL string
+}
+
+func (OSTrust) ID() string   { return "tls.os_trust" }
+func (OSTrust) Name() string { return "OS Trust Store" }
+func (c OSTrust) Run(ctx context.Context, env Env) CheckResult {
+	return osTrustResult(env, c.URL)
+}
+
+// Revocation checks the OCSP and CRL endpoints of the served chain.
+type Revocation struct {
+	URL string
+}
+
+func (Revocation) ID() string   { return "tls.revocation" }
+func (Revocation) Name() string { return "Certificate Revocation Endpoints" }
+func (c Revocation) Run(ctx context.Context, env Env) CheckResult {
+	return revocationResult(env, c.URL)
+}
+
+// Middlebox checks that AWS's JSON error bodies arrive unmodified.
+type Middlebox struct {
+	Region string
+	FIPS   bool
+}
+
+func (Middlebox) ID() string   { return "network.middlebox" }
+func (Middlebox) Name() string { return "Middlebox Interference" }
+func (c Middlebox) Run(ctx context.Context, env Env) CheckResult {
+	return middleboxResult(env, c.Region, c.FIPS)
+}
+
+// Payload sends large request bodies to find MTU and size limits.
+type Payload struct {
+	URL   string
+	Sizes []int
+}
+
+func (Payload) ID() string   { return "payload.bedrock_runtime" }
+func (Payload) Name() string { return "Large Payload Probe" }
+func (c Payload) Run(ctx context.Context, env Env) CheckResult {
+	return payloadProbeResult(env, c.URL, c.Sizes)
+}
+
+// IdleConnection holds a connection idle to find proxy and firewall
+// timeouts.
+type IdleConnection struct {
+	URL  string
+	Hold time.Duration
+}
+
+func (IdleConnection) ID() string   { return "network.idle_connection" }
+func (IdleConnection) Name() string { return "Idle Connection Probe" }
+func (c IdleConnection) Run(ctx context.Context, env Env) CheckResult {
+	return idleProbeResult(env, c.URL, c.Hold)
+}
+
+// Streaming checks that responses stream rather than arrive buffered.
+type Streaming struct {
+	Region    string
+	URL       string
+	StreamURL string
+}
+
+func (Streaming) ID() string   { return "network.stream_buffering" }
+func (Streaming) Name() string { return "Response Streaming" }
+func (c Streaming) Run(ctx context.Context, env Env) CheckResult {
+	return streamingResult(env, c.Region, c.URL, c.StreamURL)
+}
+
+// Concurrency opens parallel requests to find connection caps.
+type Concurrency struct {
+	Region string
+	URL    string
+	Count  int
+	Invoke bool
+}
+
+func (Concurrency) ID() string   { return "network.concurrency" }
+func (Concurrency) Name() string { return "Concurrency Probe" }
+func (c Concurrency) Run(ctx context.Context, env Env) CheckResult {
+	return concurrencyResult(env, c.Region, c.URL, c.Count, c.Invoke)
+}
+
+// VPCEndpoint reports that a VPC endpoint is configured.
+type VPCEndpoint struct{}
+
+func (VPCEndpoint) ID() string   { return "privatelink.vpc_endpoint" }
+func (VPCEndpoint) Name() string { return "PrivateLink VPC Endpoint" }
+func (c VPCEndpoint) Run(ctx context.Context, env Env) CheckResult {
+	return CheckResult{
+		ID:      c.ID(),
+		Name:    c.Name(),
+		Status:  "pass",
+		Message: "VPC endpoint configuration detected",
+	}
+}
+
+// PrivateDNS checks the private hosted zone and Resolver forwarding of a
+// PrivateLink endpoint.
+type PrivateDNS struct {
+	Region string
+	Host   string
+	Forced bool
+}
+
+func (PrivateDNS) ID() string   { return "privatelink.private_dns" }
+func (PrivateDNS) Name() string { return "PrivateLink DNS" }
+func (c PrivateDNS) RunAll(ctx context.Context, env Env) []CheckResult {
+	return privateDNSResults(env, c.Region, c.Host, c.Forced)
+}
+
+// OTel checks the OpenTelemetry collector endpoints and headers.
+type OTel struct{}
+
+func (OTel) ID() string   { return "otel" }
+func (OTel) Name() string { return "OpenTelemetry" }
+func (OTel) RunAll(ctx context.Context, env Env) []CheckResult {
+	return otelResults(env)
+}
+
+// APIKey checks the Bedrock API key, which replaces SigV4 when set.
+type APIKey struct {
+	Region string
+	URL    string
+}
+
+func (APIKey) ID() string   { return "bedrock.api_key" }
+func (APIKey) Name() string { return "Bedrock API Key" }
+func (c APIKey) RunAll(ctx context.Context, env Env) []CheckResult {
+	return apiKeyResults(env, c.Region, c.URL)
+}
+
+// Config runs the organization checks of the doctor config.
+type Config struct {
+	Config *DoctorConfig
+}
+
+func (Config) ID() string   { return "config" }
+func (Config) Name() string { return "Doctor Config" }
+func (c Config) RunAll(ctx context.Context, env Env) []CheckResult {
+	return configResults(env, c.Config)
+}
+
+// Plugins runs the site-specific plugin checks with the results before them.
+type Plugins struct {
+	Config      *DoctorConfig
+	Region      string
+	Prior       []CheckResult
+	ToolVersion string
+}
+
+func (Plugins) ID() string   { return "plugin:" }
+func (Plugins) Name() string { return "Plugins" }
+func (c Plugins) RunAll(ctx context.Context, env Env) []CheckResult {
+	return pluginResults(env, c.Config, c.Region, c.Prior, c.ToolVersion)
+}
+
+// SharedConfigLint checks ~/.aws/config and ~/.aws/credentials for syntax
+// errors and common mistakes.
+type SharedConfigLint struct{}
+
+func (SharedConfigLint) ID() string   { return "auth.shared_config_files" }
+func (SharedConfigLint) Name() string { return "AWS Config Files" }
+func (SharedConfigLint) RunAll(ctx context.Context, env Env) []CheckResult {
+	return sharedConfigLintResults()
+}
+
+// RetryConfig checks SDK retry and instance metadata settings.
+type RetryConfig struct{}
+
+func (RetryConfig) ID() string   { return "sdk.retry_config" }
+func (RetryConfig) Name() string { return "SDK Retry Configuration" }
+func (RetryConfig) RunAll(ctx context.Context, env Env) []CheckResult {
+	return retryConfigResults(env)
+}
+
+// AuthMatrix compares the SigV4 and Bedrock API key auth paths.
+type AuthMatrix struct {
+	Region string
+	FIPS   bool
+}
+
+func (AuthMatrix) ID() string   { return "auth_matrix.summary" }
+func (AuthMatrix) Name() string { return "Auth Method Used" }
+func (c AuthMatrix) RunAll(ctx context.Context, env Env) []CheckResult {
+	return authMatrixResults(ctx, env, c.Region, c.FIPS)
+}
+
+// SSOSession checks the IAM Identity Center session of the active profile.
+type SSOSession struct{}
+
+func (SSOSession) ID() string   { return "auth.sso_session" }
+func (SSOSession) Name() string { return "SSO Session" }
+func (SSOSession) RunAll(ctx context.Context, env Env) []CheckResult {
+	return ssoResults(env)
+}
+
+// CredentialProcess runs the credential_process of the active profile.
+type CredentialProcess struct{}
+
+func (CredentialProcess) ID() string   { return "auth.credential_process" }
+func (CredentialProcess) Name() string { return "Credential Process" }
+func (CredentialProcess) RunAll(ctx context.Context, env Env) []CheckResult {
+	return credentialProcessResults()
+}
+
+// WorkloadIdentity checks IRSA web identity or the EC2 instance profile.
+type WorkloadIdentity struct {
+	Region string
+}
+
+func (WorkloadIdentity) ID() string   { return "auth.web_identity_token" }
+func (WorkloadIdentity) Name() string { return "Workload Identity" }
+func (c WorkloadIdentity) RunAll(ctx context.Context, env Env) []CheckResult {
+	return workloadIdentityResults(env, c.Region)
+}
+
+// RoleChain assumes each role of a role_arn/source_profile chain.
+type RoleChain struct {
+	Region string
+}
+
+func (RoleChain) ID() string   { return "auth.role_chain" }
+func (RoleChain) Name() string { return "Assume-Role Chain" }
+func (c RoleChain) RunAll(ctx context.Context, env Env) []CheckResult {
+	return roleChainResults(ctx, env, c.Region)
+}
+
+// EnvCredentials checks credential-bearing environment variables that
+// override one another.
+type EnvCredentials struct {
+	Region string
+}
+
+func (EnvCredentials) ID() string   { return "auth.env_credentials" }
+func (EnvCredentials) Name() string { return "Environment Credentials" }
+func (c EnvCredentials) RunAll(ctx context.Context, env Env) []CheckResult {
+	return envCredentialsResults(env, c.Region)
+}
+
+// CredentialSource reports which link of the default credential chain won.
+type CredentialSource struct {
+	Region string
+}
+
+func (CredentialSource) ID() string   { return "auth.credential_source" }
+func (CredentialSource) Name() string { return "Credential Source" }
+func (c CredentialSource) Run(ctx context.Context, env Env) CheckResult {
+	return credentialSourceResult(ctx, env, c.Region)
+}
+
+// STSEndpoint checks which STS endpoint credential exchanges use.
+type STSEndpoint struct {
+	Region string
+	FIPS   bool
+}
+
+func (STSEndpoint) ID() string   { return "auth.sts_endpoint" }
+func (STSEndpoint) Name() string { return "STS Endpoint" }
+func (c STSEndpoint) Run(ctx context.Context, env Env) CheckResult {
+	return stsEndpointResult(env, c.Region, c.FIPS)
+}
+
+// UnsignedRequest sends the control plane request unsigned.
+type UnsignedRequest struct {
+	Region string
+	FIPS   bool
+}
+
+func (UnsignedRequest) ID() string   { return "bedrock.unsigned_request" }
+func (UnsignedRequest) Name() string { return "Unsigned Control Plane Request" }
+func (c UnsignedRequest) Run(ctx context.Context, env Env) CheckResult {
+	return unsignedRequestResult(ctx, env, c.Region, c.FIPS)
+}
+
+// SignedRequest sends the control plane request signed.
+type SignedRequest struct {
+	Region string
+	FIPS   bool
+}
+
+func (SignedRequest) ID() string   { return "bedrock.signed_request" }
+func (SignedRequest) Name() string { return "Signed Control Plane Request" }
+func (c SignedRequest) Run(ctx context.Context, env Env) CheckResult {
+	return signedRequestResult(ctx, env, c.Region, c.FIPS)
+}
+
+// BedrockAccess lists the foundation models and checks the configured
+// model's access grant.
+type BedrockAccess struct {
+	Region string
+}
+
+func (BedrockAccess) ID() string   { return "bedrock.api_access" }
+func (BedrockAccess) Name() string { return "Bedrock API Access" }
+func (c BedrockAccess) RunAll(ctx context.Context, env Env) []CheckResult {
+	return bedrockAccessResults(ctx, env, c.Region)
+}
+
+// ModelRegion checks ANTHROPIC_MODEL in the region, or finds the nearest
+// region that has it.
+type ModelRegion struct {
+	Region string
+	Search bool
+}
+
+func (ModelRegion) ID() string   { return "bedrock.model_region" }
+func (ModelRegion) Name() string { return "Model Region Availability" }
+func (c ModelRegion) RunAll(ctx context.Context, env Env) []CheckResult {
+	return modelRegionResults(ctx, env, c.Region, c.Search)
+}
+
+// ModelLifecycleCheck flags LEGACY or retired configured models.
+type ModelLifecycleCheck struct {
+	Region string
+}
+
+func (ModelLifecycleCheck) ID() string   { return "bedrock.model_lifecycle" }
+func (ModelLifecycleCheck) Name() string { return "Model Lifecycle" }
+func (c ModelLifecycleCheck) RunAll(ctx context.Context, env Env) []CheckResult {
+	return modelLifecycleResults(ctx, env, c.Region)
+}
+
+// ModelIDShape flags bare model IDs where a cross-region profile is
+// required, and the reverse.
+type ModelIDShape struct {
+	Region string
+}
+
+func (ModelIDShape) ID() string   { return "bedrock.model_id_shape" }
+func (ModelIDShape) Name() string { return "Model ID Format" }
+func (c ModelIDShape) RunAll(ctx context.Context, env Env) []CheckResult {
+	return modelIDShapeResults(env, c.Region)
+}
+
+// CrossAccount checks inference profile ARNs shared from another account.
+type CrossAccount struct {
+	Region string
+}
+
+func (CrossAccount) ID() string   { return "bedrock.cross_account" }
+func (CrossAccount) Name() string { return "Cross-Account Inference Profile" }
+func (c CrossAccount) RunAll(ctx context.Context, env Env) []CheckResult {
+	return crossAccountProfileResults(ctx, env, c.Region)
+}
+
+// SessionDuration checks the maximum session length of an assumed role.
+type SessionDuration struct {
+	Region string
+}
+
+func (SessionDuration) ID() string   { return "auth.session_duration" }
+func (SessionDuration) Name() string { return "Role Session Duration" }
+func (c SessionDuration) RunAll(ctx context.Context, env Env) []CheckResult {
+	return sessionDurationResults(ctx, env, c.Region)
+}
+
+// SmallFastModel checks the model for background tasks, possibly in
+// another region.
+type SmallFastModel struct {
+	Region string
+}
+
+func (SmallFastModel) ID() string   { return "small_model.access" }
+func (SmallFastModel) Name() string { return "Small/Fast Model" }
+func (c SmallFastModel) RunAll(ctx context.Context, env Env) []CheckResult {
+	return smallFastModelResults(env, c.Region)
+}
+
+// ProfileRegions checks every member region of a cross-region inference
+// profile.
+type ProfileRegions struct {
+	Region string
+}
+
+func (ProfileRegions) ID() string   { return "bedrock.profile_regions" }
+func (ProfileRegions) Name() string { return "Inference Profile Regions" }
+func (c ProfileRegions) RunAll(ctx context.Context, env Env) []CheckResult {
+	return profileRegionsResults(env, c.Region)
+}
+
+// IAMSimulation simulates the IAM policy for the actions Claude Code needs.
+type IAMSimulation struct {
+	Region string
+}
+
+func (IAMSimulation) ID() string   { return "bedrock.iam_simulation" }
+func (IAMSimulation) Name() string { return "IAM Policy Simulation" }
+func (c IAMSimulation) Run(ctx context.Context, env Env) CheckResult {
+	return iamSimulationResult(ctx, env, c.Region)
+}
+
+// InvocationLogging checks model invocation logging for governance.
+type InvocationLogging struct {
+	Region string
+}
+
+func (InvocationLogging) ID() string   { return "bedrock.invocation_logging" }
+func (InvocationLogging) Name() string { return "Model Invocation Logging" }
+func (c InvocationLogging) Run(ctx context.Context, env Env) CheckResult {
+	return governanceResult(ctx, env, c.Region)
+}
+
+// ServiceQuotas checks the Claude request and token quotas.
+type ServiceQuotas struct {
+	Region string
+}
+
+func (ServiceQuotas) ID() string   { return "bedrock.service_quotas" }
+func (ServiceQuotas) Name() string { return "Bedrock Service Quotas" }
+func (c ServiceQuotas) Run(ctx context.Context, env Env) CheckResult {
+	return serviceQuotasResult(env, c.Region)
+}
+
+// Usage compares CloudWatch usage with the quotas.
+type Usage struct {
+	Region string
+}
+
+func (Usage) ID() string   { return "bedrock.usage" }
+func (Usage) Name() string { return "Bedrock Usage" }
+func (c Usage) Run(ctx context.Context, env Env) CheckResult {
+	return usageResult(ctx, env, c.Region)
+}
+
+// Throttling sends a short burst of invocations to see when they throttle.
+type Throttling struct {
+	Region string
+	Count  int
+}
+
+func (Throttling) ID() string   { return "bedrock.throttling" }
+func (Throttling) Name() string { return "Bedrock Throttling Probe" }
+func (c Throttling) Run(ctx context.Context, env Env) CheckResult {
+	return throttleProbeResult(env, c.Region, c.Count)
+}
+
+// CountTokens calls CountTokens for the configured model.
+type CountTokens struct {
+	Region string
+}
+
+func (CountTokens) ID() string   { return "bedrock.count_tokens" }
+func (CountTokens) Name() string { return "Bedrock Token Counting" }
+func (c CountTokens) Run(ctx context.Context, env Env) CheckResult {
+	return countTokensResult(ctx, env, c.Region)
+}
+
+// Benchmark times billed invocations of the configured model.
+type Benchmark struct {
+	Region string
+	Runs   int
+}
+
+func (Benchmark) ID() string   { return "bedrock.benchmark" }
+func (Benchmark) Name() string { return "Bedrock Benchmark" }
+func (c Benchmark) Run(ctx context.Context, env Env) CheckResult {
+	return benchmarkResult(ctx, env, c.Region, c.Runs)
+}
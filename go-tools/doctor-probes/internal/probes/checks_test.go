@@ -0,0 +1,141 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/smithy-go"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// checks is one of every check, as the runner builds them.
+var checks = []Check{
+	TimeSync{}, Region{}, RegionConsistency{},
+	EndpointOverride{}, &EndpointDNS{Endpoint: "Bedrock Runtime"},
+	DNSMethods{Endpoint: "STS"}, DNSCompare{System: &EndpointDNS{Endpoint: "Bedrock Control"}},
+	AddressFamilies{Endpoint: "STS"}, DualStack{Endpoint: "Bedrock Runtime"},
+	EgressRoute{}, IPRanges{}, HTTPSConnectivity{}, ConnectionReuse{}, ProxyOverhead{},
+	ALPN{}, TLSPolicy{}, TLSInspection{}, Certificate{}, OSTrust{}, Revocation{},
+	Middlebox{}, Payload{}, IdleConnection{}, Streaming{}, Concurrency{}, VPCEndpoint{},
+	CredentialSource{}, STSEndpoint{}, UnsignedRequest{}, SignedRequest{},
+	IAMSimulation{}, InvocationLogging{}, ServiceQuotas{}, Usage{},
+	Throttling{}, CountTokens{}, Benchmark{},
+}
+
+// TestCheckIDs keeps the checks' IDs in step with what --severity accepts.
+func TestCheckIDs(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range checks {
+		if !validCheckID(c.ID()) {
+			t.Errorf("%T: ID %q is not accepted by --severity", c, c.ID())
+		}
+		if seen[c.ID()] {
+			t.Errorf("%T: ID %q used twice", c, c.ID())
+		}
+		seen[c.ID()] = true
+		if c.Name() == "" {
+			t.Errorf("%T has no name", c)
+		}
+	}
+}
+
+func TestEndpointDNS(t *testing.T) {
+	const host = "bedrock-runtime-fips.us-east-1.amazonaws.com"
+	nxdomain := &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	tests := []struct {
+		name     string
+		resolver *probestest.Resolver
+		fips     bool
+		status   string
+		code     string
+		contains string
+		compare  string // status of DNSCompare against a public resolver
+	}{
+		{name: "resolved", resolver: &probestest.Resolver{Addrs: []string{"52.94.0.7"}}, status: "pass", contains: "Resolved " + host, compare: "pass"},
+		{name: "no such host", resolver: &probestest.Resolver{Err: nxdomain}, status: "fail", code: codeDNSNXDomain, contains: "Failed to resolve " + host, compare: "warn"},
+		{name: "no FIPS endpoint", resolver: &probestest.Resolver{Err: nxdomain}, fips: true, status: "fail", code: codeFIPSUnavailable, contains: "No FIPS endpoint for Bedrock Runtime in us-east-1", compare: "warn"},
+	}
+	public := fakeDNS(t, "52.94.0.1")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := fakeEnv(tt.resolver, nil, nil)
+			dns := &EndpointDNS{Endpoint: "Bedrock Runtime", Host: host, Region: "us-east-1", FIPS: tt.fips}
+			r := dns.Run(context.Background(), env)
+			if r.ID != "dns.bedrock_runtime" || r.Name != dns.Name() || r.Status != tt.status || r.Code != tt.code || !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("got %s %q %s %s %q, want dns.bedrock_runtime %s %s containing %q", r.ID, r.Name, r.Status, r.Code, r.Message, tt.status, tt.code, tt.contains)
+			}
+			if !reflect.DeepEqual(dns.Addrs, tt.resolver.Addrs) || !errors.Is(dns.Err, tt.resolver.Err) {
+				t.Errorf("kept %v, %v; want the resolver's answer", dns.Addrs, dns.Err)
+			}
+			if !reflect.DeepEqual(tt.resolver.Hosts, []string{host}) {
+				t.Errorf("looked up %v, want %s once", tt.resolver.Hosts, host)
+			}
+
+			compare := DNSCompare{System: dns, Servers: []string{public}}
+			if got := compare.Run(context.Background(), env); got.ID != "dns_compare.bedrock_runtime" || got.Status != tt.compare {
+				t.Errorf("DNSCompare = %s %s (%s), want %s", got.ID, got.Status, got.Message, tt.compare)
+			}
+		})
+	}
+}
+
+func TestBedrockAccess(t *testing.T) {
+	haiku := bedrocktypes.FoundationModelSummary{
+		ModelId:        aws.String("anthropic.claude-3-haiku-20240307-v1:0"),
+		ModelName:      aws.String("Claude 3 Haiku"),
+		ModelLifecycle: &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusActive},
+	}
+	granted := &bedrock.GetFoundationModelAvailabilityOutput{
+		AuthorizationStatus:     bedrocktypes.AuthorizationStatusAuthorized,
+		EntitlementAvailability: bedrocktypes.EntitlementAvailabilityAvailable,
+		RegionAvailability:      bedrocktypes.RegionAvailabilityAvailable,
+	}
+	tests := []struct {
+		name    string
+		bedrock *probestest.Bedrock
+		status  []string // of bedrock.api_access, then bedrock.model_access
+		code    string
+	}{
+		{name: "models offered and granted", bedrock: &probestest.Bedrock{Models: []bedrocktypes.FoundationModelSummary{haiku}, Availability: granted}, status: []string{"pass", "pass"}},
+		{name: "no models offered", bedrock: &probestest.Bedrock{}, status: []string{"warn"}, code: codeNoModels},
+		{
+			name:    "access denied",
+			bedrock: &probestest.Bedrock{Err: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform bedrock:ListFoundationModels"}},
+			status:  []string{"fail"},
+			code:    codeIAMAccessDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, nil)
+			check := BedrockAccess{Region: "us-east-1"}
+			got := check.RunAll(context.Background(), fakeEnv(nil, tt.bedrock, nil))
+			var status []string
+			for _, r := range got {
+				status = append(status, r.Status)
+			}
+			if !reflect.DeepEqual(status, tt.status) || got[0].ID != check.ID() || got[0].Code != tt.code {
+				t.Errorf("got %+v, want statuses %v and code %s", got, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestEndpointOverride(t *testing.T) {
+	u, _ := url.Parse("https://vpce-0abc-123.bedrock-runtime.us-east-1.vpce.amazonaws.com")
+	check := EndpointOverride{Endpoint: u, Skipped: []string{"bedrock-runtime.us-east-1.amazonaws.com", "bedrock.us-east-1.amazonaws.com"}}
+	r := check.Run(context.Background(), fakeEnv(nil, nil, nil))
+	want := "Probing https://vpce-0abc-123.bedrock-runtime.us-east-1.vpce.amazonaws.com; public endpoints bedrock-runtime.us-east-1.amazonaws.com and bedrock.us-east-1.amazonaws.com skipped"
+	if r.ID != "endpoint.override" || r.Status != "pass" || r.Message != want {
+		t.Errorf("got %s %s %q, want endpoint.override pass %q", r.ID, r.Status, r.Message, want)
+	}
+}
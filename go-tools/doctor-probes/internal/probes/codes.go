@@ -0,0 +1,335 @@
+package probes
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/aws/smithy-go"
+)
+
+// Error codes are part of the JSON contract: add new ones freely, but never
+// rename or repurpose an existing code.
+const (
+	codeRegionUnset      = "E_REGION_UNSET"
+	codeDNSNXDomain      = "E_DNS_NXDOMAIN"
+	codeDNSTimeout       = "E_DNS_TIMEOUT"
+	codeDNSFailure       = "E_DNS_FAILURE"
+	codeDNSMismatch      = "E_DNS_MISMATCH"
+	codeFIPSUnavailable  = "E_FIPS_UNAVAILABLE"
+	codeConnectTimeout   = "E_CONNECT_TIMEOUT"
+	codeConnectRefused   = "E_CONNECT_REFUSED"
+	codeConnectFailure   = "E_CONNECT_FAILURE"
+	codeTLSFailure       = "E_TLS_FAILURE"
+	codeHTTP407          = "E_HTTP_407"
+	codeHTTPStatus       = "E_HTTP_STATUS"
+	codeSlowTTFB         = "E_SLOW_TTFB"
+	codeHTTPSRedirect    = "E_HTTPS_REDIRECT"
+	codeIPv6Unreachable  = "E_IPV6_UNREACHABLE"
+	codePayloadMTU       = "E_PAYLOAD_MTU"
+	codeAWSConfig        = "E_AWS_CONFIG"
+	codeAWSAPI           = "E_AWS_API"
+	codeIAMAccessDenied  = "E_IAM_ACCESS_DENIED"
+	codeIAMSimulatedDeny = "E_IAM_SIMULATED_DENY"
+	codeNoModels         = "E_NO_MODELS"
+	codeAPIKeyMalformed  = "E_API_KEY_MALFORMED"
+	codeAPIKeyExpired    = "E_API_KEY_EXPIRED"
+	codeAPIKeyRejected   = "E_API_KEY_REJECTED"
+	codeAuthPrecedence   = "E_AUTH_PRECEDENCE"
+	codeModelUnset       = "E_MODEL_UNSET"
+	codeModelNotGranted  = "E_MODEL_NOT_GRANTED"
+	codeModelInvalid     = "E_MODEL_INVALID"
+	codeThrottled        = "E_THROTTLED"
+	codeQuotaDefault     = "E_QUOTA_DEFAULT"
+	codeLoggingDisabled  = "E_LOGGING_DISABLED"
+	codeLoggingBucket    = "E_LOGGING_BUCKET"
+	codeOTelConfig       = "E_OTEL_CONFIG"
+	codeOTelUnreachable  = "E_OTEL_UNREACHABLE"
+	codeOTelRejected     = "E_OTEL_REJECTED"
+	codeNodeMissing      = "E_NODE_MISSING"
+	codeNodeUnsupported  = "E_NODE_UNSUPPORTED"
+	codeClaudeMissing    = "E_CLAUDE_MISSING"
+	codeClaudeNotOnPath  = "E_CLAUDE_NOT_ON_PATH"
+	codeClaudeBroken     = "E_CLAUDE_BROKEN"
+	codeClaudeOutdated   = "E_CLAUDE_OUTDATED"
+	codeAWSCLIMissing    = "E_AWS_CLI_MISSING"
+	codeAWSCLIOutdated   = "E_AWS_CLI_OUTDATED"
+	codeNPMPrefixEACCES  = "E_NPM_PREFIX_EACCES"
+
+	codeSettingsSyntax     = "E_SETTINGS_SYNTAX"
+	codeSettingsInvalid    = "E_SETTINGS_INVALID"
+	codeSettingsOverridden = "E_SETTINGS_OVERRIDDEN"
+
+	codeSSOTokenMissing = "E_SSO_TOKEN_MISSING"
+	codeSSOTokenExpired = "E_SSO_TOKEN_EXPIRED"
+	codeCredprocFailed  = "E_CREDPROC_FAILED"
+	codeCredprocTimeout = "E_CREDPROC_TIMEOUT"
+	codeCredprocInvalid = "E_CREDPROC_INVALID"
+	codeCredprocEmpty   = "E_CREDPROC_EMPTY"
+	codeCredprocExpired = "E_CREDPROC_EXPIRED"
+	codeNoProxyMismatch = "E_NO_PROXY_MISMATCH"
+
+	codeCountTokensUnavailable = "E_COUNT_TOKENS_UNAVAILABLE"
+	codeFlakyNetwork           = "E_FLAKY_NETWORK"
+	codeSystemProxyUnset       = "E_SYSTEM_PROXY_UNSET"
+	codeShortSession           = "E_SHORT_SESSION"
+	codeHTTP2Unavailable       = "E_HTTP2_UNAVAILABLE"
+	codeALPNMismatch           = "E_ALPN_MISMATCH"
+	codeTLSLegacyAccepted      = "E_TLS_LEGACY_ACCEPTED"
+	codeTLSNoModern            = "E_TLS_NO_MODERN"
+	codeRevocationBlocked      = "E_REVOCATION_BLOCKED"
+	codeEnvInvalid             = "E_ENV_INVALID"
+	codePluginFailed           = "E_PLUGIN_FAILED"
+	codePluginCheck            = "E_PLUGIN_CHECK"
+	codeSCPDeny                = "E_SCP_DENY"
+	codeIAMExplicitDeny        = "E_IAM_EXPLICIT_DENY"
+	codeCredentialsShadowed    = "E_CREDENTIALS_SHADOWED"
+	codeCredentialsExpired     = "E_CREDENTIALS_EXPIRED"
+	codeCredentialsInvalid     = "E_CREDENTIALS_INVALID"
+	codeCredentialsMissing     = "E_CREDENTIALS_MISSING"
+	codeTLSInspected           = "E_TLS_INSPECTED"
+	codeCertClockSkew          = "E_CERT_CLOCK_SKEW"
+	codeCertNameMismatch       = "E_CERT_NAME_MISMATCH"
+	codeCertUnexpectedRoot     = "E_CERT_UNEXPECTED_ROOT"
+	codeSOCKSUnreachable       = "E_SOCKS_UNREACHABLE"
+	codeSOCKSTargetUnreachable = "E_SOCKS_TARGET_UNREACHABLE"
+	codeSOCKSDNSLeak           = "E_SOCKS_DNS"
+	codeWebIdentityToken       = "E_WEB_IDENTITY_TOKEN"
+	codeWebIdentityExchange    = "E_WEB_IDENTITY_EXCHANGE"
+	codeInstanceRoleMissing    = "E_INSTANCE_ROLE_MISSING"
+	codeRoleChainBroken        = "E_ROLE_CHAIN_BROKEN"
+	codeRoleChainAssume        = "E_ROLE_CHAIN_ASSUME"
+	codeRoleChainMFA           = "E_ROLE_CHAIN_MFA"
+	codePrivateDNSDisabled     = "E_PRIVATE_DNS_DISABLED"
+	codePrivateDNSNotForwarded = "E_PRIVATE_DNS_NOT_FORWARDED"
+	codeModelNotInRegion       = "E_MODEL_NOT_IN_REGION"
+	codeUsageNearQuota         = "E_USAGE_NEAR_QUOTA"
+	codeIdleCutoff             = "E_IDLE_CUTOFF"
+	codeStreamBuffered         = "E_STREAM_BUFFERED"
+	codeConcurrencyFailure     = "E_CONCURRENCY_FAILURE"
+	codeConcurrencyQueued      = "E_CONCURRENCY_QUEUED"
+	codeSharedConfigSyntax     = "E_SHARED_CONFIG_SYNTAX"
+	codeSharedConfigMistake    = "E_SHARED_CONFIG_MISTAKE"
+	codeModelLegacy            = "E_MODEL_LEGACY"
+	codeModelRetired           = "E_MODEL_RETIRED"
+	codeModelIDMismatch        = "E_MODEL_ID_MISMATCH"
+	codeCrossAccountDenied     = "E_CROSS_ACCOUNT_DENIED"
+	codeSignatureClock         = "E_SIGNATURE_CLOCK"
+	codeSignatureRejected      = "E_SIGNATURE_REJECTED"
+	codeRegionFallback         = "E_REGION_FALLBACK"
+	codeMiddleboxRewrite       = "E_MIDDLEBOX_REWRITE"
+	codeMiddleboxHeaders       = "E_MIDDLEBOX_HEADERS"
+	codeBenchmarkSlow          = "E_BENCHMARK_SLOW"
+	codeBenchmarkThrottled     = "E_BENCHMARK_THROTTLED"
+	codeSTSGlobalEndpoint      = "E_STS_GLOBAL_ENDPOINT"
+	codeSTSEndpointUnreachable = "E_STS_ENDPOINT_UNREACHABLE"
+	codeColdConnection         = "E_COLD_CONNECTION"
+	codeConnectionNotReused    = "E_CONNECTION_NOT_REUSED"
+	codeTrustStoreDivergence   = "E_TRUST_STORE_DIVERGENCE"
+	codeProxyOverhead          = "E_PROXY_OVERHEAD"
+	codeIPRangeMismatch        = "E_IP_RANGE_MISMATCH"
+	codeIPRangesUnavailable    = "E_IP_RANGES_UNAVAILABLE"
+	codeRegionMismatch         = "E_REGION_MISMATCH"
+	codeTimeSyncDisabled       = "E_TIME_SYNC_DISABLED"
+	codeTimeSyncStale          = "E_TIME_SYNC_STALE"
+	codeRetrySlow              = "E_RETRY_SLOW"
+	codeIMDSWait               = "E_IMDS_WAIT"
+)
+
+// Severity values, derived from status unless a check sets one explicitly.
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+func SeverityForStatus(status string) string {
+	switch status {
+	case "fail":
+		return severityError
+	case "warn":
+		return severityWarning
+	default:
+		return severityInfo
+	}
+}
+
+// classifyNetError maps a DNS, dial, TLS, or HTTP transport error to a code.
+func classifyNetError(err error) string {
+	if err == nil {
+		return codeConnectFailure
+	}
+
+	var socksErr *socksProxyError
+	if errors.As(err, &socksErr) {
+		return codeSOCKSUnreachable
+	}
+	var socksTarget *socksTargetError
+	if errors.As(err, &socksTarget) && !strings.Contains(err.Error(), "tls:") {
+		return codeSOCKSTargetUnreachable
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return codeDNSNXDomain
+		case dnsErr.IsTimeout:
+			return codeDNSTimeout
+		default:
+			return codeDNSFailure
+		}
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalid x509.CertificateInvalidError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalid) ||
+		strings.Contains(err.Error(), "tls:") {
+		return codeTLSFailure
+	}
+
+	if strings.Contains(err.Error(), "407") || strings.Contains(err.Error(), "Proxy Authentication Required") {
+		return codeHTTP407
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return codeConnectTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return codeConnectRefused
+	}
+	return codeConnectFailure
+}
+
+var (
+	// orgPolicyDeny matches denials by AWS Organizations policies (service
+	// and resource control policies), which no change in the account lifts.
+	orgPolicyDeny = regexp.MustCompile(`(?i)explicit deny in an? (service|resource) control policy|no (service|resource) control policy allows`)
+	// explicitDeny matches the other denials an added allow cannot fix.
+	explicitDeny = regexp.MustCompile(`(?i)with an explicit deny|no (permissions boundary|session policy) allows`)
+)
+
+// awsErrorClass is the code of an SDK error and, for the codes with a known
+// remedy, how to fix it.
+type awsErrorClass struct {
+	Code       string
+	Fix        string // empty when the caller's own advice applies
+	FixCommand *FixCommand
+}
+
+// classifyAWSError maps an SDK error from a call of action (e.g.
+// "bedrock:ListFoundationModels") to a code and fix. Credential problems are
+// told apart first: they look the same from every API, and none of them is
+// fixed by IAM.
+func classifyAWSError(err error, action string) awsErrorClass {
+	errMsg := err.Error()
+	var apiCode string
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		apiCode = apiErr.ErrorCode()
+	}
+
+	switch {
+	case apiCode == "ExpiredToken" || apiCode == "ExpiredTokenException" || apiCode == "TokenRefreshRequired" ||
+		strings.Contains(errMsg, "ExpiredToken") || strings.Contains(errMsg, "SSO session has expired") ||
+		strings.Contains(errMsg, "cached SSO token is expired"):
+		return expiredCredentialsClass()
+	case apiCode == "InvalidClientTokenId" || apiCode == "SignatureDoesNotMatch" || apiCode == "UnrecognizedClientException":
+		return invalidCredentialsClass(apiCode)
+	case strings.Contains(errMsg, "no EC2 IMDS role found"):
+		return awsErrorClass{
+			Code: codeCredentialsMissing,
+			Fix:  "No credentials were found in the environment, the shared config, or instance metadata. Configure a profile (aws configure sso) and export AWS_PROFILE",
+		}
+	case strings.Contains(errMsg, "failed to load AWS config"):
+		return awsErrorClass{Code: codeAWSConfig}
+	case orgPolicyDeny.MatchString(errMsg):
+		return awsErrorClass{Code: codeSCPDeny, Fix: accessDeniedFix(codeSCPDeny, action)}
+	case explicitDeny.MatchString(errMsg):
+		return awsErrorClass{Code: codeIAMExplicitDeny, Fix: accessDeniedFix(codeIAMExplicitDeny, action)}
+	case strings.Contains(errMsg, "UnauthorizedOperation") || strings.Contains(errMsg, "AccessDenied"):
+		return awsErrorClass{Code: codeIAMAccessDenied, Fix: accessDeniedFix(codeIAMAccessDenied, action)}
+	case isThrottlingError(err):
+		return awsErrorClass{Code: codeThrottled}
+	default:
+		return awsErrorClass{Code: codeAWSAPI}
+	}
+}
+
+// expiredCredentialsClass advises a fresh sign-in, with the command for SSO
+// profiles.
+func expiredCredentialsClass() awsErrorClass {
+	class := awsErrorClass{
+		Code: codeCredentialsExpired,
+		Fix:  "Your AWS credentials have expired; sign in again or re-run whatever issued them (credential process, assume-role script, exported AWS_SESSION_TOKEN)",
+	}
+	if _, shared, err := loadProfile(); err == nil && shared != nil && (shared.SSOSessionName != "" || shared.SSOStartURL != "") {
+		class.Fix = "Your AWS session has expired; sign in again with aws sso login"
+		class.FixCommand = &FixCommand{
+			Bash:       `aws sso login ${AWS_PROFILE:+--profile "$AWS_PROFILE"}`,
+			PowerShell: `if ($env:AWS_PROFILE) { aws sso login --profile $env:AWS_PROFILE } else { aws sso login }`,
+		}
+	}
+	return class
+}
+
+// invalidCredentialsClass points at the most common cause of keys AWS does
+// not accept: stale keys exported in the environment.
+func invalidCredentialsClass(apiCode string) awsErrorClass {
+	class := awsErrorClass{Code: codeCredentialsInvalid}
+	switch {
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		class.Fix = "AWS rejected the access key from AWS_ACCESS_KEY_ID in the environment, which takes precedence over AWS_PROFILE. Remove stale AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN from your shell and its startup files, or replace them"
+	case apiCode == "SignatureDoesNotMatch":
+		class.Fix = "The secret access key does not match the access key ID. Check aws_secret_access_key in the active profile, and that the system clock is correct"
+	default:
+		class.Fix = "AWS does not recognize the access key; it was deleted, deactivated, or belongs to another partition. Check the keys in the active profile"
+	}
+	return class
+}
+
+// setAWSError sets r's code from a failed AWS call of action, and its fix
+// when the error has a more specific remedy than the one r already carries.
+func (r *CheckResult) setAWSError(err error, action string) {
+	class := classifyAWSError(err, action)
+	r.Code = class.Code
+	if class.Fix != "" {
+		r.Fix, r.FixCommand = class.Fix, class.FixCommand
+	}
+}
+
+// awsErrorCode maps an SDK error to a code.
+func awsErrorCode(err error) string {
+	return classifyAWSError(err, "").Code
+}
+
+// isAccessDenied reports whether code is one of the authorization denials.
+func isAccessDenied(code string) bool {
+	return code == codeIAMAccessDenied || code == codeSCPDeny || code == codeIAMExplicitDeny
+}
+
+// accessDeniedFix explains who can lift a denial of action, by its code.
+func accessDeniedFix(code, action string) string {
+	switch code {
+	case codeSCPDeny:
+		return fmt.Sprintf("An AWS Organizations policy (SCP) denies %s for this account. Only your AWS organization administrators can change it; editing your IAM role or user will not help. Send them the error above", action)
+	case codeIAMExplicitDeny:
+		return fmt.Sprintf("An explicit deny (identity policy, permissions boundary, or session policy) blocks %s, and no added allow can override it. Ask whoever manages the role's policies and boundary to remove the deny", action)
+	default:
+		return fmt.Sprintf("Add %s permission to your IAM role/user", action)
+	}
+}
+
+// checkID builds a stable ID such as "dns.bedrock_runtime" from a group and
+// a display name.
+func checkID(group, name string) string {
+	slug := strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(name))
+	return group + "." + slug
+}
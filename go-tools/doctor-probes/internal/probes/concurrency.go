@@ -0,0 +1,226 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// MaxConcurrencyProbe caps --concurrency-probe-count; Claude Code itself
+// rarely has more than a handful of requests in flight.
+const MaxConcurrencyProbe = 16
+
+// concurrentRequest is the outcome of one request of the concurrency probe.
+type concurrentRequest struct {
+	Latency time.Duration
+	Err     error
+}
+
+func (r concurrentRequest) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("failed after %s (%v)", formatMs(r.Latency), r.Err)
+	}
+	return formatMs(r.Latency)
+}
+
+// concurrencyRequester sends one lightweight request.
+type concurrencyRequester func(ctx context.Context) error
+
+// headRequester sends an unsigned HEAD to url over a connection of its own,
+// as checkHTTPSConnectivity does; any answer from AWS is a success.
+func headRequester(env Env, url string) concurrencyRequester {
+	host, _, _ := endpointAddr(url)
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := env.HTTP(host).Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return reachabilityError(resp)
+	}
+}
+
+// invokeRequester sends the 1-token InvokeModel request of the throttle
+// probe, with SDK retries off so that every throttle is seen.
+func invokeRequester(env Env, region, modelID string) (concurrencyRequester, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := env.BedrockRuntime(cfg, func(o *bedrockruntime.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+	return func(ctx context.Context) error {
+		_, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(modelID),
+			ContentType: aws.String("application/json"),
+			Accept:      aws.String("application/json"),
+			Body:        []byte(throttleProbeBody),
+		})
+		return err
+	}, nil
+}
+
+// runConcurrency sends n requests one after another, then n at once.
+func runConcurrency(send concurrencyRequester, target string, n int) (serial, parallel []concurrentRequest) {
+	do := func(phase string, i int) concurrentRequest {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		start := time.Now()
+		err := send(ctx)
+		logProbe("concurrency", target, start, err, "phase", phase, "request", i+1)
+		return concurrentRequest{Latency: time.Since(start), Err: err}
+	}
+
+	for i := 0; i < n; i++ {
+		serial = append(serial, do("serial", i))
+	}
+	parallel = make([]concurrentRequest, n)
+	var wg sync.WaitGroup
+	for i := range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parallel[i] = do("parallel", i)
+		}()
+	}
+	wg.Wait()
+	return serial, parallel
+}
+
+// latencyStats returns the median and maximum latency of the successful
+// requests, and how many failed.
+func latencyStats(reqs []concurrentRequest) (median, maximum time.Duration, failed int) {
+	var ok []time.Duration
+	for _, r := range reqs {
+		if r.Err != nil {
+			failed++
+			continue
+		}
+		ok = append(ok, r.Latency)
+	}
+	if len(ok) == 0 {
+		return 0, 0, failed
+	}
+	sort.Slice(ok, func(i, j int) bool { return ok[i] < ok[j] })
+	return ok[len(ok)/2], ok[len(ok)-1], failed
+}
+
+// concurrencyResult sends n lightweight requests to the Bedrock runtime,
+// first serially and then in parallel as Claude Code does with its main and
+// background models, and reports failures and queueing that appear only
+// under parallelism: proxy connection caps, and throttling on small quotas.
+// It is never worse than a warning.
+func concurrencyResult(env Env, region, bedrockURL string, n int, invoke bool) CheckResult {
+	const costNote = "each request is a 1-token InvokeModel call and incurs a small charge"
+	result := CheckResult{ID: "network.concurrency", Name: "Concurrency Probe"}
+
+	send, target := headRequester(env, bedrockURL), bedrockURL
+	if invoke {
+		modelID := configuredModel()
+		if modelID == "" {
+			result.Status = "skip"
+			result.Message = "ANTHROPIC_MODEL not set; no model to invoke"
+			result.Fix = "export ANTHROPIC_MODEL=<model-or-inference-profile-id>, or drop --invoke to probe with unsigned HEAD requests"
+			return result
+		}
+		var err error
+		if send, err = invokeRequester(env, region, modelID); err != nil {
+			result.Status, result.Code = "warn", codeAWSConfig
+			result.Message = err.Error()
+			return result
+		}
+		target = modelID
+	}
+
+	serial, parallel := runConcurrency(send, target, n)
+	serialMedian, serialMax, serialFailed := latencyStats(serial)
+	parallelMedian, parallelMax, parallelFailed := latencyStats(parallel)
+
+	var perRequest []string
+	for i, r := range parallel {
+		perRequest = append(perRequest, fmt.Sprintf("#%d %s", i+1, r))
+	}
+	result.Message = fmt.Sprintf("%d requests to %s: serial median %s (%d failed); parallel median %s, max %s (%d failed): %s",
+		n, target, formatMs(serialMedian), serialFailed, formatMs(parallelMedian), formatMs(parallelMax), parallelFailed, strings.Join(perRequest, ", "))
+	if invoke {
+		result.Message += "; " + costNote
+	}
+	result.Metrics = map[string]float64{
+		"requests":           float64(n),
+		"serial_median_ms":   DurationMs(serialMedian),
+		"serial_failed":      float64(serialFailed),
+		"parallel_median_ms": DurationMs(parallelMedian),
+		"parallel_max_ms":    DurationMs(parallelMax),
+		"parallel_failed":    float64(parallelFailed),
+	}
+
+	// The first parallel error says what gave way.
+	var firstErr error
+	throttled := false
+	for _, r := range parallel {
+		if r.Err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = r.Err
+		}
+		var statusErr *httpStatusError
+		if isThrottlingError(r.Err) || (errors.As(r.Err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests) {
+			throttled = true
+		}
+	}
+
+	// Requests that finish at serial speed got a connection at once; the rest
+	// waited for one, which is what a per-client connection cap looks like.
+	atSpeed := 0
+	for _, r := range parallel {
+		if r.Err == nil && r.Latency <= serialMax*3/2 {
+			atSpeed++
+		}
+	}
+
+	switch {
+	case serialFailed == n:
+		result.Status, result.Code = "warn", classifyNetError(serial[0].Err)
+		result.Message = fmt.Sprintf("Even serial requests to %s fail: %v", target, serial[0].Err)
+		result.Fix = "See HTTPS Connectivity"
+		if invoke {
+			result.Fix = "See Bedrock API Access and Bedrock Model Access"
+			result.setAWSError(serial[0].Err, "bedrock:InvokeModel")
+		}
+	case parallelFailed > serialFailed && throttled:
+		result.Status, result.Code = "warn", codeThrottled
+		result.Message = fmt.Sprintf("%d of %d parallel requests were throttled though serial ones passed; %s", parallelFailed, n, result.Message)
+		result.Fix = "The requests-per-minute quota is too small for Claude Code's parallel requests; request a quota increase in the Service Quotas console (Amazon Bedrock), or use a cross-region inference profile"
+		if !invoke {
+			result.Fix = "Something on the path (proxy or gateway) rate-limits parallel connections with HTTP 429; ask its owners to raise the per-client limit for *.amazonaws.com"
+		}
+	case parallelFailed > serialFailed:
+		result.Status, result.Code = "warn", codeConcurrencyFailure
+		result.Message = fmt.Sprintf("%d of %d parallel requests failed though serial ones passed (%v); %s", parallelFailed, n, firstErr, result.Message)
+		result.Fix = "A proxy or firewall limits connections per client; ask its owners to allow at least " + fmt.Sprint(n) + " concurrent connections to *.amazonaws.com"
+	case parallelMax > 2*serialMax && parallelMax-serialMax > 500*time.Millisecond && atSpeed > 0 && atSpeed < n:
+		result.Status, result.Code = "warn", codeConcurrencyQueued
+		result.Message = fmt.Sprintf("Parallel requests queue: only %d of %d finished at serial speed, the slowest took %s against %s serially, as behind a per-client connection cap of %d; %s",
+			atSpeed, n, formatMs(parallelMax), formatMs(serialMax), atSpeed, result.Message)
+		result.Fix = fmt.Sprintf("Claude Code's background requests will wait behind the main one; ask the proxy owners to raise the per-client connection limit above %d", atSpeed)
+	default:
+		result.Status = "pass"
+	}
+	return result
+}
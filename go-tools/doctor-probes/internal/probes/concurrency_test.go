@@ -0,0 +1,214 @@
+package probes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestLatencyStats(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	failed := errors.New("reset")
+	tests := []struct {
+		name        string
+		reqs        []concurrentRequest
+		median, max time.Duration
+		failedN     int
+	}{
+		{name: "none"},
+		{name: "all failed", reqs: []concurrentRequest{{Err: failed}, {Err: failed}}, failedN: 2},
+		{
+			name:    "mixed",
+			reqs:    []concurrentRequest{{Latency: ms(300)}, {Latency: ms(100)}, {Latency: ms(5), Err: failed}, {Latency: ms(200)}},
+			median:  ms(200),
+			max:     ms(300),
+			failedN: 1,
+		},
+	}
+	for _, tt := range tests {
+		median, maximum, n := latencyStats(tt.reqs)
+		if median != tt.median || maximum != tt.max || n != tt.failedN {
+			t.Errorf("%s: latencyStats = %s, %s, %d; want %s, %s, %d", tt.name, median, maximum, n, tt.median, tt.max, tt.failedN)
+		}
+	}
+}
+
+// concurrencyServer answers like AWS after delay, letting at most limit
+// requests through at once; over the limit it answers with overLimit, or
+// queues when that is zero. A negative overLimit drops the connection.
+func concurrencyServer(t *testing.T, delay time.Duration, limit, overLimit int) string {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		inFlight int
+	)
+	queue := make(chan struct{}, limit)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		over := inFlight > limit
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		switch {
+		case over && overLimit < 0:
+			time.Sleep(delay)
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		case over && overLimit > 0:
+			time.Sleep(delay)
+			w.WriteHeader(overLimit)
+			return
+		}
+		queue <- struct{}{}
+		time.Sleep(delay)
+		<-queue
+		w.Header().Set("x-amzn-RequestId", "r-1")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestConcurrencyResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "no limits",
+			url:      concurrencyServer(t, 50*time.Millisecond, 4, 0),
+			status:   "pass",
+			contains: []string{"4 requests to http://127.0.0.1:", "(0 failed); parallel median ", "#1 ", "#4 "},
+		},
+		{
+			name:     "connection cap",
+			url:      concurrencyServer(t, 300*time.Millisecond, 1, 0),
+			status:   "warn",
+			code:     codeConcurrencyQueued,
+			contains: []string{"Parallel requests queue: only 1 of 4 finished at serial speed", "raise the per-client connection limit above 1"},
+		},
+		{
+			name:     "proxy rate limit",
+			url:      concurrencyServer(t, 100*time.Millisecond, 1, http.StatusTooManyRequests),
+			status:   "warn",
+			code:     codeThrottled,
+			contains: []string{"3 of 4 parallel requests were throttled though serial ones passed", "rate-limits parallel connections with HTTP 429"},
+		},
+		{
+			name:     "connections dropped",
+			url:      concurrencyServer(t, 100*time.Millisecond, 1, -1),
+			status:   "warn",
+			code:     codeConcurrencyFailure,
+			contains: []string{"3 of 4 parallel requests failed though serial ones passed", "allow at least 4 concurrent connections"},
+		},
+		{
+			name:     "refused",
+			url:      "http://127.0.0.1:1",
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"Even serial requests to http://127.0.0.1:1 fail", "See HTTPS Connectivity"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withProxyEnv(t, nil)
+			// Every subtest targets 127.0.0.1, so each gets its own Env
+			// and with it a fresh rate history.
+			r := concurrencyResult(NewEnv(RunSettings{}), "us-east-1", tt.url, 4, false)
+			if r.ID != "network.concurrency" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if r.Metrics["requests"] != 4 && tt.status != "skip" {
+				t.Errorf("metrics = %v, want 4 requests", r.Metrics)
+			}
+		})
+	}
+}
+
+func TestConcurrencyResultInvoke(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		throttle bool // throttle requests that overlap; otherwise deny all
+		status   string
+		code     string
+		contains []string
+	}{
+		{name: "no model", status: "skip", contains: []string{"ANTHROPIC_MODEL not set"}},
+		{
+			name:     "denied",
+			model:    "anthropic.claude-x",
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Even serial requests to anthropic.claude-x fail", "bedrock:InvokeModel"},
+		},
+		{
+			name:     "throttled in parallel",
+			model:    "anthropic.claude-x",
+			throttle: true,
+			status:   "warn",
+			code:     codeThrottled,
+			contains: []string{"parallel requests were throttled though serial ones passed", "request a quota increase", "incurs a small charge"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				inFlight int
+			)
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				if !tt.throttle {
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+					return
+				}
+				mu.Lock()
+				inFlight++
+				over := inFlight > 1
+				mu.Unlock()
+				time.Sleep(100 * time.Millisecond)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+				if over {
+					probestest.AWSJSONError(w, http.StatusTooManyRequests, "ThrottlingException", "Too many requests")
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"content":[{"type":"text","text":"1"}]}`))
+			})
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			r := concurrencyResult(NewEnv(RunSettings{}), "us-east-1", "https://bedrock-runtime.us-east-1.amazonaws.com", 3, true)
+			if r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s (%s), want %s %s", r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
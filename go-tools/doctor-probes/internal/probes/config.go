@@ -0,0 +1,286 @@
+package probes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DoctorConfig is the organization config (--config, default
+// ~/.bcce/doctor.yaml). It only adds checks and tunes the severity of
+// existing ones; the built-in checks always run.
+type DoctorConfig struct {
+	DNS      []configDNSTarget   `yaml:"dns"`
+	HTTPS    []configHTTPSTarget `yaml:"https"`
+	Env      []configEnvVar      `yaml:"env"`
+	Severity map[string]string   `yaml:"severity"` // check ID -> fail, warn, pass, or suppress
+
+	Plugins       []string      `yaml:"plugins"`        // executables run in addition to ~/.bcce/doctor.d
+	PluginTimeout time.Duration `yaml:"plugin_timeout"` // per plugin; default 30s
+}
+
+type configDNSTarget struct {
+	Name string `yaml:"name"`
+	Host string `yaml:"host"`
+}
+
+type configHTTPSTarget struct {
+	Name         string `yaml:"name"`
+	URL          string `yaml:"url"`
+	ExpectStatus string `yaml:"expect_status"` // e.g. "200-299" or "200,204,401"; default 200-399
+
+	ranges [][2]int
+}
+
+type configEnvVar struct {
+	Name     string `yaml:"name"`
+	Pattern  string `yaml:"pattern"`
+	Optional bool   `yaml:"optional"`
+	Fix      string `yaml:"fix"`
+
+	re *regexp.Regexp
+}
+
+// DefaultConfigPath is ~/.bcce/doctor.yaml, or "" without a home directory.
+func DefaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bcce", "doctor.yaml")
+}
+
+// LoadConfig reads and validates the config at path. A missing file is
+// an error only when required (an explicit --config).
+func LoadConfig(path string, required bool) (*DoctorConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg DoctorConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	// Unknown keys report their line ("line 7: field hots not found ...")
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *DoctorConfig) validate() error {
+	for i := range c.DNS {
+		t := &c.DNS[i]
+		if t.Host == "" {
+			return fmt.Errorf("dns[%d]: host is required", i)
+		}
+		if t.Name == "" {
+			t.Name = t.Host
+		}
+	}
+	for i := range c.HTTPS {
+		t := &c.HTTPS[i]
+		u, err := url.Parse(t.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("https[%d]: url %q is not an http(s) URL", i, t.URL)
+		}
+		if t.Name == "" {
+			t.Name = u.Host
+		}
+		if t.ExpectStatus == "" {
+			t.ExpectStatus = "200-399"
+		}
+		if t.ranges, err = parseStatusRanges(t.ExpectStatus); err != nil {
+			return fmt.Errorf("https[%d]: expect_status: %w", i, err)
+		}
+	}
+	for i := range c.Env {
+		v := &c.Env[i]
+		if v.Name == "" {
+			return fmt.Errorf("env[%d]: name is required", i)
+		}
+		if v.Pattern != "" {
+			re, err := regexp.Compile(v.Pattern)
+			if err != nil {
+				return fmt.Errorf("env[%d] (%s): pattern: %w", i, v.Name, err)
+			}
+			v.re = re
+		}
+	}
+	for i, p := range c.Plugins {
+		if p == "" {
+			return fmt.Errorf("plugins[%d]: path is required", i)
+		}
+	}
+	if c.PluginTimeout < 0 {
+		return fmt.Errorf("plugin_timeout must be positive")
+	}
+	for id, status := range c.Severity {
+		if err := validateSeverityRule(id, status); err != nil {
+			return fmt.Errorf("severity: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseStatusRanges parses "200-299,401" into inclusive ranges.
+func parseStatusRanges(spec string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || to < from {
+				return nil, fmt.Errorf("invalid status range %q", part)
+			}
+		}
+		if from < 100 || to > 599 {
+			return nil, fmt.Errorf("status %q is outside 100-599", part)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+func statusInRanges(status int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if status >= r[0] && status <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// configResults runs the checks the config declares.
+func configResults(env Env, cfg *DoctorConfig) []CheckResult {
+	if cfg == nil {
+		return nil
+	}
+	var results []CheckResult
+	for _, t := range cfg.DNS {
+		results = append(results, configDNSResult(env, t))
+	}
+	for _, t := range cfg.HTTPS {
+		results = append(results, configHTTPSResult(env, t))
+	}
+	for _, v := range cfg.Env {
+		results = append(results, configEnvResult(v))
+	}
+	return results
+}
+
+func configDNSResult(env Env, t configDNSTarget) CheckResult {
+	result := CheckResult{ID: checkID("config.dns", t.Name), Name: fmt.Sprintf("DNS - %s", t.Name)}
+	var addrs []string
+	failures, err := retryProbe(func() (err error) {
+		addrs, err = checkDNS(env, t.Host)
+		return err
+	})
+	if err != nil {
+		result.Status, result.Code = "fail", classifyNetError(err)
+		result.Message = fmt.Sprintf("Failed to resolve %s: %v", t.Host, err)
+		result.Fix = "Check DNS settings and VPN/split-DNS configuration for " + t.Host
+	} else {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Resolved %s to %s", t.Host, strings.Join(addrs, ", "))
+	}
+	result.noteRetries(failures, err)
+	return result
+}
+
+func configHTTPSResult(env Env, t configHTTPSTarget) CheckResult {
+	result := CheckResult{ID: checkID("config.https", t.Name), Name: fmt.Sprintf("HTTPS - %s", t.Name)}
+	target := RedactSecrets(t.URL)
+
+	// The default transport honors the proxy environment, like Claude Code.
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: env.Engine.limited(nil),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	var status int
+	failures, err := retryProbe(func() error {
+		start := time.Now()
+		resp, err := client.Get(t.URL)
+		logProbe("config_https", target, start, err)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		return nil
+	})
+	switch {
+	case err != nil:
+		result.Status, result.Code = "fail", classifyNetError(err)
+		result.Message = fmt.Sprintf("Failed to connect to %s: %v", target, err)
+		result.Fix = "Check firewall, proxy settings, and VPN for " + target
+	case !statusInRanges(status, t.ranges):
+		result.Status, result.Code = "fail", codeHTTPStatus
+		result.Message = fmt.Sprintf("%s answered HTTP %d, expected %s", target, status, t.ExpectStatus)
+		result.Fix = "Check that the service is healthy and that no proxy or firewall is answering in its place"
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s answered HTTP %d", target, status)
+	}
+	result.noteRetries(failures, err)
+	return result
+}
+
+func configEnvResult(v configEnvVar) CheckResult {
+	result := CheckResult{ID: checkID("config.env", v.Name), Name: v.Name}
+	value, ok := os.LookupEnv(v.Name)
+	fix := v.Fix
+	switch {
+	case !ok || value == "":
+		if v.Optional {
+			result.Status = "pass"
+			result.Message = "Not set (optional)"
+			return result
+		}
+		result.Status, result.Code = "fail", codeEnvInvalid
+		result.Message = "Not set; required by the doctor config"
+		if fix == "" {
+			fix = fmt.Sprintf("export %s=<value>", v.Name)
+		}
+	case v.re != nil && !v.re.MatchString(value):
+		result.Status, result.Code = "fail", codeEnvInvalid
+		// The value may be a secret; show only the expected shape.
+		result.Message = fmt.Sprintf("Set, but does not match %s", v.Pattern)
+		if fix == "" {
+			fix = fmt.Sprintf("Set %s to a value matching %s", v.Name, v.Pattern)
+		}
+	default:
+		result.Status = "pass"
+		result.Message = "Set"
+		if v.re != nil {
+			result.Message = "Set and matches " + v.Pattern
+		}
+	}
+	result.Fix = fix
+	return result
+}
@@ -0,0 +1,254 @@
+package probes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{name: "empty file"},
+		{
+			name: "every section",
+			yaml: `dns:
+  - host: git.corp.example
+https:
+  - name: Artifactory
+    url: https://artifactory.corp.example/api/system/ping
+    expect_status: "200-299,401"
+env:
+  - name: CLAUDE_CODE_USE_BEDROCK
+    pattern: ^1$
+  - name: CORP_TEAM
+    optional: true
+severity:
+  bedrock.service_quotas: fail
+  dns.sts: warn
+plugins:
+  - /opt/corp/doctor/vpn-check
+plugin_timeout: 5s
+`,
+		},
+		{name: "unknown key reports its line", yaml: "dns:\n  - name: git\n    hots: git.corp.example\n", err: "line 3: field hots not found"},
+		{name: "unknown section", yaml: "dns: []\nchecks: []\n", err: "line 2: field checks not found"},
+		{name: "DNS host required", yaml: "dns:\n  - name: git\n", err: "dns[0]: host is required"},
+		{name: "HTTPS URL scheme", yaml: "https:\n  - url: ftp://files.corp.example\n", err: `https[0]: url "ftp://files.corp.example" is not an http(s) URL`},
+		{name: "HTTPS URL host", yaml: "https:\n  - url: https://\n", err: `https[0]: url "https://" is not an http(s) URL`},
+		{name: "status range", yaml: "https:\n  - url: https://a.example\n    expect_status: 299-200\n", err: `https[0]: expect_status: invalid status range "299-200"`},
+		{name: "env name required", yaml: "env:\n  - pattern: x\n", err: "env[0]: name is required"},
+		{name: "env regex", yaml: "env:\n  - name: A\n  - name: B\n    pattern: \"(unclosed\"\n", err: "env[1] (B): pattern: error parsing regexp"},
+		{name: "severity value", yaml: "severity:\n  dns.sts: info\n", err: `severity: dns.sts: "info" is not fail, warn, pass, or suppress`},
+		{name: "severity check ID", yaml: "severity:\n  bedrock.api: warn\n", err: `severity: "bedrock.api" is not a check ID`},
+		{name: "plugin path", yaml: "plugins:\n  - \"\"\n", err: "plugins[0]: path is required"},
+		{name: "plugin timeout", yaml: "plugin_timeout: -1s\n", err: "plugin_timeout must be positive"},
+		{name: "not YAML", yaml: "dns: [\n", err: "yaml:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "doctor.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(path, true)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || cfg == nil {
+				t.Fatalf("loadConfig = %v, %v", cfg, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doctor.yaml")
+	os.WriteFile(path, []byte("dns:\n  - host: git.corp.example\nhttps:\n  - url: https://ci.corp.example:8443/health\n"), 0o600)
+	cfg, err := LoadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DNS[0].Name != "git.corp.example" {
+		t.Errorf("DNS name = %q, want the host", cfg.DNS[0].Name)
+	}
+	h := cfg.HTTPS[0]
+	if h.Name != "ci.corp.example:8443" || h.ExpectStatus != "200-399" || !reflect.DeepEqual(h.ranges, [][2]int{{200, 399}}) {
+		t.Errorf("HTTPS target = %+v, want name from the URL host and expect_status 200-399", h)
+	}
+
+	missing := filepath.Join(t.TempDir(), "doctor.yaml")
+	if cfg, err := LoadConfig(missing, false); cfg != nil || err != nil {
+		t.Errorf("missing default config = %v, %v; want nil, nil", cfg, err)
+	}
+	if _, err := LoadConfig(missing, true); err == nil {
+		t.Error("missing --config file is not an error")
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		spec string
+		want [][2]int
+		err  string
+	}{
+		{spec: "200", want: [][2]int{{200, 200}}},
+		{spec: "200-299", want: [][2]int{{200, 299}}},
+		{spec: " 200 - 299 , 401,404", want: [][2]int{{200, 299}, {401, 401}, {404, 404}}},
+		{spec: "ok", err: `invalid status "ok"`},
+		{spec: "200-", err: `invalid status range "200-"`},
+		{spec: "300-200", err: `invalid status range "300-200"`},
+		{spec: "99", err: `status "99" is outside 100-599`},
+		{spec: "500-600", err: `status "500-600" is outside 100-599`},
+		{spec: "200,", err: `invalid status ""`},
+	}
+	for _, tt := range tests {
+		got, err := parseStatusRanges(tt.spec)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseStatusRanges(%q) err = %v, want %s", tt.spec, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStatusRanges(%q) = %v, %v; want %v", tt.spec, got, err, tt.want)
+		}
+	}
+
+	ranges := [][2]int{{200, 299}, {401, 401}}
+	for status, want := range map[int]bool{199: false, 200: true, 299: true, 301: false, 401: true, 403: false} {
+		if got := statusInRanges(status, ranges); got != want {
+			t.Errorf("statusInRanges(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestConfigEnvResult(t *testing.T) {
+	const secret = "sk-live-0123456789abcdef"
+	tests := []struct {
+		name   string
+		v      configEnvVar
+		value  *string
+		status string
+		msg    string
+		fix    string
+	}{
+		{name: "required and missing", v: configEnvVar{Name: "CORP_TOKEN"}, status: "fail", msg: "Not set; required by the doctor config", fix: "export CORP_TOKEN=<value>"},
+		{name: "empty counts as missing", v: configEnvVar{Name: "CORP_TOKEN", Fix: "Run corp-login"}, value: ptr(""), status: "fail", msg: "Not set; required by the doctor config", fix: "Run corp-login"},
+		{name: "optional and missing", v: configEnvVar{Name: "CORP_TOKEN", Optional: true}, status: "pass", msg: "Not set (optional)"},
+		{name: "set without pattern", v: configEnvVar{Name: "CORP_TOKEN"}, value: ptr(secret), status: "pass", msg: "Set"},
+		{name: "matches", v: configEnvVar{Name: "CORP_TOKEN", Pattern: "^sk-live-"}, value: ptr(secret), status: "pass", msg: "Set and matches ^sk-live-"},
+		{
+			name:   "does not match",
+			v:      configEnvVar{Name: "CORP_TOKEN", Pattern: "^sk-test-"},
+			value:  ptr(secret),
+			status: "fail",
+			msg:    "Set, but does not match ^sk-test-",
+			fix:    "Set CORP_TOKEN to a value matching ^sk-test-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.v.Pattern != "" {
+				cfg := DoctorConfig{Env: []configEnvVar{tt.v}}
+				if err := cfg.validate(); err != nil {
+					t.Fatal(err)
+				}
+				tt.v = cfg.Env[0]
+			}
+			probestest.Unsetenv(t, "CORP_TOKEN")
+			if tt.value != nil {
+				t.Setenv("CORP_TOKEN", *tt.value)
+			}
+			r := configEnvResult(tt.v)
+			if r.ID != "config.env.corp_token" || r.Status != tt.status || r.Message != tt.msg || r.Fix != tt.fix {
+				t.Errorf("got %s %s %q fix %q; want %s %q fix %q", r.ID, r.Status, r.Message, r.Fix, tt.status, tt.msg, tt.fix)
+			}
+			if r.Status == "fail" && r.Code != codeEnvInvalid {
+				t.Errorf("code = %s, want %s", r.Code, codeEnvInvalid)
+			}
+			if strings.Contains(r.Message+r.Fix, secret) {
+				t.Errorf("result shows the value: %+v", r)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestConfigHTTPSResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/denied":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	tests := []struct {
+		name     string
+		url      string
+		expect   string
+		status   string
+		code     string
+		contains string
+	}{
+		{name: "default range", url: srv.URL + "/health", status: "pass", contains: "answered HTTP 200"},
+		{name: "redirect is not followed", url: srv.URL + "/redirect", status: "pass", contains: "answered HTTP 302"},
+		{name: "listed status", url: srv.URL + "/denied", expect: "200-299,401", status: "pass", contains: "answered HTTP 401"},
+		{name: "unexpected status", url: srv.URL + "/denied", status: "fail", code: codeHTTPStatus, contains: "answered HTTP 401, expected 200-399"},
+		{name: "refused", url: "http://127.0.0.1:1/", status: "fail", code: codeConnectRefused, contains: "Failed to connect to http://127.0.0.1:1/"},
+		{name: "query secret redacted", url: srv.URL + "/health?X-Amz-Signature=abcdef0123456789", status: "pass", contains: "[REDACTED]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, nil)
+			cfg := DoctorConfig{HTTPS: []configHTTPSTarget{{Name: "Corp", URL: tt.url, ExpectStatus: tt.expect}}}
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			r := configHTTPSResult(env, cfg.HTTPS[0])
+			if r.ID != "config.https.corp" || r.Status != tt.status || r.Code != tt.code || !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("got %s %s %s %q; want %s %s containing %q", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code, tt.contains)
+			}
+			if strings.Contains(r.Message, "abcdef0123456789") {
+				t.Errorf("message shows the signature: %s", r.Message)
+			}
+		})
+	}
+}
+
+func TestConfigResults(t *testing.T) {
+	if got := configResults(NewEnv(RunSettings{}), nil); got != nil {
+		t.Errorf("configResults(nil) = %+v, want none", got)
+	}
+	probestest.Unsetenv(t, "CORP_TEAM")
+	cfg := &DoctorConfig{
+		DNS: []configDNSTarget{{Name: "loopback", Host: "localhost"}, {Name: "gone", Host: "nowhere.invalid"}},
+		Env: []configEnvVar{{Name: "CORP_TEAM", Optional: true}},
+	}
+	var got []string
+	for _, r := range configResults(NewEnv(RunSettings{}), cfg) {
+		got = append(got, r.ID+" "+r.Status+" "+r.Code)
+	}
+	want := []string{"config.dns.loopback pass ", "config.dns.gone fail " + codeDNSNXDomain, "config.env.corp_team pass "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configResults = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+package probes
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSessionTokenLifetime is the longest an STS session token lives
+// (GetSessionToken's 36 hours); a static token older than that has expired.
+const maxSessionTokenLifetime = 36 * time.Hour
+
+// configNonProfileSections are the config file sections that are not
+// profiles and so take no "profile" keyword.
+var configNonProfileSections = []string{"sso-session", "services", "plugins", "preview"}
+
+// sessionTokenExpiryKeys are the keys credential helpers (saml2aws,
+// gimme-aws-creds and the like) write next to a session token.
+var sessionTokenExpiryKeys = []string{"x_security_token_expires", "aws_expiration", "expiration"}
+
+// configProblem is one problem found in a shared config or credentials file.
+// Problems name keys and lines, never values, which may be secrets.
+type configProblem struct {
+	Path    string
+	Line    int
+	Fatal   bool // the AWS CLI (and other botocore tools) cannot load the file
+	Problem string
+	Fix     string
+}
+
+func (p configProblem) location() string {
+	return fmt.Sprintf("%s:%d", p.Path, p.Line)
+}
+
+// configSection is the state of the section being linted.
+type configSection struct {
+	Name          string
+	Line          int
+	Keys          map[string]int // key to the line it was first set on
+	LastKey       string
+	LastKeyEmpty  bool // "s3 =" and the like, which take indented sub-properties
+	SessionToken  int  // line of aws_session_token, or 0
+	SessionExpiry string
+}
+
+// lintSharedConfigFile checks one shared config (isConfig) or credentials
+// file for the syntax problems and common mistakes that make AWS tools fail
+// or silently ignore settings. modified is the file's modification time.
+func lintSharedConfigFile(path string, data []byte, isConfig bool, modified time.Time) []configProblem {
+	var problems []configProblem
+	add := func(line int, fatal bool, problem, fix string) {
+		problems = append(problems, configProblem{Path: path, Line: line, Fatal: fatal, Problem: problem, Fix: fix})
+	}
+
+	text := string(data)
+	if strings.HasPrefix(text, "\ufeff") {
+		add(1, true, "the file starts with a UTF-8 byte order mark, which the AWS CLI reads as part of the first line",
+			"re-save the file as UTF-8 without BOM (in Notepad: Save As, Encoding UTF-8)")
+		text = strings.TrimPrefix(text, "\ufeff")
+	}
+
+	seen := map[string]int{}
+	var current *configSection
+	closeSection := func() {
+		if current == nil || current.SessionToken == 0 {
+			return
+		}
+		expired := ""
+		if current.SessionExpiry != "" {
+			if t, err := time.Parse(time.RFC3339, current.SessionExpiry); err == nil && t.Before(time.Now()) {
+				expired = "expired at " + t.UTC().Format(time.RFC3339)
+			}
+		}
+		if age := time.Since(modified); expired == "" && !modified.IsZero() && age > maxSessionTokenLifetime {
+			expired = fmt.Sprintf("is in a file last written %.0f hours ago, longer than any session token lives", age.Hours())
+		}
+		if expired != "" {
+			add(current.SessionToken, false, fmt.Sprintf("aws_session_token of [%s] %s, so its keys are rejected with ExpiredToken", current.Name, expired),
+				fmt.Sprintf("replace the temporary keys of [%s] with fresh ones from whatever issued them, or switch the profile to SSO or credential_process so they refresh", current.Name))
+		}
+	}
+
+	for i, raw := range strings.Split(text, "\n") {
+		n := i + 1
+		raw = strings.TrimSuffix(raw, "\r")
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+
+		case strings.HasPrefix(line, "["):
+			closeSection()
+			current = nil
+			if !strings.HasSuffix(line, "]") {
+				add(n, true, "section header has no closing ]", "write the header as [name] on a line of its own")
+				continue
+			}
+			header := strings.Join(strings.Fields(strings.Trim(line, "[]")), " ")
+			if header == "" {
+				add(n, true, "section header has no name", "name the section, e.g. [default], or remove the line")
+				continue
+			}
+			if first, ok := seen[header]; ok {
+				add(n, true, fmt.Sprintf("[%s] is defined a second time (first on line %d)", header, first),
+					fmt.Sprintf("merge the keys of line %d's section into the one on line %d and remove the duplicate header", n, first))
+			} else {
+				seen[header] = n
+			}
+			current = &configSection{Name: header, Line: n, Keys: map[string]int{}}
+
+			kind, name, hasKind := strings.Cut(header, " ")
+			switch {
+			case isConfig && header == "profile default":
+				add(n, false, "[profile default] is read as the default profile by some tools and ignored by others",
+					"write the header as [default]")
+			case isConfig && !hasKind && header != "default" && !slices.Contains(configNonProfileSections, header):
+				add(n, false, fmt.Sprintf("[%s] lacks the profile keyword, so no tool sees it as a profile in the config file", header),
+					fmt.Sprintf("write the header as [profile %s]", header))
+			case !isConfig && hasKind && kind == "profile":
+				add(n, false, fmt.Sprintf("[%s] has the profile keyword, which the credentials file does not use, so its keys belong to a profile named %q", header, header),
+					fmt.Sprintf("write the header as [%s]", name))
+			}
+
+		case raw[0] == ' ' || raw[0] == '\t':
+			// Indented lines are sub-properties of a key with no value (s3 =),
+			// and otherwise continue the previous value.
+			switch {
+			case current == nil:
+				add(n, true, "indented line before any [section]", "remove the leading whitespace and move the line under a section header")
+			case current.LastKeyEmpty:
+				if !strings.Contains(line, "=") {
+					add(n, true, fmt.Sprintf("sub-property of %s is not a key = value pair", current.LastKey), "write it as an indented key = value")
+				}
+			case current.LastKey != "":
+				add(n, true, fmt.Sprintf("indented line is read as a continuation of the value of %s in [%s], not as a key of its own", current.LastKey, current.Name),
+					"remove the leading spaces or tabs")
+			default:
+				add(n, true, "indented line directly after a section header", "remove the leading spaces or tabs")
+			}
+
+		case current == nil:
+			add(n, true, "setting before any [section] header", "move the line under a section header, e.g. [default]")
+
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			if !ok || key == "" {
+				// Do not echo the line: it may be a pasted secret.
+				add(n, true, "line is not a [section], key = value, or comment", "fix or remove the line (a key needs an = and a value)")
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if first, ok := current.Keys[key]; ok {
+				add(n, true, fmt.Sprintf("%s is set twice in [%s] (first on line %d), which the AWS CLI rejects while other tools take the last value", key, current.Name, first),
+					fmt.Sprintf("keep one of lines %d and %d", first, n))
+			} else {
+				current.Keys[key] = n
+			}
+			current.LastKey, current.LastKeyEmpty = key, value == ""
+			switch {
+			case key == "aws_session_token" || key == "aws_security_token":
+				current.SessionToken = n
+			case slices.Contains(sessionTokenExpiryKeys, key):
+				current.SessionExpiry = value
+			case key == "region" && !isConfig:
+				add(n, false, fmt.Sprintf("region in the credentials file is ignored by the AWS CLI and most SDKs ([%s])", current.Name),
+					fmt.Sprintf("move the region line to the config file under [%s]", configSectionName(current.Name)))
+			}
+		}
+	}
+	closeSection()
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+// configSectionName is the config file header of a credentials file profile.
+func configSectionName(profile string) string {
+	if profile == "default" {
+		return "default"
+	}
+	return "profile " + profile
+}
+
+// sharedConfigLintResults parses the shared config and credentials files
+// tolerantly and reports syntax problems and common mistakes with file and
+// line. It returns nothing when neither file exists.
+func sharedConfigLintResults() []CheckResult {
+	result := CheckResult{ID: "auth.shared_config_files", Name: "AWS Config Files"}
+
+	configFile, credentialsFile := SharedConfigFiles()
+	var problems []configProblem
+	var read []string
+	for _, f := range []struct {
+		path     string
+		isConfig bool
+	}{{configFile, true}, {credentialsFile, false}} {
+		data, err := os.ReadFile(f.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			problems = append(problems, configProblem{Path: f.path, Fatal: true, Problem: fmt.Sprintf("cannot be read: %v", err), Fix: "check the file's permissions"})
+			continue
+		}
+		var modified time.Time
+		if info, err := os.Stat(f.path); err == nil {
+			modified = info.ModTime()
+		}
+		read = append(read, f.path)
+		problems = append(problems, lintSharedConfigFile(f.path, data, f.isConfig, modified)...)
+	}
+	if len(read) == 0 && len(problems) == 0 {
+		return nil
+	}
+
+	result.Metrics = map[string]float64{"problems": float64(len(problems))}
+	if len(problems) == 0 {
+		result.Status = "pass"
+		result.Message = "No syntax problems or common mistakes in " + strings.Join(read, " and ")
+		return []CheckResult{result}
+	}
+
+	result.Status, result.Code = "warn", codeSharedConfigMistake
+	var found, fixes []string
+	for _, p := range problems {
+		if p.Fatal {
+			result.Status, result.Code = "fail", codeSharedConfigSyntax
+		}
+		where := p.Path
+		if p.Line > 0 {
+			where = p.location()
+		}
+		found = append(found, fmt.Sprintf("%s: %s", where, p.Problem))
+		fixes = append(fixes, fmt.Sprintf("%s: %s", where, p.Fix))
+	}
+	result.Message = fmt.Sprintf("%d problem(s) in the shared AWS config files: %s", len(problems), strings.Join(found, "; "))
+	result.Fix = strings.Join(fixes, "; ")
+	return []CheckResult{result}
+}
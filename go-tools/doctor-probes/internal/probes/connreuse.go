@@ -0,0 +1,185 @@
+package probes
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// connTiming is one request of the connection reuse probe.
+type connTiming struct {
+	Reused  bool
+	DNS     time.Duration
+	Connect time.Duration // TCP to the proxy, or to the endpoint when direct
+	Proxy   time.Duration // CONNECT through an HTTP proxy
+	TLS     time.Duration
+	Total   time.Duration // request sent to response read
+
+	began time.Time // when the transport took the request
+}
+
+// newConnTrace returns a ClientTrace that fills in t. The proxy's share is
+// the gap between the TCP connection and the TLS handshake, which is where
+// the CONNECT exchange happens.
+func newConnTrace(t *connTiming) *httptrace.ClientTrace {
+	var dnsStart, connectStart, connectDone, tlsStart time.Time
+	return &httptrace.ClientTrace{
+		GetConn:  func(string) { t.began = time.Now() },
+		GotConn:  func(info httptrace.GotConnInfo) { t.Reused = info.Reused },
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.DNS = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			connectDone = time.Now()
+			t.Connect = connectDone.Sub(connectStart)
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+			if !connectDone.IsZero() {
+				t.Proxy = tlsStart.Sub(connectDone)
+			}
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { t.TLS = time.Since(tlsStart) },
+	}
+}
+
+// coldCost names the phase that dominates a new connection's setup.
+func (t connTiming) coldCost() (phase string, d time.Duration) {
+	phase, d = "DNS", t.DNS
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{{"TCP connect", t.Connect}, {"proxy CONNECT", t.Proxy}, {"TLS handshake", t.TLS}} {
+		if p.d > d {
+			phase, d = p.name, p.d
+		}
+	}
+	return phase, d
+}
+
+// timedRequest sends an unsigned HEAD over client and reads the response to
+// the end, so the connection can go back to the pool.
+func timedRequest(client *http.Client, target string) (connTiming, error) {
+	var t connTiming
+	ctx := httptrace.WithClientTrace(context.Background(), newConnTrace(&t))
+	req, err := newProbeRequest(ctx, http.MethodHead, target)
+	if err != nil {
+		return t, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		err = reachabilityError(resp)
+	}
+	t.Total = time.Since(start)
+	if !t.began.IsZero() {
+		t.Total = time.Since(t.began)
+	}
+	logProbe("https", target, start, err, "reused", t.Reused)
+	return t, err
+}
+
+// connectionReuseResult sends two requests to the Bedrock runtime on Claude
+// Code's route over one transport: the first on a new connection, the second
+// on the same connection. It reports the cold connection's cost over the warm
+// one, which phase dominates it, and warns when it exceeds threshold or when
+// the connection is not reused at all.
+func connectionReuseResult(env Env, bedrockURL string, threshold time.Duration) CheckResult {
+	result := CheckResult{ID: "network.connection_reuse", Name: "Connection Reuse"}
+	host, _, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+	transport := &http.Transport{MaxIdleConnsPerHost: 1, IdleConnTimeout: 30 * time.Second}
+	if isSOCKS(proxy) {
+		transport.DialContext = socksDialContext(proxy, &net.Dialer{Timeout: 5 * time.Second})
+	} else if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: env.Engine.limited(transport),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	cold, err := timedRequest(client, bedrockURL)
+	if err == nil && cold.Reused {
+		err = fmt.Errorf("the first request reused a connection; cannot measure a cold one")
+	}
+	var warm connTiming
+	if err == nil {
+		warm, err = timedRequest(client, bedrockURL)
+	}
+	if err != nil {
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("Could not time two requests to %s: %v", bedrockURL, err)
+		result.Fix = "See HTTPS Connectivity"
+		return result
+	}
+
+	penalty := cold.Total - warm.Total
+	phase, phaseTime := cold.coldCost()
+	result.Metrics = map[string]float64{
+		"cold_ms":    DurationMs(cold.Total),
+		"warm_ms":    DurationMs(warm.Total),
+		"penalty_ms": DurationMs(penalty),
+		"dns_ms":     DurationMs(cold.DNS),
+		"connect_ms": DurationMs(cold.Connect),
+		"tls_ms":     DurationMs(cold.TLS),
+	}
+	via := "direct"
+	if proxy != nil {
+		via = "via " + proxyDisplay(proxy)
+		if !isSOCKS(proxy) {
+			result.Metrics["proxy_ms"] = DurationMs(cold.Proxy)
+		}
+	}
+	summary := fmt.Sprintf("%s: new connection %s (dns %s, connect %s", via, formatMs(cold.Total), formatMs(cold.DNS), formatMs(cold.Connect))
+	if proxy != nil && !isSOCKS(proxy) {
+		summary += fmt.Sprintf(", proxy CONNECT %s", formatMs(cold.Proxy))
+	}
+	summary += fmt.Sprintf(", tls %s), reused connection %s", formatMs(cold.TLS), formatMs(warm.Total))
+
+	switch {
+	case !warm.Reused:
+		result.Status, result.Code = "warn", codeConnectionNotReused
+		result.Message = fmt.Sprintf("The second request could not reuse the first one's connection, so every request pays the setup cost: %s", summary)
+		result.Fix = fmt.Sprintf("Something closes connections after each response (a proxy without keep-alive, or Connection: close); ask the owners of %s to allow persistent connections to the Bedrock hosts", streamIntermediary(env, host))
+	case penalty > threshold:
+		result.Status, result.Code = "warn", codeColdConnection
+		result.Message = fmt.Sprintf("A new connection costs %s more than a reused one, mostly the %s (%s), so the first request after a pause is slow: %s",
+			formatMs(penalty), phase, formatMs(phaseTime), summary)
+		switch phase {
+		case "proxy CONNECT":
+			result.Fix = fmt.Sprintf("The proxy %s takes %s to open each tunnel, often per-connection authentication (NTLM, Kerberos) or upstream checks; ask its owners to speed up or exempt the Bedrock hosts", proxyDisplay(proxy), formatMs(phaseTime))
+		case "TLS handshake":
+			result.Fix = fmt.Sprintf("The TLS handshake takes %s; a TLS-inspecting proxy minting certificates, or a long route to the region, does this. See TLS Inspection, or use a closer region", formatMs(phaseTime))
+		default:
+			result.Fix = fmt.Sprintf("Setting up the connection (%s) is slow; check VPN or proxy routing, or use a closer region", phase)
+		}
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("A new connection costs %s more than a reused one: %s", formatMs(penalty), summary)
+	}
+	return result
+}
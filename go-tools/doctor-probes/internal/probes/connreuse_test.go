@@ -0,0 +1,109 @@
+package probes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestColdCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		timing connTiming
+		phase  string
+	}{
+		{name: "nothing measured", phase: "DNS"},
+		{name: "DNS", timing: connTiming{DNS: 90 * time.Millisecond, Connect: 10 * time.Millisecond}, phase: "DNS"},
+		{name: "proxy", timing: connTiming{Connect: 5 * time.Millisecond, Proxy: 800 * time.Millisecond, TLS: 60 * time.Millisecond}, phase: "proxy CONNECT"},
+		{name: "TLS", timing: connTiming{Connect: 20 * time.Millisecond, TLS: 400 * time.Millisecond}, phase: "TLS handshake"},
+	}
+	for _, tt := range tests {
+		if phase, _ := tt.timing.coldCost(); phase != tt.phase {
+			t.Errorf("%s: coldCost = %s, want %s", tt.name, phase, tt.phase)
+		}
+	}
+}
+
+func TestConnectionReuseResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc // nil means a refused connection
+		threshold time.Duration
+		status    string
+		code      string
+		contains  []string
+	}{
+		{
+			name:      "reused",
+			handler:   func(w http.ResponseWriter, r *http.Request) { awsHeaders(w) },
+			threshold: time.Minute,
+			status:    "pass",
+			contains:  []string{"more than a reused one: direct: new connection ", ", reused connection "},
+		},
+		{
+			name:      "cold penalty",
+			handler:   func(w http.ResponseWriter, r *http.Request) { awsHeaders(w) },
+			threshold: -time.Minute,
+			status:    "warn",
+			code:      codeColdConnection,
+			contains:  []string{"so the first request after a pause is slow", "Setting up the connection"},
+		},
+		{
+			name: "connection closed after each response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				awsHeaders(w)
+				w.Header().Set("Connection", "close")
+			},
+			threshold: time.Minute,
+			status:    "warn",
+			code:      codeConnectionNotReused,
+			contains:  []string{"could not reuse the first one's connection", "allow persistent connections"},
+		},
+		{
+			name:      "not AWS",
+			handler:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) },
+			threshold: time.Minute,
+			status:    "warn",
+			contains:  []string{"Could not time two requests to http://127.0.0.1:", "See HTTPS Connectivity"},
+		},
+		{
+			name:      "refused",
+			threshold: time.Minute,
+			status:    "warn",
+			code:      codeConnectRefused,
+			contains:  []string{"Could not time two requests to http://127.0.0.1:1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, nil)
+			target := "http://127.0.0.1:1"
+			if tt.handler != nil {
+				srv := httptest.NewServer(tt.handler)
+				t.Cleanup(srv.Close)
+				target = srv.URL
+			}
+
+			r := connectionReuseResult(env, target, tt.threshold)
+			if r.ID != "network.connection_reuse" || r.Status != tt.status || (tt.code != "" && r.Code != tt.code) {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if r.Status == "pass" {
+				for _, key := range []string{"cold_ms", "warm_ms", "penalty_ms", "connect_ms"} {
+					if _, ok := r.Metrics[key]; !ok {
+						t.Errorf("metrics = %v, want %s", r.Metrics, key)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	runtimetypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+// countTokensUnavailable reports whether err means the CountTokens operation
+// is not offered in the region or for the model, as opposed to a permission
+// or credential problem.
+func countTokensUnavailable(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "UnknownOperationException", "ResourceNotFoundException":
+			return true
+		case "ValidationException":
+			msg := strings.ToLower(apiErr.ErrorMessage())
+			return strings.Contains(msg, "not supported") || strings.Contains(msg, "doesn't support")
+		}
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
+
+func countTokensResult(ctx context.Context, env Env, region string) CheckResult {
+	result := CheckResult{ID: "bedrock.count_tokens", Name: "Bedrock Token Counting"}
+
+	model := configuredModel()
+	if model == "" {
+		result.Status, result.Code = "warn", codeModelUnset
+		result.Message = "ANTHROPIC_MODEL not set; no model to count tokens for"
+		result.Fix = "export ANTHROPIC_MODEL=<model-or-inference-profile-id>"
+		return result
+	}
+	// CountTokens takes a foundation model ID, not an inference profile.
+	modelID := baseModelID(model)
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		result.Fix = "Check AWS credentials and configuration"
+		return result
+	}
+
+	start := time.Now()
+	out, err := env.BedrockRuntime(cfg).CountTokens(ctx, &bedrockruntime.CountTokensInput{
+		ModelId: aws.String(modelID),
+		Input: &runtimetypes.CountTokensInputMemberInvokeModel{
+			Value: runtimetypes.InvokeModelTokensRequest{Body: []byte(throttleProbeBody)},
+		},
+	})
+	latency := time.Since(start)
+	logProbe("count_tokens", modelID, start, err)
+	if env.Verbose {
+		result.Metrics = map[string]float64{"count_tokens_ms": DurationMs(latency)}
+	}
+
+	// Claude Code estimates token counts when the API is missing, so none of
+	// these is worse than a warning.
+	const fallbackNote = "Claude Code will fall back to estimating token counts"
+	switch {
+	case err == nil:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("CountTokens works for %s (%d input tokens)", modelID, aws.ToInt32(out.InputTokens))
+		if env.Verbose {
+			result.Message += " in " + formatMs(latency)
+		}
+		return result
+	case countTokensUnavailable(err):
+		result.Status, result.Code = "warn", codeCountTokensUnavailable
+		result.Message = fmt.Sprintf("CountTokens is not available for %s in %s: %v; %s", modelID, region, err, fallbackNote)
+		result.Fix = "No action required; token counting becomes available when AWS enables it for this model and region"
+	case isAccessDenied(awsErrorCode(err)):
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Not authorized to call bedrock:CountTokens on %s: %v; %s", modelID, err, fallbackNote)
+		result.setAWSError(err, "bedrock:CountTokens")
+	default:
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("CountTokens failed for %s: %v; %s", modelID, err, fallbackNote)
+		result.Fix = "Check AWS credentials and configuration"
+		result.setAWSError(err, "bedrock:CountTokens")
+	}
+	result.attachDiagnostics(env, awsDiagnostics(err))
+	return result
+}
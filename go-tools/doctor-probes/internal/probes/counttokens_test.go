@@ -0,0 +1,99 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestCountTokensResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		respond  func(w http.ResponseWriter)
+		status   string
+		code     string
+		contains string
+	}{
+		{name: "no model", status: "warn", code: codeModelUnset, contains: "ANTHROPIC_MODEL not set"},
+		{
+			name:  "works",
+			model: "us.anthropic.claude-sonnet-4-20250514-v1:0",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"inputTokens":12}`))
+			},
+			status:   "pass",
+			contains: "CountTokens works for anthropic.claude-sonnet-4-20250514-v1:0 (12 input tokens)",
+		},
+		{
+			name:  "unknown operation",
+			model: "anthropic.claude-sonnet-4-20250514-v1:0",
+			respond: func(w http.ResponseWriter) {
+				probestest.AWSJSONError(w, http.StatusBadRequest, "UnknownOperationException", "")
+			},
+			status:   "warn",
+			code:     codeCountTokensUnavailable,
+			contains: "Claude Code will fall back to estimating token counts",
+		},
+		{
+			name:  "model not supported",
+			model: "anthropic.claude-sonnet-4-20250514-v1:0",
+			respond: func(w http.ResponseWriter) {
+				probestest.AWSJSONError(w, http.StatusBadRequest, "ValidationException", "The provided model doesn't support counting tokens.")
+			},
+			status: "warn",
+			code:   codeCountTokensUnavailable,
+		},
+		{
+			name:    "plain 404",
+			model:   "anthropic.claude-sonnet-4-20250514-v1:0",
+			respond: func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			status:  "warn",
+			code:    codeCountTokensUnavailable,
+		},
+		{
+			name:  "access denied",
+			model: "anthropic.claude-sonnet-4-20250514-v1:0",
+			respond: func(w http.ResponseWriter) {
+				probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+			},
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: "Not authorized to call bedrock:CountTokens",
+		},
+		{
+			name:  "other validation error",
+			model: "anthropic.claude-sonnet-4-20250514-v1:0",
+			respond: func(w http.ResponseWriter) {
+				probestest.AWSJSONError(w, http.StatusBadRequest, "ValidationException", "malformed input")
+			},
+			status:   "warn",
+			code:     codeAWSAPI,
+			contains: "CountTokens failed for",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/model/anthropic.claude-sonnet-4-20250514-v1:0/count-tokens" {
+					t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+				}
+				tt.respond(w)
+			})
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			got := countTokensResult(context.Background(), env, "us-east-1")
+			if got.ID != "bedrock.count_tokens" || got.Status != tt.status || got.Code != tt.code || !strings.Contains(got.Message, tt.contains) {
+				t.Errorf("got %s %s %s %q; want %s %s containing %q", got.ID, got.Status, got.Code, got.Message, tt.status, tt.code, tt.contains)
+			}
+			if got.Status == "fail" {
+				t.Error("CountTokens problems must never fail the run")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const credentialProcessTimeout = 30 * time.Second
+
+// credentialProcessOutput is the credential_process JSON contract. Only
+// presence of the secret fields is ever reported.
+type credentialProcessOutput struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// RedactCommand keeps the binary of a credential_process command line and
+// hides its arguments, which often carry tokens or account details.
+func RedactCommand(command string) string {
+	command = strings.TrimSpace(command)
+	binary, rest := command, ""
+	if strings.HasPrefix(command, `"`) {
+		if end := strings.Index(command[1:], `"`); end >= 0 {
+			binary, rest = command[:end+2], command[end+2:]
+		}
+	} else if i := strings.IndexAny(command, " \t"); i >= 0 {
+		binary, rest = command[:i], command[i:]
+	}
+	if strings.TrimSpace(rest) == "" {
+		return binary
+	}
+	return binary + " [arguments redacted]"
+}
+
+// credentialProcessResults runs the active profile's credential_process the
+// way the SDK does and validates its output. It returns nothing when the
+// profile does not use one.
+func credentialProcessResults() []CheckResult {
+	profile, shared, err := loadProfile()
+	if err != nil || shared == nil || shared.CredentialProcess == "" {
+		return nil
+	}
+	return []CheckResult{credentialProcessResult(profile, shared.CredentialProcess)}
+}
+
+func credentialProcessResult(profile, command string) CheckResult {
+	display := RedactCommand(command)
+	result := CheckResult{ID: "auth.credential_process", Name: "Credential Process"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), credentialProcessTimeout)
+	defer cancel()
+
+	// The SDK hands the command line to the platform shell.
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	logProbe("credential_process", display, start, err, "profile", profile)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Status, result.Code = "fail", codeCredprocTimeout
+		result.Message = fmt.Sprintf("%s (profile %s) did not finish within %s", display, profile, credentialProcessTimeout)
+		result.Fix = "Check whether the process is waiting for interactive input or a blocked network call"
+		return result
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.Status, result.Code = "fail", codeCredprocFailed
+		result.Message = fmt.Sprintf("%s (profile %s) exited %d: %s", display, profile, exitErr.ExitCode(), RedactSecrets(firstLine(stderr.String())))
+		result.Fix = "Run the credential_process command from ~/.aws/config directly to see its full output"
+		return result
+	}
+	if err != nil {
+		result.Status, result.Code = "fail", codeCredprocFailed
+		result.Message = fmt.Sprintf("Cannot run %s (profile %s): %v", display, profile, err)
+		result.Fix = "Check the credential_process path in ~/.aws/config and that the binary is executable"
+		return result
+	}
+
+	var out credentialProcessOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		result.Status, result.Code = "fail", codeCredprocInvalid
+		result.Message = fmt.Sprintf("%s (profile %s) printed invalid JSON: %v", display, profile, err)
+		result.Fix = "credential_process must print only the credentials JSON on stdout; send logs to stderr"
+		return result
+	}
+
+	// A zero exit with empty keys is accepted by the SDK and cached, so every
+	// call fails until the (absent) expiration passes.
+	if out.AccessKeyID == "" || out.SecretAccessKey == "" {
+		result.Status, result.Code = "fail", codeCredprocEmpty
+		result.Message = fmt.Sprintf("%s (profile %s) exited 0 with empty AccessKeyId/SecretAccessKey; the SDK caches these and every request fails", display, profile)
+		result.Fix = "The process must exit non-zero when it cannot obtain credentials; check its stderr and required environment (e.g. OIDC_ID_TOKEN)"
+		return result
+	}
+
+	var problems []string
+	if out.Version != 1 {
+		problems = append(problems, fmt.Sprintf("Version is %d, want 1", out.Version))
+	}
+	if out.Expiration != "" {
+		expiry, err := time.Parse(time.RFC3339, out.Expiration)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("Expiration %q is not RFC 3339", out.Expiration))
+		case !expiry.After(time.Now()):
+			result.Status, result.Code = "fail", codeCredprocExpired
+			result.Message = fmt.Sprintf("%s (profile %s) returned credentials that expired at %s", display, profile, out.Expiration)
+			result.Fix = "The process is returning stale cached credentials; clear its cache or fix its refresh logic"
+			return result
+		}
+	}
+	if len(problems) > 0 {
+		result.Status, result.Code = "fail", codeCredprocInvalid
+		result.Message = fmt.Sprintf("%s (profile %s) output violates the credential_process contract: %s", display, profile, strings.Join(problems, "; "))
+		result.Fix = "See https://docs.aws.amazon.com/sdkref/latest/guide/feature-process-credentials.html for the required format"
+		return result
+	}
+
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("%s (profile %s) returned valid credentials", display, profile)
+	if out.Expiration != "" {
+		result.Message += " expiring " + out.Expiration
+	}
+	return result
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	if s == "" {
+		return "(no stderr)"
+	}
+	return s
+}
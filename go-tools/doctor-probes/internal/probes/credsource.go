@@ -0,0 +1,172 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// credentialSource is one place the default credential chain can take
+// credentials from. Labels name the source, never the secret.
+type credentialSource struct {
+	Kind  string // env, web_identity, profile, container, imds
+	Label string
+}
+
+// keySuffix identifies an access key ID by its last four characters.
+func keySuffix(accessKeyID string) string {
+	if len(accessKeyID) < 4 {
+		return "…"
+	}
+	return "…" + accessKeyID[len(accessKeyID)-4:]
+}
+
+// configuredCredentialSources lists the credential sources present in the
+// environment and shared config the SDK loaded into cfg, in the priority
+// order of the default chain.
+func configuredCredentialSources(cfg aws.Config) []credentialSource {
+	var env *config.EnvConfig
+	var shared *config.SharedConfig
+	for _, src := range cfg.ConfigSources {
+		switch c := src.(type) {
+		case config.EnvConfig:
+			env = &c
+		case *config.EnvConfig:
+			env = c
+		case config.SharedConfig:
+			shared = &c
+		case *config.SharedConfig:
+			shared = c
+		}
+	}
+
+	var sources []credentialSource
+	if env != nil && env.Credentials.HasKeys() {
+		sources = append(sources, credentialSource{"env", fmt.Sprintf("environment variables (AWS_ACCESS_KEY_ID %s)", keySuffix(env.Credentials.AccessKeyID))})
+	}
+	if env != nil && env.WebIdentityTokenFilePath != "" {
+		sources = append(sources, credentialSource{"web_identity", fmt.Sprintf("web identity token from AWS_WEB_IDENTITY_TOKEN_FILE (role %s)", env.RoleARN)})
+	}
+	if shared != nil {
+		if via := sharedProfileVia(shared); via != "" {
+			sources = append(sources, credentialSource{"profile", fmt.Sprintf("shared profile '%s' via %s", shared.Profile, via)})
+		}
+	}
+	if env != nil && (env.ContainerCredentialsRelativePath != "" || env.ContainerCredentialsEndpoint != "") {
+		sources = append(sources, credentialSource{"container", "container credentials endpoint (AWS_CONTAINER_CREDENTIALS_*)"})
+	}
+	return sources
+}
+
+// sharedProfileVia describes how a shared config profile supplies
+// credentials, following the SDK's precedence, or "" when it supplies none.
+func sharedProfileVia(c *config.SharedConfig) string {
+	var via string
+	switch {
+	case c.Source != nil:
+		if via = sharedProfileVia(c.Source); via == "" {
+			via = "the default chain"
+		}
+		return fmt.Sprintf("role %s from source profile '%s' (%s)", c.RoleARN, c.SourceProfileName, via)
+	case c.Credentials.HasKeys():
+		via = fmt.Sprintf("static keys (aws_access_key_id %s)", keySuffix(c.Credentials.AccessKeyID))
+	case c.CredentialSource != "":
+		via = "credential_source " + c.CredentialSource
+	case c.WebIdentityTokenFile != "":
+		via = "web_identity_token_file"
+	case c.SSOSessionName != "" || c.SSOStartURL != "":
+		via = "SSO"
+	case c.CredentialProcess != "":
+		via = "credential_process " + RedactCommand(c.CredentialProcess)
+	default:
+		return ""
+	}
+	if c.RoleARN != "" {
+		via += ", assuming " + c.RoleARN
+	}
+	return via
+}
+
+// credentialSourceKind maps the Source of retrieved credentials to the kind
+// of chain element that produced them.
+func credentialSourceKind(source string) string {
+	switch {
+	case source == config.CredentialsSourceName:
+		return "env"
+	case source == "EC2RoleProvider":
+		return "imds"
+	case source == "CredentialsEndpointProvider":
+		return "container"
+	case source == "WebIdentityCredentials" && os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		return "web_identity"
+	case strings.HasPrefix(source, "SharedConfigCredentials"), source == "SSOProvider", source == "ProcessProvider",
+		source == "AssumeRoleProvider", source == "WebIdentityCredentials":
+		return "profile"
+	}
+	return ""
+}
+
+// credentialSourceResult reports which credential source the SDK resolved
+// and warns when other configured sources are being shadowed by it.
+func credentialSourceResult(ctx context.Context, env Env, region string) CheckResult {
+	result := CheckResult{ID: "auth.credential_source", Name: "Credential Source"}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	var creds aws.Credentials
+	if err == nil {
+		creds, err = cfg.Credentials.Retrieve(ctx)
+	}
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("No credentials resolved: %v", err)
+		result.Fix = "Configure a profile (aws configure sso) and export AWS_PROFILE, or see Bedrock API Access"
+		result.setAWSError(err, "sts:GetCallerIdentity")
+		return result
+	}
+
+	sources := configuredCredentialSources(cfg)
+	kind := credentialSourceKind(creds.Source)
+	winner := credentialSource{Kind: kind, Label: creds.Source}
+	found := false
+	var shadowed []string
+	for _, s := range sources {
+		if s.Kind == kind && !found {
+			winner, found = s, true
+			continue
+		}
+		shadowed = append(shadowed, s.Label)
+	}
+	if kind == "imds" {
+		winner.Label = "EC2 instance role (IMDS)"
+		identity, err := CallerIdentity(ctx, env, cfg)
+		if err == nil {
+			if role, ok := assumedRoleName(aws.ToString(identity.Arn)); ok {
+				winner.Label = fmt.Sprintf("EC2 instance role %s (IMDS)", role)
+			}
+		}
+	}
+
+	result.Message = "Using " + winner.Label
+	if kind != "env" {
+		result.Message += ", access key " + keySuffix(creds.AccessKeyID)
+	}
+	if len(shadowed) == 0 {
+		result.Status = "pass"
+		return result
+	}
+	result.Status, result.Code = "warn", codeCredentialsShadowed
+	result.Message += fmt.Sprintf("; also configured but ignored: %s", strings.Join(shadowed, "; "))
+	if kind == "env" {
+		result.Fix = "AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY in the environment take precedence over AWS_PROFILE. If they are stale, remove them (and AWS_SESSION_TOKEN) from your shell and its startup files"
+	} else {
+		result.Fix = "Remove the credential sources you do not use so it is clear which identity Claude Code runs as"
+	}
+	return result
+}
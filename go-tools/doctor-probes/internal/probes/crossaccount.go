@@ -0,0 +1,137 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/smithy-go"
+)
+
+// authzProbeBody is an InvokeModel body Bedrock rejects as invalid. Requests
+// are authorized before they are validated, so a ValidationException proves
+// the caller may invoke the target, an AccessDeniedException that it may not,
+// and nothing is generated or billed either way.
+const authzProbeBody = `{}`
+
+// crossAccountProfile is a configured inference profile ARN owned by
+// another account than the caller's.
+type crossAccountProfile struct {
+	Variable string
+	ARN      string
+	Owner    string // account that owns the profile
+}
+
+// crossAccountProfiles returns the configured model values that are
+// inference profile ARNs of an account other than caller.
+func crossAccountProfiles(caller string) []crossAccountProfile {
+	var profiles []crossAccountProfile
+	for _, name := range configuredModelVars {
+		value := strings.TrimSpace(os.Getenv(name))
+		a, err := arn.Parse(value)
+		if err != nil || !isInferenceProfile(value) || a.AccountID == "" || a.AccountID == caller {
+			continue
+		}
+		profiles = append(profiles, crossAccountProfile{Variable: name, ARN: value, Owner: a.AccountID})
+	}
+	return profiles
+}
+
+// apiErrorCode returns the AWS error code of err, or "".
+func apiErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// crossAccountGrantFix names both accounts and the two halves of a
+// cross-account grant, both of which are needed.
+func crossAccountGrantFix(p crossAccountProfile, caller string) string {
+	return fmt.Sprintf("Access to %s needs grants on both sides: in the owning account %s, the profile must be shared with account %s (resource policy or RAM share allowing bedrock:InvokeModel, bedrock:InvokeModelWithResponseStream and bedrock:GetInferenceProfile); in account %s, your role's IAM policy must allow the same actions on the profile ARN. Ask the admins of %s to add %s to the share, and your account admins to add the IAM allow",
+		p.ARN, p.Owner, caller, caller, p.Owner, caller)
+}
+
+// crossAccountProfileResults checks, for each configured inference profile
+// ARN owned by another account, that the caller may use it: GetInferenceProfile,
+// then an InvokeModel that is authorized but never runs. A denial is
+// reported as a missing cross-account grant. Same-account ARNs are left to
+// Model ID Format. It returns nothing when there are no cross-account ARNs.
+func crossAccountProfileResults(ctx context.Context, env Env, region string) []CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil
+	}
+	identity, err := CallerIdentity(ctx, env, cfg)
+	if err != nil {
+		// Without the caller's account there is nothing to compare; the
+		// credential checks report why.
+		return nil
+	}
+	caller := aws.ToString(identity.Account)
+
+	var results []CheckResult
+	for _, p := range crossAccountProfiles(caller) {
+		results = append(results, crossAccountProfileResult(ctx, env, cfg, p, caller))
+	}
+	return results
+}
+
+func crossAccountProfileResult(ctx context.Context, env Env, cfg aws.Config, p crossAccountProfile, caller string) CheckResult {
+	result := CheckResult{ID: "bedrock.cross_account", Name: "Cross-Account Inference Profile"}
+	where := fmt.Sprintf("%s (%s, owned by account %s; you are in %s)", p.ARN, p.Variable, p.Owner, caller)
+
+	start := time.Now()
+	_, getErr := env.Bedrock(cfg).GetInferenceProfile(ctx, &bedrock.GetInferenceProfileInput{
+		InferenceProfileIdentifier: aws.String(p.ARN),
+	})
+	logProbe("bedrock", "GetInferenceProfile", start, getErr, "profile", p.ARN)
+
+	start = time.Now()
+	_, invokeErr := env.BedrockRuntime(cfg).InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(p.ARN),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        []byte(authzProbeBody),
+	})
+	logProbe("invoke", p.ARN, start, invokeErr, "purpose", "cross_account_authz")
+
+	invokeCode := apiErrorCode(invokeErr)
+	switch {
+	case invokeCode == "ValidationException" || invokeErr == nil:
+		result.Status = "pass"
+		result.Message = "Authorized to invoke " + where
+		if getErr != nil {
+			result.Message += fmt.Sprintf("; GetInferenceProfile failed (%v), which only affects tools that describe the profile", getErr)
+		}
+	case invokeCode == "AccessDeniedException":
+		result.Status, result.Code = "fail", codeCrossAccountDenied
+		result.Message = fmt.Sprintf("Not authorized to invoke %s: %v", where, invokeErr)
+		result.Fix = crossAccountGrantFix(p, caller)
+		if class := classifyAWSError(invokeErr, "bedrock:InvokeModel"); class.Code == codeSCPDeny || class.Code == codeIAMExplicitDeny {
+			// An explicit deny on this side blocks it whatever the owner shares.
+			result.Code, result.Fix = class.Code, class.Fix
+		}
+		result.attachDiagnostics(env, awsDiagnostics(invokeErr))
+	case invokeCode == "ResourceNotFoundException":
+		result.Status, result.Code = "fail", codeCrossAccountDenied
+		result.Message = fmt.Sprintf("%s does not exist or is not shared with this account: %v", where, invokeErr)
+		result.Fix = fmt.Sprintf("Check the ARN with the owning account %s; if it is right, %s", p.Owner, crossAccountGrantFix(p, caller))
+	default:
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Cannot tell whether you may invoke %s: %v", where, invokeErr)
+		result.setAWSError(invokeErr, "bedrock:InvokeModel")
+		result.attachDiagnostics(env, awsDiagnostics(invokeErr))
+	}
+	return result
+}
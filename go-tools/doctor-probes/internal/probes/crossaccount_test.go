@@ -0,0 +1,134 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+const sharedProfile = "arn:aws:bedrock:us-east-1:444455556666:application-inference-profile/shared"
+
+func TestCrossAccountProfiles(t *testing.T) {
+	probestest.Unsetenv(t, configuredModelVars...)
+	t.Setenv("ANTHROPIC_MODEL", sharedProfile)
+	t.Setenv("ANTHROPIC_SMALL_FAST_MODEL", "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/own")
+	t.Setenv("ANTHROPIC_DEFAULT_OPUS_MODEL", "us.anthropic.claude-opus-4-1-20250805-v1:0")
+	t.Setenv("ANTHROPIC_DEFAULT_SONNET_MODEL", "arn:aws:bedrock:us-east-1:444455556666:foundation-model/anthropic.claude-3-haiku-20240307-v1:0")
+
+	got := crossAccountProfiles("123456789012")
+	want := []crossAccountProfile{{Variable: "ANTHROPIC_MODEL", ARN: sharedProfile, Owner: "444455556666"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crossAccountProfiles = %+v, want %+v", got, want)
+	}
+}
+
+func TestCrossAccountProfileResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		getDeny   bool
+		invoke    int    // status of InvokeModel
+		errorCode string // its error code
+		stsDeny   bool
+		status    string // empty means no result
+		code      string
+		contains  []string
+	}{
+		{name: "same account", model: "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/own", invoke: http.StatusBadRequest, errorCode: "ValidationException"},
+		{name: "caller unknown", model: sharedProfile, stsDeny: true},
+		{
+			name:      "authorized",
+			model:     sharedProfile,
+			invoke:    http.StatusBadRequest,
+			errorCode: "ValidationException",
+			status:    "pass",
+			contains:  []string{"Authorized to invoke " + sharedProfile + " (ANTHROPIC_MODEL, owned by account 444455556666; you are in 123456789012)"},
+		},
+		{
+			name:      "authorized without GetInferenceProfile",
+			model:     sharedProfile,
+			getDeny:   true,
+			invoke:    http.StatusBadRequest,
+			errorCode: "ValidationException",
+			status:    "pass",
+			contains:  []string{"; GetInferenceProfile failed", "only affects tools that describe the profile"},
+		},
+		{
+			name:      "not shared",
+			model:     sharedProfile,
+			getDeny:   true,
+			invoke:    http.StatusForbidden,
+			errorCode: "AccessDeniedException",
+			status:    "fail",
+			code:      codeCrossAccountDenied,
+			contains:  []string{"Not authorized to invoke " + sharedProfile, "in the owning account 444455556666, the profile must be shared with account 123456789012", "in account 123456789012, your role's IAM policy"},
+		},
+		{
+			name:      "not found",
+			model:     sharedProfile,
+			invoke:    http.StatusNotFound,
+			errorCode: "ResourceNotFoundException",
+			status:    "fail",
+			code:      codeCrossAccountDenied,
+			contains:  []string{"does not exist or is not shared with this account", "Check the ARN with the owning account 444455556666"},
+		},
+		{
+			name:      "throttled",
+			model:     sharedProfile,
+			invoke:    http.StatusTooManyRequests,
+			errorCode: "ThrottlingException",
+			status:    "warn",
+			code:      codeThrottled,
+			contains:  []string{"Cannot tell whether you may invoke " + sharedProfile},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				switch {
+				case r.Form.Get("Action") == "GetCallerIdentity":
+					if tt.stsDeny {
+						queryError(w, "AccessDenied", "not authorized")
+						return
+					}
+					probestest.CallerIdentity(w, "arn:aws:iam::123456789012:user/dev")
+				case strings.HasPrefix(r.URL.Path, "/inference-profiles/") && !tt.getDeny:
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"inferenceProfileArn":"` + sharedProfile + `","status":"ACTIVE","type":"APPLICATION"}`))
+				case strings.HasSuffix(r.URL.Path, "/invoke"):
+					probestest.AWSJSONError(w, tt.invoke, tt.errorCode, "probe")
+				default:
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+				}
+			})
+			probestest.Unsetenv(t, configuredModelVars...)
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			got := crossAccountProfileResults(context.Background(), NewEnv(RunSettings{}), "us-east-1")
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "bedrock.cross_account" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
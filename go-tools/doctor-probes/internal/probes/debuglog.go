@@ -0,0 +1,72 @@
+package probes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/logging"
+)
+
+// debugLog receives one structured record per probe attempt. It discards
+// everything unless --log-file (or BCCE_DOCTOR_LOG) is set.
+var debugLog = slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+// OpenDebugLog opens path for appending debug logs.
+func OpenDebugLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+}
+
+// EnableDebugLog points debugLog at w and returns the SDK config options
+// that wire SDK request logging into it, for RunSettings.AWSOptions.
+func EnableDebugLog(w io.Writer) []func(*config.LoadOptions) error {
+	debugLog = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Value.Kind() == slog.KindString {
+				a.Value = slog.StringValue(RedactSecrets(a.Value.String()))
+			}
+			return a
+		},
+	}))
+	return []func(*config.LoadOptions) error{
+		config.WithLogger(sdkLogger{}),
+		config.WithClientLogMode(aws.LogRetries | aws.LogRequest),
+	}
+}
+
+// sdkLogger forwards SDK log output to debugLog at debug level.
+type sdkLogger struct{}
+
+func (sdkLogger) Logf(classification logging.Classification, format string, v ...interface{}) {
+	debugLog.Debug("aws sdk", "classification", string(classification), "detail", fmt.Sprintf(format, v...))
+}
+
+// errorChain lists err and every error it wraps, outermost first.
+func errorChain(err error) []string {
+	var chain []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, RedactSecrets(err.Error()))
+	}
+	return chain
+}
+
+// logProbe records one probe attempt against target.
+func logProbe(probe, target string, start time.Time, err error, attrs ...any) {
+	attrs = append([]any{
+		"probe", probe,
+		"target", target,
+		"start", start.UTC(),
+		"duration_ms", DurationMs(time.Since(start)),
+	}, attrs...)
+	if err != nil {
+		debugLog.Debug("probe failed", append(attrs, "error_chain", errorChain(err))...)
+		return
+	}
+	debugLog.Debug("probe ok", attrs...)
+}
@@ -0,0 +1,55 @@
+package probes
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+func checkDNS(env Env, host string) ([]string, error) {
+	start := time.Now()
+	addrs, err := LookupHost(env, host)
+	logProbe("dns", host, start, err, "resolver", env.dnsResolverName(), "addrs", addrs)
+	return addrs, err
+}
+
+// dnsResult resolves the host of the named endpoint with retries. It also
+// returns the addresses and the error of the last attempt.
+func dnsResult(env Env, name, host, region string, fips bool) (CheckResult, []string, error) {
+	var addrs []string
+	failures, err := retryProbe(func() (err error) {
+		addrs, err = checkDNS(env, host)
+		return err
+	})
+	var result CheckResult
+	var dnsErr *net.DNSError
+	if fips && errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		result = CheckResult{
+			ID:      checkID("dns", name),
+			Code:    codeFIPSUnavailable,
+			Name:    fmt.Sprintf("DNS - %s", name),
+			Status:  "fail",
+			Message: fmt.Sprintf("No FIPS endpoint for %s in %s (%s does not exist)", name, region, host),
+			Fix:     "Use a region where this service offers FIPS endpoints",
+		}
+	} else if err != nil {
+		result = CheckResult{
+			ID:      checkID("dns", name),
+			Code:    classifyNetError(err),
+			Name:    fmt.Sprintf("DNS - %s", name),
+			Status:  "fail",
+			Message: fmt.Sprintf("Failed to resolve %s%s: %v", host, env.dnsVia(), err),
+			Fix:     "Check internet connectivity and DNS settings" + env.Host.dnsHint(),
+		}
+	} else {
+		result = CheckResult{
+			ID:      checkID("dns", name),
+			Name:    fmt.Sprintf("DNS - %s", name),
+			Status:  "pass",
+			Message: fmt.Sprintf("Resolved %s%s", host, env.dnsVia()),
+		}
+	}
+	result.noteRetries(failures, err)
+	return result, addrs, err
+}
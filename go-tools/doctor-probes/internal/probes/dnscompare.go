@@ -0,0 +1,186 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const DefaultDNSResolvers = "1.1.1.1,8.8.8.8"
+
+// NewResolver returns a resolver that sends every query to server
+// ("host:port") instead of the system-configured resolver.
+func NewResolver(server string) Resolver {
+	return serverResolver{server: server, base: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, server)
+		},
+	}}
+}
+
+// serverResolver queries one server. net.Resolver's errors name the
+// nameserver of resolv.conf even when Dial sent the query elsewhere, so it
+// names server in them instead.
+type serverResolver struct {
+	server string
+	base   *net.Resolver
+}
+
+func (r serverResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.base.LookupHost(ctx, host)
+	return addrs, r.named(err)
+}
+
+func (r serverResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	ips, err := r.base.LookupIP(ctx, network, host)
+	return ips, r.named(err)
+}
+
+func (r serverResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	cname, err := r.base.LookupCNAME(ctx, host)
+	return cname, r.named(err)
+}
+
+func (r serverResolver) named(err error) error {
+	dnsErr, ok := err.(*net.DNSError)
+	if !ok {
+		return err
+	}
+	named := *dnsErr
+	named.Server = r.server
+	return &named
+}
+
+// ParseResolvers turns "1.1.1.1,8.8.8.8:53" into dialable "host:port" values.
+func ParseResolvers(spec string) []string {
+	var servers []string
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		servers = append(servers, s)
+	}
+	return servers
+}
+
+func lookupWith(r Resolver, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return r.LookupHost(ctx, host)
+}
+
+// answerKind summarizes who an answer points at: "none", "private" (RFC 1918,
+// ULA, or other non-public ranges), "public", or "mixed".
+func answerKind(addrs []string) string {
+	if len(addrs) == 0 {
+		return "none"
+	}
+	private, public := 0, 0
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		if ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+			private++
+		} else {
+			public++
+		}
+	}
+	switch {
+	case public == 0:
+		return "private"
+	case private == 0:
+		return "public"
+	default:
+		return "mixed"
+	}
+}
+
+type dnsAnswer struct {
+	Resolver string
+	Addrs    []string
+	Err      error
+}
+
+func (a dnsAnswer) String() string {
+	if a.Err != nil {
+		return fmt.Sprintf("%s: error (%v)", a.Resolver, a.Err)
+	}
+	return fmt.Sprintf("%s: %s (%s)", a.Resolver, answerKind(a.Addrs), strings.Join(a.Addrs, ", "))
+}
+
+// dnsCompareResult compares the system resolver's answer for host against
+// the given external resolvers. It skips when none of them answers, as
+// when port 53 egress is blocked, since then nothing was compared.
+func dnsCompareResult(env Env, name, host string, system dnsAnswer, servers []string) CheckResult {
+	answers := []dnsAnswer{system}
+	for _, server := range servers {
+		start := time.Now()
+		addrs, err := lookupWith(env.ResolverFor(server), host)
+		logProbe("dns", host, start, err, "resolver", server, "addrs", addrs)
+		answers = append(answers, dnsAnswer{Resolver: server, Addrs: addrs, Err: err})
+	}
+
+	parts := make([]string, len(answers))
+	for i, a := range answers {
+		parts[i] = a.String()
+	}
+	result := CheckResult{
+		ID:      checkID("dns_compare", name),
+		Name:    fmt.Sprintf("DNS Compare - %s", name),
+		Status:  "pass",
+		Message: strings.Join(parts, "; "),
+	}
+
+	// Only answers from external resolvers that actually responded are
+	// useful for comparison; port 53 egress is often blocked.
+	var external []dnsAnswer
+	for _, a := range answers[1:] {
+		if a.Err == nil {
+			external = append(external, a)
+		}
+	}
+	if len(servers) == 0 {
+		result.Status = "skip"
+		result.Message = "No external resolvers to compare the system answer with"
+		return result
+	}
+	if len(external) == 0 {
+		result.Status = "skip"
+		result.Message = fmt.Sprintf("No external resolver answered, so there is nothing to compare the system answer with (%s); outbound DNS to them may be blocked", strings.Join(parts[1:], "; "))
+		return result
+	}
+
+	systemKind := answerKind(system.Addrs)
+	if system.Err != nil {
+		systemKind = "none"
+	}
+	externalKind := answerKind(external[0].Addrs)
+	vpceConfigured := env.IsVPCEndpoint()
+
+	switch {
+	case systemKind == "none" && externalKind != "none":
+		result.Status = "warn"
+		result.Code = codeDNSMismatch
+		result.Message += "; system resolver returns no answer while public resolvers do"
+		result.Fix = fmt.Sprintf("Corporate DNS appears to filter %s; ask IT to allow *.amazonaws.com", host)
+	case systemKind == "private" && externalKind == "public" && !vpceConfigured:
+		result.Status = "warn"
+		result.Code = codeDNSMismatch
+		result.Message += "; system resolver returns private IPs while public resolvers return public ones"
+		result.Fix = "Split-horizon DNS detected: confirm a Bedrock VPC endpoint with private DNS is intended, otherwise the private answer may be a DNS hijack or block page"
+	case systemKind != externalKind && systemKind != "private":
+		result.Status = "warn"
+		result.Code = codeDNSMismatch
+		result.Message += fmt.Sprintf("; answers disagree (system %s, external %s)", systemKind, externalKind)
+		result.Fix = "Compare your DNS configuration with IT; the system resolver may be rewriting AWS answers"
+	}
+
+	return result
+}
@@ -0,0 +1,176 @@
+package probes
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeDNS serves UDP DNS on loopback, answering every A query with addr and
+// every other query with no records. It returns the server's "host:port".
+func fakeDNS(t *testing.T, addr string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ip := net.ParseIP(addr).To4()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 12 {
+				continue
+			}
+			// The question ends after its name's labels, type, and class.
+			end := 12
+			for end < n && buf[end] != 0 {
+				end += int(buf[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(buf[end-4:])
+			reply := append([]byte{}, buf[:end]...)
+			reply[2], reply[3] = 0x81, 0x80           // response, recursion available
+			binary.BigEndian.PutUint16(reply[8:], 0)  // no authority records
+			binary.BigEndian.PutUint16(reply[10:], 0) // no additional records
+			binary.BigEndian.PutUint16(reply[6:], 0)  // no answers, unless A
+			if qtype == 1 {
+				binary.BigEndian.PutUint16(reply[6:], 1)
+				reply = append(reply, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				reply = append(reply, ip...)
+			}
+			conn.WriteTo(reply, from)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestParseResolvers(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{DefaultDNSResolvers, []string{"1.1.1.1:53", "8.8.8.8:53"}},
+		{" 9.9.9.9:5353 , ,10.0.0.2", []string{"9.9.9.9:5353", "10.0.0.2:53"}},
+		{"2606:4700:4700::1111", []string{"[2606:4700:4700::1111]:53"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseResolvers(tt.spec); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseResolvers(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerKind(t *testing.T) {
+	tests := []struct {
+		addrs []string
+		want  string
+	}{
+		{nil, "none"},
+		{[]string{"52.94.0.1"}, "public"},
+		{[]string{"10.0.1.5", "172.16.0.9", "192.168.1.1"}, "private"},
+		{[]string{"fd00::1", "127.0.0.1", "169.254.1.1", "0.0.0.0"}, "private"},
+		{[]string{"10.0.1.5", "52.94.0.1"}, "mixed"},
+		{[]string{"2600:1f18::1"}, "public"},
+	}
+	for _, tt := range tests {
+		if got := answerKind(tt.addrs); got != tt.want {
+			t.Errorf("answerKind(%v) = %q, want %q", tt.addrs, got, tt.want)
+		}
+	}
+}
+
+func TestDNSCompareResult(t *testing.T) {
+	public := fakeDNS(t, "52.94.0.1")
+	private := fakeDNS(t, "10.0.8.8")
+	tests := []struct {
+		name     string
+		system   dnsAnswer
+		servers  []string
+		endpoint string
+		status   string
+		contains string
+	}{
+		{
+			name:     "answers agree",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"52.94.0.7"}},
+			servers:  []string{public},
+			status:   "pass",
+			contains: public + ": public (52.94.0.1)",
+		},
+		{
+			name:     "system resolver filters the name",
+			system:   dnsAnswer{Resolver: "system", Err: &net.DNSError{Err: "no such host", IsNotFound: true}},
+			servers:  []string{public},
+			status:   "warn",
+			contains: "system resolver returns no answer while public resolvers do",
+		},
+		{
+			name:     "split horizon",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"10.0.1.5"}},
+			servers:  []string{public},
+			status:   "warn",
+			contains: "system resolver returns private IPs while public resolvers return public ones",
+		},
+		{
+			name:     "split horizon for a configured VPC endpoint",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"10.0.1.5"}},
+			servers:  []string{public},
+			endpoint: "https://vpce-0abc-123.bedrock-runtime.us-east-1.vpce.amazonaws.com",
+			status:   "pass",
+		},
+		{
+			name:     "answers disagree",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"52.94.0.7"}},
+			servers:  []string{private},
+			status:   "warn",
+			contains: "answers disagree (system public, external private)",
+		},
+		{
+			name:     "external resolvers blocked",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"10.0.1.5"}},
+			servers:  []string{"127.0.0.1:1"},
+			status:   "skip",
+			contains: "No external resolver answered, so there is nothing to compare the system answer with (127.0.0.1:1: error (lookup bedrock-runtime.us-east-1.amazonaws.com on 127.0.0.1:1: ",
+		},
+		{
+			name:     "one external resolver blocked",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"52.94.0.7"}},
+			servers:  []string{"127.0.0.1:1", public},
+			status:   "pass",
+			contains: "127.0.0.1:1: error (lookup bedrock-runtime.us-east-1.amazonaws.com on 127.0.0.1:1: ",
+		},
+		{
+			name:     "no external resolvers",
+			system:   dnsAnswer{Resolver: "system", Addrs: []string{"52.94.0.7"}},
+			status:   "skip",
+			contains: "No external resolvers to compare the system answer with",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withEndpoint(t, &env, tt.endpoint)
+			got := dnsCompareResult(env, "Bedrock Runtime", "bedrock-runtime.us-east-1.amazonaws.com", tt.system, tt.servers)
+			if got.Name != "DNS Compare - Bedrock Runtime" || got.Status != tt.status {
+				t.Errorf("name, status = %q, %s; want DNS Compare - Bedrock Runtime, %s", got.Name, got.Status, tt.status)
+			}
+			if !strings.Contains(got.Message, tt.contains) {
+				t.Errorf("message %q does not contain %q", got.Message, tt.contains)
+			}
+			if (got.Status == "warn") != (got.Fix != "") {
+				t.Errorf("status %s with fix %q", got.Status, got.Fix)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package probes
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsResolverName names the resolver checkDNS uses, for messages and logs.
+func (s RunSettings) dnsResolverName() string {
+	if s.DNSServer != "" {
+		return "resolver " + s.DNSServer
+	}
+	return "system"
+}
+
+// dnsVia is " via resolver <server>" when --resolver is set, for DNS check
+// messages.
+func (s RunSettings) dnsVia() string {
+	if s.DNSServer != "" {
+		return " via " + s.dnsResolverName()
+	}
+	return ""
+}
+
+// ParseDoHURL checks that spec is an https URL of a DNS-over-HTTPS server,
+// e.g. https://cloudflare-dns.com/dns-query.
+func ParseDoHURL(spec string) (*url.URL, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an https:// URL", spec)
+	}
+	if u.RawQuery != "" {
+		return nil, fmt.Errorf("%q has a query; give the server's path only, e.g. https://cloudflare-dns.com/dns-query", spec)
+	}
+	return u, nil
+}
+
+// dnsLookup is one method's answer for a host.
+type dnsLookup struct {
+	Method  string
+	Addrs   []string
+	CNAMEs  []string // the names host is an alias of, in order
+	Elapsed time.Duration
+	Err     error
+}
+
+// lookupResolver resolves host with r and, since net.Resolver only reports
+// the end of a CNAME chain, its canonical name.
+func lookupResolver(method string, r Resolver, host string) dnsLookup {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := r.LookupHost(ctx, host)
+	l := dnsLookup{Method: method, Addrs: addrs, Elapsed: time.Since(start), Err: err}
+	logProbe("dns", host, start, err, "resolver", method, "addrs", addrs)
+	if err == nil {
+		if cname, err := r.LookupCNAME(ctx, host); err == nil && !strings.EqualFold(strings.TrimSuffix(cname, "."), host) {
+			l.CNAMEs = []string{strings.TrimSuffix(cname, ".")}
+		}
+	}
+	return l
+}
+
+// lookupDoH resolves host's A and AAAA records with DNS-over-HTTPS (RFC 8484
+// GET) through server.
+func lookupDoH(env Env, server *url.URL, host string) dnsLookup {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l := dnsLookup{Method: "doh " + server.String()}
+	start := time.Now()
+	var errs []error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		addrs, cnames, err := dohQuery(ctx, env, server, host, qtype)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", strings.TrimPrefix(qtype.String(), "Type"), err))
+			continue
+		}
+		l.Addrs = append(l.Addrs, addrs...)
+		if len(cnames) > len(l.CNAMEs) {
+			l.CNAMEs = cnames
+		}
+	}
+	l.Elapsed = time.Since(start)
+	// A name with only IPv4 or only IPv6 addresses is answered; both
+	// queries failing is not.
+	if len(errs) == 2 {
+		l.Err = errors.Join(errs...)
+	}
+	if l.Err == nil && len(l.Addrs) == 0 {
+		l.Err = &net.DNSError{Err: "no such host", Name: host, Server: server.Host, IsNotFound: true}
+	}
+	logProbe("dns", host, start, l.Err, "resolver", l.Method, "addrs", l.Addrs)
+	return l
+}
+
+// dohQuery sends one query to server as a base64url-encoded GET parameter and
+// returns the addresses and CNAME targets of the answer.
+func dohQuery(ctx context.Context, env Env, server *url.URL, host string, qtype dnsmessage.Type) (addrs, cnames []string, err error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, nil, err
+	}
+	// ID 0 keeps the request cacheable, as RFC 8484 recommends.
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	wire, err := query.Pack()
+	if err != nil {
+		return nil, nil, err
+	}
+	u := *server
+	u.RawQuery = "dns=" + base64.RawURLEncoding.EncodeToString(wire)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := env.HTTP(server.Hostname()).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("DoH server answered HTTP %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/dns-message") {
+		return nil, nil, fmt.Errorf("DoH server answered %q, not application/dns-message (a proxy block page?)", ct)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, nil, fmt.Errorf("malformed DNS answer: %w", err)
+	}
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, nil, &net.DNSError{Err: "no such host", Name: host, Server: server.Host, IsNotFound: true}
+	default:
+		return nil, nil, &net.DNSError{Err: "server answered " + answer.RCode.String(), Name: host, Server: server.Host}
+	}
+	for _, rr := range answer.Answers {
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			cnames = append(cnames, strings.TrimSuffix(body.CNAME.String(), "."))
+		}
+	}
+	return addrs, cnames, nil
+}
+
+// describe summarizes the lookup; verbose adds the CNAME chain.
+func (l dnsLookup) describe(verbose bool) string {
+	if l.Err != nil {
+		return fmt.Sprintf("%s: error in %s (%v)", l.Method, l.Elapsed.Round(time.Millisecond), l.Err)
+	}
+	s := fmt.Sprintf("%s: %s in %s (%s)", l.Method, answerKind(l.Addrs), l.Elapsed.Round(time.Millisecond), strings.Join(l.Addrs, ", "))
+	// A CNAME to a vpce-... name shows a VPC endpoint answered.
+	if verbose && len(l.CNAMEs) > 0 {
+		s += " via CNAME " + strings.Join(l.CNAMEs, " -> ")
+	}
+	return s
+}
+
+// dnsMethodsResult resolves host with the system resolver and with --resolver
+// and --doh when given, and reports their answers and response times side by
+// side. Methods that disagree on whether host is public, private, or missing
+// warn.
+func dnsMethodsResult(env Env, name, host, server string, doh *url.URL) CheckResult {
+	lookups := []dnsLookup{lookupResolver("system", env.ResolverFor(""), host)}
+	if server != "" {
+		lookups = append(lookups, lookupResolver("resolver "+server, env.ResolverFor(server), host))
+	}
+	if doh != nil {
+		lookups = append(lookups, lookupDoH(env, doh, host))
+	}
+
+	result := CheckResult{
+		ID:      checkID("dns_methods", name),
+		Name:    fmt.Sprintf("DNS Methods - %s", name),
+		Status:  "pass",
+		Metrics: map[string]float64{},
+	}
+	parts := make([]string, len(lookups))
+	kinds := make([]string, len(lookups))
+	for i, l := range lookups {
+		parts[i] = l.describe(env.Verbose)
+		kinds[i] = "none"
+		if l.Err == nil {
+			kinds[i] = answerKind(l.Addrs)
+		}
+		// Keyed by method, not server, so the metrics compare across runs.
+		method, _, _ := strings.Cut(l.Method, " ")
+		result.Metrics[method+"_ms"] = DurationMs(l.Elapsed)
+	}
+	result.Message = strings.Join(parts, "; ")
+
+	if slices.ContainsFunc(kinds, func(k string) bool { return k != kinds[0] }) {
+		groups := make([]string, len(lookups))
+		for i, l := range lookups {
+			groups[i] = l.Method + " " + kinds[i]
+		}
+		result.Status, result.Code = "warn", codeDNSMismatch
+		result.Message += "; methods disagree: " + strings.Join(groups, ", ")
+		result.Fix = fmt.Sprintf("The resolvers give different answers for %s; a private answer from only one of them usually means a VPC endpoint's private DNS is visible to it alone, and no answer means that path filters AWS names", host)
+	}
+	return result
+}
@@ -0,0 +1,228 @@
+package probes
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseDoHURL(t *testing.T) {
+	tests := []struct {
+		spec string
+		ok   bool
+	}{
+		{"https://cloudflare-dns.com/dns-query", true},
+		{"https://10.0.0.2:8443/dns-query", true},
+		{"http://cloudflare-dns.com/dns-query", false},
+		{"cloudflare-dns.com", false},
+		{"https://dns.google/resolve?name=x", false},
+	}
+	for _, tt := range tests {
+		if _, err := ParseDoHURL(tt.spec); (err == nil) != tt.ok {
+			t.Errorf("parseDoHURL(%q) error = %v, want ok %v", tt.spec, err, tt.ok)
+		}
+	}
+}
+
+// dohAnswer is how the fake DoH server answers one query type.
+type dohAnswer struct {
+	rcode dnsmessage.RCode
+	cname string
+	addrs []string
+}
+
+// dohServer serves RFC 8484 GET queries over TLS, answering A and AAAA
+// queries from answers. It returns an environment whose HTTP client reaches
+// the server, and the server's URL.
+// contentType overrides the answer's Content-Type when set.
+func dohServer(t *testing.T, answers map[dnsmessage.Type]dohAnswer, contentType string) (Env, *url.URL) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wire, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		var query dnsmessage.Message
+		if err == nil {
+			err = query.Unpack(wire)
+		}
+		if err != nil || len(query.Questions) != 1 || r.Header.Get("Accept") != "application/dns-message" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		q := query.Questions[0]
+		a := answers[q.Type]
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: a.rcode},
+			Questions: query.Questions,
+		}
+		hdr := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60}
+		if a.cname != "" {
+			target := dnsmessage.MustNewName(a.cname + ".")
+			reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.CNAMEResource{CNAME: target}})
+			hdr.Name = target
+		}
+		for _, addr := range a.addrs {
+			ip := net.ParseIP(addr)
+			if ip4 := ip.To4(); ip4 != nil {
+				reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AResource{A: [4]byte(ip4)}})
+			} else {
+				reply.Answers = append(reply.Answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AAAAResource{AAAA: [16]byte(ip)}})
+			}
+		}
+		out, err := reply.Pack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if contentType == "" {
+			contentType = "application/dns-message"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(out)
+	}))
+	t.Cleanup(srv.Close)
+
+	env := NewEnv(RunSettings{})
+	env.HTTP = func(string) HTTPDoer { return srv.Client() }
+	u, _ := url.Parse(srv.URL + "/dns-query")
+	return env, u
+}
+
+func TestLookupDoH(t *testing.T) {
+	const host = "bedrock-runtime.us-east-1.amazonaws.com"
+	tests := []struct {
+		name        string
+		answers     map[dnsmessage.Type]dohAnswer
+		contentType string
+		addrs       []string
+		cnames      []string
+		err         string // substring; empty means no error
+	}{
+		{
+			name: "IPv4 through a VPC endpoint alias",
+			answers: map[dnsmessage.Type]dohAnswer{
+				dnsmessage.TypeA: {cname: "vpce-0abc.bedrock-runtime.us-east-1.vpce.amazonaws.com", addrs: []string{"10.0.1.5", "10.0.2.5"}},
+			},
+			addrs:  []string{"10.0.1.5", "10.0.2.5"},
+			cnames: []string{"vpce-0abc.bedrock-runtime.us-east-1.vpce.amazonaws.com"},
+		},
+		{
+			name: "dual stack",
+			answers: map[dnsmessage.Type]dohAnswer{
+				dnsmessage.TypeA:    {addrs: []string{"52.94.0.1"}},
+				dnsmessage.TypeAAAA: {addrs: []string{"2600:1f18::1"}},
+			},
+			addrs: []string{"52.94.0.1", "2600:1f18::1"},
+		},
+		{
+			name:    "no records",
+			answers: map[dnsmessage.Type]dohAnswer{},
+			err:     "no such host",
+		},
+		{
+			name: "NXDOMAIN",
+			answers: map[dnsmessage.Type]dohAnswer{
+				dnsmessage.TypeA:    {rcode: dnsmessage.RCodeNameError},
+				dnsmessage.TypeAAAA: {rcode: dnsmessage.RCodeNameError},
+			},
+			err: "no such host",
+		},
+		{
+			name: "one query failing is an answer",
+			answers: map[dnsmessage.Type]dohAnswer{
+				dnsmessage.TypeA:    {addrs: []string{"52.94.0.1"}},
+				dnsmessage.TypeAAAA: {rcode: dnsmessage.RCodeServerFailure},
+			},
+			addrs: []string{"52.94.0.1"},
+		},
+		{
+			name:        "block page",
+			answers:     map[dnsmessage.Type]dohAnswer{dnsmessage.TypeA: {addrs: []string{"52.94.0.1"}}},
+			contentType: "text/html",
+			err:         `A: DoH server answered "text/html", not application/dns-message`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, server := dohServer(t, tt.answers, tt.contentType)
+			l := lookupDoH(env, server, host)
+			if !strings.HasPrefix(l.Method, "doh https://127.0.0.1:") {
+				t.Errorf("method = %q", l.Method)
+			}
+			if tt.err != "" {
+				if l.Err == nil || !strings.Contains(l.Err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", l.Err, tt.err)
+				}
+				return
+			}
+			if l.Err != nil || !reflect.DeepEqual(l.Addrs, tt.addrs) || !reflect.DeepEqual(l.CNAMEs, tt.cnames) {
+				t.Errorf("lookupDoH = %v, %v, %v; want %v, %v", l.Addrs, l.CNAMEs, l.Err, tt.addrs, tt.cnames)
+			}
+		})
+	}
+}
+
+func TestLookupResolver(t *testing.T) {
+	l := lookupResolver("resolver test", NewResolver(fakeDNS(t, "52.94.0.1")), "bedrock-runtime.example.test")
+	if l.Err != nil || !reflect.DeepEqual(l.Addrs, []string{"52.94.0.1"}) {
+		t.Errorf("lookupResolver = %v, %v; want the fake server's answer", l.Addrs, l.Err)
+	}
+	l = lookupResolver("resolver test", NewResolver("127.0.0.1:1"), "bedrock-runtime.example.test")
+	if l.Err == nil || !strings.HasPrefix(l.describe(false), "resolver test: error in ") {
+		t.Errorf("lookupResolver = %v, %v; want an error from an unreachable server", l.Addrs, l.Err)
+	}
+}
+
+func TestDNSMethodsResult(t *testing.T) {
+	// localhost resolves the same way everywhere: from the hosts file for
+	// the system and --resolver methods, and from the fake for DoH.
+	tests := []struct {
+		name     string
+		doh      []string // the DoH server's A answer
+		status   string
+		contains []string
+	}{
+		{
+			name:     "methods agree",
+			doh:      []string{"127.0.0.1"},
+			status:   "pass",
+			contains: []string{"system: private in ", "; resolver 127.0.0.1:", "; doh https://127.0.0.1:"},
+		},
+		{
+			name:     "DoH sees the public endpoint",
+			doh:      []string{"52.94.0.1"},
+			status:   "warn",
+			contains: []string{"methods disagree: system private, resolver ", " private, doh https://127.0.0.1:", "/dns-query public"},
+		},
+		{
+			name:     "DoH has no answer",
+			status:   "warn",
+			contains: []string{"/dns-query none", "no answer means that path filters AWS names"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, server := dohServer(t, map[dnsmessage.Type]dohAnswer{dnsmessage.TypeA: {addrs: tt.doh}}, "")
+			r := dnsMethodsResult(env, "Bedrock Runtime", "localhost", fakeDNS(t, "52.94.0.1"), server)
+			if r.ID != "dns_methods.bedrock_runtime" || r.Status != tt.status || (r.Status == "warn") != (r.Code == codeDNSMismatch) {
+				t.Errorf("got %s %s %s (%s), want %s", r.ID, r.Status, r.Code, r.Message, tt.status)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			for _, key := range []string{"system_ms", "resolver_ms", "doh_ms"} {
+				if _, ok := r.Metrics[key]; !ok {
+					t.Errorf("metrics = %v, want %s", r.Metrics, key)
+				}
+			}
+		})
+	}
+}
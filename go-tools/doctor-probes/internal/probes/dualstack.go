@@ -0,0 +1,147 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+type familyResult struct {
+	Addrs     []net.IP
+	Reachable bool
+	Err       error
+}
+
+// probeFamily resolves host for one address family ("ip4" or "ip6") and
+// attempts a TCP connection to port 443 on the first address returned.
+func probeFamily(env Env, host, family string) familyResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := env.ResolverFor("").LookupIP(ctx, family, host)
+	logProbe("dns", host, start, err, "family", family, "addrs", addrs)
+	if err != nil || len(addrs) == 0 {
+		return familyResult{Err: err}
+	}
+
+	network := "tcp4"
+	if family == "ip6" {
+		network = "tcp6"
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	address := net.JoinHostPort(addrs[0].String(), "443")
+	start = time.Now()
+	var conn net.Conn
+	err = env.Engine.do(ctx, host, func() (err error) {
+		conn, err = dialer.DialContext(ctx, network, address)
+		return err
+	})
+	logProbe("tcp", host, start, err, "address", address)
+	if err != nil {
+		return familyResult{Addrs: addrs, Err: err}
+	}
+	conn.Close()
+
+	return familyResult{Addrs: addrs, Reachable: true}
+}
+
+// lookupFailed reports whether the family's DNS lookup failed without an
+// answer, as on a timeout or SERVFAIL, so whether it has records is
+// unknown. An IP literal of the other family is no records.
+func (r familyResult) lookupFailed() bool {
+	var dnsErr *net.DNSError
+	return len(r.Addrs) == 0 && errors.As(r.Err, &dnsErr) && !dnsErr.IsNotFound
+}
+
+// notFound reports whether err is a resolver's answer that the name has no
+// records (NXDOMAIN, or none of the type asked for), rather than a failure
+// to get an answer.
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func addressFamilyResult(env Env, name, host string) CheckResult {
+	return familiesResult(name, host, probeFamily(env, host, "ip4"), probeFamily(env, host, "ip6"))
+}
+
+// familiesResult reports the IPv4 and IPv6 probes of host.
+func familiesResult(name, host string, v4, v6 familyResult) CheckResult {
+	describe := func(label string, r familyResult) string {
+		switch {
+		case r.lookupFailed():
+			return fmt.Sprintf("%s lookup failed (%v)", label, r.Err)
+		case len(r.Addrs) == 0:
+			return label + " no records"
+		case r.Reachable:
+			return label + " reachable"
+		default:
+			return fmt.Sprintf("%s unreachable (%v)", label, r.Err)
+		}
+	}
+	summary := fmt.Sprintf("%s: %s, %s", host, describe("IPv4", v4), describe("IPv6", v6))
+
+	result := CheckResult{
+		ID:      checkID("ip_family", name),
+		Name:    fmt.Sprintf("IP Families - %s", name),
+		Status:  "pass",
+		Message: summary,
+	}
+
+	switch {
+	case !v4.Reachable && !v6.Reachable:
+		result.Status = "fail"
+		result.Code = classifyNetError(v4.Err)
+		result.Fix = "Check firewall rules for outbound TCP 443 over both IPv4 and IPv6"
+	case v4.Reachable && len(v6.Addrs) > 0 && !v6.Reachable:
+		result.Status = "warn"
+		result.Code = codeIPv6Unreachable
+		result.Message = summary + "; Happy Eyeballs fallback to IPv4 will add connection latency"
+		result.Fix = "Allow outbound IPv6 TCP 443 on the firewall, or prefer IPv4 for Claude Code: export NODE_OPTIONS=--dns-result-order=ipv4first"
+		result.FixCommand = &FixCommand{
+			Bash:       `case " $NODE_OPTIONS " in *" --dns-result-order=ipv4first "*) ;; *) export NODE_OPTIONS="${NODE_OPTIONS:+$NODE_OPTIONS }--dns-result-order=ipv4first" ;; esac`,
+			PowerShell: `if ("$env:NODE_OPTIONS" -notmatch "--dns-result-order=ipv4first") { $env:NODE_OPTIONS = ("$env:NODE_OPTIONS --dns-result-order=ipv4first").Trim() }`,
+		}
+	case v4.lookupFailed() || v6.lookupFailed():
+		failed := v6
+		if v4.lookupFailed() {
+			failed = v4
+		}
+		result.Status = "warn"
+		result.Code = classifyNetError(failed.Err)
+		result.Message = summary + "; the failed lookup leaves that family untested"
+		result.Fix = fmt.Sprintf("Check the DNS server: lookups of %s fail rather than answer, and clients wait on them before connecting", host)
+	}
+
+	return result
+}
+
+// dualStackResult probes the dual-stack (IPv4+IPv6) hostname of an endpoint.
+// Not every service publishes one in every region: the resolver saying the
+// name does not exist passes, but a lookup that fails without an answer
+// skips, since the endpoint may well exist.
+func dualStackResult(ctx context.Context, env Env, name, dsHost string) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := env.ResolverFor("").LookupHost(ctx, dsHost); err != nil {
+		result := CheckResult{
+			ID:      checkID("dual_stack", name),
+			Name:    fmt.Sprintf("Dual-Stack - %s", name),
+			Status:  "pass",
+			Message: fmt.Sprintf("No dual-stack endpoint %s in this region", dsHost),
+		}
+		if !notFound(err) {
+			result.Status = "skip"
+			result.Message = fmt.Sprintf("Cannot tell whether the dual-stack endpoint %s exists: %v", dsHost, err)
+		}
+		return result
+	}
+
+	result := addressFamilyResult(env, name, dsHost)
+	result.ID = checkID("dual_stack", name)
+	result.Name = fmt.Sprintf("Dual-Stack - %s", name)
+	return result
+}
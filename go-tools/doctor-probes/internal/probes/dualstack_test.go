@@ -0,0 +1,100 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestAddressFamilyResultUnreachable(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	if conn, err := net.DialTimeout("tcp4", "127.0.0.1:443", time.Second); err == nil {
+		conn.Close()
+		t.Skip("something listens on 127.0.0.1:443")
+	}
+	// An IPv4 literal has no IPv6 address, and nothing answers on 443.
+	got := addressFamilyResult(env, "Loopback", "127.0.0.1")
+	if got.Name != "IP Families - Loopback" || got.Status != "fail" {
+		t.Errorf("name, status = %q, %s; want IP Families - Loopback, fail", got.Name, got.Status)
+	}
+	for _, want := range []string{"127.0.0.1: IPv4 unreachable", "IPv6 no records"} {
+		if !strings.Contains(got.Message, want) {
+			t.Errorf("message %q does not contain %q", got.Message, want)
+		}
+	}
+	if !strings.Contains(got.Fix, "outbound TCP 443") {
+		t.Errorf("fix = %q", got.Fix)
+	}
+}
+
+func TestFamiliesResult(t *testing.T) {
+	const host = "bedrock-runtime.us-east-1.api.aws"
+	v4 := familyResult{Addrs: []net.IP{net.ParseIP("52.94.0.7")}, Reachable: true}
+	nxdomain := &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	timeout := &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	servfail := &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+	tests := []struct {
+		name     string
+		v6       familyResult
+		status   string
+		code     string
+		contains string
+	}{
+		{name: "reachable", v6: familyResult{Addrs: []net.IP{net.ParseIP("2600:1f18::1")}, Reachable: true}, status: "pass", contains: "IPv6 reachable"},
+		{name: "NXDOMAIN", v6: familyResult{Err: nxdomain}, status: "pass", contains: "IPv6 no records"},
+		{name: "no AAAA records", v6: familyResult{}, status: "pass", contains: "IPv6 no records"},
+		{name: "timeout", v6: familyResult{Err: timeout}, status: "warn", code: codeDNSTimeout, contains: "IPv6 lookup failed (lookup " + host + ": i/o timeout)"},
+		{name: "SERVFAIL", v6: familyResult{Err: servfail}, status: "warn", code: codeDNSFailure, contains: "IPv6 lookup failed (lookup " + host + ": server misbehaving)"},
+		{name: "unreachable", v6: familyResult{Addrs: []net.IP{net.ParseIP("2600:1f18::1")}, Err: errors.New("connect: network is unreachable")}, status: "warn", code: codeIPv6Unreachable, contains: "Happy Eyeballs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := familiesResult("Bedrock Runtime", host, v4, tt.v6)
+			if got.Status != tt.status || got.Code != tt.code || !strings.Contains(got.Message, tt.contains) {
+				t.Errorf("got %s %s %q, want %s %s containing %q", got.Status, got.Code, got.Message, tt.status, tt.code, tt.contains)
+			}
+			if (got.Status == "warn") != (got.Fix != "") {
+				t.Errorf("status %s with fix %q", got.Status, got.Fix)
+			}
+		})
+	}
+}
+
+func TestDualStackResultLookupFailure(t *testing.T) {
+	const host = "bedrock-runtime.us-east-1.api.aws"
+	tests := []struct {
+		name     string
+		err      error
+		status   string
+		contains string
+	}{
+		{name: "NXDOMAIN", err: &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}, status: "pass", contains: "No dual-stack endpoint " + host + " in this region"},
+		{name: "timeout", err: &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}, status: "skip", contains: "Cannot tell whether the dual-stack endpoint " + host + " exists: lookup " + host + ": i/o timeout"},
+		{name: "SERVFAIL", err: &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}, status: "skip", contains: "server misbehaving"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := fakeEnv(&probestest.Resolver{Err: tt.err}, nil, nil)
+			got := dualStackResult(context.Background(), env, "Bedrock Runtime", host)
+			if got.ID != "dual_stack.bedrock_runtime" || got.Status != tt.status || !strings.Contains(got.Message, tt.contains) {
+				t.Errorf("got %s %s %q, want dual_stack.bedrock_runtime %s containing %q", got.ID, got.Status, got.Message, tt.status, tt.contains)
+			}
+		})
+	}
+}
+
+func TestDualStackResultWithoutEndpoint(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	got := dualStackResult(context.Background(), env, "Bedrock Runtime", "bedrock-runtime.nowhere-1.invalid")
+	if got.Name != "Dual-Stack - Bedrock Runtime" || got.Status != "pass" {
+		t.Errorf("name, status = %q, %s; want Dual-Stack - Bedrock Runtime, pass", got.Name, got.Status)
+	}
+	if want := "No dual-stack endpoint bedrock-runtime.nowhere-1.invalid in this region"; got.Message != want {
+		t.Errorf("message = %q, want %q", got.Message, want)
+	}
+}
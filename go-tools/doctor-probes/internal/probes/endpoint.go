@@ -0,0 +1,33 @@
+package probes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseEndpointURL validates an endpoint override: an absolute http(s) URL
+// with a host and nothing after the path.
+func ParseEndpointURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case u.Scheme != "https" && u.Scheme != "http":
+		return nil, fmt.Errorf("%q is not an http(s) URL", raw)
+	case u.Hostname() == "":
+		return nil, fmt.Errorf("%q has no host", raw)
+	case u.User != nil:
+		return nil, fmt.Errorf("%q must not contain credentials", raw)
+	case u.RawQuery != "" || u.Fragment != "":
+		return nil, fmt.Errorf("%q must not have a query or fragment", raw)
+	}
+	return u, nil
+}
+
+// IsVPCEndpoint reports whether the endpoint override is an interface VPC
+// endpoint DNS name.
+func (s RunSettings) IsVPCEndpoint() bool {
+	return s.Endpoint != nil && strings.Contains(s.Endpoint.Hostname(), "vpce-")
+}
@@ -0,0 +1,275 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// Defaults of the politeness flags.
+const (
+	DefaultMaxConcurrency = 4
+	DefaultMaxHostRate    = 10 // requests per second to one host
+)
+
+// EssentialChecks answer whether Claude Code can reach Bedrock at all; the
+// request budget never cuts them short. Results recorded outside rec.run
+// (DNS, credentials) are essential too.
+var EssentialChecks = map[string]bool{
+	"region":                   true,
+	"https.bedrock_runtime":    true,
+	"auth.sso_session":         true,
+	"bedrock.unsigned_request": true,
+	"bedrock.signed_request":   true,
+	"bedrock.api_access":       true,
+	"bedrock.api_key":          true,
+}
+
+// budgetError is returned for a request of an optional check once the run
+// has used its --max-requests budget.
+type budgetError struct {
+	Budget int
+}
+
+func (e *budgetError) Error() string {
+	return fmt.Sprintf("request budget of %d exhausted (--max-requests)", e.Budget)
+}
+
+// ProbeEngine is the one path network probes take: every HTTP request, AWS
+// API call, DNS lookup, and raw dial acquires a slot from it first. It holds
+// the run to maxConcurrency requests in flight, starts no more than the
+// per-host rate of requests to one host in any second, and counts them
+// against the request budget, so that a run cannot trip an IDS or AWS
+// throttling by itself. A slot is held until
+// the response headers arrive or the connection is set up; a streamed body
+// is read outside it.
+type ProbeEngine struct {
+	slots   chan struct{}
+	perHost int           // requests to one host per window; 0 disables
+	window  time.Duration // one second, longer for rates below one
+	budget  int           // requests per run; 0 is unlimited
+
+	mu       sync.Mutex
+	starts   map[string][]time.Time // the last perHost scheduled starts per host
+	used     int
+	refused  int
+	optional bool // the running check may be cut short by the budget
+	inFlight int
+	peak     int
+}
+
+func NewProbeEngine(maxConcurrency int, hostRate float64, budget int) *ProbeEngine {
+	e := &ProbeEngine{
+		slots:  make(chan struct{}, maxConcurrency),
+		budget: budget,
+		starts: map[string][]time.Time{},
+	}
+	if hostRate > 0 {
+		e.perHost = int(max(math.Floor(hostRate), 1))
+		e.window = time.Duration(float64(e.perHost) / hostRate * float64(time.Second))
+	}
+	return e
+}
+
+// reset starts a new run: a fresh budget and rate history.
+func (e *ProbeEngine) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.starts = map[string][]time.Time{}
+	e.used, e.refused, e.peak = 0, 0, 0
+}
+
+// Exhausted reports whether the budget is used up, so optional checks are
+// skipped before they start.
+func (e *ProbeEngine) Exhausted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.budget > 0 && e.used >= e.budget
+}
+
+// Budget returns the requests allowed per run; 0 is unlimited.
+func (e *ProbeEngine) Budget() int {
+	return e.budget
+}
+
+// RunCheck runs check with the budget enforced unless id is essential, and
+// reports whether the budget refused any of its requests.
+func (e *ProbeEngine) RunCheck(id string, check func() []CheckResult) (results []CheckResult, cutShort bool) {
+	e.mu.Lock()
+	e.optional = !EssentialChecks[id]
+	refused := e.refused
+	e.mu.Unlock()
+	results = check()
+	e.mu.Lock()
+	e.optional = false
+	cutShort = e.refused > refused
+	e.mu.Unlock()
+	return results, cutShort
+}
+
+// acquire waits until host may be contacted and a slot is free. release
+// must be called when the request or dial is done.
+func (e *ProbeEngine) acquire(ctx context.Context, host string) (release func(), err error) {
+	e.mu.Lock()
+	if e.budget > 0 && e.used >= e.budget && e.optional {
+		e.refused++
+		e.mu.Unlock()
+		return nil, &budgetError{Budget: e.budget}
+	}
+	e.used++
+	e.mu.Unlock()
+
+	select {
+	case e.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release = func() {
+		e.mu.Lock()
+		e.inFlight--
+		e.mu.Unlock()
+		<-e.slots
+	}
+	// Sliding window, scheduled once the slot is held so that waiting for
+	// it cannot bunch starts up: start no sooner than a window after the
+	// perHost-th most recent start. Starts are scheduled in order, so the
+	// list stays sorted.
+	e.mu.Lock()
+	e.inFlight++
+	e.peak = max(e.peak, e.inFlight)
+	start := time.Now()
+	if e.perHost > 0 {
+		starts := e.starts[host]
+		if n := len(starts); n >= e.perHost {
+			if s := starts[n-e.perHost].Add(e.window); s.After(start) {
+				start = s
+			}
+		}
+		starts = append(starts, start)
+		if len(starts) > e.perHost {
+			starts = starts[len(starts)-e.perHost:]
+		}
+		e.starts[host] = starts
+	}
+	e.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// limitedTransport sends each request through the engine.
+type limitedTransport struct {
+	engine *ProbeEngine
+	base   http.RoundTripper
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.engine.acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return t.base.RoundTrip(req)
+}
+
+// limited wraps a transport, or the default one when base is nil, so its
+// requests go through the engine.
+func (e *ProbeEngine) limited(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return limitedTransport{engine: e, base: base}
+}
+
+// limitedClient sends an AWS SDK client's requests through the engine.
+type limitedClient struct {
+	engine *ProbeEngine
+	base   aws.HTTPClient
+}
+
+func (c limitedClient) Do(req *http.Request) (*http.Response, error) {
+	release, err := c.engine.acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.base.Do(req)
+}
+
+// limitedConfig returns cfg with its HTTP client going through the engine.
+func (e *ProbeEngine) limitedConfig(cfg aws.Config) aws.Config {
+	switch c := cfg.HTTPClient.(type) {
+	case limitedClient:
+		if c.engine != e {
+			cfg.HTTPClient = limitedClient{engine: e, base: c.base}
+		}
+	case nil:
+		cfg.HTTPClient = limitedClient{engine: e, base: awshttp.NewBuildableClient()}
+	default:
+		cfg.HTTPClient = limitedClient{engine: e, base: cfg.HTTPClient}
+	}
+	return cfg
+}
+
+// limitedResolver resolves names through the engine.
+type limitedResolver struct {
+	engine *ProbeEngine
+	base   Resolver
+}
+
+func (r limitedResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	release, err := r.engine.acquire(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.base.LookupHost(ctx, host)
+}
+
+func (r limitedResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	release, err := r.engine.acquire(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.base.LookupIP(ctx, network, host)
+}
+
+func (r limitedResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	release, err := r.engine.acquire(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return r.base.LookupCNAME(ctx, host)
+}
+
+// do runs fn, a dial or lookup of host, through the engine. Dials made by an
+// HTTP transport are already covered by its request and must not use it.
+func (e *ProbeEngine) do(ctx context.Context, host string, fn func() error) error {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	release, err := e.acquire(ctx, host)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
@@ -0,0 +1,221 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewProbeEngine(t *testing.T) {
+	tests := []struct {
+		rate    float64
+		perHost int
+		window  time.Duration
+	}{
+		{rate: 10, perHost: 10, window: time.Second},
+		{rate: 2.5, perHost: 2, window: 800 * time.Millisecond},
+		{rate: 0.5, perHost: 1, window: 2 * time.Second},
+		{rate: 0},
+	}
+	for _, tt := range tests {
+		e := NewProbeEngine(4, tt.rate, 0)
+		if e.perHost != tt.perHost || e.window != tt.window {
+			t.Errorf("rate %v: perHost, window = %d, %v; want %d, %v", tt.rate, e.perHost, e.window, tt.perHost, tt.window)
+		}
+	}
+}
+
+// shortContext gives up on a slot quickly.
+func shortContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestEngineConcurrency(t *testing.T) {
+	e := NewProbeEngine(2, 0, 0)
+	first, err := e.acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.acquire(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.acquire(shortContext(t), "c"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third acquire with two slots = %v, want a wait until the deadline", err)
+	}
+	first()
+	release, err := e.acquire(shortContext(t), "c")
+	if err != nil {
+		t.Fatalf("acquire after a release = %v", err)
+	}
+	release()
+	if e.peak != 2 {
+		t.Errorf("peak = %d, want 2", e.peak)
+	}
+}
+
+func TestEngineHostRate(t *testing.T) {
+	e := NewProbeEngine(8, 2, 0)
+	for range 2 {
+		release, err := e.acquire(context.Background(), "bedrock.us-east-1.amazonaws.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if _, err := e.acquire(shortContext(t), "bedrock.us-east-1.amazonaws.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("third request to a host within a second = %v, want a wait", err)
+	}
+	release, err := e.acquire(shortContext(t), "sts.us-east-1.amazonaws.com")
+	if err != nil {
+		t.Fatalf("request to another host = %v, want no wait", err)
+	}
+	release()
+}
+
+func TestEngineBudget(t *testing.T) {
+	e := NewProbeEngine(4, 0, 2)
+	request := func() error {
+		release, err := e.acquire(context.Background(), "host")
+		if err == nil {
+			release()
+		}
+		return err
+	}
+
+	_, cutShort := e.RunCheck("region", func() []CheckResult {
+		for range 3 {
+			if err := request(); err != nil {
+				t.Errorf("essential check refused: %v", err)
+			}
+		}
+		return nil
+	})
+	if cutShort || !e.Exhausted() {
+		t.Errorf("after an essential check over budget: cutShort %v, exhausted %v; want false, true", cutShort, e.Exhausted())
+	}
+
+	var budgetErr *budgetError
+	_, cutShort = e.RunCheck("bedrock.usage", func() []CheckResult {
+		if err := request(); !errors.As(err, &budgetErr) || budgetErr.Budget != 2 {
+			t.Errorf("optional request over budget = %v, want a budget error", err)
+		}
+		return nil
+	})
+	if !cutShort {
+		t.Error("optional check not reported as cut short")
+	}
+	if err := request(); err != nil {
+		t.Errorf("request outside a check = %v, want it allowed", err)
+	}
+
+	e.reset()
+	if e.Exhausted() {
+		t.Error("budget still exhausted after reset")
+	}
+}
+
+func TestLimitedTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	e := NewProbeEngine(4, 0, 1)
+	client := &http.Client{Transport: e.limited(nil)}
+
+	results, cutShort := e.RunCheck("bedrock.usage", func() []CheckResult {
+		var results []CheckResult
+		for range 2 {
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				results = append(results, CheckResult{Status: "fail", Message: err.Error()})
+				continue
+			}
+			resp.Body.Close()
+			results = append(results, CheckResult{Status: "pass"})
+		}
+		return results
+	})
+	if !cutShort || results[0].Status != "pass" || results[1].Status != "fail" {
+		t.Errorf("results, cutShort = %+v, %v; want the second request refused", results, cutShort)
+	}
+	if e.used != 1 || e.refused != 1 {
+		t.Errorf("used, refused = %d, %d; want 1, 1", e.used, e.refused)
+	}
+}
+
+// TestEngineSyntheticRun sends 50 probes across two hosts at once and checks
+// from the server's side that the limits held.
+func TestEngineSyntheticRun(t *testing.T) {
+	const (
+		probes   = 50
+		maxSlots = 4
+		hostRate = 20
+	)
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+		arrivals = map[string][]time.Time{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		host, _, _ := strings.Cut(r.Host, ":")
+		arrivals[host] = append(arrivals[host], time.Now())
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	e := NewProbeEngine(maxSlots, hostRate, 0)
+	client := &http.Client{Transport: e.limited(nil)}
+
+	var wg sync.WaitGroup
+	for i := range probes {
+		host := "127.0.0.1"
+		if i%2 == 1 {
+			host = "localhost"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get("http://" + net.JoinHostPort(host, port))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak > maxSlots || e.peak > maxSlots {
+		t.Errorf("peak in flight = %d at the server, %d in the engine; want at most %d", peak, e.peak, maxSlots)
+	}
+	if e.used != probes {
+		t.Errorf("used = %d, want %d", e.used, probes)
+	}
+	// Arrivals lag their scheduled starts by a little, so allow a slack
+	// on the window.
+	window := time.Second - 50*time.Millisecond
+	for host, times := range arrivals {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		for i := hostRate; i < len(times); i++ {
+			if d := times[i].Sub(times[i-hostRate]); d < window {
+				t.Errorf("%s: %d requests within %s, want at most %d a second", host, hostRate+1, d, hostRate)
+				break
+			}
+		}
+	}
+}
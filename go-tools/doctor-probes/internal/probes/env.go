@@ -0,0 +1,142 @@
+// Package probes holds the network and AWS dependencies of the doctor-probes
+// checks behind interfaces, and the probes built only on them, so that checks
+// can run against fakes instead of the real resolver, network, and AWS.
+package probes
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Resolver resolves host names. *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// HTTPDoer sends an HTTP request. *http.Client implements it.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// BedrockClient is the part of the Bedrock control plane API the probes call.
+type BedrockClient interface {
+	ListFoundationModels(ctx context.Context, params *bedrock.ListFoundationModelsInput, optFns ...func(*bedrock.Options)) (*bedrock.ListFoundationModelsOutput, error)
+	GetFoundationModel(ctx context.Context, params *bedrock.GetFoundationModelInput, optFns ...func(*bedrock.Options)) (*bedrock.GetFoundationModelOutput, error)
+	GetFoundationModelAvailability(ctx context.Context, params *bedrock.GetFoundationModelAvailabilityInput, optFns ...func(*bedrock.Options)) (*bedrock.GetFoundationModelAvailabilityOutput, error)
+	GetInferenceProfile(ctx context.Context, params *bedrock.GetInferenceProfileInput, optFns ...func(*bedrock.Options)) (*bedrock.GetInferenceProfileOutput, error)
+	ListInferenceProfiles(ctx context.Context, params *bedrock.ListInferenceProfilesInput, optFns ...func(*bedrock.Options)) (*bedrock.ListInferenceProfilesOutput, error)
+	GetModelInvocationLoggingConfiguration(ctx context.Context, params *bedrock.GetModelInvocationLoggingConfigurationInput, optFns ...func(*bedrock.Options)) (*bedrock.GetModelInvocationLoggingConfigurationOutput, error)
+}
+
+// BedrockRuntimeClient is the part of the Bedrock runtime API the probes call.
+type BedrockRuntimeClient interface {
+	InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+	InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error)
+	CountTokens(ctx context.Context, params *bedrockruntime.CountTokensInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.CountTokensOutput, error)
+}
+
+// STSClient is the part of the STS API the probes call.
+type STSClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+	AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+	AssumeRoleWithWebIdentity(ctx context.Context, params *sts.AssumeRoleWithWebIdentityInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleWithWebIdentityOutput, error)
+}
+
+// IAMClient is the part of the IAM API the probes call.
+type IAMClient interface {
+	GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error)
+	SimulatePrincipalPolicy(ctx context.Context, params *iam.SimulatePrincipalPolicyInput, optFns ...func(*iam.Options)) (*iam.SimulatePrincipalPolicyOutput, error)
+}
+
+// ServiceQuotasClient is the part of the Service Quotas API the probes call.
+type ServiceQuotasClient interface {
+	GetServiceQuota(ctx context.Context, params *servicequotas.GetServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error)
+	ListAWSDefaultServiceQuotas(ctx context.Context, params *servicequotas.ListAWSDefaultServiceQuotasInput, optFns ...func(*servicequotas.Options)) (*servicequotas.ListAWSDefaultServiceQuotasOutput, error)
+}
+
+// CloudWatchClient is the part of the CloudWatch API the probes call.
+type CloudWatchClient interface {
+	ListMetrics(ctx context.Context, params *cloudwatch.ListMetricsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.ListMetricsOutput, error)
+	GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error)
+}
+
+// S3Client is the part of the S3 API the probes call.
+type S3Client interface {
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+}
+
+// IMDSClient is the part of the EC2 instance metadata service the probes
+// read.
+type IMDSClient interface {
+	GetMetadata(ctx context.Context, params *imds.GetMetadataInput, optFns ...func(*imds.Options)) (*imds.GetMetadataOutput, error)
+	GetIAMInfo(ctx context.Context, params *imds.GetIAMInfoInput, optFns ...func(*imds.Options)) (*imds.GetIAMInfoOutput, error)
+}
+
+// RunSettings are the command-line settings every check of a run shares.
+type RunSettings struct {
+	// DNSServer is the --resolver server the endpoints are resolved with
+	// instead of the system resolver, or "".
+	DNSServer string
+	// Endpoint is the --endpoint-url (or AWS_BEDROCK_ENDPOINT_URL)
+	// override, e.g. a PrivateLink DNS name. When set, every Bedrock probe
+	// and SDK call targets it instead of the public endpoints; nil means
+	// public.
+	Endpoint *url.URL
+	// Host is where doctor-probes runs, for tailored fixes.
+	Host HostEnvironment
+	// Engine limits the run's requests; nil means the default limits.
+	Engine *ProbeEngine
+	// Verbose (--verbose) attaches request metadata to results.
+	Verbose bool
+	// IPRangesFile is the --ip-ranges-file copy of ip-ranges.json to read
+	// instead of downloading one, or "".
+	IPRangesFile string
+	// AWSOptions are applied to every SDK config the checks load, so
+	// command-line endpoint settings and logging reach all SDK-based checks.
+	AWSOptions []func(*config.LoadOptions) error
+}
+
+// Env is everything outside the process that a probe may touch, and the
+// settings of the run. Raw dials and the probes' own transports, which time
+// or inspect connection setup, go through Engine.
+type Env struct {
+	RunSettings
+
+	Resolver Resolver
+	// ResolverFor returns a resolver that queries server ("host:port"), or
+	// the system resolver for "", for comparing answers across resolvers.
+	ResolverFor func(server string) Resolver
+	// HTTP returns a client for one request to host. Probes that time
+	// connection setup call it per request so each pays the full cost.
+	HTTP func(host string) HTTPDoer
+	// ProxyHTTP sends requests the way Claude Code does: through the proxy
+	// environment, following redirects.
+	ProxyHTTP HTTPDoer
+
+	Bedrock        func(cfg aws.Config) BedrockClient
+	BedrockRuntime func(cfg aws.Config, optFns ...func(*bedrockruntime.Options)) BedrockRuntimeClient
+	STS            func(cfg aws.Config) STSClient
+	IAM            func(cfg aws.Config) IAMClient
+	ServiceQuotas  func(cfg aws.Config) ServiceQuotasClient
+	CloudWatch     func(cfg aws.Config) CloudWatchClient
+	S3             func(cfg aws.Config) S3Client
+	IMDS           func(cfg aws.Config) IMDSClient
+
+	awsConfigs *awsConfigCache
+	run        *runState
+}
@@ -0,0 +1,159 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// envCredentialVars are the environment variables that carry or select
+// credentials, in the order they are reported.
+var envCredentialVars = []string{
+	"AWS_BEARER_TOKEN_BEDROCK",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_ROLE_ARN",
+	"AWS_PROFILE",
+}
+
+// envCredentialPrecedence explains who wins when several mechanisms are set.
+const envCredentialPrecedence = "Claude Code sends AWS_BEARER_TOKEN_BEDROCK instead of signing when it is set; otherwise the SDK takes static keys from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY first, then AWS_WEB_IDENTITY_TOKEN_FILE, then the AWS_PROFILE profile"
+
+// envMechanism is one independent way the environment supplies credentials.
+type envMechanism struct {
+	Name string
+	Vars []string
+}
+
+// envCredentialMechanisms groups the credential variables that are set into
+// mechanisms, highest precedence first, and notes incomplete groups the SDK
+// ignores.
+func envCredentialMechanisms() (mechanisms []envMechanism, ignored []string) {
+	set := func(name string) bool { return os.Getenv(name) != "" }
+
+	if set("AWS_BEARER_TOKEN_BEDROCK") {
+		mechanisms = append(mechanisms, envMechanism{"Bedrock API key", []string{"AWS_BEARER_TOKEN_BEDROCK"}})
+	}
+	var static []string
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"} {
+		if set(name) {
+			static = append(static, name)
+		}
+	}
+	switch {
+	case set("AWS_ACCESS_KEY_ID") && set("AWS_SECRET_ACCESS_KEY"):
+		mechanisms = append(mechanisms, envMechanism{fmt.Sprintf("static keys (AWS_ACCESS_KEY_ID %s)", keySuffix(os.Getenv("AWS_ACCESS_KEY_ID"))), static})
+	case len(static) > 0:
+		ignored = append(ignored, strings.Join(static, ", ")+" without a complete access key pair")
+	}
+	switch {
+	case set("AWS_WEB_IDENTITY_TOKEN_FILE") && set("AWS_ROLE_ARN"):
+		mechanisms = append(mechanisms, envMechanism{"web identity (role " + os.Getenv("AWS_ROLE_ARN") + ")", []string{"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN"}})
+	case set("AWS_WEB_IDENTITY_TOKEN_FILE"):
+		ignored = append(ignored, "AWS_WEB_IDENTITY_TOKEN_FILE without AWS_ROLE_ARN")
+	}
+	if set("AWS_PROFILE") {
+		mechanisms = append(mechanisms, envMechanism{"profile '" + os.Getenv("AWS_PROFILE") + "'", []string{"AWS_PROFILE"}})
+	}
+	return mechanisms, ignored
+}
+
+// verifyEnvKeys calls sts:GetCallerIdentity with the static keys from the
+// environment alone. The keys do not depend on the shared config, so a
+// profile that fails to load does not stop the check.
+func verifyEnvKeys(env Env, region string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		cfg = aws.Config{Region: region}
+	}
+	cfg = cfg.Copy()
+	cfg.Credentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Source:          config.CredentialsSourceName,
+		}, nil
+	})
+	start := time.Now()
+	_, err = CallerIdentity(ctx, env, cfg)
+	logProbe("sts", "GetCallerIdentity", start, err, "credentials", "environment")
+	return err
+}
+
+// envCredentialsResults lists the credential-bearing environment variables
+// and warns when more than one independent mechanism is set, since the one
+// that wins silently overrides the others (typically AWS_ACCESS_KEY_ID left in
+// a shell profile overriding the SSO profile). Static keys that AWS rejects
+// fail the check. It returns nothing when none of the variables is set.
+func envCredentialsResults(env Env, region string) []CheckResult {
+	result := CheckResult{ID: "auth.env_credentials", Name: "Environment Credentials"}
+
+	var present []string
+	for _, name := range envCredentialVars {
+		if os.Getenv(name) != "" {
+			present = append(present, name)
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+	mechanisms, ignored := envCredentialMechanisms()
+
+	result.Message = "Set: " + strings.Join(present, ", ")
+	if len(ignored) > 0 {
+		result.Message += "; ignored by the SDK: " + strings.Join(ignored, "; ")
+	}
+	unset := &FixCommand{
+		Bash:       "unset AWS_ACCESS_KEY_ID AWS_SECRET_ACCESS_KEY AWS_SESSION_TOKEN",
+		PowerShell: "Remove-Item Env:AWS_ACCESS_KEY_ID, Env:AWS_SECRET_ACCESS_KEY, Env:AWS_SESSION_TOKEN -ErrorAction SilentlyContinue",
+	}
+
+	staticKeys := os.Getenv("AWS_ACCESS_KEY_ID") != "" && os.Getenv("AWS_SECRET_ACCESS_KEY") != ""
+	if staticKeys {
+		if err := verifyEnvKeys(env, region); err != nil {
+			class := classifyAWSError(err, "sts:GetCallerIdentity")
+			if class.Code == codeCredentialsExpired || class.Code == codeCredentialsInvalid {
+				result.Status, result.Code = "fail", class.Code
+				result.Message = fmt.Sprintf("AWS rejects the static keys in the environment (AWS_ACCESS_KEY_ID %s), and they override every other credential source: %v; %s",
+					keySuffix(os.Getenv("AWS_ACCESS_KEY_ID")), err, result.Message)
+				result.Fix = "Unset these variables, and remove them from your shell startup files (~/.bashrc, ~/.zshrc, $PROFILE), so the profile you were given is used"
+				result.FixCommand = unset
+				result.attachDiagnostics(env, awsDiagnostics(err))
+				return []CheckResult{result}
+			}
+			result.Message += fmt.Sprintf("; could not verify the static keys: %v", err)
+		}
+	}
+
+	if len(mechanisms) <= 1 {
+		result.Status = "pass"
+		return []CheckResult{result}
+	}
+	var names []string
+	for _, m := range mechanisms {
+		names = append(names, fmt.Sprintf("%s from %s", m.Name, strings.Join(m.Vars, ", ")))
+	}
+	result.Status, result.Code = "warn", codeCredentialsShadowed
+	result.Message = fmt.Sprintf("%d credential mechanisms are set in the environment and %s wins: %s. %s. %s",
+		len(mechanisms), mechanisms[0].Name, strings.Join(names, "; "), envCredentialPrecedence, result.Message)
+	switch {
+	case strings.HasPrefix(mechanisms[0].Name, "static keys"):
+		result.Fix = "If you meant to use the other mechanism, unset the static keys and remove them from your shell startup files; an AccessDenied for these keys says nothing about the profile you were given"
+		result.FixCommand = unset
+	case mechanisms[0].Vars[0] == "AWS_BEARER_TOKEN_BEDROCK":
+		result.Fix = "Claude Code authenticates to Bedrock with the API key, not the other credentials; unset AWS_BEARER_TOKEN_BEDROCK to use them"
+	default:
+		result.Fix = "Unset the variables of the mechanism you do not use so it is clear which identity Claude Code runs as"
+	}
+	return []CheckResult{result}
+}
@@ -0,0 +1,122 @@
+package probes
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// HostEnvironment describes where doctor-probes is running, so fixes can be
+// tailored to it.
+type HostEnvironment struct {
+	OS        string `json:"os"`
+	WSL       string `json:"wsl,omitempty"`       // "WSL1" or "WSL2"
+	Container string `json:"container,omitempty"` // docker, podman, kubernetes, containerd, lxc, devcontainer
+	CI        string `json:"ci,omitempty"`        // e.g. "GitHub Actions", "Codespaces"
+	Cloud     string `json:"cloud,omitempty"`     // "EC2" on an EC2 instance, including EKS nodes
+}
+
+func (e HostEnvironment) String() string {
+	parts := []string{e.OS}
+	if e.WSL != "" {
+		parts = append(parts, e.WSL)
+	}
+	if e.Container != "" {
+		parts = append(parts, "container: "+e.Container)
+	}
+	if e.CI != "" {
+		parts = append(parts, "CI: "+e.CI)
+	}
+	if e.Cloud != "" {
+		parts = append(parts, "cloud: "+e.Cloud)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DetectEnvironment inspects the filesystem under root and the environment
+// through getenv; main passes "/" and os.Getenv.
+func DetectEnvironment(root string, getenv func(string) string) HostEnvironment {
+	e := HostEnvironment{OS: runtime.GOOS}
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(root, path))
+		return err == nil
+	}
+	read := func(path string) string {
+		data, _ := os.ReadFile(filepath.Join(root, path))
+		return strings.ToLower(string(data))
+	}
+
+	if version := read("proc/version"); strings.Contains(version, "microsoft") {
+		e.WSL = "WSL1"
+		if strings.Contains(version, "wsl2") || strings.Contains(version, "microsoft-standard") {
+			e.WSL = "WSL2"
+		}
+	} else if getenv("WSL_DISTRO_NAME") != "" {
+		e.WSL = "WSL2"
+	}
+
+	cgroup := read("proc/1/cgroup")
+	switch {
+	case getenv("REMOTE_CONTAINERS") == "true" || getenv("DEVCONTAINER") == "true":
+		e.Container = "devcontainer"
+	case getenv("KUBERNETES_SERVICE_HOST") != "" || strings.Contains(cgroup, "kubepods"):
+		e.Container = "kubernetes"
+	case exists(".dockerenv") || strings.Contains(cgroup, "docker"):
+		e.Container = "docker"
+	case exists("run/.containerenv"):
+		e.Container = "podman"
+	case strings.Contains(cgroup, "containerd"):
+		e.Container = "containerd"
+	case strings.Contains(cgroup, "lxc"):
+		e.Container = "lxc"
+	}
+
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		e.CI = "GitHub Actions"
+	case getenv("CODESPACES") == "true":
+		e.CI = "Codespaces"
+	case getenv("GITLAB_CI") == "true":
+		e.CI = "GitLab CI"
+	case getenv("CODEBUILD_BUILD_ID") != "":
+		e.CI = "AWS CodeBuild"
+	case getenv("CI") != "" && getenv("CI") != "false":
+		e.CI = "CI"
+	}
+
+	// The DMI tables identify EC2 without a network call; containers see
+	// the host's.
+	if strings.Contains(read("sys/devices/virtual/dmi/id/sys_vendor"), "amazon ec2") ||
+		strings.HasPrefix(read("sys/devices/virtual/dmi/id/board_asset_tag"), "i-") ||
+		strings.HasPrefix(read("sys/hypervisor/uuid"), "ec2") {
+		e.Cloud = "EC2"
+	}
+	return e
+}
+
+// dnsHint returns environment-specific advice for DNS failures.
+func (e HostEnvironment) dnsHint() string {
+	switch {
+	case e.Container != "":
+		return "; inside a container, check the container DNS (/etc/resolv.conf, docker --dns or daemon.json \"dns\")"
+	case e.WSL != "":
+		return "; WSL generates /etc/resolv.conf from Windows, which breaks on some VPNs: try generateResolvConf=false in /etc/wsl.conf or dnsTunneling=true in .wslconfig"
+	case e.CI != "":
+		return "; check the runner's DNS and egress rules"
+	}
+	return ""
+}
+
+// proxyHint returns environment-specific advice for connection failures.
+func (e HostEnvironment) proxyHint() string {
+	switch {
+	case e.WSL != "":
+		return "; WSL does not inherit the Windows proxy settings: export HTTPS_PROXY in your shell or set autoProxy=true in .wslconfig"
+	case e.Container != "":
+		return "; containers do not inherit the host proxy: pass HTTPS_PROXY/NO_PROXY into the container"
+	case e.CI != "":
+		return "; check the runner's egress allow-list for *.amazonaws.com"
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package probes
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDetectEnvironment(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string // relative to the fake root
+		env   map[string]string
+		want  HostEnvironment
+	}{
+		{name: "bare host"},
+		{
+			name:  "WSL2 from the kernel version",
+			files: map[string]string{"proc/version": "Linux version 5.15.153.1-microsoft-standard-WSL2"},
+			want:  HostEnvironment{WSL: "WSL2"},
+		},
+		{
+			name:  "WSL1",
+			files: map[string]string{"proc/version": "Linux version 4.4.0-19041-Microsoft"},
+			want:  HostEnvironment{WSL: "WSL1"},
+		},
+		{
+			name: "WSL from the distro variable",
+			env:  map[string]string{"WSL_DISTRO_NAME": "Ubuntu"},
+			want: HostEnvironment{WSL: "WSL2"},
+		},
+		{
+			name:  "docker",
+			files: map[string]string{".dockerenv": ""},
+			want:  HostEnvironment{Container: "docker"},
+		},
+		{
+			name:  "podman",
+			files: map[string]string{"run/.containerenv": ""},
+			want:  HostEnvironment{Container: "podman"},
+		},
+		{
+			name:  "kubernetes from cgroup",
+			files: map[string]string{"proc/1/cgroup": "0::/kubepods/besteffort/pod1234"},
+			want:  HostEnvironment{Container: "kubernetes"},
+		},
+		{
+			name:  "devcontainer wins over docker",
+			files: map[string]string{".dockerenv": ""},
+			env:   map[string]string{"REMOTE_CONTAINERS": "true"},
+			want:  HostEnvironment{Container: "devcontainer"},
+		},
+		{
+			name:  "GitHub Actions on EC2",
+			files: map[string]string{"sys/devices/virtual/dmi/id/sys_vendor": "Amazon EC2\n"},
+			env:   map[string]string{"GITHUB_ACTIONS": "true", "CI": "true"},
+			want:  HostEnvironment{CI: "GitHub Actions", Cloud: "EC2"},
+		},
+		{
+			name: "generic CI",
+			env:  map[string]string{"CI": "1"},
+			want: HostEnvironment{CI: "CI"},
+		},
+		{
+			name: "CI=false is not CI",
+			env:  map[string]string{"CI": "false"},
+		},
+		{
+			name:  "EC2 from the hypervisor UUID",
+			files: map[string]string{"sys/hypervisor/uuid": "ec2e1916-9099-7caf-fd21-012345abcdef"},
+			want:  HostEnvironment{Cloud: "EC2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for path, data := range tt.files {
+				full := filepath.Join(root, path)
+				if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(full, []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := DetectEnvironment(root, func(k string) string { return tt.env[k] })
+			tt.want.OS = runtime.GOOS
+			if got != tt.want {
+				t.Errorf("detectEnvironment = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvironmentHints(t *testing.T) {
+	tests := []struct {
+		env        HostEnvironment
+		dns, proxy bool // whether a hint is expected
+		str        string
+	}{
+		{HostEnvironment{OS: "darwin"}, false, false, "darwin"},
+		{HostEnvironment{OS: "linux", WSL: "WSL2"}, true, true, "linux, WSL2"},
+		{HostEnvironment{OS: "linux", Container: "docker", CI: "GitHub Actions", Cloud: "EC2"}, true, true, "linux, container: docker, CI: GitHub Actions, cloud: EC2"},
+	}
+	for _, tt := range tests {
+		if (tt.env.dnsHint() != "") != tt.dns || (tt.env.proxyHint() != "") != tt.proxy {
+			t.Errorf("%s: dnsHint %q, proxyHint %q", tt.str, tt.env.dnsHint(), tt.env.proxyHint())
+		}
+		if tt.env.String() != tt.str {
+			t.Errorf("String() = %q, want %q", tt.env.String(), tt.str)
+		}
+	}
+}
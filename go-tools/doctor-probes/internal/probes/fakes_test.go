@@ -0,0 +1,25 @@
+package probes
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// fakeEnv is an Env of the fakes given, which stand in for the system
+// resolver; a nil fake panics when used.
+func fakeEnv(resolver Resolver, br BedrockClient, st STSClient) Env {
+	env := NewEnv(RunSettings{})
+	resolverFor := env.ResolverFor
+	env.Resolver = resolver
+	env.ResolverFor = func(server string) Resolver {
+		if server == "" {
+			return resolver
+		}
+		return resolverFor(server)
+	}
+	env.HTTP = func(string) HTTPDoer { return http.DefaultClient }
+	env.Bedrock = func(aws.Config) BedrockClient { return br }
+	env.STS = func(aws.Config) STSClient { return st }
+	return env
+}
@@ -0,0 +1,102 @@
+package probes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FixCommand is the machine-actionable part of a Fix. Commands must be
+// idempotent, must not need elevated privileges, and must never delete
+// anything.
+type FixCommand struct {
+	Bash       string `json:"bash,omitempty"`
+	PowerShell string `json:"powershell,omitempty"`
+}
+
+// Codes whose remedy lies with network, DNS, or IAM administrators rather than
+// with anything the user can run.
+var ITCodes = map[string]bool{
+	codeDNSNXDomain:      true,
+	codeDNSTimeout:       true,
+	codeDNSFailure:       true,
+	codeDNSMismatch:      true,
+	codeConnectTimeout:   true,
+	codeConnectRefused:   true,
+	codeConnectFailure:   true,
+	codeTLSFailure:       true,
+	codeHTTP407:          true,
+	codePayloadMTU:       true,
+	codeIAMAccessDenied:  true,
+	codeSCPDeny:          true,
+	codeIAMExplicitDeny:  true,
+	codeIAMSimulatedDeny: true,
+	codeQuotaDefault:     true,
+	codeLoggingDisabled:  true,
+	codeLoggingBucket:    true,
+}
+
+// DestructiveCommand matches commands that delete files or state. Checks
+// should never produce them; this is a last line of defence.
+var DestructiveCommand = regexp.MustCompile(`(?i)(^|[;&|]\s*)(rm|rmdir|del|erase|unset|dd|mkfs|Remove-Item|Clear-Item)\b|>\s*/dev/|\bformat\s+[a-z]:`)
+
+// WriteFixScript writes the fixes of all non-passing results as a commented
+// bash script, or a PowerShell script when powershell is set.
+func WriteFixScript(path string, results []CheckResult, powershell bool) error {
+	var b strings.Builder
+	name := filepath.Base(path)
+	if powershell {
+		fmt.Fprintln(&b, "# BCCE doctor-probes remediation script")
+		fmt.Fprintf(&b, "# Generated %s. Review before running, then dot-source it so the\n", time.Now().UTC().Format(time.RFC3339))
+		fmt.Fprintf(&b, "# environment changes apply to this session:  . .\\%s\n", name)
+	} else {
+		fmt.Fprintln(&b, "#!/usr/bin/env bash")
+		fmt.Fprintln(&b, "# BCCE doctor-probes remediation script")
+		fmt.Fprintf(&b, "# Generated %s. Review before running, then source it so the\n", time.Now().UTC().Format(time.RFC3339))
+		fmt.Fprintf(&b, "# environment changes apply to this shell:  source %s\n", name)
+	}
+	fmt.Fprintln(&b, "# Every step is safe to run more than once. Steps marked [IT] need your")
+	fmt.Fprintln(&b, "# network or AWS administrator and are left as comments.")
+
+	step := 0
+	for _, r := range results {
+		if r.Status == "pass" || r.Fix == "" {
+			continue
+		}
+		step++
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "# %d. %s (%s", step, r.Name, r.Status)
+		if r.Code != "" {
+			fmt.Fprintf(&b, ", %s", r.Code)
+		}
+		fmt.Fprintln(&b, ")")
+
+		var command string
+		if r.FixCommand != nil {
+			command = r.FixCommand.Bash
+			if powershell {
+				command = r.FixCommand.PowerShell
+			}
+		}
+		switch {
+		case ITCodes[r.Code]:
+			fmt.Fprintf(&b, "# [IT] %s\n", r.Fix)
+		case command == "":
+			fmt.Fprintf(&b, "# Manual: %s\n", r.Fix)
+		case DestructiveCommand.MatchString(command):
+			fmt.Fprintf(&b, "# Skipped a destructive command; apply manually: %s\n", r.Fix)
+		default:
+			fmt.Fprintf(&b, "# %s\n%s\n", r.Fix, command)
+		}
+	}
+
+	if step == 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "# Nothing to fix: all checks passed.")
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,107 @@
+package probes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDestructiveCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"export AWS_REGION=us-east-1", false},
+		{"$env:AWS_REGION = 'us-east-1'", false},
+		{"export NO_PROXY=\"$NO_PROXY,.amazonaws.com\"", false},
+		{"rm -rf ~/.aws/cli/cache", true},
+		{"export A=1; rm -f x", true},
+		{"true && dd if=/dev/zero of=x", true},
+		{"Remove-Item -Recurse $HOME\\.aws", true},
+		{"clear-item env:AWS_PROFILE", true},
+		{"echo hi > /dev/sda", true},
+		{"format c: /q", true},
+		{"export FORMAT=json", false},
+		{"export TERM=xterm # not rmdir", false},
+	}
+	for _, tt := range tests {
+		if got := DestructiveCommand.MatchString(tt.command); got != tt.want {
+			t.Errorf("destructiveCommand.MatchString(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFixScript(t *testing.T) {
+	results := []CheckResult{
+		{Name: "AWS_REGION", Status: "pass", Message: "Set"},
+		{Name: "AWS_REGION", Status: "fail", Code: codeRegionUnset, Fix: "Set AWS_REGION",
+			FixCommand: &FixCommand{Bash: "export AWS_REGION=us-east-1", PowerShell: "$env:AWS_REGION = 'us-east-1'"}},
+		{Name: "DNS - STS", Status: "fail", Code: codeDNSNXDomain, Fix: "Ask IT to fix DNS",
+			FixCommand: &FixCommand{Bash: "export IGNORED=1"}},
+		{Name: "Model", Status: "warn", Fix: "Pick a model"},
+		{Name: "Cache", Status: "warn", Fix: "Clear the cache",
+			FixCommand: &FixCommand{Bash: "rm -rf ~/.aws/cli/cache", PowerShell: "Remove-Item -Recurse ~/.aws/cli/cache"}},
+		{Name: "Quiet", Status: "warn"},
+	}
+	tests := []struct {
+		name       string
+		results    []CheckResult
+		powershell bool
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:    "bash",
+			results: results,
+			want: []string{
+				"#!/usr/bin/env bash\n",
+				"source fix.sh\n",
+				"# 1. AWS_REGION (fail, E_REGION_UNSET)\n# Set AWS_REGION\nexport AWS_REGION=us-east-1\n",
+				"# 2. DNS - STS (fail, E_DNS_NXDOMAIN)\n# [IT] Ask IT to fix DNS\n",
+				"# 3. Model (warn)\n# Manual: Pick a model\n",
+				"# 4. Cache (warn)\n# Skipped a destructive command; apply manually: Clear the cache\n",
+			},
+			notWant: []string{"IGNORED", "rm -rf", "Quiet", "# 5."},
+		},
+		{
+			name:       "PowerShell",
+			results:    results,
+			powershell: true,
+			want: []string{
+				". .\\fix.sh\n",
+				"# Set AWS_REGION\n$env:AWS_REGION = 'us-east-1'\n",
+				"# Skipped a destructive command; apply manually: Clear the cache\n",
+			},
+			notWant: []string{"#!/usr/bin/env bash", "export AWS_REGION", "Remove-Item"},
+		},
+		{
+			name:    "all passing",
+			results: results[:1],
+			want:    []string{"# Nothing to fix: all checks passed.\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fix.sh")
+			if err := WriteFixScript(path, tt.results, tt.powershell); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			script := string(data)
+			for _, want := range tt.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("script does not contain %q:\n%s", want, script)
+				}
+			}
+			for _, bad := range tt.notWant {
+				if strings.Contains(script, bad) {
+					t.Errorf("script contains %q:\n%s", bad, script)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// verifyLoggingBucket confirms the invocation log bucket exists and is owned
+// by the caller's account.
+func verifyLoggingBucket(ctx context.Context, env Env, cfg aws.Config, bucket string) error {
+	identity, err := CallerIdentity(ctx, env, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %w", err)
+	}
+
+	_, err = env.S3(cfg).HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: identity.Account,
+	})
+	if err != nil {
+		return fmt.Errorf("bucket %s not found in account %s: %w", bucket, aws.ToString(identity.Account), err)
+	}
+	return nil
+}
+
+func governanceResult(ctx context.Context, env Env, region string) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return CheckResult{
+			ID:      "bedrock.invocation_logging",
+			Code:    codeAWSConfig,
+			Name:    "Model Invocation Logging",
+			Status:  "warn",
+			Message: fmt.Sprintf("failed to load AWS config: %v", err),
+			Fix:     "Check AWS credentials and configuration",
+		}
+	}
+
+	out, err := env.Bedrock(cfg).GetModelInvocationLoggingConfiguration(ctx, &bedrock.GetModelInvocationLoggingConfigurationInput{})
+	if err != nil {
+		result := CheckResult{
+			ID:      "bedrock.invocation_logging",
+			Name:    "Model Invocation Logging",
+			Status:  "warn",
+			Message: fmt.Sprintf("bedrock API call failed: %v", err),
+			Fix:     "Check AWS credentials and network access to the Bedrock control plane",
+		}
+		result.setAWSError(err, "bedrock:GetModelInvocationLoggingConfiguration")
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return result
+	}
+
+	logging := out.LoggingConfig
+	if logging == nil || (logging.S3Config == nil && logging.CloudWatchConfig == nil) {
+		return CheckResult{
+			ID:      "bedrock.invocation_logging",
+			Code:    codeLoggingDisabled,
+			Name:    "Model Invocation Logging",
+			Status:  "warn",
+			Message: fmt.Sprintf("Model invocation logging is disabled in %s", region),
+			Fix:     "Enable model invocation logging in the Bedrock console (Settings) with an S3 bucket or CloudWatch log group destination",
+		}
+	}
+
+	var destinations []string
+	if cw := logging.CloudWatchConfig; cw != nil {
+		destinations = append(destinations, fmt.Sprintf("CloudWatch log group %s (role %s)",
+			aws.ToString(cw.LogGroupName), aws.ToString(cw.RoleArn)))
+	}
+	if s3cfg := logging.S3Config; s3cfg != nil {
+		bucket := aws.ToString(s3cfg.BucketName)
+		destinations = append(destinations, fmt.Sprintf("s3://%s/%s", bucket, aws.ToString(s3cfg.KeyPrefix)))
+
+		if err := verifyLoggingBucket(ctx, env, cfg, bucket); err != nil {
+			fix := "Confirm the bucket exists in this account and its policy allows bedrock.amazonaws.com to write objects"
+			if strings.Contains(err.Error(), "AccessDenied") || strings.Contains(err.Error(), "403") {
+				fix = "Add s3:ListBucket permission on the logging bucket to verify it, or confirm the bucket is owned by this account"
+			}
+			result := CheckResult{
+				ID:      "bedrock.invocation_logging",
+				Code:    codeLoggingBucket,
+				Name:    "Model Invocation Logging",
+				Status:  "warn",
+				Message: fmt.Sprintf("Logging enabled to %s, but %v", strings.Join(destinations, " and "), err),
+				Fix:     fix,
+			}
+			result.attachDiagnostics(env, awsDiagnostics(err))
+			return result
+		}
+	}
+
+	return CheckResult{
+		ID:      "bedrock.invocation_logging",
+		Name:    "Model Invocation Logging",
+		Status:  "pass",
+		Message: fmt.Sprintf("Logging enabled to %s", strings.Join(destinations, " and ")),
+	}
+}
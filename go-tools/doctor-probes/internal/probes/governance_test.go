@@ -0,0 +1,93 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestGovernanceResult(t *testing.T) {
+	s3Logging := `{"loggingConfig":{"s3Config":{"bucketName":"bedrock-logs","keyPrefix":"claude/"}}}`
+	tests := []struct {
+		name     string
+		logging  string // body of GetModelInvocationLoggingConfiguration; empty denies it
+		bucket   int    // status of HeadBucket
+		status   string
+		contains []string
+	}{
+		{
+			name:     "logging disabled",
+			logging:  `{}`,
+			status:   "warn",
+			contains: []string{"Model invocation logging is disabled in us-east-1", "Enable model invocation logging"},
+		},
+		{
+			name:     "CloudWatch destination",
+			logging:  `{"loggingConfig":{"cloudWatchConfig":{"logGroupName":"/bedrock/claude","roleArn":"arn:aws:iam::123456789012:role/logs"}}}`,
+			status:   "pass",
+			contains: []string{"Logging enabled to CloudWatch log group /bedrock/claude (role arn:aws:iam::123456789012:role/logs)"},
+		},
+		{
+			name:     "S3 bucket in this account",
+			logging:  s3Logging,
+			bucket:   http.StatusOK,
+			status:   "pass",
+			contains: []string{"Logging enabled to s3://bedrock-logs/claude/"},
+		},
+		{
+			name:     "S3 bucket not verifiable",
+			logging:  s3Logging,
+			bucket:   http.StatusForbidden,
+			status:   "warn",
+			contains: []string{"bucket bedrock-logs not found in account 123456789012", "s3:ListBucket"},
+		},
+		{
+			name:     "S3 bucket missing",
+			logging:  s3Logging,
+			bucket:   http.StatusNotFound,
+			status:   "warn",
+			contains: []string{"bucket bedrock-logs not found", "allows bedrock.amazonaws.com to write"},
+		},
+		{
+			name:     "permission denied",
+			status:   "warn",
+			contains: []string{"bedrock API call failed", "bedrock:GetModelInvocationLoggingConfiguration permission"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/logging/modelinvocations" && tt.logging == "":
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+				case r.URL.Path == "/logging/modelinvocations":
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(tt.logging))
+				case r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/bedrock-logs"):
+					if r.Header.Get("X-Amz-Expected-Bucket-Owner") != "123456789012" {
+						t.Errorf("expected bucket owner = %q", r.Header.Get("X-Amz-Expected-Bucket-Owner"))
+					}
+					w.WriteHeader(tt.bucket)
+				case r.Method == http.MethodPost && r.URL.Path == "/":
+					probestest.CallerIdentity(w, "arn:aws:iam::123456789012:user/dev")
+				default:
+					t.Errorf("unexpected %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			})
+			got := governanceResult(context.Background(), NewEnv(RunSettings{}), "us-east-1")
+			if got.Name != "Model Invocation Logging" || got.Status != tt.status {
+				t.Errorf("name, status = %q, %s; want Model Invocation Logging, %s (%s)", got.Name, got.Status, tt.status, got.Message)
+			}
+			text := got.Message + " " + got.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// checkHTTPSConnectivity checks that the endpoint answers as AWS; it does not
+// check authorization. It also returns the last response. Redirects are not
+// followed, and an endpoint that refuses HEAD is probed with a ranged GET.
+func checkHTTPSConnectivity(env Env, url string) (latencyBreakdown, *httpsExchange, error) {
+	return sampleLatency(url, env.HTTP)
+}
+
+// sampleLatency is checkHTTPSConnectivity over the clients newClient returns,
+// one per sample.
+func sampleLatency(url string, newClient func(host string) HTTPDoer) (latencyBreakdown, *httpsExchange, error) {
+	var samples []latencyBreakdown
+	var exchange *httpsExchange
+	host, _, _ := endpointAddr(url)
+	method := http.MethodHead
+	for i := 0; i < latencySamples; i++ {
+		var sample latencyBreakdown
+		var start time.Time
+		var resp *http.Response
+		for {
+			// Fresh connection per sample so each one pays the full DNS/TCP/TLS cost
+			client := newClient(host)
+			sample, start = latencyBreakdown{}, time.Now()
+			req, err := newProbeRequest(httptrace.WithClientTrace(context.Background(), newLatencyTrace(&sample, start)), method, url)
+			if err != nil {
+				return latencyBreakdown{}, nil, err
+			}
+			resp, err = client.Do(req)
+			if err != nil {
+				logProbe("https", url, start, err, "sample", i+1, "method", method)
+				return latencyBreakdown{}, nil, err
+			}
+			if method != http.MethodHead || !refusesHEAD(resp.StatusCode) {
+				break
+			}
+			// The HEAD measured an error path, not the endpoint; time a GET
+			// instead, for this sample and the rest.
+			resp.Body.Close()
+			logProbe("https", url, start, nil, "sample", i+1, "method", method, "http_status", resp.StatusCode)
+			method = http.MethodGet
+		}
+		exchange = readExchange(method, resp)
+		logProbe("https", url, start, nil, "sample", i+1, "method", method, "http_status", exchange.StatusCode,
+			"server", exchange.Server, "size", exchange.Size, "request_id", exchange.Diag.RequestID, "latency", sample.metrics())
+
+		if err := reachabilityError(resp); err != nil {
+			return latencyBreakdown{}, exchange, err
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return medianLatency(samples), exchange, nil
+}
+
+// httpsConnectivityResult probes the Bedrock runtime endpoint with retries.
+func httpsConnectivityResult(env Env, bedrockURL string, ttfbThreshold time.Duration) CheckResult {
+	var latency latencyBreakdown
+	var exchange *httpsExchange
+	failures, err := retryProbe(func() (err error) {
+		latency, exchange, err = checkHTTPSConnectivity(env, bedrockURL)
+		return err
+	})
+	if err != nil {
+		code := classifyNetError(err)
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode != http.StatusProxyAuthRequired {
+			code = codeHTTPStatus
+		}
+		result := CheckResult{
+			ID:      "https.bedrock_runtime",
+			Code:    code,
+			Name:    "HTTPS Connectivity",
+			Status:  "fail",
+			Message: fmt.Sprintf("Failed to connect to %s: %v", bedrockURL, err),
+			Fix:     "Check firewall, proxy settings, or VPC endpoint configuration" + env.Host.proxyHint(),
+		}
+		if exchange != nil {
+			result.Metrics = exchange.metrics()
+			result.attachDiagnostics(env, exchange.Diag)
+		}
+		result.noteRetries(failures, err)
+		return result
+	}
+
+	result := CheckResult{
+		ID:      "https.bedrock_runtime",
+		Name:    "HTTPS Connectivity",
+		Status:  "pass",
+		Message: fmt.Sprintf("Reachable: AWS answered the unsigned probe of %s (%s); authorization is checked under Bedrock API Access (median of %d: %s)", bedrockURL, exchange, latencySamples, latency),
+		Metrics: latency.metrics(),
+	}
+	for k, v := range exchange.metrics() {
+		result.Metrics[k] = v
+	}
+	switch {
+	case exchange.isRedirect():
+		// AWS API endpoints answer requests in place; a redirect means
+		// something between here and AWS is steering the traffic.
+		result.Status, result.Code = "warn", codeHTTPSRedirect
+		result.Message = fmt.Sprintf("%s answered HTTP %d redirecting to %q, which a Bedrock endpoint never does (median of %d: %s)", bedrockURL, exchange.StatusCode, RedactSecrets(exchange.Location), latencySamples, latency)
+		result.Fix = "Something on the path (proxy, gateway, or DNS) rewrites requests to the Bedrock endpoint; check the endpoint URL and where the redirect target is served from" + env.Host.proxyHint()
+	case latency.TTFB > ttfbThreshold:
+		result.Status = "warn"
+		result.Code = codeSlowTTFB
+		result.Fix = fmt.Sprintf("Time to first byte exceeds %s; Claude Code will feel slow on this link. Check VPN/proxy routing or use a closer region", ttfbThreshold)
+	}
+	result.attachDiagnostics(env, exchange.Diag)
+	result.noteRetries(failures, nil)
+	return result
+}
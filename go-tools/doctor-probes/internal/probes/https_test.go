@@ -0,0 +1,204 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckHTTPSConnectivity(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		requestID string // empty for a non-AWS server
+		getStatus int    // answer to GET when HEAD is refused
+		err       string
+	}{
+		{name: "answered", status: http.StatusOK, requestID: "req-1"},
+		{name: "HEAD refused, ranged GET", status: http.StatusMethodNotAllowed, requestID: "req-1", getStatus: http.StatusPartialContent},
+		{name: "unsigned probe forbidden by AWS", status: http.StatusForbidden, requestID: "req-1"},
+		{name: "block page", status: http.StatusForbidden, err: "HTTP 403 from a non-AWS server"},
+		{name: "captive portal redirect is not followed", status: http.StatusFound, err: "HTTP 302 from a non-AWS server"},
+		{name: "proxy auth", status: http.StatusProxyAuthRequired, requestID: "req-1", err: "HTTP 407 Proxy Authentication Required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.requestID != "" {
+					w.Header().Set("x-amzn-RequestId", tt.requestID)
+				}
+				if r.Method == http.MethodGet {
+					if tt.getStatus == 0 || r.Header.Get("Range") != "bytes=0-1023" {
+						t.Errorf("GET with Range %q, want only a ranged GET after HEAD was refused", r.Header.Get("Range"))
+					}
+					requests++
+					w.WriteHeader(tt.getStatus)
+					w.Write([]byte("partial"))
+					return
+				}
+				if tt.getStatus == 0 {
+					requests++
+				}
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "/portal")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			wantMethod, wantStatus := http.MethodHead, tt.status
+			if tt.getStatus != 0 {
+				wantMethod, wantStatus = http.MethodGet, tt.getStatus
+			}
+			latency, exchange, err := checkHTTPSConnectivity(NewEnv(RunSettings{}), srv.URL)
+			if exchange == nil || exchange.Method != wantMethod || exchange.StatusCode != wantStatus || exchange.Diag.RequestID != tt.requestID {
+				t.Errorf("exchange = %+v, want %s answered HTTP %d from request %q", exchange, wantMethod, wantStatus, tt.requestID)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				if requests != 1 {
+					t.Errorf("%d requests, want the first failure to stop sampling", requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if requests != latencySamples {
+				t.Errorf("%d requests, want %d samples", requests, latencySamples)
+			}
+			if latency.Connect <= 0 || latency.TTFB <= 0 {
+				t.Errorf("latency = %+v, want connect and ttfb measured", latency)
+			}
+		})
+	}
+}
+
+// httpsServer is a TLS test server running handler, and an environment
+// whose probe clients trust it and report redirects rather than follow them.
+func httpsServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, Env) {
+	t.Helper()
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	env := NewEnv(RunSettings{})
+	env.HTTP = func(string) HTTPDoer {
+		client := srv.Client()
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		return client
+	}
+	return srv, env
+}
+
+func TestHTTPSConnectivity(t *testing.T) {
+	aws := func(status int, header ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("x-amzn-RequestId", "req-1")
+			for i := 0; i+1 < len(header); i += 2 {
+				w.Header().Set(header[i], header[i+1])
+			}
+			w.WriteHeader(status)
+		}
+	}
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		threshold time.Duration
+		status    string
+		code      string
+		contains  string
+		attempts  int
+	}{
+		{
+			name:     "AWS answers the unsigned probe",
+			handler:  aws(http.StatusForbidden),
+			status:   "pass",
+			contains: "Reachable: AWS answered the unsigned probe of https://127.0.0.1:",
+		},
+		{
+			name:      "slow first byte",
+			handler:   aws(http.StatusForbidden),
+			threshold: time.Nanosecond,
+			status:    "warn",
+			code:      codeSlowTTFB,
+			contains:  "Reachable",
+		},
+		{
+			name:     "redirect from AWS",
+			handler:  aws(http.StatusFound, "Location", "https://login.example.com/?token=eyJa.eyJb.c"),
+			status:   "warn",
+			code:     codeHTTPSRedirect,
+			contains: `answered HTTP 302 redirecting to "https://login.example.com/?token=[REDACTED]"`,
+		},
+		{
+			name: "block page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			status:   "fail",
+			code:     codeHTTPStatus,
+			contains: "HTTP 403 from a non-AWS server",
+		},
+		{
+			name:     "proxy wants credentials",
+			handler:  aws(http.StatusProxyAuthRequired),
+			status:   "fail",
+			code:     codeHTTP407,
+			contains: "HTTP 407 Proxy Authentication Required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, env := httpsServer(t, tt.handler)
+			threshold := tt.threshold
+			if threshold == 0 {
+				threshold = time.Minute
+			}
+			check := HTTPSConnectivity{URL: srv.URL, TTFBThreshold: threshold}
+			r := check.Run(context.Background(), env)
+			if r.ID != check.ID() || r.Name != check.Name() {
+				t.Errorf("result is %s %q, want %s %q", r.ID, r.Name, check.ID(), check.Name())
+			}
+			if r.Status != tt.status || r.Code != tt.code || !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("got %s %s %q, want %s %s containing %q", r.Status, r.Code, r.Message, tt.status, tt.code, tt.contains)
+			}
+			if tt.status != "fail" && r.Metrics["ttfb_ms"] == 0 {
+				t.Errorf("metrics = %v, want the latency of the answer", r.Metrics)
+			}
+		})
+	}
+}
+
+func TestHTTPSConnectivityRetries(t *testing.T) {
+	t.Run("refused is not retried", func(t *testing.T) {
+		srv, env := httpsServer(t, nil)
+		srv.Close()
+		r := HTTPSConnectivity{URL: srv.URL, TTFBThreshold: time.Minute}.Run(context.Background(), env)
+		if r.Status != "fail" || r.Code != codeConnectRefused || r.Attempts != 1 {
+			t.Errorf("got %s %s after %d attempts (%s), want fail %s after 1", r.Status, r.Code, r.Attempts, r.Message, codeConnectRefused)
+		}
+	})
+
+	t.Run("dropped once", func(t *testing.T) {
+		var dropped atomic.Bool
+		srv, env := httpsServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if dropped.CompareAndSwap(false, true) {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.Header().Set("x-amzn-RequestId", "req-1")
+			w.WriteHeader(http.StatusForbidden)
+		})
+		r := HTTPSConnectivity{URL: srv.URL, TTFBThreshold: time.Minute}.Run(context.Background(), env)
+		if r.Status != "warn" || r.Attempts != 2 || len(r.AttemptErrors) != 1 {
+			t.Errorf("got %s after %d attempts %v (%s), want warn after 2", r.Status, r.Attempts, r.AttemptErrors, r.Message)
+		}
+	})
+}
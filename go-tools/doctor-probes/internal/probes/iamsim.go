@@ -0,0 +1,174 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Actions Claude Code calls on a model or inference profile.
+var invokeActions = []string{
+	"bedrock:InvokeModel",
+	"bedrock:InvokeModelWithResponseStream",
+	"bedrock:Converse",
+	"bedrock:ConverseStream",
+}
+
+// Account-level actions used for model discovery.
+var listActions = []string{
+	"bedrock:ListFoundationModels",
+	"bedrock:ListInferenceProfiles",
+}
+
+// principalARN converts an STS caller ARN into the IAM ARN that
+// SimulatePrincipalPolicy accepts. Assumed-role sessions map to their role;
+// the role's path is looked up when the caller may read it.
+func principalARN(ctx context.Context, client IAMClient, callerARN string) (string, error) {
+	parsed, err := arn.Parse(callerARN)
+	if err != nil {
+		return "", err
+	}
+	roleName, ok := assumedRoleName(callerARN)
+	if !ok {
+		return callerARN, nil
+	}
+
+	if out, err := client.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)}); err == nil && out.Role != nil {
+		return aws.ToString(out.Role.Arn), nil
+	}
+	return arn.ARN{Partition: parsed.Partition, Service: "iam", AccountID: parsed.AccountID, Resource: "role/" + roleName}.String(), nil
+}
+
+// modelResourceARN returns the ARN of the configured model: ARNs are used as
+// is, cross-region profile IDs become inference-profile ARNs, and anything
+// else is treated as a foundation model ID.
+func modelResourceARN(partition, region, account, model string) string {
+	if strings.HasPrefix(model, "arn:") {
+		return model
+	}
+	for _, prefix := range crossRegionPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return fmt.Sprintf("arn:%s:bedrock:%s:%s:inference-profile/%s", partition, region, account, model)
+		}
+	}
+	return fmt.Sprintf("arn:%s:bedrock:%s::foundation-model/%s", partition, region, model)
+}
+
+func simulate(ctx context.Context, client IAMClient, principal string, actions, resources []string) ([]iamtypes.EvaluationResult, error) {
+	var results []iamtypes.EvaluationResult
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(client, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     actions,
+		ResourceArns:    resources,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, page.EvaluationResults...)
+	}
+	return results, nil
+}
+
+// describeDenial names the decision and, for explicit denies, the statement
+// that matched.
+func describeDenial(r iamtypes.EvaluationResult) string {
+	s := fmt.Sprintf("%s on %s: %s", aws.ToString(r.EvalActionName), aws.ToString(r.EvalResourceName), r.EvalDecision)
+	for _, stmt := range r.MatchedStatements {
+		s += fmt.Sprintf(" (%s %s", stmt.SourcePolicyType, aws.ToString(stmt.SourcePolicyId))
+		if stmt.StartPosition != nil {
+			s += fmt.Sprintf(" line %d", stmt.StartPosition.Line)
+		}
+		s += ")"
+	}
+	if r.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeImplicitDeny && len(r.MatchedStatements) == 0 {
+		s += " (no statement allows it)"
+	}
+	return s
+}
+
+func iamSimulationResult(ctx context.Context, env Env, region string) CheckResult {
+	result := CheckResult{ID: "bedrock.iam_simulation", Name: "IAM Policy Simulation"}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		result.Fix = "Check AWS credentials and configuration"
+		return result
+	}
+
+	identity, err := CallerIdentity(ctx, env, cfg)
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("failed to get caller identity: %v", err)
+		result.Fix = "Check AWS credentials and configuration"
+		result.setAWSError(err, "sts:GetCallerIdentity")
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return result
+	}
+	callerARN := aws.ToString(identity.Arn)
+	account := aws.ToString(identity.Account)
+
+	client := env.IAM(cfg)
+	principal, err := principalARN(ctx, client, callerARN)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSAPI
+		result.Message = fmt.Sprintf("cannot derive a simulatable principal from %s: %v", callerARN, err)
+		return result
+	}
+
+	modelResources := []string{"*"}
+	if model := configuredModel(); model != "" {
+		modelResources = []string{modelResourceARN(partitionForRegion(region).ID, region, account, model)}
+	}
+
+	evaluations, err := simulate(ctx, client, principal, invokeActions, modelResources)
+	if err == nil {
+		var listEvaluations []iamtypes.EvaluationResult
+		listEvaluations, err = simulate(ctx, client, principal, listActions, []string{"*"})
+		evaluations = append(evaluations, listEvaluations...)
+	}
+	if err != nil {
+		// Simulation is a diagnostic extra; lacking permission for it is not a
+		// Claude Code problem.
+		result.Status, result.Code = "warn", awsErrorCode(err)
+		result.Message = fmt.Sprintf("iam:SimulatePrincipalPolicy failed for %s: %v", principal, err)
+		result.Fix = "Grant iam:SimulatePrincipalPolicy (and iam:GetRole) on this principal to run the simulation, or ask an IAM admin to run it"
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return result
+	}
+
+	var allowed, denied []string
+	for _, e := range evaluations {
+		if e.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			allowed = append(allowed, aws.ToString(e.EvalActionName))
+		} else {
+			denied = append(denied, describeDenial(e))
+		}
+	}
+
+	if len(denied) > 0 {
+		result.Status, result.Code = "fail", codeIAMSimulatedDeny
+		result.Message = fmt.Sprintf("%s is denied: %s", principal, strings.Join(denied, "; "))
+		if len(allowed) > 0 {
+			result.Message += fmt.Sprintf("; allowed: %s", strings.Join(allowed, ", "))
+		}
+		result.Fix = "Ask your IAM admin to allow the denied actions on the listed resources, or remove the matching Deny statement"
+		return result
+	}
+
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("%s is allowed all %d actions Claude Code needs (%s)", principal, len(allowed), strings.Join(allowed, ", "))
+	return result
+}
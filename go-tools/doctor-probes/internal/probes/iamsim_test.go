@@ -0,0 +1,216 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestModelResourceARN(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"anthropic.claude-3-5-sonnet-20241022-v2:0", "arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-3-5-sonnet-20241022-v2:0"},
+		{"us.anthropic.claude-3-5-sonnet-20241022-v2:0", "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-5-sonnet-20241022-v2:0"},
+		{"global.anthropic.claude-sonnet-4-20250514-v1:0", "arn:aws:bedrock:us-east-1:123456789012:inference-profile/global.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"arn:aws:bedrock:us-west-2:123456789012:application-inference-profile/abc", "arn:aws:bedrock:us-west-2:123456789012:application-inference-profile/abc"},
+	}
+	for _, tt := range tests {
+		if got := modelResourceARN("aws", "us-east-1", "123456789012", tt.model); got != tt.want {
+			t.Errorf("modelResourceARN(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeDenial(t *testing.T) {
+	tests := []struct {
+		name string
+		eval iamtypes.EvaluationResult
+		want string
+	}{
+		{
+			name: "implicit deny",
+			eval: iamtypes.EvaluationResult{EvalActionName: aws.String("bedrock:InvokeModel"), EvalResourceName: aws.String("*"), EvalDecision: "implicitDeny"},
+			want: "bedrock:InvokeModel on *: implicitDeny (no statement allows it)",
+		},
+		{
+			name: "explicit deny",
+			eval: iamtypes.EvaluationResult{
+				EvalActionName:   aws.String("bedrock:Converse"),
+				EvalResourceName: aws.String("*"),
+				EvalDecision:     "explicitDeny",
+				MatchedStatements: []iamtypes.Statement{
+					{SourcePolicyType: "IAM Policy", SourcePolicyId: aws.String("DenyBedrock"), StartPosition: &iamtypes.Position{Line: 7}},
+				},
+			},
+			want: "bedrock:Converse on *: explicitDeny (IAM Policy DenyBedrock line 7)",
+		},
+	}
+	for _, tt := range tests {
+		if got := describeDenial(tt.eval); got != tt.want {
+			t.Errorf("%s: describeDenial = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakeIAM answers STS GetCallerIdentity, IAM GetRole, and
+// SimulatePrincipalPolicy, denying the actions in deny.
+type fakeIAM struct {
+	caller     string
+	roleARN    string // GetRole answer; empty denies GetRole
+	maxSession int32  // MaxSessionDuration in seconds, reported by GetRole
+	deny       map[string]string
+	simError   bool
+
+	mu         sync.Mutex
+	principals []string
+	resources  []string
+}
+
+func (f *fakeIAM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	switch r.Form.Get("Action") {
+	case "GetCallerIdentity":
+		probestest.CallerIdentity(w, f.caller)
+	case "GetRole":
+		if f.roleARN == "" {
+			queryError(w, "AccessDenied", "not authorized to perform iam:GetRole")
+			return
+		}
+		fmt.Fprintf(w, `<GetRoleResponse><GetRoleResult><Role><Arn>%s</Arn><MaxSessionDuration>%d</MaxSessionDuration></Role></GetRoleResult></GetRoleResponse>`, f.roleARN, f.maxSession)
+	case "SimulatePrincipalPolicy":
+		if f.simError {
+			queryError(w, "AccessDenied", "not authorized to perform iam:SimulatePrincipalPolicy")
+			return
+		}
+		f.mu.Lock()
+		f.principals = append(f.principals, r.Form.Get("PolicySourceArn"))
+		f.resources = append(f.resources, r.Form.Get("ResourceArns.member.1"))
+		f.mu.Unlock()
+		var members strings.Builder
+		for i := 1; r.Form.Has(fmt.Sprintf("ActionNames.member.%d", i)); i++ {
+			action := r.Form.Get(fmt.Sprintf("ActionNames.member.%d", i))
+			decision := "allowed"
+			if d, ok := f.deny[action]; ok {
+				decision = d
+			}
+			fmt.Fprintf(&members, `<member><EvalActionName>%s</EvalActionName><EvalResourceName>%s</EvalResourceName><EvalDecision>%s</EvalDecision></member>`,
+				action, r.Form.Get("ResourceArns.member.1"), decision)
+		}
+		fmt.Fprintf(w, `<SimulatePrincipalPolicyResponse><SimulatePrincipalPolicyResult><IsTruncated>false</IsTruncated><EvaluationResults>%s</EvaluationResults></SimulatePrincipalPolicyResult></SimulatePrincipalPolicyResponse>`, members.String())
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// queryError writes an error the way the AWS query APIs do.
+func queryError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>r-1</RequestId></ErrorResponse>`, code, message)
+}
+
+func TestIAMSimulationResult(t *testing.T) {
+	const userARN = "arn:aws:iam::123456789012:user/dev"
+	const sessionARN = "arn:aws:sts::123456789012:assumed-role/Developer/alice"
+	tests := []struct {
+		name      string
+		fake      *fakeIAM
+		model     string
+		status    string
+		code      string
+		contains  []string
+		principal string
+		resource  string
+	}{
+		{
+			name:      "everything allowed",
+			fake:      &fakeIAM{caller: userARN},
+			status:    "pass",
+			contains:  []string{userARN + " is allowed all 6 actions Claude Code needs"},
+			principal: userARN,
+			resource:  "*",
+		},
+		{
+			name:      "assumed role resolved with its path",
+			fake:      &fakeIAM{caller: sessionARN, roleARN: "arn:aws:iam::123456789012:role/teams/Developer"},
+			status:    "pass",
+			principal: "arn:aws:iam::123456789012:role/teams/Developer",
+		},
+		{
+			name:      "assumed role without iam:GetRole",
+			fake:      &fakeIAM{caller: sessionARN},
+			status:    "pass",
+			principal: "arn:aws:iam::123456789012:role/Developer",
+		},
+		{
+			name: "streaming denied on the configured model",
+			fake: &fakeIAM{caller: userARN, deny: map[string]string{
+				"bedrock:InvokeModelWithResponseStream": "implicitDeny",
+				"bedrock:ConverseStream":                "explicitDeny",
+			}},
+			model:  "us.anthropic.claude-3-5-sonnet-20241022-v2:0",
+			status: "fail",
+			code:   codeIAMSimulatedDeny,
+			contains: []string{
+				"bedrock:InvokeModelWithResponseStream on arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-5-sonnet-20241022-v2:0: implicitDeny",
+				"bedrock:ConverseStream on",
+				"; allowed: bedrock:InvokeModel, bedrock:Converse, bedrock:ListFoundationModels, bedrock:ListInferenceProfiles",
+			},
+			resource: "arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-5-sonnet-20241022-v2:0",
+		},
+		{
+			name:     "simulation not permitted",
+			fake:     &fakeIAM{caller: userARN, simError: true},
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"iam:SimulatePrincipalPolicy failed for " + userARN},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := tt.fake
+			probestest.AWS(t, fake.ServeHTTP)
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			got := iamSimulationResult(context.Background(), NewEnv(RunSettings{}), "us-east-1")
+			if got.ID != "bedrock.iam_simulation" || got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", got.ID, got.Status, got.Code, got.Message, tt.status, tt.code)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got.Message, want) {
+					t.Errorf("message %q does not contain %q", got.Message, want)
+				}
+			}
+
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if tt.principal != "" {
+				for _, p := range fake.principals {
+					if p != tt.principal {
+						t.Errorf("simulated principal %q, want %q", p, tt.principal)
+					}
+				}
+			}
+			if tt.resource != "" {
+				// The invoke actions target the model; the list actions always "*".
+				resources := append([]string(nil), fake.resources...)
+				sort.Strings(resources)
+				if len(resources) != 2 || !slices.Contains(resources, tt.resource) || !slices.Contains(resources, "*") {
+					t.Errorf("simulated resources = %q, want %q and *", resources, tt.resource)
+				}
+			}
+		})
+	}
+}
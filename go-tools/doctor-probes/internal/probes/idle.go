@@ -0,0 +1,200 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idleKeepAlive is the TCP keepalive interval while the probe connection
+// sits idle; it is the only traffic on it.
+const idleKeepAlive = 15 * time.Second
+
+// idleProbe is the outcome of holding one connection idle.
+type idleProbe struct {
+	Route   string // "direct" or "via proxy"
+	Held    time.Duration
+	Cutoff  time.Duration // when the connection was closed; zero if it never was
+	Silent  bool          // survived the idle period but could not be reused
+	Err     error         // failure to set up the connection at all
+	ReadErr error         // how the connection ended
+}
+
+func (p idleProbe) survived() bool {
+	return p.Err == nil && p.Cutoff == 0 && !p.Silent
+}
+
+func (p idleProbe) String() string {
+	switch {
+	case p.Err != nil:
+		return fmt.Sprintf("%s: could not connect: %v", p.Route, p.Err)
+	case p.Cutoff > 0:
+		return fmt.Sprintf("%s: closed after %s idle (%v)", p.Route, FormatSeconds(DurationMs(p.Cutoff)), p.ReadErr)
+	case p.Silent:
+		return fmt.Sprintf("%s: still open after %s idle but dropped on reuse (%v), so something discarded it without a reset", p.Route, FormatSeconds(DurationMs(p.Held)), p.ReadErr)
+	}
+	return fmt.Sprintf("%s: survived %s idle and was reused", p.Route, FormatSeconds(DurationMs(p.Held)))
+}
+
+// idleRequest sends one HEAD request on conn and reads the response, as a
+// keep-alive connection is used.
+func idleRequest(conn net.Conn, br *bufio.Reader, host string) error {
+	req, _ := http.NewRequest(http.MethodHead, "https://"+host+"/", nil)
+	req.Header.Set("Connection", "keep-alive")
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.Close {
+		return errors.New("the server asked to close the connection after the first request")
+	}
+	return nil
+}
+
+// holdIdle opens a TLS connection with cfg to addr on route, makes one
+// request, then leaves it idle for hold with only TCP keepalives, watching
+// for it to be closed, and finally makes a second request on it.
+func holdIdle(env Env, route, addr string, proxy *url.URL, cfg *tls.Config, hold time.Duration) idleProbe {
+	probe := idleProbe{Route: route, Held: hold}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialRoute(ctx, env, &net.Dialer{Timeout: 5 * time.Second, KeepAlive: idleKeepAlive}, addr, proxy)
+	if err == nil {
+		conn = tls.Client(conn, cfg)
+		if err = conn.(*tls.Conn).HandshakeContext(ctx); err != nil {
+			conn.Close()
+		}
+	}
+	logProbe("idle", addr, start, err, "route", route, "phase", "connect")
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	if err := idleRequest(conn, br, cfg.ServerName); err != nil {
+		probe.Err = err
+		return probe
+	}
+
+	// A read blocks for as long as the connection is idle, and returns the
+	// moment anything on the path closes or resets it.
+	idleStart := time.Now()
+	conn.SetReadDeadline(idleStart.Add(hold))
+	_, err = br.Peek(1)
+	idle := time.Since(idleStart)
+	var netErr net.Error
+	if err == nil || !errors.As(err, &netErr) || !netErr.Timeout() {
+		probe.Cutoff, probe.ReadErr = idle, err
+		if err == nil {
+			probe.ReadErr = errors.New("unsolicited data")
+		}
+		logProbe("idle", addr, idleStart, err, "route", route, "phase", "idle", "cutoff_s", idle.Seconds())
+		return probe
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	start = time.Now()
+	err = idleRequest(conn, br, cfg.ServerName)
+	logProbe("idle", addr, start, err, "route", route, "phase", "reuse")
+	if err != nil {
+		probe.Silent, probe.ReadErr = true, err
+	}
+	return probe
+}
+
+// idleProbeResult holds a connection to the Bedrock runtime idle for hold,
+// as Claude Code's stream sits quiet during long tool calls, on the route
+// Claude Code takes and, with a proxy, directly as well, and reports whether
+// it survived and when it was cut if not. It is never worse than a warning.
+func idleProbeResult(env Env, bedrockURL string, hold time.Duration) CheckResult {
+	result := CheckResult{ID: "network.idle_connection", Name: "Idle Connection Probe"}
+
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+
+	// Both routes are held at once so the probe takes hold, not twice that.
+	probes := []idleProbe{{Route: "direct"}}
+	proxies := []*url.URL{nil}
+	if proxy != nil {
+		probes = []idleProbe{{Route: "via proxy " + proxyDisplay(proxy)}, {Route: "direct"}}
+		proxies = []*url.URL{proxy, nil}
+	}
+	var wg sync.WaitGroup
+	for i := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := &tls.Config{ServerName: host, NextProtos: []string{"http/1.1"}}
+			probes[i] = holdIdle(env, probes[i].Route, addr, proxies[i], cfg, hold)
+		}()
+	}
+	wg.Wait()
+
+	var parts []string
+	result.Metrics = map[string]float64{"idle_held_s": hold.Seconds()}
+	for i, p := range probes {
+		parts = append(parts, p.String())
+		key := "idle_cutoff_s_direct"
+		if proxies[i] != nil {
+			key = "idle_cutoff_s_proxy"
+		}
+		if p.Cutoff > 0 {
+			result.Metrics[key] = p.Cutoff.Seconds()
+		}
+	}
+	result.Message = strings.Join(parts, "; ")
+
+	primary := probes[0]
+	switch {
+	case primary.Err != nil:
+		result.Status, result.Code = "warn", classifyNetError(primary.Err)
+		result.Fix = "See HTTPS Connectivity; no connection could be opened to hold idle"
+	case primary.survived():
+		result.Status = "pass"
+	default:
+		result.Status, result.Code = "warn", codeIdleCutoff
+		cutoff := fmt.Sprintf("within %s", FormatSeconds(DurationMs(hold)))
+		if primary.Cutoff > 0 {
+			cutoff = fmt.Sprintf("after %s", FormatSeconds(DurationMs(primary.Cutoff)))
+		}
+		result.Message = fmt.Sprintf("Idle connections are cut %s, so responses that pause longer stop mid-stream; %s", cutoff, result.Message)
+		switch {
+		case proxy != nil && probes[1].survived():
+			result.Fix = fmt.Sprintf("The proxy %s closes idle tunnels %s while the direct route holds them; raise its idle timeout for *.amazonaws.com above %s, or add the Bedrock runtime host to NO_PROXY", proxyDisplay(proxy), cutoff, FormatSeconds(DurationMs(hold)))
+		case proxy != nil && probes[1].Err != nil:
+			result.Fix = fmt.Sprintf("The proxy %s, or the network behind it, closes idle tunnels %s (there is no direct route to compare); raise its idle timeout for *.amazonaws.com above %s", proxyDisplay(proxy), cutoff, FormatSeconds(DurationMs(hold)))
+		case proxy != nil:
+			result.Fix = fmt.Sprintf("Both routes cut idle connections, so a firewall or NAT gateway on the common path does; raise its idle timeout above %s", FormatSeconds(DurationMs(hold)))
+		default:
+			result.Fix = fmt.Sprintf("A firewall, NAT gateway, or VPN on the path cuts idle connections %s; raise its TCP idle timeout above %s", cutoff, FormatSeconds(DurationMs(hold)))
+		}
+	}
+	return result
+}
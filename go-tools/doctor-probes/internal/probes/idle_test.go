@@ -0,0 +1,148 @@
+package probes
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdleProbeString(t *testing.T) {
+	tests := []struct {
+		name     string
+		probe    idleProbe
+		want     string
+		survived bool
+	}{
+		{name: "survived", probe: idleProbe{Route: "direct", Held: 90 * time.Second}, want: "direct: survived 90.0s idle and was reused", survived: true},
+		{
+			name:  "closed",
+			probe: idleProbe{Route: "via proxy p:3128", Held: 90 * time.Second, Cutoff: 60 * time.Second, ReadErr: io.EOF},
+			want:  "via proxy p:3128: closed after 60.0s idle (EOF)",
+		},
+		{
+			name:  "silent drop",
+			probe: idleProbe{Route: "direct", Held: 90 * time.Second, Silent: true, ReadErr: errors.New("i/o timeout")},
+			want:  "direct: still open after 90.0s idle but dropped on reuse (i/o timeout), so something discarded it without a reset",
+		},
+		{name: "no connection", probe: idleProbe{Route: "direct", Err: errors.New("refused")}, want: "direct: could not connect: refused"},
+	}
+	for _, tt := range tests {
+		if got := tt.probe.String(); got != tt.want {
+			t.Errorf("%s: String = %q, want %q", tt.name, got, tt.want)
+		}
+		if got := tt.probe.survived(); got != tt.survived {
+			t.Errorf("%s: survived = %v, want %v", tt.name, got, tt.survived)
+		}
+	}
+}
+
+// idleServer starts a keep-alive TLS server that closes connections idle for
+// longer than idleTimeout, and returns its address and a client config that
+// trusts it.
+func idleServer(t *testing.T, idleTimeout time.Duration, handler http.HandlerFunc) (string, *tls.Config) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.Config.IdleTimeout = idleTimeout
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	cfg := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	cfg.ServerName = "example.com"
+	cfg.NextProtos = []string{"http/1.1"}
+	return srv.Listener.Addr().String(), cfg
+}
+
+func TestHoldIdle(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	tests := []struct {
+		name        string
+		idleTimeout time.Duration
+		handler     http.HandlerFunc
+		hold        time.Duration
+		survived    bool
+		cutoff      bool
+		err         string
+	}{
+		{name: "survives", idleTimeout: time.Minute, handler: ok, hold: 200 * time.Millisecond, survived: true},
+		{name: "cut while idle", idleTimeout: 100 * time.Millisecond, handler: ok, hold: 5 * time.Second, cutoff: true},
+		{
+			name:        "no keep-alive",
+			idleTimeout: time.Minute,
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.Header().Set("Connection", "close") },
+			hold:        time.Second,
+			err:         "the server asked to close the connection after the first request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			addr, cfg := idleServer(t, tt.idleTimeout, tt.handler)
+			start := time.Now()
+			p := holdIdle(env, "direct", addr, nil, cfg, tt.hold)
+			if p.survived() != tt.survived || (p.Cutoff > 0) != tt.cutoff {
+				t.Errorf("probe = %s; want survived %v, cut %v", p, tt.survived, tt.cutoff)
+			}
+			if tt.err != "" && (p.Err == nil || p.Err.Error() != tt.err) {
+				t.Errorf("err = %v, want %s", p.Err, tt.err)
+			}
+			if tt.cutoff && (p.Cutoff >= tt.hold || time.Since(start) >= tt.hold) {
+				t.Errorf("cutoff after %s; want it noticed when the server closed, not at the end of the hold", p.Cutoff)
+			}
+		})
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		env := NewEnv(RunSettings{})
+		p := holdIdle(env, "direct", "127.0.0.1:1", nil, &tls.Config{ServerName: "127.0.0.1"}, time.Second)
+		if p.Err == nil || classifyNetError(p.Err) != codeConnectRefused {
+			t.Errorf("err = %v, want connection refused", p.Err)
+		}
+	})
+}
+
+func TestIdleProbeResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		env      map[string]string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "refused",
+			url:      "https://127.0.0.1:1",
+			code:     codeConnectRefused,
+			contains: []string{"direct: could not connect: ", "See HTTPS Connectivity"},
+		},
+		{
+			name:     "both routes tried",
+			url:      "https://127.0.0.1:1",
+			env:      map[string]string{"HTTPS_PROXY": "http://127.0.0.1:1"},
+			code:     codeConnectRefused,
+			contains: []string{"via proxy http://127.0.0.1:1: could not connect: proxy 127.0.0.1:1: ", "; direct: could not connect: "},
+		},
+		{name: "bad proxy URL", url: "https://127.0.0.1:1", env: map[string]string{"HTTPS_PROXY": "http://proxy:port"}, code: codeConnectFailure, contains: []string{"Cannot parse the proxy URL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, tt.env)
+			r := idleProbeResult(env, tt.url, time.Second)
+			if r.ID != "network.idle_connection" || r.Status != "warn" || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want warn %s", r.ID, r.Status, r.Code, r.Message, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package probes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed data/tls_inspection_vendors.json
+var inspectionVendorData []byte
+
+// inspectionVendor fingerprints one SSL-inspection or secure web gateway
+// product. Each pattern is a regular expression; any match identifies the
+// vendor.
+type inspectionVendor struct {
+	Vendor   string   `json:"vendor"`
+	Issuer   []string `json:"issuer"`  // matched against each certificate's issuer DN
+	Subject  []string `json:"subject"` // matched against the leaf certificate's subject DN
+	Headers  []string `json:"headers"` // matched against "Name: value" response header lines
+	Body     []string `json:"body"`    // matched against a block page or rewritten error body
+	Guidance string   `json:"guidance"`
+
+	issuer, subject, headers, body []*regexp.Regexp
+}
+
+// setInterceptor records the TLS-inspecting product tlsInspectionResult
+// found on this run, for later checks that blame what sits on the path.
+func (env Env) setInterceptor(name string) {
+	if env.run == nil {
+		return
+	}
+	env.run.mu.Lock()
+	defer env.run.mu.Unlock()
+	env.run.interceptor = name
+}
+
+func (env Env) foundInterceptor() string {
+	if env.run == nil {
+		return ""
+	}
+	env.run.mu.Lock()
+	defer env.run.mu.Unlock()
+	return env.run.interceptor
+}
+
+// inspectionVendors is the embedded fingerprint table. It is part of the
+// binary, so a malformed entry is a build defect and panics at startup.
+var inspectionVendors = mustLoadInspectionVendors(inspectionVendorData)
+
+func mustLoadInspectionVendors(data []byte) []inspectionVendor {
+	var vendors []inspectionVendor
+	if err := json.Unmarshal(data, &vendors); err != nil {
+		panic(fmt.Sprintf("tls_inspection_vendors.json: %v", err))
+	}
+	compile := func(patterns []string) []*regexp.Regexp {
+		res := make([]*regexp.Regexp, len(patterns))
+		for i, p := range patterns {
+			res[i] = regexp.MustCompile(p)
+		}
+		return res
+	}
+	for i := range vendors {
+		v := &vendors[i]
+		v.issuer, v.subject, v.headers, v.body = compile(v.Issuer), compile(v.Subject), compile(v.Headers), compile(v.Body)
+	}
+	return vendors
+}
+
+func firstMatch(res []*regexp.Regexp, values []string) string {
+	for _, re := range res {
+		for _, v := range values {
+			if re.MatchString(v) {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// matchInspectionVendor identifies the product behind an intercepted chain
+// from its certificates and the response headers, and returns the evidence.
+func matchInspectionVendor(chain []*x509.Certificate, header http.Header) (*inspectionVendor, string) {
+	var issuers, subjects, lines []string
+	for _, cert := range chain {
+		issuers = append(issuers, cert.Issuer.String())
+	}
+	if len(chain) > 0 {
+		subjects = append(subjects, chain[0].Subject.String())
+	}
+	for name, values := range header {
+		for _, v := range values {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	sort.Strings(lines)
+
+	for i := range inspectionVendors {
+		v := &inspectionVendors[i]
+		if m := firstMatch(v.issuer, issuers); m != "" {
+			return v, "issuer " + m
+		}
+		if m := firstMatch(v.subject, subjects); m != "" {
+			return v, "subject " + m
+		}
+		if m := firstMatch(v.headers, lines); m != "" {
+			name, _, _ := strings.Cut(m, ":")
+			return v, "header " + name
+		}
+	}
+	return nil, ""
+}
+
+// awsIssued reports whether the leaf certificate was issued by Amazon's CA,
+// as every AWS service endpoint certificate is.
+func awsIssued(chain []*x509.Certificate) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	for _, org := range chain[0].Issuer.Organization {
+		if strings.Contains(org, "Amazon") {
+			return true
+		}
+	}
+	return false
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
+
+// unverifiedRouteClient returns a client that reaches host on Claude Code's
+// route (direct, HTTP proxy, or SOCKS) without verifying certificates, so
+// that what an interceptor serves can be examined. It must not carry
+// credentials.
+func unverifiedRouteClient(env Env, host string) (*http.Client, error) {
+	proxy, err := probeProxy(host)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	if isSOCKS(proxy) {
+		transport.DialContext = socksDialContext(proxy, &net.Dialer{Timeout: 5 * time.Second})
+	} else if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Timeout: 10 * time.Second, Transport: env.Engine.limited(transport)}, nil
+}
+
+// tlsInspectionResult fetches the Bedrock runtime endpoint on Claude Code's
+// route and, when the certificate was not issued by Amazon, names the
+// intercepting product from the fingerprint table. Certificates are not
+// verified here so that an untrusted interceptor can still be identified;
+// the request carries no credentials.
+func tlsInspectionResult(env Env, bedrockURL string) CheckResult {
+	result := CheckResult{ID: "tls.inspection", Name: "TLS Inspection"}
+
+	host, _, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	client, err := unverifiedRouteClient(env, host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+	start := time.Now()
+	resp, err := client.Head(bedrockURL)
+	logProbe("tls_inspection", bedrockURL, start, err)
+	if err != nil {
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("Could not fetch %s to inspect its certificate: %v", bedrockURL, err)
+		result.Fix = "See HTTPS Connectivity"
+		return result
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		result.Status, result.Code = "warn", codeTLSFailure
+		result.Message = fmt.Sprintf("%s answered without a TLS certificate", bedrockURL)
+		return result
+	}
+	chain := resp.TLS.PeerCertificates
+
+	if awsIssued(chain) {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Certificate for %s issued by %s; TLS is not intercepted", host, issuerName(chain[0]))
+		return result
+	}
+
+	result.Status, result.Code = "warn", codeTLSInspected
+	vendor, evidence := matchInspectionVendor(chain, resp.Header)
+	if vendor != nil {
+		env.setInterceptor(vendor.Vendor)
+		result.Message = fmt.Sprintf("%s is intercepting TLS to %s (%s)", vendor.Vendor, host, evidence)
+		result.Fix = vendor.Guidance
+	} else {
+		env.setInterceptor(fmt.Sprintf("the TLS-inspecting proxy issuing certificates as %s", issuerName(chain[0])))
+		result.Message = fmt.Sprintf("TLS to %s is intercepted: the certificate was issued by %s, not Amazon", host, issuerName(chain[0]))
+		result.Fix = "A TLS-inspecting proxy or firewall terminates connections to AWS. Ask its owners to exempt *.amazonaws.com from inspection"
+	}
+	if os.Getenv("NODE_EXTRA_CA_CERTS") == "" {
+		result.Fix += ". Until then Claude Code only trusts the interceptor if NODE_EXTRA_CA_CERTS points to its root CA certificate (PEM)"
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package probes
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInspectionVendorTable(t *testing.T) {
+	seen := map[string]bool{}
+	for _, v := range inspectionVendors {
+		if v.Vendor == "" || v.Guidance == "" {
+			t.Errorf("vendor %q has no name or guidance", v.Vendor)
+		}
+		if seen[v.Vendor] {
+			t.Errorf("vendor %q listed twice", v.Vendor)
+		}
+		seen[v.Vendor] = true
+		if len(v.issuer)+len(v.subject)+len(v.headers) == 0 {
+			t.Errorf("vendor %q has no certificate or header fingerprint", v.Vendor)
+		}
+	}
+}
+
+func TestMatchInspectionVendor(t *testing.T) {
+	cert := func(issuer, subject pkix.Name) *x509.Certificate {
+		return &x509.Certificate{Issuer: issuer, Subject: subject}
+	}
+	host := pkix.Name{CommonName: "bedrock-runtime.us-east-1.amazonaws.com"}
+	tests := []struct {
+		name     string
+		chain    []*x509.Certificate
+		header   http.Header
+		vendor   string
+		evidence string
+	}{
+		{
+			name:     "issuer of the leaf",
+			chain:    []*x509.Certificate{cert(pkix.Name{CommonName: "Zscaler Intermediate Root CA (zscaler.net)", Organization: []string{"Zscaler Inc."}}, host)},
+			vendor:   "Zscaler",
+			evidence: "issuer CN=Zscaler Intermediate Root CA (zscaler.net),O=Zscaler Inc.",
+		},
+		{
+			name: "issuer higher up the chain",
+			chain: []*x509.Certificate{
+				cert(pkix.Name{CommonName: "Corp Forward Trust"}, host),
+				cert(pkix.Name{CommonName: "caadmin.netskope.com"}, pkix.Name{CommonName: "Corp Forward Trust"}),
+			},
+			vendor:   "Netskope",
+			evidence: "issuer CN=caadmin.netskope.com",
+		},
+		{
+			name:     "response header",
+			chain:    []*x509.Certificate{cert(pkix.Name{CommonName: "Corp Root"}, host)},
+			header:   http.Header{"Via": {"1.1 proxy.corp (Forcepoint)"}},
+			vendor:   "Forcepoint",
+			evidence: "header Via",
+		},
+		{
+			name:  "unknown interceptor",
+			chain: []*x509.Certificate{cert(pkix.Name{CommonName: "Corp Root"}, host)},
+		},
+		{name: "no certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, evidence := matchInspectionVendor(tt.chain, tt.header)
+			vendor := ""
+			if v != nil {
+				vendor = v.Vendor
+			}
+			if vendor != tt.vendor || evidence != tt.evidence {
+				t.Errorf("matchInspectionVendor = %q, %q; want %q, %q", vendor, evidence, tt.vendor, tt.evidence)
+			}
+		})
+	}
+}
+
+func TestAWSIssued(t *testing.T) {
+	amazon := &x509.Certificate{Issuer: pkix.Name{CommonName: "Amazon RSA 2048 M02", Organization: []string{"Amazon"}}}
+	corp := &x509.Certificate{Issuer: pkix.Name{CommonName: "Corp Root", Organization: []string{"Example Corp"}}}
+	if !awsIssued([]*x509.Certificate{amazon, corp}) {
+		t.Error("awsIssued(Amazon leaf) = false")
+	}
+	if awsIssued([]*x509.Certificate{corp, amazon}) || awsIssued(nil) {
+		t.Error("awsIssued judges by anything but the leaf")
+	}
+}
+
+func TestTLSInspectionResult(t *testing.T) {
+	for _, name := range []string{"https_proxy", "HTTPS_PROXY", "http_proxy", "HTTP_PROXY", "all_proxy", "ALL_PROXY", "NODE_EXTRA_CA_CERTS"} {
+		t.Setenv(name, "")
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+		fix    string
+	}{
+		{
+			name: "unknown interceptor",
+			want: "TLS to 127.0.0.1 is intercepted: the certificate was issued by O=Acme Co, not Amazon",
+			fix:  "exempt *.amazonaws.com from inspection. Until then Claude Code only trusts the interceptor if NODE_EXTRA_CA_CERTS",
+		},
+		{
+			name:   "vendor from a header",
+			header: http.Header{"X-Zscaler-Transaction": {"abc"}},
+			want:   "Zscaler is intercepting TLS to 127.0.0.1 (header X-Zscaler-Transaction)",
+			fix:    "Ask your Zscaler administrators",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			// The test server's certificate is self-signed by "Acme Co",
+			// which is what an interceptor's looks like.
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+			}))
+			defer srv.Close()
+
+			got := tlsInspectionResult(env, srv.URL)
+			if got.Status != "warn" || got.Code != codeTLSInspected || got.Message != tt.want {
+				t.Errorf("status, code, message = %s, %s, %q; want warn, %s, %q", got.Status, got.Code, got.Message, codeTLSInspected, tt.want)
+			}
+			if !strings.Contains(got.Fix, tt.fix) {
+				t.Errorf("fix %q does not contain %q", got.Fix, tt.fix)
+			}
+			if env.foundInterceptor() == "" {
+				t.Error("interceptor not recorded for later checks")
+			}
+		})
+	}
+}
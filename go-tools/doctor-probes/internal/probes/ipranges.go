@@ -0,0 +1,235 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxIPRangeSample caps --ip-ranges-sample.
+const MaxIPRangeSample = 20
+
+// resolvedEndpoint is an endpoint and the addresses its DNS check resolved.
+type resolvedEndpoint struct {
+	Name  string
+	Host  string
+	Addrs []string
+}
+
+// regionPrefixes returns the AMAZON and BEDROCK prefixes published for
+// region, the ones a firewall allow list for Bedrock there needs.
+func regionPrefixes(ranges []awsIPRange, region string) []awsIPRange {
+	var prefixes []awsIPRange
+	for _, r := range ranges {
+		if r.Region == region && (r.Service == "AMAZON" || r.Service == "BEDROCK") {
+			prefixes = append(prefixes, r)
+		}
+	}
+	return prefixes
+}
+
+// writePrefixList writes the prefixes, one per line, to
+// ~/.bcce/firewall-prefixes-<region>.txt for the firewall team.
+func writePrefixList(region string, prefixes []awsIPRange) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(home, ".bcce", "firewall-prefixes-"+region+".txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# AWS AMAZON and BEDROCK prefixes for %s, from %s\n", region, awsIPRangesURL)
+	for _, p := range prefixes {
+		fmt.Fprintln(&b, p.Prefix)
+	}
+	return path, os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// prefixSample is the TCP/443 outcome for a few of the region's prefixes.
+type prefixSample struct {
+	Answered []netip.Prefix // connected, or refused by a host on AWS
+	Dropped  []netip.Prefix // timed out: a firewall discards the traffic
+}
+
+// samplePrefixes dials port 443 on the first host of n IPv4 prefixes spread
+// across prefixes, in parallel. Many AWS addresses have nothing listening,
+// so a refusal counts as reaching AWS; only a timeout suggests a firewall.
+func samplePrefixes(env Env, prefixes []awsIPRange, n int) prefixSample {
+	var v4 []netip.Prefix
+	for _, p := range prefixes {
+		if p.Prefix.Addr().Is4() {
+			v4 = append(v4, p.Prefix)
+		}
+	}
+	if n > len(v4) {
+		n = len(v4)
+	}
+	picked := make([]netip.Prefix, n)
+	for i := range picked {
+		picked[i] = v4[i*len(v4)/n]
+	}
+
+	answered := make([]bool, n)
+	var wg sync.WaitGroup
+	for i, p := range picked {
+		wg.Add(1)
+		go func(i int, p netip.Prefix) {
+			defer wg.Done()
+			addr := netip.AddrPortFrom(p.Masked().Addr().Next(), 443).String()
+			start := time.Now()
+			var conn net.Conn
+			err := env.Engine.do(context.Background(), addr, func() (err error) {
+				conn, err = net.DialTimeout("tcp", addr, 3*time.Second)
+				return err
+			})
+			logProbe("ip_range_sample", addr, start, err, "prefix", p.String())
+			if err == nil {
+				conn.Close()
+			}
+			var netErr net.Error
+			answered[i] = err == nil || !(errors.As(err, &netErr) && netErr.Timeout())
+		}(i, p)
+	}
+	wg.Wait()
+
+	var s prefixSample
+	for i, p := range picked {
+		if answered[i] {
+			s.Answered = append(s.Answered, p)
+		} else {
+			s.Dropped = append(s.Dropped, p)
+		}
+	}
+	return s
+}
+
+// ipRangesResult checks the addresses the DNS checks resolved against the
+// AMAZON and BEDROCK prefixes AWS publishes for region. A public address
+// outside every AWS range means DNS is being redirected; one in another
+// region's range is suspicious. Private addresses are VPC endpoints and are
+// not compared. With sample > 0 it also dials a few of the region's prefixes
+// directly, to tell whether egress is allowed by prefix or only to the
+// addresses Bedrock resolves to today, which breaks when AWS rotates them.
+func ipRangesResult(env Env, region string, endpoints []resolvedEndpoint, sample int) CheckResult {
+	result := CheckResult{ID: "network.ip_ranges", Name: "AWS IP Ranges"}
+	ranges, err := awsIPRanges(env)
+	if err != nil {
+		result.Status, result.Code = "warn", codeIPRangesUnavailable
+		result.Message = fmt.Sprintf("Could not load the AWS IP ranges: %v", err)
+		result.Fix = fmt.Sprintf("Download %s on a machine with internet access and pass it with --ip-ranges-file", awsIPRangesURL)
+		return result
+	}
+	prefixes := regionPrefixes(ranges, region)
+	if len(prefixes) == 0 {
+		result.Status, result.Code = "warn", codeIPRangesUnavailable
+		result.Message = fmt.Sprintf("AWS publishes no AMAZON or BEDROCK prefixes for %s", region)
+		result.Fix = "Check the region name; an --ip-ranges-file may be outdated"
+		return result
+	}
+
+	var inRegion, private []string
+	var otherRegion, outside []string
+	for _, e := range endpoints {
+		for _, a := range e.Addrs {
+			ip, err := netip.ParseAddr(a)
+			if err != nil {
+				continue
+			}
+			ip = ip.Unmap()
+			label := fmt.Sprintf("%s (%s)", ip, e.Host)
+			if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				private = append(private, label)
+				continue
+			}
+			if r, ok := awsRangeFor(prefixes, ip); ok {
+				inRegion = append(inRegion, fmt.Sprintf("%s in %s", label, r.Prefix))
+				continue
+			}
+			if r, ok := awsRangeFor(ranges, ip); ok {
+				otherRegion = append(otherRegion, fmt.Sprintf("%s in %s", label, r))
+				continue
+			}
+			outside = append(outside, label)
+		}
+	}
+	result.Metrics = map[string]float64{
+		"region_prefixes": float64(len(prefixes)),
+		"in_region":       float64(len(inRegion)),
+		"other_region":    float64(len(otherRegion)),
+		"outside_aws":     float64(len(outside)),
+		"private":         float64(len(private)),
+	}
+
+	var parts []string
+	if len(inRegion) > 0 {
+		parts = append(parts, fmt.Sprintf("in %s's published ranges: %s", region, strings.Join(inRegion, ", ")))
+	}
+	if len(private) > 0 {
+		parts = append(parts, fmt.Sprintf("private (VPC endpoint), not compared: %s", strings.Join(private, ", ")))
+	}
+	if len(otherRegion) > 0 {
+		parts = append(parts, fmt.Sprintf("in another region's range: %s", strings.Join(otherRegion, ", ")))
+	}
+	if len(outside) > 0 {
+		parts = append(parts, fmt.Sprintf("outside every AWS range: %s", strings.Join(outside, ", ")))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no resolved addresses to compare")
+	}
+	result.Message = "Resolved addresses " + strings.Join(parts, "; ")
+
+	var s prefixSample
+	if sample > 0 {
+		s = samplePrefixes(env, prefixes, sample)
+		result.Metrics["sample_answered"] = float64(len(s.Answered))
+		result.Metrics["sample_dropped"] = float64(len(s.Dropped))
+		switch {
+		case len(s.Dropped) == 0:
+			result.Message += fmt.Sprintf(". All %d sampled %s prefixes answer on 443, so egress is allowed by prefix or not restricted", len(s.Answered), region)
+		case len(s.Answered) == 0:
+			result.Message += fmt.Sprintf(". None of %d sampled %s prefixes answer on 443, so egress is allowed by hostname or only to specific addresses", len(s.Dropped), region)
+		default:
+			result.Message += fmt.Sprintf(". %d of %d sampled %s prefixes answer on 443; dropped: %s", len(s.Answered), len(s.Answered)+len(s.Dropped), region, joinPrefixes(s.Dropped))
+		}
+	}
+
+	switch {
+	case len(outside) > 0:
+		result.Status, result.Code = "fail", codeIPRangeMismatch
+		result.Fix = "Public addresses outside AWS's ranges mean a resolver or proxy is redirecting the Bedrock hosts (DNS hijacking). See DNS Compare, and ask the network team which resolver answers for *.amazonaws.com"
+	case len(otherRegion) > 0:
+		result.Status, result.Code = "warn", codeIPRangeMismatch
+		result.Fix = fmt.Sprintf("The Bedrock hosts resolve outside %s; check for a DNS override or an endpoint pinned to another region", region)
+	case len(s.Dropped) > 0:
+		result.Status, result.Code = "warn", codeIPRangeMismatch
+		result.Fix = "Egress allowed by address breaks when AWS rotates Bedrock's addresses; allow the hostnames, or the region's full prefix list"
+	default:
+		result.Status = "pass"
+		return result
+	}
+	if path, err := writePrefixList(region, prefixes); err == nil {
+		result.Fix += fmt.Sprintf(". The %d prefixes to allow for %s are in %s", len(prefixes), region, path)
+	} else {
+		debugLog.Debug("could not write prefix list", "error", err.Error())
+		result.Fix += fmt.Sprintf(". %s lists the %d AMAZON and BEDROCK prefixes for %s", awsIPRangesURL, len(prefixes), region)
+	}
+	return result
+}
+
+// joinPrefixes formats prefixes for a message.
+func joinPrefixes(prefixes []netip.Prefix) string {
+	s := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,182 @@
+package probes
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testIPRangesJSON = `{
+  "prefixes": [
+    {"ip_prefix": "3.5.0.0/19", "region": "us-east-1", "service": "AMAZON"},
+    {"ip_prefix": "18.97.0.0/16", "region": "us-west-2", "service": "AMAZON"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f18::/33", "region": "us-east-1", "service": "EC2"}
+  ]
+}`
+
+func TestLoadAWSIPRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string // --ip-ranges-file content; empty reads the cache
+		cache string // ~/.bcce/ip-ranges.json content, fresh
+		count int
+		err   string
+	}{
+		{name: "file", file: testIPRangesJSON, count: 3},
+		{name: "fresh cache", cache: testIPRangesJSON, count: 3},
+		{name: "file that is not JSON", file: "<html>", err: "ip-ranges.json: invalid character"},
+		{name: "file without prefixes", file: `{"prefixes":[]}`, err: "ip-ranges.json lists no prefixes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			env := NewEnv(RunSettings{})
+			if tt.file != "" {
+				env.IPRangesFile = filepath.Join(t.TempDir(), "ip-ranges.json")
+				if err := os.WriteFile(env.IPRangesFile, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.cache != "" {
+				if err := os.MkdirAll(filepath.Join(home, ".bcce"), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(home, ".bcce", "ip-ranges.json"), []byte(tt.cache), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ranges, err := loadAWSIPRanges(env)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || len(ranges) != tt.count {
+				t.Errorf("loaded %d ranges, %v; want %d", len(ranges), err, tt.count)
+			}
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		env := NewEnv(RunSettings{IPRangesFile: filepath.Join(t.TempDir(), "missing.json")})
+		if _, err := loadAWSIPRanges(env); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("err = %v, want not exist", err)
+		}
+	})
+}
+
+func TestIPRangesResult(t *testing.T) {
+	ranges := []awsIPRange{
+		// Sampling one prefix dials the first: loopback, which refuses.
+		{Prefix: netip.MustParsePrefix("127.0.0.0/24"), Region: "us-east-1", Service: "BEDROCK"},
+		{Prefix: netip.MustParsePrefix("3.5.0.0/19"), Region: "us-east-1", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("18.97.0.0/16"), Region: "us-west-2", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("2600:1f18::/33"), Region: "us-east-1", Service: "EC2"},
+	}
+	tests := []struct {
+		name       string
+		region     string
+		addrs      []string
+		sample     int
+		loadErr    error
+		status     string
+		code       string
+		contains   []string
+		prefixList bool // the fix names the prefix file
+	}{
+		{
+			name:     "in region",
+			region:   "us-east-1",
+			addrs:    []string{"3.5.1.10"},
+			status:   "pass",
+			contains: []string{"Resolved addresses in us-east-1's published ranges: 3.5.1.10 (bedrock-runtime.us-east-1.amazonaws.com) in 3.5.0.0/19"},
+		},
+		{
+			name:     "VPC endpoint",
+			region:   "us-east-1",
+			addrs:    []string{"10.0.1.5"},
+			status:   "pass",
+			contains: []string{"private (VPC endpoint), not compared: 10.0.1.5"},
+		},
+		{
+			name:       "another region",
+			region:     "us-east-1",
+			addrs:      []string{"18.97.4.4"},
+			status:     "warn",
+			code:       codeIPRangeMismatch,
+			contains:   []string{"in another region's range: 18.97.4.4 (bedrock-runtime.us-east-1.amazonaws.com) in AMAZON us-west-2 18.97.0.0/16", "resolve outside us-east-1"},
+			prefixList: true,
+		},
+		{
+			name:       "outside AWS",
+			region:     "us-east-1",
+			addrs:      []string{"3.5.1.10", "203.0.113.7"},
+			status:     "fail",
+			code:       codeIPRangeMismatch,
+			contains:   []string{"outside every AWS range: 203.0.113.7", "DNS hijacking"},
+			prefixList: true,
+		},
+		{
+			name:     "sampled prefixes answer",
+			region:   "us-east-1",
+			addrs:    []string{"3.5.1.10"},
+			sample:   1,
+			status:   "pass",
+			contains: []string{"All 1 sampled us-east-1 prefixes answer on 443"},
+		},
+		{
+			name:     "unknown region",
+			region:   "mars-north-1",
+			status:   "warn",
+			code:     codeIPRangesUnavailable,
+			contains: []string{"AWS publishes no AMAZON or BEDROCK prefixes for mars-north-1"},
+		},
+		{
+			name:     "ranges unavailable",
+			region:   "us-east-1",
+			loadErr:  errors.New("dial tcp: i/o timeout"),
+			status:   "warn",
+			code:     codeIPRangesUnavailable,
+			contains: []string{"Could not load the AWS IP ranges: dial tcp: i/o timeout", "pass it with --ip-ranges-file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if tt.loadErr != nil {
+				withAWSIPRanges(t, nil, tt.loadErr)
+			} else {
+				withAWSIPRanges(t, ranges, nil)
+			}
+			endpoints := []resolvedEndpoint{{Name: "Bedrock Runtime", Host: "bedrock-runtime.us-east-1.amazonaws.com", Addrs: tt.addrs}}
+
+			r := ipRangesResult(env, tt.region, endpoints, tt.sample)
+			if r.ID != "network.ip_ranges" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			path := filepath.Join(home, ".bcce", "firewall-prefixes-us-east-1.txt")
+			data, err := os.ReadFile(path)
+			if tt.prefixList != (err == nil) || tt.prefixList != strings.Contains(r.Fix, path) {
+				t.Errorf("prefix list %s: %v, fix %q; want one %v", path, err, r.Fix, tt.prefixList)
+			}
+			if tt.prefixList && string(data) != "# AWS AMAZON and BEDROCK prefixes for us-east-1, from "+awsIPRangesURL+"\n127.0.0.0/24\n3.5.0.0/19\n" {
+				t.Errorf("prefix list = %q", data)
+			}
+		})
+	}
+}
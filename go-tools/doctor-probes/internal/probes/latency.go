@@ -0,0 +1,87 @@
+package probes
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sort"
+	"time"
+)
+
+// Number of samples taken per endpoint; the median of each phase is reported.
+const latencySamples = 3
+
+type latencyBreakdown struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+}
+
+func (l latencyBreakdown) String() string {
+	return fmt.Sprintf("dns %s, connect %s, tls %s, ttfb %s",
+		formatMs(l.DNS), formatMs(l.Connect), formatMs(l.TLS), formatMs(l.TTFB))
+}
+
+// metrics returns the breakdown as raw milliseconds for JSON consumers.
+func (l latencyBreakdown) metrics() map[string]float64 {
+	return map[string]float64{
+		"dns_ms":     DurationMs(l.DNS),
+		"connect_ms": DurationMs(l.Connect),
+		"tls_ms":     DurationMs(l.TLS),
+		"ttfb_ms":    DurationMs(l.TTFB),
+	}
+}
+
+// newLatencyTrace returns a ClientTrace that fills in b as the request
+// progresses. start must be the time the request was issued; it moves to
+// when the transport takes the request, after any wait in the probe engine.
+func newLatencyTrace(b *latencyBreakdown, start time.Time) *httptrace.ClientTrace {
+	var dnsStart, connectStart, tlsStart time.Time
+	return &httptrace.ClientTrace{
+		GetConn:  func(string) { start = time.Now() },
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { b.DNS = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			b.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			b.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() { b.TTFB = time.Since(start) },
+	}
+}
+
+func medianLatency(samples []latencyBreakdown) latencyBreakdown {
+	pick := func(get func(latencyBreakdown) time.Duration) time.Duration {
+		values := make([]time.Duration, len(samples))
+		for i, s := range samples {
+			values[i] = get(s)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		return values[len(values)/2]
+	}
+	return latencyBreakdown{
+		DNS:     pick(func(l latencyBreakdown) time.Duration { return l.DNS }),
+		Connect: pick(func(l latencyBreakdown) time.Duration { return l.Connect }),
+		TLS:     pick(func(l latencyBreakdown) time.Duration { return l.TLS }),
+		TTFB:    pick(func(l latencyBreakdown) time.Duration { return l.TTFB }),
+	}
+}
+
+func DurationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.0fms", DurationMs(d))
+}
+
+// FormatSeconds formats a millisecond duration as seconds, e.g. "12.3s".
+func FormatSeconds(ms float64) string {
+	return fmt.Sprintf("%.1fs", ms/1000)
+}
@@ -0,0 +1,60 @@
+package probes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedianLatency(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	tests := []struct {
+		name    string
+		samples []latencyBreakdown
+		want    latencyBreakdown
+	}{
+		{
+			name:    "one sample",
+			samples: []latencyBreakdown{{DNS: ms(1), Connect: ms(2), TLS: ms(3), TTFB: ms(4)}},
+			want:    latencyBreakdown{DNS: ms(1), Connect: ms(2), TLS: ms(3), TTFB: ms(4)},
+		},
+		{
+			name: "each phase on its own",
+			samples: []latencyBreakdown{
+				{DNS: ms(30), Connect: ms(1), TLS: ms(20), TTFB: ms(300)},
+				{DNS: ms(10), Connect: ms(3), TLS: ms(10), TTFB: ms(100)},
+				{DNS: ms(20), Connect: ms(2), TLS: ms(30), TTFB: ms(200)},
+			},
+			want: latencyBreakdown{DNS: ms(20), Connect: ms(2), TLS: ms(20), TTFB: ms(200)},
+		},
+		{
+			name: "an outlier does not move the median",
+			samples: []latencyBreakdown{
+				{TTFB: ms(90)},
+				{TTFB: ms(5000)},
+				{TTFB: ms(80)},
+			},
+			want: latencyBreakdown{TTFB: ms(90)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := medianLatency(tt.samples); got != tt.want {
+				t.Errorf("medianLatency = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyBreakdownReport(t *testing.T) {
+	l := latencyBreakdown{DNS: 1500 * time.Microsecond, Connect: 12 * time.Millisecond, TLS: 40 * time.Millisecond, TTFB: 180 * time.Millisecond}
+	if got, want := l.String(), "dns 2ms, connect 12ms, tls 40ms, ttfb 180ms"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	want := map[string]float64{"dns_ms": 1.5, "connect_ms": 12, "tls_ms": 40, "ttfb_ms": 180}
+	got := l.metrics()
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("metrics[%s] = %v, want %v", key, got[key], value)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Oldest releases known to work with Bedrock as configured by BCCE.
+const (
+	minClaudeVersion = "1.0.0"
+	minNodeMajor     = 18
+)
+
+const claudeInstallCommand = "npm i -g @anthropic-ai/claude-code"
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// parseVersion extracts the first "major.minor.patch" in s.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return v, false
+	}
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return v, true
+}
+
+func versionLess(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// runLocal runs a local command with a short timeout and returns its
+// trimmed combined output.
+func runLocal(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	logProbe("exec", name, start, err, "args", args)
+	return strings.TrimSpace(string(out)), err
+}
+
+// npmGlobalBin returns the npm global prefix and the directory npm links
+// global binaries into.
+func npmGlobalBin() (prefix, bin string, err error) {
+	prefix, err = runLocal("npm", "prefix", "-g")
+	if err != nil {
+		return "", "", err
+	}
+	if runtime.GOOS == "windows" {
+		return prefix, prefix, nil
+	}
+	return prefix, filepath.Join(prefix, "bin"), nil
+}
+
+// localResults checks the Claude Code CLI and its Node runtime, and the AWS
+// CLI the setup runbooks use.
+func localResults() []CheckResult {
+	var results []CheckResult
+	results = append(results, nodeVersionResult())
+
+	prefix, npmBin, npmErr := npmGlobalBin()
+	results = append(results, claudeCLIResults(npmBin)...)
+	if npmErr == nil {
+		results = append(results, npmPrefixResult(prefix))
+	}
+	return append(results, awsCLIResult())
+}
+
+func nodeVersionResult() CheckResult {
+	out, err := runLocal("node", "--version")
+	if err != nil {
+		return CheckResult{
+			ID:      "local.node_version",
+			Code:    codeNodeMissing,
+			Name:    "Node.js",
+			Status:  "fail",
+			Message: fmt.Sprintf("node not found or not runnable: %v", err),
+			Fix:     fmt.Sprintf("Install Node.js %d or newer from https://nodejs.org", minNodeMajor),
+		}
+	}
+
+	v, ok := parseVersion(out)
+	if !ok {
+		return CheckResult{
+			ID:      "local.node_version",
+			Name:    "Node.js",
+			Status:  "warn",
+			Message: fmt.Sprintf("Could not parse node --version output %q", out),
+		}
+	}
+	if v[0] < minNodeMajor {
+		return CheckResult{
+			ID:      "local.node_version",
+			Code:    codeNodeUnsupported,
+			Name:    "Node.js",
+			Status:  "fail",
+			Message: fmt.Sprintf("Node.js %s is not supported by Claude Code (need %d+)", out, minNodeMajor),
+			Fix:     fmt.Sprintf("Upgrade to Node.js %d or newer (e.g. nvm install --lts)", minNodeMajor),
+		}
+	}
+	return CheckResult{
+		ID:      "local.node_version",
+		Name:    "Node.js",
+		Status:  "pass",
+		Message: fmt.Sprintf("Node.js %s", out),
+	}
+}
+
+// claudeCLIResults locates the claude binary and checks its version.
+func claudeCLIResults(npmBin string) []CheckResult {
+	path, err := exec.LookPath("claude")
+	if err != nil {
+		if npmBin != "" {
+			candidate := filepath.Join(npmBin, "claude")
+			if runtime.GOOS == "windows" {
+				candidate += ".cmd"
+			}
+			if _, statErr := os.Stat(candidate); statErr == nil {
+				return []CheckResult{{
+					ID:      "local.claude_cli",
+					Code:    codeClaudeNotOnPath,
+					Name:    "Claude Code CLI",
+					Status:  "fail",
+					Message: fmt.Sprintf("claude is installed at %s but %s is not on PATH", candidate, npmBin),
+					Fix:     fmt.Sprintf("Add %s to PATH in your shell profile", npmBin),
+					FixCommand: &FixCommand{
+						Bash:       fmt.Sprintf(`case ":$PATH:" in *":%[1]s:"*) ;; *) export PATH="%[1]s:$PATH" ;; esac`, npmBin),
+						PowerShell: fmt.Sprintf(`if ($env:Path -notlike "*%[1]s*") { $env:Path = "%[1]s;$env:Path" }`, npmBin),
+					},
+				}}
+			}
+		}
+		return []CheckResult{{
+			ID:      "local.claude_cli",
+			Code:    codeClaudeMissing,
+			Name:    "Claude Code CLI",
+			Status:  "fail",
+			Message: "claude not found on PATH or in the npm global prefix",
+			Fix:     claudeInstallCommand,
+			FixCommand: &FixCommand{
+				Bash:       "command -v claude >/dev/null || " + claudeInstallCommand,
+				PowerShell: "if (-not (Get-Command claude -ErrorAction SilentlyContinue)) { " + claudeInstallCommand + " }",
+			},
+		}}
+	}
+
+	out, err := runLocal(path, "--version")
+	if err != nil {
+		return []CheckResult{{
+			ID:      "local.claude_cli",
+			Code:    codeClaudeBroken,
+			Name:    "Claude Code CLI",
+			Status:  "fail",
+			Message: fmt.Sprintf("%s --version failed: %v %s", path, err, out),
+			Fix:     "Reinstall Claude Code: " + claudeInstallCommand,
+		}}
+	}
+
+	results := []CheckResult{{
+		ID:      "local.claude_cli",
+		Name:    "Claude Code CLI",
+		Status:  "pass",
+		Message: fmt.Sprintf("Found %s", path),
+	}}
+
+	v, ok := parseVersion(out)
+	minimum, _ := parseVersion(minClaudeVersion)
+	switch {
+	case !ok:
+		results = append(results, CheckResult{
+			ID:      "local.claude_version",
+			Name:    "Claude Code Version",
+			Status:  "warn",
+			Message: fmt.Sprintf("Could not parse claude --version output %q", out),
+		})
+	case versionLess(v, minimum):
+		results = append(results, CheckResult{
+			ID:      "local.claude_version",
+			Code:    codeClaudeOutdated,
+			Name:    "Claude Code Version",
+			Status:  "fail",
+			Message: fmt.Sprintf("%s is older than the minimum supported %s", out, minClaudeVersion),
+			Fix:     "Update Claude Code: " + claudeInstallCommand + "@latest",
+			FixCommand: &FixCommand{
+				Bash:       claudeInstallCommand + "@latest",
+				PowerShell: claudeInstallCommand + "@latest",
+			},
+		})
+	default:
+		results = append(results, CheckResult{
+			ID:      "local.claude_version",
+			Name:    "Claude Code Version",
+			Status:  "pass",
+			Message: out,
+		})
+	}
+	return results
+}
+
+// npmPrefixResult checks that global installs (and Claude Code's auto-updater)
+// can write to the npm prefix.
+func npmPrefixResult(prefix string) CheckResult {
+	dir := prefix
+	if runtime.GOOS != "windows" {
+		dir = filepath.Join(prefix, "lib", "node_modules")
+	}
+
+	f, err := os.CreateTemp(dir, ".bcce-doctor-*")
+	if err != nil {
+		if !os.IsPermission(err) {
+			return CheckResult{
+				ID:      "local.npm_prefix",
+				Name:    "npm Global Prefix",
+				Status:  "warn",
+				Message: fmt.Sprintf("Could not check %s: %v", dir, err),
+			}
+		}
+		return CheckResult{
+			ID:      "local.npm_prefix",
+			Code:    codeNPMPrefixEACCES,
+			Name:    "npm Global Prefix",
+			Status:  "warn",
+			Message: fmt.Sprintf("%s is not writable (EACCES); global installs and Claude Code auto-updates will fail", dir),
+			Fix:     fmt.Sprintf(`sudo chown -R "$(whoami)" "%s"/{lib/node_modules,bin,share}, or move the prefix: npm config set prefix ~/.npm-global`, prefix),
+		}
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return CheckResult{
+		ID:      "local.npm_prefix",
+		Name:    "npm Global Prefix",
+		Status:  "pass",
+		Message: fmt.Sprintf("%s is writable", dir),
+	}
+}
@@ -0,0 +1,222 @@
+package probes
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeCommand writes an executable shell script named name into dir.
+func fakeCommand(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fakePath replaces PATH with a fresh directory holding the given scripts.
+func fakePath(t *testing.T, scripts map[string]string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	dir := t.TempDir()
+	for name, script := range scripts {
+		fakeCommand(t, dir, name, script)
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want [3]int
+		ok   bool
+	}{
+		{"v20.11.1", [3]int{20, 11, 1}, true},
+		{"1.0.58 (Claude Code)", [3]int{1, 0, 58}, true},
+		{"node 18.2", [3]int{}, false},
+		{"", [3]int{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseVersion(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseVersion(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b [3]int
+		want bool
+	}{
+		{[3]int{0, 2, 9}, [3]int{1, 0, 0}, true},
+		{[3]int{1, 0, 0}, [3]int{1, 0, 0}, false},
+		{[3]int{1, 10, 0}, [3]int{1, 9, 9}, false},
+		{[3]int{1, 0, 1}, [3]int{1, 0, 2}, true},
+	}
+	for _, tt := range tests {
+		if got := versionLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("versionLess(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNodeVersionResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string // empty means no node on PATH
+		status   string
+		code     string
+		contains string
+	}{
+		{name: "supported", script: "echo v20.11.1", status: "pass", contains: "Node.js v20.11.1"},
+		{name: "too old", script: "echo v16.20.0", status: "fail", code: codeNodeUnsupported, contains: "need 18+"},
+		{name: "unparseable", script: "echo weird", status: "warn", contains: `"weird"`},
+		{name: "missing", status: "fail", code: codeNodeMissing, contains: "node not found"},
+		{name: "crashes", script: "exit 3", status: "fail", code: codeNodeMissing, contains: "exit status 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripts := map[string]string{}
+			if tt.script != "" {
+				scripts["node"] = tt.script
+			}
+			fakePath(t, scripts)
+			got := nodeVersionResult()
+			if got.Status != tt.status || got.Code != tt.code || !strings.Contains(got.Message, tt.contains) {
+				t.Errorf("got %s %s %q; want %s %s containing %q", got.Status, got.Code, got.Message, tt.status, tt.code, tt.contains)
+			}
+		})
+	}
+}
+
+func TestClaudeCLIResults(t *testing.T) {
+	type want struct{ id, status, code string }
+	tests := []struct {
+		name      string
+		onPath    string // claude script on PATH
+		inNPMBin  bool   // a claude binary in the npm bin directory
+		want      []want
+		fixBash   string
+		messageIn string
+	}{
+		{
+			name:    "missing",
+			want:    []want{{"local.claude_cli", "fail", codeClaudeMissing}},
+			fixBash: "command -v claude >/dev/null || npm i -g @anthropic-ai/claude-code",
+		},
+		{
+			name:      "installed but not on PATH",
+			inNPMBin:  true,
+			want:      []want{{"local.claude_cli", "fail", codeClaudeNotOnPath}},
+			fixBash:   `case ":$PATH:" in`,
+			messageIn: "is not on PATH",
+		},
+		{
+			name:   "broken",
+			onPath: "echo 'Cannot find module' >&2; exit 1",
+			want:   []want{{"local.claude_cli", "fail", codeClaudeBroken}},
+		},
+		{
+			name:   "outdated",
+			onPath: "echo '0.2.9 (Claude Code)'",
+			want: []want{
+				{"local.claude_cli", "pass", ""},
+				{"local.claude_version", "fail", codeClaudeOutdated},
+			},
+			fixBash: "npm i -g @anthropic-ai/claude-code@latest",
+		},
+		{
+			name:   "current",
+			onPath: "echo '1.0.58 (Claude Code)'",
+			want: []want{
+				{"local.claude_cli", "pass", ""},
+				{"local.claude_version", "pass", ""},
+			},
+		},
+		{
+			name:   "unparseable version",
+			onPath: "echo dev",
+			want: []want{
+				{"local.claude_cli", "pass", ""},
+				{"local.claude_version", "warn", ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripts := map[string]string{}
+			if tt.onPath != "" {
+				scripts["claude"] = tt.onPath
+			}
+			fakePath(t, scripts)
+			npmBin := t.TempDir()
+			if tt.inNPMBin {
+				fakeCommand(t, npmBin, "claude", "echo 1.0.58")
+			}
+
+			got := claudeCLIResults(npmBin)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if got[i].ID != w.id || got[i].Status != w.status || got[i].Code != w.code {
+					t.Errorf("result %d = %s %s %s, want %s %s %s", i, got[i].ID, got[i].Status, got[i].Code, w.id, w.status, w.code)
+				}
+			}
+			last := got[len(got)-1]
+			if tt.fixBash != "" && (last.FixCommand == nil || !strings.Contains(last.FixCommand.Bash, tt.fixBash)) {
+				t.Errorf("fix command = %+v, want bash containing %q", last.FixCommand, tt.fixBash)
+			}
+			if !strings.Contains(last.Message, tt.messageIn) {
+				t.Errorf("message %q does not contain %q", last.Message, tt.messageIn)
+			}
+		})
+	}
+}
+
+func TestNPMPrefixResult(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits do not apply")
+	}
+	tests := []struct {
+		name   string
+		mode   os.FileMode // of lib/node_modules; 0 means missing
+		status string
+		code   string
+	}{
+		{name: "writable", mode: 0o755, status: "pass"},
+		{name: "read-only", mode: 0o555, status: "warn", code: codeNPMPrefixEACCES},
+		{name: "missing", status: "warn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mode == 0o555 && os.Geteuid() == 0 {
+				t.Skip("root can write to read-only directories")
+			}
+			prefix := t.TempDir()
+			if tt.mode != 0 {
+				dir := filepath.Join(prefix, "lib", "node_modules")
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				os.Chmod(dir, tt.mode)
+				t.Cleanup(func() { os.Chmod(dir, 0o755) })
+			}
+			got := npmPrefixResult(prefix)
+			if got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %s %s (%s), want %s %s", got.Status, got.Code, got.Message, tt.status, tt.code)
+			}
+			if entries, _ := os.ReadDir(filepath.Join(prefix, "lib", "node_modules")); len(entries) != 0 {
+				t.Errorf("probe left %d files behind", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package probes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// middleboxProbeModel is the model ID in the probe's path when
+// ANTHROPIC_MODEL is not set. The request is unsigned, so AWS rejects it
+// before looking at the model.
+const middleboxProbeModel = "anthropic.claude-sonnet-4-20250514-v1:0"
+
+// middleboxHeaders are response headers AWS does not send on a runtime
+// error, so their presence means a proxy, WAF, or cache on the path added
+// them.
+var middleboxHeaders = []string{
+	"Via", "X-Bluecoat-Via", "X-Cache", "X-Cache-Lookup", "X-Squid-Error",
+	"Proxy-Agent", "Proxy-Connection", "Set-Cookie",
+}
+
+const (
+	// middleboxBodyLimit caps the body read; AWS's answer is under 100
+	// bytes, a block page a few KB.
+	middleboxBodyLimit = 64 * 1024
+	// middleboxLogBytes is how much of the body goes to the debug log.
+	middleboxLogBytes = 2048
+)
+
+// matchMiddleboxVendor names the product behind rewritten responses from
+// the fingerprint table's header patterns, then its body patterns.
+func matchMiddleboxVendor(header http.Header, body []byte) (*inspectionVendor, string) {
+	if v, evidence := matchInspectionVendor(nil, header); v != nil {
+		return v, evidence
+	}
+	for i := range inspectionVendors {
+		v := &inspectionVendors[i]
+		for _, re := range v.body {
+			if m := re.Find(body); m != nil {
+				return v, fmt.Sprintf("body %q", m)
+			}
+		}
+	}
+	return nil, ""
+}
+
+// loggedBody is body as it goes to the debug log: truncated, valid UTF-8,
+// and with secrets redacted.
+func loggedBody(body []byte) string {
+	s := string(body)
+	if len(body) > middleboxLogBytes {
+		s = string(body[:middleboxLogBytes]) + fmt.Sprintf("...(%d bytes)", len(body))
+	}
+	return RedactSecrets(strings.ToValidUTF8(s, "?"))
+}
+
+// middleboxResult sends an unsigned POST to the runtime on Claude Code's
+// route, which AWS always answers with a small JSON error, and checks the
+// answer arrived intact. An HTML or non-JSON body, a missing AWS error shape,
+// a body shorter than its Content-Length, or no AWS request ID fails: Claude
+// Code would report it as an unparseable Bedrock error. Headers only a proxy
+// adds warn.
+func middleboxResult(env Env, region string, fips bool) CheckResult {
+	result := CheckResult{ID: "network.middlebox", Name: "Middlebox Interference"}
+	model := configuredModel()
+	if model == "" {
+		model = middleboxProbeModel
+	}
+	target := runtimeURL(env, region, fips) + "/model/" + url.PathEscape(model) + "/invoke"
+
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(authzProbeBody))
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	// Without transparent decompression the Content-Length can be checked.
+	req.Header.Set("Accept-Encoding", "identity")
+	client, err := unverifiedRouteClient(env, req.URL.Hostname())
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		logProbe("middlebox", target, start, err)
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("Could not send the unsigned POST %s: %v", target, err)
+		result.Fix = "See HTTPS Connectivity"
+		return result
+	}
+	defer resp.Body.Close()
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, middleboxBodyLimit))
+	logProbe("middlebox", target, start, readErr,
+		"status", resp.StatusCode,
+		"content_type", resp.Header.Get("Content-Type"),
+		"content_length", resp.ContentLength,
+		"body_bytes", len(body),
+		"body", loggedBody(body))
+	result.attachDiagnostics(env, httpDiagnostics(resp))
+
+	var rewritten, injected []string
+	contentType := resp.Header.Get("Content-Type")
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case strings.Contains(strings.ToLower(contentType), "html") || bytes.HasPrefix(trimmed, []byte("<")):
+		rewritten = append(rewritten, fmt.Sprintf("an HTML body (Content-Type %q)", contentType))
+	case !bytes.HasPrefix(trimmed, []byte("{")) || !json.Valid(trimmed):
+		rewritten = append(rewritten, fmt.Sprintf("a body that is not a JSON object (Content-Type %q)", contentType))
+	default:
+		if code, message := parseAWSError(resp.Header, body); code == "" || message == "" {
+			rewritten = append(rewritten, "a JSON body without AWS's error type and message")
+		}
+	}
+	switch {
+	case errors.Is(readErr, io.ErrUnexpectedEOF):
+		rewritten = append(rewritten, fmt.Sprintf("%d body bytes where Content-Length says %d", len(body), resp.ContentLength))
+	case readErr != nil:
+		rewritten = append(rewritten, fmt.Sprintf("a body cut off after %d bytes (%v)", len(body), readErr))
+	}
+	if !isAWSResponse(resp) {
+		rewritten = append(rewritten, "no AWS request ID, so the answer did not come from AWS")
+	}
+	for _, name := range middleboxHeaders {
+		if len(resp.Header.Values(name)) > 0 {
+			injected = append(injected, name)
+		}
+	}
+
+	vendor, evidence := matchMiddleboxVendor(resp.Header, body)
+	suspect := ""
+	switch {
+	case vendor != nil:
+		suspect = fmt.Sprintf("; suspected %s (%s)", vendor.Vendor, evidence)
+	case env.foundInterceptor() != "":
+		suspect = fmt.Sprintf("; suspected %s", env.foundInterceptor())
+	}
+	if len(injected) > 0 {
+		suspect = fmt.Sprintf("; added headers: %s", strings.Join(injected, ", ")) + suspect
+	}
+
+	switch {
+	case len(rewritten) > 0:
+		result.Status, result.Code = "fail", codeMiddleboxRewrite
+		result.Message = fmt.Sprintf("Something on the path replaced or rewrote AWS's answer to the unsigned POST %s: HTTP %d with %s%s",
+			target, resp.StatusCode, strings.Join(rewritten, ", "), suspect)
+		result.Fix = "A WAF, gateway, or proxy rewrites responses from AWS, which Claude Code reports as unparseable Bedrock errors. Ask its owners to pass the Bedrock hosts through unmodified" + env.Host.proxyHint()
+	case vendor != nil || len(injected) > 0:
+		result.Status, result.Code = "warn", codeMiddleboxHeaders
+		result.Message = fmt.Sprintf("AWS's answer to the unsigned POST %s arrived intact (HTTP %d), but a proxy on the path modified its headers%s",
+			target, resp.StatusCode, suspect)
+		result.Fix = "The error body is intact today, but a proxy that modifies responses can also buffer or rewrite them. Ask its owners to pass the Bedrock hosts through unmodified" + env.Host.proxyHint()
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("AWS's answer to the unsigned POST %s arrived intact: HTTP %d with a %d-byte JSON error and no proxy headers", target, resp.StatusCode, len(body))
+	}
+	if vendor != nil {
+		result.Fix = vendor.Guidance
+	}
+	return result
+}
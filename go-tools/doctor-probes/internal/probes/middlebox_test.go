@@ -0,0 +1,166 @@
+package probes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestMatchMiddleboxVendor(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		vendor string
+	}{
+		{name: "nothing", header: http.Header{"X-Amzn-Requestid": {"r-1"}}, body: `{"message":"Missing Authentication Token"}`},
+		{name: "header", header: http.Header{"Via": {"1.1 squid-proxy (squid/5.7)"}}, vendor: "Squid"},
+		{name: "F5 block page", header: http.Header{}, body: "<html><body>The requested URL was rejected. Please consult with your administrator.</body></html>", vendor: "F5 BIG-IP"},
+		{name: "vendor named in the body", header: http.Header{}, body: "<title>Blocked by Zscaler</title>", vendor: "Zscaler"},
+	}
+	for _, tt := range tests {
+		v, evidence := matchMiddleboxVendor(tt.header, []byte(tt.body))
+		switch {
+		case tt.vendor == "" && v != nil:
+			t.Errorf("%s: matched %s (%s), want nothing", tt.name, v.Vendor, evidence)
+		case tt.vendor != "" && (v == nil || v.Vendor != tt.vendor || evidence == ""):
+			t.Errorf("%s: matched %v (%s), want %s", tt.name, v, evidence, tt.vendor)
+		}
+	}
+}
+
+func TestLoggedBody(t *testing.T) {
+	if got := loggedBody([]byte("sent Bearer abc.def \xff")); got != "sent Bearer "+Redacted+" ?" {
+		t.Errorf("loggedBody = %q, want the header redacted and invalid UTF-8 replaced", got)
+	}
+	long := strings.Repeat("x", middleboxLogBytes+10)
+	if got := loggedBody([]byte(long)); !strings.HasSuffix(got, fmt.Sprintf("...(%d bytes)", len(long))) || len(got) > middleboxLogBytes+20 {
+		t.Errorf("loggedBody of %d bytes = %d bytes, want it truncated", len(long), len(got))
+	}
+}
+
+func TestMiddleboxResult(t *testing.T) {
+	awsAnswer := func(w http.ResponseWriter) {
+		awsHeaders(w)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-amzn-ErrorType", "UnrecognizedClientException:http://internal.amazon.com/coral/com.amazon.coral.service/")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"Missing Authentication Token"}`))
+	}
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc // nil means a refused connection
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "intact",
+			handler:  func(w http.ResponseWriter, r *http.Request) { awsAnswer(w) },
+			status:   "pass",
+			contains: []string{"arrived intact: HTTP 403 with a 42-byte JSON error and no proxy headers"},
+		},
+		{
+			name: "proxy headers",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Via", "1.1 squid-proxy (squid/5.7)")
+				w.Header().Set("X-Cache", "MISS from squid-proxy")
+				awsAnswer(w)
+			},
+			status:   "warn",
+			code:     codeMiddleboxHeaders,
+			contains: []string{"arrived intact (HTTP 403), but a proxy on the path modified its headers; added headers: Via, X-Cache; suspected Squid", "exempt *.amazonaws.com from ssl_bump"},
+		},
+		{
+			name: "WAF block page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Set-Cookie", "TS01a2b3c4=0123; Path=/")
+				w.Write([]byte("<html><head><title>Request Rejected</title></head><body>The requested URL was rejected. Please consult with your administrator.</body></html>"))
+			},
+			status:   "fail",
+			code:     codeMiddleboxRewrite,
+			contains: []string{"HTTP 200 with an HTML body", "no AWS request ID", "added headers: Set-Cookie; suspected F5 BIG-IP", "exempt the Bedrock hosts from its security policy"},
+		},
+		{
+			name: "JSON without the AWS error shape",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				awsHeaders(w)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":"blocked by policy"}`))
+			},
+			status:   "fail",
+			code:     codeMiddleboxRewrite,
+			contains: []string{"a JSON body without AWS's error type and message", "Ask its owners to pass the Bedrock hosts through unmodified"},
+		},
+		{
+			name: "plain text",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				awsHeaders(w)
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("Forbidden"))
+			},
+			status:   "fail",
+			code:     codeMiddleboxRewrite,
+			contains: []string{`a body that is not a JSON object (Content-Type "text/plain")`},
+		},
+		{
+			name: "truncated body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				awsHeaders(w)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "200")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"message":"Missing`))
+				w.(http.Flusher).Flush()
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			},
+			status:   "fail",
+			code:     codeMiddleboxRewrite,
+			contains: []string{"19 body bytes where Content-Length says 200"},
+		},
+		{
+			name:     "refused",
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"Could not send the unsigned POST https://127.0.0.1:1/model/", "See HTTPS Connectivity"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, nil)
+			probestest.Unsetenv(t, "ANTHROPIC_MODEL")
+			endpoint := "https://127.0.0.1:1"
+			if tt.handler != nil {
+				srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Method != http.MethodPost || r.URL.Path != "/model/"+middleboxProbeModel+"/invoke" || r.Header.Get("Authorization") != "" {
+						t.Errorf("got %s %s with Authorization %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+					}
+					tt.handler(w, r)
+				}))
+				t.Cleanup(srv.Close)
+				endpoint = srv.URL
+			}
+			withEndpoint(t, &env, endpoint)
+
+			r := middleboxResult(env, "us-east-1", false)
+			if r.ID != "network.middlebox" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// modelGrant is the access status of one foundation model for this account.
+type modelGrant struct {
+	ModelID string
+	Name    string
+	Granted bool
+	Reason  string // why not granted, e.g. "not authorized"
+}
+
+// runState is what one run of the checks found that later checks reuse.
+// Model access is looked up once per run and shared by every check that
+// needs it.
+type runState struct {
+	mu          sync.Mutex
+	modelAccess map[string][]modelGrant
+	interceptor string // the TLS-inspecting product on the path, if any
+}
+
+// Reset forgets what the previous run found, model access and the TLS
+// interceptor, and starts a fresh request budget, between watch/serve
+// iterations.
+func (env Env) Reset() {
+	if env.run != nil {
+		env.run.mu.Lock()
+		env.run.modelAccess = nil
+		env.run.interceptor = ""
+		env.run.mu.Unlock()
+	}
+	env.Engine.reset()
+}
+
+// modelAccess returns the grant status of every active Anthropic model
+// offered in region.
+func (env Env) modelAccess(region string, models []bedrocktypes.FoundationModelSummary) ([]modelGrant, error) {
+	if env.run != nil {
+		env.run.mu.Lock()
+		grants, ok := env.run.modelAccess[region]
+		env.run.mu.Unlock()
+		if ok {
+			return grants, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := env.Bedrock(cfg)
+
+	var grants []modelGrant
+	for _, m := range models {
+		if m.ModelLifecycle != nil && m.ModelLifecycle.Status != bedrocktypes.FoundationModelLifecycleStatusActive {
+			continue
+		}
+		modelID := aws.ToString(m.ModelId)
+		out, err := client.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+			ModelId: aws.String(modelID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bedrock API call failed: %w", err)
+		}
+		grants = append(grants, grantFromAvailability(modelID, aws.ToString(m.ModelName), out))
+	}
+
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ModelID < grants[j].ModelID })
+	if env.run != nil {
+		env.run.mu.Lock()
+		if env.run.modelAccess == nil {
+			env.run.modelAccess = make(map[string][]modelGrant)
+		}
+		env.run.modelAccess[region] = grants
+		env.run.mu.Unlock()
+	}
+	return grants, nil
+}
+
+func grantFromAvailability(modelID, name string, out *bedrock.GetFoundationModelAvailabilityOutput) modelGrant {
+	g := modelGrant{ModelID: modelID, Name: name, Granted: true}
+	switch {
+	case out.AuthorizationStatus == bedrocktypes.AuthorizationStatusNotAuthorized:
+		g.Granted, g.Reason = false, "not authorized"
+	case out.EntitlementAvailability == bedrocktypes.EntitlementAvailabilityNotAvailable:
+		g.Granted, g.Reason = false, "access not requested"
+	case out.AgreementAvailability != nil && out.AgreementAvailability.Status != bedrocktypes.AgreementStatusAvailable:
+		g.Granted, g.Reason = false, "agreement "+strings.ToLower(string(out.AgreementAvailability.Status))
+	case out.RegionAvailability == bedrocktypes.RegionAvailabilityNotAvailable:
+		g.Granted, g.Reason = false, "not offered in this region"
+	}
+	return g
+}
+
+// baseModelID strips the cross-region prefix and ARN parts from a model or
+// inference profile identifier, e.g. "us.anthropic.claude-x" -> "anthropic.claude-x".
+func baseModelID(model string) string {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if i := strings.Index(model, "anthropic."); i > 0 {
+		model = model[i:]
+	}
+	return model
+}
+
+// modelAccessConsoleURL is the Bedrock console page where access is requested.
+func modelAccessConsoleURL(region string) string {
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/bedrock/home?region=%s#/modelaccess", region, region)
+}
+
+func modelAccessResult(env Env, region string, models []bedrocktypes.FoundationModelSummary) CheckResult {
+	result := CheckResult{ID: "bedrock.model_access", Name: "Bedrock Model Access"}
+
+	grants, err := env.modelAccess(region, models)
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Could not determine model access: %v", err)
+		result.Fix = "Add bedrock:GetFoundationModelAvailability permission to your IAM role/user, or check model access in " + modelAccessConsoleURL(region)
+		result.setAWSError(err, "bedrock:GetFoundationModelAvailability")
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return result
+	}
+
+	var granted, missing []string
+	configured := baseModelID(configuredModel())
+	configuredMissing := false
+	for _, g := range grants {
+		if g.Granted {
+			granted = append(granted, g.Name)
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("%s (%s)", g.Name, g.Reason))
+		if g.ModelID == configured {
+			configuredMissing = true
+		}
+	}
+
+	if len(missing) == 0 {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Access granted to %d Claude models: %s", len(granted), strings.Join(granted, ", "))
+		return result
+	}
+
+	result.Status, result.Code = "warn", codeModelNotGranted
+	if configuredMissing {
+		result.Status = "fail"
+	}
+	result.Message = fmt.Sprintf("Not granted: %s", strings.Join(missing, ", "))
+	if len(granted) > 0 {
+		result.Message += fmt.Sprintf("; granted: %s", strings.Join(granted, ", "))
+	}
+	var names []string
+	for _, g := range grants {
+		if !g.Granted {
+			names = append(names, g.Name)
+		}
+	}
+	result.Fix = fmt.Sprintf("Request access to %s at %s", strings.Join(names, ", "), modelAccessConsoleURL(region))
+	return result
+}
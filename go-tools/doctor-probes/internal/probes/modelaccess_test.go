@@ -0,0 +1,162 @@
+package probes
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestBaseModelID(t *testing.T) {
+	tests := map[string]string{
+		"anthropic.claude-3-5-haiku-20241022-v1:0":                                                  "anthropic.claude-3-5-haiku-20241022-v1:0",
+		"us.anthropic.claude-3-5-haiku-20241022-v1:0":                                               "anthropic.claude-3-5-haiku-20241022-v1:0",
+		"arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-3-5-haiku-20241022-v1:0":      "anthropic.claude-3-5-haiku-20241022-v1:0",
+		"arn:aws:bedrock:us-east-1:1:inference-profile/eu.anthropic.claude-3-5-haiku-20241022-v1:0": "anthropic.claude-3-5-haiku-20241022-v1:0",
+		"": "",
+	}
+	for in, want := range tests {
+		if got := baseModelID(in); got != want {
+			t.Errorf("baseModelID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGrantFromAvailability(t *testing.T) {
+	available := &bedrocktypes.AgreementAvailability{Status: bedrocktypes.AgreementStatusAvailable}
+	tests := []struct {
+		name    string
+		out     bedrock.GetFoundationModelAvailabilityOutput
+		granted bool
+		reason  string
+	}{
+		{
+			name: "granted",
+			out: bedrock.GetFoundationModelAvailabilityOutput{
+				AuthorizationStatus: "AUTHORIZED", EntitlementAvailability: "AVAILABLE", RegionAvailability: "AVAILABLE", AgreementAvailability: available,
+			},
+			granted: true,
+		},
+		{name: "not authorized", out: bedrock.GetFoundationModelAvailabilityOutput{AuthorizationStatus: "NOT_AUTHORIZED"}, reason: "not authorized"},
+		{name: "not requested", out: bedrock.GetFoundationModelAvailabilityOutput{EntitlementAvailability: "NOT_AVAILABLE"}, reason: "access not requested"},
+		{
+			name:   "agreement pending",
+			out:    bedrock.GetFoundationModelAvailabilityOutput{AgreementAvailability: &bedrocktypes.AgreementAvailability{Status: "PENDING"}},
+			reason: "agreement pending",
+		},
+		{name: "other region", out: bedrock.GetFoundationModelAvailabilityOutput{RegionAvailability: "NOT_AVAILABLE"}, reason: "not offered in this region"},
+	}
+	for _, tt := range tests {
+		g := grantFromAvailability("anthropic.claude-x", "Claude X", &tt.out)
+		if g.Granted != tt.granted || g.Reason != tt.reason || g.ModelID != "anthropic.claude-x" || g.Name != "Claude X" {
+			t.Errorf("%s: grant = %+v, want granted %v reason %q", tt.name, g, tt.granted, tt.reason)
+		}
+	}
+}
+
+func TestModelAccessResult(t *testing.T) {
+	models := []bedrocktypes.FoundationModelSummary{
+		{ModelId: aws.String("anthropic.claude-sonnet"), ModelName: aws.String("Claude Sonnet")},
+		{ModelId: aws.String("anthropic.claude-haiku"), ModelName: aws.String("Claude Haiku")},
+		{
+			ModelId: aws.String("anthropic.claude-v2"), ModelName: aws.String("Claude 2"),
+			ModelLifecycle: &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusLegacy},
+		},
+	}
+	const granted = `{"authorizationStatus":"AUTHORIZED","entitlementAvailability":"AVAILABLE","regionAvailability":"AVAILABLE","agreementAvailability":{"status":"AVAILABLE"}}`
+	const notRequested = `{"authorizationStatus":"AUTHORIZED","entitlementAvailability":"NOT_AVAILABLE","regionAvailability":"AVAILABLE","agreementAvailability":{"status":"AVAILABLE"}}`
+	tests := []struct {
+		name         string
+		availability map[string]string // body per model ID; missing denies the call
+		model        string
+		status       string
+		code         string
+		contains     []string
+	}{
+		{
+			name:         "all granted",
+			availability: map[string]string{"anthropic.claude-sonnet": granted, "anthropic.claude-haiku": granted},
+			status:       "pass",
+			contains:     []string{"Access granted to 2 Claude models: Claude Haiku, Claude Sonnet"},
+		},
+		{
+			name:         "another model not requested",
+			availability: map[string]string{"anthropic.claude-sonnet": granted, "anthropic.claude-haiku": notRequested},
+			model:        "us.anthropic.claude-sonnet",
+			status:       "warn",
+			code:         codeModelNotGranted,
+			contains:     []string{"Not granted: Claude Haiku (access not requested); granted: Claude Sonnet", "Request access to Claude Haiku at https://us-east-1.console.aws.amazon.com/bedrock/home?region=us-east-1#/modelaccess"},
+		},
+		{
+			name:         "configured model not requested",
+			availability: map[string]string{"anthropic.claude-sonnet": granted, "anthropic.claude-haiku": notRequested},
+			model:        "anthropic.claude-haiku",
+			status:       "fail",
+			code:         codeModelNotGranted,
+		},
+		{
+			name:     "permission denied",
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Could not determine model access", "bedrock:GetFoundationModelAvailability"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			var mu sync.Mutex
+			var calls []string
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				id, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/foundation-model-availability/"))
+				mu.Lock()
+				calls = append(calls, id)
+				mu.Unlock()
+				body, ok := tt.availability[id]
+				if !ok {
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(body))
+			})
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			got := modelAccessResult(env, "us-east-1", models)
+			if got.ID != "bedrock.model_access" || got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", got.ID, got.Status, got.Code, got.Message, tt.status, tt.code)
+			}
+			text := got.Message + " " + got.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+
+			callCount := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, id := range calls {
+					if id == "anthropic.claude-v2" {
+						t.Error("looked up a legacy model")
+					}
+				}
+				return len(calls)
+			}
+			before := callCount()
+			if tt.status == "pass" {
+				// A second check in the same run reuses the lookups.
+				modelAccessResult(env, "us-east-1", models)
+				if n := callCount() - before; n != 0 {
+					t.Errorf("second lookup made %d more calls, want 0", n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// modelIDShape is the kind of identifier a configured model value is.
+type modelIDShape int
+
+const (
+	shapeUnknown            modelIDShape = iota
+	shapeFoundationModel                 // anthropic.claude-..., or its foundation-model ARN
+	shapeSystemProfile                   // us.anthropic.claude-..., or its inference-profile ARN
+	shapeApplicationProfile              // arn:...:application-inference-profile/<id>
+)
+
+func (s modelIDShape) String() string {
+	switch s {
+	case shapeFoundationModel:
+		return "foundation model ID"
+	case shapeSystemProfile:
+		return "cross-region inference profile ID"
+	case shapeApplicationProfile:
+		return "application inference profile ARN"
+	}
+	return "unrecognized model identifier"
+}
+
+// classifyModelID returns the shape of a configured model value and the ID
+// it is looked up by: the model or profile ID, with ARNs reduced to it except
+// for application profiles, whose ARN is the ID.
+func classifyModelID(value string) (modelIDShape, string) {
+	if a, err := arn.Parse(value); err == nil {
+		kind, id, _ := strings.Cut(a.Resource, "/")
+		switch kind {
+		case "foundation-model":
+			return shapeFoundationModel, id
+		case "inference-profile":
+			return shapeSystemProfile, id
+		case "application-inference-profile":
+			return shapeApplicationProfile, value
+		}
+		return shapeUnknown, value
+	}
+	for _, prefix := range crossRegionPrefixes {
+		if strings.HasPrefix(value, prefix+"anthropic.") {
+			return shapeSystemProfile, value
+		}
+	}
+	if strings.HasPrefix(value, "anthropic.") {
+		return shapeFoundationModel, value
+	}
+	return shapeUnknown, value
+}
+
+// regionModelCatalog is what a region offers for invoking Claude models.
+type regionModelCatalog struct {
+	Region      string
+	OnDemand    map[string]bool     // foundation model IDs invocable by their own ID
+	Offered     map[string]bool     // foundation model IDs offered at all
+	Profiles    map[string][]string // foundation model ID to the system profile IDs that route to it
+	ProfileIDs  map[string]bool     // system profile IDs offered in the region
+	AppProfiles map[string]bool     // application profile ARNs; nil when they could not be listed
+	Account     string              // the caller's account, whose profiles AppProfiles lists
+}
+
+// preferredProfile picks the profile to suggest for region: the one of the
+// region's own geography, which keeps data in it, then global, then any.
+func preferredProfile(region string, profiles []string) string {
+	if len(profiles) == 0 {
+		return ""
+	}
+	geo := regionGeography(region)
+	if geo == "ap" {
+		geo = "apac"
+	}
+	sorted := slices.Clone(profiles)
+	sort.Strings(sorted)
+	for _, prefix := range []string{geo + ".", "global."} {
+		for _, p := range sorted {
+			if strings.HasPrefix(p, prefix) {
+				return p
+			}
+		}
+	}
+	return sorted[0]
+}
+
+// modelIDMatch is the verdict on one configured model value.
+type modelIDMatch struct {
+	Shape      modelIDShape
+	OK         bool
+	Offered    bool   // false when the region has neither the model nor a profile for it
+	Suggestion string // the value to use instead, when there is one
+	Reason     string
+}
+
+// matchModelID checks that value has the shape region requires for its
+// model: a bare model ID only for models with on-demand throughput, a
+// system profile ID only for profiles offered in the region.
+func matchModelID(value string, cat regionModelCatalog) modelIDMatch {
+	shape, id := classifyModelID(value)
+	m := modelIDMatch{Shape: shape, Offered: true}
+	switch shape {
+	case shapeFoundationModel:
+		profile := preferredProfile(cat.Region, cat.Profiles[id])
+		switch {
+		case cat.OnDemand[id]:
+			m.OK = true
+		case profile != "":
+			m.Suggestion = profile
+			m.Reason = fmt.Sprintf("%s does not support on-demand throughput in %s and must be invoked through a cross-region inference profile", id, cat.Region)
+		default:
+			m.Offered = false
+			m.Reason = fmt.Sprintf("%s is not offered in %s, on demand or through a profile", id, cat.Region)
+		}
+
+	case shapeSystemProfile:
+		base := baseModelID(id)
+		profile := preferredProfile(cat.Region, cat.Profiles[base])
+		switch {
+		case cat.ProfileIDs[id]:
+			m.OK = true
+		case profile != "":
+			m.Suggestion = profile
+			m.Reason = fmt.Sprintf("inference profile %s is not offered in %s; the profile for this model there is %s", id, cat.Region, profile)
+		case cat.OnDemand[base]:
+			m.Suggestion = base
+			m.Reason = fmt.Sprintf("%s has no cross-region inference profile in %s; invoke it on demand by its model ID", base, cat.Region)
+		default:
+			m.Offered = false
+			m.Reason = fmt.Sprintf("neither inference profile %s nor model %s is offered in %s", id, base, cat.Region)
+		}
+
+	case shapeApplicationProfile:
+		a, _ := arn.Parse(id)
+		switch {
+		case a.Region != cat.Region:
+			m.Reason = fmt.Sprintf("the application inference profile is in %s, not %s; Claude Code invokes it in AWS_REGION", a.Region, cat.Region)
+		case cat.AppProfiles == nil || cat.AppProfiles[id]:
+			m.OK = true
+		case cat.Account != "" && a.AccountID != cat.Account:
+			// Another account's profile is not listed here; Cross-Account
+			// Inference Profile checks access to it.
+			m.OK = true
+		default:
+			m.Reason = fmt.Sprintf("no application inference profile with this ARN exists in %s for this account", cat.Region)
+		}
+
+	default:
+		m.Reason = "not a Bedrock model ID, inference profile ID, or ARN"
+	}
+	return m
+}
+
+// loadModelCatalog lists the region's Anthropic models and its system and
+// application inference profiles.
+func loadModelCatalog(env Env, region string, models []bedrocktypes.FoundationModelSummary) (regionModelCatalog, error) {
+	cat := regionModelCatalog{
+		Region:     region,
+		OnDemand:   map[string]bool{},
+		Offered:    map[string]bool{},
+		Profiles:   map[string][]string{},
+		ProfileIDs: map[string]bool{},
+	}
+	for _, m := range models {
+		id := aws.ToString(m.ModelId)
+		cat.Offered[id] = true
+		cat.OnDemand[id] = slices.Contains(m.InferenceTypesSupported, bedrocktypes.InferenceTypeOnDemand)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return cat, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := env.Bedrock(cfg)
+
+	paginator := bedrock.NewListInferenceProfilesPaginator(client, &bedrock.ListInferenceProfilesInput{
+		TypeEquals: bedrocktypes.InferenceProfileTypeSystemDefined,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return cat, err
+		}
+		for _, p := range page.InferenceProfileSummaries {
+			id := aws.ToString(p.InferenceProfileId)
+			if p.Status != bedrocktypes.InferenceProfileStatusActive || !strings.Contains(id, "anthropic.") {
+				continue
+			}
+			cat.ProfileIDs[id] = true
+			base := baseModelID(id)
+			if !slices.Contains(cat.Profiles[base], id) {
+				cat.Profiles[base] = append(cat.Profiles[base], id)
+			}
+		}
+	}
+
+	// Application profiles are checked only if they can be listed.
+	apps := map[string]bool{}
+	paginator = bedrock.NewListInferenceProfilesPaginator(client, &bedrock.ListInferenceProfilesInput{
+		TypeEquals: bedrocktypes.InferenceProfileTypeApplication,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			apps = nil
+			break
+		}
+		for _, p := range page.InferenceProfileSummaries {
+			apps[aws.ToString(p.InferenceProfileArn)] = true
+		}
+	}
+	cat.AppProfiles = apps
+	if identity, err := CallerIdentity(ctx, env, cfg); err == nil {
+		cat.Account = aws.ToString(identity.Account)
+	}
+	return cat, nil
+}
+
+// modelIDShapeResults checks that each configured model value has the shape
+// the region requires for that model, the cause of "on-demand throughput
+// isn't supported" errors, and names the exact value to use instead. Values
+// for models the region does not offer are left to Model Lifecycle and Model
+// Region Availability. It returns nothing when no model is configured.
+func modelIDShapeResults(env Env, region string) []CheckResult {
+	type configured struct{ Variable, Value string }
+	var values []configured
+	for _, name := range configuredModelVars {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			values = append(values, configured{name, v})
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	result := CheckResult{ID: "bedrock.model_id_shape", Name: "Model ID Format"}
+
+	models, err := checkBedrockAccess(context.Background(), env, region)
+	var cat regionModelCatalog
+	if err == nil {
+		cat, err = loadModelCatalog(env, region, models)
+	}
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Cannot list the models and inference profiles of %s: %v", region, err)
+		result.Fix = "Add bedrock:ListFoundationModels and bedrock:ListInferenceProfiles permissions to your IAM role/user"
+		result.setAWSError(err, "bedrock:ListInferenceProfiles")
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return []CheckResult{result}
+	}
+
+	var parts, problems, exports, ps []string
+	for _, c := range values {
+		m := matchModelID(c.Value, cat)
+		switch {
+		case m.OK:
+			parts = append(parts, fmt.Sprintf("%s (%s) is what %s requires", c.Variable, m.Shape, region))
+		case !m.Offered:
+			parts = append(parts, fmt.Sprintf("%s: %s", c.Variable, m.Reason))
+		default:
+			problems = append(problems, fmt.Sprintf("%s=%s (%s): %s", c.Variable, c.Value, m.Shape, m.Reason))
+			if m.Suggestion != "" {
+				exports = append(exports, fmt.Sprintf("export %s=%s", c.Variable, m.Suggestion))
+				ps = append(ps, fmt.Sprintf(`$env:%s = "%s"`, c.Variable, m.Suggestion))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		result.Status = "pass"
+		result.Message = strings.Join(parts, "; ")
+		return []CheckResult{result}
+	}
+
+	result.Status, result.Code = "fail", codeModelIDMismatch
+	result.Message = strings.Join(append(problems, parts...), "; ")
+	if len(exports) > 0 {
+		result.Fix = "Set the value Bedrock accepts in this region (in your shell or the env block of Claude Code's settings): " + strings.Join(exports, "; ")
+		result.FixCommand = &FixCommand{Bash: strings.Join(exports, "\n"), PowerShell: strings.Join(ps, "\n")}
+	} else {
+		result.Fix = "Use the application inference profile ARN from the region in AWS_REGION (aws bedrock list-inference-profiles --type-equals APPLICATION)"
+	}
+	return []CheckResult{result}
+}
@@ -0,0 +1,214 @@
+package probes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+const (
+	sonnet45 = "anthropic.claude-sonnet-4-5-20250929-v1:0"
+	haiku3   = "anthropic.claude-3-haiku-20240307-v1:0"
+)
+
+func TestClassifyModelID(t *testing.T) {
+	appProfile := "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/a1b2c3d4e5f6"
+	tests := []struct {
+		value string
+		shape modelIDShape
+		id    string
+	}{
+		{sonnet45, shapeFoundationModel, sonnet45},
+		{"us." + sonnet45, shapeSystemProfile, "us." + sonnet45},
+		{"global." + sonnet45, shapeSystemProfile, "global." + sonnet45},
+		{"us-gov." + sonnet45, shapeSystemProfile, "us-gov." + sonnet45},
+		{"arn:aws:bedrock:us-east-1::foundation-model/" + sonnet45, shapeFoundationModel, sonnet45},
+		{"arn:aws:bedrock:us-east-1:111122223333:inference-profile/eu." + sonnet45, shapeSystemProfile, "eu." + sonnet45},
+		{appProfile, shapeApplicationProfile, appProfile},
+		{"arn:aws:bedrock:us-east-1:111122223333:provisioned-model/abc", shapeUnknown, "arn:aws:bedrock:us-east-1:111122223333:provisioned-model/abc"},
+		{"claude-sonnet-4-5", shapeUnknown, "claude-sonnet-4-5"},
+		{"sonnet", shapeUnknown, "sonnet"},
+	}
+	for _, tt := range tests {
+		shape, id := classifyModelID(tt.value)
+		if shape != tt.shape || id != tt.id {
+			t.Errorf("classifyModelID(%s) = %s, %s; want %s, %s", tt.value, shape, id, tt.shape, tt.id)
+		}
+	}
+}
+
+func TestPreferredProfile(t *testing.T) {
+	profiles := []string{"us." + sonnet45, "global." + sonnet45, "eu." + sonnet45, "apac." + sonnet45}
+	tests := []struct {
+		region   string
+		profiles []string
+		want     string
+	}{
+		{"us-west-2", profiles, "us." + sonnet45},
+		{"eu-central-1", profiles, "eu." + sonnet45},
+		{"ap-northeast-1", profiles, "apac." + sonnet45},
+		{"ca-central-1", profiles, "global." + sonnet45},
+		{"ca-central-1", []string{"us." + sonnet45, "eu." + sonnet45}, "eu." + sonnet45},
+		{"us-east-1", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := preferredProfile(tt.region, tt.profiles); got != tt.want {
+			t.Errorf("preferredProfile(%s, %v) = %q, want %q", tt.region, tt.profiles, got, tt.want)
+		}
+	}
+}
+
+func TestMatchModelID(t *testing.T) {
+	cat := regionModelCatalog{
+		Region:      "us-east-1",
+		OnDemand:    map[string]bool{haiku3: true},
+		Offered:     map[string]bool{haiku3: true, sonnet45: true},
+		Profiles:    map[string][]string{sonnet45: {"global." + sonnet45, "us." + sonnet45}},
+		ProfileIDs:  map[string]bool{"us." + sonnet45: true, "global." + sonnet45: true},
+		AppProfiles: map[string]bool{"arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/mine": true},
+		Account:     "111122223333",
+	}
+	tests := []struct {
+		name       string
+		value      string
+		ok         bool
+		offered    bool
+		suggestion string
+	}{
+		{name: "on-demand model by ID", value: haiku3, ok: true, offered: true},
+		{name: "profile-only model by ID", value: sonnet45, offered: true, suggestion: "us." + sonnet45},
+		{name: "offered profile", value: "us." + sonnet45, ok: true, offered: true},
+		{name: "profile of another geography", value: "eu." + sonnet45, offered: true, suggestion: "us." + sonnet45},
+		{name: "profile of an on-demand-only model", value: "us." + haiku3, offered: true, suggestion: haiku3},
+		{name: "model not offered", value: "anthropic.claude-opus-4-1-20250805-v1:0"},
+		{name: "profile not offered", value: "us.anthropic.claude-opus-4-1-20250805-v1:0"},
+		{name: "own application profile", value: "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/mine", ok: true, offered: true},
+		{name: "unknown application profile", value: "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/gone", offered: true},
+		{name: "another account's application profile", value: "arn:aws:bedrock:us-east-1:444455556666:application-inference-profile/shared", ok: true, offered: true},
+		{name: "application profile of another region", value: "arn:aws:bedrock:us-west-2:111122223333:application-inference-profile/mine", offered: true},
+		{name: "alias", value: "sonnet", offered: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := matchModelID(tt.value, cat)
+			if m.OK != tt.ok || m.Offered != tt.offered || m.Suggestion != tt.suggestion {
+				t.Errorf("matchModelID = ok %v, offered %v, suggestion %q; want %v, %v, %q (%s)", m.OK, m.Offered, m.Suggestion, tt.ok, tt.offered, tt.suggestion, m.Reason)
+			}
+			if !m.OK && m.Reason == "" {
+				t.Error("mismatch without a reason")
+			}
+		})
+	}
+}
+
+func TestMatchModelIDUnlistedApplicationProfiles(t *testing.T) {
+	// Without permission to list application profiles, any in the region
+	// is accepted.
+	cat := regionModelCatalog{Region: "us-east-1"}
+	if m := matchModelID("arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/any", cat); !m.OK {
+		t.Errorf("matchModelID = %+v, want OK", m)
+	}
+}
+
+func TestModelIDShapeResults(t *testing.T) {
+	const app = "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/mine"
+	tests := []struct {
+		name     string
+		env      map[string]string
+		deny     string // "models", "profiles" or "apps"
+		status   string // empty means no result
+		code     string
+		contains []string
+		fixBash  string
+	}{
+		{name: "nothing configured"},
+		{
+			name:     "right shapes",
+			env:      map[string]string{"ANTHROPIC_MODEL": "us." + sonnet45, "ANTHROPIC_SMALL_FAST_MODEL": haiku3, "ANTHROPIC_DEFAULT_OPUS_MODEL": app},
+			status:   "pass",
+			contains: []string{"ANTHROPIC_MODEL (cross-region inference profile ID) is what us-east-1 requires", "ANTHROPIC_DEFAULT_OPUS_MODEL (application inference profile ARN)"},
+		},
+		{
+			name:     "profile-only model by ID",
+			env:      map[string]string{"ANTHROPIC_MODEL": sonnet45, "ANTHROPIC_SMALL_FAST_MODEL": "anthropic.claude-opus-4-1-20250805-v1:0"},
+			status:   "fail",
+			code:     codeModelIDMismatch,
+			contains: []string{"ANTHROPIC_MODEL=" + sonnet45 + " (foundation model ID): ", "ANTHROPIC_SMALL_FAST_MODEL: "},
+			fixBash:  "export ANTHROPIC_MODEL=us." + sonnet45,
+		},
+		{
+			name:     "unknown application profile",
+			env:      map[string]string{"ANTHROPIC_MODEL": "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/gone"},
+			status:   "fail",
+			code:     codeModelIDMismatch,
+			contains: []string{"list-inference-profiles --type-equals APPLICATION"},
+		},
+		{
+			name:   "application profiles not listable",
+			env:    map[string]string{"ANTHROPIC_MODEL": "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/gone"},
+			deny:   "apps",
+			status: "pass",
+		},
+		{
+			name:     "profiles denied",
+			env:      map[string]string{"ANTHROPIC_MODEL": sonnet45},
+			deny:     "profiles",
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Cannot list the models and inference profiles of us-east-1", "bedrock:ListInferenceProfiles"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				kind := r.URL.Query().Get("type")
+				switch {
+				case r.URL.Path == "/foundation-models" && tt.deny != "models":
+					w.Write([]byte(`{"modelSummaries":[
+{"modelId":"` + haiku3 + `","inferenceTypesSupported":["ON_DEMAND"]},
+{"modelId":"` + sonnet45 + `","inferenceTypesSupported":["INFERENCE_PROFILE"]}]}`))
+				case r.URL.Path == "/inference-profiles" && kind == "SYSTEM_DEFINED" && tt.deny != "profiles":
+					w.Write([]byte(`{"inferenceProfileSummaries":[
+{"inferenceProfileId":"us.` + sonnet45 + `","status":"ACTIVE","type":"SYSTEM_DEFINED"},
+{"inferenceProfileId":"global.` + sonnet45 + `","status":"ACTIVE","type":"SYSTEM_DEFINED"},
+{"inferenceProfileId":"us.meta.llama3-70b","status":"ACTIVE","type":"SYSTEM_DEFINED"}]}`))
+				case r.URL.Path == "/inference-profiles" && kind == "APPLICATION" && tt.deny == "":
+					w.Write([]byte(`{"inferenceProfileSummaries":[{"inferenceProfileArn":"` + app + `","status":"ACTIVE","type":"APPLICATION"}]}`))
+				default:
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+				}
+			})
+			probestest.Unsetenv(t, configuredModelVars...)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got := modelIDShapeResults(NewEnv(RunSettings{}), "us-east-1")
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "bedrock.model_id_shape" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if tt.fixBash != "" && (r.FixCommand == nil || r.FixCommand.Bash != tt.fixBash) {
+				t.Errorf("fix command = %+v, want bash %q", r.FixCommand, tt.fixBash)
+			}
+		})
+	}
+}
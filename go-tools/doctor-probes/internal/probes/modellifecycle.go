@@ -0,0 +1,209 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/smithy-go"
+)
+
+// configuredModelVars are the environment variables that name a model
+// Claude Code invokes.
+var configuredModelVars = []string{
+	"ANTHROPIC_MODEL",
+	"ANTHROPIC_SMALL_FAST_MODEL",
+	"ANTHROPIC_DEFAULT_OPUS_MODEL",
+	"ANTHROPIC_DEFAULT_SONNET_MODEL",
+	"ANTHROPIC_DEFAULT_HAIKU_MODEL",
+}
+
+// Lifecycle statuses beyond Bedrock's ACTIVE and LEGACY.
+const (
+	lifecycleNotFound = "NOT_FOUND" // not offered in the region at all
+	lifecycleUnknown  = "UNKNOWN"   // an application inference profile, which hides its model
+)
+
+// ModelLifecycle is the lifecycle status of one configured model.
+type ModelLifecycle struct {
+	Variable    string `json:"variable"`              // e.g. "ANTHROPIC_MODEL"
+	ModelID     string `json:"model_id"`              // as configured
+	Status      string `json:"status"`                // ACTIVE, LEGACY, NOT_FOUND, or UNKNOWN
+	Replacement string `json:"replacement,omitempty"` // newest active model of the same family
+}
+
+var (
+	claudeTier = regexp.MustCompile(`claude-(?:[\d-]+-)?(opus|sonnet|haiku)\b`)
+	claudeDate = regexp.MustCompile(`-(20\d{6})-`)
+)
+
+// claudeFamily returns the tier (opus, sonnet, haiku) and release date of a
+// Claude model ID, e.g. "sonnet" and "20240229" for
+// anthropic.claude-3-sonnet-20240229-v1:0. Either may be empty.
+func claudeFamily(modelID string) (tier, date string) {
+	if m := claudeTier.FindStringSubmatch(modelID); m != nil {
+		tier = m[1]
+	}
+	if m := claudeDate.FindStringSubmatch(modelID); m != nil {
+		date = m[1]
+	}
+	return tier, date
+}
+
+// replacementModel returns the newest active model of the same tier as
+// modelID that was released after it, keeping modelID's cross-region
+// prefix, or "" if there is none.
+func replacementModel(modelID string, models []bedrocktypes.FoundationModelSummary) string {
+	base := baseModelID(modelID)
+	tier, date := claudeFamily(base)
+	if tier == "" {
+		return ""
+	}
+	best, bestDate := "", date
+	for _, m := range models {
+		if m.ModelLifecycle != nil && m.ModelLifecycle.Status != bedrocktypes.FoundationModelLifecycleStatusActive {
+			continue
+		}
+		id := aws.ToString(m.ModelId)
+		if t, d := claudeFamily(id); t == tier && d > bestDate {
+			best, bestDate = id, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return strings.TrimSuffix(modelID, base) + best
+}
+
+// modelLifecycleStatus returns the lifecycle status of modelID's foundation
+// model: from models (ListFoundationModels) when listed there, otherwise from
+// GetFoundationModel.
+func modelLifecycleStatus(ctx context.Context, client BedrockClient, modelID string, models []bedrocktypes.FoundationModelSummary) (string, error) {
+	base := baseModelID(modelID)
+	if !strings.HasPrefix(base, "anthropic.") {
+		return lifecycleUnknown, nil
+	}
+	for _, m := range models {
+		if aws.ToString(m.ModelId) == base && m.ModelLifecycle != nil {
+			return string(m.ModelLifecycle.Status), nil
+		}
+	}
+	out, err := client.GetFoundationModel(ctx, &bedrock.GetFoundationModelInput{ModelIdentifier: aws.String(base)})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "ValidationException" || apiErr.ErrorCode() == "ResourceNotFoundException") {
+		return lifecycleNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if out.ModelDetails == nil || out.ModelDetails.ModelLifecycle == nil {
+		return string(bedrocktypes.FoundationModelLifecycleStatusActive), nil
+	}
+	return string(out.ModelDetails.ModelLifecycle.Status), nil
+}
+
+// modelLifecycleResults reports the lifecycle status of every configured
+// model: LEGACY models warn, with the model to move to, and models that no
+// longer exist in the region fail when a newer model of the same family
+// does. It returns nothing when no model is configured.
+func modelLifecycleResults(ctx context.Context, env Env, region string) []CheckResult {
+	var lifecycles []ModelLifecycle
+	for _, name := range configuredModelVars {
+		if id := strings.TrimSpace(os.Getenv(name)); id != "" {
+			lifecycles = append(lifecycles, ModelLifecycle{Variable: name, ModelID: id})
+		}
+	}
+	if len(lifecycles) == 0 {
+		return nil
+	}
+	result := CheckResult{ID: "bedrock.model_lifecycle", Name: "Model Lifecycle"}
+
+	models, err := checkBedrockAccess(ctx, env, region)
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Cannot list foundation models: %v", err)
+		result.Fix = "Add bedrock:ListFoundationModels permission to your IAM role/user"
+		result.setAWSError(err, "bedrock:ListFoundationModels")
+		return []CheckResult{result}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return []CheckResult{result}
+	}
+	client := env.Bedrock(cfg)
+
+	var parts, legacy, retired, missing, fixes []string
+	for i := range lifecycles {
+		l := &lifecycles[i]
+		status, err := modelLifecycleStatus(ctx, client, l.ModelID, models)
+		if err != nil {
+			result.Status = "warn"
+			result.Message = fmt.Sprintf("Cannot read the lifecycle of %s: %v", l.ModelID, err)
+			result.Fix = "Add bedrock:GetFoundationModel permission to your IAM role/user"
+			result.setAWSError(err, "bedrock:GetFoundationModel")
+			result.attachDiagnostics(env, awsDiagnostics(err))
+			return []CheckResult{result}
+		}
+		l.Status = status
+		if status != string(bedrocktypes.FoundationModelLifecycleStatusActive) && status != lifecycleUnknown {
+			l.Replacement = replacementModel(l.ModelID, models)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s (%s)", l.Variable, l.ModelID, l.Status))
+
+		switch {
+		case status == string(bedrocktypes.FoundationModelLifecycleStatusLegacy):
+			legacy = append(legacy, l.Variable)
+		case status == lifecycleNotFound && l.Replacement != "":
+			retired = append(retired, l.Variable)
+		case status == lifecycleNotFound:
+			missing = append(missing, l.Variable)
+		}
+		if l.Replacement != "" {
+			fixes = append(fixes, fmt.Sprintf("export %s=%s", l.Variable, l.Replacement))
+		}
+	}
+	result.Models = lifecycles
+	result.Message = strings.Join(parts, ", ")
+
+	switch {
+	case len(retired) > 0:
+		result.Status, result.Code = "fail", codeModelRetired
+		result.Message = fmt.Sprintf("%s name models that no longer exist in %s: %s", strings.Join(retired, ", "), region, result.Message)
+	case len(legacy) > 0:
+		result.Status, result.Code = "warn", codeModelLegacy
+		result.Message = fmt.Sprintf("%s name LEGACY models, which Bedrock will remove: %s", strings.Join(legacy, ", "), result.Message)
+	case len(missing) > 0:
+		result.Status, result.Code = "warn", codeModelNotInRegion
+		result.Message = fmt.Sprintf("%s name models not offered in %s: %s", strings.Join(missing, ", "), region, result.Message)
+		result.Fix = "See Model Region Availability, or set them to a model listed in " + modelAccessConsoleURL(region)
+		return []CheckResult{result}
+	default:
+		result.Status = "pass"
+		return []CheckResult{result}
+	}
+	if len(fixes) > 0 {
+		result.Fix = "Migrate to the newest model of the same family (and update managed settings that pin the old ID): " + strings.Join(fixes, "; ")
+		result.FixCommand = &FixCommand{Bash: strings.Join(fixes, "\n")}
+		var ps []string
+		for _, l := range lifecycles {
+			if l.Replacement != "" {
+				ps = append(ps, fmt.Sprintf(`$env:%s = "%s"`, l.Variable, l.Replacement))
+			}
+		}
+		result.FixCommand.PowerShell = strings.Join(ps, "\n")
+	} else {
+		result.Fix = "Move to a current model listed in " + modelAccessConsoleURL(region)
+	}
+	return []CheckResult{result}
+}
@@ -0,0 +1,178 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestClaudeFamily(t *testing.T) {
+	tests := []struct {
+		model, tier, date string
+	}{
+		{"anthropic.claude-3-sonnet-20240229-v1:0", "sonnet", "20240229"},
+		{"us.anthropic.claude-3-5-haiku-20241022-v1:0", "haiku", "20241022"},
+		{"anthropic.claude-opus-4-20250514-v1:0", "opus", "20250514"},
+		{"anthropic.claude-instant-v1", "", ""},
+		{"amazon.titan-text-express-v1", "", ""},
+	}
+	for _, tt := range tests {
+		if tier, date := claudeFamily(tt.model); tier != tt.tier || date != tt.date {
+			t.Errorf("claudeFamily(%q) = %q, %q; want %q, %q", tt.model, tier, date, tt.tier, tt.date)
+		}
+	}
+}
+
+// lifecycleModels is a ListFoundationModels answer: the newest active
+// Sonnet, a LEGACY one, and an active Haiku.
+var lifecycleModels = []bedrocktypes.FoundationModelSummary{
+	{ModelId: aws.String("anthropic.claude-3-sonnet-20240229-v1:0"), ModelLifecycle: &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusLegacy}},
+	{ModelId: aws.String("anthropic.claude-3-5-sonnet-20241022-v2:0"), ModelLifecycle: &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusLegacy}},
+	{ModelId: aws.String("anthropic.claude-sonnet-4-20250514-v1:0"), ModelLifecycle: &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusActive}},
+	{ModelId: aws.String("anthropic.claude-3-5-haiku-20241022-v1:0"), ModelLifecycle: &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusActive}},
+}
+
+func TestReplacementModel(t *testing.T) {
+	tests := map[string]string{
+		"anthropic.claude-3-sonnet-20240229-v1:0":                                                             "anthropic.claude-sonnet-4-20250514-v1:0",
+		"us.anthropic.claude-3-sonnet-20240229-v1:0":                                                          "us.anthropic.claude-sonnet-4-20250514-v1:0",
+		"arn:aws:bedrock:us-east-1:123456789012:inference-profile/eu.anthropic.claude-3-sonnet-20240229-v1:0": "arn:aws:bedrock:us-east-1:123456789012:inference-profile/eu.anthropic.claude-sonnet-4-20250514-v1:0",
+		"anthropic.claude-sonnet-4-20250514-v1:0":                                                             "",
+		"anthropic.claude-3-haiku-20240307-v1:0":                                                              "anthropic.claude-3-5-haiku-20241022-v1:0",
+		"anthropic.claude-3-opus-20240229-v1:0":                                                               "",
+		"anthropic.claude-instant-v1":                                                                         "",
+	}
+	for model, want := range tests {
+		if got := replacementModel(model, lifecycleModels); got != want {
+			t.Errorf("replacementModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestModelLifecycleResults(t *testing.T) {
+	const (
+		legacySonnet = "anthropic.claude-3-sonnet-20240229-v1:0"
+		sonnet4      = "anthropic.claude-sonnet-4-20250514-v1:0"
+		haiku3       = "anthropic.claude-3-haiku-20240307-v1:0"
+		opus3        = "anthropic.claude-3-opus-20240229-v1:0"
+	)
+	listed := `{"modelSummaries":[
+{"modelId":"` + legacySonnet + `","modelLifecycle":{"status":"LEGACY"}},
+{"modelId":"` + sonnet4 + `","modelLifecycle":{"status":"ACTIVE"}},
+{"modelId":"anthropic.claude-3-5-haiku-20241022-v1:0","modelLifecycle":{"status":"ACTIVE"}}]}`
+	tests := []struct {
+		name     string
+		env      map[string]string
+		listDeny bool
+		getDeny  bool
+		status   string // empty means no result
+		code     string
+		contains []string
+		fixBash  string
+	}{
+		{name: "nothing configured"},
+		{
+			name:     "active and application profile",
+			env:      map[string]string{"ANTHROPIC_MODEL": "us." + sonnet4, "ANTHROPIC_SMALL_FAST_MODEL": "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc"},
+			status:   "pass",
+			contains: []string{"ANTHROPIC_MODEL=us." + sonnet4 + " (ACTIVE)", "(UNKNOWN)"},
+		},
+		{
+			name:     "legacy",
+			env:      map[string]string{"ANTHROPIC_MODEL": "us." + legacySonnet},
+			status:   "warn",
+			code:     codeModelLegacy,
+			contains: []string{"ANTHROPIC_MODEL name LEGACY models, which Bedrock will remove", "Migrate to the newest model of the same family"},
+			fixBash:  "export ANTHROPIC_MODEL=us." + sonnet4,
+		},
+		{
+			name:     "retired with a successor",
+			env:      map[string]string{"ANTHROPIC_MODEL": sonnet4, "ANTHROPIC_SMALL_FAST_MODEL": haiku3},
+			status:   "fail",
+			code:     codeModelRetired,
+			contains: []string{"ANTHROPIC_SMALL_FAST_MODEL name models that no longer exist in us-east-1", haiku3 + " (NOT_FOUND)"},
+			fixBash:  "export ANTHROPIC_SMALL_FAST_MODEL=anthropic.claude-3-5-haiku-20241022-v1:0",
+		},
+		{
+			name:     "not offered, no successor",
+			env:      map[string]string{"ANTHROPIC_DEFAULT_OPUS_MODEL": opus3},
+			status:   "warn",
+			code:     codeModelNotInRegion,
+			contains: []string{"ANTHROPIC_DEFAULT_OPUS_MODEL name models not offered in us-east-1", "See Model Region Availability"},
+		},
+		{
+			name:     "list denied",
+			env:      map[string]string{"ANTHROPIC_MODEL": sonnet4},
+			listDeny: true,
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Cannot list foundation models", "bedrock:ListFoundationModels"},
+		},
+		{
+			name:     "get denied",
+			env:      map[string]string{"ANTHROPIC_MODEL": haiku3},
+			getDeny:  true,
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Cannot read the lifecycle of " + haiku3, "bedrock:GetFoundationModel"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/foundation-models" && !tt.listDeny:
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(listed))
+				case strings.HasPrefix(r.URL.Path, "/foundation-models/") && !tt.getDeny:
+					// Only models ListFoundationModels omits are looked up.
+					id, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/foundation-models/"))
+					if id != haiku3 && id != opus3 {
+						t.Errorf("GetFoundationModel(%q) for a listed model", id)
+					}
+					probestest.AWSJSONError(w, http.StatusBadRequest, "ValidationException", "The provided model identifier is invalid.")
+				default:
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+				}
+			})
+			probestest.Unsetenv(t, configuredModelVars...)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got := modelLifecycleResults(context.Background(), NewEnv(RunSettings{}), "us-east-1")
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "bedrock.model_lifecycle" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if tt.fixBash != "" && (r.FixCommand == nil || r.FixCommand.Bash != tt.fixBash || !strings.HasPrefix(r.FixCommand.PowerShell, "$env:")) {
+				t.Errorf("fix command = %+v, want bash %q", r.FixCommand, tt.fixBash)
+			}
+			if tt.status == "pass" && len(r.Models) != len(tt.env) {
+				t.Errorf("models = %+v, want one per configured variable", r.Models)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/smithy-go"
+)
+
+// The search for another region is capped at modelRegionCandidates regions,
+// probed in parallel within modelRegionBudget.
+const (
+	modelRegionCandidates = 6
+	modelRegionBudget     = 5 * time.Second
+)
+
+// bedrockRegions are the regions that offer Bedrock, grouped by geography
+// with neighbours next to each other.
+var bedrockRegions = []string{
+	"us-east-1", "us-east-2", "us-west-2", "ca-central-1", "sa-east-1",
+	"eu-west-1", "eu-west-2", "eu-west-3", "eu-central-1", "eu-central-2", "eu-north-1", "eu-south-1", "eu-south-2",
+	"ap-northeast-1", "ap-northeast-3", "ap-northeast-2", "ap-southeast-1", "ap-southeast-2", "ap-south-1", "ap-south-2",
+	"us-gov-west-1", "us-gov-east-1",
+}
+
+// regionGeography is the geography part of a region name: "eu" for
+// eu-west-3, "us-gov" for us-gov-west-1.
+func regionGeography(region string) string {
+	if strings.HasPrefix(region, "us-gov-") {
+		return "us-gov"
+	}
+	geo, _, _ := strings.Cut(region, "-")
+	return geo
+}
+
+// candidateRegions returns the regions to search for a model missing from
+// region: those of the same partition, same geography first, capped at
+// modelRegionCandidates.
+func candidateRegions(region string) []string {
+	partition, geo := partitionForRegion(region).ID, regionGeography(region)
+	var near, far []string
+	for _, r := range bedrockRegions {
+		switch {
+		case r == region || partitionForRegion(r).ID != partition:
+		case regionGeography(r) == geo:
+			near = append(near, r)
+		default:
+			far = append(far, r)
+		}
+	}
+	candidates := append(near, far...)
+	if len(candidates) > modelRegionCandidates {
+		candidates = candidates[:modelRegionCandidates]
+	}
+	return candidates
+}
+
+// modelOffered reports whether model can be invoked in the client's region:
+// a cross-region inference profile must be listed there, and a foundation
+// model must be offered and granted to the account. reason says why not.
+func modelOffered(ctx context.Context, client BedrockClient, model string) (offered bool, reason string, err error) {
+	if isInferenceProfile(model) {
+		paginator := bedrock.NewListInferenceProfilesPaginator(client, &bedrock.ListInferenceProfilesInput{
+			TypeEquals: bedrocktypes.InferenceProfileTypeSystemDefined,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return false, "", err
+			}
+			for _, p := range page.InferenceProfileSummaries {
+				if aws.ToString(p.InferenceProfileId) == model {
+					return p.Status == bedrocktypes.InferenceProfileStatusActive, "inference profile not active", nil
+				}
+			}
+		}
+		return false, "inference profile not offered", nil
+	}
+
+	out, err := client.GetFoundationModelAvailability(ctx, &bedrock.GetFoundationModelAvailabilityInput{
+		ModelId: aws.String(baseModelID(model)),
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "ValidationException" || apiErr.ErrorCode() == "ResourceNotFoundException") {
+		return false, "not offered in this region", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	g := grantFromAvailability(baseModelID(model), "", out)
+	return g.Granted, g.Reason, nil
+}
+
+// runtimeLatency is the time to first byte of one unsigned request to the
+// Bedrock runtime of region, over a fresh connection.
+func runtimeLatency(ctx context.Context, env Env, region string) (time.Duration, error) {
+	host := partitionForRegion(region).hostname("bedrock-runtime", region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://"+host, nil)
+	if err != nil {
+		return 0, err
+	}
+	var sample latencyBreakdown
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, newLatencyTrace(&sample, start)))
+	resp, err := env.HTTP(host).Do(req)
+	logProbe("https", host, start, err, "purpose", "model_region")
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return sample.TTFB, nil
+}
+
+// regionOffer is the outcome of looking for the model in one region.
+type regionOffer struct {
+	Region  string
+	Offered bool
+	Latency time.Duration // zero when it could not be measured
+}
+
+// searchModelRegions looks for model in the candidate regions of region in
+// parallel and returns the regions that offer it, fastest first, along with
+// the latency of region itself.
+func searchModelRegions(env Env, region, model string) ([]regionOffer, time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), modelRegionBudget)
+	defer cancel()
+
+	var current time.Duration
+	candidates := candidateRegions(region)
+	offers := make([]regionOffer, len(candidates))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		current, _ = runtimeLatency(ctx, env, region)
+	}()
+	for i, r := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			offers[i].Region = r
+			cfg, err := env.loadAWSConfig(ctx, r)
+			if err != nil {
+				return
+			}
+			offered, _, err := modelOffered(ctx, env.Bedrock(cfg), model)
+			if err != nil || !offered {
+				return
+			}
+			offers[i].Offered = true
+			offers[i].Latency, _ = runtimeLatency(ctx, env, r)
+		}()
+	}
+	wg.Wait()
+
+	var found []regionOffer
+	for _, o := range offers {
+		if o.Offered {
+			found = append(found, o)
+		}
+	}
+	// Unmeasured regions go last; otherwise the candidate order stands.
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i].Latency, found[j].Latency
+		return a != 0 && (b == 0 || a < b)
+	})
+	return found, current
+}
+
+// latencyDelta describes how much further other is than current, e.g.
+// "28ms further".
+func latencyDelta(other, current time.Duration) string {
+	switch {
+	case other == 0:
+		return "latency not measured"
+	case current == 0:
+		return formatMs(other) + " to first byte"
+	case other >= current:
+		return formatMs(other-current) + " further"
+	default:
+		return formatMs(current-other) + " closer"
+	}
+}
+
+// modelRegionResults checks that ANTHROPIC_MODEL can be invoked in region
+// and, when it cannot, searches nearby regions for one where it can and
+// suggests the best of them. The search is skipped with
+// --no-external-probes and with an endpoint override, which pins traffic to
+// one region. It returns nothing without ANTHROPIC_MODEL or when the model
+// is an ARN, which names its region itself.
+func modelRegionResults(ctx context.Context, env Env, region string, search bool) []CheckResult {
+	model := configuredModel()
+	if model == "" || strings.HasPrefix(model, "arn:") {
+		return nil
+	}
+	result := CheckResult{ID: "bedrock.model_region", Name: "Model Region Availability"}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return []CheckResult{result}
+	}
+	offered, reason, err := modelOffered(ctx, env.Bedrock(cfg), model)
+	if err != nil {
+		action := "bedrock:GetFoundationModelAvailability"
+		if isInferenceProfile(model) {
+			action = "bedrock:ListInferenceProfiles"
+		}
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Cannot tell whether %s is available in %s: %v", model, region, err)
+		result.Fix = fmt.Sprintf("Add %s permission to your IAM role/user", action)
+		result.setAWSError(err, action)
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return []CheckResult{result}
+	}
+	if offered {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("ANTHROPIC_MODEL %s is available in %s", model, region)
+		return []CheckResult{result}
+	}
+
+	result.Status, result.Code = "fail", codeModelNotInRegion
+	if !strings.Contains(reason, "not offered") {
+		result.Code = codeModelNotGranted
+	}
+	result.Message = fmt.Sprintf("ANTHROPIC_MODEL %s is not available in %s (%s)", model, region, reason)
+	result.Fix = fmt.Sprintf("Set AWS_REGION to a region that offers %s, or set ANTHROPIC_MODEL to a model offered in %s", model, region)
+	if !search || env.Endpoint != nil {
+		return []CheckResult{result}
+	}
+
+	found, current := searchModelRegions(env, region, model)
+	if len(found) == 0 {
+		result.Fix = fmt.Sprintf("%s is not available to this account in any of %s either; set ANTHROPIC_MODEL to a model offered in %s, or request access in %s",
+			model, strings.Join(candidateRegions(region), ", "), region, modelAccessConsoleURL(region))
+		return []CheckResult{result}
+	}
+	best := found[0]
+	result.Fix = fmt.Sprintf("%s is available in %s (%s): export AWS_REGION=%s", model, best.Region, latencyDelta(best.Latency, current), best.Region)
+	if len(found) > 1 {
+		var others []string
+		for _, o := range found[1:] {
+			others = append(others, o.Region)
+		}
+		result.Fix += fmt.Sprintf("; also available in %s", strings.Join(others, ", "))
+	}
+	result.FixCommand = &FixCommand{
+		Bash:       fmt.Sprintf(`export AWS_REGION=%s`, best.Region),
+		PowerShell: fmt.Sprintf(`$env:AWS_REGION = "%s"`, best.Region),
+	}
+	if best.Latency > 0 {
+		result.Metrics = map[string]float64{"suggested_region_ttfb_ms": DurationMs(best.Latency)}
+		if current > 0 {
+			result.Metrics["current_region_ttfb_ms"] = DurationMs(current)
+		}
+	}
+	return []CheckResult{result}
+}
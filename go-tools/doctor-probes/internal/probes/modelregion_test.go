@@ -0,0 +1,199 @@
+package probes
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestRegionGeography(t *testing.T) {
+	tests := map[string]string{
+		"eu-west-3":      "eu",
+		"us-east-1":      "us",
+		"us-gov-west-1":  "us-gov",
+		"ap-northeast-1": "ap",
+	}
+	for region, want := range tests {
+		if got := regionGeography(region); got != want {
+			t.Errorf("regionGeography(%s) = %s, want %s", region, got, want)
+		}
+	}
+}
+
+func TestCandidateRegions(t *testing.T) {
+	tests := []struct {
+		region string
+		want   []string
+	}{
+		{"us-east-1", []string{"us-east-2", "us-west-2", "ca-central-1", "sa-east-1", "eu-west-1", "eu-west-2"}},
+		{"eu-west-3", []string{"eu-west-1", "eu-west-2", "eu-central-1", "eu-central-2", "eu-north-1", "eu-south-1"}},
+		{"us-gov-west-1", []string{"us-gov-east-1"}},
+	}
+	for _, tt := range tests {
+		if got := candidateRegions(tt.region); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("candidateRegions(%s) = %v, want %v", tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyDelta(t *testing.T) {
+	tests := []struct {
+		other, current time.Duration
+		want           string
+	}{
+		{0, 40 * time.Millisecond, "latency not measured"},
+		{60 * time.Millisecond, 0, "60ms to first byte"},
+		{68 * time.Millisecond, 40 * time.Millisecond, "28ms further"},
+		{30 * time.Millisecond, 40 * time.Millisecond, "10ms closer"},
+	}
+	for _, tt := range tests {
+		if got := latencyDelta(tt.other, tt.current); got != tt.want {
+			t.Errorf("latencyDelta(%v, %v) = %q, want %q", tt.other, tt.current, got, tt.want)
+		}
+	}
+}
+
+var signedRegion = regexp.MustCompile(`Credential=[^/]+/\d+/([^/]+)/`)
+
+func TestModelRegionResults(t *testing.T) {
+	const (
+		available    = `{"authorizationStatus":"AUTHORIZED","entitlementAvailability":"AVAILABLE","regionAvailability":"AVAILABLE"}`
+		notRequested = `{"authorizationStatus":"AUTHORIZED","entitlementAvailability":"NOT_AVAILABLE","regionAvailability":"AVAILABLE"}`
+		profiles     = `{"inferenceProfileSummaries":[{"inferenceProfileId":"us.anthropic.claude-x","status":"ACTIVE","type":"SYSTEM_DEFINED"}]}`
+	)
+	tests := []struct {
+		name     string
+		model    string
+		offers   map[string]string // availability body per region; missing means not offered
+		denied   bool
+		noSearch bool
+		pinned   bool   // endpoint override to the fake AWS
+		status   string // empty means no result
+		code     string
+		fixBash  string
+		contains []string
+	}{
+		{name: "no model"},
+		{name: "ARN", model: "arn:aws:bedrock:us-west-2:123456789012:application-inference-profile/abc"},
+		{name: "offered", model: "anthropic.claude-x", offers: map[string]string{"us-east-1": available}, status: "pass", contains: []string{"ANTHROPIC_MODEL anthropic.claude-x is available in us-east-1"}},
+		{
+			name:     "found nearby",
+			model:    "anthropic.claude-x",
+			offers:   map[string]string{"us-west-2": available, "eu-west-1": available},
+			status:   "fail",
+			code:     codeModelNotInRegion,
+			fixBash:  "export AWS_REGION=us-west-2",
+			contains: []string{"is not available in us-east-1 (not offered in this region)", "anthropic.claude-x is available in us-west-2 (latency not measured): export AWS_REGION=us-west-2; also available in eu-west-1"},
+		},
+		{
+			name:     "search disabled",
+			model:    "anthropic.claude-x",
+			offers:   map[string]string{"us-west-2": available},
+			noSearch: true,
+			status:   "fail",
+			code:     codeModelNotInRegion,
+			contains: []string{"Set AWS_REGION to a region that offers anthropic.claude-x"},
+		},
+		{
+			name:     "endpoint override pins the region",
+			model:    "anthropic.claude-x",
+			offers:   map[string]string{"us-west-2": available},
+			pinned:   true,
+			status:   "fail",
+			code:     codeModelNotInRegion,
+			contains: []string{"Set AWS_REGION to a region that offers"},
+		},
+		{
+			name:     "not granted anywhere",
+			model:    "anthropic.claude-x",
+			offers:   map[string]string{"us-east-1": notRequested},
+			status:   "fail",
+			code:     codeModelNotGranted,
+			contains: []string{"(access not requested)", "not available to this account in any of us-east-2, us-west-2, ca-central-1, sa-east-1, eu-west-1, eu-west-2 either"},
+		},
+		{
+			name:     "inference profile elsewhere",
+			model:    "us.anthropic.claude-x",
+			offers:   map[string]string{"us-east-2": profiles},
+			status:   "fail",
+			code:     codeModelNotInRegion,
+			fixBash:  "export AWS_REGION=us-east-2",
+			contains: []string{"(inference profile not offered)"},
+		},
+		{
+			name:     "denied",
+			model:    "anthropic.claude-x",
+			denied:   true,
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Cannot tell whether anthropic.claude-x is available in us-east-1", "bedrock:GetFoundationModelAvailability"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.denied {
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+					return
+				}
+				m := signedRegion.FindStringSubmatch(r.Header.Get("Authorization"))
+				body, ok := "", false
+				if m != nil {
+					body, ok = tt.offers[m[1]]
+				}
+				profileCall := strings.HasPrefix(r.URL.Path, "/inference-profiles")
+				switch {
+				case profileCall && !ok:
+					w.Write([]byte(`{"inferenceProfileSummaries":[]}`))
+				case !ok:
+					probestest.AWSJSONError(w, http.StatusBadRequest, "ValidationException", "model not found")
+				default:
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(body))
+				}
+			})
+			env := NewEnv(RunSettings{})
+			env.HTTP = func(string) HTTPDoer { return probestest.OK{} }
+			if tt.pinned {
+				withEndpoint(t, &env, os.Getenv("AWS_ENDPOINT_URL"))
+			} else {
+				withEndpoint(t, &env, "")
+			}
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			got := modelRegionResults(context.Background(), env, "us-east-1", !tt.noSearch)
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "bedrock.model_region" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if tt.fixBash != "" && (r.FixCommand == nil || r.FixCommand.Bash != tt.fixBash) {
+				t.Errorf("fix command = %+v, want %q", r.FixCommand, tt.fixBash)
+			}
+			if tt.fixBash == "" && r.FixCommand != nil {
+				t.Errorf("fix command = %+v, want none", r.FixCommand)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package probes
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// noProxyEntry is one parsed NO_PROXY entry, as undici (Node's fetch, used
+// by Claude Code) parses it.
+type noProxyEntry struct {
+	Raw      string
+	Hostname string
+	Port     int // 0 = any
+}
+
+var noProxyPort = regexp.MustCompile(`^(.+):(\d+)$`)
+
+// proxyEnv returns the first set variable among names, undici-style: an
+// empty lowercase variable still shadows the uppercase one.
+func proxyEnv(names ...string) (value, name string) {
+	for _, n := range names {
+		if v, ok := os.LookupEnv(n); ok {
+			return v, n
+		}
+	}
+	return "", names[len(names)-1]
+}
+
+func parseNoProxy(value string) []noProxyEntry {
+	var entries []noProxyEntry
+	for _, raw := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' }) {
+		entry := noProxyEntry{Raw: raw, Hostname: strings.ToLower(raw)}
+		if m := noProxyPort.FindStringSubmatch(raw); m != nil {
+			entry.Hostname = strings.ToLower(m[1])
+			entry.Port, _ = strconv.Atoi(m[2])
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// undiciShouldProxy mirrors undici's EnvHttpProxyAgent: entries without a
+// leading "." or "*" match the hostname exactly (no subdomains); entries with
+// one match as a plain suffix; "*" alone disables the proxy.
+func undiciShouldProxy(entries []noProxyEntry, noProxyValue, host string, port int) bool {
+	if noProxyValue == "*" {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, e := range entries {
+		if e.Port != 0 && e.Port != port {
+			continue
+		}
+		if !strings.HasPrefix(e.Hostname, ".") && !strings.HasPrefix(e.Hostname, "*") {
+			if host == e.Hostname {
+				return false
+			}
+		} else if strings.HasSuffix(host, strings.TrimPrefix(e.Hostname, "*")) {
+			return false
+		}
+	}
+	return true
+}
+
+// intendedBypass returns the entry that, by curl/Go domain rules, looks like
+// it was meant to cover host, and the entry undici would need instead.
+func intendedBypass(entries []noProxyEntry, host string) (entry noProxyEntry, suggestion string, ok bool) {
+	host = strings.ToLower(host)
+	for _, e := range entries {
+		domain := strings.TrimLeft(e.Hostname, "*.")
+		if domain == "" || strings.Contains(domain, "*") {
+			continue
+		}
+		if host != domain && strings.HasSuffix(host, "."+domain) {
+			return e, "." + domain, true
+		}
+	}
+	return noProxyEntry{}, "", false
+}
+
+// noProxyResult evaluates the proxy decision for each probed host. It
+// returns nothing when no HTTPS proxy is configured.
+func noProxyResult(env Env, hosts []string) []CheckResult {
+	proxy, _ := proxyEnv("https_proxy", "HTTPS_PROXY", "http_proxy", "HTTP_PROXY")
+	if proxy == "" {
+		return nil
+	}
+	noProxy, noProxyVar := proxyEnv("no_proxy", "NO_PROXY")
+	entries := parseNoProxy(noProxy)
+
+	// A custom Bedrock endpoint (VPC endpoint or private DNS name) signals
+	// that AWS traffic is meant to bypass the proxy.
+	privateRoute := ""
+	if env.Endpoint != nil {
+		privateRoute = env.Endpoint.Hostname()
+	}
+
+	var problems, decisions []string
+	suggested := map[string]bool{}
+	var additions []string
+	suggest := func(entry string) {
+		if !suggested[entry] {
+			suggested[entry] = true
+			additions = append(additions, entry)
+		}
+	}
+
+	for _, e := range entries {
+		switch {
+		case strings.Contains(strings.TrimPrefix(e.Hostname, "*"), "*"):
+			problems = append(problems, fmt.Sprintf("entry %q uses a mid-string wildcard, which Node ignores", e.Raw))
+		case strings.Contains(e.Hostname, "/"):
+			if _, _, err := net.ParseCIDR(e.Hostname); err == nil {
+				problems = append(problems, fmt.Sprintf("entry %q is a CIDR range, which Node does not support (only exact IPs)", e.Raw))
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		proxied := undiciShouldProxy(entries, noProxy, host, 443)
+		route := "direct"
+		if proxied {
+			route = "via proxy"
+		}
+		decisions = append(decisions, fmt.Sprintf("%s %s", host, route))
+		if !proxied {
+			continue
+		}
+		if e, suggestion, ok := intendedBypass(entries, host); ok {
+			problems = append(problems, fmt.Sprintf("%s goes via the proxy: entry %q matches only that exact name in Node", host, e.Raw))
+			suggest(suggestion)
+		} else if host == privateRoute {
+			problems = append(problems, fmt.Sprintf("custom Bedrock endpoint %s goes via the proxy", host))
+			suggest(host)
+		}
+	}
+
+	result := CheckResult{
+		ID:      "network.no_proxy",
+		Name:    "NO_PROXY Coverage",
+		Status:  "pass",
+		Message: fmt.Sprintf("%s=%q: %s", noProxyVar, noProxy, strings.Join(decisions, ", ")),
+	}
+	if len(problems) == 0 {
+		return []CheckResult{result}
+	}
+
+	result.Status = "warn"
+	result.Code = codeNoProxyMismatch
+	result.Message = fmt.Sprintf("%s; %s", strings.Join(problems, "; "), result.Message)
+	if len(additions) == 0 {
+		result.Fix = "Use leading-dot domain entries (e.g. .amazonaws.com) and exact IPs in " + noProxyVar
+		return []CheckResult{result}
+	}
+
+	result.Fix = fmt.Sprintf("Add %s to %s", strings.Join(additions, ","), noProxyVar)
+	var bash, ps []string
+	for _, entry := range additions {
+		bash = append(bash, fmt.Sprintf(`case ",$%[1]s," in *",%[2]s,"*) ;; *) export %[1]s="${%[1]s:+$%[1]s,}%[2]s" ;; esac`, noProxyVar, entry))
+		ps = append(ps, fmt.Sprintf(`if (",$env:%[1]s," -notlike "*,%[2]s,*") { $env:%[1]s = ("$env:%[1]s,%[2]s").TrimStart(",") }`, noProxyVar, entry))
+	}
+	result.FixCommand = &FixCommand{Bash: strings.Join(bash, "\n"), PowerShell: strings.Join(ps, "\n")}
+	return []CheckResult{result}
+}
@@ -0,0 +1,222 @@
+package probes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// withProxyEnv clears every proxy variable, then sets env.
+func withProxyEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	probestest.Unsetenv(t, "https_proxy", "HTTPS_PROXY", "http_proxy", "HTTP_PROXY", "no_proxy", "NO_PROXY", "all_proxy", "ALL_PROXY")
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestProxyEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		value     string
+		valueName string
+	}{
+		{name: "neither set", value: "", valueName: "NO_PROXY"},
+		{name: "uppercase only", env: map[string]string{"NO_PROXY": ".amazonaws.com"}, value: ".amazonaws.com", valueName: "NO_PROXY"},
+		{name: "lowercase wins", env: map[string]string{"no_proxy": "localhost", "NO_PROXY": ".amazonaws.com"}, value: "localhost", valueName: "no_proxy"},
+		{name: "empty lowercase shadows uppercase", env: map[string]string{"no_proxy": "", "NO_PROXY": ".amazonaws.com"}, value: "", valueName: "no_proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withProxyEnv(t, tt.env)
+			value, name := proxyEnv("no_proxy", "NO_PROXY")
+			if value != tt.value || name != tt.valueName {
+				t.Errorf("proxyEnv = %q, %s; want %q, %s", value, name, tt.value, tt.valueName)
+			}
+		})
+	}
+}
+
+func TestParseNoProxy(t *testing.T) {
+	got := parseNoProxy("localhost, .Example.com:8443\t10.0.0.0/8,,[::1]:443 *.corp")
+	want := []noProxyEntry{
+		{Raw: "localhost", Hostname: "localhost"},
+		{Raw: ".Example.com:8443", Hostname: ".example.com", Port: 8443},
+		{Raw: "10.0.0.0/8", Hostname: "10.0.0.0/8"},
+		{Raw: "[::1]:443", Hostname: "[::1]", Port: 443},
+		{Raw: "*.corp", Hostname: "*.corp"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNoProxy = %+v, want %+v", got, want)
+	}
+}
+
+func TestUndiciShouldProxy(t *testing.T) {
+	const host = "bedrock-runtime.us-east-1.amazonaws.com"
+	tests := []struct {
+		name    string
+		noProxy string
+		host    string
+		port    int
+		want    bool
+	}{
+		{name: "empty", noProxy: "", host: host, port: 443, want: true},
+		{name: "exact name", noProxy: host, host: host, port: 443, want: false},
+		{name: "exact name is case-insensitive", noProxy: "Bedrock-Runtime.US-East-1.amazonaws.com", host: host, port: 443, want: false},
+		{name: "bare domain does not cover subdomains", noProxy: "amazonaws.com", host: host, port: 443, want: true},
+		{name: "leading dot covers subdomains", noProxy: ".amazonaws.com", host: host, port: 443, want: false},
+		{name: "star-dot covers subdomains", noProxy: "*.amazonaws.com", host: host, port: 443, want: false},
+		{name: "star alone disables the proxy", noProxy: "*", host: host, port: 443, want: false},
+		{name: "star in a list is not special", noProxy: "localhost,*", host: host, port: 443, want: false},
+		{name: "matching port", noProxy: ".amazonaws.com:443", host: host, port: 443, want: false},
+		{name: "other port", noProxy: ".amazonaws.com:8443", host: host, port: 443, want: true},
+		{name: "unrelated entries", noProxy: "localhost,.internal", host: host, port: 443, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := undiciShouldProxy(parseNoProxy(tt.noProxy), tt.noProxy, tt.host, tt.port); got != tt.want {
+				t.Errorf("undiciShouldProxy(%q, %s:%d) = %v, want %v", tt.noProxy, tt.host, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntendedBypass(t *testing.T) {
+	tests := []struct {
+		noProxy    string
+		host       string
+		entry      string
+		suggestion string
+	}{
+		{"amazonaws.com", "sts.amazonaws.com", "amazonaws.com", ".amazonaws.com"},
+		{"localhost,us-east-1.amazonaws.com", "bedrock-runtime.us-east-1.amazonaws.com", "us-east-1.amazonaws.com", ".us-east-1.amazonaws.com"},
+		{"sts.amazonaws.com", "sts.amazonaws.com", "", ""},
+		{"*", "sts.amazonaws.com", "", ""},
+		{"ama*zonaws.com", "sts.amazonaws.com", "", ""},
+	}
+	for _, tt := range tests {
+		entry, suggestion, ok := intendedBypass(parseNoProxy(tt.noProxy), tt.host)
+		if entry.Raw != tt.entry || suggestion != tt.suggestion || ok != (tt.entry != "") {
+			t.Errorf("intendedBypass(%q, %s) = %q, %q, %v; want %q, %q", tt.noProxy, tt.host, entry.Raw, suggestion, ok, tt.entry, tt.suggestion)
+		}
+	}
+}
+
+func TestNoProxyResult(t *testing.T) {
+	hosts := []string{"bedrock-runtime.us-east-1.amazonaws.com", "sts.us-east-1.amazonaws.com"}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		endpoint string
+		status   string // empty means no result
+		contains []string
+		fix      string
+		fixBash  string
+	}{
+		{name: "no proxy configured", env: map[string]string{"NO_PROXY": "amazonaws.com"}},
+		{
+			name:     "everything via the proxy",
+			env:      map[string]string{"HTTPS_PROXY": "http://proxy:3128"},
+			status:   "pass",
+			contains: []string{`NO_PROXY="": bedrock-runtime.us-east-1.amazonaws.com via proxy, sts.us-east-1.amazonaws.com via proxy`},
+		},
+		{
+			name:     "leading dot bypasses",
+			env:      map[string]string{"https_proxy": "http://proxy:3128", "NO_PROXY": ".amazonaws.com"},
+			status:   "pass",
+			contains: []string{"bedrock-runtime.us-east-1.amazonaws.com direct, sts.us-east-1.amazonaws.com direct"},
+		},
+		{
+			name:     "bare domain meant as a suffix",
+			env:      map[string]string{"HTTPS_PROXY": "http://proxy:3128", "NO_PROXY": "amazonaws.com"},
+			status:   "warn",
+			contains: []string{`bedrock-runtime.us-east-1.amazonaws.com goes via the proxy: entry "amazonaws.com" matches only that exact name in Node`},
+			fix:      "Add .amazonaws.com to NO_PROXY",
+			fixBash:  `case ",$NO_PROXY," in *",.amazonaws.com,"*) ;; *) export NO_PROXY="${NO_PROXY:+$NO_PROXY,}.amazonaws.com" ;; esac`,
+		},
+		{
+			name:     "empty lowercase shadows uppercase",
+			env:      map[string]string{"HTTPS_PROXY": "http://proxy:3128", "no_proxy": "", "NO_PROXY": ".amazonaws.com"},
+			status:   "pass",
+			contains: []string{`no_proxy="": bedrock-runtime.us-east-1.amazonaws.com via proxy`},
+		},
+		{
+			name:     "CIDR range",
+			env:      map[string]string{"HTTPS_PROXY": "http://proxy:3128", "NO_PROXY": "10.0.0.0/8,.amazonaws.com"},
+			status:   "warn",
+			contains: []string{`entry "10.0.0.0/8" is a CIDR range, which Node does not support`},
+			fix:      "Use leading-dot domain entries (e.g. .amazonaws.com) and exact IPs in NO_PROXY",
+		},
+		{
+			name:     "mid-string wildcard",
+			env:      map[string]string{"HTTPS_PROXY": "http://proxy:3128", "NO_PROXY": "bedrock*.amazonaws.com,*.internal"},
+			status:   "warn",
+			contains: []string{`entry "bedrock*.amazonaws.com" uses a mid-string wildcard`},
+			fix:      "Use leading-dot domain entries (e.g. .amazonaws.com) and exact IPs in NO_PROXY",
+		},
+		{
+			name: "custom endpoint via the proxy",
+			env: map[string]string{
+				"HTTPS_PROXY": "http://proxy:3128",
+				"NO_PROXY":    ".amazonaws.com",
+			},
+			endpoint: "https://bedrock.corp.internal",
+			status:   "warn",
+			contains: []string{"custom Bedrock endpoint bedrock.corp.internal goes via the proxy"},
+			fix:      "Add bedrock.corp.internal to NO_PROXY",
+		},
+		{
+			name: "two suggestions, one per line",
+			env: map[string]string{
+				"HTTPS_PROXY": "http://proxy:3128",
+				"NO_PROXY":    "amazonaws.com",
+			},
+			endpoint: "https://bedrock.corp.internal",
+			status:   "warn",
+			fix:      "Add bedrock.corp.internal,.amazonaws.com to NO_PROXY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, tt.env)
+			withEndpoint(t, &env, tt.endpoint)
+			probed := hosts
+			if env.Endpoint != nil {
+				// runChecks probes the override in place of the public runtime host.
+				probed = []string{env.Endpoint.Hostname(), hosts[1]}
+			}
+			got := noProxyResult(env, probed)
+			if tt.status == "" {
+				if got != nil {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.Status != tt.status || (r.Status == "warn") != (r.Code == codeNoProxyMismatch) {
+				t.Errorf("status, code = %s, %s; want %s", r.Status, r.Code, tt.status)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(r.Message, want) {
+					t.Errorf("message %q does not contain %q", r.Message, want)
+				}
+			}
+			if r.Fix != tt.fix {
+				t.Errorf("fix = %q, want %q", r.Fix, tt.fix)
+			}
+			if tt.fixBash != "" && (r.FixCommand == nil || r.FixCommand.Bash != tt.fixBash) {
+				t.Errorf("fix command = %+v, want bash %q", r.FixCommand, tt.fixBash)
+			}
+			if r.FixCommand != nil && strings.Count(r.FixCommand.Bash, "\n") != strings.Count(r.Fix, ",") {
+				t.Errorf("want one bash line per added entry:\n%s", r.FixCommand.Bash)
+			}
+		})
+	}
+}
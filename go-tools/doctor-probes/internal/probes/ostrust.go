@@ -0,0 +1,195 @@
+package probes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// systemBundlePaths are the distribution CA bundles, in the order Go and
+// OpenSSL-based tools look for them.
+var systemBundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian, Ubuntu, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",                            // openSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS, RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine, BSDs
+}
+
+// goTrustSource describes what Go's default verification trusts here.
+func goTrustSource() string {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return "the platform verifier"
+	}
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		return "SSL_CERT_FILE=" + f
+	}
+	if d := os.Getenv("SSL_CERT_DIR"); d != "" {
+		return "SSL_CERT_DIR=" + d
+	}
+	return "the system bundle"
+}
+
+// verifyWithOSStore verifies chain for host the way the operating system
+// does, independently of Go's verifier, and names the store it used.
+func verifyWithOSStore(chain []*x509.Certificate, host string) (store string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "macOS keychains", verifyWithSecurityTool(chain, host)
+	case "windows":
+		return "Windows certificate store", verifyWithCryptoAPI(chain, host)
+	}
+	return verifyWithSystemBundle(chain, host)
+}
+
+// verifyWithSecurityTool runs macOS's security verify-cert on the served
+// chain with the SSL policy, using local certificates only.
+func verifyWithSecurityTool(chain []*x509.Certificate, host string) error {
+	dir, err := os.MkdirTemp("", "bcce-chain-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	args := []string{"verify-cert", "-L", "-p", "ssl", "-s", host}
+	for i, cert := range chain {
+		path := filepath.Join(dir, fmt.Sprintf("%d.pem", i))
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+			return err
+		}
+		args = append(args, "-c", path)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "security", args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return errors.New(strings.ReplaceAll(msg, "\n", "; "))
+		}
+		return err
+	}
+	return nil
+}
+
+// verifyWithSystemBundle verifies chain against the distribution bundle,
+// ignoring SSL_CERT_FILE and SSL_CERT_DIR, which Go honors and the OS store
+// does not.
+func verifyWithSystemBundle(chain []*x509.Certificate, host string) (string, error) {
+	for _, path := range systemBundlePaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(data) {
+			return path, fmt.Errorf("%s contains no certificates", path)
+		}
+		return path, verifyChain(chain, host, roots)
+	}
+	return "no system bundle", fmt.Errorf("none of %s exists", strings.Join(systemBundlePaths, ", "))
+}
+
+// verifyChain verifies the served chain for host against roots, or Go's
+// default roots when roots is nil.
+func verifyChain(chain []*x509.Certificate, host string, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// installRootFix says how to add the CA that issued top, the last served
+// certificate, to the OS store on this platform.
+func installRootFix(top *x509.Certificate, store string) string {
+	ca := fmt.Sprintf("the root CA that issued '%s' (and any intermediate between them)", issuerName(top))
+	switch runtime.GOOS {
+	case "darwin":
+		return fmt.Sprintf("Get %s as PEM from IT, then trust it system-wide: sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain root.pem (an intermediate is imported without trust settings: sudo security import intermediate.pem -k /Library/Keychains/System.keychain)", ca)
+	case "windows":
+		return fmt.Sprintf(`Get %s from IT, then import it: Import-Certificate -FilePath root.cer -CertStoreLocation Cert:\CurrentUser\Root (Cert:\LocalMachine\Root as administrator; an intermediate goes in Cert:\LocalMachine\CA)`, ca)
+	}
+	if strings.HasPrefix(store, "/etc/pki/") {
+		return fmt.Sprintf("Get %s as PEM from IT, then: sudo cp root.pem /etc/pki/ca-trust/source/anchors/ && sudo update-ca-trust", ca)
+	}
+	return fmt.Sprintf("Get %s as PEM from IT, then: sudo cp root.pem /usr/local/share/ca-certificates/root.crt && sudo update-ca-certificates", ca)
+}
+
+// osTrustResult verifies the chain served for the Bedrock runtime on Claude
+// Code's route twice, with Go's default verification and with the operating
+// system's store, and reports when they disagree: a half-installed corporate
+// root can satisfy one and not the other. It is never worse than a warning;
+// Certificate Validation reports an untrusted chain.
+func osTrustResult(env Env, bedrockURL string) CheckResult {
+	result := CheckResult{ID: "tls.os_trust", Name: "OS Trust Store"}
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+	start := time.Now()
+	state, err := tlsHandshake(env, addr, proxy, &tls.Config{InsecureSkipVerify: true})
+	logProbe("os_trust", addr, start, err)
+	if err != nil {
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("TLS handshake with %s failed: %v", addr, err)
+		result.Fix = "See HTTPS Connectivity"
+		return result
+	}
+	chain := state.PeerCertificates
+	if len(chain) == 0 {
+		result.Status, result.Code = "warn", codeTLSFailure
+		result.Message = fmt.Sprintf("%s presented no certificate", addr)
+		return result
+	}
+	top := chain[len(chain)-1]
+
+	goErr := verifyChain(chain, host, nil)
+	store, osErr := verifyWithOSStore(chain, host)
+	debugLog.Debug("os trust", "host", host, "go_source", goTrustSource(), "go_error", fmt.Sprint(goErr), "os_store", store, "os_error", fmt.Sprint(osErr))
+	goSide := fmt.Sprintf("Go's default verification (%s)", goTrustSource())
+	osSide := fmt.Sprintf("the OS store (%s)", store)
+
+	switch {
+	case goErr == nil && osErr == nil:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s and %s both trust the chain for %s, which ends at %s", goSide, osSide, host, issuerName(top))
+	case goErr != nil && osErr != nil:
+		result.Status, result.Code = "warn", codeTLSFailure
+		result.Message = fmt.Sprintf("Neither %s nor %s trusts the chain for %s, which ends at %s: %v; %v", goSide, osSide, host, issuerName(top), goErr, osErr)
+		result.Fix = "See Certificate Validation. " + installRootFix(top, store)
+	case osErr != nil:
+		result.Status, result.Code = "warn", codeTrustStoreDivergence
+		result.Message = fmt.Sprintf("%s trusts the chain for %s, but %s does not: %v", goSide, host, osSide, osErr)
+		result.Fix = "Go-based tools succeed while tools that use the OS store fail, including Node with --use-system-ca. " + installRootFix(top, store)
+	default:
+		result.Status, result.Code = "warn", codeTrustStoreDivergence
+		result.Message = fmt.Sprintf("%s trusts the chain for %s, but %s does not: %v", osSide, host, goSide, goErr)
+		result.Fix = "Tools that use the OS store succeed while Go-based tools fail"
+		if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+			result.Fix += fmt.Sprintf(": SSL_CERT_FILE points to %s, which lacks the CA; point it at %s or unset it", f, store)
+		} else if d := os.Getenv("SSL_CERT_DIR"); d != "" {
+			result.Fix += fmt.Sprintf(": SSL_CERT_DIR points to %s, which lacks the CA; add the CA there or unset it", d)
+		}
+	}
+	result.attachDiagnostics(env, &Diagnostics{Certificate: certificateDetails(chain)})
+	return result
+}
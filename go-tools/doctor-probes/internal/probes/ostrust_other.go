@@ -0,0 +1,13 @@
+//go:build !windows
+
+package probes
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+// verifyWithCryptoAPI is only available on Windows.
+func verifyWithCryptoAPI([]*x509.Certificate, string) error {
+	return errors.New("CryptoAPI is only available on Windows")
+}
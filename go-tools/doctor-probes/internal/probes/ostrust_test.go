@@ -0,0 +1,114 @@
+package probes
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// withBundle points the system bundle lookup at a file holding certs, or at
+// a missing file when there are none.
+func withBundle(t *testing.T, certs ...*x509.Certificate) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca-certificates.crt")
+	if len(certs) > 0 {
+		var data []byte
+		for _, cert := range certs {
+			data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := systemBundlePaths
+	systemBundlePaths = []string{path}
+	t.Cleanup(func() { systemBundlePaths = saved })
+}
+
+func TestInstallRootFix(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the fix names the platform's own tool")
+	}
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	tests := map[string]string{
+		"/etc/pki/tls/certs/ca-bundle.crt":   "/etc/pki/ca-trust/source/anchors/ && sudo update-ca-trust",
+		"/etc/ssl/certs/ca-certificates.crt": "/usr/local/share/ca-certificates/root.crt && sudo update-ca-certificates",
+	}
+	for store, want := range tests {
+		if got := installRootFix(srv.Certificate(), store); !strings.Contains(got, want) || !strings.Contains(got, "the root CA that issued") {
+			t.Errorf("installRootFix(%s) = %q, want %q", store, got, want)
+		}
+	}
+}
+
+func TestOSTrustResult(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the OS store here is the platform verifier, not a bundle file")
+	}
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	tests := []struct {
+		name     string
+		target   string
+		bundle   []*x509.Certificate // empty means no bundle exists
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "OS store trusts what Go does not",
+			target:   srv.URL,
+			bundle:   []*x509.Certificate{srv.Certificate()},
+			status:   "warn",
+			code:     codeTrustStoreDivergence,
+			contains: []string{"trusts the chain for 127.0.0.1, but Go's default verification (", "Tools that use the OS store succeed while Go-based tools fail"},
+		},
+		{
+			name:     "neither trusts",
+			target:   certServer(t, "Example Corp", nil),
+			bundle:   []*x509.Certificate{srv.Certificate()},
+			status:   "warn",
+			code:     codeTLSFailure,
+			contains: []string{"Neither Go's default verification (", "See Certificate Validation. Get the root CA that issued 'Example Corp Root'"},
+		},
+		{
+			name:     "no system bundle",
+			target:   srv.URL,
+			status:   "warn",
+			code:     codeTLSFailure,
+			contains: []string{"the OS store (no system bundle)", "ca-certificates.crt exists"},
+		},
+		{
+			name:     "refused",
+			target:   "https://127.0.0.1:1",
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"TLS handshake with 127.0.0.1:1 failed", "See HTTPS Connectivity"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, nil)
+			withBundle(t, tt.bundle...)
+
+			r := osTrustResult(env, tt.target)
+			if r.ID != "tls.os_trust" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
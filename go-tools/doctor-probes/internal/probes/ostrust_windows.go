@@ -0,0 +1,99 @@
+package probes
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// certTrustIsPartialChain is CERT_TRUST_IS_PARTIAL_CHAIN, which package
+// syscall does not define.
+const certTrustIsPartialChain = 0x00010000
+
+// chainTrustErrors names the CERT_TRUST_* bits worth telling the user about.
+var chainTrustErrors = []struct {
+	bit  uint32
+	text string
+}{
+	{syscall.CERT_TRUST_IS_NOT_TIME_VALID, "a certificate is expired or not yet valid"},
+	{syscall.CERT_TRUST_IS_REVOKED, "a certificate is revoked"},
+	{syscall.CERT_TRUST_IS_UNTRUSTED_ROOT, "the root is not trusted"},
+	{certTrustIsPartialChain, "no chain to a root could be built (missing intermediate or root)"},
+}
+
+// verifyWithCryptoAPI builds and checks the chain with CertGetCertificateChain
+// and the SSL chain policy, with the served certificates in an in-memory
+// store, as Windows applications do.
+func verifyWithCryptoAPI(chain []*x509.Certificate, host string) error {
+	store, err := syscall.CertOpenStore(syscall.CERT_STORE_PROV_MEMORY, 0, 0, syscall.CERT_STORE_DEFER_CLOSE_UNTIL_LAST_FREE_FLAG, 0)
+	if err != nil {
+		return fmt.Errorf("CertOpenStore: %w", err)
+	}
+	defer syscall.CertCloseStore(store, 0)
+
+	var leaf *syscall.CertContext
+	for i, cert := range chain {
+		ctx, err := syscall.CertCreateCertificateContext(syscall.X509_ASN_ENCODING|syscall.PKCS_7_ASN_ENCODING, &cert.Raw[0], uint32(len(cert.Raw)))
+		if err != nil {
+			return fmt.Errorf("CertCreateCertificateContext: %w", err)
+		}
+		var added *syscall.CertContext
+		err = syscall.CertAddCertificateContextToStore(store, ctx, syscall.CERT_STORE_ADD_ALWAYS, &added)
+		syscall.CertFreeCertificateContext(ctx)
+		if err != nil {
+			return fmt.Errorf("CertAddCertificateContextToStore: %w", err)
+		}
+		if i == 0 {
+			leaf = added
+		} else {
+			syscall.CertFreeCertificateContext(added)
+		}
+	}
+	defer syscall.CertFreeCertificateContext(leaf)
+
+	serverAuth := &syscall.OID_PKIX_KP_SERVER_AUTH[0]
+	para := &syscall.CertChainPara{}
+	para.Size = uint32(unsafe.Sizeof(*para))
+	para.RequestedUsage.Type = syscall.USAGE_MATCH_TYPE_OR
+	para.RequestedUsage.Usage.Length = 1
+	para.RequestedUsage.Usage.UsageIdentifiers = &serverAuth
+
+	var chainCtx *syscall.CertChainContext
+	if err := syscall.CertGetCertificateChain(syscall.Handle(0), leaf, nil, leaf.Store, para, 0, 0, &chainCtx); err != nil {
+		return fmt.Errorf("CertGetCertificateChain: %w", err)
+	}
+	defer syscall.CertFreeCertificateChain(chainCtx)
+
+	if status := chainCtx.TrustStatus.ErrorStatus; status != syscall.CERT_TRUST_NO_ERROR {
+		var problems []string
+		for _, e := range chainTrustErrors {
+			if status&e.bit != 0 {
+				problems = append(problems, e.text)
+			}
+		}
+		if len(problems) == 0 {
+			problems = append(problems, "the chain is not valid")
+		}
+		return fmt.Errorf("%s (trust status 0x%x)", strings.Join(problems, "; "), status)
+	}
+
+	serverName, err := syscall.UTF16PtrFromString(host)
+	if err != nil {
+		return err
+	}
+	sslPara := &syscall.SSLExtraCertChainPolicyPara{AuthType: syscall.AUTHTYPE_SERVER, ServerName: serverName}
+	sslPara.Size = uint32(unsafe.Sizeof(*sslPara))
+	policyPara := &syscall.CertChainPolicyPara{ExtraPolicyPara: (syscall.Pointer)(unsafe.Pointer(sslPara))}
+	policyPara.Size = uint32(unsafe.Sizeof(*policyPara))
+	status := &syscall.CertChainPolicyStatus{}
+	status.Size = uint32(unsafe.Sizeof(*status))
+	if err := syscall.CertVerifyCertificateChainPolicy(syscall.CERT_CHAIN_POLICY_SSL, chainCtx, policyPara, status); err != nil {
+		return fmt.Errorf("CertVerifyCertificateChainPolicy: %w", err)
+	}
+	if status.Error != 0 {
+		return fmt.Errorf("%w (0x%08X)", syscall.Errno(status.Error), status.Error)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type otelSignal struct {
+	name  string // "metrics" or "logs"
+	label string
+	path  string // OTLP/HTTP path appended to the base endpoint
+}
+
+var otelSignals = []otelSignal{
+	{"metrics", "Metrics", "/v1/metrics"},
+	{"logs", "Logs", "/v1/logs"},
+}
+
+// otelEnv returns the signal-specific OTEL_EXPORTER_OTLP_<SIGNAL>_<key>
+// variable, falling back to the generic OTEL_EXPORTER_OTLP_<key>.
+func otelEnv(signal, key string) (value, source string) {
+	specific := fmt.Sprintf("OTEL_EXPORTER_OTLP_%s_%s", strings.ToUpper(signal), key)
+	if v := os.Getenv(specific); v != "" {
+		return v, specific
+	}
+	generic := "OTEL_EXPORTER_OTLP_" + key
+	return os.Getenv(generic), generic
+}
+
+// parseOTLPHeaders parses "k1=v1,k2=v2" (values URL-encoded) and returns the
+// entries that could not be parsed.
+func parseOTLPHeaders(spec string) (http.Header, []string) {
+	headers := make(http.Header)
+	var bad []string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.TrimSpace(value) == "" {
+			bad = append(bad, key)
+			continue
+		}
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		headers.Set(key, strings.TrimSpace(value))
+	}
+	return headers, bad
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func otelResults(env Env) []CheckResult {
+	telemetryEnabled := os.Getenv("CLAUDE_CODE_ENABLE_TELEMETRY") == "1"
+	configured := false
+	for _, sig := range otelSignals {
+		if v, _ := otelEnv(sig.name, "ENDPOINT"); v != "" {
+			configured = true
+		}
+	}
+
+	if !configured {
+		if telemetryEnabled && os.Getenv("OTEL_METRICS_EXPORTER") != "console" {
+			return []CheckResult{{
+				ID:      "otel.endpoint",
+				Code:    codeOTelConfig,
+				Name:    "OpenTelemetry Endpoint",
+				Status:  "warn",
+				Message: "CLAUDE_CODE_ENABLE_TELEMETRY=1 but no OTEL_EXPORTER_OTLP_ENDPOINT is set",
+				Fix:     "export OTEL_EXPORTER_OTLP_ENDPOINT=<collector-url>",
+			}}
+		}
+		return nil
+	}
+
+	var results []CheckResult
+	for _, sig := range otelSignals {
+		endpoint, source := otelEnv(sig.name, "ENDPOINT")
+		if endpoint == "" {
+			continue
+		}
+		protocol, _ := otelEnv(sig.name, "PROTOCOL")
+		if protocol == "" {
+			protocol = "http/protobuf"
+		}
+		// The generic endpoint is a base URL; signal-specific ones are used as-is.
+		exportURL := endpoint
+		if source == "OTEL_EXPORTER_OTLP_ENDPOINT" && protocol != "grpc" {
+			exportURL = strings.TrimSuffix(endpoint, "/") + sig.path
+		}
+		results = append(results, otelEndpointResult(env, sig, exportURL, protocol))
+	}
+
+	if headerSpec := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); headerSpec != "" {
+		if _, bad := parseOTLPHeaders(headerSpec); len(bad) > 0 {
+			results = append(results, CheckResult{
+				ID:      "otel.headers",
+				Code:    codeOTelConfig,
+				Name:    "OpenTelemetry Headers",
+				Status:  "warn",
+				Message: fmt.Sprintf("OTEL_EXPORTER_OTLP_HEADERS has malformed or empty entries: %s", strings.Join(bad, ", ")),
+				Fix:     "Use the form key1=value1,key2=value2 with URL-encoded values",
+			})
+		}
+	}
+
+	return results
+}
+
+func otelEndpointResult(env Env, sig otelSignal, exportURL, protocol string) CheckResult {
+	name := fmt.Sprintf("OpenTelemetry %s Endpoint", sig.label)
+	id := fmt.Sprintf("otel.%s_endpoint", sig.name)
+
+	u, err := url.Parse(exportURL)
+	if err != nil || u.Host == "" {
+		return CheckResult{
+			ID:      id,
+			Code:    codeOTelConfig,
+			Name:    name,
+			Status:  "fail",
+			Message: fmt.Sprintf("Invalid OTLP endpoint %q", exportURL),
+			Fix:     "Set the endpoint to a full URL such as https://collector.example.com:4318",
+		}
+	}
+
+	port := u.Port()
+	if port == "" {
+		switch {
+		case protocol == "grpc":
+			port = "4317"
+		case u.Scheme == "https":
+			port = "443"
+		default:
+			port = "4318"
+		}
+	}
+
+	// Port 4317 is the OTLP/gRPC convention and 4318 OTLP/HTTP.
+	if (protocol == "grpc" && port == "4318") || (protocol != "grpc" && port == "4317") {
+		return CheckResult{
+			ID:      id,
+			Code:    codeOTelConfig,
+			Name:    name,
+			Status:  "warn",
+			Message: fmt.Sprintf("Protocol %s does not match port %s of %s", protocol, port, u.Host),
+			Fix:     "Use port 4317 with OTEL_EXPORTER_OTLP_PROTOCOL=grpc, or port 4318 with http/protobuf",
+		}
+	}
+
+	address := net.JoinHostPort(u.Hostname(), port)
+	start := time.Now()
+	var conn net.Conn
+	err = env.Engine.do(context.Background(), address, func() (err error) {
+		conn, err = net.DialTimeout("tcp", address, 5*time.Second)
+		return err
+	})
+	logProbe("tcp", u.Hostname(), start, err, "address", address)
+	if err != nil {
+		return CheckResult{
+			ID:      id,
+			Code:    codeOTelUnreachable,
+			Name:    name,
+			Status:  "warn",
+			Message: fmt.Sprintf("Cannot connect to collector %s: %v", address, err),
+			Fix:     "Check that the collector is running and reachable; telemetry is dropped silently otherwise",
+		}
+	}
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		tlsConn.SetDeadline(time.Now().Add(5 * time.Second))
+		err = tlsConn.Handshake()
+		conn = tlsConn
+	}
+	conn.Close()
+	if err != nil {
+		return CheckResult{
+			ID:      id,
+			Code:    codeTLSFailure,
+			Name:    name,
+			Status:  "warn",
+			Message: fmt.Sprintf("TLS handshake with collector %s failed: %v", address, err),
+			Fix:     "Check the collector certificate, or use http:// if the collector does not serve TLS",
+		}
+	}
+
+	if protocol == "grpc" {
+		return CheckResult{
+			ID:      id,
+			Name:    name,
+			Status:  "pass",
+			Message: fmt.Sprintf("Connected to gRPC collector %s", address),
+		}
+	}
+
+	// An empty protobuf body is a valid, empty export request.
+	headerSpec, _ := otelEnv(sig.name, "HEADERS")
+	headers, _ := parseOTLPHeaders(headerSpec)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exportURL, bytes.NewReader(nil))
+	if err != nil {
+		return CheckResult{ID: id, Code: codeOTelConfig, Name: name, Status: "warn", Message: err.Error()}
+	}
+	req.Header = headers
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	start = time.Now()
+	resp, err := env.ProxyHTTP.Do(req)
+	if err != nil {
+		logProbe("otlp_export", exportURL, start, err)
+	} else {
+		logProbe("otlp_export", exportURL, start, nil, "http_status", resp.StatusCode)
+	}
+	if err != nil {
+		fix := "Check the collector URL and protocol"
+		if strings.Contains(err.Error(), "malformed HTTP") {
+			fix = "The collector answered with a non-HTTP/1 response; it is probably gRPC-only. Set OTEL_EXPORTER_OTLP_PROTOCOL=grpc"
+		}
+		return CheckResult{
+			ID:      id,
+			Code:    codeOTelUnreachable,
+			Name:    name,
+			Status:  "warn",
+			Message: fmt.Sprintf("Export request to %s failed: %v", exportURL, err),
+			Fix:     fix,
+		}
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return CheckResult{
+			ID:      id,
+			Code:    codeOTelRejected,
+			Name:    name,
+			Status:  "warn",
+			Message: fmt.Sprintf("Collector %s rejected the export: HTTP %d", exportURL, resp.StatusCode),
+			Fix:     "Set the collector's auth header in OTEL_EXPORTER_OTLP_HEADERS (e.g. Authorization=Bearer%20<token>)",
+		}
+	case resp.StatusCode >= 400:
+		return CheckResult{
+			ID:      id,
+			Code:    codeOTelRejected,
+			Name:    name,
+			Status:  "warn",
+			Message: fmt.Sprintf("Collector %s returned HTTP %d", exportURL, resp.StatusCode),
+			Fix:     "Check the endpoint path (/v1/metrics, /v1/logs) and OTEL_EXPORTER_OTLP_PROTOCOL",
+		}
+	}
+
+	result := CheckResult{
+		ID:      id,
+		Name:    name,
+		Status:  "pass",
+		Message: fmt.Sprintf("Collector %s accepted an empty export (HTTP %d)", exportURL, resp.StatusCode),
+	}
+	if len(headers) == 0 && !isLoopbackHost(u.Hostname()) {
+		result.Status = "warn"
+		result.Code = codeOTelConfig
+		result.Message += "; no OTEL_EXPORTER_OTLP_HEADERS configured for a remote collector"
+		result.Fix = "Remote collectors usually require an auth header; set OTEL_EXPORTER_OTLP_HEADERS if yours does"
+	}
+	return result
+}
@@ -0,0 +1,213 @@
+package probes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// withOTelEnv clears every telemetry variable the check reads, then sets env.
+func withOTelEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{
+		"CLAUDE_CODE_ENABLE_TELEMETRY", "OTEL_METRICS_EXPORTER",
+		"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_HEADERS",
+		"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "OTEL_EXPORTER_OTLP_METRICS_HEADERS",
+		"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL", "OTEL_EXPORTER_OTLP_LOGS_HEADERS",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestOTelEnv(t *testing.T) {
+	withOTelEnv(t, map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":      "http://collector:4318",
+		"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT": "http://logs:4318/v1/logs",
+	})
+	tests := []struct {
+		signal, value, source string
+	}{
+		{"metrics", "http://collector:4318", "OTEL_EXPORTER_OTLP_ENDPOINT"},
+		{"logs", "http://logs:4318/v1/logs", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"},
+	}
+	for _, tt := range tests {
+		value, source := otelEnv(tt.signal, "ENDPOINT")
+		if value != tt.value || source != tt.source {
+			t.Errorf("otelEnv(%s) = %q, %s; want %q, %s", tt.signal, value, source, tt.value, tt.source)
+		}
+	}
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	tests := []struct {
+		spec    string
+		headers map[string]string
+		bad     []string
+	}{
+		{spec: "Authorization=Bearer%20abc, x-team = bcce", headers: map[string]string{"Authorization": "Bearer abc", "X-Team": "bcce"}},
+		{spec: "a=1,,b=2", headers: map[string]string{"A": "1", "B": "2"}},
+		{spec: "novalue,empty=,=orphan,ok=1", headers: map[string]string{"Ok": "1"}, bad: []string{"novalue", "empty", ""}},
+		{spec: "", headers: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			headers, bad := parseOTLPHeaders(tt.spec)
+			got := map[string]string{}
+			for key := range headers {
+				got[key] = headers.Get(key)
+			}
+			if !reflect.DeepEqual(got, tt.headers) {
+				t.Errorf("headers = %v, want %v", got, tt.headers)
+			}
+			if !reflect.DeepEqual(bad, tt.bad) {
+				t.Errorf("bad = %q, want %q", bad, tt.bad)
+			}
+		})
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":             true,
+		"127.0.0.1":             true,
+		"::1":                   true,
+		"10.0.0.1":              false,
+		"collector.example.com": false,
+	} {
+		if got := isLoopbackHost(host); got != want {
+			t.Errorf("isLoopbackHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestOTelResults(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+		}
+		switch {
+		case r.Header.Get("Authorization") == "Bearer bad":
+			w.WriteHeader(http.StatusUnauthorized)
+		case strings.HasPrefix(r.URL.Path, "/v1/"):
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer collector.Close()
+
+	type want struct{ name, status, contains string }
+	tests := []struct {
+		name  string
+		env   map[string]string
+		paths []string
+		want  []want
+	}{
+		{
+			name: "telemetry not configured",
+		},
+		{
+			name: "telemetry on without an endpoint",
+			env:  map[string]string{"CLAUDE_CODE_ENABLE_TELEMETRY": "1"},
+			want: []want{{"OpenTelemetry Endpoint", "warn", "no OTEL_EXPORTER_OTLP_ENDPOINT is set"}},
+		},
+		{
+			name: "console exporter needs no endpoint",
+			env:  map[string]string{"CLAUDE_CODE_ENABLE_TELEMETRY": "1", "OTEL_METRICS_EXPORTER": "console"},
+		},
+		{
+			name:  "generic endpoint gets signal paths",
+			env:   map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL + "/"},
+			paths: []string{"/v1/metrics", "/v1/logs"},
+			want: []want{
+				{"OpenTelemetry Metrics Endpoint", "pass", "accepted an empty export (HTTP 200)"},
+				{"OpenTelemetry Logs Endpoint", "pass", "accepted an empty export (HTTP 200)"},
+			},
+		},
+		{
+			name:  "signal endpoint used as is",
+			env:   map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": collector.URL + "/collect"},
+			paths: []string{"/collect"},
+			want:  []want{{"OpenTelemetry Metrics Endpoint", "warn", "returned HTTP 404"}},
+		},
+		{
+			name: "collector rejects the credentials",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": collector.URL + "/v1/metrics",
+				"OTEL_EXPORTER_OTLP_METRICS_HEADERS":  "Authorization=Bearer%20bad",
+			},
+			paths: []string{"/v1/metrics"},
+			want:  []want{{"OpenTelemetry Metrics Endpoint", "warn", "rejected the export: HTTP 401"}},
+		},
+		{
+			name: "malformed headers",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT": collector.URL + "/v1/logs",
+				"OTEL_EXPORTER_OTLP_HEADERS":       "token",
+			},
+			paths: []string{"/v1/logs"},
+			want: []want{
+				{"OpenTelemetry Logs Endpoint", "pass", "accepted"},
+				{"OpenTelemetry Headers", "warn", "malformed or empty entries: token"},
+			},
+		},
+		{
+			name: "gRPC protocol on the HTTP port",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "http://127.0.0.1:4318", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			want: []want{{"OpenTelemetry Metrics Endpoint", "warn", "Protocol grpc does not match port 4318"}},
+		},
+		{
+			name: "no collector listening",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "http://127.0.0.1:1/v1/metrics"},
+			want: []want{{"OpenTelemetry Metrics Endpoint", "warn", "Cannot connect to collector 127.0.0.1:1"}},
+		},
+		{
+			name: "not a URL",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "collector:4318"},
+			want: []want{{"OpenTelemetry Metrics Endpoint", "fail", `Invalid OTLP endpoint "collector:4318"`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withOTelEnv(t, tt.env)
+			mu.Lock()
+			paths = nil
+			mu.Unlock()
+			got := otelResults(env)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if got[i].Name != w.name || got[i].Status != w.status || !strings.Contains(got[i].Message, w.contains) {
+					t.Errorf("result %d = %q, %s, %q; want %q, %s, containing %q", i, got[i].Name, got[i].Status, got[i].Message, w.name, w.status, w.contains)
+				}
+			}
+			mu.Lock()
+			seen := append([]string(nil), paths...)
+			mu.Unlock()
+			sort.Strings(seen)
+			want := append([]string(nil), tt.paths...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(seen, want) {
+				t.Errorf("collector saw %q, want %q", seen, want)
+			}
+		})
+	}
+}
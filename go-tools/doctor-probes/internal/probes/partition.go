@@ -0,0 +1,90 @@
+package probes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// partition is the subset of the SDK's partition metadata needed to build
+// endpoint hostnames.
+type partition struct {
+	ID                 string
+	RegionPrefixes     []string
+	DNSSuffix          string
+	DualStackDNSSuffix string
+}
+
+// partitions mirrors the SDK's embedded partitions.json; "aws" is the
+// fallback for any region that matches nothing else.
+var partitions = []partition{
+	{ID: "aws-cn", RegionPrefixes: []string{"cn-"}, DNSSuffix: "amazonaws.com.cn", DualStackDNSSuffix: "api.amazonwebservices.com.cn"},
+	{ID: "aws-us-gov", RegionPrefixes: []string{"us-gov-"}, DNSSuffix: "amazonaws.com", DualStackDNSSuffix: "api.aws"},
+	{ID: "aws-iso", RegionPrefixes: []string{"us-iso-"}, DNSSuffix: "c2s.ic.gov", DualStackDNSSuffix: "c2s.ic.gov"},
+	{ID: "aws-iso-b", RegionPrefixes: []string{"us-isob-"}, DNSSuffix: "sc2s.sgov.gov", DualStackDNSSuffix: "sc2s.sgov.gov"},
+	{ID: "aws-iso-e", RegionPrefixes: []string{"eu-isoe-"}, DNSSuffix: "cloud.adc-e.uk", DualStackDNSSuffix: "cloud.adc-e.uk"},
+	{ID: "aws-iso-f", RegionPrefixes: []string{"us-isof-"}, DNSSuffix: "csp.hci.ic.gov", DualStackDNSSuffix: "csp.hci.ic.gov"},
+}
+
+var standardPartition = partition{ID: "aws", DNSSuffix: "amazonaws.com", DualStackDNSSuffix: "api.aws"}
+
+func partitionForRegion(region string) partition {
+	for _, p := range partitions {
+		for _, prefix := range p.RegionPrefixes {
+			if strings.HasPrefix(region, prefix) {
+				return p
+			}
+		}
+	}
+	return standardPartition
+}
+
+// hostname returns the regional endpoint host for service, e.g.
+// "bedrock-runtime.cn-north-1.amazonaws.com.cn".
+func (p partition) hostname(service, region string) string {
+	return fmt.Sprintf("%s.%s.%s", service, region, p.DNSSuffix)
+}
+
+// dualStackHostname returns the dual-stack (IPv4+IPv6) endpoint host for service.
+func (p partition) dualStackHostname(service, region string) string {
+	return fmt.Sprintf("%s.%s.%s", service, region, p.DualStackDNSSuffix)
+}
+
+// fipsService returns the FIPS endpoint prefix for service, e.g. "bedrock-fips".
+func fipsService(service string) string {
+	return service + "-fips"
+}
+
+// Endpoint is one AWS endpoint the connectivity checks probe.
+type Endpoint struct {
+	Name    string // e.g. "Bedrock Runtime"
+	Service string // e.g. "bedrock-runtime", or "bedrock-runtime-fips"
+	Host    string
+	Region  string
+}
+
+// Endpoints returns the Bedrock runtime, Bedrock control plane, and STS
+// endpoints of region, the FIPS ones when fips is set.
+func Endpoints(region string, fips bool) []Endpoint {
+	part := partitionForRegion(region)
+	endpoint := func(name, service string) Endpoint {
+		if fips {
+			service = fipsService(service)
+		}
+		return Endpoint{Name: name, Service: service, Host: part.hostname(service, region), Region: region}
+	}
+	return []Endpoint{
+		endpoint("Bedrock Runtime", "bedrock-runtime"),
+		endpoint("Bedrock Control", "bedrock"),
+		endpoint("STS", "sts"),
+	}
+}
+
+// DualStackHost returns the dual-stack name of e, or "" when e is not the
+// public endpoint of its service (an --endpoint override).
+func (e Endpoint) DualStackHost() string {
+	part := partitionForRegion(e.Region)
+	if e.Host != part.hostname(e.Service, e.Region) {
+		return ""
+	}
+	return part.dualStackHostname(e.Service, e.Region)
+}
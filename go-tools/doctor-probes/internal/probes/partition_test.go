@@ -0,0 +1,61 @@
+package probes
+
+import "testing"
+
+func TestPartitionHostnames(t *testing.T) {
+	tests := []struct {
+		region    string
+		service   string
+		partition string
+		host      string
+		dualStack string
+	}{
+		{"us-east-1", "bedrock-runtime", "aws", "bedrock-runtime.us-east-1.amazonaws.com", "bedrock-runtime.us-east-1.api.aws"},
+		{"eu-central-1", "sts", "aws", "sts.eu-central-1.amazonaws.com", "sts.eu-central-1.api.aws"},
+		{"us-gov-west-1", "bedrock", "aws-us-gov", "bedrock.us-gov-west-1.amazonaws.com", "bedrock.us-gov-west-1.api.aws"},
+		{"cn-north-1", "bedrock-runtime", "aws-cn", "bedrock-runtime.cn-north-1.amazonaws.com.cn", "bedrock-runtime.cn-north-1.api.amazonwebservices.com.cn"},
+		{"us-iso-east-1", "sts", "aws-iso", "sts.us-iso-east-1.c2s.ic.gov", "sts.us-iso-east-1.c2s.ic.gov"},
+		{"us-isob-east-1", "sts", "aws-iso-b", "sts.us-isob-east-1.sc2s.sgov.gov", "sts.us-isob-east-1.sc2s.sgov.gov"},
+		{"eu-isoe-west-1", "sts", "aws-iso-e", "sts.eu-isoe-west-1.cloud.adc-e.uk", "sts.eu-isoe-west-1.cloud.adc-e.uk"},
+		{"us-isof-south-1", "sts", "aws-iso-f", "sts.us-isof-south-1.csp.hci.ic.gov", "sts.us-isof-south-1.csp.hci.ic.gov"},
+		{"us-gov-east-1", fipsService("bedrock-runtime"), "aws-us-gov", "bedrock-runtime-fips.us-gov-east-1.amazonaws.com", "bedrock-runtime-fips.us-gov-east-1.api.aws"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region+"/"+tt.service, func(t *testing.T) {
+			p := partitionForRegion(tt.region)
+			if p.ID != tt.partition {
+				t.Errorf("partition = %s, want %s", p.ID, tt.partition)
+			}
+			if got := p.hostname(tt.service, tt.region); got != tt.host {
+				t.Errorf("hostname = %s, want %s", got, tt.host)
+			}
+			if got := p.dualStackHostname(tt.service, tt.region); got != tt.dualStack {
+				t.Errorf("dualStackHostname = %s, want %s", got, tt.dualStack)
+			}
+		})
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	got := Endpoints("us-gov-west-1", true)
+	want := []string{
+		"Bedrock Runtime bedrock-runtime-fips.us-gov-west-1.amazonaws.com bedrock-runtime-fips.us-gov-west-1.api.aws",
+		"Bedrock Control bedrock-fips.us-gov-west-1.amazonaws.com bedrock-fips.us-gov-west-1.api.aws",
+		"STS sts-fips.us-gov-west-1.amazonaws.com sts-fips.us-gov-west-1.api.aws",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d endpoints, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		if s := e.Name + " " + e.Host + " " + e.DualStackHost(); s != want[i] {
+			t.Errorf("endpoint %d = %s, want %s", i, s, want[i])
+		}
+	}
+
+	// An --endpoint override has no dual-stack name
+	override := got[0]
+	override.Host = "vpce-0abc.bedrock-runtime.us-gov-west-1.vpce.amazonaws.com"
+	if h := override.DualStackHost(); h != "" {
+		t.Errorf("override dual-stack host = %s, want none", h)
+	}
+}
@@ -0,0 +1,145 @@
+package probes
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultPayloadSizes = "1KB,16KB,256KB,1MB"
+
+// ParseSizes parses a comma-separated list like "1KB,16KB,1MB" into bytes,
+// sorted ascending.
+func ParseSizes(spec string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.ToUpper(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		multiplier := 1
+		switch {
+		case strings.HasSuffix(field, "MB"):
+			multiplier = 1 << 20
+			field = strings.TrimSuffix(field, "MB")
+		case strings.HasSuffix(field, "KB"):
+			multiplier = 1 << 10
+			field = strings.TrimSuffix(field, "KB")
+		case strings.HasSuffix(field, "B"):
+			field = strings.TrimSuffix(field, "B")
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid payload size %q", field)
+		}
+		sizes = append(sizes, n*multiplier)
+	}
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no payload sizes given")
+	}
+	sort.Ints(sizes)
+	return sizes, nil
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
+type payloadSample struct {
+	Size     int
+	Duration time.Duration
+	Err      error
+}
+
+// checkPayloadSizes POSTs opaque bodies of increasing size to url. Any HTTP
+// response (typically 403 for an unsigned request) means the bytes made it
+// through; only transport errors count as failures. It stops at the first
+// failing size since larger payloads will fail the same way.
+func checkPayloadSizes(env Env, url string, sizes []int) []payloadSample {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: env.Engine.limited(&http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+			}).DialContext,
+		}),
+	}
+
+	var samples []payloadSample
+	for _, size := range sizes {
+		start := time.Now()
+		resp, err := client.Post(url, "application/octet-stream", bytes.NewReader(make([]byte, size)))
+		sample := payloadSample{Size: size, Duration: time.Since(start), Err: err}
+		logProbe("payload", url, start, err, "size", size)
+		if err == nil {
+			resp.Body.Close()
+		}
+		samples = append(samples, sample)
+		if err != nil {
+			break
+		}
+	}
+	return samples
+}
+
+func payloadProbeResult(env Env, url string, sizes []int) CheckResult {
+	samples := checkPayloadSizes(env, url, sizes)
+
+	var curve []string
+	metrics := make(map[string]float64)
+	largest := 0
+	var failed *payloadSample
+	for i, s := range samples {
+		if s.Err != nil {
+			failed = &samples[i]
+			continue
+		}
+		largest = s.Size
+		curve = append(curve, fmt.Sprintf("%s %s", formatSize(s.Size), formatMs(s.Duration)))
+		metrics[fmt.Sprintf("payload_%s_ms", formatSize(s.Size))] = DurationMs(s.Duration)
+	}
+
+	if failed == nil {
+		return CheckResult{
+			ID:      "payload.bedrock_runtime",
+			Name:    "Large Payload Probe",
+			Status:  "pass",
+			Message: fmt.Sprintf("All payload sizes completed: %s", strings.Join(curve, ", ")),
+			Metrics: metrics,
+		}
+	}
+
+	if largest == 0 {
+		return CheckResult{
+			ID:      "payload.bedrock_runtime",
+			Code:    classifyNetError(failed.Err),
+			Name:    "Large Payload Probe",
+			Status:  "fail",
+			Message: fmt.Sprintf("Even a %s payload failed: %v", formatSize(failed.Size), failed.Err),
+			Fix:     "Check firewall, proxy settings, or VPC endpoint configuration",
+		}
+	}
+
+	return CheckResult{
+		ID:     "payload.bedrock_runtime",
+		Code:   codePayloadMTU,
+		Name:   "Large Payload Probe",
+		Status: "fail",
+		Message: fmt.Sprintf("Largest payload completed: %s (%s); %s failed: %v",
+			formatSize(largest), strings.Join(curve, ", "), formatSize(failed.Size), failed.Err),
+		Fix:     "Small requests work but large ones hang: likely a path MTU black hole. Lower the VPN interface MTU or enable TCP MSS clamping on the VPN/firewall",
+		Metrics: metrics,
+	}
+}
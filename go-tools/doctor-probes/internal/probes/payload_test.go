@@ -0,0 +1,129 @@
+package probes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSizes(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []int
+		err  string
+	}{
+		{spec: DefaultPayloadSizes, want: []int{1 << 10, 16 << 10, 256 << 10, 1 << 20}},
+		{spec: "2mb, 512b ,3", want: []int{3, 512, 2 << 20}},
+		{spec: "16KB,,1KB", want: []int{1 << 10, 16 << 10}},
+		{spec: "", err: "no payload sizes given"},
+		{spec: "0KB", err: `invalid payload size "0"`},
+		{spec: "1GB", err: `invalid payload size "1G"`},
+		{spec: "-5", err: `invalid payload size "-5"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSizes(tt.spec)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{512, "512B"},
+		{1 << 10, "1KB"},
+		{1536, "1536B"},
+		{256 << 10, "256KB"},
+		{2 << 20, "2MB"},
+		{(1 << 20) + (1 << 10), "1025KB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+// payloadServer answers POSTs up to limit bytes with 403, as AWS does for an
+// unsigned request, and drops the connection on anything larger.
+func payloadServer(t *testing.T, limit int64) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestPayloadProbeResult(t *testing.T) {
+	sizes := []int{1 << 10, 16 << 10, 256 << 10}
+	tests := []struct {
+		name     string
+		limit    int64
+		status   string
+		contains []string
+		metrics  int
+	}{
+		{
+			name:     "every size gets through",
+			limit:    1 << 20,
+			status:   "pass",
+			contains: []string{"All payload sizes completed: 1KB ", ", 256KB "},
+			metrics:  3,
+		},
+		{
+			name:     "black hole above 16KB",
+			limit:    16 << 10,
+			status:   "fail",
+			contains: []string{"Largest payload completed: 16KB", "256KB failed:", "MTU black hole"},
+			metrics:  2,
+		},
+		{
+			name:     "nothing gets through",
+			limit:    0,
+			status:   "fail",
+			contains: []string{"Even a 1KB payload failed:", "Check firewall"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			got := payloadProbeResult(env, payloadServer(t, tt.limit), sizes)
+			if got.Name != "Large Payload Probe" || got.Status != tt.status {
+				t.Errorf("name, status = %q, %s; want Large Payload Probe, %s", got.Name, got.Status, tt.status)
+			}
+			text := got.Message + " " + got.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if len(got.Metrics) != tt.metrics {
+				t.Errorf("metrics = %v, want %d sizes", got.Metrics, tt.metrics)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultPluginTimeout bounds each plugin run unless the config sets
+// plugin_timeout.
+const defaultPluginTimeout = 30 * time.Second
+
+//go:embed schema/plugin.schema.json
+var PluginSchema []byte
+
+// pluginInput is the JSON document written to a plugin's stdin.
+type pluginInput struct {
+	SchemaVersion string          `json:"schema_version"`
+	ToolVersion   string          `json:"tool_version"`
+	Region        string          `json:"region"`
+	Profile       string          `json:"profile"`
+	Environment   HostEnvironment `json:"environment"`
+	Endpoint      string          `json:"bedrock_endpoint,omitempty"`
+	Results       []CheckResult   `json:"results"` // checks run so far
+}
+
+// pluginInputVersion is the version of the pluginInput contract.
+const pluginInputVersion = "1"
+
+var pluginCheckID = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// pluginPaths lists the executables in ~/.bcce/doctor.d, sorted, followed by
+// the plugins named in the config.
+func pluginPaths(cfg *DoctorConfig) []string {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dir := filepath.Join(home, ".bcce", "doctor.d")
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") || e.IsDir() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || !isExecutable(info) {
+				continue
+			}
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+		sort.Strings(paths)
+	}
+	if cfg != nil {
+		paths = append(paths, cfg.Plugins...)
+	}
+	return paths
+}
+
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// pluginResults runs every plugin with the results recorded so far. A
+// plugin that fails, times out, or prints invalid output becomes one failed
+// check; it never stops the run.
+func pluginResults(env Env, cfg *DoctorConfig, region string, sofar []CheckResult, toolVersion string) []CheckResult {
+	paths := pluginPaths(cfg)
+	if len(paths) == 0 {
+		return nil
+	}
+	timeout := defaultPluginTimeout
+	if cfg != nil && cfg.PluginTimeout > 0 {
+		timeout = cfg.PluginTimeout
+	}
+	input := pluginInput{
+		SchemaVersion: pluginInputVersion,
+		ToolVersion:   toolVersion,
+		Region:        region,
+		Profile:       ActiveProfile(),
+		Environment:   env.Host,
+		Results:       sofar,
+	}
+	if env.Endpoint != nil {
+		input.Endpoint = env.Endpoint.String()
+	}
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return nil
+	}
+
+	var results []CheckResult
+	for _, path := range paths {
+		results = append(results, runPlugin(path, stdin, timeout)...)
+	}
+	return results
+}
+
+func runPlugin(path string, stdin []byte, timeout time.Duration) []CheckResult {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	failed := CheckResult{
+		ID:     "plugin:" + checkID("plugin", name),
+		Name:   "Plugin " + name,
+		Status: "fail",
+		Code:   codePluginFailed,
+		Fix:    fmt.Sprintf("Run %s by hand (see doctor-probes --plugin-schema for its input) or ask its owner; remove it from ~/.bcce/doctor.d to disable it", path),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait on grandchildren that keep the pipes open.
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	logProbe("plugin", path, start, err, "stderr", RedactSecrets(strings.TrimSpace(stderr.String())))
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		failed.Message = fmt.Sprintf("%s did not finish within %s", path, timeout)
+		return []CheckResult{failed}
+	case err != nil:
+		failed.Message = fmt.Sprintf("%s failed: %v", path, err)
+		if msg := lastLine(stderr.String()); msg != "" {
+			failed.Message += ": " + RedactSecrets(msg)
+		}
+		return []CheckResult{failed}
+	}
+
+	results, err := parsePluginOutput(stdout.Bytes())
+	if err != nil {
+		failed.Message = fmt.Sprintf("%s printed invalid output: %v", path, err)
+		return []CheckResult{failed}
+	}
+	return results
+}
+
+// parsePluginOutput decodes a sequence of CheckResult objects, or arrays of
+// them, and validates each.
+func parsePluginOutput(out []byte) ([]CheckResult, error) {
+	dec := json.NewDecoder(bytes.NewReader(out))
+	var results []CheckResult
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var batch []CheckResult
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, err
+			}
+		} else {
+			var r CheckResult
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return nil, err
+			}
+			batch = []CheckResult{r}
+		}
+		results = append(results, batch...)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no check results")
+	}
+
+	for i := range results {
+		r := &results[i]
+		switch {
+		case !pluginCheckID.MatchString(r.ID):
+			return nil, fmt.Errorf("result %d: id %q must match %s", i, r.ID, pluginCheckID)
+		case r.Name == "":
+			return nil, fmt.Errorf("result %d (%s): name is required", i, r.ID)
+		case r.Status != "pass" && r.Status != "warn" && r.Status != "fail":
+			return nil, fmt.Errorf("result %d (%s): status %q is not pass, warn, or fail", i, r.ID, r.Status)
+		}
+		r.ID = "plugin:" + r.ID
+		// Severity and timing are the host's to compute.
+		r.Severity, r.DurationMs = "", 0
+		if r.Status != "pass" && r.Code == "" {
+			r.Code = codePluginCheck
+		}
+	}
+	return results, nil
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
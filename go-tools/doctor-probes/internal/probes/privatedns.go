@@ -0,0 +1,112 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// route53ResolverLinkLocal is the Route 53 Resolver address every VPC
+// answers on, next to the CIDR base plus two.
+const route53ResolverLinkLocal = "169.254.169.253"
+
+// vpcResolver returns the VPC's Route 53 Resolver ("host:53"): the primary
+// CIDR base plus two, read from instance metadata, or the link-local address
+// when metadata does not say.
+func vpcResolver(env Env, region string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fallback := net.JoinHostPort(route53ResolverLinkLocal, "53")
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return fallback
+	}
+	client := env.IMDS(cfg)
+	get := func(path string) string {
+		out, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+		if err != nil {
+			return ""
+		}
+		defer out.Content.Close()
+		data, _ := io.ReadAll(out.Content)
+		return strings.TrimSpace(string(data))
+	}
+	mac := get("mac")
+	if mac == "" {
+		return fallback
+	}
+	prefix, err := netip.ParsePrefix(get("network/interfaces/macs/" + mac + "/vpc-ipv4-cidr-block"))
+	if err != nil || !prefix.Addr().Is4() {
+		return fallback
+	}
+	base := prefix.Masked().Addr().As4()
+	base[3] += 2
+	return net.JoinHostPort(netip.AddrFrom4(base).String(), "53")
+}
+
+// privateDNSResults checks, for PrivateLink deployments, that host resolves
+// to the private addresses of a VPC interface endpoint, and on EC2 compares
+// the system resolver with the VPC's Route 53 Resolver to tell a disabled
+// private DNS setting from a DNS server that does not forward to Route 53.
+// It runs with --privatelink, a vpce endpoint URL, or on EC2, and returns
+// nothing otherwise.
+func privateDNSResults(env Env, region, host string, forced bool) []CheckResult {
+	expectPrivate := forced || env.IsVPCEndpoint()
+	inVPC := env.Host.Cloud == "EC2"
+	if !expectPrivate && !inVPC {
+		return nil
+	}
+	result := CheckResult{ID: "privatelink.private_dns", Name: "PrivateLink DNS"}
+
+	start := time.Now()
+	addrs, err := lookupWith(env.ResolverFor(""), host)
+	logProbe("dns", host, start, err, "resolver", "system", "addrs", addrs)
+	system := dnsAnswer{Resolver: "system", Addrs: addrs, Err: err}
+	systemKind := answerKind(addrs)
+	answers := []string{system.String()}
+
+	vpcKind := ""
+	var server string
+	if inVPC {
+		server = vpcResolver(env, region)
+		start := time.Now()
+		addrs, err := lookupWith(NewResolver(server), host)
+		logProbe("dns", host, start, err, "resolver", server, "addrs", addrs)
+		vpc := dnsAnswer{Resolver: "VPC resolver " + server, Addrs: addrs, Err: err}
+		answers = append(answers, vpc.String())
+		if err == nil {
+			vpcKind = answerKind(addrs)
+		}
+	}
+	result.Message = strings.Join(answers, "; ")
+
+	switch {
+	case systemKind == "private":
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s resolves to private addresses, so Bedrock traffic stays on the VPC interface endpoint (%s)", host, result.Message)
+	case vpcKind == "private":
+		result.Status, result.Code = "fail", codePrivateDNSNotForwarded
+		result.Message = fmt.Sprintf("The VPC resolver returns the interface endpoint's private addresses for %s, but this system's DNS server does not (%s)", host, result.Message)
+		result.Fix = fmt.Sprintf("The DNS server in use does not forward to the Route 53 Resolver: forward amazonaws.com (or at least %s) to %s, or to a Route 53 Resolver inbound endpoint from on-premises DNS", host, server)
+	case expectPrivate:
+		result.Status, result.Code = "fail", codePrivateDNSDisabled
+		result.Message = fmt.Sprintf("%s does not resolve to a VPC interface endpoint (%s); traffic goes to the public endpoint, which VPC-only networks block", host, result.Message)
+		if system.Err != nil {
+			result.Message = fmt.Sprintf("%s does not resolve to a VPC interface endpoint (%s)", host, result.Message)
+		}
+		result.Fix = "Enable private DNS on the bedrock-runtime interface endpoint (aws ec2 modify-vpc-endpoint --vpc-endpoint-id <vpce-id> --private-dns-enabled), or associate its private hosted zone with this VPC"
+		if !inVPC {
+			result.Fix += "; from outside the VPC, also forward amazonaws.com to a Route 53 Resolver inbound endpoint"
+		}
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s resolves publicly in this VPC, so Bedrock traffic uses the public endpoint; no interface endpoint with private DNS applies (%s)", host, result.Message)
+	}
+	return []CheckResult{result}
+}
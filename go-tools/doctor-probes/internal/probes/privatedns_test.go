@@ -0,0 +1,141 @@
+package probes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestVPCResolver(t *testing.T) {
+	tests := []struct {
+		name string
+		mac  string
+		cidr string
+		want string
+	}{
+		{name: "CIDR base plus two", mac: "0a:1b:2c:3d:4e:5f", cidr: "10.20.0.0/16", want: "10.20.0.2:53"},
+		{name: "unmasked CIDR", mac: "0a:1b:2c:3d:4e:5f", cidr: "172.31.5.9/20", want: "172.31.0.2:53"},
+		{name: "no MAC", want: "169.254.169.253:53"},
+		{name: "IPv6 only", mac: "0a:1b:2c:3d:4e:5f", cidr: "2600:1f18::/56", want: "169.254.169.253:53"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, nil)
+			t.Setenv("AWS_EC2_METADATA_DISABLED", "false")
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/latest/api/token":
+					fmt.Fprint(w, "token")
+				case "/latest/meta-data/mac":
+					if tt.mac == "" {
+						http.NotFound(w, r)
+						return
+					}
+					fmt.Fprint(w, tt.mac)
+				case "/latest/meta-data/network/interfaces/macs/" + tt.mac + "/vpc-ipv4-cidr-block":
+					fmt.Fprint(w, tt.cidr)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", srv.URL)
+
+			if got := vpcResolver(env, "us-east-1"); got != tt.want {
+				t.Errorf("vpcResolver = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrivateDNSResults(t *testing.T) {
+	// IP literals and localhost resolve without a DNS server.
+	tests := []struct {
+		name     string
+		host     string
+		forced   bool
+		endpoint string
+		cloud    string
+		status   string // empty means no result
+		code     string
+		contains []string
+	}{
+		{name: "not PrivateLink, not EC2", host: "192.0.2.10"},
+		{
+			name:     "private answer",
+			host:     "localhost",
+			forced:   true,
+			status:   "pass",
+			contains: []string{"localhost resolves to private addresses, so Bedrock traffic stays on the VPC interface endpoint"},
+		},
+		{
+			name:     "vpce endpoint resolves publicly",
+			host:     "192.0.2.10",
+			endpoint: "https://vpce-0abc.bedrock-runtime.us-east-1.vpce.amazonaws.com",
+			status:   "fail",
+			code:     codePrivateDNSDisabled,
+			contains: []string{"192.0.2.10 does not resolve to a VPC interface endpoint", "--private-dns-enabled", "from outside the VPC, also forward amazonaws.com"},
+		},
+		{
+			name:     "forced, no answer",
+			host:     "nowhere.invalid",
+			forced:   true,
+			status:   "fail",
+			code:     codePrivateDNSDisabled,
+			contains: []string{"nowhere.invalid does not resolve to a VPC interface endpoint"},
+		},
+		{
+			name:     "public in a VPC",
+			host:     "192.0.2.10",
+			cloud:    "EC2",
+			status:   "pass",
+			contains: []string{"resolves publicly in this VPC", "VPC resolver 169.254.169.253:53"},
+		},
+		{
+			name:     "forced in a VPC",
+			host:     "192.0.2.10",
+			forced:   true,
+			cloud:    "EC2",
+			status:   "fail",
+			code:     codePrivateDNSDisabled,
+			contains: []string{"--private-dns-enabled"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, nil)
+			withEndpoint(t, &env, tt.endpoint)
+			env.Host.Cloud = tt.cloud
+
+			got := privateDNSResults(env, "us-east-1", tt.host, tt.forced)
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "privatelink.private_dns" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if tt.cloud == "EC2" && strings.Contains(r.Fix, "from outside the VPC") {
+				t.Errorf("fix %q gives outside-VPC advice inside a VPC", r.Fix)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package probes
+
+import (
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// NewEnv returns the Env that reaches the real DNS, HTTPS endpoints, and AWS
+// with settings s. Every request goes through s.Engine, and the Bedrock
+// clients target s.Endpoint when it is set. Tests replace the fields they
+// fake.
+func NewEnv(s RunSettings) Env {
+	if s.Engine == nil {
+		s.Engine = NewProbeEngine(DefaultMaxConcurrency, DefaultMaxHostRate, 0)
+	}
+	if s.Host.OS == "" {
+		s.Host.OS = runtime.GOOS
+	}
+	e := s.Engine
+	resolverFor := func(server string) Resolver {
+		if server == "" {
+			return limitedResolver{engine: e, base: net.DefaultResolver}
+		}
+		return limitedResolver{engine: e, base: NewResolver(server)}
+	}
+	endpoint := s.Endpoint
+	return Env{
+		RunSettings: s,
+		Resolver:    resolverFor(s.DNSServer),
+		ResolverFor: resolverFor,
+		HTTP:        func(host string) HTTPDoer { return probeHTTPClient(e, host) },
+		ProxyHTTP:   &http.Client{Transport: e.limited(nil)},
+		Bedrock: func(cfg aws.Config) BedrockClient {
+			return bedrock.NewFromConfig(e.limitedConfig(cfg), func(o *bedrock.Options) {
+				if endpoint != nil {
+					o.BaseEndpoint = aws.String(endpoint.String())
+				}
+			})
+		},
+		BedrockRuntime: func(cfg aws.Config, optFns ...func(*bedrockruntime.Options)) BedrockRuntimeClient {
+			return bedrockruntime.NewFromConfig(e.limitedConfig(cfg), append([]func(*bedrockruntime.Options){func(o *bedrockruntime.Options) {
+				if endpoint != nil {
+					o.BaseEndpoint = aws.String(endpoint.String())
+				}
+			}}, optFns...)...)
+		},
+		STS:           func(cfg aws.Config) STSClient { return sts.NewFromConfig(e.limitedConfig(cfg)) },
+		IAM:           func(cfg aws.Config) IAMClient { return iam.NewFromConfig(e.limitedConfig(cfg)) },
+		ServiceQuotas: func(cfg aws.Config) ServiceQuotasClient { return servicequotas.NewFromConfig(e.limitedConfig(cfg)) },
+		CloudWatch:    func(cfg aws.Config) CloudWatchClient { return cloudwatch.NewFromConfig(e.limitedConfig(cfg)) },
+		S3:            func(cfg aws.Config) S3Client { return s3.NewFromConfig(e.limitedConfig(cfg)) },
+		IMDS:          func(cfg aws.Config) IMDSClient { return imds.NewFromConfig(e.limitedConfig(cfg)) },
+
+		awsConfigs: newAWSConfigCache(),
+		run:        &runState{},
+	}
+}
+
+// probeHTTPClient returns a client with a fresh connection for one probe of
+// host. It connects directly or, when ALL_PROXY names one, through a SOCKS
+// proxy, and reports redirects rather than following them: a redirect is
+// never an AWS answer to HEAD.
+func probeHTTPClient(e *ProbeEngine, host string) HTTPDoer {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		DialContext:       dialer.DialContext,
+		DisableKeepAlives: true,
+	}
+	if socks, _ := probeProxy(host); isSOCKS(socks) {
+		transport.DialContext = socksDialContext(socks, dialer)
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: e.limited(transport),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
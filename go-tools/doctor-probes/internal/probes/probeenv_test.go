@@ -0,0 +1,53 @@
+package probes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestCheckDNSUsesProbeEnv(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	env.Resolver = &probestest.Resolver{Addrs: []string{"192.0.2.10"}}
+	if addrs, err := checkDNS(env, "bedrock-runtime.us-east-1.amazonaws.com"); err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.10" {
+		t.Errorf("checkDNS = %v, %v; want the fake resolver's answer", addrs, err)
+	}
+
+	env.Resolver = &probestest.Resolver{Err: errors.New("no such host")}
+	if _, err := checkDNS(env, "bedrock-runtime.us-east-1.amazonaws.com"); err == nil || !strings.Contains(err.Error(), "no such host") {
+		t.Errorf("err = %v, want the fake resolver's error", err)
+	}
+}
+
+func TestProbeHTTPClient(t *testing.T) {
+	withProxyEnv(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodHead, srv.URL, nil)
+	resp, err := probeHTTPClient(NewProbeEngine(DefaultMaxConcurrency, DefaultMaxHostRate, 0), "127.0.0.1").Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("status = %d, want the redirect itself", resp.StatusCode)
+	}
+
+	proxy := newSOCKSServer(t)
+	withProxyEnv(t, map[string]string{"ALL_PROXY": "socks5h://" + proxy.addr})
+	req, _ = http.NewRequest(http.MethodHead, srv.URL, nil)
+	if resp, err = probeHTTPClient(NewProbeEngine(DefaultMaxConcurrency, DefaultMaxHostRate, 0), "127.0.0.1").Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := proxy.requested(); len(got) != 1 || got[0] != strings.TrimPrefix(srv.URL, "http://") {
+		t.Errorf("SOCKS proxy was asked for %v, want %s", got, srv.URL)
+	}
+}
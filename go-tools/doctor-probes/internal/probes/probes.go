@@ -0,0 +1,46 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// LookupHost resolves host with the environment's resolver, giving up after
+// 10 seconds.
+func LookupHost(env Env, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return env.Resolver.LookupHost(ctx, host)
+}
+
+// errNoModels is returned when a region offers no Anthropic models.
+var errNoModels = errors.New("no Anthropic models available")
+
+// AnthropicModels lists the Anthropic models offered in cfg's region with a
+// signed, read-only ListFoundationModels call. No models is an error.
+func AnthropicModels(ctx context.Context, env Env, cfg aws.Config) ([]bedrocktypes.FoundationModelSummary, error) {
+	input := &bedrock.ListFoundationModelsInput{
+		ByProvider: aws.String("anthropic"),
+	}
+	result, err := env.Bedrock(cfg).ListFoundationModels(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock API call failed: %w", err)
+	}
+	if len(result.ModelSummaries) == 0 {
+		return nil, fmt.Errorf("%w in region %s", errNoModels, cfg.Region)
+	}
+	return result.ModelSummaries, nil
+}
+
+// CallerIdentity returns the identity cfg's credentials belong to.
+func CallerIdentity(ctx context.Context, env Env, cfg aws.Config) (*sts.GetCallerIdentityOutput, error) {
+	return env.STS(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+}
@@ -0,0 +1,70 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestLookupHost(t *testing.T) {
+	resolver := &probestest.Resolver{Addrs: []string{"192.0.2.1"}}
+	addrs, err := LookupHost(fakeEnv(resolver, nil, nil), "sts.us-east-1.amazonaws.com")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"192.0.2.1"}) {
+		t.Errorf("LookupHost = %v, %v; want the resolver's answer", addrs, err)
+	}
+	if !reflect.DeepEqual(resolver.Hosts, []string{"sts.us-east-1.amazonaws.com"}) {
+		t.Errorf("looked up %v", resolver.Hosts)
+	}
+}
+
+func TestAnthropicModels(t *testing.T) {
+	haiku := bedrocktypes.FoundationModelSummary{ModelId: aws.String("anthropic.claude-3-haiku")}
+	tests := []struct {
+		name    string
+		bedrock *probestest.Bedrock
+		want    int
+		err     string
+	}{
+		{name: "models", bedrock: &probestest.Bedrock{Models: []bedrocktypes.FoundationModelSummary{haiku}}, want: 1},
+		{name: "none offered", bedrock: &probestest.Bedrock{}, err: "no Anthropic models available in region us-east-1"},
+		{name: "call fails", bedrock: &probestest.Bedrock{Err: errors.New("AccessDeniedException")}, err: "bedrock API call failed: AccessDeniedException"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := fakeEnv(nil, tt.bedrock, nil)
+			got, err := AnthropicModels(context.Background(), env, aws.Config{Region: "us-east-1"})
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("err = %v, want %s", err, tt.err)
+				}
+			} else if err != nil || len(got) != tt.want {
+				t.Errorf("AnthropicModels = %d models, %v; want %d", len(got), err, tt.want)
+			}
+			if p := tt.bedrock.Input; p == nil || aws.ToString(p.ByProvider) != "anthropic" {
+				t.Errorf("ListFoundationModels input = %+v, want ByProvider anthropic", p)
+			}
+		})
+	}
+}
+
+func TestCallerIdentity(t *testing.T) {
+	arn := "arn:aws:sts::123456789012:assumed-role/dev/alice"
+	env := fakeEnv(nil, nil, &probestest.STS{Identity: &sts.GetCallerIdentityOutput{Arn: aws.String(arn)}})
+	got, err := CallerIdentity(context.Background(), env, aws.Config{})
+	if err != nil || aws.ToString(got.Arn) != arn {
+		t.Errorf("CallerIdentity = %+v, %v; want %s", got, err, arn)
+	}
+
+	env = fakeEnv(nil, nil, &probestest.STS{Err: errors.New("ExpiredToken")})
+	if _, err := CallerIdentity(context.Background(), env, aws.Config{}); err == nil || !strings.Contains(err.Error(), "ExpiredToken") {
+		t.Errorf("err = %v, want the STS error", err)
+	}
+}
@@ -0,0 +1,109 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+)
+
+// Prefixes of system-defined cross-region inference profile IDs.
+var crossRegionPrefixes = []string{"us.", "eu.", "apac.", "us-gov.", "global."}
+
+// isInferenceProfile reports whether model names an inference profile rather
+// than a single-region foundation model.
+func isInferenceProfile(model string) bool {
+	if strings.HasPrefix(model, "arn:") {
+		return strings.Contains(model, ":inference-profile/") || strings.Contains(model, ":application-inference-profile/")
+	}
+	for _, prefix := range crossRegionPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// profileRegions returns the regions of the profile's member models, as
+// reported by GetInferenceProfile.
+func profileRegions(env Env, region, profile string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	out, err := env.Bedrock(cfg).GetInferenceProfile(ctx, &bedrock.GetInferenceProfileInput{
+		InferenceProfileIdentifier: aws.String(profile),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock API call failed: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var regions []string
+	for _, m := range out.Models {
+		parsed, err := arn.Parse(aws.ToString(m.ModelArn))
+		if err != nil || parsed.Region == "" || seen[parsed.Region] {
+			continue
+		}
+		seen[parsed.Region] = true
+		regions = append(regions, parsed.Region)
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// profileRegionsResults probes the runtime endpoint of every member region of
+// the configured inference profile, since requests may be served from any of
+// them. It returns nothing when the model is not a profile or an endpoint
+// override routes all traffic through one endpoint.
+func profileRegionsResults(env Env, region string) []CheckResult {
+	model := configuredModel()
+	if !isInferenceProfile(model) || env.Endpoint != nil {
+		return nil
+	}
+	result := CheckResult{ID: "bedrock.profile_regions", Name: "Inference Profile Regions"}
+
+	regions, err := profileRegions(env, region, model)
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Cannot list the member regions of %s: %v", model, err)
+		result.Fix = "Add bedrock:GetInferenceProfile permission to your IAM role/user"
+		result.setAWSError(err, "bedrock:GetInferenceProfile")
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return []CheckResult{result}
+	}
+
+	var reached, unreachable, hosts []string
+	var firstErr error
+	for _, r := range regions {
+		host, err := probeRuntimeRegion(env, r)
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%v)", r, err))
+			hosts = append(hosts, host)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		reached = append(reached, r)
+	}
+
+	if len(unreachable) == 0 {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("All %d member regions of %s are reachable: %s", len(regions), model, strings.Join(reached, ", "))
+		return []CheckResult{result}
+	}
+	result.Status, result.Code = "warn", classifyNetError(firstErr)
+	result.Message = fmt.Sprintf("%d of %d member regions of %s are unreachable, intermittent failures likely: %s",
+		len(unreachable), len(regions), model, strings.Join(unreachable, "; "))
+	result.Fix = "Allow these endpoints through the firewall/proxy: " + strings.Join(hosts, ", ")
+	return []CheckResult{result}
+}
@@ -0,0 +1,127 @@
+package probes
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestIsInferenceProfile(t *testing.T) {
+	tests := map[string]bool{
+		"": false,
+		"anthropic.claude-3-5-haiku-20241022-v1:0":                                false,
+		"us.anthropic.claude-3-5-haiku-20241022-v1:0":                             true,
+		"global.anthropic.claude-sonnet-4-20250514-v1:0":                          true,
+		"arn:aws:bedrock:us-east-1:123456789012:inference-profile/eu.anthropic.x": true,
+		"arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/ab": true,
+		"arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-3-haiku":    false,
+	}
+	for model, want := range tests {
+		if got := isInferenceProfile(model); got != want {
+			t.Errorf("isInferenceProfile(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
+
+// profileMembers answers GetInferenceProfile with models in the given
+// regions; an empty list denies the call.
+func profileMembers(t *testing.T, profile string, regions ...string) {
+	t.Helper()
+	probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+		id, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/inference-profiles/"))
+		if id != profile {
+			t.Errorf("GetInferenceProfile(%q), want %q", id, profile)
+		}
+		if len(regions) == 0 {
+			probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+			return
+		}
+		var models []string
+		for _, region := range regions {
+			models = append(models, `{"modelArn":"arn:aws:bedrock:`+region+`::foundation-model/anthropic.claude-x"}`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"inferenceProfileId":"` + profile + `","models":[` + strings.Join(models, ",") + `]}`))
+	})
+}
+
+func TestProfileRegions(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	const profile = "us.anthropic.claude-x"
+	profileMembers(t, profile, "us-west-2", "us-east-1", "us-west-2", "us-east-2")
+	got, err := profileRegions(env, "us-east-1", profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"us-east-1", "us-east-2", "us-west-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("profileRegions = %v, want sorted and deduplicated %v", got, want)
+	}
+}
+
+func TestProfileRegionsResults(t *testing.T) {
+	const profile = "us.anthropic.claude-x"
+	tests := []struct {
+		name     string
+		model    string
+		endpoint string
+		regions  []string
+		status   string // empty means no result
+		code     string
+		contains []string
+	}{
+		{name: "foundation model", model: "anthropic.claude-x"},
+		{name: "endpoint override", model: profile, endpoint: "https://vpce-1.bedrock-runtime.us-east-1.vpce.amazonaws.com"},
+		{
+			name:     "GetInferenceProfile denied",
+			model:    profile,
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Cannot list the member regions of " + profile, "bedrock:GetInferenceProfile"},
+		},
+		{
+			name:    "unreachable members",
+			model:   profile,
+			regions: []string{"nowhere-2", "nowhere-1"},
+			status:  "warn",
+			code:    codeDNSNXDomain,
+			contains: []string{
+				"2 of 2 member regions of " + profile + " are unreachable",
+				"nowhere-1 (DNS:",
+				"bedrock-runtime.nowhere-1.amazonaws.com, bedrock-runtime.nowhere-2.amazonaws.com",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			profileMembers(t, tt.model, tt.regions...)
+			withEndpoint(t, &env, tt.endpoint)
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			got := profileRegionsResults(env, "us-east-1")
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "bedrock.profile_regions" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package probes
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// routeClient returns a probeHTTPClient-style factory that connects through
+// proxy, or directly when proxy is nil, ignoring the environment.
+func routeClient(env Env, proxy *url.URL) func(host string) HTTPDoer {
+	return func(string) HTTPDoer {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		transport := &http.Transport{
+			DialContext:       dialer.DialContext,
+			DisableKeepAlives: true,
+		}
+		if isSOCKS(proxy) {
+			transport.DialContext = socksDialContext(proxy, dialer)
+		} else if proxy != nil {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		return &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: env.Engine.limited(transport),
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+}
+
+// proxyOverheadResult times the Bedrock runtime through Claude Code's proxy
+// and, unless direct is false, with the proxy bypassed, and reports what the
+// proxy costs: nothing worth noting, more than threshold (a NO_PROXY
+// candidate, where policy allows), or nothing to compare because the direct
+// path is blocked, which strict environments expect. The direct attempt is
+// labeled as such in the message and the probe log.
+func proxyOverheadResult(env Env, bedrockURL string, threshold time.Duration, direct bool) CheckResult {
+	result := CheckResult{ID: "network.proxy_overhead", Name: "Proxy Overhead"}
+	host, _, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status = "skip"
+		result.Message = err.Error()
+		return result
+	}
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+	if proxy == nil {
+		result.Status = "skip"
+		result.Message = fmt.Sprintf("No proxy is configured for %s", host)
+		return result
+	}
+
+	via := proxyDisplay(proxy)
+	proxied, _, err := sampleLatency(bedrockURL, routeClient(env, proxy))
+	if err != nil {
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("Could not time %s via %s: %v", bedrockURL, via, err)
+		result.Fix = "See HTTPS Connectivity" + env.Host.proxyHint()
+		return result
+	}
+	result.Metrics = map[string]float64{
+		"proxied_ttfb_ms":    DurationMs(proxied.TTFB),
+		"proxied_connect_ms": DurationMs(proxied.Connect),
+		"proxied_tls_ms":     DurationMs(proxied.TLS),
+	}
+	summary := fmt.Sprintf("via %s: %s", via, proxied)
+	if !direct {
+		result.Status = "pass"
+		result.Message = summary + "; direct probe (bypassing the proxy) skipped by --no-direct-probe"
+		return result
+	}
+
+	debugLog.Debug("direct probe, bypassing the proxy", "url", bedrockURL, "proxy", via)
+	bypass, _, err := sampleLatency(bedrockURL, routeClient(env, nil))
+	if err != nil {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s; direct probe (bypassing the proxy) blocked: %v. This is expected where all egress must go through the proxy", summary, err)
+		return result
+	}
+	overhead := proxied.TTFB - bypass.TTFB
+	result.Metrics["direct_ttfb_ms"] = DurationMs(bypass.TTFB)
+	result.Metrics["direct_connect_ms"] = DurationMs(bypass.Connect)
+	result.Metrics["direct_tls_ms"] = DurationMs(bypass.TLS)
+	result.Metrics["overhead_ms"] = DurationMs(overhead)
+	summary += fmt.Sprintf("; direct probe (bypassing the proxy): %s", bypass)
+
+	if overhead > threshold {
+		result.Status, result.Code = "warn", codeProxyOverhead
+		result.Message = fmt.Sprintf("The proxy adds %s to time-to-first-byte, and a direct path exists (split tunnel): %s", formatMs(overhead), summary)
+		result.Fix = "If policy allows Bedrock traffic to bypass the proxy, add .amazonaws.com to NO_PROXY (keeping existing entries); otherwise ask the proxy's owners why it is slow for the Bedrock hosts"
+		return result
+	}
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("The proxy adds %s to time-to-first-byte: %s", formatMs(overhead), summary)
+	return result
+}
@@ -0,0 +1,128 @@
+package probes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxyOverheadResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxy     string        // "none", "refused", or empty for the forward proxy
+		delay     time.Duration // added by the forward proxy
+		blocked   bool          // the direct path is refused
+		direct    bool
+		threshold time.Duration
+		status    string
+		code      string
+		contains  []string
+		reached   bool // the endpoint saw a direct request
+	}{
+		{
+			name:     "no proxy",
+			proxy:    "none",
+			direct:   true,
+			status:   "skip",
+			contains: []string{"No proxy is configured for 127.0.0.1"},
+		},
+		{
+			name:      "equivalent",
+			direct:    true,
+			threshold: time.Minute,
+			status:    "pass",
+			contains:  []string{"The proxy adds ", "via http://127.0.0.1:", "; direct probe (bypassing the proxy): "},
+			reached:   true,
+		},
+		{
+			name:      "slow proxy with a split tunnel",
+			delay:     100 * time.Millisecond,
+			direct:    true,
+			threshold: 50 * time.Millisecond,
+			status:    "warn",
+			code:      codeProxyOverhead,
+			contains:  []string{"a direct path exists (split tunnel)", "add .amazonaws.com to NO_PROXY"},
+			reached:   true,
+		},
+		{
+			name:      "direct path blocked",
+			blocked:   true,
+			direct:    true,
+			threshold: time.Minute,
+			status:    "pass",
+			contains:  []string{"direct probe (bypassing the proxy) blocked: ", "This is expected where all egress must go through the proxy"},
+		},
+		{
+			name:      "direct probe disabled",
+			threshold: time.Minute,
+			status:    "pass",
+			contains:  []string{"skipped by --no-direct-probe"},
+		},
+		{
+			name:     "proxy refused",
+			proxy:    "refused",
+			direct:   true,
+			status:   "warn",
+			contains: []string{"Could not time ", " via http://127.0.0.1:1: ", "See HTTPS Connectivity"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			var (
+				mu      sync.Mutex
+				reached bool
+			)
+			endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				reached = true
+				mu.Unlock()
+				awsHeaders(w)
+				w.WriteHeader(http.StatusForbidden)
+			}))
+			t.Cleanup(endpoint.Close)
+			target := endpoint.URL
+			if tt.blocked {
+				target = "http://127.0.0.1:1"
+			}
+			// The forward proxy answers for AWS itself, after its delay.
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if u, _ := url.Parse(target); r.URL.Host != u.Host {
+					t.Errorf("proxy got a request for %q, want %q", r.URL.Host, u.Host)
+				}
+				time.Sleep(tt.delay)
+				awsHeaders(w)
+				w.WriteHeader(http.StatusForbidden)
+			}))
+			t.Cleanup(proxy.Close)
+			switch tt.proxy {
+			case "none":
+				withProxyEnv(t, nil)
+			case "refused":
+				withProxyEnv(t, map[string]string{"HTTPS_PROXY": "http://127.0.0.1:1"})
+			default:
+				withProxyEnv(t, map[string]string{"HTTPS_PROXY": proxy.URL})
+			}
+
+			r := proxyOverheadResult(env, target, tt.threshold, tt.direct)
+			if r.ID != "network.proxy_overhead" || r.Status != tt.status || (tt.code != "" && r.Code != tt.code) {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if reached != tt.reached {
+				t.Errorf("endpoint reached directly: %v, want %v", reached, tt.reached)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+)
+
+// Bedrock publishes one quota per model and limit type, named like
+// "On-demand model inference requests per minute for Anthropic Claude 3.5 Sonnet".
+var claudeQuotaName = regexp.MustCompile(`^On-demand model inference (requests|tokens) per minute for Anthropic (Claude .+)$`)
+
+type quotaStatus struct {
+	Code    string
+	Model   string // human name, e.g. "Claude 3.5 Sonnet"
+	Unit    string // "requests/min" or "tokens/min"
+	Applied float64
+	Default float64
+}
+
+func (q quotaStatus) isDefault() bool {
+	return q.Applied <= q.Default
+}
+
+func (q quotaStatus) String() string {
+	s := fmt.Sprintf("%s: %s %s", q.Model, strconv.FormatFloat(q.Applied, 'f', -1, 64), q.Unit)
+	if q.isDefault() {
+		s += " (default)"
+	}
+	return s
+}
+
+func checkServiceQuotas(env Env, region string) ([]quotaStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := env.ServiceQuotas(cfg)
+
+	// The default quota list gives us the Claude quota codes and their defaults
+	// in one pass; the applied values are then looked up per code.
+	var quotas []quotaStatus
+	paginator := servicequotas.NewListAWSDefaultServiceQuotasPaginator(client, &servicequotas.ListAWSDefaultServiceQuotasInput{
+		ServiceCode: aws.String("bedrock"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("service quotas API call failed: %w", err)
+		}
+		for _, q := range page.Quotas {
+			m := claudeQuotaName.FindStringSubmatch(aws.ToString(q.QuotaName))
+			if m == nil || q.Value == nil {
+				continue
+			}
+			quotas = append(quotas, quotaStatus{
+				Code:    aws.ToString(q.QuotaCode),
+				Model:   m[2],
+				Unit:    m[1] + "/min",
+				Default: *q.Value,
+				Applied: *q.Value,
+			})
+		}
+	}
+
+	for i := range quotas {
+		out, err := client.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+			ServiceCode: aws.String("bedrock"),
+			QuotaCode:   aws.String(quotas[i].Code),
+		})
+		if err != nil {
+			// No applied value means the account is still on the default.
+			var notFound *sqtypes.NoSuchResourceException
+			if errors.As(err, &notFound) {
+				continue
+			}
+			return nil, fmt.Errorf("service quotas API call failed: %w", err)
+		}
+		if out.Quota != nil && out.Quota.Value != nil {
+			quotas[i].Applied = *out.Quota.Value
+		}
+	}
+
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Model != quotas[j].Model {
+			return quotas[i].Model < quotas[j].Model
+		}
+		return quotas[i].Unit < quotas[j].Unit
+	})
+
+	return quotas, nil
+}
+
+func serviceQuotasResult(env Env, region string) CheckResult {
+	quotas, err := checkServiceQuotas(env, region)
+	if err != nil {
+		fix := "Check AWS credentials and network access to the Service Quotas API"
+		if errMsg := err.Error(); strings.Contains(errMsg, "AccessDenied") {
+			fix = "Add servicequotas:GetServiceQuota and servicequotas:ListAWSDefaultServiceQuotas permissions to your IAM role/user"
+		}
+		result := CheckResult{
+			ID:      "bedrock.service_quotas",
+			Code:    awsErrorCode(err),
+			Name:    "Bedrock Service Quotas",
+			Status:  "warn",
+			Message: err.Error(),
+			Fix:     fix,
+		}
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return result
+	}
+
+	if len(quotas) == 0 {
+		return CheckResult{
+			ID:      "bedrock.service_quotas",
+			Name:    "Bedrock Service Quotas",
+			Status:  "warn",
+			Message: fmt.Sprintf("No Claude model quotas found in region %s", region),
+			Fix:     "Confirm Anthropic models are offered in this region",
+		}
+	}
+
+	var parts []string
+	atDefault := false
+	for _, q := range quotas {
+		parts = append(parts, q.String())
+		if q.isDefault() {
+			atDefault = true
+		}
+	}
+
+	result := CheckResult{
+		ID:      "bedrock.service_quotas",
+		Name:    "Bedrock Service Quotas",
+		Status:  "pass",
+		Message: strings.Join(parts, "; "),
+	}
+	if atDefault {
+		result.Status = "warn"
+		result.Code = codeQuotaDefault
+		result.Fix = "Request a quota increase for the Claude models you use in the Service Quotas console (Amazon Bedrock)"
+	}
+	return result
+}
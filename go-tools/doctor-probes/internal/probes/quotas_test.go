@@ -0,0 +1,145 @@
+package probes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestClaudeQuotaName(t *testing.T) {
+	tests := []struct {
+		name  string
+		unit  string // "" when the name should not match
+		model string
+	}{
+		{"On-demand model inference requests per minute for Anthropic Claude 3.5 Sonnet", "requests", "Claude 3.5 Sonnet"},
+		{"On-demand model inference tokens per minute for Anthropic Claude 3 Haiku", "tokens", "Claude 3 Haiku"},
+		{"On-demand model inference requests per minute for Anthropic Claude Sonnet 4 V1", "requests", "Claude Sonnet 4 V1"},
+		{"On-demand model inference requests per minute for Amazon Titan Text Express", "", ""},
+		{"Cross-region model inference requests per minute for Anthropic Claude 3.5 Sonnet", "", ""},
+		{"On-demand model inference requests per day for Anthropic Claude 3.5 Sonnet", "", ""},
+		{"On-demand model inference requests per minute for Anthropic", "", ""},
+		{"Model invocation max tokens per day for Anthropic Claude 3.5 Sonnet", "", ""},
+	}
+	for _, tt := range tests {
+		m := claudeQuotaName.FindStringSubmatch(tt.name)
+		switch {
+		case tt.unit == "" && m != nil:
+			t.Errorf("%q matched as %q", tt.name, m[2])
+		case tt.unit != "" && m == nil:
+			t.Errorf("%q did not match", tt.name)
+		case tt.unit != "" && (m[1] != tt.unit || m[2] != tt.model):
+			t.Errorf("%q = %s for %q, want %s for %q", tt.name, m[1], m[2], tt.unit, tt.model)
+		}
+	}
+}
+
+func TestQuotaStatus(t *testing.T) {
+	tests := []struct {
+		applied   float64
+		isDefault bool
+		want      string
+	}{
+		{applied: 20, isDefault: true, want: "Claude 3.5 Sonnet: 20 requests/min (default)"},
+		{applied: 50, isDefault: true, want: "Claude 3.5 Sonnet: 50 requests/min (default)"},
+		{applied: 250, isDefault: false, want: "Claude 3.5 Sonnet: 250 requests/min"},
+		{applied: 62.5, isDefault: false, want: "Claude 3.5 Sonnet: 62.5 requests/min"},
+	}
+	for _, tt := range tests {
+		q := quotaStatus{Code: "L-1", Model: "Claude 3.5 Sonnet", Unit: "requests/min", Applied: tt.applied, Default: 50}
+		if q.isDefault() != tt.isDefault {
+			t.Errorf("applied %v of default 50: isDefault = %v", tt.applied, q.isDefault())
+		}
+		if got := q.String(); got != tt.want {
+			t.Errorf("applied %v: %q, want %q", tt.applied, got, tt.want)
+		}
+	}
+}
+
+// fakeQuotas answers the Service Quotas API with Claude quotas defaulting to
+// 50 requests/min and 40000 tokens/min. applied maps a quota code to its
+// applied value; codes not in it have none. denied refuses every call.
+func fakeQuotas(t *testing.T, applied map[string]float64, denied bool) {
+	t.Helper()
+	probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get("X-Amz-Target")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch {
+		case denied:
+			probestest.AWSJSONError(w, http.StatusBadRequest, "AccessDeniedException", "User is not authorized to perform: servicequotas:ListAWSDefaultServiceQuotas")
+		case strings.HasSuffix(target, ".ListAWSDefaultServiceQuotas"):
+			w.Write([]byte(`{"Quotas":[
+{"QuotaCode":"L-SR","QuotaName":"On-demand model inference requests per minute for Anthropic Claude 3.5 Sonnet","Value":50},
+{"QuotaCode":"L-ST","QuotaName":"On-demand model inference tokens per minute for Anthropic Claude 3.5 Sonnet","Value":40000},
+{"QuotaCode":"L-TR","QuotaName":"On-demand model inference requests per minute for Amazon Titan Text Express","Value":100}]}`))
+		case strings.HasSuffix(target, ".GetServiceQuota"):
+			var in struct{ QuotaCode string }
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				t.Error(err)
+			}
+			value, ok := applied[in.QuotaCode]
+			if !ok {
+				probestest.AWSJSONError(w, http.StatusBadRequest, "NoSuchResourceException", "no applied value")
+				return
+			}
+			fmt.Fprintf(w, `{"Quota":{"QuotaCode":%q,"Value":%v}}`, in.QuotaCode, value)
+		default:
+			t.Errorf("unexpected call %s", target)
+		}
+	})
+}
+
+func TestServiceQuotasResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied map[string]float64
+		denied  bool
+		status  string
+		code    string
+		message string
+		fix     string
+	}{
+		{
+			name:    "at defaults",
+			status:  "warn",
+			code:    codeQuotaDefault,
+			message: "Claude 3.5 Sonnet: 50 requests/min (default); Claude 3.5 Sonnet: 40000 tokens/min (default)",
+			fix:     "Request a quota increase",
+		},
+		{
+			name:    "one raised",
+			applied: map[string]float64{"L-ST": 400000},
+			status:  "warn",
+			code:    codeQuotaDefault,
+			message: "Claude 3.5 Sonnet: 50 requests/min (default); Claude 3.5 Sonnet: 400000 tokens/min",
+		},
+		{
+			name:    "all raised",
+			applied: map[string]float64{"L-SR": 500, "L-ST": 400000},
+			status:  "pass",
+			message: "Claude 3.5 Sonnet: 500 requests/min; Claude 3.5 Sonnet: 400000 tokens/min",
+		},
+		{
+			name:    "access denied",
+			denied:  true,
+			status:  "warn",
+			code:    codeIAMAccessDenied,
+			message: "service quotas API call failed",
+			fix:     "servicequotas:GetServiceQuota",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			fakeQuotas(t, tt.applied, tt.denied)
+			got := serviceQuotasResult(env, "us-east-1")
+			if got.Status != tt.status || got.Code != tt.code || !strings.Contains(got.Message, tt.message) || !strings.Contains(got.Fix, tt.fix) {
+				t.Errorf("got %s %s %q (fix %q), want %s %s %q (fix containing %q)", got.Status, got.Code, got.Message, got.Fix, tt.status, tt.code, tt.message, tt.fix)
+			}
+		})
+	}
+}
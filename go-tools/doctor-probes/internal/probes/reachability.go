@@ -0,0 +1,137 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// httpStatusError is a completed HTTPS exchange that proves nothing about
+// reaching AWS: a proxy demanding credentials, or a response from something
+// other than AWS (firewall block page, captive portal).
+type httpStatusError struct {
+	StatusCode int
+	Server     string
+	Location   string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.StatusCode == http.StatusProxyAuthRequired {
+		return "HTTP 407 Proxy Authentication Required"
+	}
+	msg := fmt.Sprintf("HTTP %d from a non-AWS server", e.StatusCode)
+	if e.Server != "" {
+		msg += fmt.Sprintf(" (Server: %s)", e.Server)
+	}
+	if e.Location != "" {
+		msg += fmt.Sprintf(", redirecting to %s", RedactSecrets(e.Location))
+	}
+	return msg + "; likely a firewall block page or captive portal"
+}
+
+// isAWSResponse reports whether resp was served by AWS. AWS endpoints tag
+// every response, including errors for unsigned requests, with a request ID.
+func isAWSResponse(resp *http.Response) bool {
+	for _, h := range []string{"x-amzn-RequestId", "x-amz-request-id", "x-amz-id-2"} {
+		if resp.Header.Get(h) != "" {
+			return true
+		}
+	}
+	server := strings.ToLower(resp.Header.Get("Server"))
+	return strings.Contains(server, "amazon") || strings.Contains(server, "awselb")
+}
+
+// reachabilityError returns nil when resp shows the endpoint is reachable.
+// Any status from AWS counts: the probe is unsigned, so 403 and 404 are the
+// expected answers and authorization is checked separately.
+func reachabilityError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusProxyAuthRequired && isAWSResponse(resp) {
+		return nil
+	}
+	return &httpStatusError{
+		StatusCode: resp.StatusCode,
+		Server:     resp.Header.Get("Server"),
+		Location:   resp.Header.Get("Location"),
+	}
+}
+
+// rangedProbeBytes is how much of the body the ranged GET fallback asks
+// for and reads; the probe needs the headers, not the content.
+const rangedProbeBytes = 1024
+
+// httpsExchange is the metadata of an HTTPS probe's final response.
+type httpsExchange struct {
+	Method     string // HEAD, or GET when the endpoint refused HEAD
+	StatusCode int
+	Server     string
+	Location   string // set on 3xx; redirects are never followed
+	Size       int64  // body bytes read, or Content-Length for HEAD; -1 if unknown
+	Diag       *Diagnostics
+}
+
+// isRedirect reports whether the exchange ended in a redirect.
+func (e *httpsExchange) isRedirect() bool {
+	return e.StatusCode >= 300 && e.StatusCode < 400
+}
+
+func (e *httpsExchange) String() string {
+	s := fmt.Sprintf("%s answered HTTP %d", e.Method, e.StatusCode)
+	if e.Server != "" {
+		s += fmt.Sprintf(", Server: %s", e.Server)
+	}
+	if e.Size >= 0 {
+		s += fmt.Sprintf(", %d bytes", e.Size)
+	}
+	return s
+}
+
+// refusesHEAD reports whether status means the endpoint does not serve HEAD,
+// so that only a GET measures it.
+func refusesHEAD(status int) bool {
+	return status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented
+}
+
+// newProbeRequest builds the probe request: a HEAD, or for endpoints that
+// refuse HEAD a GET for the first rangedProbeBytes of the body.
+func newProbeRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", rangedProbeBytes-1))
+	}
+	return req, nil
+}
+
+// readExchange records resp, reading at most rangedProbeBytes of its body,
+// and closes it.
+func readExchange(method string, resp *http.Response) *httpsExchange {
+	defer resp.Body.Close()
+	exchange := &httpsExchange{
+		Method:     method,
+		StatusCode: resp.StatusCode,
+		Server:     resp.Header.Get("Server"),
+		Location:   resp.Header.Get("Location"),
+		Size:       resp.ContentLength,
+		Diag:       httpDiagnostics(resp),
+	}
+	if method != http.MethodHead {
+		n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, rangedProbeBytes))
+		if err == nil {
+			exchange.Size = n
+		}
+	}
+	return exchange
+}
+
+// metrics returns the exchange's status and size for machine consumers.
+func (e *httpsExchange) metrics() map[string]float64 {
+	m := map[string]float64{"http_status": float64(e.StatusCode)}
+	if e.Size >= 0 {
+		m["response_bytes"] = float64(e.Size)
+	}
+	return m
+}
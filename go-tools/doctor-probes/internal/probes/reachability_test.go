@@ -0,0 +1,155 @@
+package probes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReachabilityError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		err     string // empty when reachable
+	}{
+		{name: "unsigned 403 from AWS", status: 403, headers: map[string]string{"x-amzn-RequestId": "abc"}},
+		{name: "404 from S3-style request ID", status: 404, headers: map[string]string{"x-amz-request-id": "abc"}},
+		{name: "extended request ID", status: 400, headers: map[string]string{"x-amz-id-2": "abc"}},
+		{name: "Amazon server header", status: 200, headers: map[string]string{"Server": "AmazonS3"}},
+		{name: "load balancer", status: 503, headers: map[string]string{"Server": "awselb/2.0"}},
+		{
+			name:    "proxy 407 even with AWS headers",
+			status:  407,
+			headers: map[string]string{"x-amzn-RequestId": "abc"},
+			err:     "HTTP 407 Proxy Authentication Required",
+		},
+		{
+			name:   "block page without server",
+			status: 403,
+			err:    "HTTP 403 from a non-AWS server; likely a firewall block page or captive portal",
+		},
+		{
+			name:    "captive portal redirect",
+			status:  302,
+			headers: map[string]string{"Server": "nginx", "Location": "https://portal.example.com/?token=eyJa.eyJb.c"},
+			err:     "HTTP 302 from a non-AWS server (Server: nginx), redirecting to https://portal.example.com/?token=[REDACTED]; likely a firewall block page or captive portal",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+			err := reachabilityError(resp)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("reachabilityError = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("reachabilityError = %v, want %s", err, tt.err)
+			}
+			if _, ok := err.(*httpStatusError); !ok {
+				t.Errorf("error is %T, want *httpStatusError", err)
+			}
+		})
+	}
+}
+
+func TestNewProbeRequest(t *testing.T) {
+	head, err := newProbeRequest(context.Background(), http.MethodHead, "https://bedrock-runtime.us-east-1.amazonaws.com/")
+	if err != nil || head.Header.Get("Range") != "" {
+		t.Errorf("HEAD request = %v, %v; want no Range header", head.Header, err)
+	}
+	get, err := newProbeRequest(context.Background(), http.MethodGet, "https://bedrock-runtime.us-east-1.amazonaws.com/")
+	if err != nil || get.Header.Get("Range") != "bytes=0-1023" {
+		t.Errorf("GET request = %v, %v; want Range bytes=0-1023", get.Header, err)
+	}
+	if _, err := newProbeRequest(context.Background(), http.MethodGet, "https://[::1"); err == nil {
+		t.Error("newProbeRequest accepted a malformed URL")
+	}
+}
+
+func TestRefusesHEAD(t *testing.T) {
+	for status, want := range map[int]bool{405: true, 501: true, 200: false, 403: false, 404: false, 302: false} {
+		if got := refusesHEAD(status); got != want {
+			t.Errorf("refusesHEAD(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestReadExchange(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		header   http.Header
+		body     string
+		length   int64
+		want     string
+		redirect bool
+		metrics  map[string]float64
+	}{
+		{
+			name:    "HEAD takes Content-Length",
+			method:  http.MethodHead,
+			status:  404,
+			header:  http.Header{"Server": {"AmazonS3"}},
+			length:  42,
+			want:    "HEAD answered HTTP 404, Server: AmazonS3, 42 bytes",
+			metrics: map[string]float64{"http_status": 404, "response_bytes": 42},
+		},
+		{
+			name:    "HEAD without length",
+			method:  http.MethodHead,
+			status:  403,
+			length:  -1,
+			want:    "HEAD answered HTTP 403",
+			metrics: map[string]float64{"http_status": 403},
+		},
+		{
+			name:    "GET reads at most the ranged bytes",
+			method:  http.MethodGet,
+			status:  200,
+			body:    strings.Repeat("x", 5000),
+			length:  -1,
+			want:    "GET answered HTTP 200, 1024 bytes",
+			metrics: map[string]float64{"http_status": 200, "response_bytes": 1024},
+		},
+		{
+			name:     "redirect",
+			method:   http.MethodHead,
+			status:   301,
+			header:   http.Header{"Location": {"https://example.com/"}},
+			length:   0,
+			want:     "HEAD answered HTTP 301, 0 bytes",
+			redirect: true,
+			metrics:  map[string]float64{"http_status": 301, "response_bytes": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			resp := &http.Response{StatusCode: tt.status, Header: header, ContentLength: tt.length, Body: io.NopCloser(strings.NewReader(tt.body))}
+			got := readExchange(tt.method, resp)
+			if got.String() != tt.want || got.isRedirect() != tt.redirect {
+				t.Errorf("exchange = %q, redirect %v; want %q, %v", got, got.isRedirect(), tt.want, tt.redirect)
+			}
+			if got.Location != header.Get("Location") {
+				t.Errorf("location = %q", got.Location)
+			}
+			if !reflect.DeepEqual(got.metrics(), tt.metrics) {
+				t.Errorf("metrics = %v, want %v", got.metrics(), tt.metrics)
+			}
+		})
+	}
+}
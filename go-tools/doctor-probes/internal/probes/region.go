@@ -0,0 +1,68 @@
+package probes
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var RegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// ResolveRegion returns the region to check and where it came from, in the
+// SDK's order: flag (the --region value), AWS_REGION, AWS_DEFAULT_REGION,
+// then the active profile's region. Both are "" when none is set.
+func ResolveRegion(flag string) (region, source string) {
+	if flag != "" {
+		return flag, "--region"
+	}
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if v := os.Getenv(name); v != "" {
+			return v, name
+		}
+	}
+	if profile, shared, err := loadProfile(); err == nil && shared != nil && shared.Region != "" {
+		return shared.Region, fmt.Sprintf("the region of profile '%s'", profile)
+	}
+	return "", ""
+}
+
+// CurrentRegion is the resolved region alone, for reports and history.
+func CurrentRegion(flag string) string {
+	region, _ := ResolveRegion(flag)
+	return region
+}
+
+// regionResult reports the region and its source. Only a missing region
+// fails; one that did not come from AWS_REGION warns, because Claude Code
+// and some other tools read that variable and nothing else.
+func regionResult(region, source string) CheckResult {
+	result := CheckResult{ID: "region", Name: "AWS Region"}
+	if region == "" {
+		result.Status, result.Code = "fail", codeRegionUnset
+		result.Message = "No region set: AWS_REGION, AWS_DEFAULT_REGION, and the active profile's region are all empty"
+		result.Fix = "export AWS_REGION=us-east-1 (or your preferred region)"
+		result.FixCommand = &FixCommand{
+			Bash:       `export AWS_REGION="${AWS_REGION:-us-east-1}"  # change to your preferred region`,
+			PowerShell: `if (-not $env:AWS_REGION) { $env:AWS_REGION = "us-east-1" }  # change to your preferred region`,
+		}
+		return result
+	}
+
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("%s from %s (partition %s)", region, source, partitionForRegion(region).ID)
+	env := os.Getenv("AWS_REGION")
+	switch {
+	case source == "AWS_REGION":
+	case env != "":
+		result.Message += fmt.Sprintf("; AWS_REGION is %s, which Claude Code uses instead", env)
+	default:
+		result.Status, result.Code = "warn", codeRegionFallback
+		result.Message += "; AWS_REGION is not set"
+		result.Fix = fmt.Sprintf("The SDK finds the region, but Claude Code and some other tools read only AWS_REGION: export AWS_REGION=%s", region)
+		result.FixCommand = &FixCommand{
+			Bash:       fmt.Sprintf(`export AWS_REGION=%s`, region),
+			PowerShell: fmt.Sprintf(`$env:AWS_REGION = "%s"`, region),
+		}
+	}
+	return result
+}
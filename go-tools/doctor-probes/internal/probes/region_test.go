@@ -0,0 +1,121 @@
+package probes
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResolveRegion(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   string
+		env    map[string]string
+		config string
+		region string
+		source string
+	}{
+		{
+			name:   "flag wins",
+			flag:   "eu-west-1",
+			env:    map[string]string{"AWS_REGION": "us-east-1", "AWS_DEFAULT_REGION": "us-west-2"},
+			config: "[profile dev]\nregion = ap-northeast-1\n",
+			region: "eu-west-1",
+			source: "--region",
+		},
+		{
+			name:   "AWS_REGION before AWS_DEFAULT_REGION",
+			env:    map[string]string{"AWS_REGION": "us-east-1", "AWS_DEFAULT_REGION": "us-west-2"},
+			region: "us-east-1",
+			source: "AWS_REGION",
+		},
+		{
+			name:   "AWS_DEFAULT_REGION before the profile",
+			env:    map[string]string{"AWS_DEFAULT_REGION": "us-west-2"},
+			config: "[profile dev]\nregion = ap-northeast-1\n",
+			region: "us-west-2",
+			source: "AWS_DEFAULT_REGION",
+		},
+		{
+			name:   "profile",
+			config: "[profile dev]\nregion = ap-northeast-1\n",
+			region: "ap-northeast-1",
+			source: "the region of profile 'dev'",
+		},
+		{
+			name:   "profile without a region",
+			config: "[profile dev]\noutput = json\n",
+		},
+		{name: "nothing set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSharedConfig(t, tt.config)
+			for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+				t.Setenv(name, tt.env[name])
+			}
+			region, source := ResolveRegion(tt.flag)
+			if region != tt.region || source != tt.source {
+				t.Errorf("resolveRegion = %q, %q; want %q, %q", region, source, tt.region, tt.source)
+			}
+		})
+	}
+}
+
+func TestRegionResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    string
+		source    string
+		awsRegion string
+		status    string
+		code      string
+		message   string
+	}{
+		{
+			name:    "unset",
+			status:  "fail",
+			code:    codeRegionUnset,
+			message: "No region set",
+		},
+		{
+			name:      "from AWS_REGION",
+			region:    "us-east-1",
+			source:    "AWS_REGION",
+			awsRegion: "us-east-1",
+			status:    "pass",
+			message:   "us-east-1 from AWS_REGION (partition aws)",
+		},
+		{
+			name:    "from the profile only",
+			region:  "cn-north-1",
+			source:  "the region of profile 'dev'",
+			status:  "warn",
+			code:    codeRegionFallback,
+			message: "cn-north-1 from the region of profile 'dev' (partition aws-cn); AWS_REGION is not set",
+		},
+		{
+			name:      "flag differs from AWS_REGION",
+			region:    "eu-west-1",
+			source:    "--region",
+			awsRegion: "us-east-1",
+			status:    "pass",
+			message:   "eu-west-1 from --region (partition aws); AWS_REGION is us-east-1, which Claude Code uses instead",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", tt.awsRegion)
+			if tt.awsRegion == "" {
+				os.Unsetenv("AWS_REGION")
+			}
+			got := regionResult(tt.region, tt.source)
+			if got.Status != tt.status || got.Code != tt.code || !strings.HasPrefix(got.Message, tt.message) {
+				t.Errorf("status, code, message = %s, %q, %q; want %s, %q, %q", got.Status, got.Code, got.Message, tt.status, tt.code, tt.message)
+			}
+			if got.Status != "pass" && got.FixCommand == nil {
+				t.Error("no fix command")
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package probes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// RegionSource is one place that configures a region, or a geography through
+// an inference profile prefix, and what reads it.
+type RegionSource struct {
+	Source    string `json:"source"`              // e.g. "AWS_REGION (environment)"
+	Value     string `json:"value"`               // as configured
+	Region    string `json:"region,omitempty"`    // region it names, if any
+	Geography string `json:"geography,omitempty"` // geography of a cross-region profile prefix
+	UsedBy    string `json:"used_by"`
+	Matches   bool   `json:"matches"`
+}
+
+// hostRegion finds a region name in an endpoint hostname, e.g. us-east-1 in
+// vpce-0a1b.bedrock-runtime.us-east-1.vpce.amazonaws.com.
+var hostRegion = regexp.MustCompile(`(?:^|\.)([a-z]{2}(?:-[a-z]+)+-\d+)\.`)
+
+// profileGeographies maps a cross-region inference profile prefix to the
+// region geography it serves; global serves every one.
+var profileGeographies = map[string]string{"us.": "us", "eu.": "eu", "apac.": "ap", "us-gov.": "us-gov", "global.": "global"}
+
+// settingsEnvBlock merges the env blocks of the settings files, later files
+// winning, and records which file each value came from. Files that do not
+// parse are left to the settings checks.
+func settingsEnvBlock() (values, sources map[string]string) {
+	values, sources = map[string]string{}, map[string]string{}
+	for _, f := range SettingsFiles() {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue
+		}
+		var settings struct {
+			Env map[string]any `json:"env"`
+		}
+		if json.Unmarshal(data, &settings) != nil {
+			continue
+		}
+		for k, v := range settings.Env {
+			if s, ok := v.(string); ok {
+				values[k], sources[k] = s, f.Path
+			}
+		}
+	}
+	return values, sources
+}
+
+// claudeEnv returns name as Claude Code sees it: the shell's value, else the
+// settings env block's, and a label for where it came from.
+func claudeEnv(name string, values, sources map[string]string) (value, source string) {
+	if v := os.Getenv(name); v != "" {
+		return v, name + " (environment)"
+	}
+	if v := values[name]; v != "" {
+		return v, fmt.Sprintf("%s (%s env)", name, sources[name])
+	}
+	return "", ""
+}
+
+// modelGeography returns the region of a model ARN or the geography of a
+// cross-region profile ID; both are "" for a single-region model ID.
+func modelGeography(model string) (region, geography string) {
+	if parsed, err := arn.Parse(model); err == nil {
+		return parsed.Region, ""
+	}
+	for prefix, geo := range profileGeographies {
+		if strings.HasPrefix(model, prefix) {
+			return "", geo
+		}
+	}
+	return "", ""
+}
+
+// regionSources gathers every configured region. The first source is the
+// one Claude Code sends requests to: AWS_REGION, or the doctor's resolved
+// region when that is unset. flag is the --region value.
+func regionSources(flag string) []RegionSource {
+	values, sources := settingsEnvBlock()
+	var out []RegionSource
+	// The resolved region names its source without the "(environment)"
+	// suffix; do not list it twice.
+	add := func(s RegionSource) {
+		if len(out) == 0 || !strings.HasPrefix(s.Source, out[0].Source) {
+			out = append(out, s)
+		}
+	}
+	if v, src := claudeEnv("AWS_REGION", values, sources); v != "" {
+		add(RegionSource{Source: src, Value: v, Region: v, UsedBy: "Claude Code's Bedrock requests and SigV4 signing; AWS CLI and SDKs"})
+	} else if region, src := ResolveRegion(flag); region != "" {
+		add(RegionSource{Source: src, Value: region, Region: region, UsedBy: "this doctor run; Claude Code ignores it without AWS_REGION"})
+	}
+	if flag != "" {
+		add(RegionSource{Source: "--region", Value: flag, Region: flag, UsedBy: "this doctor run only"})
+	}
+	if v := os.Getenv("AWS_DEFAULT_REGION"); v != "" {
+		add(RegionSource{Source: "AWS_DEFAULT_REGION (environment)", Value: v, Region: v, UsedBy: "AWS CLI and SDKs when AWS_REGION is unset"})
+	}
+	if profile, shared, err := loadProfile(); err == nil && shared != nil && shared.Region != "" {
+		add(RegionSource{Source: fmt.Sprintf("the region of profile '%s'", profile), Value: shared.Region, Region: shared.Region,
+			UsedBy: "AWS CLI, aws sso login, and credential_process helpers; SDKs when no variable is set"})
+	}
+	if v, src := claudeEnv("ANTHROPIC_BEDROCK_BASE_URL", values, sources); v != "" {
+		s := RegionSource{Source: src, Value: v, UsedBy: "Claude Code's Bedrock endpoint, still signed for AWS_REGION"}
+		if u, err := url.Parse(v); err == nil {
+			if m := hostRegion.FindStringSubmatch(u.Hostname() + "."); m != nil {
+				s.Region = m[1]
+			}
+		}
+		add(s)
+	}
+	for _, name := range configuredModelVars {
+		v, src := claudeEnv(name, values, sources)
+		if v == "" {
+			continue
+		}
+		region, geo := modelGeography(v)
+		if region == "" && geo == "" {
+			continue
+		}
+		add(RegionSource{Source: src, Value: v, Region: region, Geography: geo,
+			UsedBy: "Claude Code's model routing; the profile must be offered in AWS_REGION"})
+	}
+	return out
+}
+
+// regionConsistencyResult compares every configured region, and the
+// geography of every cross-region inference profile, with the region Claude
+// Code sends requests to, and warns with a table of sources when they
+// disagree. It never fails: each component works alone, but together they
+// sign, route, or log in to different places.
+func regionConsistencyResult(flag string) CheckResult {
+	result := CheckResult{ID: "region.consistency", Name: "Region Consistency"}
+	sources := regionSources(flag)
+	if len(sources) == 0 {
+		result.Status = "skip"
+		result.Message = "No region configured"
+		return result
+	}
+	ref := sources[0].Region
+	geo := regionGeography(ref)
+	var mismatched []RegionSource
+	for i := range sources {
+		s := &sources[i]
+		switch {
+		case s.Geography != "":
+			s.Matches = s.Geography == "global" || s.Geography == geo
+		case s.Region != "":
+			s.Matches = s.Region == ref
+		default:
+			s.Matches = true // no region to compare, e.g. a gateway URL
+		}
+		if !s.Matches {
+			mismatched = append(mismatched, *s)
+		}
+	}
+	result.Regions = sources
+
+	if len(mismatched) == 0 {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%d region source(s) agree with %s from %s", len(sources), ref, sources[0].Source)
+		return result
+	}
+	var parts, fixes []string
+	for _, s := range mismatched {
+		if s.Geography != "" {
+			parts = append(parts, fmt.Sprintf("%s is %s, for the %s geography", s.Source, s.Value, s.Geography))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s is %s", s.Source, s.Region))
+		}
+		fixes = append(fixes, regionSourceFix(s, ref))
+	}
+	result.Status, result.Code = "warn", codeRegionMismatch
+	result.Message = fmt.Sprintf("Region sources disagree with %s from %s: %s", ref, sources[0].Source, strings.Join(parts, "; "))
+	result.Fix = fmt.Sprintf("Make them match %s, or change %s if the others are right: %s", ref, sources[0].Source, strings.Join(fixes, "; "))
+	return result
+}
+
+// regionSourceFix says how to bring s in line with region.
+func regionSourceFix(s RegionSource, region string) string {
+	switch {
+	case strings.HasPrefix(s.Source, "the region of profile '"):
+		profile := strings.TrimSuffix(strings.TrimPrefix(s.Source, "the region of profile '"), "'")
+		return fmt.Sprintf("aws configure set region %s --profile %s", region, profile)
+	case strings.HasPrefix(s.Source, "AWS_DEFAULT_REGION"):
+		return fmt.Sprintf("export AWS_DEFAULT_REGION=%s (or unset it)", region)
+	case strings.HasPrefix(s.Source, "ANTHROPIC_BEDROCK_BASE_URL"):
+		return fmt.Sprintf("point ANTHROPIC_BEDROCK_BASE_URL at the %s endpoint", region)
+	case s.Source == "--region":
+		return fmt.Sprintf("drop --region, or run with --region %s", region)
+	case s.Geography != "":
+		geo := regionGeography(region)
+		if geo == "ap" {
+			geo = "apac"
+		}
+		name, _, _ := strings.Cut(s.Source, " ")
+		_, rest, _ := strings.Cut(s.Value, ".")
+		return fmt.Sprintf("set %s to %s.%s (or global.%s)", name, geo, rest, rest)
+	}
+	name, _, _ := strings.Cut(s.Source, " ")
+	return fmt.Sprintf("set %s to a model or profile ARN in %s", name, region)
+}
+
+// PrintRegionTable prints a result's region sources under it.
+func PrintRegionTable(w io.Writer, sources []RegionSource) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "   SOURCE\tVALUE\tREGION\tMATCHES\tUSED BY")
+	for _, s := range sources {
+		region := s.Region
+		if s.Geography != "" {
+			region = s.Geography + " (geography)"
+		}
+		if region == "" {
+			region = "-"
+		}
+		matches := "yes"
+		if !s.Matches {
+			matches = "NO"
+		}
+		fmt.Fprintf(tw, "   %s\t%s\t%s\t%s\t%s\n", s.Source, s.Value, region, matches, s.UsedBy)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,140 @@
+package probes
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestModelGeography(t *testing.T) {
+	tests := []struct {
+		model, region, geography string
+	}{
+		{"eu.anthropic.claude-sonnet-4-20250514-v1:0", "", "eu"},
+		{"apac.anthropic.claude-sonnet-4-20250514-v1:0", "", "ap"},
+		{"global.anthropic.claude-sonnet-4-5-20250929-v1:0", "", "global"},
+		{"arn:aws:bedrock:eu-west-1:111122223333:application-inference-profile/abc123", "eu-west-1", ""},
+		{"anthropic.claude-3-5-haiku-20241022-v1:0", "", ""},
+	}
+	for _, tt := range tests {
+		if region, geo := modelGeography(tt.model); region != tt.region || geo != tt.geography {
+			t.Errorf("modelGeography(%s) = %q, %q; want %q, %q", tt.model, region, geo, tt.region, tt.geography)
+		}
+	}
+}
+
+func TestRegionConsistencyResult(t *testing.T) {
+	const sonnet = "anthropic.claude-sonnet-4-20250514-v1:0"
+	tests := []struct {
+		name     string
+		env      map[string]string
+		settings string // ~/.claude/settings.json
+		config   string // the shared config; profile dev is active
+		flag     string // --region
+		status   string
+		sources  []string // "source=region" or "source=geography (geography)", in order
+		contains []string
+	}{
+		{
+			name:     "nothing configured",
+			status:   "skip",
+			contains: []string{"No region configured"},
+		},
+		{
+			name:     "all agree",
+			env:      map[string]string{"AWS_REGION": "eu-west-1", "ANTHROPIC_MODEL": "eu." + sonnet},
+			config:   "[profile dev]\nregion = eu-west-1\n",
+			status:   "pass",
+			sources:  []string{"AWS_REGION (environment)=eu-west-1", "the region of profile 'dev'=eu-west-1", "ANTHROPIC_MODEL (environment)=eu (geography)"},
+			contains: []string{"3 region source(s) agree with eu-west-1 from AWS_REGION (environment)"},
+		},
+		{
+			name:     "three sources disagree",
+			env:      map[string]string{"AWS_REGION": "us-east-1"},
+			settings: `{"env":{"ANTHROPIC_MODEL":"eu.` + sonnet + `","ANTHROPIC_SMALL_FAST_MODEL":"global.` + sonnet + `"}}`,
+			config:   "[profile dev]\nregion = eu-west-1\n",
+			status:   "warn",
+			sources:  []string{"AWS_REGION (environment)=us-east-1", "the region of profile 'dev'=eu-west-1", "ANTHROPIC_MODEL=eu (geography)", "ANTHROPIC_SMALL_FAST_MODEL=global (geography)"},
+			contains: []string{
+				"Region sources disagree with us-east-1 from AWS_REGION (environment): the region of profile 'dev' is eu-west-1; ANTHROPIC_MODEL (",
+				"settings.json env) is eu." + sonnet + ", for the eu geography",
+				"aws configure set region us-east-1 --profile dev",
+				"set ANTHROPIC_MODEL to us." + sonnet + " (or global." + sonnet + ")",
+			},
+		},
+		{
+			name:     "only the profile sets a region",
+			env:      map[string]string{"ANTHROPIC_BEDROCK_BASE_URL": "https://vpce-0a1b.bedrock-runtime.us-east-1.vpce.amazonaws.com"},
+			config:   "[profile dev]\nregion = us-west-2\n",
+			status:   "warn",
+			sources:  []string{"the region of profile 'dev'=us-west-2", "ANTHROPIC_BEDROCK_BASE_URL (environment)=us-east-1"},
+			contains: []string{"ANTHROPIC_BEDROCK_BASE_URL (environment) is us-east-1", "point ANTHROPIC_BEDROCK_BASE_URL at the us-west-2 endpoint"},
+		},
+		{
+			name:     "flag, default region, and ARN",
+			env:      map[string]string{"AWS_REGION": "ap-southeast-2", "AWS_DEFAULT_REGION": "ap-southeast-2", "ANTHROPIC_MODEL": "arn:aws:bedrock:us-east-1:111122223333:application-inference-profile/abc123"},
+			flag:     "us-west-2",
+			status:   "warn",
+			sources:  []string{"AWS_REGION (environment)=ap-southeast-2", "--region=us-west-2", "AWS_DEFAULT_REGION (environment)=ap-southeast-2", "ANTHROPIC_MODEL (environment)=us-east-1"},
+			contains: []string{"drop --region, or run with --region ap-southeast-2", "set ANTHROPIC_MODEL to a model or profile ARN in ap-southeast-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := probestest.AWSConfig(t, tt.config)
+			t.Setenv("AWS_PROFILE", "dev")
+			probestest.Unsetenv(t, append([]string{"AWS_REGION", "AWS_DEFAULT_REGION", "ANTHROPIC_BEDROCK_BASE_URL"}, configuredModelVars...)...)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.settings != "" {
+				path := filepath.Join(home, ".claude", "settings.json")
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.settings), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r := regionConsistencyResult(tt.flag)
+			wantCode := ""
+			if tt.status == "warn" {
+				wantCode = codeRegionMismatch
+			}
+			if r.ID != "region.consistency" || r.Status != tt.status || r.Code != wantCode {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, wantCode)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			var got []string
+			for _, s := range r.Regions {
+				region := s.Region
+				if s.Geography != "" {
+					region = s.Geography + " (geography)"
+				}
+				source := s.Source
+				if strings.Contains(source, "settings.json") {
+					source, _, _ = strings.Cut(source, " ")
+				}
+				got = append(got, source+"="+region)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.sources, ", ") {
+				t.Errorf("sources = %q, want %q", got, tt.sources)
+			}
+
+			var table bytes.Buffer
+			PrintRegionTable(&table, r.Regions)
+			if tt.status == "warn" && (!strings.Contains(table.String(), "MATCHES") || !strings.Contains(table.String(), "  NO  ")) {
+				t.Errorf("table does not flag the mismatch:\n%s", table.String())
+			}
+		})
+	}
+}
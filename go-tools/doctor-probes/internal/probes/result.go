@@ -0,0 +1,40 @@
+package probes
+
+type CheckResult struct {
+	ID       string `json:"id"`             // stable slug, e.g. "dns.bedrock_runtime"
+	Code     string `json:"code,omitempty"` // stable error code, e.g. "E_DNS_NXDOMAIN"
+	Severity string `json:"severity"`       // info, warning, error
+
+	// Legacy fields; Name and Message are for humans and may change wording
+	Name    string `json:"name"`
+	Status  string `json:"status"` // pass, fail, warn
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+
+	// Shell form of Fix, for checks whose remedy the user can run themselves
+	FixCommand *FixCommand `json:"fix_command,omitempty"`
+
+	// Raw numeric measurements (e.g. "ttfb_ms") for machine consumers
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	DurationMs float64 `json:"duration_ms"`
+
+	// Request metadata for AWS Support, only in --verbose mode
+	Diagnostics *Diagnostics `json:"diagnostics,omitempty"`
+
+	// Retry history of network probes that needed more than one attempt
+	Attempts      int      `json:"attempts,omitempty"`
+	AttemptErrors []string `json:"attempt_errors,omitempty"`
+
+	// Lifecycle status of each configured model, for the model lifecycle check
+	Models []ModelLifecycle `json:"models,omitempty"`
+
+	// Per-run samples of the benchmark
+	Benchmark []BenchmarkRun `json:"benchmark,omitempty"`
+
+	// Every configured region and its source, for the region consistency check
+	Regions []RegionSource `json:"regions,omitempty"`
+
+	// Set by a suppress severity policy: reported, but not in the exit code
+	Suppressed bool `json:"suppressed,omitempty"`
+}
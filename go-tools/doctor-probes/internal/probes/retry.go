@@ -0,0 +1,93 @@
+package probes
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// Retry budget for DNS and HTTPS probes: a single dropped packet should not
+// turn a healthy environment red.
+const (
+	probeAttempts  = 3
+	retryBaseDelay = 250 * time.Millisecond
+)
+
+// sdkRetryer keeps the SDK's standard retry behaviour but caps the backoff so
+// a failing check reports within its timeout instead of sleeping through it.
+func sdkRetryer() aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = probeAttempts
+		o.MaxBackoff = 2 * time.Second
+	})
+}
+
+// transientNetError reports whether err may go away on retry. NXDOMAIN,
+// refused connections, TLS and HTTP errors are answers, not flakes.
+func transientNetError(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return false
+	}
+	switch classifyNetError(err) {
+	case codeDNSTimeout, codeDNSFailure, codeConnectTimeout, codeConnectFailure:
+		return true
+	}
+	return false
+}
+
+// jitteredBackoff returns the delay before retry number attempt (1-based):
+// exponential from retryBaseDelay, scaled by a random factor in [0.5, 1.5).
+func jitteredBackoff(attempt int) time.Duration {
+	d := retryBaseDelay << (attempt - 1)
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// retryProbe calls fn until it succeeds, fails with a non-transient error, or
+// the budget is spent. It returns the error of every failed attempt and the
+// final error (nil if an attempt succeeded).
+func retryProbe(fn func() error) (failures []error, err error) {
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return failures, nil
+		}
+		failures = append(failures, err)
+		if attempt == probeAttempts || !transientNetError(err) {
+			return failures, err
+		}
+		time.Sleep(jitteredBackoff(attempt))
+	}
+}
+
+// noteRetries records a probe's retry history on r. A probe that passed only
+// after retrying becomes a warning, so flakiness is visible without failing
+// the run.
+func (r *CheckResult) noteRetries(failures []error, err error) {
+	if len(failures) == 0 {
+		return
+	}
+	r.Attempts = len(failures)
+	if err == nil {
+		r.Attempts++
+	}
+	for _, f := range failures {
+		r.AttemptErrors = append(r.AttemptErrors, RedactSecrets(f.Error()))
+	}
+	if err != nil {
+		return
+	}
+
+	retries := "retry"
+	if len(failures) > 1 {
+		retries = "retries"
+	}
+	r.Message += fmt.Sprintf(" (passed after %d %s)", len(failures), retries)
+	if r.Status == "pass" {
+		r.Status, r.Code = "warn", codeFlakyNetwork
+		r.Fix = "The network path is intermittent; Claude Code retries too, but expect occasional stalls. Check Wi-Fi, VPN, or proxy stability"
+	}
+}
@@ -0,0 +1,197 @@
+package probes
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Claude Code retries on top of the SDK, so a request that keeps failing is
+// only reported once every SDK attempt is spent. retryAttemptEstimate is
+// how long one failed Bedrock attempt typically takes (a throttle or 5xx
+// after queueing, or a connection timeout); past retryWorstCaseLimit an
+// interactive user gives up waiting.
+const (
+	retryAttemptEstimate = 30 * time.Second
+	retryMaxBackoff      = 20 * time.Second // the SDKs' cap between attempts
+	retryWorstCaseLimit  = 2 * time.Minute
+)
+
+// Claude Code machines need no more than the SDK's standard defaults.
+const (
+	recommendedRetryMode     = "standard"
+	recommendedRetryAttempts = 3
+)
+
+// sdkSetting is one SDK setting in effect and where it came from.
+type sdkSetting struct {
+	Value  string
+	Source string // the environment variable or "<key> in profile 'name'"
+	Env    string // the variable that sets it
+	Key    string // the shared config key that sets it
+}
+
+func (s sdkSetting) fromProfile() bool {
+	return s.Source != "" && s.Source != s.Env
+}
+
+// sdkSettingFor resolves a setting like the SDKs do: the environment
+// variable, then the active profile, then def.
+func sdkSettingFor(env, key, def string) sdkSetting {
+	s := sdkSetting{Env: env, Key: key}
+	if v := os.Getenv(env); v != "" {
+		s.Value, s.Source = v, env
+		return s
+	}
+	profile := ActiveProfile()
+	if sections, err := sharedConfigSections(); err == nil {
+		if v := sections[profile][key]; v != "" {
+			s.Value, s.Source = v, fmt.Sprintf("%s in profile '%s'", key, profile)
+			return s
+		}
+	}
+	s.Value = def
+	return s
+}
+
+func (s sdkSetting) String() string {
+	if s.Source == "" {
+		return s.Value + " (the SDK default)"
+	}
+	return fmt.Sprintf("%s (%s)", s.Value, s.Source)
+}
+
+// settingFix returns how to set s to value, where s is set now: the
+// environment or the active profile.
+func settingFix(s sdkSetting, value string) (fix, bash, powerShell string) {
+	if s.fromProfile() {
+		profile := ActiveProfile()
+		configFile, _ := SharedConfigFiles()
+		cmd := fmt.Sprintf("aws configure set %s %s --profile %s", s.Key, value, profile)
+		return fmt.Sprintf("set %s = %s in the [%s] section of %s", s.Key, value, configSectionName(profile), configFile), cmd, cmd
+	}
+	return fmt.Sprintf("export %s=%s", s.Env, value), fmt.Sprintf("export %s=%s", s.Env, value), fmt.Sprintf(`$env:%s = "%s"`, s.Env, value)
+}
+
+// retryWorstCase is how long a request that never succeeds takes to fail:
+// every attempt, and the longest backoff between them.
+func retryWorstCase(attempts int) time.Duration {
+	total := time.Duration(attempts) * retryAttemptEstimate
+	for i := 1; i < attempts; i++ {
+		total += min(time.Second<<i, retryMaxBackoff)
+	}
+	return total
+}
+
+// retryConfigResults reports the SDK retry settings in effect and warns when
+// they make failing requests hang, and, off EC2, when instance metadata
+// settings make the credential chain wait for IMDS.
+func retryConfigResults(env Env) []CheckResult {
+	results := []CheckResult{retryConfigResult()}
+	if r, ok := imdsAttemptsResult(env); ok {
+		results = append(results, r)
+	}
+	return results
+}
+
+func retryConfigResult() CheckResult {
+	result := CheckResult{ID: "sdk.retry_config", Name: "SDK Retry Configuration"}
+	mode := sdkSettingFor("AWS_RETRY_MODE", "retry_mode", recommendedRetryMode)
+	mode.Value = strings.ToLower(mode.Value)
+	defaultAttempts := recommendedRetryAttempts
+	if mode.Value == "legacy" {
+		defaultAttempts = 5 // botocore's legacy mode; the CLI uses it
+	}
+	attemptsSetting := sdkSettingFor("AWS_MAX_ATTEMPTS", "max_attempts", strconv.Itoa(defaultAttempts))
+
+	var fixes, bash, powerShell []string
+	addFix := func(s sdkSetting, value string) {
+		fix, b, p := settingFix(s, value)
+		fixes, bash, powerShell = append(fixes, fix), append(bash, b), append(powerShell, p)
+	}
+
+	attempts, err := strconv.Atoi(attemptsSetting.Value)
+	if err != nil || attempts < 1 {
+		result.Status, result.Code = "warn", codeSharedConfigMistake
+		result.Message = fmt.Sprintf("Max attempts %s is not a positive number; the SDKs reject it or fall back to their default", attemptsSetting)
+		addFix(attemptsSetting, strconv.Itoa(recommendedRetryAttempts))
+		result.Fix = strings.Join(fixes, "; ")
+		return result
+	}
+	switch mode.Value {
+	case "standard", "adaptive", "legacy":
+	default:
+		result.Status, result.Code = "warn", codeSharedConfigMistake
+		result.Message = fmt.Sprintf("Retry mode %s is not standard, adaptive, or legacy; the SDKs reject it", mode)
+		addFix(mode, recommendedRetryMode)
+		result.Fix = strings.Join(fixes, "; ")
+		return result
+	}
+
+	worst := retryWorstCase(attempts)
+	result.Metrics = map[string]float64{
+		"max_attempts":       float64(attempts),
+		"worst_case_seconds": worst.Seconds(),
+	}
+	result.Message = fmt.Sprintf("Retry mode %s, max attempts %s: a request that keeps failing takes up to %s before Claude Code sees the error",
+		mode, attemptsSetting, worst)
+
+	var problems []string
+	if worst > retryWorstCaseLimit {
+		problems = append(problems, fmt.Sprintf("%d attempts take over %s to fail", attempts, retryWorstCaseLimit))
+		addFix(attemptsSetting, strconv.Itoa(recommendedRetryAttempts))
+	}
+	if mode.Value == "adaptive" {
+		problems = append(problems, "adaptive mode also holds requests back client-side after throttling, stalling every request in a busy account")
+		addFix(mode, recommendedRetryMode)
+	}
+	if len(problems) == 0 {
+		result.Status = "pass"
+		return result
+	}
+	result.Status, result.Code = "warn", codeRetrySlow
+	result.Message = fmt.Sprintf("%s; %s", strings.Join(problems, ", and "), result.Message)
+	result.Fix = "Claude Code retries on its own; on Claude Code machines use standard mode with 3 attempts: " + strings.Join(fixes, "; ")
+	result.FixCommand = &FixCommand{Bash: strings.Join(bash, " && "), PowerShell: strings.Join(powerShell, "; ")}
+	return result
+}
+
+// imdsAttemptsResult flags instance metadata settings that make a machine
+// off EC2 wait for IMDS whenever the credential chain reaches it. ok is
+// false when none is set, or on EC2, where they are harmless.
+func imdsAttemptsResult(env Env) (result CheckResult, ok bool) {
+	attemptsSetting := sdkSettingFor("AWS_METADATA_SERVICE_NUM_ATTEMPTS", "metadata_service_num_attempts", "1")
+	timeoutSetting := sdkSettingFor("AWS_METADATA_SERVICE_TIMEOUT", "metadata_service_timeout", "1")
+	if env.Host.Cloud == "EC2" || attemptsSetting.Source == "" && timeoutSetting.Source == "" {
+		return CheckResult{}, false
+	}
+	if disabled := os.Getenv("AWS_EC2_METADATA_DISABLED"); strings.EqualFold(disabled, "true") {
+		return CheckResult{}, false
+	}
+	result = CheckResult{ID: "sdk.imds_attempts", Name: "Instance Metadata Wait"}
+
+	attempts, err1 := strconv.Atoi(attemptsSetting.Value)
+	timeout, err2 := strconv.ParseFloat(timeoutSetting.Value, 64)
+	if err1 != nil || err2 != nil || attempts < 1 || timeout <= 0 {
+		result.Status, result.Code = "warn", codeSharedConfigMistake
+		result.Message = fmt.Sprintf("Instance metadata attempts %s or timeout %s is not a positive number", attemptsSetting, timeoutSetting)
+		return result, true
+	}
+	wait := time.Duration(float64(attempts) * timeout * float64(time.Second))
+	result.Metrics = map[string]float64{"wait_seconds": wait.Seconds()}
+	result.Message = fmt.Sprintf("Instance metadata attempts %s, timeout %s seconds: off EC2, the credential chain waits %s for IMDS whenever it gets that far", attemptsSetting, timeoutSetting, wait)
+	if wait <= time.Second {
+		result.Status = "pass"
+		return result, true
+	}
+	result.Status, result.Code = "warn", codeIMDSWait
+	result.Message = "This machine is not on EC2, yet " + result.Message
+	result.Fix = "Unset the instance metadata settings on machines off EC2, or turn IMDS off for the SDKs"
+	result.FixCommand = &FixCommand{
+		Bash:       "export AWS_EC2_METADATA_DISABLED=true",
+		PowerShell: `$env:AWS_EC2_METADATA_DISABLED = "true"`,
+	}
+	return result, true
+}
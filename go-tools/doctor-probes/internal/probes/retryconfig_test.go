@@ -0,0 +1,198 @@
+package probes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestRetryWorstCase(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{3, 96 * time.Second},
+		{10, 300*time.Second + 2*time.Second + 4*time.Second + 8*time.Second + 16*time.Second + 5*20*time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryWorstCase(tt.attempts); got != tt.want {
+			t.Errorf("retryWorstCase(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRetryConfigResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		config   string // the shared config; profile dev is active
+		status   string
+		code     string
+		contains []string
+		fixBash  string
+	}{
+		{
+			name:     "SDK defaults",
+			status:   "pass",
+			contains: []string{"Retry mode standard (the SDK default), max attempts 3 (the SDK default)", "up to 1m36s"},
+		},
+		{
+			name:     "many attempts in the environment",
+			env:      map[string]string{"AWS_MAX_ATTEMPTS": "10"},
+			status:   "warn",
+			code:     codeRetrySlow,
+			contains: []string{"10 attempts take over 2m0s to fail", "max attempts 10 (AWS_MAX_ATTEMPTS)", "standard mode with 3 attempts"},
+			fixBash:  "export AWS_MAX_ATTEMPTS=3",
+		},
+		{
+			name:     "adaptive mode in the profile",
+			config:   "[profile dev]\nretry_mode = adaptive\n",
+			status:   "warn",
+			code:     codeRetrySlow,
+			contains: []string{"adaptive mode also holds requests back", "adaptive (retry_mode in profile 'dev')", "set retry_mode = standard in the [profile dev] section"},
+			fixBash:  "aws configure set retry_mode standard --profile dev",
+		},
+		{
+			name:     "both",
+			env:      map[string]string{"AWS_RETRY_MODE": "adaptive"},
+			config:   "[profile dev]\nmax_attempts = 8\n",
+			status:   "warn",
+			code:     codeRetrySlow,
+			contains: []string{"8 attempts take over 2m0s to fail, and adaptive mode"},
+			fixBash:  "aws configure set max_attempts 3 --profile dev && export AWS_RETRY_MODE=standard",
+		},
+		{
+			name:     "the environment wins",
+			env:      map[string]string{"AWS_MAX_ATTEMPTS": "2"},
+			config:   "[profile dev]\nmax_attempts = 10\n",
+			status:   "pass",
+			contains: []string{"max attempts 2 (AWS_MAX_ATTEMPTS)"},
+		},
+		{
+			name:     "legacy mode defaults to 5 attempts",
+			env:      map[string]string{"AWS_RETRY_MODE": "legacy"},
+			status:   "warn",
+			code:     codeRetrySlow,
+			contains: []string{"5 attempts take over", "max attempts 5 (the SDK default)"},
+		},
+		{
+			name:     "unknown mode",
+			env:      map[string]string{"AWS_RETRY_MODE": "aggressive"},
+			status:   "warn",
+			code:     codeSharedConfigMistake,
+			contains: []string{"Retry mode aggressive (AWS_RETRY_MODE) is not standard, adaptive, or legacy", "export AWS_RETRY_MODE=standard"},
+		},
+		{
+			name:     "bad attempts",
+			config:   "[profile dev]\nmax_attempts = many\n",
+			status:   "warn",
+			code:     codeSharedConfigMistake,
+			contains: []string{"Max attempts many (max_attempts in profile 'dev') is not a positive number"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSharedConfig(t, tt.config)
+			probestest.Unsetenv(t, "AWS_RETRY_MODE", "AWS_MAX_ATTEMPTS")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			r := retryConfigResult()
+			if r.ID != "sdk.retry_config" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if tt.fixBash != "" && (r.FixCommand == nil || r.FixCommand.Bash != tt.fixBash) {
+				t.Errorf("fix command = %+v, want %q", r.FixCommand, tt.fixBash)
+			}
+		})
+	}
+}
+
+func TestIMDSAttemptsResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		config   string
+		cloud    string
+		want     bool
+		status   string
+		code     string
+		contains []string
+	}{
+		{name: "not set"},
+		{
+			name:     "slow off EC2",
+			env:      map[string]string{"AWS_METADATA_SERVICE_NUM_ATTEMPTS": "5", "AWS_METADATA_SERVICE_TIMEOUT": "3"},
+			want:     true,
+			status:   "warn",
+			code:     codeIMDSWait,
+			contains: []string{"not on EC2", "attempts 5 (AWS_METADATA_SERVICE_NUM_ATTEMPTS), timeout 3 (AWS_METADATA_SERVICE_TIMEOUT) seconds", "waits 15s"},
+		},
+		{
+			name:     "from the profile",
+			config:   "[profile dev]\nmetadata_service_num_attempts = 3\n",
+			want:     true,
+			status:   "warn",
+			code:     codeIMDSWait,
+			contains: []string{"attempts 3 (metadata_service_num_attempts in profile 'dev')", "waits 3s"},
+		},
+		{
+			name:     "set to the defaults",
+			env:      map[string]string{"AWS_METADATA_SERVICE_NUM_ATTEMPTS": "1"},
+			want:     true,
+			status:   "pass",
+			contains: []string{"waits 1s"},
+		},
+		{
+			name:  "on EC2",
+			env:   map[string]string{"AWS_METADATA_SERVICE_NUM_ATTEMPTS": "5"},
+			cloud: "EC2",
+		},
+		{
+			name: "IMDS disabled",
+			env:  map[string]string{"AWS_METADATA_SERVICE_NUM_ATTEMPTS": "5", "AWS_EC2_METADATA_DISABLED": "true"},
+		},
+		{
+			name:   "not a number",
+			env:    map[string]string{"AWS_METADATA_SERVICE_TIMEOUT": "soon"},
+			want:   true,
+			status: "warn",
+			code:   codeSharedConfigMistake,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withSharedConfig(t, tt.config)
+			probestest.Unsetenv(t, "AWS_METADATA_SERVICE_NUM_ATTEMPTS", "AWS_METADATA_SERVICE_TIMEOUT", "AWS_EC2_METADATA_DISABLED")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			env.Host.Cloud = tt.cloud
+			r, ok := imdsAttemptsResult(env)
+			if ok != tt.want {
+				t.Fatalf("reported = %v, want %v (%+v)", ok, tt.want, r)
+			}
+			if !ok {
+				return
+			}
+			if r.ID != "sdk.imds_attempts" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(r.Message, want) {
+					t.Errorf("%q does not contain %q", r.Message, want)
+				}
+			}
+		})
+	}
+}
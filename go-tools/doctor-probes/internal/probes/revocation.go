@@ -0,0 +1,142 @@
+package probes
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// revocationEndpoint is an OCSP responder or CRL distribution point named
+// by a certificate in the served chain.
+type revocationEndpoint struct {
+	Kind string // "OCSP" or "CRL"
+	URL  string
+	Err  error
+}
+
+// revocationEndpoints returns the OCSP and CRL URLs of every certificate the
+// endpoint at addr serves, deduplicated, in chain order.
+func revocationEndpoints(env Env, addr string, proxy *url.URL) ([]revocationEndpoint, error) {
+	// Trust is checked by HTTPS Connectivity; here only the chain matters.
+	state, err := tlsHandshake(env, addr, proxy, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var endpoints []revocationEndpoint
+	add := func(kind, u string) {
+		if !seen[u] && strings.HasPrefix(u, "http") {
+			seen[u] = true
+			endpoints = append(endpoints, revocationEndpoint{Kind: kind, URL: u})
+		}
+	}
+	for _, cert := range state.PeerCertificates {
+		for _, u := range cert.OCSPServer {
+			add("OCSP", u)
+		}
+		for _, u := range cert.CRLDistributionPoints {
+			add("CRL", u)
+		}
+	}
+	return endpoints, nil
+}
+
+// probeRevocationEndpoint sends a HEAD request; any HTTP answer means the
+// responder is reachable. The proxy environment is honored, as the OS
+// revocation fetcher typically uses the system proxy.
+func probeRevocationEndpoint(env Env, rawURL string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Transport: env.Engine.limited(&http.Transport{Proxy: http.ProxyFromEnvironment, DisableKeepAlives: true}),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	logProbe("revocation", rawURL, start, err)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return &httpStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// revocationResult probes the certificate revocation endpoints of the
+// Bedrock runtime chain. Go does not check revocation, so a blocked
+// responder only hurts clients whose OS enforces it; this is never worse
+// than a warning.
+func revocationResult(env Env, bedrockURL string) CheckResult {
+	result := CheckResult{ID: "tls.revocation", Name: "Certificate Revocation Endpoints"}
+
+	host, addr, err := endpointAddr(bedrockURL)
+	if err == nil {
+		var proxy *url.URL
+		if proxy, err = probeProxy(host); err == nil {
+			var endpoints []revocationEndpoint
+			if endpoints, err = revocationEndpoints(env, addr, proxy); err == nil {
+				return revocationProbeResult(env, result, addr, endpoints)
+			}
+		}
+	}
+	result.Status, result.Code = "warn", classifyNetError(err)
+	result.Message = fmt.Sprintf("Could not read the certificate chain of %s: %v", addr, err)
+	result.Fix = "See HTTPS Connectivity"
+	return result
+}
+
+func revocationProbeResult(env Env, result CheckResult, addr string, endpoints []revocationEndpoint) CheckResult {
+	if len(endpoints) == 0 {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("The certificate chain of %s names no OCSP or CRL endpoints", addr)
+		return result
+	}
+
+	var wg sync.WaitGroup
+	for i := range endpoints {
+		wg.Add(1)
+		go func(e *revocationEndpoint) {
+			defer wg.Done()
+			e.Err = probeRevocationEndpoint(env, e.URL)
+		}(&endpoints[i])
+	}
+	wg.Wait()
+
+	var reached, blocked []string
+	blockedHosts := map[string]bool{}
+	var hosts []string
+	for _, e := range endpoints {
+		if e.Err == nil {
+			reached = append(reached, fmt.Sprintf("%s %s", e.Kind, e.URL))
+			continue
+		}
+		blocked = append(blocked, fmt.Sprintf("%s %s (%v)", e.Kind, e.URL, e.Err))
+		if u, err := url.Parse(e.URL); err == nil && !blockedHosts[u.Host] {
+			blockedHosts[u.Host] = true
+			hosts = append(hosts, u.Host)
+		}
+	}
+
+	if len(blocked) == 0 {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Reached all %d revocation endpoints of %s: %s", len(reached), addr, strings.Join(reached, ", "))
+		return result
+	}
+	result.Status, result.Code = "warn", codeRevocationBlocked
+	result.Message = fmt.Sprintf("%d of %d revocation endpoints of %s are unreachable: %s", len(blocked), len(endpoints), addr, strings.Join(blocked, "; "))
+	result.Fix = fmt.Sprintf("Where the OS enforces certificate revocation checks (common on locked-down Windows images), TLS to Bedrock works sometimes and fails after AWS rotates its certificate. Allow outbound HTTP (port 80) to %s", strings.Join(hosts, ", "))
+	return result
+}
@@ -0,0 +1,136 @@
+package probes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// revocationServer starts a TLS server whose certificate names the given
+// OCSP responders and CRL distribution points.
+func revocationServer(t *testing.T, ocsp, crl []string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"localhost"},
+		OCSPServer:            ocsp,
+		CRLDistributionPoints: crl,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRevocationEndpoints(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	withProxyEnv(t, nil)
+	u := revocationServer(t,
+		[]string{"http://ocsp.example", "ldap://ocsp.example"},
+		[]string{"http://crl.example/a.crl", "http://ocsp.example"})
+	_, addr, _ := endpointAddr(u)
+
+	got, err := revocationEndpoints(env, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []revocationEndpoint{{Kind: "OCSP", URL: "http://ocsp.example"}, {Kind: "CRL", URL: "http://crl.example/a.crl"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("revocationEndpoints = %+v, want HTTP URLs only, deduplicated: %+v", got, want)
+	}
+}
+
+func TestRevocationProbeResult(t *testing.T) {
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://127.0.0.1:1/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	t.Cleanup(responder.Close)
+	proxyAuth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	t.Cleanup(proxyAuth.Close)
+
+	tests := []struct {
+		name      string
+		endpoints []revocationEndpoint
+		status    string
+		contains  []string
+	}{
+		{name: "no endpoints", status: "pass", contains: []string{"names no OCSP or CRL endpoints"}},
+		{
+			name:      "any HTTP answer counts",
+			endpoints: []revocationEndpoint{{Kind: "OCSP", URL: responder.URL + "/ocsp"}, {Kind: "CRL", URL: responder.URL + "/redirect"}},
+			status:    "pass",
+			contains:  []string{"Reached all 2 revocation endpoints of bedrock:443"},
+		},
+		{
+			name: "blocked endpoints",
+			endpoints: []revocationEndpoint{
+				{Kind: "OCSP", URL: responder.URL + "/ocsp"},
+				{Kind: "CRL", URL: "http://127.0.0.1:1/a.crl"},
+				{Kind: "CRL", URL: "http://127.0.0.1:1/b.crl"},
+				{Kind: "OCSP", URL: proxyAuth.URL},
+			},
+			status: "warn",
+			contains: []string{
+				"3 of 4 revocation endpoints of bedrock:443 are unreachable: CRL http://127.0.0.1:1/a.crl (",
+				"Allow outbound HTTP (port 80) to 127.0.0.1:1, " + strings.TrimPrefix(proxyAuth.URL, "http://"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, nil)
+			r := revocationProbeResult(env, CheckResult{ID: "tls.revocation"}, "bedrock:443", tt.endpoints)
+			if r.Status != tt.status || (r.Status == "warn") != (r.Code == codeRevocationBlocked) {
+				t.Errorf("got %s %s (%s), want %s", r.Status, r.Code, r.Message, tt.status)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRevocationResultUnreachable(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	withProxyEnv(t, nil)
+	r := revocationResult(env, "https://127.0.0.1:1")
+	if r.ID != "tls.revocation" || r.Status != "warn" || r.Code != codeConnectRefused || !strings.HasPrefix(r.Message, "Could not read the certificate chain of 127.0.0.1:1") {
+		t.Errorf("got %s %s %s (%s), want a refused warning", r.ID, r.Status, r.Code, r.Message)
+	}
+}
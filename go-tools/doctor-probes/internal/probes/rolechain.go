@@ -0,0 +1,269 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// roleHop is one profile of an assume-role chain and the role it assumes.
+type roleHop struct {
+	Profile    string
+	RoleARN    string
+	ExternalID string
+	MFASerial  string
+}
+
+// roleChain is the chain declared by role_arn/source_profile: where the
+// first credentials come from, then the roles assumed in order.
+type roleChain struct {
+	Base    string // profile holding the first credentials; "" with credential_source
+	BaseVia string // static keys, SSO, credential_process, or credential_source X
+	Hops    []roleHop
+}
+
+func (c roleChain) String() string {
+	parts := []string{fmt.Sprintf("%s (%s)", c.Base, c.BaseVia)}
+	if c.Base == "" {
+		parts[0] = c.BaseVia
+	}
+	for _, h := range c.Hops {
+		parts = append(parts, fmt.Sprintf("%s (profile %s)", h.RoleARN, h.Profile))
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// profileCredentialVia describes how a profile without role_arn supplies
+// credentials, or "" when it supplies none.
+func profileCredentialVia(keys map[string]string) string {
+	switch {
+	case keys["aws_access_key_id"] != "":
+		return "static keys"
+	case keys["sso_session"] != "" || keys["sso_start_url"] != "":
+		return "SSO"
+	case keys["credential_process"] != "":
+		return "credential_process"
+	}
+	return ""
+}
+
+// walkRoleChain follows source_profile from profile through the shared
+// config sections to the credentials at its root. It does the walk itself,
+// rather than leaving it to the SDK, so that a broken link can be named.
+func walkRoleChain(sections map[string]map[string]string, profile string) (roleChain, error) {
+	var chain roleChain
+	var path []string
+	name := profile
+walk:
+	for {
+		for _, p := range path {
+			if p == name {
+				return chain, fmt.Errorf("circular source_profile chain: %s -> %s", strings.Join(path, " -> "), name)
+			}
+		}
+		keys, ok := sections[name]
+		if !ok {
+			return chain, fmt.Errorf("profile %s names source_profile %s, which does not exist", path[len(path)-1], name)
+		}
+		path = append(path, name)
+
+		role := keys["role_arn"]
+		if role == "" {
+			chain.Base, chain.BaseVia = name, profileCredentialVia(keys)
+			if chain.BaseVia == "" {
+				return chain, fmt.Errorf("source profile %s has no credentials (no keys, SSO, or credential_process)", name)
+			}
+			break
+		}
+		chain.Hops = append(chain.Hops, roleHop{Profile: name, RoleARN: role, ExternalID: keys["external_id"], MFASerial: keys["mfa_serial"]})
+
+		source, credentialSource := keys["source_profile"], keys["credential_source"]
+		switch {
+		case source != "" && credentialSource != "":
+			return chain, fmt.Errorf("profile %s sets both source_profile and credential_source", name)
+		case credentialSource != "":
+			chain.BaseVia = "credential_source " + credentialSource
+			break walk
+		case source == "":
+			return chain, fmt.Errorf("profile %s sets role_arn but neither source_profile nor credential_source", name)
+		case source == name && keys["aws_access_key_id"] != "":
+			// A profile may assume its role with its own static keys.
+			chain.Base, chain.BaseVia = name, "static keys"
+			break walk
+		}
+		name = source
+	}
+
+	// Assumed from the root outwards
+	for i, j := 0, len(chain.Hops)-1; i < j; i, j = i+1, j-1 {
+		chain.Hops[i], chain.Hops[j] = chain.Hops[j], chain.Hops[i]
+	}
+	return chain, nil
+}
+
+// staticProvider serves fixed credentials.
+func staticProvider(creds aws.Credentials) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return creds, nil
+	})
+}
+
+// roleChainResults validates the assume-role chain of the active profile hop
+// by hop: the root credentials, then sts:AssumeRole for each role with the
+// credentials of the hop before. It returns nothing when the active profile
+// does not assume a role.
+func roleChainResults(ctx context.Context, env Env, region string) []CheckResult {
+	profile := ActiveProfile()
+	sections, err := sharedConfigSections()
+	if err != nil || sections[profile]["role_arn"] == "" {
+		return nil
+	}
+	result := CheckResult{ID: "auth.role_chain", Name: "Assume-Role Chain"}
+
+	chain, err := walkRoleChain(sections, profile)
+	if err != nil {
+		result.Status, result.Code = "fail", codeRoleChainBroken
+		result.Message = fmt.Sprintf("Profile %s: %v", profile, err)
+		result.Fix = "Fix role_arn/source_profile in ~/.aws/config so the chain ends at a profile with credentials"
+		return []CheckResult{result}
+	}
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		result.Status = "skip"
+		result.Message = fmt.Sprintf("Profile %s chains %s, but AWS_ACCESS_KEY_ID in the environment takes precedence, so the chain is not used", profile, chain)
+		return []CheckResult{result}
+	}
+
+	// The SDK refuses to load a profile with mfa_serial without a token
+	// prompt, so such a chain is reported without being exercised.
+	hops := chain.Hops
+	for i, hop := range hops {
+		if hop.MFASerial != "" {
+			result.Status, result.Code = "warn", codeRoleChainMFA
+			result.Message = fmt.Sprintf("Profile %s: hop %d (%s, profile %s) requires an MFA code for %s; Claude Code cannot prompt for one, so it cannot use this chain as is", profile, i+1, hop.RoleARN, hop.Profile, hop.MFASerial)
+			result.Fix = "Have the awsAuthRefresh command in Claude Code settings (or a credential_process) prompt for the MFA code and cache the session"
+			return []CheckResult{result}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	root := chain.Base
+	if root == "" {
+		root = hops[0].Profile
+	}
+	cfg, err := profileConfig(ctx, env, region, root)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config for profile %s: %v", root, err)
+		return []CheckResult{result}
+	}
+
+	// Root credentials. With credential_source the SDK resolves them and
+	// assumes the first role in one step, so that hop is checked as a whole.
+	var creds aws.Credentials
+	switch {
+	case chain.Base == "":
+		creds, err = cfg.Credentials.Retrieve(ctx)
+		if err != nil {
+			result.Status = "fail"
+			result.Message = fmt.Sprintf("Profile %s: hop 1 (%s, then %s) failed: %v", profile, chain.BaseVia, hops[0].RoleARN, err)
+			result.setAWSError(err, "sts:AssumeRole")
+			return []CheckResult{result}
+		}
+		hops = hops[1:]
+	case chain.Base == hops[0].Profile:
+		keys := sections[chain.Base]
+		creds = aws.Credentials{AccessKeyID: keys["aws_access_key_id"], SecretAccessKey: keys["aws_secret_access_key"], SessionToken: keys["aws_session_token"]}
+	default:
+		creds, err = cfg.Credentials.Retrieve(ctx)
+		if err != nil {
+			result.Status = "fail"
+			result.Message = fmt.Sprintf("Profile %s: source profile %s (%s) has no usable credentials: %v", profile, chain.Base, chain.BaseVia, err)
+			result.Fix = fmt.Sprintf("Fix the credentials of profile %s", chain.Base)
+			result.setAWSError(err, "sts:AssumeRole")
+			return []CheckResult{result}
+		}
+	}
+
+	principal := ""
+	for i, hop := range hops {
+		n := len(chain.Hops) - len(hops) + i + 1
+		hopCfg := cfg.Copy()
+		hopCfg.Credentials = staticProvider(creds)
+		input := &sts.AssumeRoleInput{
+			RoleArn:         aws.String(hop.RoleARN),
+			RoleSessionName: aws.String("bcce-doctor"),
+			DurationSeconds: aws.Int32(900),
+		}
+		if hop.ExternalID != "" {
+			input.ExternalId = aws.String(hop.ExternalID)
+		}
+		start := time.Now()
+		out, err := env.STS(hopCfg).AssumeRole(ctx, input)
+		logProbe("sts_assume_role", hop.RoleARN, start, err)
+		if err != nil {
+			if principal == "" {
+				if identity, idErr := CallerIdentity(ctx, env, hopCfg); idErr == nil {
+					principal = aws.ToString(identity.Arn)
+				}
+			}
+			return []CheckResult{roleHopFailure(env, result, profile, n, hop, principal, err)}
+		}
+		creds = aws.Credentials{
+			AccessKeyID:     aws.ToString(out.Credentials.AccessKeyId),
+			SecretAccessKey: aws.ToString(out.Credentials.SecretAccessKey),
+			SessionToken:    aws.ToString(out.Credentials.SessionToken),
+		}
+		principal = aws.ToString(out.AssumedRoleUser.Arn)
+	}
+
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("Profile %s: %s; every role was assumed", profile, chain)
+	return []CheckResult{result}
+}
+
+// profileConfig loads the SDK config of one profile, which need not be the
+// active one.
+func profileConfig(ctx context.Context, env Env, region, profile string) (aws.Config, error) {
+	optFns := append([]func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithRetryer(sdkRetryer),
+		config.WithSharedConfigProfile(profile),
+	}, env.AWSOptions...)
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	return env.Engine.limitedConfig(cfg), nil
+}
+
+// roleHopFailure reports the hop whose sts:AssumeRole failed and why.
+func roleHopFailure(env Env, result CheckResult, profile string, n int, hop roleHop, principal string, err error) CheckResult {
+	result.Status = "fail"
+	result.Message = fmt.Sprintf("Profile %s: hop %d, assuming %s (profile %s), failed: %v", profile, n, hop.RoleARN, hop.Profile, err)
+	result.setAWSError(err, "sts:AssumeRole")
+	result.attachDiagnostics(env, awsDiagnostics(err))
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "AccessDenied" {
+		return result
+	}
+	result.Code = codeRoleChainAssume
+	if principal == "" {
+		principal = "the previous hop's identity"
+	}
+	result.Fix = fmt.Sprintf("The trust policy of %s must allow %s to call sts:AssumeRole", hop.RoleARN, principal)
+	if hop.ExternalID == "" {
+		result.Fix += "; if it requires an external ID, set external_id on profile " + hop.Profile
+	}
+	result.Fix += "; if it requires MFA (aws:MultiFactorAuthPresent), set mfa_serial on profile " + hop.Profile
+	return result
+}
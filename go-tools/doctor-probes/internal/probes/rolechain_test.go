@@ -0,0 +1,249 @@
+package probes
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestWalkRoleChain(t *testing.T) {
+	sections := map[string]map[string]string{
+		"base":     {"aws_access_key_id": "AKIA"},
+		"sso":      {"sso_session": "corp"},
+		"empty":    {"region": "us-east-1"},
+		"dev":      {"role_arn": "arn:dev", "source_profile": "base"},
+		"admin":    {"role_arn": "arn:admin", "source_profile": "dev", "external_id": "x"},
+		"via-sso":  {"role_arn": "arn:sso", "source_profile": "sso"},
+		"ec2":      {"role_arn": "arn:ec2", "credential_source": "Ec2InstanceMetadata"},
+		"self":     {"role_arn": "arn:self", "source_profile": "self", "aws_access_key_id": "AKIA"},
+		"dangling": {"role_arn": "arn:d", "source_profile": "nope"},
+		"loop-a":   {"role_arn": "arn:a", "source_profile": "loop-b"},
+		"loop-b":   {"role_arn": "arn:b", "source_profile": "loop-a"},
+		"both":     {"role_arn": "arn:both", "source_profile": "base", "credential_source": "Environment"},
+		"neither":  {"role_arn": "arn:n"},
+		"no-creds": {"role_arn": "arn:nc", "source_profile": "empty"},
+	}
+	tests := []struct {
+		profile string
+		want    string
+		err     string
+	}{
+		{profile: "dev", want: "base (static keys) -> arn:dev (profile dev)"},
+		{profile: "admin", want: "base (static keys) -> arn:dev (profile dev) -> arn:admin (profile admin)"},
+		{profile: "via-sso", want: "sso (SSO) -> arn:sso (profile via-sso)"},
+		{profile: "ec2", want: "credential_source Ec2InstanceMetadata -> arn:ec2 (profile ec2)"},
+		{profile: "self", want: "self (static keys) -> arn:self (profile self)"},
+		{profile: "dangling", err: "profile dangling names source_profile nope, which does not exist"},
+		{profile: "loop-a", err: "circular source_profile chain: loop-a -> loop-b -> loop-a"},
+		{profile: "both", err: "profile both sets both source_profile and credential_source"},
+		{profile: "neither", err: "profile neither sets role_arn but neither source_profile nor credential_source"},
+		{profile: "no-creds", err: "source profile empty has no credentials (no keys, SSO, or credential_process)"},
+	}
+	for _, tt := range tests {
+		chain, err := walkRoleChain(sections, tt.profile)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: err = %v, want %s", tt.profile, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || chain.String() != tt.want {
+			t.Errorf("%s: chain = %s, %v; want %s", tt.profile, chain, err, tt.want)
+		}
+	}
+	if chain, _ := walkRoleChain(sections, "admin"); chain.Hops[1].ExternalID != "x" {
+		t.Errorf("external_id not kept: %+v", chain.Hops)
+	}
+}
+
+// chainSTS answers AssumeRole for every role except those in deny, and
+// records the access key each call was signed with.
+func chainSTS(key string, deny map[string]bool, mu *sync.Mutex, calls *[]string) *probestest.STS {
+	return &probestest.STS{
+		Identity: &sts.GetCallerIdentityOutput{Arn: aws.String("arn:aws:iam::123456789012:user/" + key)},
+		AssumeRoleFunc: func(in *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+			role := aws.ToString(in.RoleArn)
+			mu.Lock()
+			*calls = append(*calls, strings.TrimSpace(key+" -> "+role+" "+aws.ToString(in.ExternalId)))
+			mu.Unlock()
+			if deny[role] {
+				return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform sts:AssumeRole"}
+			}
+			name := role[strings.LastIndex(role, "/")+1:]
+			return &sts.AssumeRoleOutput{
+				Credentials:     &ststypes.Credentials{AccessKeyId: aws.String("ASIA" + strings.ToUpper(name)), SecretAccessKey: aws.String("s"), SessionToken: aws.String("t")},
+				AssumedRoleUser: &ststypes.AssumedRoleUser{Arn: aws.String("arn:aws:sts::123456789012:assumed-role/" + name + "/bcce-doctor")},
+			}, nil
+		},
+	}
+}
+
+func TestRoleChainResults(t *testing.T) {
+	const config = `[profile plain]
+region = us-east-1
+
+[profile dev]
+role_arn = arn:aws:iam::123456789012:role/dev
+source_profile = base
+
+[profile admin]
+role_arn = arn:aws:iam::123456789012:role/admin
+source_profile = dev
+
+[profile partner]
+role_arn = arn:aws:iam::210987654321:role/partner
+source_profile = dev
+external_id = abc
+
+[profile mfa]
+role_arn = arn:aws:iam::123456789012:role/mfa
+source_profile = base
+mfa_serial = arn:aws:iam::123456789012:mfa/alice
+
+[profile self]
+role_arn = arn:aws:iam::123456789012:role/self
+source_profile = self
+
+[profile ec2]
+role_arn = arn:aws:iam::123456789012:role/ec2
+credential_source = Ec2InstanceMetadata
+
+[profile broken]
+role_arn = arn:aws:iam::123456789012:role/broken
+source_profile = missing
+`
+	const credentials = "[base]\naws_access_key_id = AKIABASE\naws_secret_access_key = s\n\n[self]\naws_access_key_id = AKIASELF\naws_secret_access_key = s\n"
+	tests := []struct {
+		name     string
+		profile  string
+		envKeys  bool
+		deny     []string
+		status   string // empty means no result
+		code     string
+		calls    []string
+		contains []string
+	}{
+		{name: "no role", profile: "plain"},
+		{
+			name:     "broken chain",
+			profile:  "broken",
+			status:   "fail",
+			code:     codeRoleChainBroken,
+			contains: []string{"Profile broken: profile broken names source_profile missing, which does not exist"},
+		},
+		{
+			name:     "environment keys win",
+			profile:  "dev",
+			envKeys:  true,
+			status:   "skip",
+			contains: []string{"AWS_ACCESS_KEY_ID in the environment takes precedence"},
+		},
+		{
+			name:     "MFA",
+			profile:  "mfa",
+			status:   "warn",
+			code:     codeRoleChainMFA,
+			contains: []string{"hop 1 (arn:aws:iam::123456789012:role/mfa, profile mfa) requires an MFA code for arn:aws:iam::123456789012:mfa/alice"},
+		},
+		{
+			name:     "two hops",
+			profile:  "admin",
+			status:   "pass",
+			calls:    []string{"AKIABASE -> arn:aws:iam::123456789012:role/dev", "ASIADEV -> arn:aws:iam::123456789012:role/admin"},
+			contains: []string{"Profile admin: base (static keys) -> arn:aws:iam::123456789012:role/dev (profile dev) -> arn:aws:iam::123456789012:role/admin (profile admin); every role was assumed"},
+		},
+		{
+			name:     "own static keys",
+			profile:  "self",
+			status:   "pass",
+			calls:    []string{"AKIASELF -> arn:aws:iam::123456789012:role/self"},
+			contains: []string{"self (static keys)"},
+		},
+		{
+			name:    "second hop denied",
+			profile: "partner",
+			deny:    []string{"arn:aws:iam::210987654321:role/partner"},
+			status:  "fail",
+			code:    codeRoleChainAssume,
+			calls:   []string{"AKIABASE -> arn:aws:iam::123456789012:role/dev", "ASIADEV -> arn:aws:iam::210987654321:role/partner abc"},
+			contains: []string{
+				"hop 2, assuming arn:aws:iam::210987654321:role/partner (profile partner), failed",
+				"must allow arn:aws:sts::123456789012:assumed-role/dev/bcce-doctor to call sts:AssumeRole",
+				"set mfa_serial on profile partner",
+			},
+		},
+		{
+			name:     "first hop denied names the base identity",
+			profile:  "dev",
+			deny:     []string{"arn:aws:iam::123456789012:role/dev"},
+			status:   "fail",
+			code:     codeRoleChainAssume,
+			calls:    []string{"AKIABASE -> arn:aws:iam::123456789012:role/dev"},
+			contains: []string{"must allow arn:aws:iam::123456789012:user/AKIABASE", "set external_id on profile dev"},
+		},
+		{
+			name:     "credential_source without metadata",
+			profile:  "ec2",
+			status:   "fail",
+			contains: []string{"hop 1 (credential_source Ec2InstanceMetadata, then arn:aws:iam::123456789012:role/ec2) failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, nil)
+			os.WriteFile(os.Getenv("AWS_CONFIG_FILE"), []byte(config), 0o600)
+			os.WriteFile(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), []byte(credentials), 0o600)
+			if !tt.envKeys {
+				probestest.Unsetenv(t, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY")
+			}
+			t.Setenv("AWS_PROFILE", tt.profile)
+
+			deny := map[string]bool{}
+			for _, role := range tt.deny {
+				deny[role] = true
+			}
+			var (
+				mu    sync.Mutex
+				calls []string
+			)
+			env := NewEnv(RunSettings{})
+			env.STS = func(cfg aws.Config) STSClient {
+				creds, _ := cfg.Credentials.Retrieve(context.Background())
+				return chainSTS(creds.AccessKeyID, deny, &mu, &calls)
+			}
+
+			got := roleChainResults(context.Background(), env, "us-east-1")
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "auth.role_chain" || r.Status != tt.status || tt.code != "" && r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if strings.Join(calls, "\n") != strings.Join(tt.calls, "\n") {
+				t.Errorf("AssumeRole calls =\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(tt.calls, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// vpnInterfacePrefixes and vpnInterfaceWords recognize VPN adapters by name:
+// tunnel devices on macOS and Linux, and the adapter names VPN clients
+// register on Windows.
+var (
+	vpnInterfacePrefixes = []string{"utun", "tun", "tap", "wg", "ppp", "ipsec", "gpd", "cscotun", "tailscale", "zt", "nordlynx"}
+	vpnInterfaceWords    = []string{"vpn", "wireguard", "anyconnect", "globalprotect", "pangp", "fortinet", "forticlient", "openvpn", "zscaler", "netskope", "tap-windows", "wintun"}
+)
+
+// isVPNInterface reports whether an interface name looks like a VPN adapter.
+func isVPNInterface(name string) bool {
+	lower := strings.ToLower(name)
+	for _, p := range vpnInterfacePrefixes {
+		if strings.HasPrefix(lower, p) {
+			return true
+		}
+	}
+	for _, w := range vpnInterfaceWords {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceFor returns the name of the local interface that holds ip.
+func interfaceFor(ip net.IP) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// egressRouteResult reports which local interface a connection to the
+// Bedrock runtime (or to Claude Code's proxy for it) leaves through, whether
+// that is a VPN adapter, and whether the remote address is a published AWS
+// range. It tells users on a split-tunnel VPN which side of the tunnel
+// Bedrock traffic is on. Interface names are a heuristic, so the check is
+// informational and never fails.
+func egressRouteResult(ctx context.Context, env Env, bedrockURL string) CheckResult {
+	result := CheckResult{ID: "network.egress_route", Name: "Egress Route"}
+
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status = "skip"
+		result.Message = err.Error()
+		return result
+	}
+	target, via := addr, ""
+	if proxy, _ := probeProxy(host); proxy != nil {
+		target, via = proxyHostPort(proxy), proxyDisplay(proxy)
+	}
+
+	start := time.Now()
+	var conn net.Conn
+	err = env.Engine.do(ctx, target, func() (err error) {
+		conn, err = (&net.Dialer{Timeout: 5 * time.Second}).Dial("tcp", target)
+		return err
+	})
+	logProbe("route", target, start, err)
+	if err != nil {
+		result.Status = "skip"
+		result.Message = fmt.Sprintf("Could not connect to %s to see the route: %v", target, err)
+		return result
+	}
+	local := conn.LocalAddr().(*net.TCPAddr).IP
+	remote := conn.RemoteAddr().(*net.TCPAddr).IP
+	conn.Close()
+
+	iface := interfaceFor(local)
+	if iface == "" {
+		iface = "an unnamed interface"
+	}
+	route := fmt.Sprintf("%s (direct, not a VPN adapter)", iface)
+	if isVPNInterface(iface) {
+		route = "VPN interface " + iface
+	}
+
+	result.Status = "pass"
+	if via != "" {
+		result.Message = fmt.Sprintf("Bedrock traffic goes to the proxy %s (%s) via %s, local address %s; the proxy connects to AWS itself", via, remote, route, local)
+		return result
+	}
+	result.Message = fmt.Sprintf("Bedrock traffic to %s egresses via %s, local address %s", remote, route, local)
+	ip, _ := netip.AddrFromSlice(remote)
+	ranges, err := awsIPRanges(env)
+	switch r, ok := awsRangeFor(ranges, ip); {
+	case err != nil:
+		result.Message += fmt.Sprintf("; AWS IP ranges unavailable: %v", err)
+	case ok:
+		result.Message += fmt.Sprintf("; %s is in the published AWS range %s", remote, r)
+	default:
+		result.Message += fmt.Sprintf("; %s is not in the published AWS ranges (a private endpoint, or DNS pointing elsewhere)", remote)
+	}
+	return result
+}
@@ -0,0 +1,145 @@
+package probes
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIsVPNInterface(t *testing.T) {
+	tests := map[string]bool{
+		"utun3":                   true,
+		"wg0":                     true,
+		"tailscale0":              true,
+		"PANGP Virtual Ethernet":  true,
+		"Cisco AnyConnect Secure": true,
+		"eth0":                    false,
+		"en0":                     false,
+		"Wi-Fi":                   false,
+		"lo":                      false,
+	}
+	for name, want := range tests {
+		if got := isVPNInterface(name); got != want {
+			t.Errorf("isVPNInterface(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestProxyHostPort(t *testing.T) {
+	tests := map[string]string{
+		"http://proxy.corp:3128":   "proxy.corp:3128",
+		"http://proxy.corp":        "proxy.corp:80",
+		"https://proxy.corp":       "proxy.corp:443",
+		"socks5h://127.0.0.1:1080": "127.0.0.1:1080",
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := proxyHostPort(u); got != want {
+			t.Errorf("proxyHostPort(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestAWSRangeFor(t *testing.T) {
+	ranges := []awsIPRange{
+		{Prefix: netip.MustParsePrefix("3.0.0.0/8"), Region: "GLOBAL", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("3.80.0.0/12"), Region: "us-east-1", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("3.80.0.0/12"), Region: "us-east-1", Service: "EC2"},
+		{Prefix: netip.MustParsePrefix("2600:1f18::/33"), Region: "us-east-1", Service: "EC2"},
+	}
+	tests := []struct {
+		ip   string
+		want string // empty means no range
+	}{
+		{"3.82.1.2", "EC2 us-east-1 3.80.0.0/12"},
+		{"3.1.2.3", "AMAZON GLOBAL 3.0.0.0/8"},
+		{"::ffff:3.82.1.2", "EC2 us-east-1 3.80.0.0/12"},
+		{"2600:1f18::1", "EC2 us-east-1 2600:1f18::/33"},
+		{"10.0.0.1", ""},
+	}
+	for _, tt := range tests {
+		r, ok := awsRangeFor(ranges, netip.MustParseAddr(tt.ip))
+		if got := r.String(); ok != (tt.want != "") || ok && got != tt.want {
+			t.Errorf("awsRangeFor(%s) = %s, %v; want %q", tt.ip, got, ok, tt.want)
+		}
+	}
+}
+
+// withAWSIPRanges makes awsIPRanges return ranges and err without a download.
+func withAWSIPRanges(t *testing.T, ranges []awsIPRange, err error) {
+	t.Helper()
+	reset := func() {
+		awsIPRangesOnce = sync.Once{}
+		awsIPRangesList, awsIPRangesErr = nil, nil
+	}
+	reset()
+	t.Cleanup(reset)
+	awsIPRangesOnce.Do(func() { awsIPRangesList, awsIPRangesErr = ranges, err })
+}
+
+func TestEgressRouteResult(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	target := "https://" + ln.Addr().String()
+	loopback := interfaceFor(net.IPv4(127, 0, 0, 1))
+	if loopback == "" {
+		t.Skip("no interface holds 127.0.0.1")
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		env      map[string]string
+		ranges   []awsIPRange
+		status   string
+		contains []string
+	}{
+		{
+			name:     "direct, outside AWS",
+			url:      target,
+			status:   "pass",
+			contains: []string{"Bedrock traffic to 127.0.0.1 egresses via " + loopback + " (direct, not a VPN adapter), local address 127.0.0.1", "not in the published AWS ranges"},
+		},
+		{
+			name:     "direct, in an AWS range",
+			url:      target,
+			ranges:   []awsIPRange{{Prefix: netip.MustParsePrefix("127.0.0.0/8"), Region: "us-east-1", Service: "EC2"}},
+			status:   "pass",
+			contains: []string{"127.0.0.1 is in the published AWS range EC2 us-east-1 127.0.0.0/8"},
+		},
+		{
+			name:     "through a proxy",
+			url:      "https://bedrock-runtime.us-east-1.amazonaws.com",
+			env:      map[string]string{"HTTPS_PROXY": "http://user:secret@" + ln.Addr().String()},
+			status:   "pass",
+			contains: []string{"goes to the proxy http://" + ln.Addr().String() + " (127.0.0.1)", "the proxy connects to AWS itself"},
+		},
+		{name: "refused", url: "https://127.0.0.1:1", status: "skip", contains: []string{"Could not connect to 127.0.0.1:1 to see the route"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, tt.env)
+			withAWSIPRanges(t, tt.ranges, nil)
+			got := egressRouteResult(context.Background(), env, tt.url)
+			if got.ID != "network.egress_route" || got.Status != tt.status {
+				t.Errorf("got %s %s (%s), want %s", got.ID, got.Status, got.Message, tt.status)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got.Message, want) {
+					t.Errorf("%q does not contain %q", got.Message, want)
+				}
+			}
+			if strings.Contains(got.Message, "secret") {
+				t.Errorf("%q shows the proxy password", got.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// Claude Code sessions last hours; roles capped at the IAM default of one
+// hour expire mid-session.
+const minRoleSessionDuration = time.Hour
+
+// assumedRoleName returns the role name of an STS assumed-role ARN.
+func assumedRoleName(callerARN string) (string, bool) {
+	parsed, err := arn.Parse(callerARN)
+	if err != nil || parsed.Service != "sts" || !strings.HasPrefix(parsed.Resource, "assumed-role/") {
+		return "", false
+	}
+	return strings.Split(strings.TrimPrefix(parsed.Resource, "assumed-role/"), "/")[0], true
+}
+
+// sessionDurationResults reports the assumed role's maximum session duration
+// against the remaining lifetime of the current credentials. It returns
+// nothing when the caller is not an assumed role.
+func sessionDurationResults(ctx context.Context, env Env, region string) []CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil
+	}
+	identity, err := CallerIdentity(ctx, env, cfg)
+	if err != nil {
+		// Credential failures are reported by the Bedrock API access check.
+		return nil
+	}
+	roleName, ok := assumedRoleName(aws.ToString(identity.Arn))
+	if !ok {
+		return nil
+	}
+	result := CheckResult{ID: "auth.session_duration", Name: "Role Session Duration"}
+
+	remaining := "credentials do not report an expiry"
+	if creds, err := cfg.Credentials.Retrieve(ctx); err == nil && creds.CanExpire {
+		remaining = fmt.Sprintf("current credentials expire in %s (%s)",
+			time.Until(creds.Expires).Round(time.Minute), creds.Expires.UTC().Format(time.RFC3339))
+	}
+
+	out, err := env.IAM(cfg).GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if err != nil {
+		// Reading the role is a diagnostic extra; report what we can.
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Role %s: %s; maximum session duration unknown (iam:GetRole: %s)", roleName, remaining, awsErrorCode(err))
+		return []CheckResult{result}
+	}
+	maxDuration := time.Duration(aws.ToInt32(out.Role.MaxSessionDuration)) * time.Second
+
+	if maxDuration <= minRoleSessionDuration {
+		result.Status, result.Code = "warn", codeShortSession
+		result.Message = fmt.Sprintf("Role %s allows sessions of only %s; %s. Long Claude Code sessions will hit credential expiry mid-task", roleName, maxDuration, remaining)
+		result.Fix = fmt.Sprintf("Ask your IAM admin to raise MaxSessionDuration of %s to 4h or more (aws iam update-role --role-name %s --max-session-duration 14400), or verify that your credential refresh (SSO, credential_process) renews before expiry", roleName, roleName)
+		return []CheckResult{result}
+	}
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("Role %s allows sessions of up to %s; %s", roleName, maxDuration, remaining)
+	return []CheckResult{result}
+}
@@ -0,0 +1,86 @@
+package probes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestAssumedRoleName(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/Developer/alice", "Developer"},
+		{"arn:aws-us-gov:sts::123456789012:assumed-role/AWSReservedSSO_Admin_abc/bob@example.com", "AWSReservedSSO_Admin_abc"},
+		{"arn:aws:iam::123456789012:user/dev", ""},
+		{"arn:aws:iam::123456789012:role/Developer", ""},
+		{"not an arn", ""},
+	}
+	for _, tt := range tests {
+		got, ok := assumedRoleName(tt.arn)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("assumedRoleName(%q) = %q, %v; want %q", tt.arn, got, ok, tt.want)
+		}
+	}
+}
+
+func TestSessionDurationResults(t *testing.T) {
+	const sessionARN = "arn:aws:sts::123456789012:assumed-role/Developer/alice"
+	const roleARN = "arn:aws:iam::123456789012:role/Developer"
+	tests := []struct {
+		name     string
+		fake     *fakeIAM
+		status   string // empty means no result
+		code     string
+		contains []string
+	}{
+		{name: "IAM user", fake: &fakeIAM{caller: "arn:aws:iam::123456789012:user/dev"}},
+		{
+			name:     "one-hour role",
+			fake:     &fakeIAM{caller: sessionARN, roleARN: roleARN, maxSession: 3600},
+			status:   "warn",
+			code:     codeShortSession,
+			contains: []string{"Role Developer allows sessions of only 1h0m0s; credentials do not report an expiry", "--role-name Developer --max-session-duration 14400"},
+		},
+		{
+			name:     "twelve-hour role",
+			fake:     &fakeIAM{caller: sessionARN, roleARN: roleARN, maxSession: 43200},
+			status:   "pass",
+			contains: []string{"Role Developer allows sessions of up to 12h0m0s"},
+		},
+		{
+			name:     "GetRole denied",
+			fake:     &fakeIAM{caller: sessionARN},
+			status:   "pass",
+			contains: []string{"maximum session duration unknown (iam:GetRole: " + codeIAMAccessDenied + ")"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.AWS(t, tt.fake.ServeHTTP)
+			got := sessionDurationResults(context.Background(), NewEnv(RunSettings{}), "us-east-1")
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "auth.session_duration" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package probes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type settingsFile struct {
+	Scope string // "user", "project", "project_local"
+	Label string
+	Path  string
+}
+
+// SettingsFiles lists the Claude Code settings files in increasing order of
+// precedence.
+func SettingsFiles() []settingsFile {
+	var files []settingsFile
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, settingsFile{"user", "User", filepath.Join(home, ".claude", "settings.json")})
+	}
+	files = append(files,
+		settingsFile{"project", "Project", filepath.Join(".claude", "settings.json")},
+		settingsFile{"project_local", "Project Local", filepath.Join(".claude", "settings.local.json")},
+	)
+	return files
+}
+
+// Environment variables Claude Code reads for Bedrock, proxies, updates, and
+// telemetry.
+var knownSettingsEnv = []string{
+	"CLAUDE_CODE_USE_BEDROCK",
+	"CLAUDE_CODE_SKIP_BEDROCK_AUTH",
+	"CLAUDE_CODE_ENABLE_TELEMETRY",
+	"CLAUDE_CODE_MAX_OUTPUT_TOKENS",
+	"MAX_THINKING_TOKENS",
+	"DISABLE_PROMPT_CACHING",
+	"DISABLE_AUTOUPDATER",
+	"CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC",
+	"ANTHROPIC_MODEL",
+	"ANTHROPIC_SMALL_FAST_MODEL",
+	"ANTHROPIC_DEFAULT_OPUS_MODEL",
+	"ANTHROPIC_DEFAULT_SONNET_MODEL",
+	"ANTHROPIC_DEFAULT_HAIKU_MODEL",
+	"ANTHROPIC_BEDROCK_BASE_URL",
+	"AWS_BEARER_TOKEN_BEDROCK",
+	"AWS_REGION",
+	"AWS_PROFILE",
+	"HTTPS_PROXY",
+	"HTTP_PROXY",
+	"NO_PROXY",
+	"NODE_EXTRA_CA_CERTS",
+	"OTEL_METRICS_EXPORTER",
+	"OTEL_LOGS_EXPORTER",
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_PROTOCOL",
+	"OTEL_EXPORTER_OTLP_HEADERS",
+}
+
+// Settings env values that must never be echoed.
+var SecretSettingsEnv = map[string]bool{
+	"AWS_BEARER_TOKEN_BEDROCK":   true,
+	"AWS_SECRET_ACCESS_KEY":      true,
+	"AWS_SESSION_TOKEN":          true,
+	"OTEL_EXPORTER_OTLP_HEADERS": true,
+}
+
+// Bedrock model IDs, cross-region inference profile IDs, or ARNs.
+var bedrockModelID = regexp.MustCompile(`^(((us|eu|apac|us-gov|global)\.)?anthropic\.claude-[a-z0-9.-]+(:\d+)?|arn:aws[a-z-]*:bedrock:[a-z0-9-]+:\d*:[a-z-]+/.+)$`)
+
+// Aliases Claude Code accepts for the top-level "model" setting.
+var modelAliases = map[string]bool{"sonnet": true, "opus": true, "haiku": true, "opusplan": true, "default": true}
+
+// lineCol converts a byte offset in data to a 1-based line and column.
+func lineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - (bytes.LastIndexByte(before, '\n') + 1)
+	if col < 1 {
+		col = 1
+	}
+	return line, col
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// suggestEnvName returns the known variable that name is likely a typo of, if any.
+func suggestEnvName(name string) string {
+	upper := strings.ToUpper(name)
+	for _, known := range knownSettingsEnv {
+		if known == name {
+			return ""
+		}
+	}
+	for _, known := range knownSettingsEnv {
+		if upper == known || editDistance(upper, known) <= 2 {
+			return known
+		}
+	}
+	return ""
+}
+
+// validateSettings returns the problems found in one parsed settings file.
+func validateSettings(settings map[string]any) []string {
+	var problems []string
+
+	if model, ok := settings["model"]; ok {
+		s, isString := model.(string)
+		switch {
+		case !isString:
+			problems = append(problems, fmt.Sprintf(`"model" must be a string, got %s`, jsonType(model)))
+		case !modelAliases[s] && !bedrockModelID.MatchString(s):
+			problems = append(problems, fmt.Sprintf(`"model" %q is not a Bedrock model ID, inference profile ID, or ARN`, s))
+		}
+	}
+
+	rawEnv, ok := settings["env"]
+	if !ok {
+		return problems
+	}
+	env, ok := rawEnv.(map[string]any)
+	if !ok {
+		return append(problems, fmt.Sprintf(`"env" must be an object, got %s`, jsonType(rawEnv)))
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := env[name]
+		s, isString := value.(string)
+		if !isString {
+			hint := ""
+			if name == "CLAUDE_CODE_USE_BEDROCK" {
+				hint = ` (use "1")`
+			}
+			problems = append(problems, fmt.Sprintf(`env.%s must be a string, got %s%s`, name, jsonType(value), hint))
+			continue
+		}
+		if suggestion := suggestEnvName(name); suggestion != "" {
+			problems = append(problems, fmt.Sprintf("env.%s is not recognized; did you mean %s?", name, suggestion))
+			continue
+		}
+		switch {
+		case name == "CLAUDE_CODE_USE_BEDROCK" && s != "1":
+			problems = append(problems, fmt.Sprintf(`env.CLAUDE_CODE_USE_BEDROCK is %q; Bedrock mode needs "1"`, s))
+		case strings.HasPrefix(name, "ANTHROPIC_") && strings.HasSuffix(name, "_MODEL") && !bedrockModelID.MatchString(s):
+			problems = append(problems, fmt.Sprintf("env.%s %q is not a Bedrock model ID, inference profile ID, or ARN", name, s))
+		}
+	}
+	return problems
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case nil:
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+// settingsResults validates each settings file, then compares the merged env
+// block with the live environment.
+func settingsResults(env Env) []CheckResult {
+	var results []CheckResult
+	effective := make(map[string]string)
+	source := make(map[string]string)
+	found := false
+
+	for _, f := range SettingsFiles() {
+		data, err := os.ReadFile(f.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		found = true
+
+		id := "settings." + f.Scope
+		name := fmt.Sprintf("Claude Settings - %s", f.Label)
+		if err != nil {
+			results = append(results, CheckResult{ID: id, Name: name, Status: "warn", Message: err.Error()})
+			continue
+		}
+
+		var settings map[string]any
+		if err := json.Unmarshal(data, &settings); err != nil {
+			msg := err.Error()
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr):
+				line, col := lineCol(data, syntaxErr.Offset)
+				msg = fmt.Sprintf("%s:%d:%d: %v", f.Path, line, col, err)
+			case errors.As(err, &typeErr):
+				line, col := lineCol(data, typeErr.Offset)
+				msg = fmt.Sprintf("%s:%d:%d: top level must be an object", f.Path, line, col)
+			}
+			results = append(results, CheckResult{
+				ID:      id,
+				Code:    codeSettingsSyntax,
+				Name:    name,
+				Status:  "fail",
+				Message: msg,
+				Fix:     "Fix the JSON syntax; Claude Code ignores a settings file it cannot parse",
+			})
+			continue
+		}
+
+		if problems := validateSettings(settings); len(problems) > 0 {
+			results = append(results, CheckResult{
+				ID:      id,
+				Code:    codeSettingsInvalid,
+				Name:    name,
+				Status:  "warn",
+				Message: fmt.Sprintf("%s: %s", f.Path, strings.Join(problems, "; ")),
+				Fix:     fmt.Sprintf("Edit %s; env values must be strings", f.Path),
+			})
+		} else {
+			results = append(results, CheckResult{
+				ID:      id,
+				Name:    name,
+				Status:  "pass",
+				Message: fmt.Sprintf("%s is valid", f.Path),
+			})
+		}
+
+		if env, ok := settings["env"].(map[string]any); ok {
+			for k, v := range env {
+				if s, ok := v.(string); ok {
+					effective[k] = s
+					source[k] = f.Path
+				}
+			}
+		}
+	}
+
+	if !found {
+		return []CheckResult{{
+			ID:      "settings.files",
+			Name:    "Claude Settings",
+			Status:  "pass",
+			Message: "No settings.json files found; Claude Code uses the environment only",
+		}}
+	}
+
+	// Variables set in the shell take precedence over the settings env block.
+	keys := make([]string, 0, len(effective))
+	for k := range effective {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var overridden []string
+	for _, k := range keys {
+		if live, ok := os.LookupEnv(k); ok && live != effective[k] {
+			overridden = append(overridden, k)
+			effective[k] = live
+			source[k] = "environment"
+		}
+	}
+	if len(overridden) > 0 {
+		results = append(results, CheckResult{
+			ID:      "settings.env_override",
+			Code:    codeSettingsOverridden,
+			Name:    "Claude Settings - Environment Override",
+			Status:  "warn",
+			Message: fmt.Sprintf("The environment overrides settings.json for %s; the environment value wins", strings.Join(overridden, ", ")),
+			Fix:     "Unset the variable in your shell profile or align settings.json with it, so there is one source of truth",
+		})
+	}
+
+	if env.Verbose && len(keys) > 0 {
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			v := effective[k]
+			if SecretSettingsEnv[k] {
+				v = Redacted
+			}
+			parts = append(parts, fmt.Sprintf("%s=%s (%s)", k, v, source[k]))
+		}
+		results = append(results, CheckResult{
+			ID:      "settings.effective",
+			Name:    "Claude Settings - Effective",
+			Status:  "pass",
+			Message: RedactSecrets(strings.Join(parts, ", ")),
+		})
+	}
+
+	return results
+}
@@ -0,0 +1,244 @@
+package probes
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// chdir changes into dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	saved, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(saved) })
+}
+
+// withSettings gives the test an empty HOME and working directory, then
+// writes files (keyed by scope: user, project, project_local) into them.
+func withSettings(t *testing.T, files map[string]string) {
+	t.Helper()
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	chdir(t, project)
+	paths := map[string]string{
+		"user":          filepath.Join(home, ".claude", "settings.json"),
+		"project":       filepath.Join(project, ".claude", "settings.json"),
+		"project_local": filepath.Join(project, ".claude", "settings.local.json"),
+	}
+	for scope, content := range files {
+		path := paths[scope]
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLineCol(t *testing.T) {
+	data := []byte("{\n  \"a\": 1,\n  oops\n}")
+	tests := []struct {
+		offset    int64
+		line, col int
+	}{
+		{0, 1, 1},
+		{1, 1, 1},
+		{2, 2, 1},
+		{15, 3, 3},
+		{999, 4, 1},
+	}
+	for _, tt := range tests {
+		if line, col := lineCol(data, tt.offset); line != tt.line || col != tt.col {
+			t.Errorf("lineCol(%d) = %d:%d, want %d:%d", tt.offset, line, col, tt.line, tt.col)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"AWS_REGION", "AWS_REGION", 0},
+		{"AWS_REGOIN", "AWS_REGION", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"AWS_REGION":              "",
+		"aws_region":              "AWS_REGION",
+		"CLAUDE_CODE_USE_BEDROK":  "CLAUDE_CODE_USE_BEDROCK",
+		"ANTHROPIC_MODLE":         "ANTHROPIC_MODEL",
+		"MY_TEAM_SETTING":         "",
+		"DISABLE_AUTO_UPDATER":    "DISABLE_AUTOUPDATER",
+		"OTEL_EXPORTER_OTLP_PORT": "",
+	}
+	for name, want := range tests {
+		if got := suggestEnvName(name); got != want {
+			t.Errorf("suggestEnvName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     []string
+	}{
+		{name: "empty", settings: map[string]any{}},
+		{
+			name: "valid",
+			settings: map[string]any{
+				"model": "us.anthropic.claude-sonnet-4-20250514-v1:0",
+				"env":   map[string]any{"CLAUDE_CODE_USE_BEDROCK": "1", "ANTHROPIC_SMALL_FAST_MODEL": "anthropic.claude-3-5-haiku-20241022-v1:0", "TEAM": "x"},
+			},
+		},
+		{name: "alias", settings: map[string]any{"model": "opusplan"}},
+		{name: "ARN", settings: map[string]any{"model": "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc"}},
+		{
+			name:     "model not a string",
+			settings: map[string]any{"model": 4.0},
+			want:     []string{`"model" must be a string, got number`},
+		},
+		{
+			name:     "Anthropic API model name",
+			settings: map[string]any{"model": "claude-sonnet-4-20250514"},
+			want:     []string{`"model" "claude-sonnet-4-20250514" is not a Bedrock model ID, inference profile ID, or ARN`},
+		},
+		{
+			name:     "env not an object",
+			settings: map[string]any{"env": []any{"AWS_REGION=us-east-1"}},
+			want:     []string{`"env" must be an object, got array`},
+		},
+		{
+			name:     "boolean Bedrock switch",
+			settings: map[string]any{"env": map[string]any{"CLAUDE_CODE_USE_BEDROCK": true}},
+			want:     []string{`env.CLAUDE_CODE_USE_BEDROCK must be a string, got boolean (use "1")`},
+		},
+		{
+			name:     "Bedrock switch not 1",
+			settings: map[string]any{"env": map[string]any{"CLAUDE_CODE_USE_BEDROCK": "true"}},
+			want:     []string{`env.CLAUDE_CODE_USE_BEDROCK is "true"; Bedrock mode needs "1"`},
+		},
+		{
+			name:     "typo and bad model, sorted",
+			settings: map[string]any{"env": map[string]any{"AWS_REGOIN": "us-east-1", "ANTHROPIC_MODEL": "sonnet"}},
+			want: []string{
+				`env.ANTHROPIC_MODEL "sonnet" is not a Bedrock model ID, inference profile ID, or ARN`,
+				"env.AWS_REGOIN is not recognized; did you mean AWS_REGION?",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateSettings(tt.settings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsResults(t *testing.T) {
+	type want struct{ id, status, code, contains string }
+	tests := []struct {
+		name    string
+		files   map[string]string
+		env     map[string]string
+		verbose bool
+		want    []want
+	}{
+		{
+			name: "no files",
+			want: []want{{"settings.files", "pass", "", "No settings.json files found"}},
+		},
+		{
+			name:  "valid user settings",
+			files: map[string]string{"user": `{"env":{"CLAUDE_CODE_USE_BEDROCK":"1"}}`},
+			want:  []want{{"settings.user", "pass", "", "settings.json is valid"}},
+		},
+		{
+			name:  "syntax error with position",
+			files: map[string]string{"project": "{\n  \"env\": {\n    \"AWS_REGION\": \"us-east-1\",\n  }\n}"},
+			want:  []want{{"settings.project", "fail", codeSettingsSyntax, "settings.json:4:3: invalid character '}'"}},
+		},
+		{
+			name:  "top level not an object",
+			files: map[string]string{"project_local": `["model"]`},
+			want:  []want{{"settings.project_local", "fail", codeSettingsSyntax, "settings.local.json:1:1: top level must be an object"}},
+		},
+		{
+			name:  "invalid values",
+			files: map[string]string{"user": `{"env":{"CLAUDE_CODE_USE_BEDROCK":1}}`},
+			want:  []want{{"settings.user", "warn", codeSettingsInvalid, "got number (use \"1\")"}},
+		},
+		{
+			name:  "environment overrides the files",
+			files: map[string]string{"user": `{"env":{"AWS_REGION":"us-west-2"}}`, "project": `{"env":{"AWS_REGION":"eu-west-1"}}`},
+			env:   map[string]string{"AWS_REGION": "us-east-1"},
+			want: []want{
+				{"settings.user", "pass", "", ""},
+				{"settings.project", "pass", "", ""},
+				{"settings.env_override", "warn", codeSettingsOverridden, "overrides settings.json for AWS_REGION"},
+			},
+		},
+		{
+			name:    "verbose shows the effective env without secrets",
+			files:   map[string]string{"user": `{"env":{"AWS_REGION":"us-west-2","AWS_BEARER_TOKEN_BEDROCK":"ABSKsecret"}}`, "project_local": `{"env":{"AWS_REGION":"eu-west-1"}}`},
+			verbose: true,
+			want: []want{
+				{"settings.user", "pass", "", ""},
+				{"settings.project_local", "pass", "", ""},
+				{"settings.effective", "pass", "", "AWS_BEARER_TOKEN_BEDROCK=[REDACTED] ("},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withSettings(t, tt.files)
+			probestest.Unsetenv(t, "AWS_REGION", "CLAUDE_CODE_USE_BEDROCK", "AWS_BEARER_TOKEN_BEDROCK")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			env.Verbose = tt.verbose
+
+			got := settingsResults(env)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if got[i].ID != w.id || got[i].Status != w.status || got[i].Code != w.code || !strings.Contains(got[i].Message, w.contains) {
+					t.Errorf("result %d = %s %s %s %q; want %s %s %s containing %q",
+						i, got[i].ID, got[i].Status, got[i].Code, got[i].Message, w.id, w.status, w.code, w.contains)
+				}
+				if strings.Contains(got[i].Message, "ABSKsecret") {
+					t.Errorf("result %d leaks the bearer token: %q", i, got[i].Message)
+				}
+			}
+			if tt.verbose && !strings.Contains(got[len(got)-1].Message, "AWS_REGION=eu-west-1 (.claude/settings.local.json)") {
+				t.Errorf("effective env does not credit the highest-precedence file: %q", got[len(got)-1].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package probes
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// severitySuppress keeps a check's result in the report, marked suppressed,
+// but out of the console output and the exit code.
+const severitySuppress = "suppress"
+
+// SeverityRule is the policy for one check ID: the status its non-passing
+// results are reported with, or severitySuppress.
+type SeverityRule struct {
+	Status string // fail, warn, pass, or suppress
+	Source string // where it was set, for the result's message
+}
+
+// staticCheckIDs are the IDs of the built-in checks with a fixed ID.
+var staticCheckIDs = []string{
+	"auth.credential_process", "auth.credential_source", "auth.env_credentials",
+	"auth.instance_role", "auth.role_chain", "auth.session_duration",
+	"auth.shared_config_files", "auth.sso_oidc", "auth.sso_session", "auth.sts_endpoint",
+	"auth.web_identity_exchange", "auth.web_identity_token",
+	"auth_matrix.api_key", "auth_matrix.sigv4", "auth_matrix.summary",
+	"bedrock.api_access", "bedrock.api_key", "bedrock.benchmark", "bedrock.count_tokens", "bedrock.cross_account",
+	"bedrock.iam_simulation", "bedrock.invocation_logging", "bedrock.model_access",
+	"bedrock.model_id_shape", "bedrock.model_lifecycle", "bedrock.model_region",
+	"bedrock.profile_regions", "bedrock.service_quotas", "bedrock.signed_request",
+	"bedrock.throttling", "bedrock.unsigned_request", "bedrock.usage",
+	"clock.time_sync", "endpoint.override", "https.bedrock_runtime",
+	"local.aws_cli", "local.claude_cli", "local.claude_version", "local.node_version", "local.npm_prefix",
+	"network.concurrency", "network.connection_reuse", "network.egress_route", "network.idle_connection",
+	"network.ip_ranges", "network.middlebox", "network.no_proxy", "network.proxy_overhead",
+	"network.socks_proxy", "network.stream_buffering", "network.system_proxy",
+	"otel.headers", "otel.logs_endpoint", "otel.metrics_endpoint",
+	"payload.bedrock_runtime", "privatelink.private_dns", "privatelink.vpc_endpoint", "region",
+	"region.consistency", "sdk.imds_attempts", "sdk.retry_config",
+	"settings.effective", "settings.env_override", "settings.files",
+	"settings.project", "settings.project_local", "settings.user",
+	"small_model.access", "small_model.reachability", "small_model.regions",
+	"tls.alpn", "tls.certificate", "tls.inspection", "tls.os_trust", "tls.revocation",
+	"tls.version_policy",
+}
+
+// dynamicCheckGroups are the ID prefixes of checks named after an endpoint,
+// a config entry, or a plugin, whose IDs are only known when they run.
+var dynamicCheckGroups = []string{
+	"dns.", "dns_compare.", "dns_methods.", "ip_family.", "dual_stack.",
+	"config.dns.", "config.https.", "config.env.", "plugin:",
+}
+
+var checkIDSlug = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// validCheckID reports whether id is a built-in check, or a well-formed ID
+// in one of the dynamic groups.
+func validCheckID(id string) bool {
+	if slices.Contains(staticCheckIDs, id) {
+		return true
+	}
+	for _, group := range dynamicCheckGroups {
+		if rest, ok := strings.CutPrefix(id, group); ok && checkIDSlug.MatchString(rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSeverityRule checks one check ID and policy value.
+func validateSeverityRule(id, status string) error {
+	if !validCheckID(id) {
+		return fmt.Errorf("%q is not a check ID (e.g. bedrock.api_access, dns.bedrock_runtime; see the id field of --format json)", id)
+	}
+	switch status {
+	case "fail", "warn", "pass", severitySuppress:
+		return nil
+	}
+	return fmt.Errorf("%s: %q is not fail, warn, pass, or suppress", id, status)
+}
+
+// ParseSeverityFlags parses --severity values, each one or more
+// comma-separated id=status pairs.
+func ParseSeverityFlags(specs []string) (map[string]string, error) {
+	rules := map[string]string{}
+	for _, spec := range specs {
+		for _, pair := range strings.Split(spec, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			id, status, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("%q is not id=status", pair)
+			}
+			id, status = strings.TrimSpace(id), strings.TrimSpace(status)
+			if err := validateSeverityRule(id, status); err != nil {
+				return nil, err
+			}
+			rules[id] = status
+		}
+	}
+	return rules, nil
+}
+
+// ApplySeverityOverride applies the policy for r's check ID from overrides,
+// which map check IDs to their policy (the config's severity section and
+// --severity, which wins): a non-passing result is reported with the
+// configured status, or marked suppressed.
+func ApplySeverityOverride(r *CheckResult, overrides map[string]SeverityRule) {
+	rule, ok := overrides[r.ID]
+	if !ok || (r.Status != "warn" && r.Status != "fail") {
+		return
+	}
+	if rule.Status == severitySuppress {
+		r.Suppressed, r.Severity = true, severityInfo
+		r.Message += fmt.Sprintf(" (suppressed by %s)", rule.Source)
+		return
+	}
+	if r.Status == rule.Status {
+		return
+	}
+	r.Message += fmt.Sprintf(" (%s, reported as %s by %s)", r.Status, rule.Status, rule.Source)
+	r.Status = rule.Status
+}
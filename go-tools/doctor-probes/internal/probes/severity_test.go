@@ -0,0 +1,65 @@
+package probes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSeverityFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  map[string]string
+		err   string
+	}{
+		{
+			name:  "repeated and comma-separated",
+			specs: []string{"bedrock.throttling=warn, tls.revocation=suppress", "dns.bedrock_runtime=fail"},
+			want:  map[string]string{"bedrock.throttling": "warn", "tls.revocation": "suppress", "dns.bedrock_runtime": "fail"},
+		},
+		{
+			name:  "later wins, blanks skipped",
+			specs: []string{"region=warn,,", " region = pass "},
+			want:  map[string]string{"region": "pass"},
+		},
+		{
+			name:  "dynamic groups",
+			specs: []string{"config.https.internal_gateway=warn,plugin:vpn_check=suppress,dns_compare.sts=pass"},
+			want:  map[string]string{"config.https.internal_gateway": "warn", "plugin:vpn_check": "suppress", "dns_compare.sts": "pass"},
+		},
+		{name: "none", want: map[string]string{}},
+		{name: "missing status", specs: []string{"region"}, err: `"region" is not id=status`},
+		{name: "unknown check", specs: []string{"bedrock.api=warn"}, err: `"bedrock.api" is not a check ID`},
+		{name: "malformed dynamic ID", specs: []string{"dns.Bedrock Runtime=warn"}, err: "is not a check ID"},
+		{name: "empty dynamic ID", specs: []string{"dns.=warn"}, err: "is not a check ID"},
+		{name: "unknown status", specs: []string{"region=error"}, err: `region: "error" is not fail, warn, pass, or suppress`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSeverityFlags(tt.specs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSeverityFlags = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticCheckIDsAreValid(t *testing.T) {
+	seen := map[string]bool{}
+	for _, id := range staticCheckIDs {
+		if seen[id] {
+			t.Errorf("%s listed twice", id)
+		}
+		seen[id] = true
+		if !checkIDSlug.MatchString(id) {
+			t.Errorf("%s is not a well-formed check ID", id)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package probes
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// SharedConfigFiles returns the shared config and credentials file paths the
+// SDK reads.
+func SharedConfigFiles() (configFile, credentialsFile string) {
+	configFile = os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = config.DefaultSharedConfigFilename()
+	}
+	credentialsFile = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		credentialsFile = config.DefaultSharedCredentialsFilename()
+	}
+	return configFile, credentialsFile
+}
+
+// sharedConfigSections reads the keys of every profile in the shared config
+// and credentials files, as the SDK merges them: keys in the credentials file
+// win. Keys are lower-cased. Files that do not exist are skipped.
+func sharedConfigSections() (map[string]map[string]string, error) {
+	configFile, credentialsFile := SharedConfigFiles()
+	sections := map[string]map[string]string{}
+	for _, f := range []struct {
+		path     string
+		isConfig bool
+	}{{configFile, true}, {credentialsFile, false}} {
+		if err := readSharedConfigFile(f.path, f.isConfig, sections); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
+
+// readSharedConfigFile adds the profile sections of one file to sections. In
+// the config file profiles are [profile name] (or [default]); other sections
+// such as [sso-session name] are skipped. Indented sub-properties are too.
+func readSharedConfigFile(path string, isConfig bool, sections map[string]map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "["):
+			name := strings.TrimSpace(strings.Trim(line, "[]"))
+			current = nil
+			if isConfig {
+				if name != "default" && !strings.HasPrefix(name, "profile ") {
+					continue
+				}
+				name = strings.TrimSpace(strings.TrimPrefix(name, "profile "))
+			}
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			current = sections[name]
+		case current == nil || raw[0] == ' ' || raw[0] == '\t':
+			continue
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			current[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return scanner.Err()
+}
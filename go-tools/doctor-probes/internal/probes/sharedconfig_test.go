@@ -0,0 +1,58 @@
+package probes
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestSharedConfigSections(t *testing.T) {
+	probestest.AWS(t, nil)
+	config := `[default]
+region = us-east-1
+
+# comment
+[profile dev]
+Role_ARN = arn:aws:iam::123456789012:role/dev
+source_profile = base
+s3 =
+  max_concurrent_requests = 20
+
+[sso-session corp]
+sso_region = us-east-1
+
+[ profile  base ]
+region = us-west-2
+`
+	credentials := `[base]
+aws_access_key_id = AKIABASEEXAMPLE
+region = eu-west-1
+; comment
+[ci]
+aws_access_key_id = AKIACIEXAMPLE
+`
+	os.WriteFile(os.Getenv("AWS_CONFIG_FILE"), []byte(config), 0o600)
+	os.WriteFile(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), []byte(credentials), 0o600)
+
+	got, err := sharedConfigSections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"default": {"region": "us-east-1"},
+		"dev":     {"role_arn": "arn:aws:iam::123456789012:role/dev", "source_profile": "base", "s3": ""},
+		"base":    {"region": "eu-west-1", "aws_access_key_id": "AKIABASEEXAMPLE"},
+		"ci":      {"aws_access_key_id": "AKIACIEXAMPLE"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sharedConfigSections =\n%v\nwant\n%v", got, want)
+	}
+
+	os.Remove(os.Getenv("AWS_CONFIG_FILE"))
+	os.Remove(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"))
+	if got, err := sharedConfigSections(); err != nil || len(got) != 0 {
+		t.Errorf("missing files = %v, %v; want no sections and no error", got, err)
+	}
+}
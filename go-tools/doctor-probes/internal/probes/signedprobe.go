@@ -0,0 +1,266 @@
+package probes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body, which SigV4 signs for a
+// GET.
+var emptyPayloadHash = func() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}()
+
+// controlPlaneProbePath is a read-only control plane call every Claude Code
+// user needs to be allowed anyway.
+const controlPlaneProbePath = "/foundation-models?byProvider=anthropic"
+
+// controlPlaneURL returns the Bedrock control plane URL the raw probes call:
+// the endpoint override, or the regional (FIPS) endpoint.
+func controlPlaneURL(env Env, region string, fips bool) string {
+	if env.Endpoint != nil {
+		return strings.TrimSuffix(env.Endpoint.String(), "/") + controlPlaneProbePath
+	}
+	service := "bedrock"
+	if fips {
+		service = fipsService(service)
+	}
+	return "https://" + partitionForRegion(region).hostname(service, region) + controlPlaneProbePath
+}
+
+// awsReply is an AWS answer to a raw request, with the error body parsed.
+type awsReply struct {
+	StatusCode int
+	ErrorCode  string // e.g. "UnrecognizedClientException"; "" on success
+	Message    string
+	Date       time.Time // the server's clock, from the Date header
+	Diag       *Diagnostics
+}
+
+// err returns the reply as an SDK-style API error, so that it classifies
+// like the same answer to an SDK call, or nil on success.
+func (r *awsReply) err() error {
+	if r.StatusCode < 300 {
+		return nil
+	}
+	code := r.ErrorCode
+	if code == "" {
+		code = fmt.Sprintf("HTTP%d", r.StatusCode)
+	}
+	return &smithy.GenericAPIError{Code: code, Message: r.Message}
+}
+
+// parseAWSError reads the error code and message of an AWS JSON error
+// response: the code from x-amzn-ErrorType or the body's __type, either of
+// which may carry a namespace ("com.amazon.coral.service#...") or a suffix
+// (":http://internal.amazon.com/...").
+func parseAWSError(header http.Header, body []byte) (code, message string) {
+	var doc struct {
+		Type         string `json:"__type"`
+		Code         string `json:"code"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	_ = json.Unmarshal(body, &doc)
+
+	code = header.Get("x-amzn-ErrorType")
+	if code == "" {
+		code = doc.Type
+	}
+	if code == "" {
+		code = doc.Code
+	}
+	code, _, _ = strings.Cut(code, ":")
+	if i := strings.LastIndex(code, "#"); i >= 0 {
+		code = code[i+1:]
+	}
+	message = doc.Message
+	if message == "" {
+		message = doc.MessageUpper
+	}
+	return code, message
+}
+
+// requestAuth adds credentials to a raw request with the given body.
+type requestAuth func(ctx context.Context, req *http.Request, body []byte) error
+
+// sigV4Auth signs requests with SigV4. The signing name is "bedrock" for the
+// runtime, FIPS, and VPC endpoints too.
+func sigV4Auth(creds aws.Credentials, region string) requestAuth {
+	return func(ctx context.Context, req *http.Request, body []byte) error {
+		hash := emptyPayloadHash
+		if body != nil {
+			sum := sha256.Sum256(body)
+			hash = hex.EncodeToString(sum[:])
+		}
+		return v4.NewSigner().SignHTTP(ctx, creds, req, hash, "bedrock", region, time.Now())
+	}
+}
+
+// sendAWSProbe sends a raw request to target, authorized by auth when it is
+// not nil, and parses AWS's answer. A JSON body makes it a POST. An answer
+// not from AWS is an *httpStatusError.
+func sendAWSProbe(ctx context.Context, env Env, target string, body []byte, auth requestAuth) (*awsReply, error) {
+	method := http.MethodGet
+	if body != nil {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth != nil {
+		if err := auth(ctx, req, body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := env.HTTP(req.URL.Hostname()).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := reachabilityError(resp); err != nil {
+		return nil, err
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	reply := &awsReply{StatusCode: resp.StatusCode, Diag: httpDiagnostics(resp)}
+	if resp.StatusCode >= 300 {
+		reply.ErrorCode, reply.Message = parseAWSError(resp.Header, respBody)
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		reply.Date = date
+	}
+	return reply, nil
+}
+
+// unsignedRequestResult sends the control plane request without a signature.
+// Any AWS error answer (403 MissingAuthenticationToken is expected) proves
+// DNS, the network path, and TLS work, whatever the credentials.
+func unsignedRequestResult(ctx context.Context, env Env, region string, fips bool) CheckResult {
+	result := CheckResult{ID: "bedrock.unsigned_request", Name: "Unsigned Control Plane Request"}
+	target := controlPlaneURL(env, region, fips)
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	start := time.Now()
+	reply, err := sendAWSProbe(ctx, env, target, nil, nil)
+	logProbe("https", target, start, err, "signed", false)
+	if err != nil {
+		result.Status, result.Code = "fail", classifyNetError(err)
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode != http.StatusProxyAuthRequired {
+			result.Code = codeHTTPStatus
+		}
+		result.Message = fmt.Sprintf("Network problem: an unsigned GET %s got no answer from AWS: %v", target, err)
+		result.Fix = "This fails before credentials are involved: check DNS, firewall, proxy, and VPC endpoint settings" + env.Host.proxyHint()
+		return result
+	}
+
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("Network and TLS are fine: AWS answered the unsigned GET %s with HTTP %d", target, reply.StatusCode)
+	if reply.ErrorCode != "" {
+		result.Message += fmt.Sprintf(" %s (%q)", reply.ErrorCode, reply.Message)
+	}
+	result.attachDiagnostics(env, reply.Diag)
+	return result
+}
+
+// signedRequestResult sends the same request signed with SigV4 and the
+// resolved credentials. Success proves signing, the clock, and the
+// credentials; the error AWS returns says which of them is wrong, without
+// the SDK's retries and wrapping in the way.
+func signedRequestResult(ctx context.Context, env Env, region string, fips bool) CheckResult {
+	result := CheckResult{ID: "bedrock.signed_request", Name: "Signed Control Plane Request"}
+	target := controlPlaneURL(env, region, fips)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "fail", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return result
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		result.Status = "fail"
+		result.Message = fmt.Sprintf("Credential problem: no credentials to sign with: %v", err)
+		result.Fix = "Configure credentials (aws configure sso) and export AWS_PROFILE"
+		result.setAWSError(err, "bedrock:ListFoundationModels")
+		return result
+	}
+	who := fmt.Sprintf("access key %s from %s", keySuffix(creds.AccessKeyID), creds.Source)
+
+	start := time.Now()
+	reply, err := sendAWSProbe(ctx, env, target, nil, sigV4Auth(creds, region))
+	logProbe("https", target, start, err, "signed", true)
+	if err != nil {
+		result.Status, result.Code = "fail", classifyNetError(err)
+		result.Message = fmt.Sprintf("Network problem: the signed GET %s got no answer from AWS: %v", target, err)
+		result.Fix = "If Unsigned Control Plane Request passed, a proxy or gateway treats signed requests differently; check its rules for Authorization headers" + env.Host.proxyHint()
+		return result
+	}
+	result.attachDiagnostics(env, reply.Diag)
+	apiErr := reply.err()
+	if apiErr == nil {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Signing, clock, and credentials are fine: AWS accepted the GET %s signed with %s (HTTP %d)", target, who, reply.StatusCode)
+		return result
+	}
+
+	detail := strings.TrimSpace(fmt.Sprintf("HTTP %d %s", reply.StatusCode, reply.ErrorCode)) + fmt.Sprintf(" (%q)", reply.Message)
+	skew := time.Duration(0)
+	if !reply.Date.IsZero() {
+		skew = time.Until(reply.Date).Round(time.Second)
+	}
+	switch {
+	case reply.ErrorCode == "InvalidSignatureException" && (strings.Contains(reply.Message, "expired") || strings.Contains(reply.Message, "not yet current")):
+		result.Status, result.Code = "fail", codeSignatureClock
+		result.Message = fmt.Sprintf("Clock problem: AWS rejected the signature's timestamp: %s", detail)
+		if skew != 0 {
+			result.Message += fmt.Sprintf("; this machine's clock is %s off from AWS's", skew.Abs())
+		}
+		result.Fix = "Signatures are valid for 5 minutes around AWS's time; sync the system clock (enable NTP / Set time automatically), and see Time Sync for why it drifted"
+	case reply.ErrorCode == "InvalidSignatureException":
+		result.Status, result.Code = "fail", codeSignatureRejected
+		result.Message = fmt.Sprintf("Signing problem: AWS computed a different signature for the request it received, signed with %s: %s", who, detail)
+		result.Fix = "Check that the secret key matches the access key ID; if it does, something on the path (proxy, gateway) rewrites the request's path, query, or headers" + env.Host.proxyHint()
+	default:
+		class := classifyAWSError(apiErr, "bedrock:ListFoundationModels")
+		result.Code, result.Fix, result.FixCommand = class.Code, class.Fix, class.FixCommand
+		switch {
+		case isAccessDenied(class.Code):
+			// The request was authenticated; what it may do is for Bedrock
+			// API Access to report.
+			result.Status = "pass"
+			result.Code, result.Fix, result.FixCommand = "", "", nil
+			result.Message = fmt.Sprintf("Signing, clock, and credentials are fine: AWS authenticated %s and denied the action, which is an authorization question (see Bedrock API Access): %s", who, detail)
+		case class.Code == codeCredentialsExpired || class.Code == codeCredentialsInvalid:
+			result.Status = "fail"
+			result.Message = fmt.Sprintf("Credential problem: AWS does not accept %s: %s", who, detail)
+		default:
+			result.Status = "warn"
+			result.Message = fmt.Sprintf("AWS answered the signed GET %s with %s", target, detail)
+		}
+	}
+	return result
+}
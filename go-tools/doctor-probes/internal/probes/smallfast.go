@@ -0,0 +1,138 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Failures of the small/fast model only degrade background work, so they
+// are never worse than a warning.
+const smallFastDegradeNote = "Claude Code uses this model for background tasks (titles, summaries), which will degrade; the main model is unaffected"
+
+// probeRuntimeRegion resolves and connects to the public Bedrock runtime
+// endpoint of region, with the usual retry budget.
+func probeRuntimeRegion(env Env, region string) (host string, err error) {
+	host = partitionForRegion(region).hostname("bedrock-runtime", region)
+	if _, err = retryProbe(func() error {
+		_, err := checkDNS(env, host)
+		return err
+	}); err != nil {
+		return host, fmt.Errorf("DNS: %w", err)
+	}
+	if _, err = retryProbe(func() error {
+		_, _, err := checkHTTPSConnectivity(env, "https://"+host)
+		return err
+	}); err != nil {
+		return host, fmt.Errorf("HTTPS: %w", err)
+	}
+	return host, nil
+}
+
+// smallFastModelResults checks ANTHROPIC_SMALL_FAST_MODEL and, when
+// ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION points elsewhere, that region's
+// runtime endpoint. It returns nothing when neither is set.
+func smallFastModelResults(env Env, region string) []CheckResult {
+	model := os.Getenv("ANTHROPIC_SMALL_FAST_MODEL")
+	regionOverride := os.Getenv("ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION")
+	if model == "" && regionOverride == "" {
+		return nil
+	}
+
+	smallRegion, source := region, "AWS_REGION"
+	if regionOverride != "" {
+		smallRegion, source = regionOverride, "ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION"
+	}
+	describe := func(m string) string {
+		if m == "" {
+			return "default"
+		}
+		return m
+	}
+	results := []CheckResult{{
+		ID:     "small_model.regions",
+		Name:   "Model Regions",
+		Status: "pass",
+		Message: fmt.Sprintf("ANTHROPIC_MODEL (%s) uses %s (AWS_REGION); ANTHROPIC_SMALL_FAST_MODEL (%s) uses %s (%s)",
+			describe(configuredModel()), region, describe(model), smallRegion, source),
+	}}
+
+	// The endpoint override routes every region through one endpoint.
+	if smallRegion != region && env.Endpoint == nil {
+		results = append(results, smallFastReachabilityResult(env, smallRegion))
+	}
+	if model != "" {
+		results = append(results, smallFastModelResult(env, model, smallRegion))
+	}
+	return results
+}
+
+func smallFastReachabilityResult(env Env, region string) CheckResult {
+	result := CheckResult{ID: "small_model.reachability", Name: "Small/Fast Model Region"}
+	host, err := probeRuntimeRegion(env, region)
+	if err != nil {
+		result.Status, result.Code = "warn", classifyNetError(err)
+		result.Message = fmt.Sprintf("Cannot reach %s: %v. %s", host, err, smallFastDegradeNote)
+		result.Fix = fmt.Sprintf("Allow %s through the firewall/proxy, or unset ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION", host)
+		return result
+	}
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("Reached %s", host)
+	return result
+}
+
+// smallFastModelResult validates the model ID and its access grant in
+// region the same way as the primary model.
+func smallFastModelResult(env Env, model, region string) CheckResult {
+	result := CheckResult{ID: "small_model.access", Name: "Small/Fast Model"}
+
+	if !bedrockModelID.MatchString(model) {
+		result.Status, result.Code = "warn", codeModelInvalid
+		result.Message = fmt.Sprintf("ANTHROPIC_SMALL_FAST_MODEL=%q is not a Bedrock model ID, inference profile ID, or ARN. %s", model, smallFastDegradeNote)
+		result.Fix = "Use a Bedrock ID such as us.anthropic.claude-3-5-haiku-20241022-v1:0"
+		return result
+	}
+	// Application inference profile ARNs do not name their model.
+	if strings.HasPrefix(model, "arn:") && !strings.Contains(model, "anthropic.") {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s in %s (inference profile ARN; model access not verified)", model, region)
+		return result
+	}
+
+	models, err := checkBedrockAccess(context.Background(), env, region)
+	if err == nil {
+		var grants []modelGrant
+		if grants, err = env.modelAccess(region, models); err == nil {
+			return smallFastGrantResult(result, model, region, grants)
+		}
+	}
+	result.Status = "warn"
+	result.Message = fmt.Sprintf("Cannot verify %s in %s: %v. %s", model, region, err, smallFastDegradeNote)
+	result.Fix = "Check AWS credentials and IAM permissions for bedrock:ListFoundationModels and bedrock:GetFoundationModelAvailability in " + region
+	result.setAWSError(err, "bedrock:ListFoundationModels and bedrock:GetFoundationModelAvailability")
+	result.attachDiagnostics(env, awsDiagnostics(err))
+	return result
+}
+
+func smallFastGrantResult(result CheckResult, model, region string, grants []modelGrant) CheckResult {
+	base := baseModelID(model)
+	for _, g := range grants {
+		if g.ModelID != base {
+			continue
+		}
+		if g.Granted {
+			result.Status = "pass"
+			result.Message = fmt.Sprintf("%s (%s) is granted in %s", model, g.Name, region)
+			return result
+		}
+		result.Status, result.Code = "warn", codeModelNotGranted
+		result.Message = fmt.Sprintf("%s (%s) is not granted in %s: %s. %s", model, g.Name, region, g.Reason, smallFastDegradeNote)
+		result.Fix = fmt.Sprintf("Request access to %s at %s", g.Name, modelAccessConsoleURL(region))
+		return result
+	}
+	result.Status, result.Code = "warn", codeNoModels
+	result.Message = fmt.Sprintf("%s is not offered in %s. %s", base, region, smallFastDegradeNote)
+	result.Fix = "Choose a model available in " + region + ", or set ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION to a region that offers it"
+	return result
+}
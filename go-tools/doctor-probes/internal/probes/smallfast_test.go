@@ -0,0 +1,117 @@
+package probes
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestSmallFastGrantResult(t *testing.T) {
+	grants := []modelGrant{
+		{ModelID: "anthropic.claude-3-5-haiku-20241022-v1:0", Name: "Claude 3.5 Haiku", Granted: true},
+		{ModelID: "anthropic.claude-3-haiku-20240307-v1:0", Name: "Claude 3 Haiku", Reason: "access not requested"},
+	}
+	tests := []struct {
+		model    string
+		status   string
+		code     string
+		contains string
+	}{
+		{"us.anthropic.claude-3-5-haiku-20241022-v1:0", "pass", "", "(Claude 3.5 Haiku) is granted in us-west-2"},
+		{"anthropic.claude-3-haiku-20240307-v1:0", "warn", codeModelNotGranted, "is not granted in us-west-2: access not requested"},
+		{"anthropic.claude-instant-v1", "warn", codeNoModels, "anthropic.claude-instant-v1 is not offered in us-west-2"},
+	}
+	for _, tt := range tests {
+		got := smallFastGrantResult(CheckResult{ID: "small_model.access"}, tt.model, "us-west-2", grants)
+		if got.ID != "small_model.access" || got.Status != tt.status || got.Code != tt.code || !strings.Contains(got.Message, tt.contains) {
+			t.Errorf("%s: got %s %s %q; want %s %s containing %q", tt.model, got.Status, got.Code, got.Message, tt.status, tt.code, tt.contains)
+		}
+	}
+}
+
+func TestSmallFastModelResults(t *testing.T) {
+	const haiku = "anthropic.claude-3-5-haiku-20241022-v1:0"
+	type want struct{ id, status, code string }
+	tests := []struct {
+		name     string
+		model    string
+		region   string // ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION
+		endpoint bool   // route through the fake Bedrock endpoint
+		want     []want
+		contains string
+	}{
+		{name: "neither set"},
+		{
+			name:     "invalid model ID",
+			model:    "claude-haiku",
+			want:     []want{{"small_model.regions", "pass", ""}, {"small_model.access", "warn", codeModelInvalid}},
+			contains: "which will degrade; the main model is unaffected",
+		},
+		{
+			name:     "application inference profile",
+			model:    "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc",
+			want:     []want{{"small_model.regions", "pass", ""}, {"small_model.access", "pass", ""}},
+			contains: "model access not verified",
+		},
+		{
+			name:     "unreachable override region",
+			region:   "nowhere-1",
+			want:     []want{{"small_model.regions", "pass", ""}, {"small_model.reachability", "warn", codeDNSNXDomain}},
+			contains: "Cannot reach bedrock-runtime.nowhere-1.amazonaws.com",
+		},
+		{
+			name:     "granted in the override region",
+			model:    "us." + haiku,
+			region:   "us-west-2",
+			endpoint: true,
+			want:     []want{{"small_model.regions", "pass", ""}, {"small_model.access", "pass", ""}},
+			contains: "is granted in us-west-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == "/foundation-models":
+					w.Write([]byte(`{"modelSummaries":[{"modelId":"` + haiku + `","modelName":"Claude 3.5 Haiku"}]}`))
+				case strings.HasPrefix(r.URL.Path, "/foundation-model-availability/"):
+					w.Write([]byte(`{"authorizationStatus":"AUTHORIZED","entitlementAvailability":"AVAILABLE","regionAvailability":"AVAILABLE","agreementAvailability":{"status":"AVAILABLE"}}`))
+				default:
+					t.Errorf("unexpected %s %s", r.Method, r.URL)
+				}
+			})
+			endpoint := ""
+			if tt.endpoint {
+				endpoint = os.Getenv("AWS_ENDPOINT_URL")
+			}
+			withEndpoint(t, &env, endpoint)
+			t.Setenv("ANTHROPIC_MODEL", "")
+			t.Setenv("ANTHROPIC_SMALL_FAST_MODEL", tt.model)
+			t.Setenv("ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION", tt.region)
+
+			got := smallFastModelResults(env, "us-east-1")
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d results, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if got[i].ID != w.id || got[i].Status != w.status || got[i].Code != w.code {
+					t.Errorf("result %d = %s %s %s (%s), want %s %s %s", i, got[i].ID, got[i].Status, got[i].Code, got[i].Message, w.id, w.status, w.code)
+				}
+				if got[i].Status == "fail" {
+					t.Errorf("result %d fails; small/fast problems are never worse than warn", i)
+				}
+			}
+			if len(got) > 0 && !strings.Contains(got[len(got)-1].Message, tt.contains) {
+				t.Errorf("message %q does not contain %q", got[len(got)-1].Message, tt.contains)
+			}
+			if tt.region != "" && !strings.Contains(got[0].Message, "uses "+tt.region+" (ANTHROPIC_SMALL_FAST_MODEL_AWS_REGION)") {
+				t.Errorf("regions message %q does not name the override", got[0].Message)
+			}
+		})
+	}
+}
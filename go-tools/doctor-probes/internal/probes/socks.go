@@ -0,0 +1,224 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// socksProbes are the checks that connect through a SOCKS proxy from
+// ALL_PROXY when no HTTP(S) proxy applies.
+var socksProbes = []string{"HTTPS Connectivity", "HTTP/2 Negotiation", "TLS Version Policy", "TLS Inspection", "Certificate Validation"}
+
+// socksProxyError is a failure to reach the SOCKS proxy itself.
+type socksProxyError struct {
+	Proxy string
+	Err   error
+}
+
+func (e *socksProxyError) Error() string {
+	return fmt.Sprintf("SOCKS proxy %s unreachable: %v", e.Proxy, e.Err)
+}
+
+func (e *socksProxyError) Unwrap() error { return e.Err }
+
+// socksTargetError is a failure of the SOCKS proxy to reach the target.
+type socksTargetError struct {
+	Proxy  string
+	Target string
+	Err    error
+}
+
+func (e *socksTargetError) Error() string {
+	return fmt.Sprintf("%s unreachable through SOCKS proxy %s: %v", e.Target, e.Proxy, e.Err)
+}
+
+func (e *socksTargetError) Unwrap() error { return e.Err }
+
+func isSOCKS(u *url.URL) bool {
+	return u != nil && (u.Scheme == "socks5" || u.Scheme == "socks5h")
+}
+
+// socksProxy returns the SOCKS5 proxy from ALL_PROXY for host, or nil when
+// ALL_PROXY is unset, is not a socks5 or socks5h URL, or NO_PROXY excludes
+// host.
+func socksProxy(host string) (*url.URL, error) {
+	raw, name := proxyEnv("all_proxy", "ALL_PROXY")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if !isSOCKS(u) {
+		return nil, nil
+	}
+	noProxy, _ := proxyEnv("no_proxy", "NO_PROXY")
+	if !undiciShouldProxy(parseNoProxy(noProxy), noProxy, host, 443) {
+		return nil, nil
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "1080")
+	}
+	return u, nil
+}
+
+// probeProxy returns the proxy the TLS probes use for host: Claude Code's
+// HTTP(S) proxy, or else a SOCKS5 proxy from ALL_PROXY.
+func probeProxy(host string) (*url.URL, error) {
+	if p, err := claudeCodeProxy(host); p != nil || err != nil {
+		return p, err
+	}
+	return socksProxy(host)
+}
+
+// proxyDisplay is a proxy URL without credentials.
+func proxyDisplay(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+// socksForward dials the SOCKS proxy and marks failures to reach it.
+type socksForward struct {
+	dialer *net.Dialer
+}
+
+func (f socksForward) Dial(network, addr string) (net.Conn, error) {
+	return f.DialContext(context.Background(), network, addr)
+}
+
+func (f socksForward) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := f.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, &socksProxyError{Proxy: addr, Err: err}
+	}
+	return conn, nil
+}
+
+// socksDialContext returns a dial function that connects through the SOCKS
+// proxy u. With socks5 the target is resolved locally and the proxy is given
+// an address; with socks5h the proxy resolves the name.
+func socksDialContext(u *url.URL, dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, socksForward{dialer: dialer})
+		if err != nil {
+			return nil, err
+		}
+		target := addr
+		if u.Scheme == "socks5" {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if net.ParseIP(host) == nil {
+				addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+				if err != nil {
+					return nil, err
+				}
+				target = net.JoinHostPort(addrs[0], port)
+			}
+		}
+		conn, err := d.(proxy.ContextDialer).DialContext(ctx, network, target)
+		var proxyErr *socksProxyError
+		if err != nil && !errors.As(err, &proxyErr) {
+			err = &socksTargetError{Proxy: u.Host, Target: addr, Err: err}
+		}
+		return conn, err
+	}
+}
+
+// socksResults reports the SOCKS5 proxy in ALL_PROXY: whether it and the
+// Bedrock runtime through it are reachable, which probes use it, and how
+// name resolution behaves for its scheme. It returns nothing without a
+// SOCKS ALL_PROXY.
+func socksResults(ctx context.Context, env Env, bedrockURL string) []CheckResult {
+	raw, name := proxyEnv("all_proxy", "ALL_PROXY")
+	if raw == "" {
+		return nil
+	}
+	result := CheckResult{ID: "network.socks_proxy", Name: "SOCKS Proxy"}
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		return nil
+	}
+	u, err := socksProxy(host)
+	if err != nil {
+		result.Status, result.Code = "fail", codeSOCKSUnreachable
+		result.Message = fmt.Sprintf("Cannot parse %s: %v", name, err)
+		result.Fix = fmt.Sprintf("Set %s to socks5h://host:port", name)
+		return []CheckResult{result}
+	}
+	if u == nil {
+		if parsed, _ := url.Parse(raw); parsed == nil || !isSOCKS(parsed) {
+			return nil
+		}
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("%s is set, but NO_PROXY excludes %s; no probe uses the SOCKS proxy", name, host)
+		return []CheckResult{result}
+	}
+	display := proxyDisplay(u)
+
+	// Through the proxy, as the probes connect
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	var conn net.Conn
+	err = env.Engine.do(ctx, addr, func() (err error) {
+		conn, err = socksDialContext(u, &net.Dialer{Timeout: 5 * time.Second})(ctx, "tcp", addr)
+		return err
+	})
+	logProbe("socks", addr, start, err, "proxy", display)
+	var proxyErr *socksProxyError
+	switch {
+	case errors.As(err, &proxyErr):
+		result.Status, result.Code = "fail", codeSOCKSUnreachable
+		result.Message = fmt.Sprintf("Nothing is listening at the SOCKS proxy %s from %s: %v", display, name, proxyErr.Err)
+		result.Fix = "Start the tunnel (e.g. ssh -D 1080 -N <bastion>) or unset " + name
+		return []CheckResult{result}
+	case err != nil:
+		result.Status, result.Code = "fail", codeSOCKSTargetUnreachable
+		result.Message = fmt.Sprintf("The SOCKS proxy %s is up but cannot reach %s: %v", display, addr, err)
+		result.Fix = "Check that the tunnel's far end can reach AWS (DNS and outbound 443 on the bastion)"
+		if u.Scheme == "socks5" {
+			result.Fix += "; with socks5:// names are resolved locally, so also try socks5h:// to let the proxy resolve them"
+		}
+		return []CheckResult{result}
+	}
+	conn.Close()
+
+	usedBy := strings.Join(socksProbes, ", ")
+	if p, _ := claudeCodeProxy(host); p != nil {
+		usedBy = "none (HTTPS_PROXY takes precedence)"
+	}
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("%s reaches %s through %s; used by: %s", name, addr, display, usedBy)
+
+	// socks5 resolves locally, so it needs working local DNS; socks5h is
+	// chosen to keep AWS lookups off the local resolver, so it should not.
+	if net.ParseIP(host) != nil {
+		return []CheckResult{result}
+	}
+	_, dnsErr := lookupWith(env.ResolverFor(""), host)
+	switch {
+	case u.Scheme == "socks5" && dnsErr != nil:
+		result.Status, result.Code = "warn", codeSOCKSDNSLeak
+		result.Message += fmt.Sprintf("; with socks5:// %s is resolved locally, and the local resolver fails: %v", host, dnsErr)
+		result.Fix = fmt.Sprintf("Use socks5h:// in %s so the proxy resolves names", name)
+	case u.Scheme == "socks5h" && dnsErr == nil:
+		result.Status, result.Code = "warn", codeSOCKSDNSLeak
+		result.Message += fmt.Sprintf("; the local resolver also answers for %s, so tools that ignore %s (including Claude Code, which does not read it) resolve and connect outside the tunnel", host, name)
+		result.Fix = "To keep all AWS traffic in the tunnel, point HTTPS_PROXY at an HTTP CONNECT proxy on the tunnel (Claude Code honors HTTPS_PROXY), or route the AWS hosts through the VPN instead"
+	}
+	return []CheckResult{result}
+}
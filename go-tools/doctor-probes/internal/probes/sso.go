@@ -0,0 +1,206 @@
+package probes
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Warn when the SSO access token expires within this window.
+const ssoExpiryWarning = 15 * time.Minute
+
+// ActiveProfile returns the shared config profile the SDK will use.
+func ActiveProfile() string {
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	if p := os.Getenv("AWS_DEFAULT_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// loadProfile reads the active profile from the shared config files. A
+// missing profile is not an error: the SDK falls back to other providers.
+func loadProfile() (string, *config.SharedConfig, error) {
+	profile := ActiveProfile()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The SDK's defaults ignore AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE
+	// here, although the credential chain honors them.
+	configFile, credentialsFile := SharedConfigFiles()
+	shared, err := config.LoadSharedConfigProfile(ctx, profile, func(o *config.LoadSharedConfigOptions) {
+		o.ConfigFiles = []string{configFile}
+		o.CredentialsFiles = []string{credentialsFile}
+	})
+	if err != nil {
+		var notExist config.SharedConfigProfileNotExistError
+		if errors.As(err, &notExist) {
+			return profile, nil, nil
+		}
+		return profile, nil, err
+	}
+	return profile, &shared, nil
+}
+
+type ssoCachedToken struct {
+	AccessToken string    `json:"accessToken"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// ssoTokenPath returns the file the AWS CLI caches an SSO token in: the SHA-1
+// of the sso-session name, or of the start URL for legacy profiles.
+func ssoTokenPath(cacheKey string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(cacheKey))
+	return filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// ssoResults checks the cached IAM Identity Center token and the reachability
+// of the SSO OIDC endpoint. It returns nothing for non-SSO profiles.
+func ssoResults(env Env) []CheckResult {
+	profile, shared, err := loadProfile()
+	if err != nil {
+		return []CheckResult{{
+			ID:      "auth.sso_session",
+			Code:    codeAWSConfig,
+			Name:    "SSO Session",
+			Status:  "fail",
+			Message: fmt.Sprintf("Cannot read profile %s from the shared config: %v", profile, err),
+			Fix:     "Check ~/.aws/config for syntax errors and a complete [sso-session] section",
+		}}
+	}
+	if shared == nil {
+		return nil
+	}
+
+	// sso-session style configuration takes precedence over the legacy
+	// sso_start_url/sso_region keys on the profile.
+	var cacheKey, ssoRegion, style string
+	switch {
+	case shared.SSOSession != nil:
+		cacheKey, ssoRegion, style = shared.SSOSession.Name, shared.SSOSession.SSORegion, "sso-session "+shared.SSOSession.Name
+	case shared.SSOStartURL != "":
+		cacheKey, ssoRegion, style = shared.SSOStartURL, shared.SSORegion, "legacy sso_start_url"
+	default:
+		return nil
+	}
+
+	loginCommand := fmt.Sprintf("aws sso login --profile %s", profile)
+	loginFix := &FixCommand{Bash: loginCommand, PowerShell: loginCommand}
+	results := []CheckResult{ssoTokenResult(profile, style, cacheKey, loginCommand, loginFix)}
+	if ssoRegion != "" {
+		results = append(results, ssoOIDCResult(env, ssoRegion))
+	}
+	return results
+}
+
+func ssoTokenResult(profile, style, cacheKey, loginCommand string, loginFix *FixCommand) CheckResult {
+	path, err := ssoTokenPath(cacheKey)
+	if err != nil {
+		return CheckResult{ID: "auth.sso_session", Name: "SSO Session", Status: "warn", Message: err.Error()}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CheckResult{
+			ID:         "auth.sso_session",
+			Code:       codeSSOTokenMissing,
+			Name:       "SSO Session",
+			Status:     "fail",
+			Message:    fmt.Sprintf("Profile %s uses %s but no cached SSO token was found", profile, style),
+			Fix:        fmt.Sprintf("Run `%s`", loginCommand),
+			FixCommand: loginFix,
+		}
+	}
+
+	var token ssoCachedToken
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return CheckResult{
+			ID:         "auth.sso_session",
+			Code:       codeSSOTokenMissing,
+			Name:       "SSO Session",
+			Status:     "fail",
+			Message:    fmt.Sprintf("Cached SSO token %s is unreadable or empty", path),
+			Fix:        fmt.Sprintf("Run `%s`", loginCommand),
+			FixCommand: loginFix,
+		}
+	}
+
+	remaining := time.Until(token.ExpiresAt)
+	switch {
+	case remaining <= 0:
+		return CheckResult{
+			ID:         "auth.sso_session",
+			Code:       codeSSOTokenExpired,
+			Name:       "SSO Session",
+			Status:     "fail",
+			Message:    fmt.Sprintf("SSO session for profile %s expired at %s; Bedrock calls will fail with InvalidGrantException", profile, token.ExpiresAt.Local().Format(time.RFC1123)),
+			Fix:        fmt.Sprintf("Run `%s`", loginCommand),
+			FixCommand: loginFix,
+		}
+	case remaining < ssoExpiryWarning:
+		return CheckResult{
+			ID:         "auth.sso_session",
+			Code:       codeSSOTokenExpired,
+			Name:       "SSO Session",
+			Status:     "warn",
+			Message:    fmt.Sprintf("SSO session for profile %s expires in %s", profile, remaining.Round(time.Minute)),
+			Fix:        fmt.Sprintf("Run `%s` before starting a long session", loginCommand),
+			FixCommand: loginFix,
+		}
+	}
+	return CheckResult{
+		ID:      "auth.sso_session",
+		Name:    "SSO Session",
+		Status:  "pass",
+		Message: fmt.Sprintf("SSO session for profile %s (%s) valid until %s", profile, style, token.ExpiresAt.Local().Format(time.RFC1123)),
+	}
+}
+
+// ssoOIDCResult checks the OIDC endpoint the SDK refreshes SSO tokens
+// against; it is a different host (and often region) than Bedrock.
+func ssoOIDCResult(env Env, ssoRegion string) CheckResult {
+	host := partitionForRegion(ssoRegion).hostname("oidc", ssoRegion)
+	address := net.JoinHostPort(host, "443")
+
+	start := time.Now()
+	var conn *tls.Conn
+	err := env.Engine.do(context.Background(), host, func() (err error) {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", address, &tls.Config{ServerName: host})
+		return err
+	})
+	logProbe("tls", host, start, err)
+	if err != nil {
+		return CheckResult{
+			ID:      "auth.sso_oidc",
+			Code:    classifyNetError(err),
+			Name:    "SSO OIDC Endpoint",
+			Status:  "fail",
+			Message: fmt.Sprintf("Cannot reach %s: %v", address, err),
+			Fix:     fmt.Sprintf("Allow outbound HTTPS to %s; SSO token refresh uses it even when Bedrock goes through a VPC endpoint", host),
+		}
+	}
+	conn.Close()
+
+	return CheckResult{
+		ID:      "auth.sso_oidc",
+		Name:    "SSO OIDC Endpoint",
+		Status:  "pass",
+		Message: fmt.Sprintf("Reached %s", address),
+	}
+}
@@ -0,0 +1,148 @@
+package probes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// writeSSOToken caches an SSO token for cacheKey the way the AWS CLI does.
+func writeSSOToken(t *testing.T, home, cacheKey, body string) {
+	t.Helper()
+	sum := sha1.Sum([]byte(cacheKey))
+	dir := filepath.Join(home, ".aws", "sso", "cache")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActiveProfile(t *testing.T) {
+	tests := []struct {
+		profile, defaultProfile, want string
+	}{
+		{"", "", "default"},
+		{"", "team", "team"},
+		{"dev", "team", "dev"},
+	}
+	for _, tt := range tests {
+		t.Setenv("AWS_PROFILE", tt.profile)
+		t.Setenv("AWS_DEFAULT_PROFILE", tt.defaultProfile)
+		if got := ActiveProfile(); got != tt.want {
+			t.Errorf("activeProfile() with AWS_PROFILE=%q AWS_DEFAULT_PROFILE=%q = %q, want %q", tt.profile, tt.defaultProfile, got, tt.want)
+		}
+	}
+}
+
+func TestSSOResults(t *testing.T) {
+	const sessionConfig = `[profile dev]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Developer
+region = us-east-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = nowhere-1
+`
+	const legacyConfig = `[profile dev]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = nowhere-1
+sso_account_id = 123456789012
+sso_role_name = Developer
+`
+	token := func(expires time.Time) string {
+		return fmt.Sprintf(`{"accessToken":"secret-token","expiresAt":%q}`, expires.UTC().Format(time.RFC3339))
+	}
+	tests := []struct {
+		name     string
+		config   string
+		profile  string
+		cacheKey string
+		token    string // cached token body; empty means none
+		status   string
+		code     string
+		contains string
+	}{
+		{name: "not an SSO profile", config: "[profile dev]\nregion = us-east-1\n", profile: "dev"},
+		{name: "profile missing", config: "[profile other]\n", profile: "dev"},
+		{
+			name: "no cached token", config: sessionConfig, profile: "dev",
+			status: "fail", code: codeSSOTokenMissing, contains: "uses sso-session corp but no cached SSO token was found",
+		},
+		{
+			name: "unreadable token", config: sessionConfig, profile: "dev", cacheKey: "corp", token: "{",
+			status: "fail", code: codeSSOTokenMissing, contains: "is unreadable or empty",
+		},
+		{
+			name: "expired", config: sessionConfig, profile: "dev", cacheKey: "corp", token: token(time.Now().Add(-time.Hour)),
+			status: "fail", code: codeSSOTokenExpired, contains: "expired at",
+		},
+		{
+			name: "expiring soon", config: sessionConfig, profile: "dev", cacheKey: "corp", token: token(time.Now().Add(5 * time.Minute)),
+			status: "warn", code: codeSSOTokenExpired, contains: "expires in 5m",
+		},
+		{
+			name: "valid session", config: sessionConfig, profile: "dev", cacheKey: "corp", token: token(time.Now().Add(8 * time.Hour)),
+			status: "pass", contains: "SSO session for profile dev (sso-session corp) valid until",
+		},
+		{
+			name: "legacy profile keyed by start URL", config: legacyConfig, profile: "dev", cacheKey: "https://legacy.awsapps.com/start",
+			token: token(time.Now().Add(8 * time.Hour)), status: "pass", contains: "(legacy sso_start_url)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			home := probestest.AWSConfig(t, tt.config)
+			t.Setenv("AWS_PROFILE", tt.profile)
+			if tt.token != "" {
+				writeSSOToken(t, home, tt.cacheKey, tt.token)
+			}
+
+			got := ssoResults(env)
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 2 {
+				t.Fatalf("got %d results, want session and OIDC: %+v", len(got), got)
+			}
+			r := got[0]
+			if r.ID != "auth.sso_session" || r.Status != tt.status || r.Code != tt.code || !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("got %s %s %s %q; want auth.sso_session %s %s containing %q", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code, tt.contains)
+			}
+			if r.Status != "pass" && (r.FixCommand == nil || r.FixCommand.Bash != "aws sso login --profile dev") {
+				t.Errorf("fix command = %+v, want aws sso login --profile dev", r.FixCommand)
+			}
+			if strings.Contains(r.Message, "secret-token") {
+				t.Errorf("message leaks the access token: %q", r.Message)
+			}
+			// The OIDC host for a region that does not exist never resolves.
+			if oidc := got[1]; oidc.ID != "auth.sso_oidc" || oidc.Status != "fail" || !strings.Contains(oidc.Message, "oidc.nowhere-1.amazonaws.com:443") {
+				t.Errorf("OIDC result = %s %s %q", oidc.ID, oidc.Status, oidc.Message)
+			}
+		})
+	}
+}
+
+func TestSSOResultsBrokenConfig(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	probestest.AWSConfig(t, "[profile dev]\nsso_session = missing\n")
+	t.Setenv("AWS_PROFILE", "dev")
+	got := ssoResults(env)
+	if len(got) != 1 || got[0].Status != "fail" || got[0].Code != codeAWSConfig {
+		t.Errorf("results = %+v, want one fail with %s", got, codeAWSConfig)
+	}
+}
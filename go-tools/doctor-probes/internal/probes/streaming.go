@@ -0,0 +1,251 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// streamProbeBody asks for a few hundred tokens, so that a streamed answer
+// arrives over seconds rather than in one burst.
+const streamProbeBody = `{"anthropic_version":"bedrock-2023-05-31","max_tokens":300,"messages":[{"role":"user","content":"Count from one to sixty in words, one number per line."}]}`
+
+// streamBufferedShare is the share of bytes arriving in the final tenth of
+// the wall time above which the response is taken to have been buffered.
+const streamBufferedShare = 0.9
+
+// streamMinDuration is the shortest response that can tell streaming from
+// buffering.
+const streamMinDuration = 500 * time.Millisecond
+
+// streamChunk is one piece of a response and when it arrived.
+type streamChunk struct {
+	At    time.Duration // since the request was sent
+	Bytes int
+}
+
+// streamTiming is a response read incrementally.
+type streamTiming struct {
+	Source string // what was streamed
+	Chunks []streamChunk
+	Total  time.Duration // request sent to last byte
+}
+
+func (t streamTiming) bytes() int {
+	n := 0
+	for _, c := range t.Chunks {
+		n += c.Bytes
+	}
+	return n
+}
+
+// histogram returns the bytes that arrived in each tenth of the wall time.
+func (t streamTiming) histogram() [10]int {
+	var buckets [10]int
+	for _, c := range t.Chunks {
+		i := 9
+		if t.Total > 0 {
+			i = min(int(10*c.At/t.Total), 9)
+		}
+		buckets[i] += c.Bytes
+	}
+	return buckets
+}
+
+// finalShare is the share of bytes that arrived in the final tenth of the
+// wall time: about a tenth when the response streams, close to all of it
+// when something held it back.
+func (t streamTiming) finalShare() float64 {
+	total := t.bytes()
+	if total == 0 {
+		return 0
+	}
+	return float64(t.histogram()[9]) / float64(total)
+}
+
+func (t streamTiming) histogramString() string {
+	var parts []string
+	for i, n := range t.histogram() {
+		parts = append(parts, fmt.Sprintf("%d-%d%% %dB", i*10, (i+1)*10, n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// streamModel calls InvokeModelWithResponseStream and times each event as
+// it is read off the stream.
+func streamModel(env Env, region, modelID string) (streamTiming, error) {
+	timing := streamTiming{Source: "InvokeModelWithResponseStream " + modelID}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return timing, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	start := time.Now()
+	out, err := env.BedrockRuntime(cfg).InvokeModelWithResponseStream(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        []byte(streamProbeBody),
+	})
+	if err != nil {
+		logProbe("invoke_stream", modelID, start, err)
+		return timing, err
+	}
+	stream := out.GetStream()
+	defer stream.Close()
+	for event := range stream.Events() {
+		if chunk, ok := event.(*brtypes.ResponseStreamMemberChunk); ok {
+			timing.Chunks = append(timing.Chunks, streamChunk{At: time.Since(start), Bytes: len(chunk.Value.Bytes)})
+		}
+	}
+	timing.Total = time.Since(start)
+	err = stream.Err()
+	logProbe("invoke_stream", modelID, start, err, "chunks", len(timing.Chunks))
+	return timing, err
+}
+
+// streamURL fetches rawURL on Claude Code's route and times each read of the
+// body.
+func streamURL(env Env, rawURL string) (streamTiming, error) {
+	timing := streamTiming{Source: rawURL}
+	u, err := ParseEndpointURL(rawURL)
+	if err != nil {
+		return timing, err
+	}
+	proxy, err := probeProxy(u.Hostname())
+	if err != nil {
+		return timing, err
+	}
+	transport := &http.Transport{}
+	if isSOCKS(proxy) {
+		transport.DialContext = socksDialContext(proxy, &net.Dialer{Timeout: 5 * time.Second})
+	} else if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	client := &http.Client{Timeout: 60 * time.Second, Transport: env.Engine.limited(transport)}
+
+	start := time.Now()
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		logProbe("stream", rawURL, start, err)
+		return timing, err
+	}
+	defer resp.Body.Close()
+	// Read as the bytes come rather than with io.ReadAll, so each arrival is
+	// timed.
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			timing.Chunks = append(timing.Chunks, streamChunk{At: time.Since(start), Bytes: n})
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			timing.Total = time.Since(start)
+			logProbe("stream", rawURL, start, err)
+			return timing, err
+		}
+	}
+	timing.Total = time.Since(start)
+	logProbe("stream", rawURL, start, nil, "status", resp.StatusCode, "chunks", len(timing.Chunks))
+	return timing, nil
+}
+
+// streamIntermediary names what is likely holding the response back: the
+// interceptor found by the TLS inspection check, else the proxy.
+func streamIntermediary(env Env, host string) string {
+	if name := env.foundInterceptor(); name != "" {
+		return name
+	}
+	if proxy, _ := probeProxy(host); proxy != nil {
+		return "the proxy " + proxyDisplay(proxy)
+	}
+	return "an intermediary on the path"
+}
+
+// streamingResult streams a response, from the configured model when there
+// is one or else from streamURL, and checks that its bytes arrive over time
+// rather than all at the end, which is what a gateway that buffers whole
+// responses does to Claude Code's output. It is never worse than a warning.
+func streamingResult(env Env, region, bedrockURL, streamURLFlag string) CheckResult {
+	const costNote = "the model probe is a ~300-token InvokeModelWithResponseStream call and incurs a small charge"
+	result := CheckResult{ID: "network.stream_buffering", Name: "Response Streaming"}
+
+	model := configuredModel()
+	if model == "" && streamURLFlag == "" {
+		result.Status = "skip"
+		result.Message = "Nothing to stream: ANTHROPIC_MODEL is not set and no --stream-url was given"
+		result.Fix = "export ANTHROPIC_MODEL=<model-or-inference-profile-id>, or pass --stream-url with a URL that streams its response slowly"
+		return result
+	}
+	var timing streamTiming
+	var err error
+	useURL := model == ""
+	if model != "" {
+		timing, err = streamModel(env, region, model)
+		useURL = err != nil && streamURLFlag != ""
+	}
+	host, _, _ := endpointAddr(bedrockURL)
+	if useURL {
+		timing, err = streamURL(env, streamURLFlag)
+		host, _, _ = endpointAddr(streamURLFlag)
+	}
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Could not stream %s: %v", timing.Source, err)
+		if useURL {
+			result.Code = classifyNetError(err)
+		} else {
+			result.Fix = "Add bedrock:InvokeModelWithResponseStream permission, or pass --stream-url to test with a generic streaming URL"
+			result.setAWSError(err, "bedrock:InvokeModelWithResponseStream")
+			result.attachDiagnostics(env, awsDiagnostics(err))
+		}
+		return result
+	}
+
+	share := timing.finalShare()
+	result.Metrics = map[string]float64{
+		"duration_ms":        DurationMs(timing.Total),
+		"chunks":             float64(len(timing.Chunks)),
+		"bytes":              float64(timing.bytes()),
+		"final_decile_share": share,
+	}
+	if len(timing.Chunks) > 0 {
+		result.Metrics["first_chunk_ms"] = DurationMs(timing.Chunks[0].At)
+	}
+	summary := fmt.Sprintf("%s: %d bytes in %d chunks over %s, %.0f%% of them in the final 10%% of the time",
+		timing.Source, timing.bytes(), len(timing.Chunks), FormatSeconds(DurationMs(timing.Total)), 100*share)
+	if env.Verbose {
+		summary += "; chunk timing: " + timing.histogramString()
+	}
+
+	switch {
+	case timing.bytes() == 0 || timing.Total < streamMinDuration:
+		result.Status = "skip"
+		result.Message = "Inconclusive, the response was too short to time: " + summary
+	case share > streamBufferedShare:
+		result.Status, result.Code = "warn", codeStreamBuffered
+		result.Message = fmt.Sprintf("Response buffering detected: %s", summary)
+		result.Fix = fmt.Sprintf("%s appears to hold back streamed responses until they complete, so Claude Code hangs and then prints the whole answer at once; ask its owners to pass bedrock-runtime responses (application/vnd.amazon.eventstream) through unbuffered", streamIntermediary(env, host))
+	default:
+		result.Status = "pass"
+		result.Message = "Streams as it is generated: " + summary
+	}
+	if !useURL {
+		result.Message += "; " + costNote
+	}
+	return result
+}
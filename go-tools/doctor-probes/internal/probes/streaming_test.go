@@ -0,0 +1,161 @@
+package probes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestStreamTiming(t *testing.T) {
+	tests := []struct {
+		name   string
+		timing streamTiming
+		share  float64
+		bucket [10]int
+	}{
+		{name: "empty", timing: streamTiming{Total: time.Second}},
+		{
+			name:   "streamed",
+			timing: streamTiming{Total: time.Second, Chunks: []streamChunk{{50 * time.Millisecond, 10}, {450 * time.Millisecond, 10}, {950 * time.Millisecond, 20}}},
+			share:  0.5,
+			bucket: [10]int{0: 10, 4: 10, 9: 20},
+		},
+		{
+			name:   "buffered",
+			timing: streamTiming{Total: time.Second, Chunks: []streamChunk{{990 * time.Millisecond, 300}, {time.Second, 100}}},
+			share:  1,
+			bucket: [10]int{9: 400},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.timing.histogram(); got != tt.bucket {
+			t.Errorf("%s: histogram = %v, want %v", tt.name, got, tt.bucket)
+		}
+		if got := tt.timing.finalShare(); got != tt.share {
+			t.Errorf("%s: finalShare = %v, want %v", tt.name, got, tt.share)
+		}
+	}
+}
+
+func TestStreamIntermediary(t *testing.T) {
+	const host = "bedrock-runtime.us-east-1.amazonaws.com"
+	tests := []struct {
+		name        string
+		interceptor string
+		env         map[string]string
+		want        string
+	}{
+		{name: "nothing known", want: "an intermediary on the path"},
+		{name: "proxy", env: map[string]string{"HTTPS_PROXY": "http://proxy.corp:3128"}, want: "the proxy http://proxy.corp:3128"},
+		{name: "interceptor wins", interceptor: "Zscaler", env: map[string]string{"HTTPS_PROXY": "http://proxy.corp:3128"}, want: "Zscaler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, tt.env)
+			env.setInterceptor(tt.interceptor)
+			if got := streamIntermediary(env, host); got != tt.want {
+				t.Errorf("streamIntermediary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// slowServer writes chunks of a line each, interval apart, flushing each one
+// unless buffered, in which case the whole body goes out at the end.
+func slowServer(t *testing.T, chunks int, interval time.Duration, buffered bool) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body strings.Builder
+		for i := 0; i < chunks; i++ {
+			time.Sleep(interval)
+			line := fmt.Sprintf("line %02d of the streamed answer\n", i)
+			if buffered {
+				body.WriteString(line)
+				continue
+			}
+			fmt.Fprint(w, line)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, body.String())
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestStreamingResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		url      string
+		denied   bool // the fake Bedrock denies streaming
+		status   string
+		code     string
+		contains []string
+	}{
+		{name: "nothing to stream", status: "skip", contains: []string{"ANTHROPIC_MODEL is not set and no --stream-url"}},
+		{
+			name:     "streams",
+			url:      slowServer(t, 10, 70*time.Millisecond, false),
+			status:   "pass",
+			contains: []string{"Streams as it is generated: http://127.0.0.1:", "310 bytes in 10 chunks"},
+		},
+		{
+			name:     "buffered",
+			url:      slowServer(t, 10, 70*time.Millisecond, true),
+			status:   "warn",
+			code:     codeStreamBuffered,
+			contains: []string{"Response buffering detected", "100% of them in the final 10% of the time", "an intermediary on the path appears to hold back"},
+		},
+		{name: "too short to tell", url: slowServer(t, 1, 0, false), status: "skip", contains: []string{"Inconclusive, the response was too short to time"}},
+		{name: "URL refused", url: "http://127.0.0.1:1/", status: "warn", code: codeConnectRefused, contains: []string{"Could not stream http://127.0.0.1:1/: "}},
+		{
+			name:     "model denied",
+			model:    "anthropic.claude-x",
+			denied:   true,
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Could not stream InvokeModelWithResponseStream anthropic.claude-x", "bedrock:InvokeModelWithResponseStream"},
+		},
+		{
+			name:     "model denied, URL used instead",
+			model:    "anthropic.claude-x",
+			url:      slowServer(t, 10, 70*time.Millisecond, false),
+			denied:   true,
+			status:   "pass",
+			contains: []string{"Streams as it is generated: http://127.0.0.1:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				if !tt.denied {
+					t.Errorf("unexpected %s %s", r.Method, r.URL)
+				}
+				probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+			})
+			withProxyEnv(t, nil)
+			t.Setenv("ANTHROPIC_MODEL", tt.model)
+
+			r := streamingResult(env, "us-east-1", "https://bedrock-runtime.us-east-1.amazonaws.com", tt.url)
+			if r.ID != "network.stream_buffering" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if tt.url != "" && strings.Contains(r.Message, "incurs a small charge") {
+				t.Errorf("message %q carries the model cost note for a URL probe", r.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// stsGlobalHost is the legacy global STS endpoint, served from us-east-1.
+// Only the aws partition has one.
+const stsGlobalHost = "sts.amazonaws.com"
+
+// stsLegacyGlobalRegions are the regions whose STS calls go to the global
+// endpoint when sts_regional_endpoints is legacy; all others stay regional.
+var stsLegacyGlobalRegions = []string{
+	"ap-northeast-1", "ap-south-1", "ap-southeast-1", "ap-southeast-2",
+	"ca-central-1", "eu-central-1", "eu-north-1", "eu-west-1", "eu-west-2",
+	"eu-west-3", "sa-east-1", "us-east-1", "us-east-2", "us-west-1", "us-west-2",
+}
+
+// stsEndpointsMode returns the sts_regional_endpoints setting in effect and
+// where it came from: AWS_STS_REGIONAL_ENDPOINTS, the active profile, or
+// the SDK default (regional).
+func stsEndpointsMode() (mode, source string) {
+	if v := os.Getenv("AWS_STS_REGIONAL_ENDPOINTS"); v != "" {
+		return strings.ToLower(v), "AWS_STS_REGIONAL_ENDPOINTS"
+	}
+	profile := ActiveProfile()
+	if sections, err := sharedConfigSections(); err == nil {
+		if v := sections[profile]["sts_regional_endpoints"]; v != "" {
+			return strings.ToLower(v), fmt.Sprintf("sts_regional_endpoints in profile '%s'", profile)
+		}
+	}
+	return "regional", "the SDK default"
+}
+
+// stsProbe is the reachability of one STS host.
+type stsProbe struct {
+	Host    string
+	Elapsed time.Duration
+	Err     error
+}
+
+func (p stsProbe) String() string {
+	if p.Err != nil {
+		return fmt.Sprintf("%s unreachable (%v)", p.Host, p.Err)
+	}
+	return fmt.Sprintf("%s answered in %s", p.Host, formatMs(p.Elapsed))
+}
+
+// probeSTSHost sends an unsigned GET on Claude Code's route; any AWS answer
+// proves the host is reachable.
+func probeSTSHost(env Env, host string) stsProbe {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	target := "https://" + host + "/"
+	start := time.Now()
+	_, err := sendAWSProbe(ctx, env, target, nil, nil)
+	logProbe("https", target, start, err)
+	return stsProbe{Host: host, Elapsed: time.Since(start), Err: err}
+}
+
+// stsEndpointResult determines which STS endpoint credential exchanges
+// (role assumption, SSO, web identity) will use, probes it and the other
+// one, and warns when the global endpoint is selected, or when the selected
+// endpoint is unreachable while the other works.
+func stsEndpointResult(env Env, region string, fips bool) CheckResult {
+	result := CheckResult{ID: "auth.sts_endpoint", Name: "STS Endpoint"}
+	mode, source := stsEndpointsMode()
+	if mode != "legacy" && mode != "regional" {
+		result.Status, result.Code = "warn", codeSharedConfigMistake
+		result.Message = fmt.Sprintf("%s is %q; the SDKs accept only legacy or regional", source, mode)
+		result.Fix, result.FixCommand = stsModeFix("regional", source)
+		return result
+	}
+
+	part := partitionForRegion(region)
+	service := "sts"
+	if fips {
+		service = fipsService(service)
+	}
+	regional := probeSTSHost(env, part.hostname(service, region))
+	hasGlobal := part.ID == standardPartition.ID && !fips
+	global := stsProbe{Host: stsGlobalHost}
+	if hasGlobal {
+		global = probeSTSHost(env, stsGlobalHost)
+	}
+	useGlobal := hasGlobal && mode == "legacy" && slices.Contains(stsLegacyGlobalRegions, region)
+	selected, other := regional, global
+	if useGlobal {
+		selected, other = global, regional
+	}
+
+	result.Metrics = map[string]float64{}
+	if regional.Err == nil {
+		result.Metrics["regional_ms"] = DurationMs(regional.Elapsed)
+	}
+	if hasGlobal && global.Err == nil {
+		result.Metrics["global_ms"] = DurationMs(global.Elapsed)
+	}
+	result.Message = fmt.Sprintf("%s from %s selects %s", mode, source, selected.Host)
+	probesSummary := selected.String()
+	if hasGlobal {
+		probesSummary += "; " + other.String()
+	}
+
+	switch {
+	case selected.Err != nil && hasGlobal && other.Err == nil:
+		result.Status, result.Code = "warn", codeSTSEndpointUnreachable
+		result.Message += fmt.Sprintf(", which is unreachable while %s works: %s", other.Host, probesSummary)
+		if useGlobal {
+			result.Fix, result.FixCommand = stsModeFix("regional", source)
+		} else {
+			result.Fix = fmt.Sprintf("Ask the network team to allow %s (or add an STS interface VPC endpoint)", selected.Host)
+			if slices.Contains(stsLegacyGlobalRegions, region) {
+				fix, cmd := stsModeFix("legacy", source)
+				result.Fix += fmt.Sprintf("; until then, legacy mode sends %s's STS calls to the global endpoint, which works: %s", region, fix)
+				result.FixCommand = cmd
+			}
+		}
+	case selected.Err != nil:
+		result.Status, result.Code = "warn", classifyNetError(selected.Err)
+		result.Message += fmt.Sprintf(", which is unreachable: %s", probesSummary)
+		result.Fix = "Role assumption, SSO, and web identity credentials need STS: check DNS, firewall, and proxy settings for " + selected.Host + env.Host.proxyHint()
+	case useGlobal:
+		result.Status, result.Code = "warn", codeSTSGlobalEndpoint
+		result.Message += fmt.Sprintf(", the legacy global endpoint in us-east-1, rather than %s: %s", regional.Host, probesSummary)
+		result.Fix, result.FixCommand = stsModeFix("regional", source)
+		result.Fix = "The global endpoint adds latency from far regions, is a single point of failure, and is blocked in VPCs with only regional STS endpoints. " + result.Fix
+	default:
+		result.Status = "pass"
+		result.Message += ": " + probesSummary
+	}
+	return result
+}
+
+// stsModeFix returns the setting that selects mode, in the place source
+// says it is set now: the environment or the active profile.
+func stsModeFix(mode, source string) (string, *FixCommand) {
+	if strings.HasPrefix(source, "sts_regional_endpoints") {
+		profile := ActiveProfile()
+		configFile, _ := SharedConfigFiles()
+		return fmt.Sprintf("Set sts_regional_endpoints = %s in the [%s] section of %s", mode, configSectionName(profile), configFile),
+			&FixCommand{
+				Bash:       fmt.Sprintf("aws configure set sts_regional_endpoints %s --profile %s", mode, profile),
+				PowerShell: fmt.Sprintf("aws configure set sts_regional_endpoints %s --profile %s", mode, profile),
+			}
+	}
+	return fmt.Sprintf("export AWS_STS_REGIONAL_ENDPOINTS=%s", mode),
+		&FixCommand{
+			Bash:       fmt.Sprintf("export AWS_STS_REGIONAL_ENDPOINTS=%s", mode),
+			PowerShell: fmt.Sprintf(`$env:AWS_STS_REGIONAL_ENDPOINTS = "%s"`, mode),
+		}
+}
@@ -0,0 +1,190 @@
+package probes
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// stsHosts returns an environment whose probe clients reach a test server
+// answering like AWS for reachable hosts and refuse every other host, and a
+// func returning the hosts probed, in order.
+func stsHosts(t *testing.T, reachable ...string) (Env, func() []string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		awsHeaders(w)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(srv.Close)
+	var (
+		mu     sync.Mutex
+		probed []string
+	)
+	env := NewEnv(RunSettings{})
+	env.HTTP = func(host string) HTTPDoer {
+		mu.Lock()
+		probed = append(probed, host)
+		mu.Unlock()
+		addr := "127.0.0.1:1"
+		if slices.Contains(reachable, host) {
+			addr = srv.Listener.Addr().String()
+		}
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+	return env, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(probed)
+	}
+}
+
+func TestSTSEndpointResult(t *testing.T) {
+	const (
+		regional = "sts.us-east-1.amazonaws.com"
+		global   = "sts.amazonaws.com"
+	)
+	tests := []struct {
+		name      string
+		env       string // AWS_STS_REGIONAL_ENDPOINTS
+		config    string // the shared config; profile dev is active
+		region    string
+		fips      bool
+		reachable []string
+		probed    []string
+		status    string
+		code      string
+		contains  []string
+		fixBash   string
+	}{
+		{
+			name:      "SDK default",
+			region:    "us-east-1",
+			reachable: []string{regional, global},
+			probed:    []string{regional, global},
+			status:    "pass",
+			contains:  []string{"regional from the SDK default selects " + regional + ": " + regional + " answered in ", global + " answered in "},
+		},
+		{
+			name:      "legacy from the environment",
+			env:       "legacy",
+			region:    "us-east-1",
+			reachable: []string{regional, global},
+			probed:    []string{regional, global},
+			status:    "warn",
+			code:      codeSTSGlobalEndpoint,
+			contains:  []string{"legacy from AWS_STS_REGIONAL_ENDPOINTS selects " + global + ", the legacy global endpoint in us-east-1, rather than " + regional, "single point of failure"},
+			fixBash:   "export AWS_STS_REGIONAL_ENDPOINTS=regional",
+		},
+		{
+			name:      "legacy in a region that stays regional",
+			config:    "[profile dev]\nsts_regional_endpoints = legacy\n",
+			region:    "ap-east-1",
+			reachable: []string{"sts.ap-east-1.amazonaws.com", global},
+			probed:    []string{"sts.ap-east-1.amazonaws.com", global},
+			status:    "pass",
+			contains:  []string{"legacy from sts_regional_endpoints in profile 'dev' selects sts.ap-east-1.amazonaws.com"},
+		},
+		{
+			name:      "legacy global endpoint blocked",
+			config:    "[profile dev]\nsts_regional_endpoints = Legacy\n",
+			region:    "us-east-1",
+			reachable: []string{regional},
+			probed:    []string{regional, global},
+			status:    "warn",
+			code:      codeSTSEndpointUnreachable,
+			contains:  []string{"selects " + global + ", which is unreachable while " + regional + " works", "Set sts_regional_endpoints = regional in the [profile dev] section of "},
+			fixBash:   "aws configure set sts_regional_endpoints regional --profile dev",
+		},
+		{
+			name:      "regional endpoint blocked",
+			region:    "us-east-1",
+			reachable: []string{global},
+			probed:    []string{regional, global},
+			status:    "warn",
+			code:      codeSTSEndpointUnreachable,
+			contains:  []string{"Ask the network team to allow " + regional, "legacy mode sends us-east-1's STS calls to the global endpoint, which works"},
+			fixBash:   "export AWS_STS_REGIONAL_ENDPOINTS=legacy",
+		},
+		{
+			name:     "nothing reachable",
+			region:   "us-east-1",
+			probed:   []string{regional, global},
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"which is unreachable: " + regional + " unreachable", "check DNS, firewall, and proxy settings for " + regional},
+		},
+		{
+			name:     "unknown mode",
+			env:      "global",
+			region:   "us-east-1",
+			status:   "warn",
+			code:     codeSharedConfigMistake,
+			contains: []string{`AWS_STS_REGIONAL_ENDPOINTS is "global"; the SDKs accept only legacy or regional`},
+			fixBash:  "export AWS_STS_REGIONAL_ENDPOINTS=regional",
+		},
+		{
+			name:      "GovCloud has no global endpoint",
+			env:       "legacy",
+			region:    "us-gov-west-1",
+			reachable: []string{"sts.us-gov-west-1.amazonaws.com"},
+			probed:    []string{"sts.us-gov-west-1.amazonaws.com"},
+			status:    "pass",
+			contains:  []string{"selects sts.us-gov-west-1.amazonaws.com: sts.us-gov-west-1.amazonaws.com answered in "},
+		},
+		{
+			name:      "FIPS",
+			env:       "legacy",
+			region:    "us-east-1",
+			fips:      true,
+			reachable: []string{"sts-fips.us-east-1.amazonaws.com"},
+			probed:    []string{"sts-fips.us-east-1.amazonaws.com"},
+			status:    "pass",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSharedConfig(t, tt.config)
+			withProxyEnv(t, nil)
+			probestest.Unsetenv(t, "AWS_STS_REGIONAL_ENDPOINTS")
+			if tt.env != "" {
+				t.Setenv("AWS_STS_REGIONAL_ENDPOINTS", tt.env)
+			}
+			env, probed := stsHosts(t, tt.reachable...)
+
+			r := stsEndpointResult(env, tt.region, tt.fips)
+			if r.ID != "auth.sts_endpoint" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			if got := probed(); !reflect.DeepEqual(got, tt.probed) {
+				t.Errorf("probed %q, want %q", got, tt.probed)
+			}
+			gotBash := ""
+			if r.FixCommand != nil {
+				gotBash = r.FixCommand.Bash
+			}
+			if gotBash != tt.fixBash {
+				t.Errorf("fix command = %q, want %q", gotBash, tt.fixBash)
+			}
+		})
+	}
+}
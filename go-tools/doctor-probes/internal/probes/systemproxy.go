@@ -0,0 +1,258 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// systemProxy is the OS-level proxy configuration, which browsers honor but
+// Claude Code does not: Node only reads the proxy environment variables.
+type systemProxy struct {
+	Source string // where it was read from, e.g. "scutil --proxy"
+	Proxy  string // host:port of a manually configured HTTPS proxy
+	PAC    string // proxy auto-config URL
+	WPAD   bool   // auto-discovery enabled
+}
+
+func (p systemProxy) configured() bool {
+	return p.Proxy != "" || p.PAC != "" || p.WPAD
+}
+
+// detectSystemProxy reads the platform's proxy settings. It returns false
+// where there is no supported source (or the tool to read it is missing).
+func detectSystemProxy() (systemProxy, bool) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := runLocal("scutil", "--proxy")
+		if err != nil {
+			return systemProxy{}, false
+		}
+		return parseScutilProxy(out), true
+	case "windows":
+		out, err := runLocal("reg", "query", `HKCU\Software\Microsoft\Windows\CurrentVersion\Internet Settings`)
+		if err != nil {
+			return systemProxy{}, false
+		}
+		p := parseInternetSettings(out)
+		if p.Proxy == "" {
+			if winhttp, err := runLocal("netsh", "winhttp", "show", "proxy"); err == nil {
+				p.Proxy = parseWinHTTPProxy(winhttp)
+			}
+		}
+		return p, true
+	case "linux":
+		mode, err := runLocal("gsettings", "get", "org.gnome.system.proxy", "mode")
+		if err != nil {
+			return systemProxy{}, false
+		}
+		p := systemProxy{Source: "GNOME proxy settings"}
+		switch strings.Trim(mode, "'") {
+		case "manual":
+			host, _ := runLocal("gsettings", "get", "org.gnome.system.proxy.https", "host")
+			port, _ := runLocal("gsettings", "get", "org.gnome.system.proxy.https", "port")
+			if host = strings.Trim(host, "'"); host != "" {
+				p.Proxy = host + ":" + port
+			}
+		case "auto":
+			url, _ := runLocal("gsettings", "get", "org.gnome.system.proxy", "autoconfig-url")
+			if p.PAC = strings.Trim(url, "'"); p.PAC == "" {
+				p.WPAD = true
+			}
+		}
+		return p, true
+	}
+	return systemProxy{}, false
+}
+
+var scutilLine = regexp.MustCompile(`^\s*(\w+)\s*:\s*(.*?)\s*$`)
+
+// parseScutilProxy parses the dictionary printed by macOS `scutil --proxy`.
+func parseScutilProxy(out string) systemProxy {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if m := scutilLine.FindStringSubmatch(line); m != nil {
+			values[m[1]] = m[2]
+		}
+	}
+	p := systemProxy{Source: "scutil --proxy"}
+	if values["HTTPSEnable"] == "1" && values["HTTPSProxy"] != "" {
+		p.Proxy = values["HTTPSProxy"] + ":" + values["HTTPSPort"]
+	}
+	if values["ProxyAutoConfigEnable"] == "1" {
+		p.PAC = values["ProxyAutoConfigURLString"]
+	}
+	p.WPAD = values["ProxyAutoDiscoveryEnable"] == "1"
+	return p
+}
+
+// parseInternetSettings parses `reg query` output of the WinINet (IE)
+// Internet Settings key.
+func parseInternetSettings(out string) systemProxy {
+	p := systemProxy{Source: "Windows Internet Settings"}
+	enabled := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "ProxyEnable":
+			enabled = fields[2] == "0x1"
+		case "ProxyServer":
+			p.Proxy = winProxyServer(fields[2])
+		case "AutoConfigURL":
+			p.PAC = fields[2]
+		case "AutoDetect":
+			p.WPAD = fields[2] == "0x1"
+		}
+	}
+	if !enabled {
+		p.Proxy = ""
+	}
+	return p
+}
+
+// winProxyServer picks the HTTPS proxy out of a ProxyServer value, which is
+// either "host:port" or "http=h:p;https=h:p".
+func winProxyServer(value string) string {
+	if !strings.Contains(value, "=") {
+		return value
+	}
+	for _, part := range strings.Split(value, ";") {
+		if scheme, server, ok := strings.Cut(part, "="); ok && scheme == "https" {
+			return server
+		}
+	}
+	return ""
+}
+
+// parseWinHTTPProxy parses `netsh winhttp show proxy`.
+func parseWinHTTPProxy(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(k) == "Proxy Server(s)" {
+			return winProxyServer(strings.TrimSpace(v))
+		}
+	}
+	return ""
+}
+
+var (
+	pacReturn = regexp.MustCompile(`return\s*["']([^"']+)["']`)
+	pacAWS    = regexp.MustCompile(`(?i)(shExpMatch|dnsDomainIs|host\s*==|localHostOrDomainIs)[^\n]*amazonaws\.com`)
+)
+
+// pacDecision fetches the PAC file and guesses what it returns for AWS
+// hosts: the first return after a rule naming amazonaws.com, otherwise the
+// last return in the file (usually the default). It is a heuristic, not a
+// JavaScript evaluator.
+func pacDecision(env Env, pacURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pacURL, nil)
+	if err != nil {
+		return "", err
+	}
+	start := time.Now()
+	resp, err := env.ProxyHTTP.Do(req)
+	logProbe("pac", pacURL, start, err)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	script := string(body)
+
+	if loc := pacAWS.FindStringIndex(script); loc != nil {
+		if m := pacReturn.FindStringSubmatch(script[loc[1]:]); m != nil {
+			return m[1], nil
+		}
+	}
+	all := pacReturn.FindAllStringSubmatch(script, -1)
+	if len(all) == 0 {
+		return "", fmt.Errorf("no return statement found")
+	}
+	return all[len(all)-1][1], nil
+}
+
+// pacProxy returns the first proxy named in a PAC result such as
+// "PROXY p1:8080; DIRECT", or "" for DIRECT.
+func pacProxy(decision string) string {
+	for _, part := range strings.Split(decision, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 2 && (fields[0] == "PROXY" || fields[0] == "HTTPS") {
+			return fields[1]
+		}
+		if len(fields) == 1 && fields[0] == "DIRECT" {
+			return ""
+		}
+	}
+	return ""
+}
+
+// systemProxyResults reports OS-level proxy settings that the proxy
+// environment variables do not reflect. It returns nothing on platforms
+// without a supported source or when nothing is configured.
+func systemProxyResults(env Env) []CheckResult {
+	p, ok := detectSystemProxy()
+	if !ok || !p.configured() {
+		return nil
+	}
+	result := CheckResult{ID: "network.system_proxy", Name: "System Proxy"}
+
+	var found []string
+	if p.Proxy != "" {
+		found = append(found, "proxy "+p.Proxy)
+	}
+	if p.PAC != "" {
+		found = append(found, "PAC "+RedactSecrets(p.PAC))
+	}
+	if p.WPAD {
+		found = append(found, "auto-discovery (WPAD)")
+	}
+	result.Message = fmt.Sprintf("%s: %s", p.Source, strings.Join(found, ", "))
+
+	proxy := p.Proxy
+	if p.PAC != "" {
+		if decision, err := pacDecision(env, p.PAC); err != nil {
+			result.Message += fmt.Sprintf("; could not read PAC file: %v", err)
+		} else {
+			proxy = pacProxy(decision)
+			result.Message += fmt.Sprintf("; PAC heuristic for *.amazonaws.com: %s", decision)
+		}
+	}
+
+	envProxy, envName := proxyEnv("https_proxy", "HTTPS_PROXY", "http_proxy", "HTTP_PROXY")
+	switch {
+	case envProxy != "":
+		result.Status = "pass"
+		result.Message += fmt.Sprintf("; %s is set for Claude Code", envName)
+	case proxy == "" && p.PAC != "":
+		result.Status = "pass"
+		result.Message += "; AWS traffic goes direct, no environment variable needed"
+	default:
+		result.Status, result.Code = "warn", codeSystemProxyUnset
+		result.Message += "; HTTPS_PROXY is not set, and Claude Code (Node) ignores system proxy settings"
+		if proxy == "" {
+			result.Fix = "Ask IT for the proxy address the PAC/WPAD configuration selects and export HTTPS_PROXY=http://<proxy>:<port>"
+			break
+		}
+		result.Fix = fmt.Sprintf("export HTTPS_PROXY=http://%s", proxy)
+		result.FixCommand = &FixCommand{
+			Bash:       fmt.Sprintf(`export HTTPS_PROXY="${HTTPS_PROXY:-http://%s}"`, proxy),
+			PowerShell: fmt.Sprintf(`if (-not $env:HTTPS_PROXY) { $env:HTTPS_PROXY = "http://%s" }`, proxy),
+		}
+	}
+	return []CheckResult{result}
+}
@@ -0,0 +1,279 @@
+package probes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseScutilProxy(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want systemProxy
+	}{
+		{
+			name: "manual HTTPS proxy",
+			out: `<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+  }
+  HTTPSEnable : 1
+  HTTPSPort : 8080
+  HTTPSProxy : proxy.corp.example
+  ProxyAutoDiscoveryEnable : 0
+}`,
+			want: systemProxy{Source: "scutil --proxy", Proxy: "proxy.corp.example:8080"},
+		},
+		{
+			name: "PAC and WPAD",
+			out: `<dictionary> {
+  HTTPSEnable : 0
+  HTTPSProxy : stale.example
+  ProxyAutoConfigEnable : 1
+  ProxyAutoConfigURLString : http://wpad.corp.example/proxy.pac
+  ProxyAutoDiscoveryEnable : 1
+}`,
+			want: systemProxy{Source: "scutil --proxy", PAC: "http://wpad.corp.example/proxy.pac", WPAD: true},
+		},
+		{name: "nothing", out: "<dictionary> {\n}", want: systemProxy{Source: "scutil --proxy"}},
+	}
+	for _, tt := range tests {
+		if got := parseScutilProxy(tt.out); got != tt.want {
+			t.Errorf("%s: parseScutilProxy = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseInternetSettings(t *testing.T) {
+	const key = "HKEY_CURRENT_USER\\Software\\Microsoft\\Windows\\CurrentVersion\\Internet Settings\r\n"
+	tests := []struct {
+		name string
+		out  string
+		want systemProxy
+	}{
+		{
+			name: "enabled per-scheme proxy",
+			out:  key + "    ProxyEnable    REG_DWORD    0x1\r\n    ProxyServer    REG_SZ    http=p1:80;https=p2:8443\r\n",
+			want: systemProxy{Source: "Windows Internet Settings", Proxy: "p2:8443"},
+		},
+		{
+			name: "disabled proxy, PAC and auto-detect",
+			out:  key + "    ProxyEnable    REG_DWORD    0x0\r\n    ProxyServer    REG_SZ    p1:80\r\n    AutoConfigURL    REG_SZ    http://pac/p.pac\r\n    AutoDetect    REG_DWORD    0x1\r\n",
+			want: systemProxy{Source: "Windows Internet Settings", PAC: "http://pac/p.pac", WPAD: true},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseInternetSettings(tt.out); got != tt.want {
+			t.Errorf("%s: parseInternetSettings = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWinProxyServer(t *testing.T) {
+	tests := map[string]string{
+		"proxy:8080":                  "proxy:8080",
+		"http=p1:80;https=p2:8443":    "p2:8443",
+		"http=p1:80;ftp=p3:21":        "",
+		"https=p2:8443;socks=p4:1080": "p2:8443",
+	}
+	for in, want := range tests {
+		if got := winProxyServer(in); got != want {
+			t.Errorf("winProxyServer(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseWinHTTPProxy(t *testing.T) {
+	tests := map[string]string{
+		"\r\nCurrent WinHTTP proxy settings:\r\n\r\n    Direct access (no proxy server).\r\n":                                "",
+		"Current WinHTTP proxy settings:\r\n\r\n    Proxy Server(s) :  proxy.corp:3128\r\n    Bypass List     :  (none)\r\n": "proxy.corp:3128",
+	}
+	for in, want := range tests {
+		if got := parseWinHTTPProxy(in); got != want {
+			t.Errorf("parseWinHTTPProxy(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPACProxy(t *testing.T) {
+	tests := map[string]string{
+		"DIRECT":                      "",
+		"PROXY p1:8080; DIRECT":       "p1:8080",
+		"HTTPS secure:443":            "secure:443",
+		"SOCKS s:1080; PROXY p2:3128": "p2:3128",
+		"":                            "",
+	}
+	for in, want := range tests {
+		if got := pacProxy(in); got != want {
+			t.Errorf("pacProxy(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// pacServer serves script as a PAC file.
+func pacServer(t *testing.T, status int, script string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		w.WriteHeader(status)
+		fmt.Fprint(w, script)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/proxy.pac"
+}
+
+func TestPACDecision(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		script string
+		want   string
+		err    string
+	}{
+		{
+			name:   "rule for AWS",
+			status: http.StatusOK,
+			script: `function FindProxyForURL(url, host) {
+  if (isPlainHostName(host)) return "DIRECT";
+  if (shExpMatch(host, "*.amazonaws.com")) return "DIRECT";
+  return "PROXY proxy.corp:8080";
+}`,
+			want: "DIRECT",
+		},
+		{
+			name:   "falls back to the default",
+			status: http.StatusOK,
+			script: "function FindProxyForURL(url, host) {\n  if (dnsDomainIs(host, \".corp\")) return 'DIRECT';\n  return 'PROXY proxy.corp:8080; DIRECT';\n}",
+			want:   "PROXY proxy.corp:8080; DIRECT",
+		},
+		{name: "no return", status: http.StatusOK, script: "function FindProxyForURL() {}", err: "no return statement found"},
+		{name: "not found", status: http.StatusNotFound, err: "HTTP 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			got, err := pacDecision(env, pacServer(t, tt.status, tt.script))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("pacDecision = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemProxyResults(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fakes the GNOME gsettings source")
+	}
+	directPAC := `function FindProxyForURL(u, h) { if (dnsDomainIs(h, ".amazonaws.com")) return "DIRECT"; return "PROXY p:1"; }`
+	proxyPAC := `function FindProxyForURL(u, h) { return "PROXY pac-proxy.corp:3128"; }`
+	tests := []struct {
+		name     string
+		settings map[string]string // gsettings "schema key" -> value; nil means no gsettings
+		pac      string
+		envProxy string
+		status   string // empty means no result
+		fixBash  string
+		contains string
+	}{
+		{name: "no gsettings"},
+		{name: "proxy mode none", settings: map[string]string{"org.gnome.system.proxy mode": "'none'"}},
+		{
+			name: "manual proxy without HTTPS_PROXY",
+			settings: map[string]string{
+				"org.gnome.system.proxy mode":       "'manual'",
+				"org.gnome.system.proxy.https host": "'proxy.corp'",
+				"org.gnome.system.proxy.https port": "8080",
+			},
+			status:   "warn",
+			fixBash:  `export HTTPS_PROXY="${HTTPS_PROXY:-http://proxy.corp:8080}"`,
+			contains: "GNOME proxy settings: proxy proxy.corp:8080; HTTPS_PROXY is not set",
+		},
+		{
+			name: "manual proxy with HTTPS_PROXY",
+			settings: map[string]string{
+				"org.gnome.system.proxy mode":       "'manual'",
+				"org.gnome.system.proxy.https host": "'proxy.corp'",
+				"org.gnome.system.proxy.https port": "8080",
+			},
+			envProxy: "http://proxy.corp:8080",
+			status:   "pass",
+			contains: "HTTPS_PROXY is set for Claude Code",
+		},
+		{
+			name:     "PAC sends AWS direct",
+			settings: map[string]string{"org.gnome.system.proxy mode": "'auto'"},
+			pac:      directPAC,
+			status:   "pass",
+			contains: "AWS traffic goes direct",
+		},
+		{
+			name:     "PAC picks a proxy",
+			settings: map[string]string{"org.gnome.system.proxy mode": "'auto'"},
+			pac:      proxyPAC,
+			status:   "warn",
+			fixBash:  `export HTTPS_PROXY="${HTTPS_PROXY:-http://pac-proxy.corp:3128}"`,
+			contains: "PAC heuristic for *.amazonaws.com: PROXY pac-proxy.corp:3128",
+		},
+		{
+			name:     "WPAD only",
+			settings: map[string]string{"org.gnome.system.proxy mode": "'auto'", "org.gnome.system.proxy autoconfig-url": "''"},
+			status:   "warn",
+			contains: "auto-discovery (WPAD)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyEnv := map[string]string{}
+			if tt.envProxy != "" {
+				proxyEnv["HTTPS_PROXY"] = tt.envProxy
+			}
+			withProxyEnv(t, proxyEnv)
+			scripts := map[string]string{}
+			if tt.settings != nil {
+				if tt.pac != "" {
+					tt.settings["org.gnome.system.proxy autoconfig-url"] = "'" + pacServer(t, http.StatusOK, tt.pac) + "'"
+				}
+				var cases strings.Builder
+				for key, value := range tt.settings {
+					fmt.Fprintf(&cases, "  %q) echo %q ;;\n", "get "+key, value)
+				}
+				scripts["gsettings"] = "case \"$*\" in\n" + cases.String() + "  *) echo \"''\" ;;\nesac"
+			}
+			fakePath(t, scripts)
+
+			got := systemProxyResults(NewEnv(RunSettings{}))
+			if tt.status == "" {
+				if len(got) != 0 {
+					t.Errorf("results = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1", len(got))
+			}
+			r := got[0]
+			if r.ID != "network.system_proxy" || r.Status != tt.status || (r.Status == "warn") != (r.Code == codeSystemProxyUnset) {
+				t.Errorf("got %s %s %s, want %s", r.ID, r.Status, r.Code, tt.status)
+			}
+			if !strings.Contains(r.Message, tt.contains) {
+				t.Errorf("message %q does not contain %q", r.Message, tt.contains)
+			}
+			if tt.fixBash != "" && (r.FixCommand == nil || r.FixCommand.Bash != tt.fixBash) {
+				t.Errorf("fix command = %+v, want bash %q", r.FixCommand, tt.fixBash)
+			}
+			if tt.status == "warn" && tt.fixBash == "" && (r.FixCommand != nil || r.Fix == "") {
+				t.Errorf("fix, command = %q, %+v; want manual advice only", r.Fix, r.FixCommand)
+			}
+		})
+	}
+}
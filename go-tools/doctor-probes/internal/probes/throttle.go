@@ -0,0 +1,254 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Burst rate for the throttle probe: one request every 200ms (5 req/s).
+const throttleProbeInterval = 200 * time.Millisecond
+
+// MaxThrottleProbe caps --throttle-probe-count: every request of the burst
+// is a billed InvokeModel call.
+const MaxThrottleProbe = 20
+
+// Smallest valid Anthropic Messages request: one input word, one output token.
+const throttleProbeBody = `{"anthropic_version":"bedrock-2023-05-31","max_tokens":1,"messages":[{"role":"user","content":"ping"}]}`
+
+type probeAttempt struct {
+	Throttled  bool
+	RetryAfter string
+	Sent       time.Duration // since the burst started
+	Latency    time.Duration
+	Err        error
+}
+
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ThrottlingException" {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusTooManyRequests
+}
+
+// isConnectionError reports whether a runtime call failed without an HTTP
+// response: the endpoint was never reached, so the failure says nothing
+// about throttling.
+func isConnectionError(err error) bool {
+	var sendErr *smithyhttp.RequestSendError
+	return errors.As(err, &sendErr)
+}
+
+func retryAfterHeader(err error) string {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		return respErr.Response.Header.Get("Retry-After")
+	}
+	return ""
+}
+
+// sustainableRate roughly estimates the request rate, per second, that the
+// account sustains: the rate requests were accepted at before the first
+// throttle. A burst of a few seconds also spends the quota's burst
+// allowance, so the estimate runs high; ok is false when no request was
+// accepted before the first throttle, or none was throttled.
+func sustainableRate(attempts []probeAttempt) (perSecond float64, ok bool) {
+	accepted := 0
+	for _, a := range attempts {
+		if a.Throttled {
+			if accepted == 0 || a.Sent <= 0 {
+				return 0, false
+			}
+			return float64(accepted) / a.Sent.Seconds(), true
+		}
+		if a.Err == nil {
+			accepted++
+		}
+	}
+	return 0, false
+}
+
+func runThrottleProbe(env Env, region, modelID string, count int) ([]probeAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	// Disable SDK retries so every throttle is observed rather than absorbed.
+	client := env.BedrockRuntime(cfg, func(o *bedrockruntime.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+
+	ticker := time.NewTicker(throttleProbeInterval)
+	defer ticker.Stop()
+
+	attempts := make([]probeAttempt, 0, count)
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			<-ticker.C
+		}
+
+		reqStart := time.Now()
+		_, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(modelID),
+			ContentType: aws.String("application/json"),
+			Accept:      aws.String("application/json"),
+			Body:        []byte(throttleProbeBody),
+		})
+		attempt := probeAttempt{Sent: reqStart.Sub(start), Latency: time.Since(reqStart), Err: err}
+		if err != nil && isThrottlingError(err) {
+			attempt.Throttled = true
+			attempt.RetryAfter = retryAfterHeader(err)
+		}
+		logProbe("invoke", modelID, reqStart, err, "attempt", i+1, "throttled", attempt.Throttled)
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, nil
+}
+
+func throttleProbeResult(env Env, region string, count int) CheckResult {
+	const costNote = "Note: each probe request is a 1-token InvokeModel call and incurs a small charge"
+
+	modelID := configuredModel()
+	if modelID == "" {
+		return CheckResult{
+			ID:      "bedrock.throttling",
+			Code:    codeModelUnset,
+			Name:    "Bedrock Throttling Probe",
+			Status:  "warn",
+			Message: "ANTHROPIC_MODEL not set; no model to probe",
+			Fix:     "export ANTHROPIC_MODEL=<model-or-inference-profile-id>",
+		}
+	}
+
+	attempts, err := runThrottleProbe(env, region, modelID, count)
+	if err != nil {
+		result := CheckResult{
+			ID:      "bedrock.throttling",
+			Name:    "Bedrock Throttling Probe",
+			Status:  "warn",
+			Message: err.Error(),
+			Fix:     "Check AWS credentials and configuration",
+		}
+		result.setAWSError(err, "bedrock:InvokeModel on "+modelID)
+		return result
+	}
+
+	var throttled, succeeded, unreachable int
+	var firstThrottle *probeAttempt
+	var retryAfter []string
+	var connErr, otherErr error
+	for i, a := range attempts {
+		switch {
+		case a.Throttled:
+			throttled++
+			if firstThrottle == nil {
+				firstThrottle = &attempts[i]
+			}
+			if a.RetryAfter != "" {
+				retryAfter = append(retryAfter, a.RetryAfter)
+			}
+		case isConnectionError(a.Err):
+			unreachable++
+			connErr = a.Err
+		case a.Err != nil:
+			otherErr = a.Err
+		default:
+			succeeded++
+		}
+	}
+	metrics := map[string]float64{
+		"requests":    float64(len(attempts)),
+		"throttled":   float64(throttled),
+		"succeeded":   float64(succeeded),
+		"unreachable": float64(unreachable),
+	}
+
+	burstRate := int(time.Second / throttleProbeInterval)
+	switch {
+	case throttled == 0 && connErr != nil:
+		// Never reaching the endpoint is a connectivity failure, which the
+		// HTTPS checks explain; it must not read as a rate limit.
+		return CheckResult{
+			ID:      "bedrock.throttling",
+			Code:    classifyNetError(connErr),
+			Name:    "Bedrock Throttling Probe",
+			Status:  "warn",
+			Message: fmt.Sprintf("%d/%d requests could not reach the Bedrock runtime, so throttling could not be measured: %v. %s", unreachable, len(attempts), connErr, costNote),
+			Fix:     "This is a connectivity failure, not throttling: see the HTTPS Connectivity check" + env.Host.proxyHint(),
+			Metrics: metrics,
+		}
+	case throttled == 0 && otherErr != nil:
+		result := CheckResult{
+			ID:      "bedrock.throttling",
+			Name:    "Bedrock Throttling Probe",
+			Status:  "warn",
+			Message: fmt.Sprintf("%d/%d requests failed without throttling: %v. %s", len(attempts)-succeeded, len(attempts), otherErr, costNote),
+			Fix:     fmt.Sprintf("Check bedrock:InvokeModel permission and model access for %s", modelID),
+			Metrics: metrics,
+		}
+		result.setAWSError(otherErr, "bedrock:InvokeModel on "+modelID)
+		result.attachDiagnostics(env, awsDiagnostics(otherErr))
+		return result
+	case throttled == 0:
+		return CheckResult{
+			ID:      "bedrock.throttling",
+			Name:    "Bedrock Throttling Probe",
+			Status:  "pass",
+			Message: fmt.Sprintf("0/%d requests throttled in a burst at %d req/s. %s", len(attempts), burstRate, costNote),
+			Metrics: metrics,
+		}
+	}
+
+	backoff := "no Retry-After header returned"
+	if len(retryAfter) > 0 {
+		backoff = "Retry-After: " + strings.Join(retryAfter, ", ")
+	}
+	before := 0
+	for _, a := range attempts {
+		if a.Throttled {
+			break
+		}
+		if a.Err == nil {
+			before++
+		}
+	}
+	metrics["first_throttle_ms"] = DurationMs(firstThrottle.Sent)
+	estimate := fmt.Sprintf("Rough sustainable rate: below %d req/s, as nothing was accepted before the first throttle", burstRate)
+	if rate, ok := sustainableRate(attempts); ok {
+		metrics["sustainable_rps"] = rate
+		estimate = fmt.Sprintf("Rough sustainable rate: about %.1f req/s, the rate requests were accepted at before the first throttle", rate)
+	}
+
+	result := CheckResult{
+		ID:     "bedrock.throttling",
+		Code:   codeThrottled,
+		Name:   "Bedrock Throttling Probe",
+		Status: "warn",
+		Message: fmt.Sprintf("%d/%d requests throttled in a burst at %d req/s, the first after %d succeeded in %.1fs (%s). %s; a short burst does not measure the per-minute quota, see Bedrock Service Quotas. %s",
+			throttled, len(attempts), burstRate, before, firstThrottle.Sent.Seconds(), backoff, estimate, costNote),
+		Fix:     "Throttling is a quota limit, not a network fault: request a Bedrock quota increase for this model in the Service Quotas console",
+		Metrics: metrics,
+	}
+	if unreachable > 0 {
+		result.Message += fmt.Sprintf(" %d other requests could not reach the endpoint: %v", unreachable, connErr)
+	}
+	result.attachDiagnostics(env, awsDiagnostics(firstThrottle.Err))
+	return result
+}
@@ -0,0 +1,145 @@
+package probes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestThrottleProbeResult(t *testing.T) {
+	const haiku = "anthropic.claude-3-5-haiku-20241022-v1:0"
+	tests := []struct {
+		name     string
+		answers  []string // per request, the last repeating: ok, throttle, deny
+		endpoint string   // overrides the fake runtime
+		status   string
+		code     string
+		contains []string
+		absent   []string
+	}{
+		{
+			name:     "all succeed",
+			answers:  []string{"ok"},
+			status:   "pass",
+			contains: []string{"0/3 requests throttled in a burst at 5 req/s", "incurs a small charge"},
+		},
+		{
+			name:     "throttled",
+			answers:  []string{"ok", "throttle"},
+			status:   "warn",
+			code:     codeThrottled,
+			contains: []string{"2/3 requests throttled in a burst at 5 req/s, the first after 1 succeeded", "Retry-After: 2, 2", "Rough sustainable rate: about ", "does not measure the per-minute quota", "quota increase"},
+		},
+		{
+			name:     "throttled from the start",
+			answers:  []string{"throttle"},
+			status:   "warn",
+			code:     codeThrottled,
+			contains: []string{"3/3 requests throttled", "Rough sustainable rate: below 5 req/s"},
+		},
+		{
+			name:     "connection refused",
+			endpoint: "http://127.0.0.1:1",
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"3/3 requests could not reach the Bedrock runtime", "connectivity failure, not throttling"},
+			absent:   []string{"quota"},
+		},
+		{
+			name:     "denied",
+			answers:  []string{"deny"},
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"3/3 requests failed without throttling", "bedrock:InvokeModel on " + haiku},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			var (
+				mu       sync.Mutex
+				requests int
+			)
+			probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/model/"+haiku+"/invoke" {
+					t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+					return
+				}
+				mu.Lock()
+				answer := tt.answers[min(requests, len(tt.answers)-1)]
+				requests++
+				mu.Unlock()
+				switch answer {
+				case "ok":
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"content":[{"type":"text","text":"p"}],"stop_reason":"max_tokens"}`))
+				case "throttle":
+					w.Header().Set("Retry-After", "2")
+					probestest.AWSJSONError(w, http.StatusTooManyRequests, "ThrottlingException", "Too many requests, please wait before trying again.")
+				default:
+					probestest.AWSJSONError(w, http.StatusForbidden, "AccessDeniedException", "not authorized")
+				}
+			})
+			if tt.endpoint != "" {
+				t.Setenv("AWS_ENDPOINT_URL", tt.endpoint)
+			}
+			t.Setenv("ANTHROPIC_MODEL", haiku)
+
+			r := throttleProbeResult(env, "us-east-1", 3)
+			if r.ID != "bedrock.throttling" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("%q contains %q", text, unwanted)
+				}
+			}
+			if r.Metrics["requests"] != 3 {
+				t.Errorf("metrics %v, want 3 requests", r.Metrics)
+			}
+		})
+	}
+}
+
+func TestSustainableRate(t *testing.T) {
+	ok := probeAttempt{}
+	throttled := func(sent time.Duration) probeAttempt { return probeAttempt{Throttled: true, Sent: sent} }
+	tests := []struct {
+		name     string
+		attempts []probeAttempt
+		rate     float64
+		ok       bool
+	}{
+		{name: "two accepted in 800ms", attempts: []probeAttempt{ok, ok, throttled(800 * time.Millisecond), ok}, rate: 2.5, ok: true},
+		{name: "failures are not accepted", attempts: []probeAttempt{ok, {Err: errors.New("reset")}, ok, ok, throttled(2 * time.Second)}, rate: 1.5, ok: true},
+		{name: "throttled first", attempts: []probeAttempt{throttled(0), ok}},
+		{name: "never throttled", attempts: []probeAttempt{ok, ok}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, ok := sustainableRate(tt.attempts)
+			if rate != tt.rate || ok != tt.ok {
+				t.Errorf("sustainableRate = %g, %v; want %g, %v", rate, ok, tt.rate, tt.ok)
+			}
+		})
+	}
+}
+
+func TestThrottleProbeResultNoModel(t *testing.T) {
+	env := NewEnv(RunSettings{})
+	t.Setenv("ANTHROPIC_MODEL", "")
+	if r := throttleProbeResult(env, "us-east-1", 3); r.Status != "warn" || r.Code != codeModelUnset {
+		t.Errorf("got %s %s, want a warning that no model is set", r.Status, r.Code)
+	}
+}
@@ -0,0 +1,275 @@
+package probes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// timeSyncStaleAfter is how old the last sync may be. SigV4 tolerates 5
+// minutes of skew, which a drifting clock takes days to build up, and Windows
+// syncs only weekly by default.
+const timeSyncStaleAfter = 8 * 24 * time.Hour
+
+// amazonTimeSync is the Amazon Time Sync Service, reachable from every VPC.
+const amazonTimeSync = "169.254.169.123"
+
+// timesyncdStamp is touched by systemd-timesyncd on every successful sync.
+var timesyncdStamp = "/run/systemd/timesync/synchronized"
+
+// errNoTimeTool means none of the platform's time sync tools is installed.
+var errNoTimeTool = errors.New("no time sync tool found")
+
+// timeSync is what the platform's time service reports.
+type timeSync struct {
+	Tool     string    // the command that reported it
+	Enabled  bool      // network time sync is turned on
+	Synced   bool      // the service considers the clock synchronized
+	LastSync time.Time // zero when the tool does not say
+	Source   string    // the time server in use or configured
+}
+
+// keyValues splits "key<sep>value" lines, trimming both sides.
+func keyValues(out, sep string) map[string]string {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(line, sep); ok {
+			values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return values
+}
+
+// parseChronyTracking reads chronyc tracking:
+//
+//	Reference ID    : A9FEA97B (169.254.169.123)
+//	Ref time (UTC)  : Fri Oct 16 21:58:01 2026
+//	Leap status     : Normal
+func parseChronyTracking(out string) (timeSync, error) {
+	s := timeSync{Tool: "chronyc"}
+	if strings.Contains(out, "Cannot talk to daemon") {
+		return s, nil
+	}
+	values := keyValues(out, " : ")
+	leap, ok := values["Leap status"]
+	if !ok {
+		return s, fmt.Errorf("unexpected chronyc tracking output %q", out)
+	}
+	s.Enabled = true
+	s.Synced = leap != "Not synchronised"
+	if _, name, ok := strings.Cut(values["Reference ID"], "("); ok {
+		s.Source = strings.TrimSuffix(name, ")")
+	}
+	if t, err := time.Parse("Mon Jan _2 15:04:05 2006", values["Ref time (UTC)"]); err == nil && t.Year() > 1970 {
+		s.LastSync = t
+	}
+	return s, nil
+}
+
+// parseTimedatectl reads timedatectl show and timedatectl show-timesync,
+// which only systemd-timesyncd answers.
+func parseTimedatectl(show, timesync string) (timeSync, error) {
+	s := timeSync{Tool: "timedatectl"}
+	values := keyValues(show, "=")
+	ntp, ok := values["NTP"]
+	if !ok {
+		return s, fmt.Errorf("unexpected timedatectl show output %q", show)
+	}
+	s.Enabled = ntp == "yes" && values["CanNTP"] != "no"
+	s.Synced = values["NTPSynchronized"] == "yes"
+	s.Source = keyValues(timesync, "=")["ServerName"]
+	return s, nil
+}
+
+// parseW32tm reads w32tm /query /status:
+//
+//	Last Successful Sync Time: 10/16/2026 9:58:01 PM
+//	Source: time.windows.com,0x9
+func parseW32tm(out string) (timeSync, error) {
+	s := timeSync{Tool: "w32tm"}
+	if strings.Contains(out, "has not been started") {
+		return s, nil
+	}
+	values := keyValues(out, ": ")
+	source, ok := values["Source"]
+	if !ok {
+		return s, fmt.Errorf("unexpected w32tm /query /status output %q", out)
+	}
+	s.Source, _, _ = strings.Cut(source, ",")
+	// The service runs but keeps time from the hardware clock alone.
+	if s.Source != "Local CMOS Clock" && s.Source != "Free-running System Clock" {
+		s.Enabled, s.Synced = true, true
+	}
+	// The timestamp follows the locale; an unknown layout leaves the age
+	// unknown.
+	for _, layout := range []string{"1/2/2006 3:04:05 PM", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, values["Last Successful Sync Time"], time.Local); err == nil {
+			s.LastSync = t
+			break
+		}
+	}
+	if s.LastSync.IsZero() && values["Last Successful Sync Time"] == "unspecified" {
+		s.Synced = false
+	}
+	return s, nil
+}
+
+// parseSystemsetup reads systemsetup -getusingnetworktime ("Network Time:
+// On") and -getnetworktimeserver ("Network Time Server: time.apple.com").
+func parseSystemsetup(using, server string) (timeSync, error) {
+	s := timeSync{Tool: "systemsetup"}
+	flag, ok := keyValues(using, ": ")["Network Time"]
+	if !ok {
+		return s, fmt.Errorf("unexpected systemsetup output %q", using)
+	}
+	s.Enabled = flag == "On"
+	s.Synced = s.Enabled // timed does not report sync state
+	s.Source = keyValues(server, ": ")["Network Time Server"]
+	return s, nil
+}
+
+// readTimeSync asks the platform's time service about its state.
+func readTimeSync() (timeSync, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("systemsetup"); err != nil {
+			return timeSync{}, errNoTimeTool
+		}
+		using, err := runLocal("systemsetup", "-getusingnetworktime")
+		if strings.Contains(using, "administrator access") {
+			return timeSync{}, errors.New("systemsetup needs administrator access; run with sudo to check network time")
+		}
+		if err != nil {
+			return timeSync{}, fmt.Errorf("systemsetup -getusingnetworktime failed: %v %s", err, using)
+		}
+		server, _ := runLocal("systemsetup", "-getnetworktimeserver")
+		return parseSystemsetup(using, server)
+	case "windows":
+		if _, err := exec.LookPath("w32tm"); err != nil {
+			return timeSync{}, errNoTimeTool
+		}
+		// w32tm exits non-zero when the service is stopped; the output says so.
+		out, _ := runLocal("w32tm", "/query", "/status")
+		return parseW32tm(out)
+	}
+
+	if _, err := exec.LookPath("chronyc"); err == nil {
+		out, _ := runLocal("chronyc", "tracking")
+		s, err := parseChronyTracking(out)
+		if err != nil || s.Enabled {
+			return s, err
+		}
+		// chronyd is not running; timesyncd may be in use instead.
+		if _, err := exec.LookPath("timedatectl"); err != nil {
+			return s, nil
+		}
+	}
+	if _, err := exec.LookPath("timedatectl"); err != nil {
+		return timeSync{}, errNoTimeTool
+	}
+	show, err := runLocal("timedatectl", "show")
+	if err != nil {
+		return timeSync{}, fmt.Errorf("timedatectl show failed: %v %s", err, show)
+	}
+	timesync, _ := runLocal("timedatectl", "show-timesync")
+	s, err := parseTimedatectl(show, timesync)
+	if err == nil && s.Synced {
+		if info, statErr := os.Stat(timesyncdStamp); statErr == nil {
+			s.LastSync = info.ModTime()
+		}
+	}
+	return s, err
+}
+
+// timeSyncEnable says how to turn on sync with the tool that reported it off.
+func timeSyncEnable(tool string) string {
+	switch tool {
+	case "chronyc":
+		return "Start chronyd (sudo systemctl enable --now chronyd)"
+	case "systemsetup":
+		return "Turn on network time (sudo systemsetup -setusingnetworktime on, or System Settings > General > Date & Time > Set time and date automatically)"
+	case "w32tm":
+		return "Start the Windows Time service with a network source (net start w32time; w32tm /config /syncfromflags:manual /manualpeerlist:time.windows.com /update; w32tm /resync)"
+	default:
+		return "Turn on network time (sudo timedatectl set-ntp true)"
+	}
+}
+
+// amazonTimeSyncFix says how to point the tool at the Amazon Time Sync
+// Service.
+func amazonTimeSyncFix(tool string) string {
+	switch tool {
+	case "chronyc":
+		return fmt.Sprintf("add \"server %s prefer iburst\" to chrony.conf and restart chronyd", amazonTimeSync)
+	case "w32tm":
+		return fmt.Sprintf("w32tm /config /manualpeerlist:%s /syncfromflags:manual /update", amazonTimeSync)
+	default:
+		return fmt.Sprintf("set NTP=%s in /etc/systemd/timesyncd.conf and restart systemd-timesyncd", amazonTimeSync)
+	}
+}
+
+// timeSyncResult checks that the clock is kept in sync, since skew that
+// breaks SigV4 signing recurs when it is not. It complements the signed
+// request check, which measures the skew itself.
+func timeSyncResult(env Env) CheckResult {
+	s, err := readTimeSync()
+	return timeSyncVerdict(env, s, err, time.Now())
+}
+
+func timeSyncVerdict(env Env, s timeSync, err error, now time.Time) CheckResult {
+	result := CheckResult{ID: "clock.time_sync", Name: "Time Sync"}
+	if errors.Is(err, errNoTimeTool) {
+		result.Status = "skip"
+		result.Message = "No time sync tool (chronyc, timedatectl, systemsetup, or w32tm) found to ask"
+		if env.Host.Container != "" {
+			result.Message += "; in a container the clock is the host's, so check time sync there"
+		}
+		return result
+	}
+	if err != nil {
+		result.Status = "warn"
+		result.Message = err.Error()
+		return result
+	}
+
+	source := ""
+	if s.Source != "" {
+		source = " from " + s.Source
+	}
+	age := ""
+	if !s.LastSync.IsZero() {
+		age = fmt.Sprintf(", last synchronized %s ago", now.Sub(s.LastSync).Round(time.Minute))
+	}
+	onEC2 := env.Host.Cloud == "EC2"
+
+	switch {
+	case !s.Enabled:
+		result.Status, result.Code = "warn", codeTimeSyncDisabled
+		result.Message = fmt.Sprintf("Network time sync is off (%s): the clock drifts until AWS rejects request signatures", s.Tool)
+		result.Fix = timeSyncEnable(s.Tool)
+		if onEC2 {
+			result.Fix += "; in a VPC, use the Amazon Time Sync Service: " + amazonTimeSyncFix(s.Tool)
+		}
+		return result
+	case !s.Synced:
+		result.Status, result.Code = "warn", codeTimeSyncStale
+		result.Message = fmt.Sprintf("Network time sync is on but has not synchronized%s (%s)", source, s.Tool)
+	case !s.LastSync.IsZero() && now.Sub(s.LastSync) > timeSyncStaleAfter:
+		result.Status, result.Code = "warn", codeTimeSyncStale
+		result.Message = fmt.Sprintf("The clock was last synchronized%s %s ago (%s); the time server may be unreachable", source, now.Sub(s.LastSync).Round(time.Hour), s.Tool)
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Network time sync is on%s%s (%s)", source, age, s.Tool)
+		return result
+	}
+	if onEC2 {
+		result.Fix = "In a VPC, use the Amazon Time Sync Service, which needs no internet access: " + amazonTimeSyncFix(s.Tool)
+	} else {
+		result.Fix = "Check that the time server is reachable (NTP is UDP port 123), or configure one that is"
+	}
+	return result
+}
@@ -0,0 +1,261 @@
+package probes
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chronyTracking = `Reference ID    : A9FEA97B (169.254.169.123)
+Stratum         : 4
+Ref time (UTC)  : Fri Oct 16 21:58:01 2026
+System time     : 0.000011183 seconds slow of NTP time
+Last offset     : -0.000005133 seconds
+Leap status     : Normal`
+
+const chronyUnsynced = `Reference ID    : 00000000 ()
+Stratum         : 0
+Ref time (UTC)  : Thu Jan 01 00:00:00 1970
+Leap status     : Not synchronised`
+
+const w32tmStatus = `Leap Indicator: 0(no warning)
+Stratum: 4 (secondary reference - syncd by (S)NTP)
+ReferenceId: 0x14658D16 (source IP:  20.101.57.9)
+Last Successful Sync Time: 10/16/2026 9:58:01 PM
+Source: time.windows.com,0x9
+Poll Interval: 10 (1024s)`
+
+const w32tmLocalClock = `Leap Indicator: 3(not synchronized)
+Stratum: 1 (primary reference - syncd by radio clock)
+Last Successful Sync Time: unspecified
+Source: Local CMOS Clock
+Poll Interval: 6 (64s)`
+
+func TestParseTimeSync(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func() (timeSync, error)
+		want  timeSync
+	}{
+		{
+			name:  "chrony synchronized",
+			parse: func() (timeSync, error) { return parseChronyTracking(chronyTracking) },
+			want:  timeSync{Tool: "chronyc", Enabled: true, Synced: true, Source: "169.254.169.123", LastSync: time.Date(2026, 10, 16, 21, 58, 1, 0, time.UTC)},
+		},
+		{
+			name:  "chrony never synchronized",
+			parse: func() (timeSync, error) { return parseChronyTracking(chronyUnsynced) },
+			want:  timeSync{Tool: "chronyc", Enabled: true},
+		},
+		{
+			name:  "chronyd not running",
+			parse: func() (timeSync, error) { return parseChronyTracking("506 Cannot talk to daemon") },
+			want:  timeSync{Tool: "chronyc"},
+		},
+		{
+			name: "timesyncd",
+			parse: func() (timeSync, error) {
+				return parseTimedatectl("Timezone=UTC\nNTP=yes\nCanNTP=yes\nNTPSynchronized=yes", "FallbackNTPServers=ntp.ubuntu.com\nServerName=169.254.169.123\nServerAddress=169.254.169.123")
+			},
+			want: timeSync{Tool: "timedatectl", Enabled: true, Synced: true, Source: "169.254.169.123"},
+		},
+		{
+			name:  "timedatectl without a sync service",
+			parse: func() (timeSync, error) { return parseTimedatectl("NTP=no\nCanNTP=no\nNTPSynchronized=no", "") },
+			want:  timeSync{Tool: "timedatectl"},
+		},
+		{
+			name:  "w32tm synchronized",
+			parse: func() (timeSync, error) { return parseW32tm(w32tmStatus) },
+			want:  timeSync{Tool: "w32tm", Enabled: true, Synced: true, Source: "time.windows.com", LastSync: time.Date(2026, 10, 16, 21, 58, 1, 0, time.Local)},
+		},
+		{
+			name:  "w32tm on the hardware clock",
+			parse: func() (timeSync, error) { return parseW32tm(w32tmLocalClock) },
+			want:  timeSync{Tool: "w32tm", Source: "Local CMOS Clock"},
+		},
+		{
+			name: "w32time stopped",
+			parse: func() (timeSync, error) {
+				return parseW32tm("The following error occurred: The service has not been started. (0x80070426)")
+			},
+			want: timeSync{Tool: "w32tm"},
+		},
+		{
+			name: "macOS network time on",
+			parse: func() (timeSync, error) {
+				return parseSystemsetup("Network Time: On", "Network Time Server: time.apple.com")
+			},
+			want: timeSync{Tool: "systemsetup", Enabled: true, Synced: true, Source: "time.apple.com"},
+		},
+		{
+			name:  "macOS network time off",
+			parse: func() (timeSync, error) { return parseSystemsetup("Network Time: Off", "") },
+			want:  timeSync{Tool: "systemsetup"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Tool != tt.want.Tool || got.Enabled != tt.want.Enabled || got.Synced != tt.want.Synced || got.Source != tt.want.Source || !got.LastSync.Equal(tt.want.LastSync) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []func() (timeSync, error){
+		func() (timeSync, error) { return parseChronyTracking("chronyc: command failed") },
+		func() (timeSync, error) { return parseTimedatectl("Failed to connect to bus", "") },
+		func() (timeSync, error) { return parseW32tm("garbage") },
+		func() (timeSync, error) {
+			return parseSystemsetup("You need administrator access to run this tool", "")
+		},
+	} {
+		if s, err := bad(); err == nil {
+			t.Errorf("%s parsed unexpected output without an error", s.Tool)
+		}
+	}
+}
+
+func TestTimeSyncVerdict(t *testing.T) {
+	now := time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		sync     timeSync
+		err      error
+		cloud    string
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "synchronized",
+			sync:     timeSync{Tool: "chronyc", Enabled: true, Synced: true, Source: "169.254.169.123", LastSync: now.Add(-5 * time.Minute)},
+			status:   "pass",
+			contains: []string{"Network time sync is on from 169.254.169.123, last synchronized 5m0s ago (chronyc)"},
+		},
+		{
+			name:     "disabled",
+			sync:     timeSync{Tool: "timedatectl"},
+			status:   "warn",
+			code:     codeTimeSyncDisabled,
+			contains: []string{"Network time sync is off (timedatectl)", "sudo timedatectl set-ntp true"},
+		},
+		{
+			name:     "disabled on EC2",
+			sync:     timeSync{Tool: "chronyc"},
+			cloud:    "EC2",
+			status:   "warn",
+			code:     codeTimeSyncDisabled,
+			contains: []string{"systemctl enable --now chronyd", "Amazon Time Sync Service", "server 169.254.169.123 prefer iburst"},
+		},
+		{
+			name:     "never synchronized",
+			sync:     timeSync{Tool: "timedatectl", Enabled: true, Source: "ntp.corp.example"},
+			status:   "warn",
+			code:     codeTimeSyncStale,
+			contains: []string{"on but has not synchronized from ntp.corp.example", "UDP port 123"},
+		},
+		{
+			name:     "stale on EC2",
+			sync:     timeSync{Tool: "w32tm", Enabled: true, Synced: true, Source: "time.windows.com", LastSync: now.Add(-30 * 24 * time.Hour)},
+			cloud:    "EC2",
+			status:   "warn",
+			code:     codeTimeSyncStale,
+			contains: []string{"last synchronized from time.windows.com 720h0m0s ago", "/manualpeerlist:169.254.169.123"},
+		},
+		{
+			name:     "no tool",
+			err:      errNoTimeTool,
+			status:   "skip",
+			contains: []string{"No time sync tool"},
+		},
+		{
+			name:     "tool failed",
+			err:      errors.New("systemsetup needs administrator access; run with sudo to check network time"),
+			status:   "warn",
+			contains: []string{"administrator access"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			env.Host.Cloud = tt.cloud
+			r := timeSyncVerdict(env, tt.sync, tt.err, now)
+			if r.ID != "clock.time_sync" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadTimeSyncLinux(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads the Linux tools")
+	}
+	stamp := filepath.Join(t.TempDir(), "synchronized")
+	if err := os.WriteFile(stamp, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := timesyncdStamp
+	timesyncdStamp = stamp
+	t.Cleanup(func() { timesyncdStamp = saved })
+
+	tests := []struct {
+		name    string
+		scripts map[string]string
+		want    timeSync
+		err     error
+	}{
+		{
+			name:    "chrony",
+			scripts: map[string]string{"chronyc": "echo '" + chronyTracking + "'"},
+			want:    timeSync{Tool: "chronyc", Enabled: true, Synced: true, Source: "169.254.169.123"},
+		},
+		{
+			name: "chronyd stopped, timesyncd running",
+			scripts: map[string]string{
+				"chronyc":     `echo "506 Cannot talk to daemon"; exit 1`,
+				"timedatectl": `if [ "$1" = show ]; then printf 'NTP=yes\nNTPSynchronized=yes\n'; else echo ServerName=ntp.ubuntu.com; fi`,
+			},
+			want: timeSync{Tool: "timedatectl", Enabled: true, Synced: true, Source: "ntp.ubuntu.com"},
+		},
+		{
+			name:    "chronyd stopped, no timedatectl",
+			scripts: map[string]string{"chronyc": `echo "506 Cannot talk to daemon"; exit 1`},
+			want:    timeSync{Tool: "chronyc"},
+		},
+		{
+			name: "no tools",
+			err:  errNoTimeTool,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakePath(t, tt.scripts)
+			got, err := readTimeSync()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got.Tool != tt.want.Tool || got.Enabled != tt.want.Enabled || got.Synced != tt.want.Synced || got.Source != tt.want.Source {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.Tool == "timedatectl" && got.LastSync.IsZero() {
+				t.Error("last sync not read from the timesyncd stamp")
+			}
+		})
+	}
+}
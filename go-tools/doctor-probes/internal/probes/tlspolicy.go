@@ -0,0 +1,101 @@
+package probes
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tlsVersions are the protocol versions probed one at a time, oldest first.
+var tlsVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// tlsPolicyResult handshakes with the Bedrock runtime endpoint, or with the
+// proxy when it intercepts TLS, once per protocol version and reports which
+// versions the terminating server accepts. Certificates are not verified
+// here: HTTPS Connectivity checks trust, and no data is sent over these
+// connections.
+func tlsPolicyResult(env Env, bedrockURL string) CheckResult {
+	result := CheckResult{ID: "tls.version_policy", Name: "TLS Version Policy"}
+
+	host, addr, err := endpointAddr(bedrockURL)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = err.Error()
+		return result
+	}
+	proxy, err := probeProxy(host)
+	if err != nil {
+		result.Status, result.Code = "warn", codeConnectFailure
+		result.Message = fmt.Sprintf("Cannot parse the proxy URL: %v", err)
+		return result
+	}
+	handshake := func(label string, cfg *tls.Config) (tls.ConnectionState, error) {
+		cfg.InsecureSkipVerify = true
+		start := time.Now()
+		state, err := tlsHandshake(env, addr, proxy, cfg)
+		logProbe("tls_version", addr, start, err, "version", label, "negotiated", tls.VersionName(state.Version))
+		return state, err
+	}
+
+	state, defaultErr := handshake("default", &tls.Config{})
+	if defaultErr != nil && classifyNetError(defaultErr) != codeTLSFailure {
+		// Not a TLS problem; the connectivity checks report it.
+		result.Status, result.Code = "warn", classifyNetError(defaultErr)
+		result.Message = fmt.Sprintf("Could not reach %s to test TLS versions: %v", addr, defaultErr)
+		result.Fix = "See HTTPS Connectivity"
+		return result
+	}
+
+	var accepted, rejected []string
+	legacy := false
+	for _, v := range tlsVersions {
+		if _, err := handshake(tls.VersionName(v), &tls.Config{MinVersion: v, MaxVersion: v}); err != nil {
+			rejected = append(rejected, tls.VersionName(v))
+			continue
+		}
+		accepted = append(accepted, tls.VersionName(v))
+		legacy = legacy || v < tls.VersionTLS12
+	}
+
+	terminator := addr
+	if proxy != nil {
+		terminator = fmt.Sprintf("%s via proxy %s", addr, (&url.URL{Scheme: proxy.Scheme, Host: proxy.Host}).String())
+	}
+	if len(state.PeerCertificates) > 0 {
+		issuer := state.PeerCertificates[0].Issuer
+		name := issuer.CommonName
+		if name == "" {
+			name = issuer.String()
+		}
+		terminator += fmt.Sprintf(" (certificate issued by %s)", name)
+	}
+	if defaultErr != nil {
+		result.Message = fmt.Sprintf("Default TLS handshake with %s failed: %v", terminator, defaultErr)
+	} else {
+		result.Message = fmt.Sprintf("Negotiated %s with %s", tls.VersionName(state.Version), terminator)
+		if env.Verbose {
+			result.Message += fmt.Sprintf(", cipher %s", tls.CipherSuiteName(state.CipherSuite))
+		}
+	}
+	if len(accepted) > 0 {
+		result.Message += fmt.Sprintf("; accepts %s", strings.Join(accepted, ", "))
+	}
+	if len(rejected) > 0 {
+		result.Message += fmt.Sprintf("; rejects %s", strings.Join(rejected, ", "))
+	}
+
+	switch {
+	case defaultErr != nil:
+		result.Status, result.Code = "fail", codeTLSNoModern
+		result.Message += "; TLS 1.2 or later could not be negotiated"
+		result.Fix = "Whatever terminates TLS on this route only speaks pre-1.2 TLS; AWS requires TLS 1.2+. Have the proxy or firewall upgraded or bypassed for *.amazonaws.com"
+	case legacy:
+		result.Status, result.Code = "warn", codeTLSLegacyAccepted
+		result.Fix = "Whatever terminates TLS on this route accepts TLS 1.0/1.1, so a downgrade is possible; AWS itself rejects them, so this is usually a TLS-inspecting proxy. Ask its owners to require TLS 1.2+"
+	default:
+		result.Status = "pass"
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package probes
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEndpointAddr(t *testing.T) {
+	tests := []struct {
+		url, host, addr string
+	}{
+		{"https://bedrock-runtime.us-east-1.amazonaws.com", "bedrock-runtime.us-east-1.amazonaws.com", "bedrock-runtime.us-east-1.amazonaws.com:443"},
+		{"https://vpce.example:8443/path", "vpce.example", "vpce.example:8443"},
+		{"https://[::1]", "::1", "[::1]:443"},
+	}
+	for _, tt := range tests {
+		host, addr, err := endpointAddr(tt.url)
+		if err != nil || host != tt.host || addr != tt.addr {
+			t.Errorf("endpointAddr(%q) = %q, %q, %v; want %q, %q", tt.url, host, addr, err, tt.host, tt.addr)
+		}
+	}
+	if _, _, err := endpointAddr("https://bad host"); err == nil {
+		t.Error("endpointAddr accepted an invalid URL")
+	}
+}
+
+// tlsServer starts a TLS server limited to the given protocol versions.
+func tlsServer(t *testing.T, min, max uint16) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{MinVersion: min, MaxVersion: max}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestTLSPolicyResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		status   string
+		code     string
+		contains []string
+	}{
+		{
+			name:     "modern only",
+			url:      tlsServer(t, tls.VersionTLS12, 0),
+			status:   "pass",
+			contains: []string{"Negotiated TLS 1.3 with 127.0.0.1:", "(certificate issued by O=Acme Co)", "; accepts TLS 1.2, TLS 1.3; rejects TLS 1.0, TLS 1.1"},
+		},
+		{
+			name:     "legacy accepted",
+			url:      tlsServer(t, tls.VersionTLS10, 0),
+			status:   "warn",
+			code:     codeTLSLegacyAccepted,
+			contains: []string{"; accepts TLS 1.0, TLS 1.1, TLS 1.2, TLS 1.3"},
+		},
+		{
+			name:     "legacy only",
+			url:      tlsServer(t, tls.VersionTLS10, tls.VersionTLS11),
+			status:   "fail",
+			code:     codeTLSNoModern,
+			contains: []string{"Default TLS handshake with 127.0.0.1:", "; accepts TLS 1.0, TLS 1.1; rejects TLS 1.2, TLS 1.3; TLS 1.2 or later could not be negotiated"},
+		},
+		{
+			name:     "unreachable",
+			url:      "https://127.0.0.1:1",
+			status:   "warn",
+			code:     codeConnectRefused,
+			contains: []string{"Could not reach 127.0.0.1:1 to test TLS versions"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			withProxyEnv(t, nil)
+			r := tlsPolicyResult(env, tt.url)
+			if r.ID != "tls.version_policy" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(r.Message, want) {
+					t.Errorf("message %q does not contain %q", r.Message, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// usageWarnPercent is the share of a per-minute quota above which the
+// busiest minute is reported as a warning.
+const usageWarnPercent = 70.0
+
+// usageMetrics are the AWS/Bedrock metrics read per model.
+var usageMetrics = []string{"Invocations", "InvocationThrottles", "InputTokenCount", "OutputTokenCount"}
+
+// usageWindow is one model's usage over a window: totals, and the busiest
+// minute, which is what the per-minute quotas limit.
+type usageWindow struct {
+	Invocations    float64
+	Throttles      float64
+	InputTokens    float64
+	OutputTokens   float64
+	PeakRequests   float64 // invocations in the busiest minute
+	PeakTokens     float64 // input plus output tokens in the busiest minute
+	RequestPercent float64 // PeakRequests as a share of the quota; 0 without one
+	TokenPercent   float64
+}
+
+// modelUsage is the usage of one model ID (a foundation model or an
+// inference profile) over the past hour and day.
+type modelUsage struct {
+	ModelID      string
+	Hour, Day    usageWindow
+	RequestQuota float64 // applied requests/min quota; 0 when not found
+	TokenQuota   float64
+}
+
+// usageModelIDs lists the Claude model IDs the AWS/Bedrock namespace has
+// metrics for.
+func usageModelIDs(ctx context.Context, client CloudWatchClient) ([]string, error) {
+	seen := map[string]bool{}
+	paginator := cloudwatch.NewListMetricsPaginator(client, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("AWS/Bedrock"),
+		MetricName: aws.String("Invocations"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range page.Metrics {
+			for _, d := range m.Dimensions {
+				if id := aws.ToString(d.Value); aws.ToString(d.Name) == "ModelId" && strings.Contains(id, "anthropic.") {
+					seen[id] = true
+				}
+			}
+		}
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// fetchModelUsage reads the per-minute sums of usageMetrics for each model
+// over the past day and folds them into hour and day windows.
+func fetchModelUsage(ctx context.Context, client CloudWatchClient, ids []string, now time.Time) ([]modelUsage, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var queries []cwtypes.MetricDataQuery
+	for i, id := range ids {
+		for j, name := range usageMetrics {
+			queries = append(queries, cwtypes.MetricDataQuery{
+				Id: aws.String(fmt.Sprintf("m%d_%d", i, j)),
+				MetricStat: &cwtypes.MetricStat{
+					Metric: &cwtypes.Metric{
+						Namespace:  aws.String("AWS/Bedrock"),
+						MetricName: aws.String(name),
+						Dimensions: []cwtypes.Dimension{{Name: aws.String("ModelId"), Value: aws.String(id)}},
+					},
+					Period: aws.Int32(60),
+					Stat:   aws.String("Sum"),
+				},
+			})
+		}
+	}
+
+	// values[query ID][minute] = sum
+	values := map[string]map[time.Time]float64{}
+	end := now.Truncate(time.Minute)
+	paginator := cloudwatch.NewGetMetricDataPaginator(client, &cloudwatch.GetMetricDataInput{
+		StartTime:         aws.Time(end.Add(-24 * time.Hour)),
+		EndTime:           aws.Time(end),
+		MetricDataQueries: queries,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.MetricDataResults {
+			id := aws.ToString(r.Id)
+			if values[id] == nil {
+				values[id] = map[time.Time]float64{}
+			}
+			for k, t := range r.Timestamps {
+				if k < len(r.Values) {
+					values[id][t] += r.Values[k]
+				}
+			}
+		}
+	}
+
+	usage := make([]modelUsage, len(ids))
+	hourStart := end.Add(-time.Hour)
+	for i, id := range ids {
+		usage[i].ModelID = id
+		series := func(j int) map[time.Time]float64 { return values[fmt.Sprintf("m%d_%d", i, j)] }
+		invocations, throttles, input, output := series(0), series(1), series(2), series(3)
+		minutes := map[time.Time]bool{}
+		for _, s := range []map[time.Time]float64{invocations, throttles, input, output} {
+			for t := range s {
+				minutes[t] = true
+			}
+		}
+		for t := range minutes {
+			windows := []*usageWindow{&usage[i].Day}
+			if !t.Before(hourStart) {
+				windows = append(windows, &usage[i].Hour)
+			}
+			for _, w := range windows {
+				w.Invocations += invocations[t]
+				w.Throttles += throttles[t]
+				w.InputTokens += input[t]
+				w.OutputTokens += output[t]
+				w.PeakRequests = max(w.PeakRequests, invocations[t])
+				w.PeakTokens = max(w.PeakTokens, input[t]+output[t])
+			}
+		}
+	}
+	return usage, nil
+}
+
+// pairQuotas fills in each model's applied per-minute quotas, matched by the
+// model name the quotas are named after, and the utilization of its busiest
+// minutes.
+func pairQuotas(usage []modelUsage, quotas []quotaStatus, names map[string]string) {
+	for i := range usage {
+		u := &usage[i]
+		name := names[baseModelID(u.ModelID)]
+		for _, q := range quotas {
+			if name == "" || !strings.EqualFold(q.Model, name) {
+				continue
+			}
+			switch q.Unit {
+			case "requests/min":
+				u.RequestQuota = q.Applied
+			case "tokens/min":
+				u.TokenQuota = q.Applied
+			}
+		}
+		for _, w := range []*usageWindow{&u.Hour, &u.Day} {
+			if u.RequestQuota > 0 {
+				w.RequestPercent = 100 * w.PeakRequests / u.RequestQuota
+			}
+			if u.TokenQuota > 0 {
+				w.TokenPercent = 100 * w.PeakTokens / u.TokenQuota
+			}
+		}
+	}
+}
+
+func formatCount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// describe summarizes a window, e.g. "120 invocations (3 throttled), 90000
+// input / 8000 output tokens, peak 14 requests/min (28% of quota)".
+func (w usageWindow) describe() string {
+	s := fmt.Sprintf("%s invocations", formatCount(w.Invocations))
+	if w.Throttles > 0 {
+		s += fmt.Sprintf(" (%s throttled)", formatCount(w.Throttles))
+	}
+	s += fmt.Sprintf(", %s input / %s output tokens, peak %s requests/min", formatCount(w.InputTokens), formatCount(w.OutputTokens), formatCount(w.PeakRequests))
+	if w.RequestPercent > 0 {
+		s += fmt.Sprintf(" (%.0f%% of quota)", w.RequestPercent)
+	}
+	s += fmt.Sprintf(" and %s tokens/min", formatCount(w.PeakTokens))
+	if w.TokenPercent > 0 {
+		s += fmt.Sprintf(" (%.0f%% of quota)", w.TokenPercent)
+	}
+	return s
+}
+
+// metrics returns the window's raw numbers under keys prefixed with the
+// model ID and suffixed with the window, for dashboards.
+func (w usageWindow) metrics(m map[string]float64, modelID, window string) {
+	m[modelID+".invocations_"+window] = w.Invocations
+	m[modelID+".throttles_"+window] = w.Throttles
+	m[modelID+".input_tokens_"+window] = w.InputTokens
+	m[modelID+".output_tokens_"+window] = w.OutputTokens
+	m[modelID+".peak_requests_per_min_"+window] = w.PeakRequests
+	m[modelID+".peak_tokens_per_min_"+window] = w.PeakTokens
+	if w.RequestPercent > 0 {
+		m[modelID+".request_quota_pct_"+window] = w.RequestPercent
+	}
+	if w.TokenPercent > 0 {
+		m[modelID+".token_quota_pct_"+window] = w.TokenPercent
+	}
+}
+
+// usageResult reports the account's Claude usage over the past hour and day
+// from CloudWatch, against the applied per-minute Service Quotas, so admins
+// can see the headroom before onboarding more users. It warns when the
+// busiest minute used more than usageWarnPercent of a quota or requests were
+// throttled.
+func usageResult(ctx context.Context, env Env, region string) CheckResult {
+	result := CheckResult{ID: "bedrock.usage", Name: "Bedrock Usage"}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return result
+	}
+	client := env.CloudWatch(cfg)
+
+	action := "cloudwatch:ListMetrics"
+	ids, err := usageModelIDs(ctx, client)
+	var usage []modelUsage
+	if err == nil {
+		action = "cloudwatch:GetMetricData"
+		usage, err = fetchModelUsage(ctx, client, ids, time.Now())
+	}
+	if err != nil {
+		result.Status = "warn"
+		result.Message = fmt.Sprintf("Cannot read Bedrock metrics from CloudWatch: %v", err)
+		result.Fix = "Add cloudwatch:ListMetrics and cloudwatch:GetMetricData permissions to your IAM role/user"
+		result.setAWSError(err, action)
+		result.attachDiagnostics(env, awsDiagnostics(err))
+		return result
+	}
+	if len(usage) == 0 {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("No Claude invocations recorded in %s in the past day", region)
+		return result
+	}
+
+	// Quotas are named after the model, so the pairing needs model names too.
+	quotaNote := ""
+	quotas, quotaErr := checkServiceQuotas(env, region)
+	names := map[string]string{}
+	if models, err := checkBedrockAccess(context.Background(), env, region); err == nil {
+		for _, m := range models {
+			names[aws.ToString(m.ModelId)] = aws.ToString(m.ModelName)
+		}
+	}
+	if quotaErr != nil {
+		quotaNote = fmt.Sprintf("; quotas unavailable, so no utilization is shown: %v", quotaErr)
+	}
+	pairQuotas(usage, quotas, names)
+
+	result.Status = "pass"
+	result.Metrics = map[string]float64{}
+	var parts, hot []string
+	for _, u := range usage {
+		parts = append(parts, fmt.Sprintf("%s: past hour %s; past day %s", u.ModelID, u.Hour.describe(), u.Day.describe()))
+		u.Hour.metrics(result.Metrics, u.ModelID, "1h")
+		u.Day.metrics(result.Metrics, u.ModelID, "24h")
+		if u.RequestQuota > 0 {
+			result.Metrics[u.ModelID+".request_quota_per_min"] = u.RequestQuota
+		}
+		if u.TokenQuota > 0 {
+			result.Metrics[u.ModelID+".token_quota_per_min"] = u.TokenQuota
+		}
+		switch peak := max(u.Day.RequestPercent, u.Day.TokenPercent); {
+		case peak > usageWarnPercent:
+			hot = append(hot, fmt.Sprintf("%s (peak minute at %.0f%% of quota)", u.ModelID, peak))
+		case u.Day.Throttles > 0:
+			hot = append(hot, fmt.Sprintf("%s (%s throttled requests)", u.ModelID, formatCount(u.Day.Throttles)))
+		}
+	}
+	result.Message = strings.Join(parts, "; ") + quotaNote
+	if len(hot) > 0 {
+		result.Status, result.Code = "warn", codeUsageNearQuota
+		result.Message = fmt.Sprintf("Near or at quota: %s. %s", strings.Join(hot, ", "), result.Message)
+		result.Fix = "Request a quota increase for these models in the Service Quotas console (Amazon Bedrock) before onboarding more users, or spread load with a cross-region inference profile"
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+func TestUsageWindowDescribe(t *testing.T) {
+	tests := []struct {
+		name string
+		w    usageWindow
+		want string
+	}{
+		{
+			name: "idle",
+			want: "0 invocations, 0 input / 0 output tokens, peak 0 requests/min and 0 tokens/min",
+		},
+		{
+			name: "throttled with quotas",
+			w:    usageWindow{Invocations: 120, Throttles: 3, InputTokens: 90000, OutputTokens: 8000, PeakRequests: 14, PeakTokens: 9000, RequestPercent: 28, TokenPercent: 4.5},
+			want: "120 invocations (3 throttled), 90000 input / 8000 output tokens, peak 14 requests/min (28% of quota) and 9000 tokens/min (4% of quota)",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.w.describe(); got != tt.want {
+			t.Errorf("%s: describe =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUsageWindowMetrics(t *testing.T) {
+	m := map[string]float64{}
+	usageWindow{Invocations: 5, PeakRequests: 2, RequestPercent: 40}.metrics(m, "anthropic.x", "1h")
+	if m["anthropic.x.invocations_1h"] != 5 || m["anthropic.x.peak_requests_per_min_1h"] != 2 || m["anthropic.x.request_quota_pct_1h"] != 40 {
+		t.Errorf("metrics = %v", m)
+	}
+	if _, ok := m["anthropic.x.token_quota_pct_1h"]; ok {
+		t.Errorf("metrics = %v, want no token utilization without a token quota", m)
+	}
+}
+
+func TestPairQuotas(t *testing.T) {
+	quotas := []quotaStatus{
+		{Model: "Claude 3.5 Haiku", Unit: "requests/min", Applied: 50},
+		{Model: "Claude 3.5 Haiku", Unit: "tokens/min", Applied: 100000},
+		{Model: "Claude Sonnet 4", Unit: "requests/min", Applied: 200},
+	}
+	names := map[string]string{
+		"anthropic.claude-3-5-haiku-20241022-v1:0": "claude 3.5 haiku",
+		"anthropic.claude-sonnet-4-20250514-v1:0":  "Claude Sonnet 4",
+	}
+	tests := []struct {
+		model            string
+		requests, tokens float64 // quotas
+		requestPct       float64 // of the day window
+	}{
+		{model: "anthropic.claude-3-5-haiku-20241022-v1:0", requests: 50, tokens: 100000, requestPct: 20},
+		{model: "us.anthropic.claude-sonnet-4-20250514-v1:0", requests: 200, requestPct: 5},
+		{model: "anthropic.claude-unknown"},
+	}
+	for _, tt := range tests {
+		usage := []modelUsage{{ModelID: tt.model, Day: usageWindow{PeakRequests: 10, PeakTokens: 5000}}}
+		pairQuotas(usage, quotas, names)
+		u := usage[0]
+		if u.RequestQuota != tt.requests || u.TokenQuota != tt.tokens || u.Day.RequestPercent != tt.requestPct {
+			t.Errorf("%s: quotas %v/%v, %v%%; want %v/%v, %v%%", tt.model, u.RequestQuota, u.TokenQuota, u.Day.RequestPercent, tt.requests, tt.tokens, tt.requestPct)
+		}
+		if tt.tokens > 0 && u.Day.TokenPercent != 5 {
+			t.Errorf("%s: token utilization = %v%%, want 5%%", tt.model, u.Day.TokenPercent)
+		}
+	}
+}
+
+// usagePoint is one minute of a metric, minutes before now.
+type usagePoint struct {
+	ago   int
+	value float64
+}
+
+// fakeUsage answers CloudWatch, Service Quotas and ListFoundationModels for
+// usageResult. series maps a GetMetricData query ID to its points; a nil
+// series denies CloudWatch.
+func fakeUsage(t *testing.T, series map[string][]usagePoint, quotasDenied bool) {
+	t.Helper()
+	end := time.Now().UTC().Truncate(time.Minute)
+	probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+		switch target := r.Header.Get("X-Amz-Target"); {
+		case r.URL.Path == "/foundation-models":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"modelSummaries":[{"modelId":"anthropic.claude-3-5-haiku-20241022-v1:0","modelName":"Claude 3.5 Haiku"}]}`))
+		case quotasDenied && strings.HasPrefix(target, "ServiceQuotas"):
+			probestest.AWSJSONError(w, http.StatusBadRequest, "AccessDeniedException", "not authorized")
+		case strings.HasSuffix(target, ".ListAWSDefaultServiceQuotas"):
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			w.Write([]byte(`{"Quotas":[
+{"QuotaCode":"L-R","QuotaName":"On-demand model inference requests per minute for Anthropic Claude 3.5 Haiku","Value":20},
+{"QuotaCode":"L-T","QuotaName":"On-demand model inference tokens per minute for Anthropic Claude 3.5 Haiku","Value":100000}]}`))
+		case strings.HasSuffix(target, ".GetServiceQuota"):
+			probestest.AWSJSONError(w, http.StatusBadRequest, "NoSuchResourceException", "default")
+		default:
+			r.ParseForm()
+			if series == nil {
+				queryError(w, "AccessDenied", "not authorized")
+				return
+			}
+			w.Header().Set("Content-Type", "text/xml")
+			switch action := r.Form.Get("Action"); action {
+			case "ListMetrics":
+				var members strings.Builder
+				for _, id := range []string{"anthropic.claude-3-5-haiku-20241022-v1:0", "amazon.titan-text-express-v1"} {
+					if len(series) == 0 && strings.HasPrefix(id, "anthropic.") {
+						continue
+					}
+					fmt.Fprintf(&members, `<member><Namespace>AWS/Bedrock</Namespace><MetricName>Invocations</MetricName><Dimensions><member><Name>ModelId</Name><Value>%s</Value></member></Dimensions></member>`, id)
+				}
+				fmt.Fprintf(w, `<ListMetricsResponse><ListMetricsResult><Metrics>%s</Metrics></ListMetricsResult></ListMetricsResponse>`, members.String())
+			case "GetMetricData":
+				var results strings.Builder
+				for id, points := range series {
+					var ts, vs strings.Builder
+					for _, p := range points {
+						fmt.Fprintf(&ts, "<member>%s</member>", end.Add(-time.Duration(p.ago)*time.Minute).Format(time.RFC3339))
+						fmt.Fprintf(&vs, "<member>%v</member>", p.value)
+					}
+					fmt.Fprintf(&results, `<member><Id>%s</Id><Timestamps>%s</Timestamps><Values>%s</Values><StatusCode>Complete</StatusCode></member>`, id, ts.String(), vs.String())
+				}
+				fmt.Fprintf(w, `<GetMetricDataResponse><GetMetricDataResult><MetricDataResults>%s</MetricDataResults></GetMetricDataResult></GetMetricDataResponse>`, results.String())
+			default:
+				t.Errorf("unexpected %s %s %s", r.Method, r.URL, action)
+			}
+		}
+	})
+}
+
+func TestUsageResult(t *testing.T) {
+	const haiku = "anthropic.claude-3-5-haiku-20241022-v1:0"
+	tests := []struct {
+		name         string
+		series       map[string][]usagePoint // query ID m0_<metric index> -> points
+		quotasDenied bool
+		status       string
+		code         string
+		contains     []string
+		metrics      map[string]float64
+	}{
+		{
+			name:     "CloudWatch denied",
+			status:   "warn",
+			code:     codeIAMAccessDenied,
+			contains: []string{"Cannot read Bedrock metrics from CloudWatch", "cloudwatch:ListMetrics"},
+		},
+		{
+			name:     "no Claude metrics",
+			series:   map[string][]usagePoint{},
+			status:   "pass",
+			contains: []string{"No Claude invocations recorded in us-east-1 in the past day"},
+		},
+		{
+			name: "headroom",
+			series: map[string][]usagePoint{
+				"m0_0": {{5, 4}, {180, 2}},
+				"m0_2": {{5, 1000}, {180, 500}},
+				"m0_3": {{5, 100}},
+			},
+			status:   "pass",
+			contains: []string{haiku + ": past hour 4 invocations, 1000 input / 100 output tokens, peak 4 requests/min (20% of quota)", "past day 6 invocations"},
+			metrics:  map[string]float64{haiku + ".invocations_1h": 4, haiku + ".invocations_24h": 6, haiku + ".request_quota_per_min": 20},
+		},
+		{
+			name:     "busiest minute near the quota",
+			series:   map[string][]usagePoint{"m0_0": {{300, 18}}},
+			status:   "warn",
+			code:     codeUsageNearQuota,
+			contains: []string{"Near or at quota: " + haiku + " (peak minute at 90% of quota)", "past hour 0 invocations"},
+		},
+		{
+			name:     "throttled",
+			series:   map[string][]usagePoint{"m0_0": {{10, 2}}, "m0_1": {{10, 3}}},
+			status:   "warn",
+			code:     codeUsageNearQuota,
+			contains: []string{haiku + " (3 throttled requests)"},
+		},
+		{
+			name:         "quotas unavailable",
+			series:       map[string][]usagePoint{"m0_0": {{10, 2}}},
+			quotasDenied: true,
+			status:       "pass",
+			contains:     []string{"peak 2 requests/min and", "quotas unavailable, so no utilization is shown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeUsage(t, tt.series, tt.quotasDenied)
+			r := usageResult(context.Background(), NewEnv(RunSettings{}), "us-east-1")
+			if r.ID != "bedrock.usage" || r.Status != tt.status || r.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", r.ID, r.Status, r.Code, r.Message, tt.status, tt.code)
+			}
+			text := r.Message + " " + r.Fix
+			for _, want := range tt.contains {
+				if !strings.Contains(text, want) {
+					t.Errorf("%q does not contain %q", text, want)
+				}
+			}
+			for key, want := range tt.metrics {
+				if got := r.Metrics[key]; got != want {
+					t.Errorf("metric %s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package probes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+)
+
+// Diagnostics is the request metadata AWS Support asks for when escalating.
+type Diagnostics struct {
+	RequestID  string            `json:"request_id,omitempty"`
+	HTTPStatus int               `json:"http_status,omitempty"`
+	ErrorCode  string            `json:"error_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+
+	// Served certificate chain, for the certificate checks
+	Certificate *CertificateDetails `json:"certificate,omitempty"`
+}
+
+func (d *Diagnostics) String() string {
+	var parts []string
+	if d.RequestID != "" {
+		parts = append(parts, "request ID "+d.RequestID)
+	}
+	if d.HTTPStatus != 0 {
+		parts = append(parts, fmt.Sprintf("HTTP %d", d.HTTPStatus))
+	}
+	if d.ErrorCode != "" {
+		parts = append(parts, "error code "+d.ErrorCode)
+	}
+	if server := d.Headers["Server"]; server != "" {
+		parts = append(parts, "server "+server)
+	}
+	if d.Certificate != nil {
+		parts = append(parts, d.Certificate.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// awsDiagnostics extracts request metadata from an SDK error, or returns nil
+// if the error never reached AWS.
+func awsDiagnostics(err error) *Diagnostics {
+	d := &Diagnostics{}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		d.RequestID = respErr.ServiceRequestID()
+		d.HTTPStatus = respErr.HTTPStatusCode()
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		d.ErrorCode = apiErr.ErrorCode()
+	}
+	if d.RequestID == "" && d.HTTPStatus == 0 && d.ErrorCode == "" {
+		return nil
+	}
+	return d
+}
+
+// httpDiagnostics records the metadata of a raw HTTPS response.
+func httpDiagnostics(resp *http.Response) *Diagnostics {
+	requestID := resp.Header.Get("x-amzn-RequestId")
+	if requestID == "" {
+		requestID = resp.Header.Get("x-amz-request-id")
+	}
+	return &Diagnostics{
+		RequestID:  requestID,
+		HTTPStatus: resp.StatusCode,
+		Headers:    redactHeaders(resp.Header),
+	}
+}
+
+// attachDiagnostics adds d to the result (message and JSON) in verbose mode
+// (env.Verbose).
+func (r *CheckResult) attachDiagnostics(env Env, d *Diagnostics) {
+	if !env.Verbose || d == nil {
+		return
+	}
+	r.Diagnostics = d
+	if s := d.String(); s != "" {
+		r.Message = RedactSecrets(fmt.Sprintf("%s [%s]", r.Message, s))
+	}
+}
+
+// Headers whose values are credentials or session state.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Amz-Security-Token": true,
+	"X-Amz-Credential":     true,
+	"X-Api-Key":            true,
+}
+
+const Redacted = "[REDACTED]"
+
+// redactHeaders flattens h for output with credential values replaced.
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		canonical := http.CanonicalHeaderKey(k)
+		if sensitiveHeaders[canonical] {
+			out[canonical] = Redacted
+			continue
+		}
+		out[canonical] = RedactSecrets(strings.Join(h[k], ", "))
+	}
+	return out
+}
+
+// secretPatterns match credentials in free text. Group 1, when present, is a
+// prefix kept in the output; group 2 a suffix.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+	regexp.MustCompile(`\b(?:bedrock-api-key-|ABSK)[A-Za-z0-9+/_-]{16,}`),
+	regexp.MustCompile(`(?i)((?:Signature|X-Amz-Signature|X-Amz-Security-Token|X-Amz-Credential)=)[^&\s,]+`),
+	regexp.MustCompile(`(?i)((?:Authorization|Proxy-Authorization|X-Amz-Security-Token|Cookie):)[^\r\n]*`),
+	regexp.MustCompile(`(?i)(\bBearer\s+)[A-Za-z0-9._~+/-]+=*`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), // JWTs, e.g. OIDC ID tokens
+	regexp.MustCompile(`(?i)((?:aws_access_key_id|aws_secret_access_key|aws_session_token|aws_security_token)\s*=\s*)\S+`),
+	regexp.MustCompile(`(?i)("(?:AccessKeyId|SecretAccessKey|SessionToken|access_?token|id_?token|refresh_?token)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.-]*://[^:/@\s]+:)[^@/\s]+(@)`), // password in proxy URLs
+}
+
+// RedactSecrets masks access key IDs, API keys, signatures, auth headers,
+// bearer and OIDC tokens, and URL passwords in s, including the header dumps
+// of SDK request logging.
+func RedactSecrets(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			sub := re.FindStringSubmatch(m)
+			switch len(sub) {
+			case 3:
+				return sub[1] + Redacted + sub[2]
+			case 2:
+				return sub[1] + Redacted
+			}
+			return Redacted
+		})
+	}
+	return s
+}
@@ -0,0 +1,255 @@
+package probes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// stsAudience is the audience STS requires of web identity tokens; the EKS
+// pod identity webhook projects tokens with it.
+const stsAudience = "sts.amazonaws.com"
+
+// webIdentityClaims are the JWT claims STS checks. The audience is a string
+// or a list of strings.
+type webIdentityClaims struct {
+	Issuer   string          `json:"iss"`
+	Subject  string          `json:"sub"`
+	Audience json.RawMessage `json:"aud"`
+	Expiry   int64           `json:"exp"`
+}
+
+func (c webIdentityClaims) audiences() []string {
+	var list []string
+	if json.Unmarshal(c.Audience, &list) == nil {
+		return list
+	}
+	var one string
+	if json.Unmarshal(c.Audience, &one) == nil && one != "" {
+		return []string{one}
+	}
+	return nil
+}
+
+// decodeWebIdentityToken reads the claims of a JWT without verifying its
+// signature; STS does that.
+func decodeWebIdentityToken(token string) (webIdentityClaims, error) {
+	var claims webIdentityClaims
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("not a JWT (%d dot-separated parts, want 3)", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims, fmt.Errorf("payload is not base64url: %w", err)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("payload is not JSON: %w", err)
+	}
+	return claims, nil
+}
+
+// webIdentityFix is the hint for fixing the web identity wiring, tailored to
+// Kubernetes where it comes from the service account annotation.
+func webIdentityFix(env Env) string {
+	if env.Host.Container == "kubernetes" {
+		return "Annotate the pod's service account with eks.amazonaws.com/role-arn: <role ARN> and restart the pod so the EKS pod identity webhook injects AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE"
+	}
+	return "Set both AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE"
+}
+
+// workloadIdentityResults validates the credentials of a cloud workload: the
+// web identity token and its exchange with STS when AWS_WEB_IDENTITY_TOKEN_FILE
+// or AWS_ROLE_ARN is set (EKS IRSA), or else the instance profile on EC2. It
+// returns nothing elsewhere.
+func workloadIdentityResults(env Env, region string) []CheckResult {
+	tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	switch {
+	case tokenFile != "" || roleARN != "":
+		token, result := webIdentityTokenResult(env, tokenFile, roleARN)
+		if result.Status == "fail" {
+			return []CheckResult{result}
+		}
+		return []CheckResult{result, webIdentityExchangeResult(env, region, roleARN, token)}
+	case env.Host.Cloud == "EC2" && !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true"):
+		return []CheckResult{instanceRoleResult(env, region)}
+	}
+	return nil
+}
+
+// webIdentityTokenResult reads and decodes the projected token and checks
+// the claims STS will reject: expiry and audience.
+func webIdentityTokenResult(env Env, tokenFile, roleARN string) (string, CheckResult) {
+	result := CheckResult{ID: "auth.web_identity_token", Name: "Web Identity Token"}
+
+	switch {
+	case tokenFile == "":
+		result.Status, result.Code = "fail", codeWebIdentityToken
+		result.Message = fmt.Sprintf("AWS_ROLE_ARN is set to %s but AWS_WEB_IDENTITY_TOKEN_FILE is not, so the SDK ignores it", roleARN)
+		result.Fix = webIdentityFix(env)
+		return "", result
+	case roleARN == "":
+		result.Status, result.Code = "fail", codeWebIdentityToken
+		result.Message = fmt.Sprintf("AWS_WEB_IDENTITY_TOKEN_FILE is set to %s but AWS_ROLE_ARN is not, so the SDK cannot assume a role with it", tokenFile)
+		result.Fix = webIdentityFix(env)
+		return "", result
+	}
+
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		result.Status, result.Code = "fail", codeWebIdentityToken
+		result.Message = fmt.Sprintf("Cannot read the web identity token: %v", err)
+		result.Fix = "The token is a projected service account volume; check that the pod mounts it and that the container user can read it (fsGroup or runAsUser)"
+		return "", result
+	}
+	token := strings.TrimSpace(string(data))
+	claims, err := decodeWebIdentityToken(token)
+	if err != nil {
+		result.Status, result.Code = "fail", codeWebIdentityToken
+		result.Message = fmt.Sprintf("%s does not hold a valid token: %v", tokenFile, err)
+		result.Fix = "Point AWS_WEB_IDENTITY_TOKEN_FILE at the projected service account token"
+		return "", result
+	}
+
+	audiences := claims.audiences()
+	expiry := time.Unix(claims.Expiry, 0)
+	switch {
+	case claims.Expiry != 0 && time.Now().After(expiry):
+		result.Status, result.Code = "fail", codeWebIdentityToken
+		result.Message = fmt.Sprintf("The token in %s expired at %s", tokenFile, expiry.Local().Format(time.RFC1123))
+		result.Fix = "The kubelet refreshes projected tokens while the pod runs; a stale token means the file was copied. Read it from the projected volume instead"
+	case !slices.Contains(audiences, stsAudience):
+		result.Status, result.Code = "fail", codeWebIdentityToken
+		result.Message = fmt.Sprintf("The token in %s is for audience %s, not %s", tokenFile, strings.Join(audiences, ", "), stsAudience)
+		result.Fix = fmt.Sprintf("Project the service account token with audience %s (the EKS pod identity webhook does this by default)", stsAudience)
+	default:
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("Token for %s from %s, valid until %s", claims.Subject, claims.Issuer, expiry.Local().Format(time.RFC1123))
+	}
+	return token, result
+}
+
+// webIdentityExchangeResult exchanges the token with STS, as the SDK will, to
+// confirm the OIDC provider and the role's trust policy accept it. The call
+// is unsigned and returns short-lived credentials that are discarded.
+func webIdentityExchangeResult(env Env, region, roleARN, token string) CheckResult {
+	result := CheckResult{ID: "auth.web_identity_exchange", Name: "Web Identity Exchange"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return result
+	}
+	claims, _ := decodeWebIdentityToken(token)
+
+	out, err := env.STS(cfg).AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+		RoleArn:          aws.String(roleARN),
+		RoleSessionName:  aws.String("bcce-doctor"),
+		WebIdentityToken: aws.String(token),
+	}, func(o *sts.Options) {
+		o.Credentials = aws.AnonymousCredentials{}
+	})
+	if err == nil {
+		result.Status = "pass"
+		result.Message = fmt.Sprintf("STS accepted the token for %s", roleARN)
+		if out.AssumedRoleUser != nil {
+			result.Message += " and returned credentials for " + aws.ToString(out.AssumedRoleUser.Arn)
+		}
+		return result
+	}
+
+	result.Status = "fail"
+	result.Message = fmt.Sprintf("sts:AssumeRoleWithWebIdentity for %s failed: %v", roleARN, err)
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		result.Code = classifyNetError(err)
+		result.Fix = "See DNS - STS and HTTPS Connectivity"
+		return result
+	}
+	result.Code = codeWebIdentityExchange
+	result.attachDiagnostics(env, awsDiagnostics(err))
+	switch apiErr.ErrorCode() {
+	case "InvalidIdentityToken":
+		result.Fix = fmt.Sprintf("The account has no IAM OIDC identity provider for the issuer %s. Create it (eksctl utils associate-iam-oidc-provider --cluster <cluster> --approve)", claims.Issuer)
+	case "AccessDenied":
+		result.Fix = fmt.Sprintf("The trust policy of %s does not allow this token. It needs a Federated principal for the OIDC provider of %s with conditions %s:sub = %s and %s:aud = %s",
+			roleARN, claims.Issuer, strings.TrimPrefix(claims.Issuer, "https://"), claims.Subject, strings.TrimPrefix(claims.Issuer, "https://"), stsAudience)
+	case "ExpiredTokenException":
+		result.Fix = "The token expired; read it from the projected volume, which the kubelet keeps fresh"
+	case "IDPCommunicationError", "IDPRejectedClaim":
+		result.Fix = fmt.Sprintf("STS could not validate the token with %s; check that the cluster's OIDC issuer is publicly reachable", claims.Issuer)
+	default:
+		result.Fix = "Check the role's trust policy and the account's IAM OIDC identity providers"
+	}
+	return result
+}
+
+// instanceRoleResult asks the instance metadata service which instance
+// profile is attached, as the SDK's last credential source will.
+func instanceRoleResult(env Env, region string) CheckResult {
+	result := CheckResult{ID: "auth.instance_role", Name: "EC2 Instance Role"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg, err := env.loadAWSConfig(ctx, region)
+	if err != nil {
+		result.Status, result.Code = "warn", codeAWSConfig
+		result.Message = fmt.Sprintf("failed to load AWS config: %v", err)
+		return result
+	}
+	client := env.IMDS(cfg)
+
+	start := time.Now()
+	info, err := client.GetIAMInfo(ctx, &imds.GetIAMInfoInput{})
+	logProbe("imds", "iam/info", start, err)
+	var respErr *smithyhttp.ResponseError
+	switch {
+	case errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound:
+		result.Status, result.Code = "fail", codeInstanceRoleMissing
+		result.Message = "This EC2 instance has no instance profile, so the SDK finds no credentials on it"
+		result.Fix = "Attach an instance profile whose role allows the Bedrock actions (EC2 console: Actions > Security > Modify IAM role), or configure another credential source"
+		return result
+	case err != nil:
+		result.Status, result.Code = "fail", codeCredentialsMissing
+		result.Message = fmt.Sprintf("Cannot reach the instance metadata service: %v", err)
+		result.Fix = "Check that instance metadata is enabled for this instance"
+		if env.Host.Container != "" {
+			result.Fix = "Containers sit one network hop behind the instance, which IMDSv2 refuses by default: raise the hop limit (aws ec2 modify-instance-metadata-options --instance-id <id> --http-put-response-hop-limit 2)"
+		}
+		return result
+	}
+
+	roles, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: "iam/security-credentials/"})
+	role := ""
+	if err == nil {
+		data, _ := io.ReadAll(roles.Content)
+		roles.Content.Close()
+		role, _, _ = strings.Cut(strings.TrimSpace(string(data)), "\n")
+	}
+	if role == "" {
+		result.Status, result.Code = "fail", codeInstanceRoleMissing
+		result.Message = fmt.Sprintf("Instance profile %s is attached but has no role", info.InstanceProfileArn)
+		result.Fix = "Add a role to the instance profile (aws iam add-role-to-instance-profile)"
+		return result
+	}
+	result.Status = "pass"
+	result.Message = fmt.Sprintf("Instance profile %s provides role %s", info.InstanceProfileArn, role)
+	return result
+}
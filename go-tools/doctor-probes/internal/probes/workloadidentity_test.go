@@ -0,0 +1,260 @@
+package probes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// webIdentityJWT returns an unsigned JWT with the given claims payload.
+func webIdentityJWT(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(payload)) + ".c2ln"
+}
+
+func validClaims(aud string, exp time.Time) string {
+	return fmt.Sprintf(`{"iss":"https://oidc.eks.us-east-1.amazonaws.com/id/ABC","sub":"system:serviceaccount:dev:claude","aud":%s,"exp":%d}`, aud, exp.Unix())
+}
+
+func TestDecodeWebIdentityToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		token     string
+		audiences []string
+		err       string
+	}{
+		{name: "audience string", token: webIdentityJWT(validClaims(`"sts.amazonaws.com"`, exp)), audiences: []string{"sts.amazonaws.com"}},
+		{name: "audience list", token: webIdentityJWT(validClaims(`["a","sts.amazonaws.com"]`, exp)) + "\n", audiences: []string{"a", "sts.amazonaws.com"}},
+		{name: "not a JWT", token: "abc.def", err: "not a JWT (2 dot-separated parts, want 3)"},
+		{name: "bad base64", token: "a.!!!.c", err: "payload is not base64url"},
+		{name: "bad JSON", token: "a." + base64.RawURLEncoding.EncodeToString([]byte("nope")) + ".c", err: "payload is not JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := decodeWebIdentityToken(tt.token)
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Errorf("err = %v, want prefix %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(claims.audiences(), tt.audiences) || claims.Subject != "system:serviceaccount:dev:claude" {
+				t.Errorf("claims = %+v (audiences %v), %v; want audiences %v", claims, claims.audiences(), err, tt.audiences)
+			}
+		})
+	}
+}
+
+func TestWebIdentityTokenResult(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	const role = "arn:aws:iam::123456789012:role/claude"
+	tests := []struct {
+		name      string
+		tokenFile string
+		roleARN   string
+		status    string
+		contains  string
+	}{
+		{name: "role without token", roleARN: role, status: "fail", contains: "AWS_ROLE_ARN is set to " + role + " but AWS_WEB_IDENTITY_TOKEN_FILE is not"},
+		{name: "token without role", tokenFile: "/var/run/token", status: "fail", contains: "AWS_WEB_IDENTITY_TOKEN_FILE is set to /var/run/token but AWS_ROLE_ARN is not"},
+		{name: "unreadable", tokenFile: filepath.Join(dir, "missing"), roleARN: role, status: "fail", contains: "Cannot read the web identity token"},
+		{name: "not a token", tokenFile: write("garbage", "hello"), roleARN: role, status: "fail", contains: "does not hold a valid token: not a JWT"},
+		{name: "expired", tokenFile: write("expired", webIdentityJWT(validClaims(`"sts.amazonaws.com"`, time.Now().Add(-time.Hour)))), roleARN: role, status: "fail", contains: "expired at"},
+		{name: "wrong audience", tokenFile: write("aud", webIdentityJWT(validClaims(`["vault"]`, time.Now().Add(time.Hour)))), roleARN: role, status: "fail", contains: "is for audience vault, not sts.amazonaws.com"},
+		{name: "valid", tokenFile: write("valid", webIdentityJWT(validClaims(`"sts.amazonaws.com"`, time.Now().Add(time.Hour)))), roleARN: role, status: "pass", contains: "Token for system:serviceaccount:dev:claude from https://oidc.eks.us-east-1.amazonaws.com/id/ABC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			token, got := webIdentityTokenResult(env, tt.tokenFile, tt.roleARN)
+			if got.ID != "auth.web_identity_token" || got.Status != tt.status || (got.Status == "fail") != (got.Code == codeWebIdentityToken) {
+				t.Errorf("got %s %s %s, want %s", got.ID, got.Status, got.Code, tt.status)
+			}
+			if !strings.Contains(got.Message, tt.contains) {
+				t.Errorf("message %q does not contain %q", got.Message, tt.contains)
+			}
+			if (token != "") != (tt.status == "pass" || tt.name == "expired" || tt.name == "wrong audience") {
+				t.Errorf("token returned = %v for %s", token != "", tt.name)
+			}
+		})
+	}
+}
+
+func TestWebIdentityFix(t *testing.T) {
+	env := NewEnv(RunSettings{Host: HostEnvironment{Container: "kubernetes"}})
+	if got := webIdentityFix(env); !strings.Contains(got, "eks.amazonaws.com/role-arn") {
+		t.Errorf("kubernetes fix = %q, want the service account annotation", got)
+	}
+	env.Host = HostEnvironment{}
+	if got := webIdentityFix(env); got != "Set both AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE" {
+		t.Errorf("fix = %q", got)
+	}
+}
+
+func TestWebIdentityExchangeResult(t *testing.T) {
+	probestest.AWS(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected AWS call %s %s", r.Method, r.URL)
+	})
+	const role = "arn:aws:iam::123456789012:role/claude"
+	token := webIdentityJWT(validClaims(`"sts.amazonaws.com"`, time.Now().Add(time.Hour)))
+	apiErr := func(code string) error { return &smithy.GenericAPIError{Code: code, Message: "rejected"} }
+	tests := []struct {
+		name   string
+		arn    string // of the credentials STS returns
+		err    error
+		status string
+		code   string
+		want   string // in the message or fix
+	}{
+		{name: "accepted", arn: "arn:aws:sts::123456789012:assumed-role/claude/bcce-doctor", status: "pass", want: "STS accepted the token for " + role + " and returned credentials for arn:aws:sts::123456789012:assumed-role/claude/bcce-doctor"},
+		{name: "no OIDC provider", err: apiErr("InvalidIdentityToken"), status: "fail", code: codeWebIdentityExchange, want: "no IAM OIDC identity provider for the issuer https://oidc.eks.us-east-1.amazonaws.com/id/ABC"},
+		{name: "trust policy", err: apiErr("AccessDenied"), status: "fail", code: codeWebIdentityExchange, want: "oidc.eks.us-east-1.amazonaws.com/id/ABC:sub = system:serviceaccount:dev:claude"},
+		{name: "expired", err: apiErr("ExpiredTokenException"), status: "fail", code: codeWebIdentityExchange, want: "The token expired"},
+		{name: "IdP unreachable", err: apiErr("IDPCommunicationError"), status: "fail", code: codeWebIdentityExchange, want: "publicly reachable"},
+		{name: "other API error", err: apiErr("RegionDisabledException"), status: "fail", code: codeWebIdentityExchange, want: "Check the role's trust policy"},
+		{name: "network", err: errors.New("dial tcp: connection refused"), status: "fail", code: codeConnectFailure, want: "See DNS - STS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input *sts.AssumeRoleWithWebIdentityInput
+			fake := &probestest.STS{Err: tt.err, WebIdentityFunc: func(in *sts.AssumeRoleWithWebIdentityInput) (*sts.AssumeRoleWithWebIdentityOutput, error) {
+				input = in
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &sts.AssumeRoleWithWebIdentityOutput{AssumedRoleUser: &ststypes.AssumedRoleUser{Arn: aws.String(tt.arn)}}, nil
+			}}
+			env := NewEnv(RunSettings{})
+			env.STS = func(aws.Config) STSClient { return fake }
+
+			got := webIdentityExchangeResult(env, "us-east-1", role, token)
+			if got.ID != "auth.web_identity_exchange" || got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", got.ID, got.Status, got.Code, got.Message, tt.status, tt.code)
+			}
+			if text := got.Message + " " + got.Fix; !strings.Contains(text, tt.want) {
+				t.Errorf("%q does not contain %q", text, tt.want)
+			}
+			if in := input; in == nil || aws.ToString(in.RoleArn) != role || aws.ToString(in.WebIdentityToken) != token || aws.ToString(in.RoleSessionName) != "bcce-doctor" {
+				t.Errorf("AssumeRoleWithWebIdentity input = %+v", in)
+			}
+		})
+	}
+}
+
+// imdsServer fakes the instance metadata service. An empty profile answers
+// iam/info with 404.
+func imdsServer(t *testing.T, profile, roles string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+			fmt.Fprint(w, "token")
+		case "/latest/meta-data/iam/info":
+			if profile == "" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"Code":"Success","LastUpdated":"2026-10-16T09:00:00Z","InstanceProfileArn":%q,"InstanceProfileId":"AIPA"}`, profile)
+		case "/latest/meta-data/iam/security-credentials/":
+			fmt.Fprint(w, roles)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", srv.URL)
+}
+
+func TestInstanceRoleResult(t *testing.T) {
+	const profile = "arn:aws:iam::123456789012:instance-profile/claude"
+	tests := []struct {
+		name      string
+		profile   string
+		roles     string
+		endpoint  string
+		container string
+		status    string
+		code      string
+		want      string
+	}{
+		{name: "role attached", profile: profile, roles: "claude-role\n", status: "pass", want: "Instance profile " + profile + " provides role claude-role"},
+		{name: "no instance profile", status: "fail", code: codeInstanceRoleMissing, want: "has no instance profile"},
+		{name: "profile without role", profile: profile, status: "fail", code: codeInstanceRoleMissing, want: "aws iam add-role-to-instance-profile"},
+		{name: "unreachable from a container", endpoint: "http://127.0.0.1:1", container: "docker", status: "fail", code: codeCredentialsMissing, want: "--http-put-response-hop-limit 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(RunSettings{})
+			probestest.AWS(t, nil)
+			t.Setenv("AWS_EC2_METADATA_DISABLED", "false")
+			imdsServer(t, tt.profile, tt.roles)
+			if tt.endpoint != "" {
+				t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", tt.endpoint)
+			}
+			env.Host.Container = tt.container
+
+			got := instanceRoleResult(env, "us-east-1")
+			if got.ID != "auth.instance_role" || got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got %s %s %s (%s), want %s %s", got.ID, got.Status, got.Code, got.Message, tt.status, tt.code)
+			}
+			if text := got.Message + " " + got.Fix; !strings.Contains(text, tt.want) {
+				t.Errorf("%q does not contain %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadIdentityResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		cloud   string
+		wantIDs []string
+	}{
+		{name: "neither", wantIDs: nil},
+		{name: "EC2 with IMDS disabled", cloud: "EC2", env: map[string]string{"AWS_EC2_METADATA_DISABLED": "TRUE"}, wantIDs: nil},
+		{name: "broken web identity stops early", env: map[string]string{"AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/claude"}, wantIDs: []string{"auth.web_identity_token"}},
+		{name: "web identity wins over EC2", cloud: "EC2", env: map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": "/nonexistent"}, wantIDs: []string{"auth.web_identity_token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probestest.Unsetenv(t, "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_EC2_METADATA_DISABLED")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			env := NewEnv(RunSettings{})
+			env.Host.Cloud = tt.cloud
+			var ids []string
+			for _, r := range workloadIdentityResults(env, "us-east-1") {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("result IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -1,226 +1,423 @@
 package main
 
 import (
-	"context"
+	"bytes"
+	"flag"
 	"fmt"
-	"net"
-	"net/http"
+	"io"
+	"net/url"
 	"os"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
 )
 
-type CheckResult struct {
-	Name    string `json:"name"`
-	Status  string `json:"status"` // pass, fail, warn
-	Message string `json:"message"`
-	Fix     string `json:"fix,omitempty"`
+type options struct {
+	region             string // --region
+	format             string
+	output             string
+	force              bool
+	logFile            string
+	fixScript          string
+	quiet              bool
+	watch              time.Duration
+	serve              string
+	serveInterval      time.Duration
+	exitPolicy         exitPolicy
+	ttfbThreshold      time.Duration
+	coldPenalty        time.Duration
+	proxyOverhead      time.Duration
+	maxConcurrency     int
+	maxHostRate        float64
+	maxRequests        int
+	noDirectProbe      bool
+	dualStack          bool
+	fips               bool
+	dnsResolvers       []string
+	doh                *url.URL
+	noExternalDNS      bool
+	noExternalProbes   bool
+	skip               map[string]bool
+	governance         bool
+	privateLink        bool
+	simulateIAM        bool
+	usage              bool
+	payloadProbe       bool
+	ipRanges           bool
+	ipRangesSample     int
+	idleProbe          bool
+	idleDuration       time.Duration
+	streamProbe        bool
+	streamURL          string
+	concurrencyProbe   bool
+	concurrencyCount   int
+	concurrencyInvoke  bool
+	payloadSizes       []int
+	throttleProbe      bool
+	throttleProbeCount int
+	countTokens        bool
+	interactive        bool
+	benchmark          bool
+	benchmarkRuns      int
+	acceptCost         bool
+	authMatrix         bool
+	endpointURL        string
+	profiles           []string
+	bundle             string
+	failFast           bool
+	diff               bool
+	history            int
+	config             *probes.DoctorConfig
+	severity           map[string]probes.SeverityRule
+	// settings are the flags every check shares, and env reaches the
+	// network and AWS with them; main builds env once settings are final.
+	settings probes.RunSettings
+	env      probes.Env
 }
 
-func checkDNS(host string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	
-	_, err := net.DefaultResolver.LookupHost(ctx, host)
-	return err
-}
+func parseFlags() options {
+	var opts options
+	flag.StringVar(&opts.region, "region", "", "Region to check (default: AWS_REGION, AWS_DEFAULT_REGION, then the profile's region)")
+	flag.StringVar(&opts.format, "format", "console", "Output format: console, json, junit, or markdown")
+	flag.StringVar(&opts.output, "output", "", "Write the report in --format to this file (- for stdout); the console still gets the human summary")
+	flag.BoolVar(&opts.force, "force", false, "Overwrite an existing --output file")
+	printSchema := flag.Bool("schema", false, "Print the JSON Schema of the --format json report and exit")
+	printPluginSchema := flag.Bool("plugin-schema", false, "Print the JSON Schemas of the plugin input and output and exit")
+	flag.StringVar(&opts.fixScript, "emit-fix-script", "", "Write the fixes for failing checks to this file as a reviewable shell script (PowerShell on Windows)")
+	profiles := flag.String("profiles", "", "Run the credential and Bedrock checks for each of these comma-separated AWS profiles (or all) and compare them")
+	var severitySpecs []string
+	flag.Func("severity", "Report a check's non-passing results as fail, warn, or pass, or suppress them: id=status, repeatable or comma-separated (e.g. otel.metrics_endpoint=fail)", func(s string) error {
+		severitySpecs = append(severitySpecs, s)
+		return nil
+	})
+	configPath := flag.String("config", "", "Organization doctor config with extra checks and severity overrides (default ~/.bcce/doctor.yaml if present)")
+	skip := flag.String("skip", "", "Comma-separated check groups to skip: local, revocation")
+	flag.BoolVar(&opts.failFast, "fail-fast", false, "Skip checks whose prerequisites failed (e.g. Bedrock API checks when DNS fails) and report the root failure")
+	flag.StringVar(&opts.bundle, "bundle", "", "Write a redacted support bundle (report, probe log, environment, config excerpts) to this .tar.gz file; implies --verbose")
+	flag.StringVar(&opts.logFile, "log-file", os.Getenv("BCCE_DOCTOR_LOG"), "Append structured JSON debug logs of every probe attempt to this file (default from BCCE_DOCTOR_LOG)")
+	flag.BoolVar(&opts.settings.Verbose, "verbose", false, "Include AWS request IDs, HTTP status, and error codes in check results")
+	flag.BoolVar(&opts.diff, "diff", false, "Print only the checks whose status changed since the previous run for this region and profile")
+	flag.IntVar(&opts.history, "history", 0, "List the last N runs for this region and profile and exit")
+	flag.BoolVar(&opts.quiet, "quiet", false, "Hide passing checks in console output (JSON output stays complete)")
+	ascii := flag.Bool("ascii", false, "Mark check status with [PASS]/[WARN]/[FAIL] instead of emoji (default when stdout is not a terminal)")
+	noColor := flag.Bool("no-color", false, "Disable colored output (also NO_COLOR)")
+	flag.DurationVar(&opts.watch, "watch", 0, "Re-run the checks on this interval until interrupted (e.g. 30s)")
+	flag.StringVar(&opts.serve, "serve", "", "Serve Prometheus metrics on this address (e.g. :9101) and re-run checks periodically")
+	flag.DurationVar(&opts.serveInterval, "serve-interval", 60*time.Second, "Check interval in --serve mode")
+	strict := flag.Bool("strict", false, "Treat warnings as failures (exit 1)")
+	warnExitZero := flag.Bool("warn-exit-zero", false, "Exit 0 when there are only warnings")
+	flag.DurationVar(&opts.ttfbThreshold, "ttfb-threshold", 500*time.Millisecond, "Warn when time-to-first-byte to the Bedrock runtime exceeds this")
+	flag.DurationVar(&opts.coldPenalty, "cold-penalty-threshold", 500*time.Millisecond, "Warn when a new connection to the Bedrock runtime costs this much more than a reused one")
+	flag.DurationVar(&opts.proxyOverhead, "proxy-overhead-threshold", 200*time.Millisecond, "Warn when the proxy adds this much time-to-first-byte over a direct path to the Bedrock runtime")
+	flag.IntVar(&opts.maxConcurrency, "max-concurrency", probes.DefaultMaxConcurrency, "Most network requests in flight at once, across all probes")
+	flag.Float64Var(&opts.maxHostRate, "max-host-rate", probes.DefaultMaxHostRate, "Most requests per second to any one host (0 for no limit)")
+	flag.IntVar(&opts.maxRequests, "max-requests", 0, "Request budget per run; once used, remaining optional probes are skipped (0 for no limit)")
+	flag.BoolVar(&opts.noDirectProbe, "no-direct-probe", false, "Never connect around the configured proxy (where bypassing it is a policy violation)")
+	dnsResolvers := flag.String("dns-resolvers", probes.DefaultDNSResolvers, "Comma-separated external resolvers to compare DNS answers against")
+	flag.StringVar(&opts.settings.DNSServer, "resolver", "", "Resolve the endpoints with this DNS server (host[:port]) instead of the system resolver, and compare the two")
+	doh := flag.String("doh", "", "Also resolve the endpoints with this DNS-over-HTTPS server (e.g. https://cloudflare-dns.com/dns-query) and compare the answers")
+	flag.BoolVar(&opts.noExternalDNS, "no-external-dns", false, "Skip external resolver comparison (air-gapped environments)")
+	flag.BoolVar(&opts.noExternalProbes, "no-external-probes", false, "Only probe the configured region: no external resolvers and no search of other regions (restricted environments)")
+	flag.BoolVar(&opts.fips, "fips", os.Getenv("AWS_USE_FIPS_ENDPOINT") == "true", "Use FIPS endpoints for all checks (default from AWS_USE_FIPS_ENDPOINT)")
+	flag.StringVar(&opts.endpointURL, "endpoint-url", os.Getenv("AWS_BEDROCK_ENDPOINT_URL"), "Probe this Bedrock endpoint (e.g. a PrivateLink DNS name) instead of the public ones (default from AWS_BEDROCK_ENDPOINT_URL)")
+	flag.BoolVar(&opts.dualStack, "dual-stack", false, "Also test the dual-stack (IPv4+IPv6) endpoint hostnames")
+	flag.BoolVar(&opts.privateLink, "privatelink", false, "Expect Bedrock to resolve to a VPC interface endpoint (checked by default on EC2 and with a vpce --endpoint-url)")
+	flag.BoolVar(&opts.governance, "governance", false, "Check Bedrock model invocation logging configuration")
+	flag.BoolVar(&opts.usage, "usage", false, "Report Claude usage from CloudWatch for the past hour and day against the Service Quotas")
+	flag.BoolVar(&opts.simulateIAM, "simulate-iam", false, "Simulate the caller's IAM policies for every Bedrock action Claude Code needs")
+	flag.BoolVar(&opts.ipRanges, "ip-ranges", false, "Check the resolved Bedrock addresses against AWS's published IP ranges for the region (cached in ~/.bcce for a day)")
+	flag.StringVar(&opts.settings.IPRangesFile, "ip-ranges-file", "", "Read AWS's ip-ranges.json from this file instead of downloading it (implies --ip-ranges)")
+	flag.IntVar(&opts.ipRangesSample, "ip-ranges-sample", 0, fmt.Sprintf("With --ip-ranges, also dial port 443 on this many of the region's prefixes directly (at most %d)", probes.MaxIPRangeSample))
+	flag.BoolVar(&opts.payloadProbe, "payload-probe", false, "POST progressively larger payloads to detect MTU black holes")
+	payloadSizes := flag.String("payload-sizes", probes.DefaultPayloadSizes, "Comma-separated payload sizes for --payload-probe")
+	flag.BoolVar(&opts.idleProbe, "idle-probe", false, "Hold a connection to the Bedrock runtime idle, direct and via the proxy, to find idle timeouts that cut streams")
+	flag.DurationVar(&opts.idleDuration, "idle-duration", 90*time.Second, "How long --idle-probe holds the connection idle")
+	flag.BoolVar(&opts.streamProbe, "stream-probe", false, "Stream a short model response (incurs a small cost) to detect gateways that buffer whole responses")
+	flag.BoolVar(&opts.concurrencyProbe, "concurrency-probe", false, "Send requests to the Bedrock runtime serially and then in parallel to find connection caps and throttling under parallelism")
+	flag.IntVar(&opts.concurrencyCount, "concurrency-probe-count", 4, fmt.Sprintf("Number of requests in each phase of the concurrency probe (at most %d)", probes.MaxConcurrencyProbe))
+	flag.BoolVar(&opts.concurrencyInvoke, "invoke", false, "Make the concurrency probe send real 1-token InvokeModel requests instead of unsigned HEADs (incurs a small cost)")
+	flag.StringVar(&opts.streamURL, "stream-url", "", "URL that streams its response slowly, for --stream-probe without ANTHROPIC_MODEL or model access")
+	flag.BoolVar(&opts.throttleProbe, "throttle-probe", false, "Send a short burst of InvokeModel requests to detect throttling (incurs a small cost)")
+	flag.IntVar(&opts.throttleProbeCount, "throttle-probe-count", 5, fmt.Sprintf("Number of requests in the throttle probe burst (at most %d; each is a billed call)", probes.MaxThrottleProbe))
+	flag.BoolVar(&opts.authMatrix, "auth-matrix", false, "Check Bedrock access with SigV4 credentials and with the AWS_BEARER_TOKEN_BEDROCK API key side by side, and show which one Claude Code uses")
+	flag.BoolVar(&opts.countTokens, "count-tokens", false, "Call the Bedrock CountTokens API for the configured model")
+	flag.BoolVar(&opts.interactive, "interactive", false, "After the run, go through each failed check, apply safe local fixes you confirm, and run the check again")
+	flag.BoolVar(&opts.benchmark, "benchmark", false, "Stream a fixed ~200-token prompt to the configured model and report time to first token and tokens/second (incurs a cost; needs --accept-cost)")
+	flag.IntVar(&opts.benchmarkRuns, "benchmark-runs", 3, fmt.Sprintf("Number of streaming invocations in --benchmark (at most %d)", probes.MaxBenchmarkRuns))
+	flag.BoolVar(&opts.acceptCost, "accept-cost", false, "Consent to the billed model invocations of --benchmark")
+	flag.Parse()
 
-func checkHTTPSConnectivity(url string) error {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: 5 * time.Second,
-			}).DialContext,
-		},
-	}
-	
-	resp, err := client.Head(url)
+	if err := validateDependencies(checkDependencies); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printSchema {
+		os.Stdout.Write(reportSchema)
+		os.Exit(0)
+	}
+	if *printPluginSchema {
+		os.Stdout.Write(probes.PluginSchema)
+		os.Exit(0)
+	}
+	switch opts.format {
+	case "console", "json":
+	case "junit", "markdown":
+		if opts.watch > 0 || opts.serve != "" || opts.history > 0 {
+			fmt.Fprintf(os.Stderr, "--format %s cannot be combined with --watch, --serve, or --history\n", opts.format)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown --format %q (want console, json, junit, or markdown)\n", opts.format)
+		os.Exit(1)
+	}
+	if opts.output != "" && opts.output != "-" {
+		if opts.watch > 0 || opts.serve != "" || opts.history > 0 {
+			fmt.Fprintln(os.Stderr, "--output cannot be combined with --watch, --serve, or --history")
+			os.Exit(1)
+		}
+		if _, err := os.Stat(opts.output); err == nil && !opts.force {
+			fmt.Fprintf(os.Stderr, "--output: %s already exists; use --force to overwrite it\n", opts.output)
+			os.Exit(1)
+		}
+	}
+	if opts.region != "" && !probes.RegionPattern.MatchString(opts.region) {
+		fmt.Fprintf(os.Stderr, "--region: %q is not a region name (e.g. us-east-1)\n", opts.region)
+		os.Exit(1)
+	}
+	policy, err := selectExitPolicy(*strict, *warnExitZero)
 	if err != nil {
-		return err
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	resp.Body.Close()
-	
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	opts.exitPolicy = policy
+	consoleStyle = output.DetectStyle(*ascii, *noColor)
+	opts.skip = make(map[string]bool)
+	for _, group := range strings.Split(*skip, ",") {
+		group = strings.TrimSpace(group)
+		switch group {
+		case "":
+		case "local", "revocation":
+			opts.skip[group] = true
+		default:
+			fmt.Fprintf(os.Stderr, "unknown --skip group %q (want local or revocation)\n", group)
+			os.Exit(1)
+		}
 	}
-	
-	return nil
-}
-
-func checkBedrockAccess(region string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	opts.dnsResolvers = probes.ParseResolvers(*dnsResolvers)
+	if opts.settings.DNSServer != "" {
+		servers := probes.ParseResolvers(opts.settings.DNSServer)
+		if len(servers) != 1 {
+			fmt.Fprintf(os.Stderr, "--resolver: want one server, got %q\n", opts.settings.DNSServer)
+			os.Exit(1)
+		}
+		opts.settings.DNSServer = servers[0]
+	}
+	if *doh != "" {
+		u, err := probes.ParseDoHURL(*doh)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--doh: %v\n", err)
+			os.Exit(1)
+		}
+		opts.doh = u
+	}
+	if opts.fips {
+		opts.settings.AWSOptions = append(opts.settings.AWSOptions, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	sizes, err := probes.ParseSizes(*payloadSizes)
 	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %w", err)
+		fmt.Fprintf(os.Stderr, "--payload-sizes: %v\n", err)
+		os.Exit(1)
 	}
-
-	client := bedrock.NewFromConfig(cfg)
-	
-	// Minimal dry-run: list foundation models (read-only operation)
-	input := &bedrock.ListFoundationModelsInput{
-		ByProvider: aws.String("anthropic"),
-	}
-	
-	result, err := client.ListFoundationModels(ctx, input)
+	opts.payloadSizes = sizes
+	if opts.settings.IPRangesFile != "" {
+		opts.ipRanges = true
+	}
+	if opts.ipRangesSample < 0 || opts.ipRangesSample > probes.MaxIPRangeSample {
+		fmt.Fprintf(os.Stderr, "--ip-ranges-sample must be between 0 and %d\n", probes.MaxIPRangeSample)
+		os.Exit(1)
+	}
+	if opts.ipRangesSample > 0 && opts.noDirectProbe {
+		fmt.Fprintln(os.Stderr, "--ip-ranges-sample dials AWS directly and cannot be combined with --no-direct-probe")
+		os.Exit(1)
+	}
+	if opts.throttleProbeCount < 1 || opts.throttleProbeCount > probes.MaxThrottleProbe {
+		fmt.Fprintf(os.Stderr, "--throttle-probe-count must be between 1 and %d; each request is a billed InvokeModel call\n", probes.MaxThrottleProbe)
+		os.Exit(1)
+	}
+	if opts.concurrencyCount < 1 || opts.concurrencyCount > probes.MaxConcurrencyProbe {
+		fmt.Fprintf(os.Stderr, "--concurrency-probe-count must be between 1 and %d\n", probes.MaxConcurrencyProbe)
+		os.Exit(1)
+	}
+	if opts.maxConcurrency < 1 || opts.maxHostRate < 0 || opts.maxRequests < 0 {
+		fmt.Fprintln(os.Stderr, "--max-concurrency must be at least 1, and --max-host-rate and --max-requests not negative")
+		os.Exit(1)
+	}
+	if opts.concurrencyProbe && (opts.concurrencyCount > opts.maxConcurrency || opts.maxHostRate > 0 && float64(opts.concurrencyCount) > opts.maxHostRate) {
+		fmt.Fprintf(os.Stderr, "--concurrency-probe-count %d exceeds --max-concurrency or --max-host-rate, so its parallel phase could not run in parallel\n", opts.concurrencyCount)
+		os.Exit(1)
+	}
+	opts.settings.Engine = probes.NewProbeEngine(opts.maxConcurrency, opts.maxHostRate, opts.maxRequests)
+	if opts.benchmark {
+		if !opts.acceptCost {
+			fmt.Fprintf(os.Stderr, "--benchmark sends %d billed streaming requests to ANTHROPIC_MODEL; add --accept-cost to run it\n", opts.benchmarkRuns)
+			os.Exit(1)
+		}
+		if opts.benchmarkRuns < 1 || opts.benchmarkRuns > probes.MaxBenchmarkRuns {
+			fmt.Fprintf(os.Stderr, "--benchmark-runs must be between 1 and %d\n", probes.MaxBenchmarkRuns)
+			os.Exit(1)
+		}
+		if opts.watch > 0 || opts.serve != "" {
+			fmt.Fprintln(os.Stderr, "--benchmark cannot be combined with --watch or --serve")
+			os.Exit(1)
+		}
+	}
+	if opts.interactive {
+		if !output.IsTerminal(os.Stdin) || opts.format != "console" {
+			fmt.Fprintln(os.Stderr, "--interactive needs a terminal and console output")
+			os.Exit(1)
+		}
+		if opts.watch > 0 || opts.serve != "" || opts.history > 0 || *profiles != "" {
+			fmt.Fprintln(os.Stderr, "--interactive cannot be combined with --watch, --serve, --history, or --profiles")
+			os.Exit(1)
+		}
+	}
+	if opts.idleDuration <= 0 {
+		fmt.Fprintln(os.Stderr, "--idle-duration must be positive")
+		os.Exit(1)
+	}
+	if opts.diff && (opts.format != "console" || opts.watch > 0 || opts.serve != "") {
+		fmt.Fprintln(os.Stderr, "--diff needs console output and cannot be combined with --watch or --serve")
+		os.Exit(1)
+	}
+	if opts.history < 0 {
+		fmt.Fprintln(os.Stderr, "--history must be positive")
+		os.Exit(1)
+	}
+	if *profiles != "" {
+		if opts.watch > 0 || opts.serve != "" {
+			fmt.Fprintln(os.Stderr, "--profiles cannot be combined with --watch or --serve")
+			os.Exit(1)
+		}
+		list, err := parseProfiles(*profiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--profiles: %v\n", err)
+			os.Exit(1)
+		}
+		opts.profiles = list
+	}
+	path, required := *configPath, true
+	if path == "" {
+		path, required = probes.DefaultConfigPath(), false
+	}
+	if path != "" {
+		cfg, err := probes.LoadConfig(path, required)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "config %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		opts.config = cfg
+	}
+	severityFlags, err := probes.ParseSeverityFlags(severitySpecs)
 	if err != nil {
-		return fmt.Errorf("bedrock API call failed: %w", err)
+		fmt.Fprintf(os.Stderr, "--severity: %v\n", err)
+		os.Exit(1)
+	}
+	opts.severity = make(map[string]probes.SeverityRule)
+	if opts.config != nil {
+		for id, status := range opts.config.Severity {
+			opts.severity[id] = probes.SeverityRule{Status: status, Source: "the doctor config"}
+		}
 	}
-	
-	if len(result.ModelSummaries) == 0 {
-		return fmt.Errorf("no Anthropic models available in region %s", region)
+	for id, status := range severityFlags {
+		opts.severity[id] = probes.SeverityRule{Status: status, Source: "--severity"}
 	}
-	
-	return nil
+	if opts.endpointURL != "" {
+		u, err := probes.ParseEndpointURL(opts.endpointURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--endpoint-url: %v\n", err)
+			os.Exit(1)
+		}
+		opts.settings.Endpoint = u
+	}
+	if opts.bundle != "" {
+		// Request IDs and diagnostics are what support needs most.
+		opts.settings.Verbose = true
+	}
+	return opts
 }
 
-func runChecks() []CheckResult {
-	var results []CheckResult
-	
-	// Get region from environment
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		results = append(results, CheckResult{
-			Name:    "AWS_REGION",
-			Status:  "fail",
-			Message: "AWS_REGION environment variable not set",
-			Fix:     "export AWS_REGION=us-east-1 (or your preferred region)",
-		})
-		return results // Can't continue without region
-	}
-	
-	results = append(results, CheckResult{
-		Name:    "AWS_REGION",
-		Status:  "pass",
-		Message: fmt.Sprintf("Set to: %s", region),
-	})
-	
-	// DNS resolution checks
-	endpoints := []struct {
-		name string
-		host string
-	}{
-		{"Bedrock Runtime", fmt.Sprintf("bedrock-runtime.%s.amazonaws.com", region)},
-		{"Bedrock Control", fmt.Sprintf("bedrock.%s.amazonaws.com", region)},
-		{"STS", fmt.Sprintf("sts.%s.amazonaws.com", region)},
-	}
-	
-	for _, endpoint := range endpoints {
-		if err := checkDNS(endpoint.host); err != nil {
-			results = append(results, CheckResult{
-				Name:    fmt.Sprintf("DNS - %s", endpoint.name),
-				Status:  "fail", 
-				Message: fmt.Sprintf("Failed to resolve %s: %v", endpoint.host, err),
-				Fix:     "Check internet connectivity and DNS settings",
-			})
+func main() {
+	opts := parseFlags()
+	opts.settings.Host = probes.DetectEnvironment("/", os.Getenv)
+	var logOut []io.Writer
+	if opts.logFile != "" {
+		f, err := probes.OpenDebugLog(opts.logFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--log-file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		logOut = append(logOut, f)
+	}
+	// The support bundle carries its own copy of the probe log.
+	var probeLog bytes.Buffer
+	if opts.bundle != "" {
+		logOut = append(logOut, &probeLog)
+	}
+	if len(logOut) > 0 {
+		opts.settings.AWSOptions = append(opts.settings.AWSOptions, probes.EnableDebugLog(io.MultiWriter(logOut...))...)
+	}
+	opts.env = probes.NewEnv(opts.settings)
+	if opts.history > 0 {
+		os.Exit(runHistory(opts.history, opts.region))
+	}
+	if opts.serve != "" {
+		os.Exit(runServe(opts))
+	}
+	if opts.watch > 0 {
+		os.Exit(runWatch(opts))
+	}
+
+	started := time.Now()
+	results := runChecks(opts)
+	var runs []output.ProfileRun
+	if len(opts.profiles) > 0 {
+		runs = runProfiles(opts, probes.CurrentRegion(opts.region), results)
+	}
+	rep := newReport(opts, results, started, time.Now())
+	rep.Profiles = runs
+	records := historyRecords(rep)
+	recordHistory(records)
+
+	if opts.fixScript != "" {
+		if err := probes.WriteFixScript(opts.fixScript, results, runtime.GOOS == "windows"); err != nil {
+			fmt.Fprintf(os.Stderr, "--emit-fix-script: %v\n", err)
 		} else {
-			results = append(results, CheckResult{
-				Name:    fmt.Sprintf("DNS - %s", endpoint.name),
-				Status:  "pass",
-				Message: fmt.Sprintf("Resolved %s", endpoint.host),
-			})
-		}
-	}
-	
-	// HTTPS connectivity check
-	bedrockURL := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
-	if err := checkHTTPSConnectivity(bedrockURL); err != nil {
-		results = append(results, CheckResult{
-			Name:    "HTTPS Connectivity",
-			Status:  "fail",
-			Message: fmt.Sprintf("Failed to connect to %s: %v", bedrockURL, err),
-			Fix:     "Check firewall, proxy settings, or VPC endpoint configuration",
-		})
-	} else {
-		results = append(results, CheckResult{
-			Name:    "HTTPS Connectivity", 
-			Status:  "pass",
-			Message: fmt.Sprintf("Successfully connected to %s", bedrockURL),
-		})
-	}
-	
-	// PrivateLink endpoint check (if VPC endpoint is configured)
-	if strings.Contains(os.Getenv("AWS_BEDROCK_ENDPOINT_URL"), "vpce-") {
-		results = append(results, CheckResult{
-			Name:    "PrivateLink VPC Endpoint",
-			Status:  "pass",
-			Message: "VPC endpoint configuration detected",
-		})
-	}
-	
-	// Bedrock API access check
-	if err := checkBedrockAccess(region); err != nil {
-		status := "fail"
-		fix := "Check AWS credentials and IAM permissions for bedrock:ListFoundationModels"
-		
-		// Provide more specific guidance based on error type
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "UnauthorizedOperation") || strings.Contains(errMsg, "AccessDenied") {
-			fix = "Add bedrock:ListFoundationModels permission to your IAM role/user"
-		} else if strings.Contains(errMsg, "no models available") {
-			status = "warn"
-			fix = "Request access to Anthropic models in AWS Bedrock console"
-		}
-		
-		results = append(results, CheckResult{
-			Name:    "Bedrock API Access",
-			Status:  status,
-			Message: errMsg,
-			Fix:     fix,
-		})
-	} else {
-		results = append(results, CheckResult{
-			Name:    "Bedrock API Access",
-			Status:  "pass", 
-			Message: fmt.Sprintf("Successfully accessed Bedrock API in %s", region),
-		})
-	}
-	
-	return results
-}
+			fmt.Fprintf(os.Stderr, "Fix script written to %s; review it before running\n", opts.fixScript)
+		}
+	}
 
-func main() {
-	results := runChecks()
-	
-	hasFailures := false
-	hasWarnings := false
-	
-	fmt.Println("🩺 BCCE Doctor Probes Report")
-	fmt.Println()
-	
-	for _, result := range results {
-		icon := "✅"
-		switch result.Status {
-		case "warn":
-			icon = "⚠️"
-			hasWarnings = true
-		case "fail":
-			icon = "❌"
-			hasFailures = true
-		}
-		
-		fmt.Printf("%s %s: %s\n", icon, result.Name, result.Message)
-		if result.Fix != "" {
-			fmt.Printf("   Fix: %s\n", result.Fix)
-		}
-	}
-	
-	fmt.Println()
-	
-	if hasFailures {
-		fmt.Println("❌ Critical connectivity issues detected")
-		os.Exit(1)
-	} else if hasWarnings {
-		fmt.Println("⚠️  Some warnings detected")
-		os.Exit(2)
-	} else {
-		fmt.Println("✅ All connectivity checks passed")
-		os.Exit(0)
+	if opts.bundle != "" {
+		contents, err := writeBundle(opts.bundle, rep, probeLog.Bytes())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--bundle: %v\n", err)
+		} else {
+			printBundleSummary(os.Stderr, opts.bundle, contents)
+		}
 	}
-}
\ No newline at end of file
+
+	if code := writeReports(opts, records, rep, os.Stdout, os.Stderr); code != 0 {
+		os.Exit(code)
+	}
+
+	if opts.interactive {
+		results = runInteractive(opts, os.Stdin, os.Stdout, results)
+	}
+	os.Exit(opts.exitPolicy.code(output.AllResults(results, runs)))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+)
+
+// consoleStyle is the style of all console output, set from --ascii,
+// --no-color, NO_COLOR, and whether stdout is a terminal.
+var consoleStyle output.Style
+
+// fileStyle is the style of reports written to a file with --output.
+var fileStyle = output.Style{ASCII: true}
+
+// newRenderer returns the renderer for the --format and --diff options.
+// The console and diff formats mark status in style.
+func newRenderer(opts options, records []historyRecord, style output.Style) output.Renderer {
+	switch {
+	case opts.format == "json":
+		return output.JSON{}
+	case opts.format == "junit":
+		p := opts.exitPolicy
+		return output.JUnit{WarnFails: p.Warn != p.Pass}
+	case opts.format == "markdown":
+		return output.Markdown{Policy: opts.exitPolicy.String()}
+	case opts.diff:
+		return diffRenderer{records: records, style: style}
+	default:
+		return output.Console{Quiet: opts.quiet, Policy: opts.exitPolicy.String(), Style: style}
+	}
+}
+
+// writeReports writes rep in --format to the --output file, if any, and to
+// stdout: in --format too, or as the console summary when a file got the
+// report. It returns exitRenderError, after saying why on stderr, when
+// either could not be written, so a broken report is not mistaken for the
+// checks' outcome, and 0 otherwise.
+func writeReports(opts options, records []historyRecord, rep output.Report, stdout, stderr io.Writer) int {
+	if opts.output != "" && opts.output != "-" {
+		if err := writeOutput(opts.output, opts.force, newRenderer(opts, records, fileStyle), rep); err != nil {
+			fmt.Fprintf(stderr, "--output: %v\n", err)
+			return exitRenderError
+		}
+		fmt.Fprintf(stderr, "Report written to %s\n", opts.output)
+		opts.format = "console"
+	}
+	if err := newRenderer(opts, records, consoleStyle).Render(stdout, rep); err != nil {
+		fmt.Fprintf(stderr, "rendering the report: %v\n", err)
+		return exitRenderError
+	}
+	return 0
+}
+
+// writeOutput renders rep to path atomically: into a temporary file in the
+// same directory, which is then renamed over path, so a reader never sees a
+// partial report. Missing parent directories are created. Without force an
+// existing file is left alone.
+func writeOutput(path string, force bool, r output.Renderer, rep output.Report) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+		}
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if err := r.Render(tmp, rep); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// diffRenderer prints, per region and profile, what changed since the
+// previous run.
+type diffRenderer struct {
+	records []historyRecord
+	style   output.Style
+}
+
+func (d diffRenderer) Render(w io.Writer, _ output.Report) error {
+	for _, rec := range d.records {
+		printDiff(w, rec, d.style)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+func testReport() output.Report {
+	return output.Report{
+		Region:      "us-east-1",
+		Environment: probes.HostEnvironment{OS: "linux", CI: "GitHub Actions"},
+		Timestamp:   time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+		Results: []probes.CheckResult{
+			{ID: "region", Name: "AWS Region", Status: "pass", Message: "us-east-1", DurationMs: 1},
+			{ID: "dns.sts", Name: "DNS sts", Status: "warn", Message: "slow | 900ms", Fix: "Check the resolver", DurationMs: 900},
+			{ID: "bedrock.api_access", Name: "Bedrock API", Status: "fail", Code: "BCCE-AUTH-001", Message: "AccessDenied", Fix: "Grant bedrock:ListFoundationModels", DurationMs: 250},
+			{ID: "bedrock.usage", Name: "Usage", Status: "skip", Message: "skipped (dependency failed: bedrock.api_access)"},
+		},
+		Profiles: []output.ProfileRun{{Profile: "dev", Results: []probes.CheckResult{
+			{ID: "bedrock.api_access", Name: "Bedrock API", Status: "pass", Message: "ok"},
+		}}},
+	}
+}
+
+func TestNewRenderer(t *testing.T) {
+	tests := []struct {
+		opts options
+		want output.Renderer
+	}{
+		{options{format: "console", quiet: true, exitPolicy: strictExitPolicy}, output.Console{Quiet: true, Policy: strictExitPolicy.String(), Style: fileStyle}},
+		{options{format: "console", diff: true}, diffRenderer{style: fileStyle}},
+		{options{format: "json", diff: true}, output.JSON{}},
+		{options{format: "junit", exitPolicy: defaultExitPolicy}, output.JUnit{WarnFails: true}},
+		{options{format: "junit", exitPolicy: warnExitZeroExitPolicy}, output.JUnit{}},
+		{options{format: "markdown", exitPolicy: strictExitPolicy}, output.Markdown{Policy: strictExitPolicy.String()}},
+	}
+	for _, tt := range tests {
+		got := newRenderer(tt.opts, nil, fileStyle)
+		if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", tt.want) {
+			t.Errorf("newRenderer(%s, diff %v) = %#v, want %#v", tt.opts.format, tt.opts.diff, got, tt.want)
+		}
+	}
+}
+
+type failingRenderer struct{}
+
+func (failingRenderer) Render(w io.Writer, _ output.Report) error {
+	w.Write([]byte("partial"))
+	return errors.New("render failed")
+}
+
+func TestWriteOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // contents of the file before, if any
+		force    bool
+		r        output.Renderer
+		err      string
+		want     string // contents after
+	}{
+		{name: "creates parent directories", r: output.JSON{}, want: `"region": "us-east-1"`},
+		{name: "refuses to overwrite", existing: "old", r: output.JSON{}, err: "already exists; use --force", want: "old"},
+		{name: "overwrites with force", existing: "old", force: true, r: output.JSON{}, want: `"region": "us-east-1"`},
+		{name: "keeps the old report when rendering fails", existing: "old", force: true, r: failingRenderer{}, err: "render failed", want: "old"},
+		{name: "junit", r: newRenderer(options{format: "junit", exitPolicy: defaultExitPolicy}, nil, fileStyle), want: `<failure message="AccessDenied" type="BCCE-AUTH-001">`},
+		{name: "markdown", r: newRenderer(options{format: "markdown", exitPolicy: defaultExitPolicy}, nil, fileStyle), want: "| ❌ FAIL | Bedrock API | AccessDenied | Grant bedrock:ListFoundationModels |"},
+		{name: "console in the file style", r: output.Console{Policy: defaultExitPolicy.String(), Style: fileStyle}, want: "[FAIL] Bedrock API: AccessDenied\n   Fix: Grant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "reports", "doctor.json")
+			if tt.existing != "" {
+				os.MkdirAll(filepath.Dir(path), 0o755)
+				os.WriteFile(path, []byte(tt.existing), 0o644)
+			}
+			err := writeOutput(path, tt.force, tt.r, testReport())
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+			data, _ := os.ReadFile(path)
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("contents = %q, want %q", data, tt.want)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			if len(entries) != 1 {
+				t.Errorf("temporary files left behind: %v", entries)
+			}
+		})
+	}
+}
+
+// errWriter fails every write, like a closed stdout.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestWriteReports(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	os.WriteFile(blocker, nil, 0o644)
+	tests := []struct {
+		name   string
+		opts   options
+		stdout io.Writer
+		code   int
+		file   string // in the --output file
+		out    string // on stdout
+		errOut string // on stderr
+	}{
+		{
+			name: "junit to a file, summary to the console",
+			opts: options{format: "junit", output: filepath.Join(dir, "junit.xml")},
+			file: "<testsuites", out: "Bedrock API: AccessDenied", errOut: "Report written to",
+		},
+		{
+			name: "markdown to stdout with -",
+			opts: options{format: "markdown", output: "-"},
+			out:  "| Status | Check | Result | Fix |",
+		},
+		{
+			name:   "unwritable output file",
+			opts:   options{format: "junit", output: filepath.Join(blocker, "junit.xml")},
+			code:   exitRenderError,
+			errOut: "--output: ",
+		},
+		{
+			name:   "unwritable stdout",
+			opts:   options{format: "junit"},
+			stdout: errWriter{},
+			code:   exitRenderError,
+			errOut: "rendering the report: broken pipe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.exitPolicy = defaultExitPolicy
+			var stdout, stderr strings.Builder
+			w := tt.stdout
+			if w == nil {
+				w = &stdout
+			}
+			if code := writeReports(tt.opts, nil, testReport(), w, &stderr); code != tt.code {
+				t.Errorf("exit code = %d, want %d (stderr %q)", code, tt.code, stderr.String())
+			}
+			if tt.file != "" {
+				data, _ := os.ReadFile(tt.opts.output)
+				if !strings.Contains(string(data), tt.file) {
+					t.Errorf("file = %q, want %q", data, tt.file)
+				}
+			}
+			if !strings.Contains(stdout.String(), tt.out) {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.out)
+			}
+			if !strings.Contains(stderr.String(), tt.errOut) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.errOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+var profileSection = regexp.MustCompile(`^\s*\[\s*(?:profile\s+)?([^\]\s]+)\s*\]`)
+
+// sharedConfigProfiles lists every profile defined in the shared config and
+// credentials files, sorted.
+func sharedConfigProfiles() []string {
+	configFile, credentialsFile := probes.SharedConfigFiles()
+	seen := map[string]bool{}
+	for _, path := range []string{configFile, credentialsFile} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// sso-session and services sections are not profiles
+			if strings.Contains(line, "sso-session ") || strings.Contains(line, "services ") {
+				continue
+			}
+			if m := profileSection.FindStringSubmatch(line); m != nil {
+				seen[m[1]] = true
+			}
+		}
+		f.Close()
+	}
+
+	profiles := make([]string, 0, len(seen))
+	for p := range seen {
+		profiles = append(profiles, p)
+	}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// parseProfiles expands the --profiles value: a comma-separated list, or
+// "all" for every profile in the shared config files.
+func parseProfiles(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "all" {
+		profiles := sharedConfigProfiles()
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("no profiles found in the shared config files")
+		}
+		return profiles, nil
+	}
+	var profiles []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles, nil
+}
+
+// runProfiles runs the credential checks once per profile by pointing
+// AWS_PROFILE at it, the same way Claude Code selects a profile. Failures in
+// the shared results block dependent checks under --fail-fast.
+func runProfiles(opts options, region string, shared []probes.CheckResult) []output.ProfileRun {
+	if original, ok := os.LookupEnv("AWS_PROFILE"); ok {
+		defer os.Setenv("AWS_PROFILE", original)
+	} else {
+		defer os.Unsetenv("AWS_PROFILE")
+	}
+
+	runs := make([]output.ProfileRun, 0, len(opts.profiles))
+	for _, profile := range opts.profiles {
+		os.Setenv("AWS_PROFILE", profile)
+		opts.env.Reset()
+		rec := newRecorder(opts)
+		rec.failFast = opts.failFast
+		rec.inherit(shared)
+		credentialChecks(rec, opts, region)
+		runs = append(runs, output.ProfileRun{Profile: profile, Results: rec.results})
+	}
+	return runs
+}
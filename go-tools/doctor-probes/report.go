@@ -0,0 +1,35 @@
+package main
+
+import (
+	_ "embed"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// schemaVersion is the version of the JSON report envelope. Bump it (and
+// schema/report.schema.json) whenever a field is added, removed, or changes
+// meaning; consumers should branch on it rather than on message text.
+const schemaVersion = "1.15"
+
+// version is the tool version, set at build time with
+// -ldflags "-X main.version=<version>".
+var version = "dev"
+
+//go:embed schema/report.schema.json
+var reportSchema []byte
+
+// newReport wraps the results of a run that began at started and ended at
+// now.
+func newReport(opts options, results []probes.CheckResult, started, now time.Time) output.Report {
+	return output.Report{
+		SchemaVersion: schemaVersion,
+		ToolVersion:   version,
+		Region:        probes.CurrentRegion(opts.region),
+		Environment:   opts.env.Host,
+		Timestamp:     now.UTC(),
+		DurationMs:    probes.DurationMs(now.Sub(started)),
+		Results:       results,
+	}
+}
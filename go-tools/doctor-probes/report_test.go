@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+	"bcce/go-tools/doctor-probes/internal/probes/probestest"
+)
+
+// validateSchema checks v against the subset of JSON Schema that
+// schema/report.schema.json uses and returns one line per violation.
+func validateSchema(root, schema map[string]any, v any, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		def, _ := root["$defs"].(map[string]any)[name].(map[string]any)
+		if def == nil {
+			return []string{fmt.Sprintf("%s: unresolved $ref %s", path, ref)}
+		}
+		return validateSchema(root, def, v, path)
+	}
+
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	if want, ok := schema["const"]; ok && v != want {
+		fail("= %v, want const %v", v, want)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || v == e
+		}
+		if !found {
+			fail("%v not in enum %v", v, enum)
+		}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("%T is not an object", v)
+			return errs
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				fail("missing required %q", name)
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			sub, ok := props[key].(map[string]any)
+			if !ok {
+				sub, _ = schema["additionalProperties"].(map[string]any)
+			}
+			if sub != nil {
+				errs = append(errs, validateSchema(root, sub, obj[key], path+"."+key)...)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail("%T is not an array", v)
+			return errs
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range arr {
+			errs = append(errs, validateSchema(root, items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			fail("%T is not a string", v)
+			return errs
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			fail("%q does not match %s", s, pattern)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				fail("%q is not a date-time", s)
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			fail("%T is not a number", v)
+			return errs
+		}
+		if schema["type"] == "integer" && n != float64(int64(n)) {
+			fail("%v is not an integer", n)
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			fail("%v is below the minimum %v", n, min)
+		}
+	}
+	return errs
+}
+
+var reportResults = []probes.CheckResult{
+	{ID: "region", Name: "AWS_REGION", Status: "pass", Message: "Set to: us-east-1"},
+	{ID: "dns.bedrock_runtime", Code: "E_DNS_NXDOMAIN", Name: "DNS - Bedrock Runtime", Status: "fail", Message: "no such host", Fix: "Check DNS"},
+	{ID: "https.bedrock_runtime", Code: "E_SLOW_TTFB", Name: "HTTPS Connectivity", Status: "warn", Message: "slow", Metrics: map[string]float64{"ttfb_ms": 900}},
+}
+
+func TestReportMatchesSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(reportSchema, &schema); err != nil {
+		t.Fatalf("--schema output is not JSON: %v", err)
+	}
+	if got := schema["properties"].(map[string]any)["schema_version"].(map[string]any)["const"]; got != schemaVersion {
+		t.Errorf("schema pins schema_version %v, code writes %s", got, schemaVersion)
+	}
+
+	rec := newRecorder(options{env: probes.NewEnv(probes.RunSettings{})})
+	rec.add(append([]probes.CheckResult(nil), reportResults...)...)
+	t.Setenv("AWS_REGION", "us-east-1")
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+
+	tests := []struct {
+		name string
+		doc  any
+	}{
+		{"report", newReport(options{}, rec.results, now.Add(-3*time.Second), now)},
+		{"watch iteration", watchIteration{Iteration: 3, Report: newReport(options{}, rec.results, now.Add(-3*time.Second), now)}},
+		{"no results", newReport(options{}, []probes.CheckResult{}, now, now)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var v map[string]any
+			if err := json.Unmarshal(data, &v); err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range validateSchema(schema, schema, v, "$") {
+				t.Error(e)
+			}
+			if v["timestamp"] != "2026-10-16T07:00:00Z" {
+				t.Errorf("timestamp = %v, want UTC", v["timestamp"])
+			}
+		})
+	}
+}
+
+// TestMultiRegionReportMatchesSchema renders the report of a machine whose
+// region comes from its profile while other tools are configured for other
+// regions, so the envelope and the results name different regions.
+func TestMultiRegionReportMatchesSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(reportSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	probestest.AWSConfig(t, "[profile dev]\nregion = eu-west-1\n")
+	t.Setenv("AWS_PROFILE", "dev")
+	probestest.Unsetenv(t, "AWS_REGION", "AWS_DEFAULT_REGION")
+
+	results := []probes.CheckResult{
+		{ID: "region", Code: "E_REGION_FALLBACK", Name: "AWS Region", Status: "warn", Message: "eu-west-1 from the region of profile 'dev'", Fix: "export AWS_REGION=eu-west-1"},
+		{
+			ID: "region.consistency", Code: "E_REGION_MISMATCH", Name: "Region Consistency", Status: "warn", Message: "Claude Code and the AWS CLI use different regions",
+			Regions: []probes.RegionSource{
+				{Source: "region (profile dev)", Value: "eu-west-1", Region: "eu-west-1", UsedBy: "Claude Code", Matches: true},
+				{Source: "ANTHROPIC_MODEL (environment)", Value: "us.anthropic.claude-sonnet-4-5-20250929-v1:0", Geography: "us", UsedBy: "Claude Code"},
+				{Source: "region (profile prod)", Value: "ap-southeast-2", Region: "ap-southeast-2", UsedBy: "AWS CLI"},
+			},
+		},
+	}
+	rec := newRecorder(options{env: probes.NewEnv(probes.RunSettings{})})
+	rec.add(results...)
+	data, err := json.Marshal(newReport(options{}, rec.results, time.Now(), time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range validateSchema(schema, schema, v, "$") {
+		t.Error(e)
+	}
+	if v["region"] != "eu-west-1" {
+		t.Errorf("region = %v, want eu-west-1 from the profile", v["region"])
+	}
+}
+
+func TestReportSchemaRejects(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(reportSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		result string
+		want   string
+	}{
+		{"unknown status", `{"id":"x","severity":"info","name":"X","status":"ok","message":"","duration_ms":0}`, "not in enum"},
+		{"bad code", `{"id":"x","code":"DNS","severity":"info","name":"X","status":"pass","message":"","duration_ms":0}`, "does not match"},
+		{"missing id", `{"severity":"info","name":"X","status":"pass","message":"","duration_ms":0}`, `missing required "id"`},
+		{"negative duration", `{"id":"x","severity":"info","name":"X","status":"pass","message":"","duration_ms":-1}`, "below the minimum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A valid envelope around the one bad result.
+			data, _ := json.Marshal(newReport(options{}, nil, time.Now(), time.Now()))
+			var v map[string]any
+			if err := json.Unmarshal(data, &v); err != nil {
+				t.Fatal(err)
+			}
+			var result any
+			if err := json.Unmarshal([]byte(tt.result), &result); err != nil {
+				t.Fatal(err)
+			}
+			v["results"] = []any{result}
+			errs := validateSchema(schema, schema, v, "$")
+			if len(errs) != 1 || !strings.Contains(errs[0], tt.want) {
+				t.Errorf("errors = %q, want one containing %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+// TestReportKeepsLegacyFields guards consumers of the pre-envelope output,
+// which read name, status, message, fix, metrics, and duration_ms from each
+// result.
+func TestReportKeepsLegacyFields(t *testing.T) {
+	data, err := json.Marshal(newReport(options{}, reportResults, time.Now(), time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Results []struct {
+			Name       *string            `json:"name"`
+			Status     *string            `json:"status"`
+			Message    *string            `json:"message"`
+			Fix        string             `json:"fix"`
+			Metrics    map[string]float64 `json:"metrics"`
+			DurationMs *float64           `json:"duration_ms"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Results) != len(reportResults) {
+		t.Fatalf("got %d results, want %d", len(v.Results), len(reportResults))
+	}
+	for i, r := range v.Results {
+		want := reportResults[i]
+		if r.Name == nil || *r.Name != want.Name || r.Status == nil || *r.Status != want.Status ||
+			r.Message == nil || *r.Message != want.Message || r.DurationMs == nil {
+			t.Errorf("result %d lost a legacy field: %s", i, data)
+		}
+		if r.Fix != want.Fix || len(r.Metrics) != len(want.Metrics) {
+			t.Errorf("result %d fix, metrics = %q, %v; want %q, %v", i, r.Fix, r.Metrics, want.Fix, want.Metrics)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// recorder runs checks against env and collects their results, stamping
+// each with the time of the call that produced it. Results without an
+// explicit severity get one derived from their status.
+type recorder struct {
+	ctx      context.Context
+	env      probes.Env
+	severity map[string]probes.SeverityRule // the config's and --severity's
+	results  []probes.CheckResult
+
+	// --fail-fast: failed or skipped check IDs, mapped to the root failure
+	failFast bool
+	failed   map[string]string
+}
+
+func newRecorder(opts options) *recorder {
+	return &recorder{ctx: context.Background(), env: opts.env, severity: opts.severity, failed: make(map[string]string)}
+}
+
+func (r *recorder) add(results ...probes.CheckResult) {
+	for i := range results {
+		probes.ApplySeverityOverride(&results[i], r.severity)
+		if results[i].Severity == "" {
+			results[i].Severity = probes.SeverityForStatus(results[i].Status)
+		}
+		if results[i].Status == "fail" {
+			r.failed[results[i].ID] = results[i].ID
+		}
+	}
+	r.results = append(r.results, results...)
+}
+
+// stamp sets the duration of results to the time since start, when the one
+// call that produced them began. The results of a group share that time:
+// how it divides among them is not known.
+func stamp(results []probes.CheckResult, start time.Time) []probes.CheckResult {
+	elapsed := probes.DurationMs(time.Since(start))
+	for i := range results {
+		results[i].DurationMs = elapsed
+	}
+	return results
+}
+
+// addCheck adds the result of a check that always runs.
+func (r *recorder) addCheck(c probes.Check) {
+	start := time.Now()
+	r.add(stamp([]probes.CheckResult{c.Run(r.ctx, r.env)}, start)...)
+}
+
+// addGroup adds the results of a group that always runs.
+func (r *recorder) addGroup(g probes.Group) {
+	start := time.Now()
+	r.add(stamp(g.RunAll(r.ctx, r.env), start)...)
+}
+
+// runCheck runs c subject to its prerequisites and the request budget.
+func (r *recorder) runCheck(c probes.Check) {
+	r.run(c.ID(), c.Name(), func() []probes.CheckResult {
+		return []probes.CheckResult{c.Run(r.ctx, r.env)}
+	})
+}
+
+// runGroup runs g subject to its prerequisites and the request budget.
+func (r *recorder) runGroup(g probes.Group) {
+	r.run(g.ID(), g.Name(), func() []probes.CheckResult {
+		return g.RunAll(r.ctx, r.env)
+	})
+}
+
+func runChecks(opts options) []probes.CheckResult {
+	opts.env.Reset()
+	rec := newRecorder(opts)
+	rec.failFast = opts.failFast
+
+	// Local Claude Code install; skipped on servers that only need connectivity
+	if !opts.skip["local"] {
+		rec.addGroup(probes.LocalInstall{})
+		rec.addGroup(probes.Settings{})
+	}
+
+	// Clock sync; servers need it as much as laptops, so --skip local keeps it
+	rec.addCheck(probes.TimeSync{})
+
+	// Region, from --region, the environment, or the profile as the SDK
+	// resolves it
+	region, _ := probes.ResolveRegion(opts.region)
+	rec.runCheck(probes.Region{Flag: opts.region})
+	rec.runCheck(probes.RegionConsistency{Flag: opts.region})
+	if region == "" {
+		return rec.results // Can't continue without region
+	}
+	// DNS resolution checks
+	endpoints := probes.Endpoints(region, opts.fips)
+	bedrockURL := "https://" + endpoints[0].Host
+
+	// An endpoint override replaces both Bedrock endpoints, so the public
+	// ones are not probed.
+	if override := opts.env.Endpoint; override != nil {
+		rec.addCheck(probes.EndpointOverride{Endpoint: override, Skipped: []string{endpoints[0].Host, endpoints[1].Host}})
+		bedrockURL = override.String()
+		endpoints[0].Host = override.Hostname()
+		endpoints = append(endpoints[:1], endpoints[2:]...)
+	}
+
+	var resolved []*probes.EndpointDNS
+	for _, endpoint := range endpoints {
+		dns := &probes.EndpointDNS{Endpoint: endpoint.Name, Host: endpoint.Host, Region: region, FIPS: opts.fips}
+		if cause := rec.blockedBy(dns.ID()); cause != "" {
+			rec.skip(dns.ID(), dns.Name(), cause)
+			continue
+		}
+		rec.addCheck(dns)
+		if dns.Err == nil {
+			resolved = append(resolved, dns)
+		}
+
+		if opts.env.DNSServer != "" || opts.doh != nil {
+			rec.addCheck(probes.DNSMethods{Endpoint: endpoint.Name, Host: endpoint.Host, Server: opts.env.DNSServer, DoH: opts.doh})
+		}
+
+		if !opts.noExternalDNS && !opts.noExternalProbes {
+			rec.addCheck(probes.DNSCompare{System: dns, Servers: opts.dnsResolvers})
+		}
+	}
+
+	// IPv4/IPv6 reachability per endpoint
+	for _, endpoint := range endpoints {
+		rec.runCheck(probes.AddressFamilies{Endpoint: endpoint.Name, Host: endpoint.Host})
+		// Dual-stack names exist only for the public endpoints
+		if host := endpoint.DualStackHost(); opts.dualStack && host != "" {
+			rec.addCheck(probes.DualStack{Endpoint: endpoint.Name, Host: host})
+		}
+	}
+
+	// Proxy bypass decisions, evaluated with Node's NO_PROXY rules
+	hosts := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		hosts[i] = endpoint.Host
+	}
+	rec.addGroup(probes.NoProxy{Hosts: hosts})
+
+	// OS-level proxy / PAC settings that Node does not see
+	rec.addGroup(probes.SystemProxy{})
+
+	// SOCKS5 proxy from ALL_PROXY, used by the TLS probes
+	rec.addGroup(probes.SOCKSProxy{URL: bedrockURL})
+
+	// Local interface and AWS range of the Bedrock route (split-tunnel VPNs)
+	rec.runCheck(probes.EgressRoute{URL: bedrockURL})
+
+	// Resolved addresses against AWS's published ranges (DNS hijacking,
+	// address-based allow lists)
+	if opts.ipRanges {
+		rec.runCheck(probes.IPRanges{Region: region, DNS: resolved, Sample: opts.ipRangesSample})
+	}
+
+	// HTTPS connectivity check
+	rec.runCheck(probes.HTTPSConnectivity{URL: bedrockURL, TTFBThreshold: opts.ttfbThreshold})
+
+	// What a new connection costs over a reused one
+	rec.runCheck(probes.ConnectionReuse{URL: bedrockURL, ColdPenalty: opts.coldPenalty})
+
+	// What the proxy costs over a direct path, when both exist
+	rec.runCheck(probes.ProxyOverhead{URL: bedrockURL, Threshold: opts.proxyOverhead, Direct: !opts.noDirectProbe})
+
+	// Protocol negotiated by ALPN, direct and through the proxy
+	if strings.HasPrefix(bedrockURL, "https://") {
+		rec.runCheck(probes.ALPN{URL: bedrockURL, Direct: !opts.noDirectProbe})
+
+		// Protocol versions accepted by whatever terminates TLS
+		rec.runCheck(probes.TLSPolicy{URL: bedrockURL})
+
+		// Who issued the served certificate, and which inspecting product
+		rec.runCheck(probes.TLSInspection{URL: bedrockURL})
+
+		// Validity window, SAN coverage, and root of the served certificate
+		rec.runCheck(probes.Certificate{URL: bedrockURL})
+
+		// Go's default verification against the OS trust store (never worse than warn)
+		rec.runCheck(probes.OSTrust{URL: bedrockURL})
+
+		// OCSP/CRL endpoints of the served chain (never worse than warn)
+		if !opts.skip["revocation"] {
+			rec.runCheck(probes.Revocation{URL: bedrockURL})
+		}
+	}
+
+	// Whether AWS's JSON error bodies arrive unmodified
+	rec.runCheck(probes.Middlebox{Region: region, FIPS: opts.fips})
+
+	// Opt-in large-payload / MTU probe
+	if opts.payloadProbe {
+		rec.runCheck(probes.Payload{URL: bedrockURL, Sizes: opts.payloadSizes})
+	}
+
+	// Opt-in idle connection probe (proxy and firewall idle timeouts)
+	if opts.idleProbe && strings.HasPrefix(bedrockURL, "https://") {
+		rec.runCheck(probes.IdleConnection{URL: bedrockURL, Hold: opts.idleDuration})
+	}
+
+	// Opt-in response buffering probe (never worse than warn)
+	if opts.streamProbe {
+		rec.runCheck(probes.Streaming{Region: region, URL: bedrockURL, StreamURL: opts.streamURL})
+	}
+
+	// Opt-in concurrency probe (proxy connection caps, throttling)
+	if opts.concurrencyProbe {
+		rec.runCheck(probes.Concurrency{Region: region, URL: bedrockURL, Count: opts.concurrencyCount, Invoke: opts.concurrencyInvoke})
+	}
+
+	// PrivateLink endpoint check (if VPC endpoint is configured)
+	if opts.env.IsVPCEndpoint() {
+		rec.addCheck(probes.VPCEndpoint{})
+	}
+
+	// Private hosted zone and Route 53 Resolver forwarding for PrivateLink
+	rec.addGroup(probes.PrivateDNS{Region: region, Host: endpoints[0].Host, Forced: opts.privateLink})
+
+	// OpenTelemetry collector check (skipped when telemetry isn't configured)
+	rec.addGroup(probes.OTel{})
+
+	// Bedrock API key, which Claude Code uses instead of SigV4 when set
+	rec.runGroup(probes.APIKey{Region: region, URL: bedrockURL})
+
+	// Organization checks from the doctor config
+	rec.addGroup(probes.Config{Config: opts.config})
+
+	// Site-specific plugin checks from ~/.bcce/doctor.d and the config
+	rec.addGroup(probes.Plugins{Config: opts.config, Region: region, Prior: rec.results, ToolVersion: version})
+
+	// Syntax and common mistakes in ~/.aws/config and ~/.aws/credentials
+	rec.addGroup(probes.SharedConfigLint{})
+
+	// SDK retry and instance metadata settings that slow down failures
+	rec.addGroup(probes.RetryConfig{})
+
+	// Checks that depend on the AWS credentials; with --profiles they run
+	// once per profile instead
+	if len(opts.profiles) == 0 {
+		credentialChecks(rec, opts, region)
+	}
+
+	// Opt-in comparison of the SigV4 and Bedrock API key auth paths
+	if opts.authMatrix {
+		rec.runGroup(probes.AuthMatrix{Region: region, FIPS: opts.fips})
+	}
+
+	return rec.results
+}
+
+// credentialChecks runs the checks that depend on the active AWS credentials.
+func credentialChecks(rec *recorder, opts options, region string) {
+	// IAM Identity Center session and credential_process (only when the
+	// active profile uses them)
+	rec.runGroup(probes.SSOSession{})
+	rec.addGroup(probes.CredentialProcess{})
+
+	// IRSA web identity or EC2 instance profile (only in those environments)
+	rec.addGroup(probes.WorkloadIdentity{Region: region})
+
+	// Each hop of a role_arn/source_profile chain
+	rec.addGroup(probes.RoleChain{Region: region})
+
+	// Credential-bearing environment variables that override one another
+	rec.addGroup(probes.EnvCredentials{Region: region})
+
+	// Which link of the default credential chain won, and what it shadows
+	rec.addCheck(probes.CredentialSource{Region: region})
+
+	// Which STS endpoint credential exchanges use, and whether it is reachable
+	rec.runCheck(probes.STSEndpoint{Region: region, FIPS: opts.fips})
+
+	// The control plane request unsigned and then signed, to tell network
+	// problems from credential problems
+	rec.runCheck(probes.UnsignedRequest{Region: region, FIPS: opts.fips})
+	rec.runCheck(probes.SignedRequest{Region: region, FIPS: opts.fips})
+
+	// Bedrock API access check
+	rec.runGroup(probes.BedrockAccess{Region: region})
+
+	// ANTHROPIC_MODEL in this region, or the nearest region that has it
+	rec.runGroup(probes.ModelRegion{Region: region, Search: !opts.noExternalProbes})
+
+	// LEGACY or retired model IDs in the configured models
+	rec.runGroup(probes.ModelLifecycleCheck{Region: region})
+
+	// Bare model IDs where a cross-region profile is required, and the reverse
+	rec.runGroup(probes.ModelIDShape{Region: region})
+
+	// Inference profile ARNs shared from another account
+	rec.runGroup(probes.CrossAccount{Region: region})
+
+	// Maximum session length of an assumed role
+	rec.runGroup(probes.SessionDuration{Region: region})
+
+	// Small/fast model for background tasks, possibly in another region
+	rec.runGroup(probes.SmallFastModel{Region: region})
+
+	// Every member region of a cross-region inference profile
+	rec.runGroup(probes.ProfileRegions{Region: region})
+
+	// Opt-in IAM policy simulation for the actions Claude Code needs
+	if opts.simulateIAM {
+		rec.runCheck(probes.IAMSimulation{Region: region})
+	}
+
+	// Opt-in governance check for model invocation logging
+	if opts.governance {
+		rec.runCheck(probes.InvocationLogging{Region: region})
+	}
+
+	// Service Quotas check for Claude request/token limits
+	rec.runCheck(probes.ServiceQuotas{Region: region})
+
+	// Opt-in usage against quotas from CloudWatch metrics
+	if opts.usage {
+		rec.runCheck(probes.Usage{Region: region})
+	}
+
+	// Opt-in throttling probe (never worse than warn)
+	if opts.throttleProbe {
+		rec.runCheck(probes.Throttling{Region: region, Count: opts.throttleProbeCount})
+	}
+
+	// Opt-in token counting probe (never worse than warn)
+	if opts.countTokens {
+		rec.runCheck(probes.CountTokens{Region: region})
+	}
+
+	// Opt-in, billed benchmark (never worse than warn)
+	if opts.benchmark {
+		rec.runCheck(probes.Benchmark{Region: region, Runs: opts.benchmarkRuns})
+	}
+}
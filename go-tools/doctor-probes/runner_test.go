@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+func TestRecorderSeverity(t *testing.T) {
+	severity := map[string]probes.SeverityRule{
+		"bedrock.throttling": {Status: "warn", Source: "--severity"},
+		"tls.revocation":     {Status: "suppress", Source: "the doctor config"},
+		"time.sync":          {Status: "fail", Source: "--severity"},
+	}
+
+	tests := []struct {
+		in         probes.CheckResult
+		status     string
+		message    string
+		suppressed bool
+	}{
+		{
+			in:      probes.CheckResult{ID: "bedrock.throttling", Status: "fail", Message: "Throttled"},
+			status:  "warn",
+			message: "Throttled (fail, reported as warn by --severity)",
+		},
+		{
+			in:      probes.CheckResult{ID: "time.sync", Status: "warn", Message: "Stale"},
+			status:  "fail",
+			message: "Stale (warn, reported as fail by --severity)",
+		},
+		{
+			in:         probes.CheckResult{ID: "tls.revocation", Status: "warn", Message: "OCSP unreachable"},
+			status:     "warn",
+			message:    "OCSP unreachable (suppressed by the doctor config)",
+			suppressed: true,
+		},
+		{
+			in:      probes.CheckResult{ID: "bedrock.throttling", Status: "warn", Message: "Slow"},
+			status:  "warn",
+			message: "Slow",
+		},
+		{
+			in:      probes.CheckResult{ID: "tls.revocation", Status: "pass", Message: "OK"},
+			status:  "pass",
+			message: "OK",
+		},
+		{
+			in:      probes.CheckResult{ID: "bedrock.throttling", Status: "skip", Message: "skipped"},
+			status:  "skip",
+			message: "skipped",
+		},
+		{
+			in:      probes.CheckResult{ID: "region", Status: "fail", Message: "No region"},
+			status:  "fail",
+			message: "No region",
+		},
+	}
+	for _, tt := range tests {
+		rec := newRecorder(options{env: probes.NewEnv(probes.RunSettings{}), severity: severity})
+		rec.add(tt.in)
+		r := rec.results[0]
+		if r.Status != tt.status || r.Message != tt.message || r.Suppressed != tt.suppressed {
+			t.Errorf("%s %s: status, message, suppressed = %s, %q, %v; want %s, %q, %v",
+				tt.in.ID, tt.in.Status, r.Status, r.Message, r.Suppressed, tt.status, tt.message, tt.suppressed)
+		}
+		want := probes.SeverityForStatus(tt.status)
+		if tt.suppressed {
+			want = "info"
+		}
+		if r.Severity != want {
+			t.Errorf("%s %s: severity = %s, want %s", tt.in.ID, tt.in.Status, r.Severity, want)
+		}
+		if _, blocked := rec.failed[tt.in.ID]; blocked != (tt.status == "fail") {
+			t.Errorf("%s %s: --fail-fast sees the check as failed = %v, want %v", tt.in.ID, tt.in.Status, blocked, tt.status == "fail")
+		}
+	}
+}
+
+// sleepCheck is a check that takes d.
+type sleepCheck struct {
+	id string
+	d  time.Duration
+}
+
+func (c sleepCheck) ID() string   { return c.id }
+func (c sleepCheck) Name() string { return c.id }
+func (c sleepCheck) Run(ctx context.Context, env probes.Env) probes.CheckResult {
+	time.Sleep(c.d)
+	return probes.CheckResult{ID: c.id, Name: c.id, Status: "pass"}
+}
+
+// sleepGroup is a group whose one call takes d and yields two results.
+type sleepGroup struct{ d time.Duration }
+
+func (sleepGroup) ID() string   { return "group" }
+func (sleepGroup) Name() string { return "Group" }
+func (g sleepGroup) RunAll(ctx context.Context, env probes.Env) []probes.CheckResult {
+	time.Sleep(g.d)
+	return []probes.CheckResult{{ID: "group.a", Status: "pass"}, {ID: "group.b", Status: "pass"}}
+}
+
+func TestRecorderTiming(t *testing.T) {
+	const slow = 60 * time.Millisecond
+	rec := newRecorder(options{env: probes.NewEnv(probes.RunSettings{})})
+	time.Sleep(slow) // before the first check: no check's time
+	rec.addCheck(sleepCheck{id: "fast"})
+	time.Sleep(slow) // between checks: no check's time either
+	rec.runCheck(sleepCheck{id: "slow", d: slow})
+	rec.addGroup(sleepGroup{d: slow})
+
+	got := map[string]float64{}
+	for _, r := range rec.results {
+		got[r.ID] = r.DurationMs
+	}
+	if got["fast"] >= float64(slow.Milliseconds())/2 {
+		t.Errorf("fast check took %vms; the time before and between checks was counted", got["fast"])
+	}
+	if got["slow"] < float64(slow.Milliseconds()) {
+		t.Errorf("slow check took %vms, want at least %v", got["slow"], slow)
+	}
+	// The results of one group call share its time rather than split it.
+	if got["group.a"] < float64(slow.Milliseconds()) || got["group.a"] != got["group.b"] {
+		t.Errorf("group results took %vms and %vms, want %v or more each", got["group.a"], got["group.b"], slow)
+	}
+}
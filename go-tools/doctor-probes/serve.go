@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// Upper bounds (seconds) of the check duration histogram buckets.
+var durationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30}
+
+type durationHistogram struct {
+	name   string   // of the check's latest result
+	counts []uint64 // cumulative per bucket
+	sum    float64
+	count  uint64
+}
+
+func (h *durationHistogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// metricsExporter holds the latest results in Prometheus exposition form.
+// Series are keyed by check ID, since names repeat across endpoints and
+// targets; the name is a label of its own.
+type metricsExporter struct {
+	mu        sync.Mutex
+	region    string
+	results   []probes.CheckResult
+	durations map[string]*durationHistogram // by check ID
+	lastRun   time.Time
+}
+
+func newMetricsExporter(region string) *metricsExporter {
+	return &metricsExporter{region: region, durations: make(map[string]*durationHistogram)}
+}
+
+func (e *metricsExporter) update(results []probes.CheckResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.results = results
+	e.lastRun = time.Now()
+	for _, r := range results {
+		h, ok := e.durations[r.ID]
+		if !ok {
+			h = &durationHistogram{}
+			e.durations[r.ID] = h
+		}
+		h.name = r.Name
+		h.observe(r.DurationMs / 1000)
+	}
+}
+
+func statusValue(status string) int {
+	switch status {
+	case "warn":
+		return 1
+	case "fail":
+		return 2
+	case "skip":
+		return 3
+	default:
+		return 0
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	region := labelEscaper.Replace(e.region)
+
+	fmt.Fprintln(w, "# HELP bcce_check_status Check status (0=pass, 1=warn, 2=fail, 3=skipped).")
+	fmt.Fprintln(w, "# TYPE bcce_check_status gauge")
+	for _, r := range e.results {
+		fmt.Fprintf(w, "bcce_check_status{check=\"%s\",name=\"%s\",region=\"%s\"} %d\n", labelEscaper.Replace(r.ID), labelEscaper.Replace(r.Name), region, statusValue(r.Status))
+	}
+
+	fmt.Fprintln(w, "# HELP bcce_check_duration_seconds Time taken by each check.")
+	fmt.Fprintln(w, "# TYPE bcce_check_duration_seconds histogram")
+	ids := make([]string, 0, len(e.durations))
+	for id := range e.durations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		h := e.durations[id]
+		labels := fmt.Sprintf("check=\"%s\",name=\"%s\",region=\"%s\"", labelEscaper.Replace(id), labelEscaper.Replace(h.name), region)
+		for i, le := range durationBuckets {
+			fmt.Fprintf(w, "bcce_check_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, le, h.counts[i])
+		}
+		fmt.Fprintf(w, "bcce_check_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "bcce_check_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(w, "bcce_check_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	if !e.lastRun.IsZero() {
+		fmt.Fprintln(w, "# HELP bcce_last_run_timestamp_seconds Unix time of the last completed check run.")
+		fmt.Fprintln(w, "# TYPE bcce_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(w, "bcce_last_run_timestamp_seconds %d\n", e.lastRun.Unix())
+	}
+}
+
+// healthz reports 200 unless the latest run had failures (or hasn't finished).
+func (e *metricsExporter) healthz(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lastRun.IsZero() {
+		http.Error(w, "no completed run yet", http.StatusServiceUnavailable)
+		return
+	}
+	hasFailures, hasWarnings := output.Summarize(e.results)
+	switch {
+	case hasFailures:
+		http.Error(w, "fail", http.StatusServiceUnavailable)
+	case hasWarnings:
+		fmt.Fprintln(w, "warn")
+	default:
+		fmt.Fprintln(w, "pass")
+	}
+}
+
+// serveChecks runs one round of checks for the exporter; tests replace it.
+var serveChecks = runChecks
+
+// runServe runs the checks every opts.serveInterval and serves the results
+// on opts.serve until SIGINT/SIGTERM.
+func runServe(opts options) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	exporter := newMetricsExporter(probes.CurrentRegion(opts.region))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	mux.HandleFunc("/healthz", exporter.healthz)
+	srv := &http.Server{Addr: opts.serve, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	// The check loop runs independently so slow checks never block scrapes.
+	check := serveChecks
+	go func() {
+		for {
+			exporter.update(check(opts))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(opts.serveInterval):
+			}
+		}
+	}()
+
+	log.Printf("Serving doctor metrics on %s/metrics (interval %s)", opts.serve, opts.serveInterval)
+
+	code := 0
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		log.Printf("Metrics server failed: %v", err)
+		code = 1
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Metrics server shutdown: %v", err)
+	}
+	return code
+}
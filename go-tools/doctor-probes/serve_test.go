@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	exporter := newMetricsExporter("us-east-1")
+	exporter.update([]probes.CheckResult{
+		{ID: "dns.sts", Name: "DNS - STS", Status: "pass", DurationMs: 40},
+		{ID: "say.hi", Name: `Say "hi"`, Status: "warn", DurationMs: 700},
+		{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "fail", DurationMs: 3000},
+	})
+	// Two checks share a name; each keeps its own series.
+	exporter.update([]probes.CheckResult{
+		{ID: "dns.sts", Name: "DNS - STS", Status: "pass", DurationMs: 60},
+		{ID: "https.bedrock", Name: "HTTPS Connectivity", Status: "pass", DurationMs: 200},
+	})
+
+	srv := httptest.NewServer(exporter)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	out := string(body)
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Errorf("content type = %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE bcce_check_status gauge\nbcce_check_status{check=\"dns.sts\",name=\"DNS - STS\",region=\"us-east-1\"} 0\n",
+		`bcce_check_status{check="https.bedrock",name="HTTPS Connectivity",region="us-east-1"} 0` + "\n# HELP",
+		"# TYPE bcce_check_duration_seconds histogram\n",
+		`bcce_check_duration_seconds_bucket{check="dns.sts",name="DNS - STS",region="us-east-1",le="0.1"} 2`,
+		`bcce_check_duration_seconds_bucket{check="dns.sts",name="DNS - STS",region="us-east-1",le="+Inf"} 2`,
+		`bcce_check_duration_seconds_sum{check="dns.sts",name="DNS - STS",region="us-east-1"} 0.1`,
+		`bcce_check_duration_seconds_count{check="dns.sts",name="DNS - STS",region="us-east-1"} 2`,
+		`bcce_check_duration_seconds_bucket{check="https.bedrock",name="HTTPS Connectivity",region="us-east-1",le="0.5"} 1`,
+		`bcce_check_duration_seconds_count{check="https.bedrock",name="HTTPS Connectivity",region="us-east-1"} 1`,
+		`bcce_check_duration_seconds_bucket{check="https.bedrock_runtime",name="HTTPS Connectivity",region="us-east-1",le="2.5"} 0`,
+		`bcce_check_duration_seconds_bucket{check="https.bedrock_runtime",name="HTTPS Connectivity",region="us-east-1",le="5"} 1`,
+		`bcce_check_duration_seconds_count{check="https.bedrock_runtime",name="HTTPS Connectivity",region="us-east-1"} 1`,
+		`bcce_check_duration_seconds_bucket{check="say.hi",name="Say \"hi\"",region="us-east-1",le="1"} 1`,
+		"# TYPE bcce_last_run_timestamp_seconds gauge\nbcce_last_run_timestamp_seconds ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exposition does not contain %q:\n%s", want, out)
+		}
+	}
+	// Only the latest run sets the status gauges.
+	if strings.Contains(out, `bcce_check_status{check="https.bedrock_runtime"`) {
+		t.Errorf("status of a check from an older run is still exported:\n%s", out)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []probes.CheckResult // nil means no completed run
+		code    int
+		body    string
+	}{
+		{name: "no run yet", code: http.StatusServiceUnavailable, body: "no completed run yet"},
+		{name: "all pass", results: []probes.CheckResult{{Status: "pass"}}, code: http.StatusOK, body: "pass"},
+		{name: "warnings", results: []probes.CheckResult{{Status: "pass"}, {Status: "warn"}}, code: http.StatusOK, body: "warn"},
+		{name: "failures", results: []probes.CheckResult{{Status: "warn"}, {Status: "fail"}}, code: http.StatusServiceUnavailable, body: "fail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := newMetricsExporter("us-east-1")
+			if tt.results != nil {
+				exporter.update(tt.results)
+			}
+			rec := httptest.NewRecorder()
+			exporter.healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if rec.Code != tt.code || strings.TrimSpace(rec.Body.String()) != tt.body {
+				t.Errorf("healthz = %d %q, want %d %q", rec.Code, rec.Body.String(), tt.code, tt.body)
+			}
+		})
+	}
+}
+
+// freeAddr returns a loopback address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// waitServing polls addr until it serves the fake run, or returns false if
+// runServe exits or nothing shows up within five seconds.
+func waitServing(addr string, done <-chan int) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.Contains(string(body), `check="serve-test"`) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunServeShutsDownOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs to signal its own process")
+	}
+	saved := serveChecks
+	serveChecks = func(options) []probes.CheckResult {
+		return []probes.CheckResult{{ID: "serve-test", Name: "Serve Test", Status: "pass"}}
+	}
+	t.Cleanup(func() { serveChecks = saved })
+
+	// Another process can take a free port before runServe listens on it;
+	// then runServe fails (or someone else answers), so try a new one.
+	var addr string
+	done := make(chan int, 1)
+	for attempt := 1; ; attempt++ {
+		addr = freeAddr(t)
+		go func(addr string) { done <- runServe(options{serve: addr, serveInterval: time.Hour}) }(addr)
+		if waitServing(addr, done) {
+			break
+		}
+		if attempt == 3 {
+			t.Fatal("runServe never served the first run")
+		}
+	}
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(syscall.SIGTERM)
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("exit code = %d, want 0 after SIGTERM", code)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("runServe did not return after SIGTERM")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Errorf("%s still accepts connections after shutdown", addr)
+	}
+}
+
+func TestRunServeListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	saved := serveChecks
+	serveChecks = func(options) []probes.CheckResult { return nil }
+	t.Cleanup(func() { serveChecks = saved })
+
+	if code := runServe(options{serve: ln.Addr().String(), serveInterval: time.Hour}); code != 1 {
+		t.Errorf("exit code = %d, want 1 when the address is taken", code)
+	}
+}
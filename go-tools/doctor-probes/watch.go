@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/output"
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+type watchIteration struct {
+	Iteration int `json:"iteration"`
+	output.Report
+}
+
+// redTracker accumulates how long each check spent in the fail state, by
+// check ID.
+type redTracker struct {
+	since map[string]time.Time
+	total map[string]time.Duration
+	names map[string]string // latest name of each ID
+}
+
+func newRedTracker() *redTracker {
+	return &redTracker{since: make(map[string]time.Time), total: make(map[string]time.Duration), names: make(map[string]string)}
+}
+
+func (t *redTracker) observe(results []probes.CheckResult, now time.Time) {
+	failing := make(map[string]bool)
+	for _, r := range results {
+		if r.Status != "fail" {
+			continue
+		}
+		failing[r.ID] = true
+		t.names[r.ID] = r.Name
+		if _, ok := t.since[r.ID]; !ok {
+			t.since[r.ID] = now
+		}
+	}
+	for id, start := range t.since {
+		if !failing[id] {
+			t.total[id] += now.Sub(start)
+			delete(t.since, id)
+		}
+	}
+}
+
+func (t *redTracker) finish(now time.Time) map[string]time.Duration {
+	for id, start := range t.since {
+		t.total[id] += now.Sub(start)
+	}
+	t.since = make(map[string]time.Time)
+	return t.total
+}
+
+// printWatchDelta prints the run's counts and the results that are new or
+// changed since previous, the statuses of the last run by check ID.
+func printWatchDelta(iteration int, now time.Time, results []probes.CheckResult, previous map[string]string, quiet bool) {
+	pass, warn, fail, skip := 0, 0, 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case "pass":
+			pass++
+		case "warn":
+			warn++
+		case "fail":
+			fail++
+		case "skip":
+			skip++
+		}
+	}
+	fmt.Printf("[%s] run %d: %d pass, %d warn, %d fail, %d skipped\n", now.Format("15:04:05"), iteration, pass, warn, fail, skip)
+
+	for _, r := range results {
+		before, seen := previous[r.ID]
+		changed := seen && before != r.Status
+		if seen && !changed {
+			continue
+		}
+		// Recoveries to pass are always shown; quiet only hides first sightings.
+		if quiet && r.Status == "pass" && !changed {
+			continue
+		}
+		change := ""
+		if changed {
+			change = fmt.Sprintf(" (was %s)", before)
+		}
+		fmt.Printf("  %s %s: %s%s\n", consoleStyle.Mark(r.Status), r.Name, r.Message, change)
+	}
+}
+
+// watchChecks runs one iteration of the watch; tests replace it.
+var watchChecks = runChecks
+
+// runWatch re-runs the checks every interval until interrupted and returns
+// the exit code for the final iteration.
+func runWatch(opts options) int {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	tracker := newRedTracker()
+	previous := make(map[string]string)
+	started := time.Now()
+	var last []probes.CheckResult
+	enc := json.NewEncoder(os.Stdout)
+
+	for iteration := 1; ; iteration++ {
+		begun := time.Now()
+		done := make(chan []probes.CheckResult, 1)
+		go func() { done <- watchChecks(opts) }()
+
+		var results []probes.CheckResult
+		select {
+		case results = <-done:
+		case <-sigs:
+			return finishWatch(opts, tracker, iteration-1, started, last)
+		}
+
+		now := time.Now()
+		tracker.observe(results, now)
+		if opts.format == "json" {
+			enc.Encode(watchIteration{Iteration: iteration, Report: newReport(opts, results, begun, now)})
+		} else {
+			printWatchDelta(iteration, now, results, previous, opts.quiet)
+		}
+
+		previous = make(map[string]string, len(results))
+		for _, r := range results {
+			previous[r.ID] = r.Status
+		}
+		last = results
+
+		select {
+		case <-time.After(opts.watch):
+		case <-sigs:
+			return finishWatch(opts, tracker, iteration, started, last)
+		}
+	}
+}
+
+func finishWatch(opts options, tracker *redTracker, runs int, started time.Time, last []probes.CheckResult) int {
+	red := tracker.finish(time.Now())
+
+	// Keep stdout clean JSON Lines in JSON mode.
+	out := os.Stdout
+	if opts.format == "json" {
+		out = os.Stderr
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Watch stopped after %d runs (%s)\n", runs, time.Since(started).Round(time.Second))
+	if len(red) == 0 {
+		fmt.Fprintln(out, "No checks failed during the watch")
+	} else {
+		ids := make([]string, 0, len(red))
+		named := make(map[string]int)
+		for id := range red {
+			ids = append(ids, id)
+			named[tracker.names[id]]++
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			a, b := tracker.names[ids[i]], tracker.names[ids[j]]
+			return a < b || a == b && ids[i] < ids[j]
+		})
+		fmt.Fprintln(out, "Time spent failing:")
+		for _, id := range ids {
+			name := tracker.names[id]
+			if named[name] > 1 {
+				name += " (" + id + ")"
+			}
+			fmt.Fprintf(out, "  %s %s: %s\n", consoleStyle.Mark("fail"), name, red[id].Round(time.Second))
+		}
+	}
+
+	return opts.exitPolicy.code(last)
+}
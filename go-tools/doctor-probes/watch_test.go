@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"bcce/go-tools/doctor-probes/internal/probes"
+)
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected and returns
+// what it wrote to each.
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	read := func(f **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *f
+		*f = w
+		out := make(chan string)
+		go func() {
+			b, _ := io.ReadAll(r)
+			out <- string(b)
+		}()
+		return func() string {
+			*f = saved
+			w.Close()
+			return <-out
+		}
+	}
+	stopOut := read(&os.Stdout)
+	stopErr := read(&os.Stderr)
+	fn()
+	return stopOut(), stopErr()
+}
+
+func TestRedTracker(t *testing.T) {
+	t0 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	// The last two checks share a name, as per-endpoint checks do.
+	results := func(statuses ...string) []probes.CheckResult {
+		checks := []probes.CheckResult{{ID: "dns.sts", Name: "DNS"}, {ID: "https.bedrock_runtime", Name: "HTTPS"}, {ID: "https.bedrock", Name: "HTTPS"}}
+		var out []probes.CheckResult
+		for i, status := range statuses {
+			checks[i].Status = status
+			out = append(out, checks[i])
+		}
+		return out
+	}
+
+	tracker := newRedTracker()
+	tracker.observe(results("fail", "pass", "pass"), at(0))
+	tracker.observe(results("fail", "fail", "warn"), at(10))
+	tracker.observe(results("pass", "fail", "pass"), at(30))
+	tracker.observe(results("fail", "fail", "pass"), at(40))
+	got := tracker.finish(at(60))
+
+	want := map[string]time.Duration{
+		"dns.sts":               30*time.Second + 20*time.Second, // 0-30s, then 40-60s
+		"https.bedrock_runtime": 50 * time.Second,
+	}
+	if len(got) != len(want) {
+		t.Errorf("time failing = %v, want %v", got, want)
+	}
+	for id, d := range want {
+		if got[id] != d {
+			t.Errorf("%s failed for %s, want %s", id, got[id], d)
+		}
+	}
+}
+
+// watchRuns makes runWatch see each of iterations in turn and then an
+// interrupt, as if the user pressed Ctrl-C during the next run.
+func watchRuns(t *testing.T, iterations ...[]probes.CheckResult) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs to interrupt its own process")
+	}
+	saved := watchChecks
+	interrupted, stop := make(chan struct{}), make(chan struct{})
+	t.Cleanup(func() {
+		// The last run is still blocked in the fake; let it go first.
+		select {
+		case <-interrupted:
+		case <-time.After(time.Second):
+		}
+		close(stop)
+		watchChecks = saved
+	})
+	run := 0
+	watchChecks = func(options) []probes.CheckResult {
+		if run < len(iterations) {
+			run++
+			return iterations[run-1]
+		}
+		close(interrupted)
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(os.Interrupt)
+		<-stop
+		return nil
+	}
+}
+
+var watchIterations = [][]probes.CheckResult{
+	{
+		{ID: "region", Name: "AWS_REGION", Status: "pass", Message: "Set to: us-east-1"},
+		{ID: "dns.sts", Name: "DNS - STS", Status: "fail", Message: "no such host"},
+		{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "warn", Message: "slow"},
+	},
+	{
+		{ID: "region", Name: "AWS_REGION", Status: "pass", Message: "Set to: us-east-1"},
+		{ID: "dns.sts", Name: "DNS - STS", Status: "pass", Message: "Resolved"},
+		{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "fail", Message: "timeout"},
+	},
+}
+
+func TestRunWatchConsole(t *testing.T) {
+	watchRuns(t, watchIterations...)
+	var code int
+	stdout, _ := captureOutput(t, func() {
+		code = runWatch(options{watch: time.Millisecond, exitPolicy: defaultExitPolicy})
+	})
+
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1 for the failing last run", code)
+	}
+	for _, want := range []string{
+		"run 1: 1 pass, 1 warn, 1 fail, 0 skipped\n  ✅ AWS_REGION: Set to: us-east-1\n  ❌ DNS - STS: no such host\n  ⚠️ HTTPS Connectivity: slow\n",
+		"run 2: 2 pass, 0 warn, 1 fail, 0 skipped\n  ✅ DNS - STS: Resolved (was fail)\n  ❌ HTTPS Connectivity: timeout (was warn)\n",
+		"Watch stopped after 2 runs",
+		"Time spent failing:\n  ❌ DNS - STS: 0s\n  ❌ HTTPS Connectivity: 0s\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRunWatchQuiet(t *testing.T) {
+	watchRuns(t, watchIterations...)
+	stdout, _ := captureOutput(t, func() {
+		runWatch(options{watch: time.Millisecond, quiet: true, exitPolicy: defaultExitPolicy})
+	})
+	if strings.Contains(stdout, "AWS_REGION") {
+		t.Errorf("quiet output shows a check that always passed:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "DNS - STS: Resolved (was fail)") {
+		t.Errorf("quiet output hides a recovery:\n%s", stdout)
+	}
+}
+
+func TestRunWatchSharedNames(t *testing.T) {
+	// Per-endpoint checks share a name; each keeps its own state.
+	run := []probes.CheckResult{
+		{ID: "https.bedrock_runtime", Name: "HTTPS Connectivity", Status: "fail", Message: "timeout"},
+		{ID: "https.bedrock", Name: "HTTPS Connectivity", Status: "pass", Message: "HTTP 403"},
+	}
+	watchRuns(t, run, run)
+	stdout, _ := captureOutput(t, func() {
+		runWatch(options{watch: time.Millisecond, exitPolicy: defaultExitPolicy})
+	})
+	if strings.Contains(stdout, "(was ") {
+		t.Errorf("unchanged checks reported as changed:\n%s", stdout)
+	}
+	if want := "run 2: 1 pass, 0 warn, 1 fail, 0 skipped\n\n"; !strings.Contains(stdout, want) {
+		t.Errorf("output does not contain %q:\n%s", want, stdout)
+	}
+	if want := "Time spent failing:\n  ❌ HTTPS Connectivity: 0s\n"; !strings.Contains(stdout, want) {
+		t.Errorf("output does not contain %q:\n%s", want, stdout)
+	}
+}
+
+func TestRunWatchJSONLines(t *testing.T) {
+	watchRuns(t, watchIterations...)
+	var code int
+	stdout, stderr := captureOutput(t, func() {
+		code = runWatch(options{watch: time.Millisecond, format: "json", exitPolicy: warnExitZeroExitPolicy})
+	})
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+
+	var lines []watchIteration
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	for scanner.Scan() {
+		var it watchIteration
+		if err := json.Unmarshal(scanner.Bytes(), &it); err != nil {
+			t.Fatalf("line %d is not JSON: %v\n%s", len(lines)+1, err, scanner.Text())
+		}
+		lines = append(lines, it)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d JSON lines, want 2:\n%s", len(lines), stdout)
+	}
+	for i, it := range lines {
+		if it.Iteration != i+1 || it.Timestamp.IsZero() || len(it.Results) != 3 {
+			t.Errorf("line %d = iteration %d at %v with %d results", i+1, it.Iteration, it.Timestamp, len(it.Results))
+		}
+	}
+	if lines[0].Results[1].Status != "fail" || lines[1].Results[1].Status != "pass" {
+		t.Errorf("DNS - STS went %s -> %s, want fail -> pass", lines[0].Results[1].Status, lines[1].Results[1].Status)
+	}
+	if !strings.Contains(stderr, "Time spent failing:") || strings.Contains(stdout, "Watch stopped") {
+		t.Errorf("summary must go to stderr in JSON mode; stderr:\n%s", stderr)
+	}
+}